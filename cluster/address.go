@@ -0,0 +1,55 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to choosing whether to
+ * reach a segment by its hostname or by its address.
+ */
+
+/*
+ * An AddressSelection returns the name or IP address to use to connect to a
+ * segment.  Deployments that route admin traffic over a different interface
+ * than the one named by Hostname can use PreferAddress or any custom function
+ * with this signature.  A Cluster with a nil AddressSelection uses
+ * PreferHostname, as Hostname was always used before AddressSelection existed.
+ */
+type AddressSelection func(segment SegConfig) string
+
+var PreferHostname AddressSelection = func(segment SegConfig) string {
+	return segment.Hostname
+}
+
+// Address may be empty, e.g. for a SegConfig read from an older gpsegconfig_dump file
+var PreferAddress AddressSelection = func(segment SegConfig) string {
+	if segment.Address != "" {
+		return segment.Address
+	}
+	return segment.Hostname
+}
+
+func (cluster *Cluster) GetAddressForSegment(segment SegConfig) string {
+	if cluster.AddressSelection == nil {
+		return PreferHostname(segment)
+	}
+	return cluster.AddressSelection(segment)
+}
+
+func (cluster *Cluster) GetAddressForContent(contentID int, role ...string) string {
+	segConfig := getSegmentByRole(cluster.ByContent[contentID], role...)
+	if segConfig == nil {
+		return ""
+	}
+	return cluster.GetAddressForSegment(*segConfig)
+}
+
+/*
+ * All segments on a host are assumed to share an address, so the address for
+ * a host is that of the first segment on it.  If the host is not in the
+ * cluster, the hostname is returned unchanged.
+ */
+func (cluster *Cluster) GetAddressForHost(hostname string) string {
+	segments := cluster.ByHost[hostname]
+	if len(segments) == 0 {
+		return hostname
+	}
+	return cluster.GetAddressForSegment(*segments[0])
+}
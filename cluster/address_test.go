@@ -0,0 +1,81 @@
+package cluster_test
+
+import (
+	"os/user"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/address tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "cdw", Address: "cdw-admin", DataDir: "/data/gpseg-1", Role: "p"}
+	remoteSegOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 6000, Hostname: "sdw1", Address: "10.0.0.1", DataDir: "/data/gpseg0", Role: "p"}
+	remoteSegTwo := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 6000, Hostname: "sdw2", Address: "", DataDir: "/data/gpseg1", Role: "p"}
+	var testCluster *cluster.Cluster
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, remoteSegOne, remoteSegTwo})
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("Address accessors", func() {
+		It("uses hostnames by default", func() {
+			Expect(testCluster.GetAddressForContent(0)).To(Equal("sdw1"))
+			Expect(testCluster.GetAddressForHost("sdw1")).To(Equal("sdw1"))
+		})
+		It("uses addresses, falling back to hostnames, with PreferAddress", func() {
+			testCluster.AddressSelection = cluster.PreferAddress
+			Expect(testCluster.GetAddressForContent(0)).To(Equal("10.0.0.1"))
+			Expect(testCluster.GetAddressForHost("sdw1")).To(Equal("10.0.0.1"))
+			Expect(testCluster.GetAddressForContent(1)).To(Equal("sdw2"))
+		})
+		It("uses a custom address selection function", func() {
+			testCluster.AddressSelection = func(segment cluster.SegConfig) string {
+				return segment.Hostname + "-admin.example.com"
+			}
+			Expect(testCluster.GetAddressForSegment(remoteSegTwo)).To(Equal("sdw2-admin.example.com"))
+		})
+		It("returns the hostname for a host not in the cluster and nothing for a missing content", func() {
+			testCluster.AddressSelection = cluster.PreferAddress
+			Expect(testCluster.GetAddressForHost("otherhost")).To(Equal("otherhost"))
+			Expect(testCluster.GetAddressForContent(5)).To(Equal(""))
+		})
+	})
+	Describe("Command generation", func() {
+		It("sends per-segment ssh commands to the selected address", func() {
+			testCluster.AddressSelection = cluster.PreferAddress
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(_ int) string { return "ls" })
+			Expect(commandList).To(HaveLen(2))
+			Expect(commandList[0].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no testUser@10.0.0.1 ls"))
+			Expect(commandList[1].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no testUser@sdw2 ls"))
+		})
+		It("sends per-host ssh commands to the selected address but keeps local commands local", func() {
+			testCluster.AddressSelection = cluster.PreferAddress
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR, func(_ string) string { return "ls" })
+			Expect(commandList).To(HaveLen(3))
+			Expect(commandList[0].Host).To(Equal("cdw"))
+			Expect(commandList[0].CommandString).To(Equal("bash -c ls"))
+			Expect(commandList[1].Host).To(Equal("sdw1"))
+			Expect(commandList[1].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no testUser@10.0.0.1 ls"))
+		})
+		It("verifies ssh access using the selected address", func() {
+			testExecutor := &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+			testCluster.Executor = testExecutor
+			testCluster.AddressSelection = cluster.PreferAddress
+
+			testCluster.VerifySSHAccess(cluster.ON_HOSTS)
+
+			commandStrings := []string{}
+			for _, command := range testExecutor.ClusterCommands[0] {
+				commandStrings = append(commandStrings, command.CommandString)
+			}
+			Expect(strings.Join(commandStrings, "\n")).To(ContainSubstring("testUser@10.0.0.1 true"))
+		})
+	})
+})
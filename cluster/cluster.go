@@ -16,6 +16,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
@@ -23,14 +24,34 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Messages from this package are logged to the "cluster" domain, so their verbosity can be set separately
+var logDomain = gplog.Domain("cluster")
+
 type Executor interface {
 	ExecuteLocalCommand(commandStr string) (string, error)
 	ExecuteLocalCommandWithContext(commandStr string, ctx context.Context) (string, error)
+	ExecuteLocalCommandWithOptions(cmd []string, opts LocalOptions) (LocalOutput, error)
 	ExecuteClusterCommand(scope Scope, commandList []ShellCommand) *RemoteOutput
 }
 
-// This type only exists to allow us to mock Execute[...]Command functions for testing
-type GPDBExecutor struct{}
+/*
+ * This type only exists to allow us to mock Execute[...]Command functions for
+ * testing.  If Tracer is set, ExecuteClusterCommand creates a span for each
+ * execution with a child span for each command, under TraceContext if set.
+ * If Sessions is set, ssh commands to hosts with a live session are sent over
+ * that session.  Hooks are called as each command starts and completes; see
+ * hooks.go.  Options determine whether a batch of commands stops early when
+ * some of them fail; see policy.go.  Progress determines whether the progress
+ * of a batch is logged or reported as it runs; see progress.go.
+ */
+type GPDBExecutor struct {
+	Tracer       Tracer
+	TraceContext context.Context
+	Sessions     *HostSessionManager
+	Hooks        []CommandHooks
+	Options      ExecutionOptions
+	Progress     ProgressOptions
+}
 
 /*
  * A Cluster object stores information about the cluster in three ways:
@@ -38,30 +59,54 @@ type GPDBExecutor struct{}
  *   list of segment information, and is ordered by content id.
  * - ByContent is a map of content id to the single corresponding segment.
  * - ByHost is a map of hostname to all of the segments on that host.
+ * - ByDbid is a map of dbid to the single corresponding segment.
  * The maps are only stored for efficient lookup; Segments is the "source of
  * truth" for the cluster.  The maps actually hold pointers to the SegConfigs
  * in Segments, so modifying Segments will modify the maps as well.
+ * TablespacesByDbid is only populated if SetTablespaces is called.
+ *
+ * If GroupByHost is set, GenerateAndExecuteCommand runs per-segment commands
+ * with one ssh session per host rather than one per segment.  If
+ * AggregateErrors is set, CheckClusterError logs one error per distinct
+ * failure rather than one per failed command.  If LogPerformanceSummary is
+ * set, CheckClusterError also logs a line of timing statistics at the Verbose
+ * level, naming the slowest hosts; see stats.go.
+ *
+ * Transport determines how commands are sent to other hosts, and GPHome and
+ * GPHomeByHost whether they source greenplum_path.sh first; see transport.go.
+ * HostResources gives the resources of hosts whose hardware differs from the
+ * host the utility runs on, for GetHostParallelism; see parallelism.go.
  */
 type Cluster struct {
-	ContentIDs []int
-	Hostnames  []string
-	Segments   []SegConfig
-	ByContent  map[int][]*SegConfig
-	ByHost     map[string][]*SegConfig
+	ContentIDs            []int
+	Hostnames             []string
+	Segments              []SegConfig
+	ByContent             map[int][]*SegConfig
+	ByHost                map[string][]*SegConfig
+	ByDbid                map[int]*SegConfig
+	TablespacesByDbid     map[int][]Tablespace
+	AddressSelection      AddressSelection
+	GroupByHost           bool
+	AggregateErrors       bool
+	LogPerformanceSummary bool
+	Transport             Transport
+	GPHome                string
+	GPHomeByHost          map[string]string
+	HostResources         map[string]HostResources
 	Executor
 }
 
 type SegConfig struct {
-	DbID          int
-	ContentID     int
-	Role          string
-	PreferredRole string
-	Mode          string
-	Status        string
-	Port          int
-	Hostname      string
-	Address       string
-	DataDir       string
+	DbID          int    `json:"dbid" yaml:"dbid"`
+	ContentID     int    `json:"content" yaml:"content"`
+	Role          string `json:"role" yaml:"role"`
+	PreferredRole string `json:"preferred_role" yaml:"preferred_role"`
+	Mode          string `json:"mode" yaml:"mode"`
+	Status        string `json:"status" yaml:"status"`
+	Port          int    `json:"port" yaml:"port"`
+	Hostname      string `json:"hostname" yaml:"hostname"`
+	Address       string `json:"address" yaml:"address"`
+	DataDir       string `json:"datadir" yaml:"datadir"`
 }
 
 /*
@@ -82,21 +127,27 @@ type SegConfig struct {
  * INCLUDE_MIRRORS: Include mirror segments and hosts in the command list.
  * EXCLUDE_MIRRORS: Exclude mirror segments and hosts from the command list.
  *
+ * ON_STANDBY:      Execute a single command for the standby coordinator, on its
+ *                  host, in place of the segments or hosts selected by the
+ *                  coordinator and mirror bits, which are ignored.  The command
+ *                  list is empty if the cluster has no standby.
+ *
  * A scope is composed of one or more of these values bitwise-OR'd together to
  * obtain a final scope, which has the following bitmask:
  *
- *   /------- INCLUDE_MIRRORS (1) or EXCLUDE_MIRRORS (0)
- *   |/------ INCLUDE_COORDINATOR (1) or EXCLUDE_COORDINATOR (0)
- *   ||/----- ON_LOCAL (1) or ON_REMOTE (0)
- *   |||/---- ON_HOSTS (1) or ON_SEGMENTS (0)
- *   ||||
- *   vvvv
- *   0000
+ *   /-------- ON_STANDBY (1)
+ *   |/------- INCLUDE_MIRRORS (1) or EXCLUDE_MIRRORS (0)
+ *   ||/------ INCLUDE_COORDINATOR (1) or EXCLUDE_COORDINATOR (0)
+ *   |||/----- ON_LOCAL (1) or ON_REMOTE (0)
+ *   ||||/---- ON_HOSTS (1) or ON_SEGMENTS (0)
+ *   |||||
+ *   vvvvv
+ *   00000
  *
  * For instance, to execute a command on all hosts including the coordinator host,
  * you would pass a function the scope ON_HOSTS | INCLUDE_COORDINATOR.
  *
- * The default scope is 0000, to execute a command on all primary segments,
+ * The default scope is 00000, to execute a command on all primary segments,
  * equivalent to ON_SEGMENTS | ON_REMOTE | EXCLUDE_COORDINATOR | EXCLUDE_MIRRORS,
  * though by convention only ON_SEGMENTS need be passed to a function.
  *
@@ -125,6 +176,7 @@ const (
 	ON_LOCAL            Scope = 1 << 2
 	EXCLUDE_MIRRORS     Scope = 0
 	INCLUDE_MIRRORS     Scope = 1 << 3
+	ON_STANDBY          Scope = 1 << 4
 )
 
 func scopeIsSegments(scope Scope) bool {
@@ -159,6 +211,10 @@ func scopeIncludesMirrors(scope Scope) bool {
 	return scope&INCLUDE_MIRRORS == INCLUDE_MIRRORS
 }
 
+func scopeIsStandby(scope Scope) bool {
+	return scope&ON_STANDBY == ON_STANDBY
+}
+
 /*
  * A ShellCommand stores a command to be executed (in both executable and
  * display form), as well as the results of the command execution and the
@@ -179,6 +235,7 @@ type ShellCommand struct {
 	Stderr        string
 	Error         error
 	Completed     bool
+	Duration      time.Duration
 }
 
 func NewShellCommand(scope Scope, content int, host string, command []string) ShellCommand {
@@ -186,7 +243,7 @@ func NewShellCommand(scope Scope, content int, host string, command []string) Sh
 		Scope:         scope,
 		Content:       content,
 		Host:          host,
-		Command:       exec.Command(command[0], command[1:]...),
+		Command:       operating.System.ExecCommand(command[0], command[1:]...),
 		CommandString: strings.Join(command, " "),
 	}
 }
@@ -223,11 +280,18 @@ func NewRemoteOutput(scope Scope, numErrors int, commands []ShellCommand) *Remot
  * Base cluster functions
  */
 
+/*
+ * The segment configuration need not include the coordinator (content -1),
+ * e.g. when it holds only mirrors; functions that rely on the coordinator,
+ * such as GetCoordinator, return nil or empty values if it is missing.
+ * Cluster.Validate reports a configuration without one.
+ */
 func NewCluster(segConfigs []SegConfig) *Cluster {
 	cluster := Cluster{}
 	cluster.Segments = segConfigs
 	cluster.ByContent = make(map[int][]*SegConfig, 0)
 	cluster.ByHost = make(map[string][]*SegConfig, 0)
+	cluster.ByDbid = make(map[int]*SegConfig, 0)
 	cluster.Executor = &GPDBExecutor{}
 
 	for i := range cluster.Segments {
@@ -243,6 +307,7 @@ func NewCluster(segConfigs []SegConfig) *Cluster {
 			segmentList[0], segmentList[1] = segmentList[1], segmentList[0]
 		}
 		cluster.ByHost[segment.Hostname] = append(cluster.ByHost[segment.Hostname], segment)
+		cluster.ByDbid[segment.DbID] = segment
 		if len(cluster.ByHost[segment.Hostname]) == 1 { // Only add each hostname once
 			cluster.Hostnames = append(cluster.Hostnames, segment.Hostname)
 		}
@@ -259,6 +324,12 @@ func NewCluster(segConfigs []SegConfig) *Cluster {
  * "generator" argument to this function can accept one of two types:
  * - func(int) []string, which takes a content id, for per-segment commands
  * - func(string) []string, which takes a hostname, for per-host commands
+ * Either may also return LocalOptions, as func(int) ([]string, LocalOptions)
+ * or func(string) ([]string, LocalOptions), to run each command with its own
+ * environment and working directory; Timeout and Stdin are ignored.  As these
+ * commands are run as given, the options apply to the local process, which
+ * for an ssh command is ssh itself; GenerateSSHCommandList applies them on
+ * the host the command runs on instead.
  * The function uses a type switch to identify the right one, and panics if
  * an invalid function type is passed in via programmer error.
  * This method makes it easier for the user to pass in whichever function fits
@@ -266,16 +337,33 @@ func NewCluster(segConfigs []SegConfig) *Cluster {
  * content and hostname regardless of scope or using some sort of helper struct.
  */
 func (cluster *Cluster) GenerateCommandList(scope Scope, generator interface{}) []ShellCommand {
-	commands := []ShellCommand{}
+	var generateForContent func(content int) ([]string, LocalOptions)
+	var generateForHost func(host string) ([]string, LocalOptions)
 	switch generateCommand := generator.(type) {
 	case func(content int) []string:
+		generateForContent = func(content int) ([]string, LocalOptions) { return generateCommand(content), LocalOptions{} }
+	case func(content int) ([]string, LocalOptions):
+		generateForContent = generateCommand
+	case func(host string) []string:
+		generateForHost = func(host string) ([]string, LocalOptions) { return generateCommand(host), LocalOptions{} }
+	case func(host string) ([]string, LocalOptions):
+		generateForHost = generateCommand
+	default:
+		gplog.Fatal(nil, "Generator function passed to GenerateCommandList had an invalid function header.")
+	}
+	if scopeIsStandby(scope) {
+		return cluster.generateStandbyCommandList(scope, generateForContent, generateForHost)
+	}
+	commands := []ShellCommand{}
+	if generateForContent != nil {
 		for _, content := range cluster.ContentIDs {
 			if content == -1 && scopeExcludesCoordinator(scope) {
 				continue
 			}
-			commands = append(commands, NewShellCommand(scope, content, "", generateCommand(content)))
+			command, opts := generateForContent(content)
+			commands = append(commands, newShellCommandWithOptions(scope, content, "", command, opts))
 		}
-	case func(host string) []string:
+	} else {
 		for _, host := range cluster.Hostnames {
 			hostHasOneContent := len(cluster.GetContentsForHost(host)) == 1
 			if host == cluster.GetHostForContent(-1, "p") && scopeExcludesCoordinator(scope) && hostHasOneContent {
@@ -286,78 +374,160 @@ func (cluster *Cluster) GenerateCommandList(scope Scope, generator interface{})
 				// Only exclude the standby coordinator host if there are no segments there
 				continue
 			}
-			commands = append(commands, NewShellCommand(scope, -2, host, generateCommand(host)))
+			command, opts := generateForHost(host)
+			commands = append(commands, newShellCommandWithOptions(scope, -2, host, command, opts))
 		}
-	default:
-		gplog.Fatal(nil, "Generator function passed to GenerateCommandList had an invalid function header.")
 	}
 	return commands
 }
 
+/*
+ * With ON_STANDBY, the command list holds a single command for the standby
+ * coordinator.  Per-segment commands are generated for content -1, and unlike
+ * other per-segment commands they have Host set, so that they can be told
+ * apart from commands for the coordinator.
+ */
+func (cluster *Cluster) generateStandbyCommandList(scope Scope, generateForContent func(content int) ([]string, LocalOptions), generateForHost func(host string) ([]string, LocalOptions)) []ShellCommand {
+	standby, hasStandby := cluster.GetStandbyCoordinator()
+	if !hasStandby {
+		return []ShellCommand{}
+	}
+	if generateForContent != nil {
+		command, opts := generateForContent(-1)
+		return []ShellCommand{newShellCommandWithOptions(scope, -1, standby.Hostname, command, opts)}
+	}
+	command, opts := generateForHost(standby.Hostname)
+	return []ShellCommand{newShellCommandWithOptions(scope, -2, standby.Hostname, command, opts)}
+}
+
+/*
+ * This function generates one command per segment in scope, rather than one
+ * per content or host, for operations that apply to individual primaries and
+ * mirrors (e.g. recovery).  Mirrors, including the standby coordinator, are
+ * only included if the scope includes mirrors, and only the standby
+ * coordinator is included if the scope is ON_STANDBY.  Commands are ordered by
+ * dbid, and unlike with GenerateCommandList, both Content and Host are set.
+ */
+func (cluster *Cluster) GenerateCommandListPerDbid(scope Scope, generator func(dbid int) []string) []ShellCommand {
+	dbids := make([]int, 0, len(cluster.ByDbid))
+	for dbid := range cluster.ByDbid {
+		dbids = append(dbids, dbid)
+	}
+	sort.Ints(dbids)
+
+	commands := []ShellCommand{}
+	for _, dbid := range dbids {
+		segment := cluster.ByDbid[dbid]
+		isMirror := cluster.ByContent[segment.ContentID][0] != segment
+		if scopeIsStandby(scope) {
+			if segment.ContentID != -1 || !isMirror {
+				continue
+			}
+		} else if segment.ContentID == -1 && scopeExcludesCoordinator(scope) {
+			continue
+		} else if isMirror && scopeExcludesMirrors(scope) {
+			continue
+		}
+		commands = append(commands, NewShellCommand(scope, segment.ContentID, segment.Hostname, generator(dbid)))
+	}
+	return commands
+}
+
+// Remote commands check host keys according to the policy set with SetHostKeyPolicy
 func ConstructSSHCommand(useLocal bool, host string, cmd string) []string {
 	if useLocal {
 		return []string{"bash", "-c", cmd}
 	}
 	currentUser, _ := operating.System.CurrentUser()
 	user := currentUser.Username
-	return []string{"ssh", "-o", "StrictHostKeyChecking=no", fmt.Sprintf("%s@%s", user, host), cmd}
+	args := append([]string{"ssh"}, hostKeyOptions()...)
+	return append(args, fmt.Sprintf("%s@%s", user, host), cmd)
 }
 
 /*
  * This function essentially wraps GenerateCommandList such that commands to be
- * executed on other hosts are sent through SSH and local commands use Bash.
+ * executed on other hosts are sent through the cluster's Transport, which is
+ * SSH by default, and local commands use Bash.  Whether a command is local is
+ * determined by hostname, but remote commands are sent to the address chosen
+ * by the cluster's AddressSelection.  As with GenerateCommandList, generators
+ * may also return LocalOptions, as func(int) (string, LocalOptions) or
+ * func(string) (string, LocalOptions); each command is wrapped with
+ * LocalOptions.WrapCommand, so its environment and working directory apply
+ * on the host it runs on, whether locally or remotely.
  */
 func (cluster *Cluster) GenerateSSHCommandList(scope Scope, generator interface{}) []ShellCommand {
 	var commands []ShellCommand
-	localHost := cluster.GetHostForContent(-1)
 	switch generateCommand := generator.(type) {
 	case func(content int) string:
 		commands = cluster.GenerateCommandList(scope, func(content int) []string {
-			useLocal := (cluster.GetHostForContent(content) == localHost || scopeIsLocal(scope))
-			cmd := generateCommand(content)
-			return ConstructSSHCommand(useLocal, cluster.GetHostForContent(content), cmd)
+			return cluster.BuildContentCommand(scope, content, generateCommand(content))
 		})
 	case func(host string) string:
 		commands = cluster.GenerateCommandList(scope, func(host string) []string {
-			useLocal := (host == localHost || scopeIsLocal(scope))
-			cmd := generateCommand(host)
-			return ConstructSSHCommand(useLocal, host, cmd)
+			return cluster.BuildHostCommand(scope, host, generateCommand(host))
+		})
+	case func(content int) (string, LocalOptions):
+		commands = cluster.GenerateCommandList(scope, func(content int) []string {
+			cmd, opts := generateCommand(content)
+			return cluster.BuildContentCommand(scope, content, opts.WrapCommand(cmd))
+		})
+	case func(host string) (string, LocalOptions):
+		commands = cluster.GenerateCommandList(scope, func(host string) []string {
+			cmd, opts := generateCommand(host)
+			return cluster.BuildHostCommand(scope, host, opts.WrapCommand(cmd))
 		})
 	}
 	return commands
 }
 
 func (executor *GPDBExecutor) ExecuteLocalCommand(commandStr string) (string, error) {
-	output, err := exec.Command("bash", "-c", commandStr).CombinedOutput()
+	output, err := operating.System.ExecCommand("bash", "-c", commandStr).CombinedOutput()
 	return string(output), err
 }
 
 func (executor *GPDBExecutor) ExecuteLocalCommandWithContext(commandStr string, ctx context.Context) (string, error) {
-	output, err := exec.CommandContext(ctx, "bash", "-c", commandStr).CombinedOutput()
+	output, err := operating.System.ExecCommandContext(ctx, "bash", "-c", commandStr).CombinedOutput()
 	return string(output), err
 }
 
 /*
- * This function just executes all of the commands passed to it in parallel; it
+ * This function just executes all of the commands passed to it in parallel,
+ * subject to the MaxInFlight and MaxInFlightPerHost ExecutionOptions; it
  * doesn't care about the scope of the command except to pass that on to the
  * RemoteOutput after execution.
- * TODO: Add batching to prevent bottlenecks when executing in a huge cluster.
  */
 func (executor *GPDBExecutor) ExecuteClusterCommand(scope Scope, commandList []ShellCommand) *RemoteOutput {
 	length := len(commandList)
 	finished := make(chan int)
 	numErrors := 0
+	ctx, clusterSpan := executor.startClusterSpan(scope, length)
+	batch := newCommandBatch(executor.Hooks, executor.Options, commandList)
+	scheduler := newCommandScheduler(executor.Options, batch.hosts)
+	reporter := newProgressReporter(executor.Progress, length)
 	for i := range commandList {
 		go func(index int) {
+			scheduler.acquire(index)
 			command := commandList[index]
-			var stderr bytes.Buffer
+			span := executor.startCommandSpan(ctx, command)
+			start := operating.System.Now()
+			var stdout, stderr bytes.Buffer
 			cmd := command.Command
+			if executor.Sessions != nil {
+				cmd = executor.Sessions.commandForSession(cmd)
+			}
+			cmd.Stdout = &stdout
 			cmd.Stderr = &stderr
-			out, err := cmd.Output()
-			command.Stdout = string(out)
+			err := batch.start(index, command, cmd)
+			if err == nil {
+				err = batch.finish(index, cmd.Wait())
+			}
+			scheduler.release(index)
+			command.Stdout = stdout.String()
 			command.Stderr = stderr.String()
 			command.Error = err
 			command.Completed = true
+			command.Duration = operating.System.Now().Sub(start)
+			endCommandSpan(span, command, command.Duration)
 			commandList[index] = command
 			finished <- index
 		}(i)
@@ -367,8 +537,13 @@ func (executor *GPDBExecutor) ExecuteClusterCommand(scope Scope, commandList []S
 		if commandList[index].Error != nil {
 			numErrors++
 		}
+		batch.complete(index, commandList[index])
+		reporter.commandCompleted(commandList[index])
 	}
-	return NewRemoteOutput(scope, numErrors, commandList)
+	endClusterSpan(clusterSpan, numErrors)
+	remoteOutput := NewRemoteOutput(scope, numErrors, commandList)
+	batch.completeBatch(remoteOutput)
+	return remoteOutput
 }
 
 /*
@@ -380,33 +555,71 @@ func (executor *GPDBExecutor) ExecuteClusterCommand(scope Scope, commandList []S
  *    - e.g. running multiple scps on coordinator to push a file to all segments
  */
 func (cluster *Cluster) GenerateAndExecuteCommand(verboseMsg string, scope Scope, generator interface{}) *RemoteOutput {
-	gplog.Verbose(verboseMsg)
+	logDomain.Verbose(verboseMsg)
+	if generateCommand, ok := generator.(func(content int) string); ok && cluster.GroupByHost && !scopeIsStandby(scope) {
+		return cluster.executeGroupedByHost(scope, generateCommand)
+	}
 	commandList := cluster.GenerateSSHCommandList(scope, generator)
 	return cluster.ExecuteClusterCommand(scope, commandList)
 }
 
 func (cluster *Cluster) CheckClusterError(remoteOutput *RemoteOutput, finalErrMsg string, messageFunc interface{}, noFatal ...bool) {
+	if cluster.LogPerformanceSummary {
+		logDomain.Verbose("Performance summary: %s", remoteOutput.Stats(numSlowestHostsToLog))
+	}
 	if remoteOutput.NumErrors == 0 {
 		return
 	}
+	if cluster.AggregateErrors {
+		cluster.logAggregatedErrors(remoteOutput, messageFunc)
+	} else {
+		cluster.logCommandErrors(remoteOutput, messageFunc, false)
+	}
+
+	if len(noFatal) == 1 && noFatal[0] == true {
+		logDomain.Error(finalErrMsg)
+	} else {
+		LogFatalClusterError(finalErrMsg, remoteOutput.Scope, remoteOutput.NumErrors)
+	}
+}
+
+func (cluster *Cluster) logCommandErrors(remoteOutput *RemoteOutput, messageFunc interface{}, debugOnly bool) {
+	fileVerbosity, shellVerbosity := gplog.LOGERROR, gplog.LOGVERBOSE
+	if debugOnly {
+		fileVerbosity, shellVerbosity = gplog.LOGDEBUG, gplog.LOGDEBUG
+	}
 	for _, failedCommand := range remoteOutput.FailedCommands {
 		errStr := fmt.Sprintf("with error %s: %s", failedCommand.Error, failedCommand.Stderr)
 		switch getMessage := messageFunc.(type) {
 		case func(content int) string:
 			content := failedCommand.Content
 			host := cluster.GetHostForContent(content)
-			gplog.Custom(gplog.LOGERROR, gplog.LOGVERBOSE, "%s on segment %d on host %s %s", getMessage(content), content, host, errStr)
+			logDomain.Custom(fileVerbosity, shellVerbosity, "%s on segment %d on host %s %s", getMessage(content), content, host, errStr)
 		case func(host string) string:
 			host := failedCommand.Host
-			gplog.Custom(gplog.LOGERROR, gplog.LOGVERBOSE, "%s on host %s %s", getMessage(host), host, errStr)
+			logDomain.Custom(fileVerbosity, shellVerbosity, "%s on host %s %s", getMessage(host), host, errStr)
 		}
-		gplog.Verbose("Command was: %s", failedCommand.CommandString)
+		logDomain.Custom(shellVerbosity, shellVerbosity, "Command was: %s", failedCommand.CommandString)
 	}
+}
 
-	if len(noFatal) == 1 && noFatal[0] == true {
-		gplog.Error(finalErrMsg)
-	} else {
-		LogFatalClusterError(finalErrMsg, remoteOutput.Scope, remoteOutput.NumErrors)
+/*
+ * The error for each group of commands that failed the same way is logged
+ * once, listing the contents or hosts on which it occurred, and the messages
+ * for the individual commands are only logged at debug level.
+ */
+func (cluster *Cluster) logAggregatedErrors(remoteOutput *RemoteOutput, messageFunc interface{}) {
+	cluster.logCommandErrors(remoteOutput, messageFunc, true)
+	_, perHost := messageFunc.(func(host string) string)
+	for _, group := range remoteOutput.GroupErrors(cluster) {
+		numFailed, segMsg, where := len(group.Contents), "segment", FormatContentRanges(group.Contents)
+		if perHost || len(group.Contents) == 0 {
+			numFailed, segMsg, where = len(group.Commands), "host", strings.Join(group.Hosts, ", ")
+		}
+		if numFailed != 1 {
+			segMsg += "s"
+		}
+		logDomain.Custom(gplog.LOGERROR, gplog.LOGVERBOSE, "Error %s occurred on %d %s: %s", group.Error, numFailed, segMsg, where)
 	}
 }
 
@@ -448,6 +661,43 @@ func getSegmentByRole(segmentList []*SegConfig, role ...string) *SegConfig {
 	return segmentList[0]
 }
 
+func (cluster *Cluster) GetCoordinator() *SegConfig {
+	return getSegmentByRole(cluster.ByContent[-1])
+}
+
+func (cluster *Cluster) GetStandbyCoordinator() (*SegConfig, bool) {
+	standby := getSegmentByRole(cluster.ByContent[-1], "m")
+	return standby, standby != nil
+}
+
+func (cluster *Cluster) HasStandby() bool {
+	_, hasStandby := cluster.GetStandbyCoordinator()
+	return hasStandby
+}
+
+// HasMirrors only considers segment mirrors, not the standby coordinator.
+func (cluster *Cluster) HasMirrors() bool {
+	for _, content := range cluster.ContentIDs {
+		if content != -1 && len(cluster.ByContent[content]) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (cluster *Cluster) GetSegByDbid(dbid int) (*SegConfig, bool) {
+	segConfig, ok := cluster.ByDbid[dbid]
+	return segConfig, ok
+}
+
+func (cluster *Cluster) GetHostForDbid(dbid int) string {
+	segConfig, ok := cluster.ByDbid[dbid]
+	if !ok {
+		return ""
+	}
+	return segConfig.Hostname
+}
+
 func (cluster *Cluster) GetDbidForContent(contentID int, role ...string) int {
 	segConfig := getSegmentByRole(cluster.ByContent[contentID], role...)
 	if segConfig == nil {
@@ -14,6 +14,7 @@ import (
 
 	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gp-common-go-libs/operating"
 	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 	"github.com/pkg/errors"
@@ -354,8 +355,66 @@ var _ = Describe("cluster/cluster tests", func() {
 			Expect(results[1]).To(Equal(localSegTwoValue))
 			Expect(results[2]).To(Equal(remoteSegOneValue))
 		})
+		DescribeTable("uses the query for the connected version",
+			func(versionStr string) {
+				segConnection, segMock := testhelper.CreateAndConnectMockDBWithVersion(1, versionStr)
+				testhelper.ExpectSegmentConfigQueryForVersion(segMock, versionStr, []cluster.SegConfig{localSegOneValue, localSegTwoValue})
+				results, err := cluster.GetSegmentConfiguration(segConnection, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(results).To(Equal([]cluster.SegConfig{localSegOneValue, localSegTwoValue}))
+				Expect(segMock.ExpectationsWereMet()).To(Succeed())
+			},
+			Entry("GPDB 5", "5.28.0"),
+			Entry("GPDB 6", "6.20.0"),
+			Entry("GPDB 7", "7.0.0"),
+		)
+		It("only matches the pg_filespace_entry query for GPDB 5", func() {
+			testhelper.SetDBVersion(connection, "6.20.0")
+			testhelper.ExpectSegmentConfigQueryForVersion(mock, "5.28.0", []cluster.SegConfig{localSegOneValue})
+			_, err := cluster.GetSegmentConfiguration(connection)
+			Expect(err).To(HaveOccurred())
+		})
 	})
 
+	Describe("GenerateCommandListPerDbid", func() {
+		var mirrorCluster *cluster.Cluster
+		BeforeEach(func() {
+			mirrorOne := cluster.SegConfig{DbID: 7, ContentID: 0, Port: 21000, Hostname: "remotehost1", DataDir: "/data/mirror/gpseg0", Role: "m"}
+			mirrorCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, standbyCoordinator, localSegOne, mirrorOne, remoteSegOne})
+		})
+		generator := func(dbid int) []string {
+			return []string{"touch", fmt.Sprintf("/tmp/dbid%d", dbid)}
+		}
+		It("returns one command per primary segment", func() {
+			commandList := mirrorCluster.GenerateCommandListPerDbid(cluster.ON_SEGMENTS, generator)
+			Expect(commandList).To(HaveLen(2))
+			Expect(commandList[0].CommandString).To(Equal("touch /tmp/dbid2"))
+			Expect(commandList[0].Content).To(Equal(0))
+			Expect(commandList[0].Host).To(Equal("localhost"))
+			Expect(commandList[1].CommandString).To(Equal("touch /tmp/dbid3"))
+			Expect(commandList[1].Host).To(Equal("remotehost1"))
+		})
+		It("includes mirrors and the standby coordinator if specified", func() {
+			commandList := mirrorCluster.GenerateCommandListPerDbid(cluster.ON_SEGMENTS|cluster.INCLUDE_MIRRORS|cluster.INCLUDE_COORDINATOR, generator)
+			dbidCommands := make([]string, len(commandList))
+			for i, command := range commandList {
+				dbidCommands[i] = command.CommandString
+			}
+			Expect(dbidCommands).To(Equal([]string{"touch /tmp/dbid1", "touch /tmp/dbid2", "touch /tmp/dbid3", "touch /tmp/dbid6", "touch /tmp/dbid7"}))
+		})
+		It("excludes the standby coordinator if mirrors are excluded", func() {
+			commandList := mirrorCluster.GenerateCommandListPerDbid(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, generator)
+			Expect(commandList).To(HaveLen(3))
+			Expect(commandList[0].Content).To(Equal(-1))
+			Expect(commandList[0].Host).To(Equal("localhost"))
+		})
+		It("returns only the standby coordinator with ON_STANDBY", func() {
+			commandList := mirrorCluster.GenerateCommandListPerDbid(cluster.ON_SEGMENTS|cluster.ON_STANDBY|cluster.INCLUDE_MIRRORS, generator)
+			Expect(commandList).To(HaveLen(1))
+			Expect(commandList[0].CommandString).To(Equal("touch /tmp/dbid6"))
+			Expect(commandList[0].Host).To(Equal("standbycoordinatorhost"))
+		})
+	})
 	Describe("GenerateSSHCommandList", func() {
 		coordinatorSegCmd := []string{"bash", "-c", "ls"}
 		localSegCmd := []string{"bash", "-c", "ls"}
@@ -446,6 +505,36 @@ var _ = Describe("cluster/cluster tests", func() {
 			Entry("returns a list of ssh commands for one local host and two remote hosts, including the coordinator host", cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR, true, false, standbyCoordinator, 0, 2),
 			Entry("returns a list of ssh commands for one local host and two remote hosts, excluding the coordinator host", cluster.ON_HOSTS, false, false, standbyCoordinator, 0, 2),
 		)
+		Context("with ON_STANDBY", func() {
+			It("returns a single command on the standby coordinator host for a per-segment generator", func() {
+				standbyCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, standbyCoordinator, localSegOne, remoteSegOne})
+				contents := make([]int, 0)
+
+				commandList := standbyCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS|cluster.ON_STANDBY, func(content int) string {
+					contents = append(contents, content)
+					return "ls"
+				})
+
+				Expect(contents).To(Equal([]int{-1}))
+				Expect(commandList).To(Equal([]cluster.ShellCommand{cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.ON_STANDBY, -1, "standbycoordinatorhost", standbyCoordinatorCmd)}))
+			})
+			It("returns a single command on the standby coordinator host for a per-host generator", func() {
+				standbyCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, standbyCoordinatorOnSegHost, localSegOne, remoteSegOne})
+
+				commandList := standbyCluster.GenerateSSHCommandList(cluster.ON_HOSTS|cluster.ON_STANDBY|cluster.INCLUDE_COORDINATOR, func(host string) string {
+					return "ls"
+				})
+
+				Expect(commandList).To(Equal([]cluster.ShellCommand{cluster.NewShellCommand(cluster.ON_HOSTS|cluster.ON_STANDBY|cluster.INCLUDE_COORDINATOR, -2, "remotehost1", remoteSegOneCmd)}))
+			})
+			It("returns no commands if there is no standby coordinator", func() {
+				commandList := testCluster.GenerateSSHCommandList(cluster.ON_HOSTS|cluster.ON_STANDBY, func(host string) string {
+					return "ls"
+				})
+
+				Expect(commandList).To(BeEmpty())
+			})
+		})
 	})
 	Describe("ExecuteLocalCommand", func() {
 		BeforeEach(func() {
@@ -471,6 +560,18 @@ var _ = Describe("cluster/cluster tests", func() {
 			Expect(output).To(ContainSubstring("some-non-existent-command: command not found\n"))
 			Expect(err.Error()).To(Equal("exit status 127"))
 		})
+		It("runs the command through operating.System.ExecCommand", func() {
+			defer func() { operating.System = operating.InitializeSystemFunctions() }()
+			runner := testhelper.MockExecCommand("some output", "", 0)
+			testCluster := cluster.Cluster{}
+			testCluster.Executor = &cluster.GPDBExecutor{}
+
+			output, err := testCluster.ExecuteLocalCommand("ls /tmp")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal("some output"))
+			Expect(runner.Commands).To(Equal([][]string{{"bash", "-c", "ls /tmp"}}))
+		})
 	})
 	Describe("ExecuteLocalCommandWithContext", func() {
 		BeforeEach(func() {
@@ -499,13 +600,26 @@ var _ = Describe("cluster/cluster tests", func() {
 		It("kills the command if it runs beyond the timeout", func() {
 			testCluster := cluster.Cluster{}
 			commandStr := "while true; do echo Keep running; sleep 0.1; done"
-			ctx, _ := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
 			testCluster.Executor = &cluster.GPDBExecutor{}
 			output, err := testCluster.ExecuteLocalCommandWithContext(commandStr, ctx)
 			Expect(ctx.Err()).To(Equal(context.DeadlineExceeded))
 			Expect(err).To(HaveOccurred())
 			Expect(output).To(Equal("Keep running\nKeep running\n"))
 		})
+		It("runs the command through operating.System.ExecCommandContext", func() {
+			defer func() { operating.System = operating.InitializeSystemFunctions() }()
+			runner := testhelper.MockExecCommand("", "some error", 1)
+			testCluster := cluster.Cluster{}
+			testCluster.Executor = &cluster.GPDBExecutor{}
+
+			output, err := testCluster.ExecuteLocalCommandWithContext("ls /tmp", context.TODO())
+
+			Expect(err).To(MatchError("exit status 1"))
+			Expect(output).To(Equal("some error"))
+			Expect(runner.Commands).To(Equal([][]string{{"bash", "-c", "ls /tmp"}}))
+		})
 	})
 	Describe("ExecuteClusterCommand", func() {
 		BeforeEach(func() {
@@ -555,6 +669,22 @@ var _ = Describe("cluster/cluster tests", func() {
 				Expect(cmd.Completed).To(BeTrue())
 			}
 		})
+		It("runs commands created through operating.System.ExecCommand", func() {
+			defer func() { operating.System = operating.InitializeSystemFunctions() }()
+			runner := testhelper.MockExecCommand("some output", "some error", 2)
+			testCluster := cluster.Cluster{}
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"ssh", "remotehost1", "ls"}),
+			}
+			testCluster.Executor = &cluster.GPDBExecutor{}
+			clusterOutput := testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+
+			Expect(runner.Commands).To(Equal([][]string{{"ssh", "remotehost1", "ls"}}))
+			Expect(clusterOutput.NumErrors).To(Equal(1))
+			Expect(clusterOutput.Commands[0].Stdout).To(Equal("some output"))
+			Expect(clusterOutput.Commands[0].Stderr).To(Equal("some error"))
+			Expect(clusterOutput.Commands[0].Error).To(MatchError("exit status 2"))
+		})
 	})
 	Describe("CheckClusterError", func() {
 		var (
@@ -608,6 +738,24 @@ var _ = Describe("cluster/cluster tests", func() {
 			Entry("prints error messages for commands executed on coordinator to hosts, including coordinator", cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR|cluster.ON_LOCAL, true, false, false),
 			Entry("prints error messages for commands executed on coordinator to hosts, excluding coordinator", cluster.ON_HOSTS|cluster.ON_LOCAL, false, false, false),
 		)
+		It("logs one error per distinct failure if AggregateErrors is set", func() {
+			testCluster.AggregateErrors = true
+			permissionErr := errors.New("exit status 1")
+			commands := []cluster.ShellCommand{
+				{Scope: cluster.ON_SEGMENTS, Content: 0, CommandString: "rm /data/gpseg0/file", Error: permissionErr, Stderr: "rm: cannot remove '/data/gpseg0/file': Permission denied\n"},
+				{Scope: cluster.ON_SEGMENTS, Content: 1, CommandString: "rm /data/gpseg1/file", Error: permissionErr, Stderr: "rm: cannot remove '/data/gpseg1/file': Permission denied\n"},
+			}
+			remoteOutput = cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 2, commands)
+			defer gplog.SetErrorCode(0)
+
+			testCluster.CheckClusterError(remoteOutput, "Got an error", func(contentID int) string { return "Error received" }, true)
+
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-Error received on segment 0 on host localhost with error exit status 1: rm: cannot remove '/data/gpseg0/file'`))
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-Command was: rm /data/gpseg0/file`))
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-Error received on segment 1 on host remotehost1`))
+			Expect(logfile).To(gbytes.Say(`\[ERROR\]:-Error exit status 1: rm: cannot remove '<datadir>/file': Permission denied occurred on 2 segments: 0-1\n`))
+			Expect(logfile).To(gbytes.Say(`\[ERROR\]:-Got an error`))
+		})
 	})
 	Describe("LogFatalClusterError", func() {
 		It("logs an error for 1 segment (with coordinator)", func() {
@@ -659,6 +807,15 @@ var _ = Describe("cluster/cluster tests", func() {
 			Expect(newCluster.Segments[2].DataDir).To(Equal("/data/gpseg3"))
 			Expect(newCluster.GetHostForContent(3)).To(Equal("remotehost2"))
 		})
+		It("allows an empty segment configuration", func() {
+			newCluster := cluster.NewCluster([]cluster.SegConfig{})
+			Expect(newCluster.ContentIDs).To(BeEmpty())
+		})
+		It("allows a segment configuration without a coordinator", func() {
+			newCluster := cluster.NewCluster([]cluster.SegConfig{localSegOne, remoteSegOne})
+			Expect(newCluster.ContentIDs).To(Equal([]int{0, 1}))
+			Expect(newCluster.GetCoordinator()).To(BeNil())
+		})
 		It("ensures that modifying a segment value in Segments is reflected in ByContent and ByHost", func() {
 			newCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg})
 			newCluster.Segments[0].DataDir = "/new/dir"
@@ -709,5 +866,45 @@ var _ = Describe("cluster/cluster tests", func() {
 			Expect(mirrorCluster.GetPortsForHost("localhost")).To(Equal([]int{5432, 20000}))
 			Expect(mirrorCluster.GetDirsForHost("localhost")).To(Equal([]string{"/data/gpseg-1", "/data/primary/gpseg0"}))
 		})
+		It("returns segment information by dbid", func() {
+			seg, ok := mirrorCluster.GetSegByDbid(3)
+			Expect(ok).To(BeTrue())
+			Expect(seg.DataDir).To(Equal("/data/mirror/gpseg0"))
+			Expect(mirrorCluster.GetHostForDbid(3)).To(Equal("otherhost"))
+			Expect(mirrorCluster.GetHostForDbid(1)).To(Equal("localhost"))
+		})
+		It("returns no segment information for a nonexistent dbid", func() {
+			seg, ok := mirrorCluster.GetSegByDbid(10)
+			Expect(ok).To(BeFalse())
+			Expect(seg).To(BeNil())
+			Expect(mirrorCluster.GetHostForDbid(10)).To(Equal(""))
+		})
+		It("ensures that modifying a segment value in ByDbid is reflected in Segments", func() {
+			mirrorCluster.ByDbid[3].DataDir = "/new/dir"
+			Expect(mirrorCluster.Segments[2].DataDir).To(Equal("/new/dir"))
+			Expect(mirrorCluster.GetDirForContent(0, "m")).To(Equal("/new/dir"))
+		})
+		It("returns the coordinator", func() {
+			Expect(mirrorCluster.GetCoordinator()).To(Equal(&coordinatorSeg))
+		})
+		It("returns the standby coordinator if there is one", func() {
+			standbyCluster := cluster.NewCluster([]cluster.SegConfig{standbyCoordinator, coordinatorSeg, localSegOne})
+			standby, ok := standbyCluster.GetStandbyCoordinator()
+			Expect(ok).To(BeTrue())
+			Expect(standby).To(Equal(&standbyCoordinator))
+			Expect(standbyCluster.GetCoordinator()).To(Equal(&coordinatorSeg))
+			Expect(standbyCluster.HasStandby()).To(BeTrue())
+			Expect(standbyCluster.HasMirrors()).To(BeFalse())
+		})
+		It("reports that there is no standby coordinator if there is not one", func() {
+			standby, ok := mirrorCluster.GetStandbyCoordinator()
+			Expect(ok).To(BeFalse())
+			Expect(standby).To(BeNil())
+			Expect(mirrorCluster.HasStandby()).To(BeFalse())
+		})
+		It("reports whether the cluster has mirrors", func() {
+			Expect(mirrorCluster.HasMirrors()).To(BeTrue())
+			Expect(testCluster.HasMirrors()).To(BeFalse())
+		})
 	})
 })
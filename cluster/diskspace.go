@@ -0,0 +1,183 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to checking that segments
+ * have enough free disk space before an operation, e.g. a backup or restore.
+ */
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * A DirectorySpace describes the free space on the filesystem containing a
+ * single directory.  Filesystem is the filesystem's mount point.
+ */
+type DirectorySpace struct {
+	Path           string
+	Filesystem     string
+	AvailableBytes int64
+}
+
+/*
+ * A SegmentDiskSpace describes whether a single segment has enough free space
+ * for an operation.  Directories holds the segment's data directory followed
+ * by its tablespace directories, if the cluster's tablespaces have been set
+ * with SetTablespaces.
+ *
+ * The requirement is checked against the filesystem containing the data
+ * directory.  Several segments on a host may share that filesystem, so
+ * FilesystemRequiredBytes is the total required by every segment in scope
+ * whose data directory is on it, and ShortfallBytes is the amount by which
+ * that total exceeds AvailableBytes, or 0 if there is enough space.  All of the
+ * segments sharing the filesystem report the same shortfall.
+ */
+type SegmentDiskSpace struct {
+	DbID                    int
+	Content                 int
+	Host                    string
+	RequiredBytes           int64
+	FilesystemRequiredBytes int64
+	AvailableBytes          int64
+	ShortfallBytes          int64
+	Directories             []DirectorySpace
+}
+
+func (space SegmentDiskSpace) String() string {
+	message := fmt.Sprintf("content %d (dbid %d) on %s requires %d bytes", space.Content, space.DbID, space.Host, space.RequiredBytes)
+	if len(space.Directories) > 0 {
+		message += fmt.Sprintf(" on %s", space.Directories[0].Filesystem)
+	}
+	if space.FilesystemRequiredBytes != space.RequiredBytes {
+		message += fmt.Sprintf(" (%d bytes including other segments)", space.FilesystemRequiredBytes)
+	}
+	message += fmt.Sprintf(", %d bytes available", space.AvailableBytes)
+	if space.ShortfallBytes > 0 {
+		message += fmt.Sprintf(", %d bytes short", space.ShortfallBytes)
+	}
+	return message
+}
+
+/*
+ * A DiskSpaceReport wraps the RemoteOutput of the df commands with the free
+ * space for each segment, in the same order as RemoteOutput.Commands.  The
+ * entries for segments whose command failed have no Directories and are not
+ * counted as having a shortfall, so callers must check NumErrors as well.
+ */
+type DiskSpaceReport struct {
+	*RemoteOutput
+	Segments []SegmentDiskSpace
+}
+
+// Shortfalls returns the segments that do not have enough free space
+func (report *DiskSpaceReport) Shortfalls() []SegmentDiskSpace {
+	shortfalls := make([]SegmentDiskSpace, 0)
+	for _, segment := range report.Segments {
+		if segment.ShortfallBytes > 0 {
+			shortfalls = append(shortfalls, segment)
+		}
+	}
+	return shortfalls
+}
+
+/*
+ * Parses the output of "df -P -k" for the given directories, which has a
+ * header line followed by one line per directory.  The mount point is the last
+ * field and may contain spaces, so the fields are located relative to the
+ * capacity field, which ends in "%".
+ */
+func parseDfOutput(output string, dirs []string) ([]DirectorySpace, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != len(dirs)+1 {
+		return nil, errors.Errorf("Expected %d lines of df output, got %d", len(dirs)+1, len(lines))
+	}
+	spaces := make([]DirectorySpace, len(dirs))
+	for i, line := range lines[1:] {
+		fields := strings.Fields(line)
+		capacityIndex := -1
+		for j := len(fields) - 2; j >= 3; j-- {
+			if strings.HasSuffix(fields[j], "%") {
+				capacityIndex = j
+				break
+			}
+		}
+		if capacityIndex == -1 {
+			return nil, errors.Errorf("Unable to parse df output line %q", line)
+		}
+		availableKB, err := strconv.ParseInt(fields[capacityIndex-1], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("Unable to parse df output line %q", line)
+		}
+		spaces[i] = DirectorySpace{
+			Path:           dirs[i],
+			Filesystem:     strings.Join(fields[capacityIndex+1:], " "),
+			AvailableBytes: availableKB * 1024,
+		}
+	}
+	return spaces, nil
+}
+
+/*
+ * CheckDiskSpace runs df against the data directory and tablespace directories
+ * of each segment in scope and compares the free space on each data
+ * directory's filesystem against the bytes that requiredBytesFunc returns for
+ * the segment's content.  Mirrors and the standby are only included if scope
+ * includes mirrors, and the coordinator is only included if scope includes the
+ * coordinator.
+ */
+func (cluster *Cluster) CheckDiskSpace(scope Scope, requiredBytesFunc func(content int) int64) *DiskSpaceReport {
+	// The generator is called once per command, in the same order as the commands
+	segments := make([]SegmentDiskSpace, 0)
+	dirsForCommand := make([][]string, 0)
+	commands := cluster.GenerateCommandListPerDbid(scope, func(dbid int) []string {
+		segment := cluster.ByDbid[dbid]
+		segments = append(segments, SegmentDiskSpace{
+			DbID:          dbid,
+			Content:       segment.ContentID,
+			Host:          segment.Hostname,
+			RequiredBytes: requiredBytesFunc(segment.ContentID),
+		})
+		dirs := append([]string{segment.DataDir}, cluster.getTablespaceDirsForDbid(dbid)...)
+		dirsForCommand = append(dirsForCommand, dirs)
+		return cluster.BuildSegmentCommand(scope, *segment, shellJoin(append([]string{"df", "-P", "-k"}, dirs...)))
+	})
+
+	logDomain.Verbose("Checking free disk space on %d segments", len(commands))
+	remoteOutput := cluster.ExecuteClusterCommand(scope, commands)
+	type filesystemKey struct {
+		host       string
+		filesystem string
+	}
+	requiredByFilesystem := make(map[filesystemKey]int64)
+	for i := range remoteOutput.Commands {
+		command := &remoteOutput.Commands[i]
+		if command.Error != nil {
+			continue
+		}
+		dirs, err := parseDfOutput(command.Stdout, dirsForCommand[i])
+		if err != nil {
+			command.Error = err
+			remoteOutput.NumErrors++
+			remoteOutput.FailedCommands = append(remoteOutput.FailedCommands, command)
+			continue
+		}
+		segments[i].Directories = dirs
+		segments[i].AvailableBytes = dirs[0].AvailableBytes
+		requiredByFilesystem[filesystemKey{segments[i].Host, dirs[0].Filesystem}] += segments[i].RequiredBytes
+	}
+	for i := range segments {
+		if len(segments[i].Directories) == 0 {
+			continue
+		}
+		required := requiredByFilesystem[filesystemKey{segments[i].Host, segments[i].Directories[0].Filesystem}]
+		segments[i].FilesystemRequiredBytes = required
+		if required > segments[i].AvailableBytes {
+			segments[i].ShortfallBytes = required - segments[i].AvailableBytes
+		}
+	}
+	return &DiskSpaceReport{RemoteOutput: remoteOutput, Segments: segments}
+}
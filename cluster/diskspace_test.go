@@ -0,0 +1,95 @@
+package cluster_test
+
+import (
+	"os"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/diskspace tests", func() {
+	const dfOutput = `Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sdb1        104857600 52428800  52428800      50% /data disk
+`
+	var testCluster *cluster.Cluster
+	BeforeEach(func() {
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "p", Hostname: "cdw", DataDir: "/data/coordinator/gpseg-1"},
+			{DbID: 2, ContentID: 0, Role: "p", Hostname: "sdw1", DataDir: "/data/primary/gpseg0"},
+			{DbID: 3, ContentID: 1, Role: "p", Hostname: "sdw1", DataDir: "/data/primary/gpseg1"},
+			{DbID: 4, ContentID: 0, Role: "m", Hostname: "sdw2", DataDir: "/data/mirror/gpseg0"},
+		})
+		testCluster.Transport = cluster.LocalTransport{}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("CheckDiskSpace", func() {
+		It("runs df against the data and tablespace directories of each segment in scope", func() {
+			runner := testhelper.MockExecCommand(dfOutput, "", 0)
+			testCluster.SetTablespaces([]cluster.Tablespace{{Oid: 16384, Name: "fast", DbID: 2, Location: "/fast/tablespace one/2"}})
+
+			testCluster.CheckDiskSpace(cluster.ON_SEGMENTS, func(content int) int64 { return 0 })
+
+			Expect(runner.Commands).To(ConsistOf(
+				[]string{"bash", "-c", "df -P -k /data/primary/gpseg0 '/fast/tablespace one/2'"},
+				[]string{"bash", "-c", "df -P -k /data/primary/gpseg1"},
+			))
+		})
+		It("reports the shortfall for segments sharing a filesystem", func() {
+			testhelper.MockExecCommand(dfOutput, "", 0)
+			required := map[int]int64{-1: 1 << 20, 0: 30 << 30, 1: 30 << 30}
+
+			report := testCluster.CheckDiskSpace(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR|cluster.INCLUDE_MIRRORS, func(content int) int64 {
+				return required[content]
+			})
+
+			Expect(report.NumErrors).To(Equal(0))
+			Expect(report.Segments).To(HaveLen(4))
+			Expect(report.Segments[0].Directories).To(Equal([]cluster.DirectorySpace{{Path: "/data/coordinator/gpseg-1", Filesystem: "/data disk", AvailableBytes: 50 << 30}}))
+			Expect(report.Segments[0].ShortfallBytes).To(BeZero())
+			shortfalls := report.Shortfalls()
+			Expect(shortfalls).To(HaveLen(2))
+			Expect(shortfalls[0]).To(Equal(cluster.SegmentDiskSpace{
+				DbID:                    2,
+				Content:                 0,
+				Host:                    "sdw1",
+				RequiredBytes:           30 << 30,
+				FilesystemRequiredBytes: 60 << 30,
+				AvailableBytes:          50 << 30,
+				ShortfallBytes:          10 << 30,
+				Directories:             []cluster.DirectorySpace{{Path: "/data/primary/gpseg0", Filesystem: "/data disk", AvailableBytes: 50 << 30}},
+			}))
+			Expect(shortfalls[1].DbID).To(Equal(3))
+			Expect(shortfalls[0].String()).To(Equal("content 0 (dbid 2) on sdw1 requires 32212254720 bytes on /data disk (64424509440 bytes including other segments), 53687091200 bytes available, 10737418240 bytes short"))
+		})
+		It("reports segments whose df command fails or cannot be parsed as errors", func() {
+			testhelper.MockExecCommand("unexpected output", "", 0)
+
+			report := testCluster.CheckDiskSpace(cluster.ON_SEGMENTS, func(content int) int64 { return 1 })
+
+			Expect(report.NumErrors).To(Equal(2))
+			Expect(report.FailedCommands).To(HaveLen(2))
+			Expect(report.FailedCommands[0].Error).To(MatchError("Expected 2 lines of df output, got 1"))
+			Expect(report.Shortfalls()).To(BeEmpty())
+		})
+		It("checks the free space on local directories", func() {
+			dataDir := GinkgoT().TempDir()
+			localCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Hostname: "localhost", DataDir: dataDir},
+				{DbID: 2, ContentID: 0, Role: "p", Hostname: "localhost", DataDir: os.TempDir()},
+			})
+
+			report := localCluster.CheckDiskSpace(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(content int) int64 { return 1 })
+
+			Expect(report.NumErrors).To(Equal(0))
+			Expect(report.Segments[0].AvailableBytes).To(BeNumerically(">", 0))
+			Expect(report.Segments[0].Directories[0].Path).To(Equal(dataDir))
+			Expect(report.Shortfalls()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,145 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to reporting the commands
+ * a utility would run instead of running them.
+ */
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+/*
+ * A PlannedCommand is a command that would have been run.  For local commands,
+ * Local is true and Scope, Content, and Host are not meaningful; otherwise,
+ * as with a ShellCommand, Content is -2 for per-host commands and Host is ""
+ * for per-segment commands generated by GenerateCommandList.
+ */
+type PlannedCommand struct {
+	Scope         Scope
+	Content       int
+	Host          string
+	Local         bool
+	CommandString string
+}
+
+func (command PlannedCommand) String() string {
+	switch {
+	case command.Local:
+		return fmt.Sprintf("local: %s", command.CommandString)
+	case scopeIsHosts(command.Scope):
+		return fmt.Sprintf("host %s: %s", command.Host, command.CommandString)
+	case command.Host != "":
+		return fmt.Sprintf("segment %d on host %s: %s", command.Content, command.Host, command.CommandString)
+	}
+	return fmt.Sprintf("segment %d: %s", command.Content, command.CommandString)
+}
+
+/*
+ * A PlanStep holds the commands passed to a single Execute call, which would
+ * have been run in parallel.
+ */
+type PlanStep struct {
+	Scope    Scope
+	Local    bool
+	Commands []PlannedCommand
+}
+
+type PlanReport struct {
+	Steps []PlanStep
+}
+
+func (report PlanReport) NumCommands() int {
+	numCommands := 0
+	for _, step := range report.Steps {
+		numCommands += len(step.Commands)
+	}
+	return numCommands
+}
+
+func (report PlanReport) String() string {
+	lines := make([]string, 0)
+	for i, step := range report.Steps {
+		description := "local command"
+		if !step.Local {
+			description = fmt.Sprintf("%d commands on %s", len(step.Commands), scopeDescription(step.Scope))
+		}
+		lines = append(lines, fmt.Sprintf("Step %d: %s", i+1, description))
+		for _, command := range step.Commands {
+			lines = append(lines, "    "+command.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+/*
+ * A DryRunExecutor records every command passed to it in a PlanReport instead
+ * of running it.  Local commands return empty output and cluster commands
+ * return a RemoteOutput with no errors and no commands marked Completed, so a
+ * utility that depends on the output of earlier commands to decide what to do
+ * next may plan differently than it would actually run.
+ */
+type DryRunExecutor struct {
+	report PlanReport
+	mutex  sync.Mutex
+}
+
+/*
+ * EnableDryRun replaces the cluster's Executor with a DryRunExecutor, so that
+ * GenerateAndExecuteCommand and every other function that runs commands
+ * through the cluster records them instead, and returns it so that the plan
+ * can be retrieved afterward.
+ */
+func (cluster *Cluster) EnableDryRun() *DryRunExecutor {
+	executor := &DryRunExecutor{}
+	cluster.Executor = executor
+	return executor
+}
+
+// Report returns a copy of the commands recorded so far
+func (executor *DryRunExecutor) Report() PlanReport {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	steps := make([]PlanStep, len(executor.report.Steps))
+	copy(steps, executor.report.Steps)
+	return PlanReport{Steps: steps}
+}
+
+func (executor *DryRunExecutor) recordLocal(commandStr string) {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	executor.report.Steps = append(executor.report.Steps, PlanStep{
+		Scope:    ON_LOCAL,
+		Local:    true,
+		Commands: []PlannedCommand{{Scope: ON_LOCAL, Content: -2, Local: true, CommandString: commandStr}},
+	})
+}
+
+func (executor *DryRunExecutor) ExecuteLocalCommand(commandStr string) (string, error) {
+	executor.recordLocal(commandStr)
+	return "", nil
+}
+
+func (executor *DryRunExecutor) ExecuteLocalCommandWithContext(commandStr string, ctx context.Context) (string, error) {
+	executor.recordLocal(commandStr)
+	return "", nil
+}
+
+func (executor *DryRunExecutor) ExecuteLocalCommandWithOptions(cmd []string, opts LocalOptions) (LocalOutput, error) {
+	executor.recordLocal(opts.WrapCommand(strings.Join(cmd, " ")))
+	return LocalOutput{}, nil
+}
+
+func (executor *DryRunExecutor) ExecuteClusterCommand(scope Scope, commandList []ShellCommand) *RemoteOutput {
+	step := PlanStep{Scope: scope, Commands: make([]PlannedCommand, len(commandList))}
+	for i, command := range commandList {
+		step.Commands[i] = PlannedCommand{Scope: command.Scope, Content: command.Content, Host: command.Host, CommandString: command.CommandString}
+	}
+	executor.mutex.Lock()
+	executor.report.Steps = append(executor.report.Steps, step)
+	executor.mutex.Unlock()
+	return NewRemoteOutput(scope, 0, commandList)
+}
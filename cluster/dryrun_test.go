@@ -0,0 +1,78 @@
+package cluster_test
+
+import (
+	"os/user"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/dryrun tests", func() {
+	var testCluster *cluster.Cluster
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "gpadmin"}, nil }
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "p", Hostname: "cdw", DataDir: "/data/gpseg-1"},
+			{DbID: 2, ContentID: 0, Role: "p", Hostname: "sdw1", DataDir: "/data/gpseg0"},
+			{DbID: 3, ContentID: 1, Role: "p", Hostname: "sdw2", DataDir: "/data/gpseg1"},
+		})
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("EnableDryRun", func() {
+		It("records commands instead of running them", func() {
+			executor := testCluster.EnableDryRun()
+
+			output := testCluster.GenerateAndExecuteCommand("Removing files", cluster.ON_SEGMENTS, func(content int) string {
+				return "rm -rf " + testCluster.GetDirForContent(content) + "/tmp"
+			})
+			_, err := testCluster.ExecuteLocalCommand("touch /tmp/done")
+			hostOutput := testCluster.GenerateAndExecuteCommand("Checking hosts", cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR, func(host string) string {
+				return "hostname"
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.Commands[0].Completed).To(BeFalse())
+			Expect(hostOutput.Commands).To(HaveLen(3))
+			report := executor.Report()
+			Expect(report.NumCommands()).To(Equal(6))
+			Expect(report.Steps[0].Commands[0]).To(Equal(cluster.PlannedCommand{
+				Scope:         cluster.ON_SEGMENTS,
+				Content:       0,
+				CommandString: "ssh -o StrictHostKeyChecking=no gpadmin@sdw1 rm -rf /data/gpseg0/tmp",
+			}))
+			Expect(report.Steps[1].Local).To(BeTrue())
+			Expect(report.String()).To(Equal(`Step 1: 2 commands on segments
+    segment 0: ssh -o StrictHostKeyChecking=no gpadmin@sdw1 rm -rf /data/gpseg0/tmp
+    segment 1: ssh -o StrictHostKeyChecking=no gpadmin@sdw2 rm -rf /data/gpseg1/tmp
+Step 2: local command
+    local: touch /tmp/done
+Step 3: 3 commands on hosts,coordinator
+    host cdw: bash -c hostname
+    host sdw1: ssh -o StrictHostKeyChecking=no gpadmin@sdw1 hostname
+    host sdw2: ssh -o StrictHostKeyChecking=no gpadmin@sdw2 hostname`))
+		})
+		It("includes the environment and directory of local commands run with options", func() {
+			executor := testCluster.EnableDryRun()
+
+			_, err := testCluster.ExecuteLocalCommandWithOptions([]string{"gpstop", "-a"}, cluster.LocalOptions{Dir: "/home/gpadmin"})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(executor.Report().Steps[0].Commands[0].CommandString).To(Equal("cd '/home/gpadmin' && bash -c 'gpstop -a'"))
+		})
+		It("records segment commands with their hosts", func() {
+			executor := testCluster.EnableDryRun()
+
+			testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS, testCluster.GenerateCommandListPerDbid(cluster.ON_SEGMENTS, func(dbid int) []string {
+				return []string{"ls"}
+			}))
+
+			Expect(executor.Report().Steps[0].Commands[1].String()).To(Equal("segment 1 on host sdw2: ls"))
+		})
+	})
+})
@@ -0,0 +1,129 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to running the commands
+ * for all segments on a host through a single ssh session.
+ */
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const groupedResultMarker = "GP_GROUPED_SEGMENT_RESULT"
+
+/*
+ * The generated script runs each segment's command in the background with its
+ * output redirected to temporary files, then prints one line per segment with
+ * its exit status and base64-encoded stdout and stderr, so that the output of
+ * each command can be separated from the others however much it prints.
+ */
+func groupedCommandScript(indices []int, commands []string) string {
+	lines := []string{
+		`dir=$(mktemp -d) || exit 1`,
+		`trap 'rm -rf "$dir"' EXIT`,
+	}
+	for i, command := range commands {
+		lines = append(lines, fmt.Sprintf(`bash -c %s > "$dir/%d.out" 2> "$dir/%d.err" < /dev/null &`, shellQuote(command), i, i))
+		lines = append(lines, fmt.Sprintf(`pid%d=$!`, i))
+	}
+	for i := range commands {
+		lines = append(lines, fmt.Sprintf(`wait $pid%d; printf '%s %d %%d %%s %%s\n' $? "$(base64 < "$dir/%d.out" | tr -d '\n')" "$(base64 < "$dir/%d.err" | tr -d '\n')"`,
+			i, groupedResultMarker, indices[i], i, i))
+	}
+	return strings.Join(lines, "\n")
+}
+
+type groupedResult struct {
+	exitCode int
+	stdout   string
+	stderr   string
+}
+
+func parseGroupedResults(output string) map[int]groupedResult {
+	results := make(map[int]groupedResult)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, " ")
+		if len(fields) != 5 || fields[0] != groupedResultMarker {
+			continue
+		}
+		index, indexErr := strconv.Atoi(fields[1])
+		exitCode, codeErr := strconv.Atoi(fields[2])
+		stdout, stdoutErr := base64.StdEncoding.DecodeString(fields[3])
+		stderr, stderrErr := base64.StdEncoding.DecodeString(fields[4])
+		if indexErr != nil || codeErr != nil || stdoutErr != nil || stderrErr != nil {
+			continue
+		}
+		results[index] = groupedResult{exitCode: exitCode, stdout: string(stdout), stderr: string(stderr)}
+	}
+	return results
+}
+
+/*
+ * executeGroupedByHost runs the per-segment commands from generator with one
+ * command per host, and returns a RemoteOutput with one command per segment,
+ * as if the commands had been run separately.  If a host's command fails
+ * without reporting a segment's result, e.g. because ssh could not connect,
+ * that segment's command has the host command's error and stderr.
+ */
+func (cluster *Cluster) executeGroupedByHost(scope Scope, generator func(content int) string) *RemoteOutput {
+	segmentCommands := make([]ShellCommand, 0)
+	indicesByHost := make(map[string][]int)
+	hosts := make([]string, 0)
+	for _, content := range cluster.ContentIDs {
+		if content == -1 && scopeExcludesCoordinator(scope) {
+			continue
+		}
+		host := cluster.GetHostForContent(content)
+		if _, ok := indicesByHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		indicesByHost[host] = append(indicesByHost[host], len(segmentCommands))
+		segmentCommands = append(segmentCommands, ShellCommand{Scope: scope, Content: content, Host: host, CommandString: generator(content)})
+	}
+	sort.Strings(hosts)
+
+	hostScope := scope | ON_HOSTS
+	hostCommands := make([]ShellCommand, len(hosts))
+	for i, host := range hosts {
+		indices := indicesByHost[host]
+		commands := make([]string, len(indices))
+		for j, index := range indices {
+			commands[j] = segmentCommands[index].CommandString
+		}
+		script := groupedCommandScript(indices, commands)
+		hostCommands[i] = NewShellCommand(hostScope, -2, host, cluster.BuildHostCommand(scope, host, script))
+	}
+	hostOutput := cluster.ExecuteClusterCommand(hostScope, hostCommands)
+
+	numErrors := 0
+	for _, hostCommand := range hostOutput.Commands {
+		results := parseGroupedResults(hostCommand.Stdout)
+		for _, index := range indicesByHost[hostCommand.Host] {
+			command := &segmentCommands[index]
+			command.Completed = hostCommand.Completed
+			command.Duration = hostCommand.Duration
+			if result, ok := results[index]; ok {
+				command.Stdout = result.stdout
+				command.Stderr = result.stderr
+				if result.exitCode != 0 {
+					command.Error = errors.Errorf("exit status %d", result.exitCode)
+				}
+			} else if hostCommand.Error != nil {
+				command.Stderr = hostCommand.Stderr
+				command.Error = hostCommand.Error
+			} else if hostCommand.Completed {
+				command.Error = errors.Errorf("No result reported for segment %d on host %s", command.Content, command.Host)
+			}
+			if command.Error != nil {
+				numErrors++
+			}
+		}
+	}
+	return NewRemoteOutput(scope, numErrors, segmentCommands)
+}
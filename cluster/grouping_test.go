@@ -0,0 +1,100 @@
+package cluster_test
+
+import (
+	"os/user"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/grouping tests", func() {
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "gpadmin"}, nil }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("GenerateAndExecuteCommand with GroupByHost", func() {
+		It("runs all commands for a host together and reports each segment's result", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Hostname: "localhost", DataDir: "/data/gpseg-1"},
+				{DbID: 2, ContentID: 0, Role: "p", Hostname: "localhost", DataDir: "/data/gpseg0"},
+				{DbID: 3, ContentID: 1, Role: "p", Hostname: "localhost", DataDir: "/data/gpseg1"},
+				{DbID: 4, ContentID: 2, Role: "p", Hostname: "localhost", DataDir: "/data/gpseg2"},
+			})
+			testCluster.GroupByHost = true
+			commands := map[int]string{
+				0: `echo "segment 0's output"; echo 'GP_GROUPED_SEGMENT_RESULT 1 0  ' >&2`,
+				1: `printf 'line one\nline two'; exit 2`,
+				2: `cat; echo "$HOME" | grep -c /`,
+			}
+
+			output := testCluster.GenerateAndExecuteCommand("Running commands", cluster.ON_SEGMENTS, func(content int) string {
+				return commands[content]
+			})
+
+			Expect(output.Scope).To(Equal(cluster.ON_SEGMENTS))
+			Expect(output.Commands).To(HaveLen(3))
+			Expect(output.NumErrors).To(Equal(1))
+			Expect(output.Commands[0].Content).To(Equal(0))
+			Expect(output.Commands[0].CommandString).To(Equal(commands[0]))
+			Expect(output.Commands[0].Stdout).To(Equal("segment 0's output\n"))
+			Expect(output.Commands[0].Stderr).To(Equal("GP_GROUPED_SEGMENT_RESULT 1 0  \n"))
+			Expect(output.Commands[0].Error).ToNot(HaveOccurred())
+			Expect(output.Commands[1].Stdout).To(Equal("line one\nline two"))
+			Expect(output.Commands[1].Error).To(MatchError("exit status 2"))
+			Expect(output.FailedCommands[0].Content).To(Equal(1))
+			Expect(output.Commands[2].Stdout).To(Equal("1\n"))
+			Expect(output.Commands[2].Completed).To(BeTrue())
+		})
+		It("sends one ssh command per remote host", func() {
+			testExecutor := &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Hostname: "cdw", DataDir: "/data/gpseg-1"},
+				{DbID: 2, ContentID: 0, Role: "p", Hostname: "sdw1", DataDir: "/data/gpseg0"},
+				{DbID: 3, ContentID: 1, Role: "p", Hostname: "sdw1", DataDir: "/data/gpseg1"},
+				{DbID: 4, ContentID: 2, Role: "p", Hostname: "sdw2", DataDir: "/data/gpseg2"},
+			})
+			testCluster.Executor = testExecutor
+			testCluster.GroupByHost = true
+
+			testCluster.GenerateAndExecuteCommand("Running commands", cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(content int) string {
+				return "ls"
+			})
+
+			hostCommands := testExecutor.ClusterCommands[0]
+			Expect(hostCommands).To(HaveLen(3))
+			Expect(hostCommands[0].Host).To(Equal("cdw"))
+			Expect(hostCommands[0].CommandString).To(HavePrefix("bash -c dir=$(mktemp -d)"))
+			Expect(hostCommands[1].Host).To(Equal("sdw1"))
+			Expect(hostCommands[1].CommandString).To(HavePrefix("ssh -o StrictHostKeyChecking=no gpadmin@sdw1 dir=$(mktemp -d)"))
+			Expect(hostCommands[1].CommandString).To(ContainSubstring("printf 'GP_GROUPED_SEGMENT_RESULT 2 "))
+		})
+		It("reports a host's failure for each of its segments", func() {
+			testExecutor := &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{Commands: []cluster.ShellCommand{
+				{Host: "sdw1", Completed: true, Error: errors.New("exit status 255"), Stderr: "ssh: connect to host sdw1 port 22: Connection refused"},
+			}}}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Hostname: "cdw", DataDir: "/data/gpseg-1"},
+				{DbID: 2, ContentID: 0, Role: "p", Hostname: "sdw1", DataDir: "/data/gpseg0"},
+				{DbID: 3, ContentID: 1, Role: "p", Hostname: "sdw1", DataDir: "/data/gpseg1"},
+			})
+			testCluster.Executor = testExecutor
+			testCluster.GroupByHost = true
+
+			output := testCluster.GenerateAndExecuteCommand("Running commands", cluster.ON_SEGMENTS, func(content int) string {
+				return "ls"
+			})
+
+			Expect(output.NumErrors).To(Equal(2))
+			Expect(output.FailedCommands[1].Content).To(Equal(1))
+			Expect(output.FailedCommands[1].Error).To(MatchError("exit status 255"))
+			Expect(output.FailedCommands[1].Stderr).To(ContainSubstring("Connection refused"))
+		})
+	})
+})
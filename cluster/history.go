@@ -0,0 +1,133 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to reading the history of
+ * changes to gp_segment_configuration, such as failovers made by FTS, from
+ * gp_configuration_history.
+ */
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+)
+
+/*
+ * A ConfigurationChange is a single row of gp_configuration_history.  Reason
+ * is the component that made the change, e.g. "FTS" or "gprecoverseg", taken
+ * from the start of Description.
+ *
+ * For changes whose description gives the segment's new state, NewRole,
+ * NewStatus, and NewMode are the new values, and are empty for values that did
+ * not change.  A role change always swaps a segment between primary and
+ * mirror, so OldRole is the role the segment had before it.  ContentID is -2
+ * if the segment is no longer in gp_segment_configuration and its content is
+ * not in the description.
+ */
+type ConfigurationChange struct {
+	Time        time.Time `db:"time"`
+	DbID        int       `db:"dbid"`
+	ContentID   int       `db:"contentid"`
+	Description string    `db:"description"`
+	Reason      string
+	OldRole     string
+	NewRole     string
+	NewStatus   string
+	NewMode     string
+}
+
+// IsFailover returns whether the change promoted a mirror to primary
+func (change ConfigurationChange) IsFailover() bool {
+	return change.OldRole == "m" && change.NewRole == "p"
+}
+
+/*
+ * These are the descriptions FTS records for the segments it changes, e.g.
+ * "FTS: update role, status, and mode for dbid 3 with contentid 0 to p, u,
+ * and n" for a mirror promoted to primary.
+ */
+var (
+	roleStatusModePattern = regexp.MustCompile(`update role, status, and mode for dbid \d+ with contentid (-?\d+) to (\w), (\w),? and (\w)`)
+	statusModePattern     = regexp.MustCompile(`update status and mode for dbid \d+ with contentid (-?\d+) to (\w) and (\w)`)
+)
+
+/*
+ * ParseConfigurationChange fills in the fields of change that are derived
+ * from its Description.  Descriptions in other formats leave those fields
+ * empty, apart from Reason.
+ */
+func ParseConfigurationChange(change ConfigurationChange) ConfigurationChange {
+	if reason, _, found := strings.Cut(change.Description, ":"); found && !strings.Contains(reason, " ") {
+		change.Reason = reason
+	}
+	var content string
+	if match := roleStatusModePattern.FindStringSubmatch(change.Description); match != nil {
+		content, change.NewRole, change.NewStatus, change.NewMode = match[1], match[2], match[3], match[4]
+		if change.NewRole == "p" {
+			change.OldRole = "m"
+		} else {
+			change.OldRole = "p"
+		}
+	} else if match := statusModePattern.FindStringSubmatch(change.Description); match != nil {
+		content, change.NewStatus, change.NewMode = match[1], match[2], match[3]
+	}
+	if contentID, err := strconv.Atoi(content); err == nil {
+		change.ContentID = contentID
+	}
+	return change
+}
+
+/*
+ * GetConfigurationHistory returns every change recorded in
+ * gp_configuration_history, oldest first, with the fields derived from each
+ * change's description filled in.
+ */
+func GetConfigurationHistory(connection *dbconn.DBConn) ([]ConfigurationChange, error) {
+	query := `
+SELECT
+	h.time,
+	h.dbid,
+	coalesce(s.content, -2) AS contentid,
+	h."desc" AS description
+FROM gp_configuration_history h
+LEFT JOIN gp_segment_configuration s ON h.dbid = s.dbid
+ORDER BY h.time, h.dbid;`
+	results := make([]ConfigurationChange, 0)
+	err := connection.Select(&results, query)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i] = ParseConfigurationChange(results[i])
+	}
+	return results, nil
+}
+
+func MustGetConfigurationHistory(connection *dbconn.DBConn) []ConfigurationChange {
+	history, err := GetConfigurationHistory(connection)
+	gplog.FatalOnError(err)
+	return history
+}
+
+/*
+ * LastFailovers returns the most recent failover for each content in history,
+ * keyed by content ID, e.g. so that recovery tooling can tell which contents
+ * have failed over since a rebalance.  Contents that have never failed over
+ * are not included.
+ */
+func LastFailovers(history []ConfigurationChange) map[int]ConfigurationChange {
+	failovers := make(map[int]ConfigurationChange)
+	for _, change := range history {
+		if !change.IsFailover() {
+			continue
+		}
+		if last, ok := failovers[change.ContentID]; !ok || !change.Time.Before(last.Time) {
+			failovers[change.ContentID] = change
+		}
+	}
+	return failovers
+}
@@ -0,0 +1,98 @@
+package cluster_test
+
+import (
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/history tests", func() {
+	header := []string{"time", "dbid", "contentid", "description"}
+	failoverTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	Describe("ParseConfigurationChange", func() {
+		It("parses a mirror being promoted", func() {
+			change := cluster.ParseConfigurationChange(cluster.ConfigurationChange{DbID: 3, ContentID: -2,
+				Description: "FTS: update role, status, and mode for dbid 3 with contentid 0 to p, u, and n"})
+			Expect(change.Reason).To(Equal("FTS"))
+			Expect(change.ContentID).To(Equal(0))
+			Expect(change.OldRole).To(Equal("m"))
+			Expect(change.NewRole).To(Equal("p"))
+			Expect(change.NewStatus).To(Equal("u"))
+			Expect(change.NewMode).To(Equal("n"))
+			Expect(change.IsFailover()).To(BeTrue())
+		})
+		It("parses a primary being demoted", func() {
+			change := cluster.ParseConfigurationChange(cluster.ConfigurationChange{DbID: 2, ContentID: 0,
+				Description: "FTS: update role, status, and mode for dbid 2 with contentid 0 to m, d, and n"})
+			Expect(change.OldRole).To(Equal("p"))
+			Expect(change.NewRole).To(Equal("m"))
+			Expect(change.NewStatus).To(Equal("d"))
+			Expect(change.IsFailover()).To(BeFalse())
+		})
+		It("parses a status change without a role change", func() {
+			change := cluster.ParseConfigurationChange(cluster.ConfigurationChange{DbID: 3, ContentID: 0,
+				Description: "FTS: update status and mode for dbid 3 with contentid 0 to d and n"})
+			Expect(change.Reason).To(Equal("FTS"))
+			Expect(change.OldRole).To(Equal(""))
+			Expect(change.NewRole).To(Equal(""))
+			Expect(change.NewStatus).To(Equal("d"))
+			Expect(change.NewMode).To(Equal("n"))
+			Expect(change.IsFailover()).To(BeFalse())
+		})
+		It("only sets the reason for other descriptions", func() {
+			change := cluster.ParseConfigurationChange(cluster.ConfigurationChange{DbID: 5, ContentID: 1,
+				Description: "gp_add_segment_mirror: inserted mirror segment configuration"})
+			Expect(change.Reason).To(Equal("gp_add_segment_mirror"))
+			Expect(change.ContentID).To(Equal(1))
+			Expect(change.NewRole).To(Equal(""))
+			Expect(change.NewStatus).To(Equal(""))
+		})
+		It("does not set a reason for descriptions without one", func() {
+			change := cluster.ParseConfigurationChange(cluster.ConfigurationChange{Description: "segment added by hand: see ticket"})
+			Expect(change.Reason).To(Equal(""))
+		})
+	})
+	Describe("GetConfigurationHistory", func() {
+		It("returns the parsed history", func() {
+			fakeResult := sqlmock.NewRows(header).
+				AddRow(failoverTime, 2, 0, "FTS: update role, status, and mode for dbid 2 with contentid 0 to m, d, and n").
+				AddRow(failoverTime, 3, -2, "FTS: update role, status, and mode for dbid 3 with contentid 0 to p, u, and n")
+			mock.ExpectQuery("SELECT (.*) FROM gp_configuration_history").WillReturnRows(fakeResult)
+			history, err := cluster.GetConfigurationHistory(connection)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(history).To(HaveLen(2))
+			Expect(history[0].Time).To(Equal(failoverTime))
+			Expect(history[0].DbID).To(Equal(2))
+			Expect(history[0].NewRole).To(Equal("m"))
+			Expect(history[1].DbID).To(Equal(3))
+			Expect(history[1].ContentID).To(Equal(0))
+			Expect(history[1].IsFailover()).To(BeTrue())
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnError(errors.New("relation does not exist"))
+			_, err := cluster.GetConfigurationHistory(connection)
+			Expect(err).To(MatchError("relation does not exist"))
+		})
+	})
+	Describe("LastFailovers", func() {
+		It("returns the most recent failover for each content", func() {
+			first := cluster.ConfigurationChange{Time: failoverTime, DbID: 3, ContentID: 0, OldRole: "m", NewRole: "p"}
+			demotion := cluster.ConfigurationChange{Time: failoverTime, DbID: 2, ContentID: 0, OldRole: "p", NewRole: "m"}
+			second := cluster.ConfigurationChange{Time: failoverTime.Add(time.Hour), DbID: 2, ContentID: 0, OldRole: "m", NewRole: "p"}
+			other := cluster.ConfigurationChange{Time: failoverTime, DbID: 5, ContentID: 1, OldRole: "m", NewRole: "p"}
+			statusOnly := cluster.ConfigurationChange{Time: failoverTime, DbID: 6, ContentID: 2, NewStatus: "d"}
+			failovers := cluster.LastFailovers([]cluster.ConfigurationChange{second, first, demotion, other, statusOnly})
+			Expect(failovers).To(Equal(map[int]cluster.ConfigurationChange{0: second, 1: other}))
+		})
+		It("returns an empty map if no segment has failed over", func() {
+			Expect(cluster.LastFailovers(nil)).To(BeEmpty())
+		})
+	})
+})
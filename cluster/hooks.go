@@ -0,0 +1,253 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to observing the execution
+ * of cluster commands as it happens, e.g. to emit metrics or update progress.
+ */
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * CommandHooks are called by GPDBExecutor.ExecuteClusterCommand for each
+ * command it runs and for the batch as a whole.  Calls to the hooks for a
+ * batch are never concurrent, so implementations need no locking of their own
+ * unless they are shared between executors.
+ *
+ * If OnCommandComplete returns an error, the batch is aborted: commands that
+ * are still running are killed and commands that have not yet started are not
+ * run, and the Error of each such command is a CommandAbortedError holding the
+ * returned error.  OnCommandComplete is not called for aborted commands.
+ */
+type CommandHooks interface {
+	OnCommandStart(command ShellCommand)
+	OnCommandComplete(command ShellCommand) error
+	OnBatchComplete(output *RemoteOutput)
+}
+
+/*
+ * CommandHookFuncs implements CommandHooks with a function for each hook, any
+ * of which may be nil, for callers that only need some of the hooks.
+ */
+type CommandHookFuncs struct {
+	Start         func(command ShellCommand)
+	Complete      func(command ShellCommand) error
+	BatchComplete func(output *RemoteOutput)
+}
+
+func (hooks CommandHookFuncs) OnCommandStart(command ShellCommand) {
+	if hooks.Start != nil {
+		hooks.Start(command)
+	}
+}
+
+func (hooks CommandHookFuncs) OnCommandComplete(command ShellCommand) error {
+	if hooks.Complete != nil {
+		return hooks.Complete(command)
+	}
+	return nil
+}
+
+func (hooks CommandHookFuncs) OnBatchComplete(output *RemoteOutput) {
+	if hooks.BatchComplete != nil {
+		hooks.BatchComplete(output)
+	}
+}
+
+/*
+ * AbortAfterErrors returns hooks that abort each batch once maxErrors commands
+ * in it have failed, e.g. so that a command that fails on every segment of a
+ * large cluster does not have to finish everywhere before the caller can stop.
+ * It is the same as setting ExecutionOptions.MaxErrors, for callers that
+ * configure executors through their hooks; if both are set, the lower limit
+ * applies.  The hooks hold no state of their own: each batch counts its own failures, so
+ * the same hooks may be used by any number of batches, including concurrent
+ * ones.
+ */
+func AbortAfterErrors(maxErrors int) CommandHooks {
+	return abortAfterErrors{maxErrors: maxErrors}
+}
+
+// abortAfterErrors is recognized by newCommandBatch, which applies it to the batch's own error count
+type abortAfterErrors struct {
+	CommandHookFuncs
+	maxErrors int
+}
+
+type CommandAbortedError struct {
+	Reason error
+}
+
+func (err CommandAbortedError) Error() string {
+	return fmt.Sprintf("Command aborted: %v", err.Reason)
+}
+
+func (err CommandAbortedError) Unwrap() error {
+	return err.Reason
+}
+
+/*
+ * A commandBatch tracks the running processes of one ExecuteClusterCommand
+ * call, so that they can be killed if a hook or the executor's
+ * ExecutionOptions abort some or all of the batch.
+ */
+type commandBatch struct {
+	hooks   []CommandHooks
+	options ExecutionOptions
+	// The host of each command, or "" if it is not known
+	hosts []string
+	// Held while calling hooks, so that they are never called concurrently
+	hookMutex sync.Mutex
+	mutex     sync.Mutex
+	numErrors int
+	// The reason every remaining command was aborted, or nil
+	abortErr error
+	// The reason the remaining commands on each host were aborted
+	hostAbortErrs map[string]error
+	processes     map[int]*os.Process
+	// The reason each killed command was aborted
+	killed map[int]error
+}
+
+func newCommandBatch(hooks []CommandHooks, options ExecutionOptions, commands []ShellCommand) *commandBatch {
+	for _, hook := range hooks {
+		if limit, ok := hook.(abortAfterErrors); ok && limit.maxErrors > 0 && (options.MaxErrors <= 0 || limit.maxErrors < options.MaxErrors) {
+			options.MaxErrors = limit.maxErrors
+		}
+	}
+	hosts := make([]string, len(commands))
+	for i, command := range commands {
+		hosts[i] = commandHost(command)
+	}
+	return &commandBatch{
+		hooks:         hooks,
+		options:       options,
+		hosts:         hosts,
+		hostAbortErrs: make(map[string]error),
+		processes:     make(map[int]*os.Process),
+		killed:        make(map[int]error),
+	}
+}
+
+// Must be called with batch.mutex held
+func (batch *commandBatch) abortReason(index int) error {
+	if batch.abortErr != nil {
+		return batch.abortErr
+	}
+	if host := batch.hosts[index]; host != "" {
+		return batch.hostAbortErrs[host]
+	}
+	return nil
+}
+
+func (batch *commandBatch) abortError(index int) error {
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+	if reason := batch.abortReason(index); reason != nil {
+		return CommandAbortedError{Reason: reason}
+	}
+	return nil
+}
+
+// Starts the command unless it has been aborted
+func (batch *commandBatch) start(index int, command ShellCommand, cmd *exec.Cmd) error {
+	if err := batch.abortError(index); err != nil {
+		return err
+	}
+	batch.hookMutex.Lock()
+	for _, hooks := range batch.hooks {
+		hooks.OnCommandStart(command)
+	}
+	batch.hookMutex.Unlock()
+
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+	if reason := batch.abortReason(index); reason != nil {
+		return CommandAbortedError{Reason: reason}
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	batch.processes[index] = cmd.Process
+	return nil
+}
+
+// Returns the error to record for the command, which is replaced if the command was killed by an abort
+func (batch *commandBatch) finish(index int, err error) error {
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+	delete(batch.processes, index)
+	if reason, ok := batch.killed[index]; ok {
+		return CommandAbortedError{Reason: reason}
+	}
+	return err
+}
+
+/*
+ * Calls OnCommandComplete and applies the ExecutionOptions, aborting some or
+ * all of the batch if a hook returns an error or an option calls for it.
+ */
+func (batch *commandBatch) complete(index int, command ShellCommand) {
+	var abortedErr CommandAbortedError
+	if errors.As(command.Error, &abortedErr) {
+		return
+	}
+	var reason error
+	batch.hookMutex.Lock()
+	for _, hooks := range batch.hooks {
+		if reason = hooks.OnCommandComplete(command); reason != nil {
+			break
+		}
+	}
+	batch.hookMutex.Unlock()
+	if reason != nil {
+		batch.abort(reason, "")
+		return
+	}
+	if command.Error == nil {
+		return
+	}
+	batch.numErrors++
+	if batch.options.FailFast || (batch.options.MaxErrors > 0 && batch.numErrors >= batch.options.MaxErrors) {
+		batch.abort(errors.Errorf("%d commands failed", batch.numErrors), "")
+	} else if host := batch.hosts[index]; batch.options.BreakOnHostError && host != "" {
+		batch.abort(errors.Errorf("An earlier command on host %s failed", host), host)
+	}
+}
+
+// Aborts the remaining commands on the host, or every remaining command if host is ""
+func (batch *commandBatch) abort(reason error, host string) {
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+	if batch.abortErr != nil || (host != "" && batch.hostAbortErrs[host] != nil) {
+		return
+	}
+	if host == "" {
+		batch.abortErr = reason
+	} else {
+		batch.hostAbortErrs[host] = reason
+	}
+	numKilled := 0
+	for index, process := range batch.processes {
+		if host == "" || batch.hosts[index] == host {
+			batch.killed[index] = reason
+			_ = process.Kill()
+			numKilled++
+		}
+	}
+	logDomain.Verbose("Aborting remaining commands, including %d running commands: %v", numKilled, reason)
+}
+
+func (batch *commandBatch) completeBatch(output *RemoteOutput) {
+	batch.hookMutex.Lock()
+	defer batch.hookMutex.Unlock()
+	for _, hooks := range batch.hooks {
+		hooks.OnBatchComplete(output)
+	}
+}
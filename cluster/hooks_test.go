@@ -0,0 +1,126 @@
+package cluster_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/hooks tests", func() {
+	newCommands := func(scripts ...string) []cluster.ShellCommand {
+		commands := make([]cluster.ShellCommand, len(scripts))
+		for i, script := range scripts {
+			commands[i] = cluster.NewShellCommand(cluster.ON_SEGMENTS, i, "", []string{"bash", "-c", script})
+		}
+		return commands
+	}
+
+	Describe("GPDBExecutor.Hooks", func() {
+		It("are called for each command and for the batch", func() {
+			started := make([]int, 0)
+			completed := make(map[int]string)
+			var batchOutput *cluster.RemoteOutput
+			executor := &cluster.GPDBExecutor{Hooks: []cluster.CommandHooks{cluster.CommandHookFuncs{
+				Start: func(command cluster.ShellCommand) {
+					started = append(started, command.Content)
+				},
+				Complete: func(command cluster.ShellCommand) error {
+					completed[command.Content] = command.Stdout
+					return nil
+				},
+				BatchComplete: func(output *cluster.RemoteOutput) {
+					batchOutput = output
+				},
+			}}}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands("echo zero", "echo one; exit 1"))
+
+			Expect(started).To(ConsistOf(0, 1))
+			Expect(completed).To(Equal(map[int]string{0: "zero\n", 1: "one\n"}))
+			Expect(batchOutput).To(Equal(output))
+			Expect(output.NumErrors).To(Equal(1))
+		})
+		It("abort the batch if a hook returns an error", func() {
+			executor := &cluster.GPDBExecutor{Hooks: []cluster.CommandHooks{cluster.CommandHookFuncs{
+				Complete: func(command cluster.ShellCommand) error {
+					if command.Error != nil {
+						return errors.New("giving up")
+					}
+					return nil
+				},
+			}}}
+			start := time.Now()
+
+			output := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands("exit 1", "exec sleep 30", "exec sleep 30"))
+
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+			Expect(output.NumErrors).To(Equal(3))
+			Expect(output.Commands[0].Error).To(MatchError("exit status 1"))
+			for _, command := range output.Commands[1:] {
+				var abortedErr cluster.CommandAbortedError
+				Expect(errors.As(command.Error, &abortedErr)).To(BeTrue())
+				Expect(command.Error).To(MatchError("Command aborted: giving up"))
+			}
+		})
+	})
+	Describe("AbortAfterErrors", func() {
+		It("aborts the batch once the given number of commands have failed", func() {
+			executor := &cluster.GPDBExecutor{Hooks: []cluster.CommandHooks{cluster.AbortAfterErrors(1)}}
+			start := time.Now()
+
+			output := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands("exit 1", "exec sleep 30", "exec sleep 30"))
+
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+			Expect(output.NumErrors).To(Equal(3))
+			Expect(output.Commands[1].Error).To(MatchError("Command aborted: 1 commands failed"))
+			Expect(output.Commands[2].Error).To(MatchError("Command aborted: 1 commands failed"))
+		})
+		It("applies the lower of its limit and MaxErrors", func() {
+			executor := &cluster.GPDBExecutor{
+				Hooks:   []cluster.CommandHooks{cluster.AbortAfterErrors(3)},
+				Options: cluster.ExecutionOptions{MaxErrors: 1},
+			}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands("exit 1", "exec sleep 30"))
+
+			Expect(output.Commands[1].Error).To(MatchError("Command aborted: 1 commands failed"))
+
+			executor.Hooks = []cluster.CommandHooks{cluster.AbortAfterErrors(1)}
+			executor.Options.MaxErrors = 3
+			output = executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands("exit 1", "exec sleep 30"))
+
+			Expect(output.Commands[1].Error).To(MatchError("Command aborted: 1 commands failed"))
+		})
+		It("does not carry the error count over to later batches", func() {
+			executor := &cluster.GPDBExecutor{Hooks: []cluster.CommandHooks{cluster.AbortAfterErrors(2)}}
+
+			first := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands("exit 1", "true"))
+			second := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands("exit 1", "true"))
+
+			Expect(first.NumErrors).To(Equal(1))
+			Expect(second.NumErrors).To(Equal(1))
+			Expect(second.Commands[1].Error).ToNot(HaveOccurred())
+		})
+		It("counts the errors of concurrent batches separately", func() {
+			hooks := []cluster.CommandHooks{cluster.AbortAfterErrors(2)}
+			outputs := make(chan *cluster.RemoteOutput, 2)
+
+			for i := 0; i < 2; i++ {
+				go func() {
+					executor := &cluster.GPDBExecutor{Hooks: hooks}
+					outputs <- executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands("exit 1", "sleep 0.2"))
+				}()
+			}
+
+			for i := 0; i < 2; i++ {
+				output := <-outputs
+				Expect(output.NumErrors).To(Equal(1))
+				Expect(output.Commands[1].Error).ToNot(HaveOccurred())
+			}
+		})
+	})
+})
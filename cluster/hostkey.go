@@ -0,0 +1,58 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to how ssh commands built
+ * by this package check the host keys of remote hosts.
+ */
+
+import (
+	"sync"
+)
+
+/*
+ * A HostKeyPolicy is passed to ssh as its StrictHostKeyChecking option, so
+ * host keys are always checked by ssh itself against ~/.ssh/known_hosts.
+ *
+ * HOST_KEY_ACCEPT_ANY connects even if a known host's key has changed,
+ * though ssh then disables password authentication; it is the default, as
+ * it works with every version of OpenSSH and with reinstalled hosts.
+ * HOST_KEY_ACCEPT_NEW adds keys of hosts not yet in known_hosts but refuses
+ * changed keys, and requires OpenSSH 7.6 or later.  HOST_KEY_STRICT refuses
+ * hosts not already in known_hosts, e.g. for sites that distribute known_hosts
+ * files with pinned keys.
+ */
+type HostKeyPolicy string
+
+const (
+	HOST_KEY_ACCEPT_ANY HostKeyPolicy = "no"
+	HOST_KEY_ACCEPT_NEW HostKeyPolicy = "accept-new"
+	HOST_KEY_STRICT     HostKeyPolicy = "yes"
+)
+
+var (
+	hostKeyPolicy      = HOST_KEY_ACCEPT_ANY
+	hostKeyPolicyMutex sync.RWMutex
+)
+
+/*
+ * SetHostKeyPolicy sets the policy used by every ssh command built by this
+ * package from then on, including those of ConstructSSHCommand, SSHTransport,
+ * HostSessionManager, and VerifySSHAccess.  It is process-global, so it
+ * should be set once at startup.
+ */
+func SetHostKeyPolicy(policy HostKeyPolicy) {
+	hostKeyPolicyMutex.Lock()
+	defer hostKeyPolicyMutex.Unlock()
+	hostKeyPolicy = policy
+}
+
+func GetHostKeyPolicy() HostKeyPolicy {
+	hostKeyPolicyMutex.RLock()
+	defer hostKeyPolicyMutex.RUnlock()
+	return hostKeyPolicy
+}
+
+// Returns the ssh options applying the current policy, which must come before any other StrictHostKeyChecking option
+func hostKeyOptions() []string {
+	return []string{"-o", "StrictHostKeyChecking=" + string(GetHostKeyPolicy())}
+}
@@ -0,0 +1,56 @@
+package cluster_test
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/hostkey tests", func() {
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "gpadmin"}, nil }
+	})
+	AfterEach(func() {
+		cluster.SetHostKeyPolicy(cluster.HOST_KEY_ACCEPT_ANY)
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("SetHostKeyPolicy", func() {
+		It("defaults to accepting any host key", func() {
+			Expect(cluster.GetHostKeyPolicy()).To(Equal(cluster.HOST_KEY_ACCEPT_ANY))
+		})
+		It("is applied by ConstructSSHCommand", func() {
+			cluster.SetHostKeyPolicy(cluster.HOST_KEY_ACCEPT_NEW)
+
+			Expect(cluster.ConstructSSHCommand(false, "sdw1", "ls")).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=accept-new", "gpadmin@sdw1", "ls"}))
+		})
+		It("is applied by SSHTransport before any other options", func() {
+			cluster.SetHostKeyPolicy(cluster.HOST_KEY_STRICT)
+			transport := cluster.SSHTransport{Options: []string{"-o", "StrictHostKeyChecking=no"}}
+
+			command := transport.BuildCommand(cluster.CommandTarget{Address: "sdw1"}, "ls")
+
+			Expect(command).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=yes", "-o", "StrictHostKeyChecking=no", "gpadmin@sdw1", "ls"}))
+		})
+		It("is applied by HostSessionManager", func() {
+			fakeSSHDir := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(fakeSSHDir, "ssh"), []byte("#!/bin/bash\necho \"$*\" >> \""+fakeSSHDir+"/log\"\n"), 0755)).To(Succeed())
+			GinkgoT().Setenv("PATH", fakeSSHDir+":"+os.Getenv("PATH"))
+			cluster.SetHostKeyPolicy(cluster.HOST_KEY_ACCEPT_NEW)
+
+			manager := cluster.NewHostSessionManager(GinkgoT().TempDir())
+			Expect(manager.Open("sdw1")).To(Succeed())
+
+			contents, err := os.ReadFile(filepath.Join(fakeSSHDir, "log"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(strings.TrimSpace(string(contents))).To(ContainSubstring(" -o BatchMode=yes -o StrictHostKeyChecking=accept-new -o ConnectTimeout=5 "))
+		})
+	})
+})
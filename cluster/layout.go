@@ -0,0 +1,205 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to analyzing how the
+ * mirrors in a cluster are laid out relative to their primaries.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type MirroringLayout string
+
+/*
+ * In a grouped layout, all mirrors of the primaries on a host are on one other
+ * host; in a spread layout, each mirror of the primaries on a host is on a
+ * different host.  Layouts that are neither are reported as custom, and a
+ * cluster with one primary per host, which fits both, is reported as grouped.
+ */
+const (
+	MIRRORING_NONE    MirroringLayout = "none"
+	MIRRORING_GROUPED MirroringLayout = "grouped"
+	MIRRORING_SPREAD  MirroringLayout = "spread"
+	MIRRORING_CUSTOM  MirroringLayout = "custom"
+)
+
+/*
+ * Primaries is the number of segments on the host currently acting as
+ * primaries, while PreferredPrimaries is the number that should be once the
+ * cluster is balanced.  They differ after a failover that has not been
+ * followed by a rebalance.
+ */
+type HostPrimaryCounts struct {
+	Host               string
+	Primaries          int
+	PreferredPrimaries int
+}
+
+/*
+ * A RebalanceMove describes a content whose preferred primary is currently
+ * acting as a mirror, and so would swap roles in a rebalance.
+ */
+type RebalanceMove struct {
+	Content       int
+	CurrentDbID   int
+	CurrentHost   string
+	PreferredDbID int
+	PreferredHost string
+}
+
+func (move RebalanceMove) String() string {
+	return fmt.Sprintf("Content %d: move primary from dbid %d on host %s back to dbid %d on host %s", move.Content, move.CurrentDbID, move.CurrentHost, move.PreferredDbID, move.PreferredHost)
+}
+
+type MirroringLayoutReport struct {
+	Layout          MirroringLayout
+	Hosts           []HostPrimaryCounts
+	UnbalancedHosts []HostPrimaryCounts
+	Moves           []RebalanceMove
+}
+
+func (report MirroringLayoutReport) IsBalanced() bool {
+	return len(report.UnbalancedHosts) == 0 && len(report.Moves) == 0
+}
+
+func (report MirroringLayoutReport) String() string {
+	lines := []string{fmt.Sprintf("Mirroring layout: %s", report.Layout)}
+	if report.IsBalanced() {
+		lines = append(lines, "All segments are in their preferred roles")
+		return strings.Join(lines, "\n")
+	}
+	for _, host := range report.UnbalancedHosts {
+		lines = append(lines, fmt.Sprintf("Host %s has %d primaries, but should have %d", host.Host, host.Primaries, host.PreferredPrimaries))
+	}
+	lines = append(lines, fmt.Sprintf("Rebalancing the cluster (e.g. with gprecoverseg -r) would make %d changes:", len(report.Moves)))
+	for _, move := range report.Moves {
+		lines = append(lines, "  "+move.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Segments read from an older gpsegconfig_dump file may lack a preferred role
+func preferredRole(segment *SegConfig) string {
+	if segment.PreferredRole != "" {
+		return segment.PreferredRole
+	}
+	return segment.Role
+}
+
+/*
+ * AnalyzeMirroringLayout classifies the intended layout of the cluster's
+ * mirrors based on preferred roles, and reports the hosts whose number of
+ * acting primaries differs from the intended number along with the role
+ * changes a rebalance would make to fix them.  The coordinator and standby are
+ * not considered.
+ */
+func (cluster *Cluster) AnalyzeMirroringLayout() MirroringLayoutReport {
+	report := MirroringLayoutReport{
+		Layout:          cluster.classifyMirroringLayout(),
+		Hosts:           make([]HostPrimaryCounts, 0),
+		UnbalancedHosts: make([]HostPrimaryCounts, 0),
+		Moves:           make([]RebalanceMove, 0),
+	}
+
+	countsByHost := make(map[string]*HostPrimaryCounts)
+	for _, content := range cluster.ContentIDs {
+		if content == -1 {
+			continue
+		}
+		var actingPrimary, preferredPrimary *SegConfig
+		for _, segment := range cluster.ByContent[content] {
+			counts, ok := countsByHost[segment.Hostname]
+			if !ok {
+				counts = &HostPrimaryCounts{Host: segment.Hostname}
+				countsByHost[segment.Hostname] = counts
+			}
+			if segment.Role == "p" {
+				counts.Primaries++
+				actingPrimary = segment
+			}
+			if preferredRole(segment) == "p" {
+				counts.PreferredPrimaries++
+				preferredPrimary = segment
+			}
+		}
+		if actingPrimary != nil && preferredPrimary != nil && actingPrimary != preferredPrimary {
+			report.Moves = append(report.Moves, RebalanceMove{
+				Content:       content,
+				CurrentDbID:   actingPrimary.DbID,
+				CurrentHost:   actingPrimary.Hostname,
+				PreferredDbID: preferredPrimary.DbID,
+				PreferredHost: preferredPrimary.Hostname,
+			})
+		}
+	}
+
+	hosts := make([]string, 0, len(countsByHost))
+	for host := range countsByHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		counts := *countsByHost[host]
+		report.Hosts = append(report.Hosts, counts)
+		if counts.Primaries != counts.PreferredPrimaries {
+			report.UnbalancedHosts = append(report.UnbalancedHosts, counts)
+		}
+	}
+	return report
+}
+
+func (cluster *Cluster) classifyMirroringLayout() MirroringLayout {
+	// For each host, the number of its preferred primaries mirrored on each other host
+	mirrorHosts := make(map[string]map[string]int)
+	hasMirrors := false
+	for _, content := range cluster.ContentIDs {
+		if content == -1 {
+			continue
+		}
+		var primary, mirror *SegConfig
+		for _, segment := range cluster.ByContent[content] {
+			if preferredRole(segment) == "p" {
+				primary = segment
+			} else {
+				mirror = segment
+			}
+		}
+		if primary == nil || mirror == nil {
+			continue
+		}
+		hasMirrors = true
+		if mirrorHosts[primary.Hostname] == nil {
+			mirrorHosts[primary.Hostname] = make(map[string]int)
+		}
+		mirrorHosts[primary.Hostname][mirror.Hostname]++
+	}
+	if !hasMirrors {
+		return MIRRORING_NONE
+	}
+
+	isGrouped, isSpread := true, true
+	for primaryHost, counts := range mirrorHosts {
+		if len(counts) > 1 {
+			isGrouped = false
+		}
+		for mirrorHost, count := range counts {
+			if mirrorHost == primaryHost {
+				isGrouped, isSpread = false, false
+			}
+			if count > 1 {
+				isSpread = false
+			}
+		}
+	}
+	switch {
+	case isGrouped:
+		return MIRRORING_GROUPED
+	case isSpread:
+		return MIRRORING_SPREAD
+	default:
+		return MIRRORING_CUSTOM
+	}
+}
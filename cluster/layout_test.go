@@ -0,0 +1,99 @@
+package cluster_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/layout tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Role: "p", PreferredRole: "p", Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1"}
+	seg := func(dbid int, content int, role string, preferredRole string, host string) cluster.SegConfig {
+		return cluster.SegConfig{DbID: dbid, ContentID: content, Role: role, PreferredRole: preferredRole, Port: 6000 + dbid, Hostname: host, DataDir: "/data/gpseg"}
+	}
+	// Two primaries on each of three hosts
+	groupedSegs := []cluster.SegConfig{coordinatorSeg,
+		seg(2, 0, "p", "p", "sdw1"), seg(3, 1, "p", "p", "sdw1"),
+		seg(4, 2, "p", "p", "sdw2"), seg(5, 3, "p", "p", "sdw2"),
+		seg(6, 4, "p", "p", "sdw3"), seg(7, 5, "p", "p", "sdw3"),
+		seg(8, 0, "m", "m", "sdw2"), seg(9, 1, "m", "m", "sdw2"),
+		seg(10, 2, "m", "m", "sdw3"), seg(11, 3, "m", "m", "sdw3"),
+		seg(12, 4, "m", "m", "sdw1"), seg(13, 5, "m", "m", "sdw1"),
+	}
+	Describe("AnalyzeMirroringLayout", func() {
+		It("classifies a cluster without mirrors", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, seg(2, 0, "p", "p", "sdw1"), seg(3, 1, "p", "p", "sdw2")})
+
+			report := testCluster.AnalyzeMirroringLayout()
+
+			Expect(report.Layout).To(Equal(cluster.MIRRORING_NONE))
+			Expect(report.IsBalanced()).To(BeTrue())
+			Expect(report.Hosts).To(Equal([]cluster.HostPrimaryCounts{{"sdw1", 1, 1}, {"sdw2", 1, 1}}))
+		})
+		It("classifies a grouped layout", func() {
+			report := cluster.NewCluster(groupedSegs).AnalyzeMirroringLayout()
+
+			Expect(report.Layout).To(Equal(cluster.MIRRORING_GROUPED))
+			Expect(report.IsBalanced()).To(BeTrue())
+			Expect(report.String()).To(Equal("Mirroring layout: grouped\nAll segments are in their preferred roles"))
+		})
+		It("classifies a spread layout", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg,
+				seg(2, 0, "p", "p", "sdw1"), seg(3, 1, "p", "p", "sdw1"),
+				seg(4, 2, "p", "p", "sdw2"), seg(5, 3, "p", "p", "sdw2"),
+				seg(6, 4, "p", "p", "sdw3"), seg(7, 5, "p", "p", "sdw3"),
+				seg(8, 0, "m", "m", "sdw2"), seg(9, 1, "m", "m", "sdw3"),
+				seg(10, 2, "m", "m", "sdw3"), seg(11, 3, "m", "m", "sdw1"),
+				seg(12, 4, "m", "m", "sdw1"), seg(13, 5, "m", "m", "sdw2"),
+			})
+
+			Expect(testCluster.AnalyzeMirroringLayout().Layout).To(Equal(cluster.MIRRORING_SPREAD))
+		})
+		It("classifies other layouts as custom", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg,
+				seg(2, 0, "p", "p", "sdw1"), seg(3, 1, "p", "p", "sdw1"), seg(4, 2, "p", "p", "sdw1"),
+				seg(5, 3, "p", "p", "sdw2"),
+				seg(6, 0, "m", "m", "sdw2"), seg(7, 1, "m", "m", "sdw2"), seg(8, 2, "m", "m", "sdw3"),
+				seg(9, 3, "m", "m", "sdw1"),
+			})
+
+			Expect(testCluster.AnalyzeMirroringLayout().Layout).To(Equal(cluster.MIRRORING_CUSTOM))
+		})
+		It("classifies a layout with a mirror on its primary's host as custom", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, seg(2, 0, "p", "p", "sdw1"), seg(3, 0, "m", "m", "sdw1")})
+
+			Expect(testCluster.AnalyzeMirroringLayout().Layout).To(Equal(cluster.MIRRORING_CUSTOM))
+		})
+		It("reports unbalanced hosts and the moves needed after a failover", func() {
+			failedOverSegs := append([]cluster.SegConfig{}, groupedSegs...)
+			// Contents 0 and 1 have failed over from sdw1 to sdw2
+			failedOverSegs[1].Role, failedOverSegs[2].Role = "m", "m"
+			failedOverSegs[7].Role, failedOverSegs[8].Role = "p", "p"
+
+			report := cluster.NewCluster(failedOverSegs).AnalyzeMirroringLayout()
+
+			Expect(report.Layout).To(Equal(cluster.MIRRORING_GROUPED))
+			Expect(report.IsBalanced()).To(BeFalse())
+			Expect(report.UnbalancedHosts).To(Equal([]cluster.HostPrimaryCounts{{"sdw1", 0, 2}, {"sdw2", 4, 2}}))
+			Expect(report.Moves).To(Equal([]cluster.RebalanceMove{
+				{Content: 0, CurrentDbID: 8, CurrentHost: "sdw2", PreferredDbID: 2, PreferredHost: "sdw1"},
+				{Content: 1, CurrentDbID: 9, CurrentHost: "sdw2", PreferredDbID: 3, PreferredHost: "sdw1"},
+			}))
+			Expect(report.String()).To(Equal(`Mirroring layout: grouped
+Host sdw1 has 0 primaries, but should have 2
+Host sdw2 has 4 primaries, but should have 2
+Rebalancing the cluster (e.g. with gprecoverseg -r) would make 2 changes:
+  Content 0: move primary from dbid 8 on host sdw2 back to dbid 2 on host sdw1
+  Content 1: move primary from dbid 9 on host sdw2 back to dbid 3 on host sdw1`))
+		})
+		It("uses the current role when the preferred role is unknown", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, seg(2, 0, "p", "", "sdw1"), seg(3, 0, "m", "", "sdw2")})
+
+			report := testCluster.AnalyzeMirroringLayout()
+
+			Expect(report.Layout).To(Equal(cluster.MIRRORING_GROUPED))
+			Expect(report.IsBalanced()).To(BeTrue())
+		})
+	})
+})
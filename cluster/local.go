@@ -0,0 +1,129 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to running local commands
+ * with control over their environment, working directory, and input.
+ */
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Env is added to the current environment, overriding any variables already
+ * set.  A Timeout of 0 means the command is never timed out.
+ */
+type LocalOptions struct {
+	Env     map[string]string
+	Dir     string
+	Timeout time.Duration
+	Stdin   io.Reader
+}
+
+type LocalOutput struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+func sortedEnv(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	assignments := make([]string, len(keys))
+	for i, key := range keys {
+		assignments[i] = key + "=" + env[key]
+	}
+	return assignments
+}
+
+/*
+ * WrapCommand returns cmd wrapped so that it runs in opts.Dir with opts.Env
+ * added to its environment, for commands that run through a shell, possibly
+ * on another host.  GenerateSSHCommandList uses this to apply the options
+ * returned by a generator; Timeout and Stdin are ignored.
+ */
+func (opts LocalOptions) WrapCommand(cmd string) string {
+	if len(opts.Env) == 0 && opts.Dir == "" {
+		return cmd
+	}
+	wrapped := ""
+	if opts.Dir != "" {
+		wrapped = fmt.Sprintf("cd %s && ", shellQuote(opts.Dir))
+	}
+	if len(opts.Env) > 0 {
+		wrapped += "env"
+		for _, assignment := range sortedEnv(opts.Env) {
+			wrapped += " " + shellQuote(assignment)
+		}
+		wrapped += " "
+	}
+	return wrapped + "bash -c " + shellQuote(cmd)
+}
+
+// Applies Env and Dir to a command run directly by this process
+func (opts LocalOptions) applyTo(command *exec.Cmd) {
+	if len(opts.Env) > 0 {
+		command.Env = append(operating.System.Environ(), sortedEnv(opts.Env)...)
+	}
+	if opts.Dir != "" {
+		command.Dir = opts.Dir
+	}
+}
+
+func newShellCommandWithOptions(scope Scope, content int, host string, command []string, opts LocalOptions) ShellCommand {
+	shellCommand := NewShellCommand(scope, content, host, command)
+	opts.applyTo(shellCommand.Command)
+	return shellCommand
+}
+
+/*
+ * Unlike ExecuteLocalCommand, this function runs cmd directly instead of
+ * through bash, so its arguments need no quoting, and it returns stdout and
+ * stderr separately along with the exit code.  The returned error is non-nil
+ * if the command could not be run, exited with a non-zero code, or timed out;
+ * ExitCode is -1 if the command did not exit normally.
+ */
+func (executor *GPDBExecutor) ExecuteLocalCommandWithOptions(cmd []string, opts LocalOptions) (LocalOutput, error) {
+	if len(cmd) == 0 {
+		return LocalOutput{ExitCode: -1}, errors.New("No command provided")
+	}
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	command := operating.System.ExecCommandContext(ctx, cmd[0], cmd[1:]...)
+	opts.applyTo(command)
+	command.Stdin = opts.Stdin
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+
+	err := command.Run()
+	output := LocalOutput{Stdout: stdout.String(), Stderr: stderr.String()}
+	if ctx.Err() == context.DeadlineExceeded {
+		output.ExitCode = -1
+		return output, errors.Errorf("Command %s timed out after %s", strings.Join(cmd, " "), opts.Timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		output.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		output.ExitCode = -1
+	}
+	return output, err
+}
@@ -0,0 +1,133 @@
+package cluster_test
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/local tests", func() {
+	var executor *cluster.GPDBExecutor
+	BeforeEach(func() {
+		executor = &cluster.GPDBExecutor{}
+	})
+	Describe("ExecuteLocalCommandWithOptions", func() {
+		It("returns stdout, stderr, and the exit code separately", func() {
+			output, err := executor.ExecuteLocalCommandWithOptions([]string{"bash", "-c", "echo out; echo err >&2; exit 3"}, cluster.LocalOptions{})
+
+			Expect(err).To(HaveOccurred())
+			Expect(output).To(Equal(cluster.LocalOutput{Stdout: "out\n", Stderr: "err\n", ExitCode: 3}))
+		})
+		It("runs the command with the given environment, directory, and input", func() {
+			dir, err := os.MkdirTemp("", "local_test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			opts := cluster.LocalOptions{
+				Env:   map[string]string{"GP_TEST_VAR": "it's set"},
+				Dir:   dir,
+				Stdin: strings.NewReader("input"),
+			}
+
+			output, err := executor.ExecuteLocalCommandWithOptions([]string{"bash", "-c", `echo "$GP_TEST_VAR"; echo "$HOME"; pwd; cat`}, opts)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output.Stdout).To(Equal("it's set\n" + os.Getenv("HOME") + "\n" + dir + "\ninput"))
+			Expect(output.ExitCode).To(Equal(0))
+		})
+		It("kills the command if it times out", func() {
+			start := time.Now()
+
+			output, err := executor.ExecuteLocalCommandWithOptions([]string{"sleep", "10"}, cluster.LocalOptions{Timeout: 50 * time.Millisecond})
+
+			Expect(err).To(MatchError("Command sleep 10 timed out after 50ms"))
+			Expect(output.ExitCode).To(Equal(-1))
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		})
+		It("returns an error if the command cannot be run", func() {
+			output, err := executor.ExecuteLocalCommandWithOptions([]string{"/nonexistent/command"}, cluster.LocalOptions{})
+			Expect(err).To(HaveOccurred())
+			Expect(output.ExitCode).To(Equal(-1))
+
+			_, err = executor.ExecuteLocalCommandWithOptions([]string{}, cluster.LocalOptions{})
+			Expect(err).To(MatchError("No command provided"))
+		})
+		It("can be replaced with a TestExecutor", func() {
+			testExecutor := &testhelper.TestExecutor{LocalOutput: "output"}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{{DbID: 1, ContentID: -1, Hostname: "localhost"}})
+			testCluster.Executor = testExecutor
+			opts := cluster.LocalOptions{Dir: "/tmp"}
+
+			output, err := testCluster.ExecuteLocalCommandWithOptions([]string{"ls", "-l"}, opts)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal(cluster.LocalOutput{Stdout: "output"}))
+			Expect(testExecutor.LocalCommands).To(Equal([]string{"ls -l"}))
+			Expect(testExecutor.LocalOptions).To(Equal([]cluster.LocalOptions{opts}))
+		})
+	})
+	Describe("LocalOptions.WrapCommand", func() {
+		It("returns the command unchanged if there are no options", func() {
+			Expect(cluster.LocalOptions{Timeout: time.Second}.WrapCommand("ls -l")).To(Equal("ls -l"))
+		})
+		It("sets the environment and directory for the command", func() {
+			opts := cluster.LocalOptions{Env: map[string]string{"PGPORT": "6000", "PGOPTIONS": "-c gp_role=utility"}, Dir: "/data/it's here"}
+			wrapped := opts.WrapCommand(`echo "$PGPORT $PGOPTIONS" && pwd`)
+
+			Expect(wrapped).To(Equal(`cd '/data/it'\''s here' && env 'PGOPTIONS=-c gp_role=utility' 'PGPORT=6000' bash -c 'echo "$PGPORT $PGOPTIONS" && pwd'`))
+		})
+		It("produces a command that runs with the options when passed to bash", func() {
+			dir, err := os.MkdirTemp("", "local_test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			opts := cluster.LocalOptions{Env: map[string]string{"GP_TEST_VAR": "value"}, Dir: dir}
+
+			output, err := exec.Command("bash", "-c", opts.WrapCommand(`echo "$GP_TEST_VAR"; pwd`)).Output()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(output)).To(Equal("value\n" + dir + "\n"))
+		})
+	})
+	Describe("generators returning LocalOptions", func() {
+		var (
+			dirs        map[int]string
+			testCluster *cluster.Cluster
+		)
+		BeforeEach(func() {
+			dirs = map[int]string{-1: GinkgoT().TempDir(), 0: GinkgoT().TempDir()}
+			testCluster = cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "localhost", DataDir: dirs[-1]},
+				{DbID: 2, ContentID: 0, Role: "p", Port: 6000, Hostname: "localhost", DataDir: dirs[0]},
+			})
+		})
+		It("run each command from GenerateCommandList with its own environment and directory", func() {
+			commands := testCluster.GenerateCommandList(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(content int) ([]string, cluster.LocalOptions) {
+				opts := cluster.LocalOptions{Env: map[string]string{"GP_TEST_CONTENT": strconv.Itoa(content)}, Dir: dirs[content]}
+				return []string{"bash", "-c", `echo "$GP_TEST_CONTENT"; pwd`}, opts
+			})
+
+			output := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, commands)
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.Commands[0].Stdout).To(Equal("-1\n" + dirs[-1] + "\n"))
+			Expect(output.Commands[1].Stdout).To(Equal("0\n" + dirs[0] + "\n"))
+		})
+		It("wrap each command from GenerateSSHCommandList so the options apply where it runs", func() {
+			commands := testCluster.GenerateSSHCommandList(cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR, func(host string) (string, cluster.LocalOptions) {
+				return `echo "$GP_TEST_HOST"; pwd`, cluster.LocalOptions{Env: map[string]string{"GP_TEST_HOST": host}, Dir: dirs[0]}
+			})
+
+			Expect(commands).To(HaveLen(1))
+			Expect(commands[0].Command.Args[2]).To(Equal("cd '" + dirs[0] + `' && env 'GP_TEST_HOST=localhost' bash -c 'echo "$GP_TEST_HOST"; pwd'`))
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR, commands)
+			Expect(output.Commands[0].Stdout).To(Equal("localhost\n" + dirs[0] + "\n"))
+		})
+	})
+})
@@ -0,0 +1,113 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to collecting server log
+ * files from segments, e.g. for support cases.
+ */
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+type LogCollectionOptions struct {
+	// The maximum total size in bytes of the (uncompressed) log files collected from each segment; 0 means unlimited
+	MaxBytesPerSegment int64
+}
+
+type SegmentLogArchive struct {
+	DbID         int
+	Content      int
+	Host         string
+	Path         string
+	FilesSkipped int // Files in the time window left out because of MaxBytesPerSegment
+}
+
+/*
+ * A LogCollectionOutput wraps the RemoteOutput of the collection commands with
+ * the archive for each segment, in the same order as RemoteOutput.Commands.
+ */
+type LogCollectionOutput struct {
+	*RemoteOutput
+	Archives []SegmentLogArchive
+}
+
+var logFilesSkippedPattern = regexp.MustCompile(`(?m)^Skipped (\d+) log files over the size limit`)
+
+/*
+ * The script runs on the segment host and writes a gzipped tar archive of the
+ * selected log files to stdout.  GPDB 7 writes server logs to "log" and
+ * earlier versions to "pg_log".  Files are considered newest first, so that if
+ * the size limit is reached it is the oldest files that are left out.
+ */
+func logCollectionScript(dataDir string, since time.Time, until time.Time, maxBytes int64) string {
+	// Log file names (e.g. gpdb-2024-01-15_000000.csv) sort by the time at which the file was started
+	untilName := "gpdb-" + until.Format("2006-01-02_150405")
+	return fmt.Sprintf(`cd %s || exit 1
+logdir=""
+for dir in log pg_log; do if [ -d "$dir" ]; then logdir="$dir"; break; fi; done
+if [ -z "$logdir" ]; then echo "No log directory found in %s" >&2; exit 1; fi
+total=0
+skipped=0
+find "$logdir" -maxdepth 1 -type f -newermt "@%d" -printf '%%T@ %%s %%p\n' | sort -rn | {
+	while read -r mtime size path; do
+		name="${path##*/}"
+		case "$name" in gpdb-*) if [[ "$name" > %s ]]; then continue; fi;; esac
+		if [ %d -gt 0 ] && [ $((total + size)) -gt %d ]; then skipped=$((skipped + 1)); continue; fi
+		total=$((total + size))
+		printf '%%s\0' "$path"
+	done
+	if [ $skipped -gt 0 ]; then echo "Skipped $skipped log files over the size limit" >&2; fi
+} | tar --null -czf - -T -`, shellQuote(dataDir), dataDir, since.Unix(), shellQuote(untilName), maxBytes, maxBytes)
+}
+
+/*
+ * CollectSegmentLogs fetches the server log files of each segment in scope that
+ * may contain messages from between since and until into destDir, with one
+ * gzipped tar archive per segment.  Files are selected by modification time
+ * and by the start time in their names, which is in the segment host's local
+ * time zone and is assumed to match the local time zone here.
+ *
+ * Mirrors and the standby are only included if scope includes mirrors, and
+ * the coordinator is only included if scope includes the coordinator.  If a
+ * segment's logs could not be collected, its partial archive is removed.
+ */
+func (cluster *Cluster) CollectSegmentLogs(scope Scope, since time.Time, until time.Time, destDir string, opts LogCollectionOptions) *LogCollectionOutput {
+	err := operating.System.MkdirAll(destDir, 0755)
+	gplog.FatalOnError(err, fmt.Sprintf("Unable to create log collection directory %s", destDir))
+
+	// The generator is called once per command, in the same order as the commands
+	archives := make([]SegmentLogArchive, 0)
+	commands := cluster.GenerateCommandListPerDbid(scope, func(dbid int) []string {
+		segment := cluster.ByDbid[dbid]
+		archive := SegmentLogArchive{
+			DbID:    dbid,
+			Content: segment.ContentID,
+			Host:    segment.Hostname,
+			Path:    filepath.Join(destDir, fmt.Sprintf("gpseg%d_dbid%d_%s_logs.tar.gz", segment.ContentID, dbid, segment.Hostname)),
+		}
+		archives = append(archives, archive)
+
+		script := "bash -c " + shellQuote(logCollectionScript(segment.DataDir, since, until, opts.MaxBytesPerSegment))
+		script = shellJoin(cluster.BuildSegmentCommand(scope, *segment, script))
+		return []string{"bash", "-c", fmt.Sprintf("set -o pipefail; %s > %s", script, shellQuote(archive.Path))}
+	})
+
+	logDomain.Verbose("Collecting segment log files from %s to %s", since, until)
+	remoteOutput := cluster.ExecuteClusterCommand(scope, commands)
+	for i, command := range remoteOutput.Commands {
+		if command.Error != nil {
+			_ = operating.System.Remove(archives[i].Path)
+		}
+		if match := logFilesSkippedPattern.FindStringSubmatch(command.Stderr); match != nil {
+			archives[i].FilesSkipped, _ = strconv.Atoi(match[1])
+		}
+	}
+	return &LogCollectionOutput{RemoteOutput: remoteOutput, Archives: archives}
+}
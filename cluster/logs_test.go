@@ -0,0 +1,132 @@
+package cluster_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/logs tests", func() {
+	var (
+		baseDir  string
+		destDir  string
+		since    time.Time
+		until    time.Time
+		writeLog = func(dataDir string, logDir string, name string, contents string, mtime time.Time) {
+			path := filepath.Join(dataDir, logDir, name)
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+			Expect(os.Chtimes(path, mtime, mtime)).To(Succeed())
+		}
+		listArchive = func(path string) []string {
+			output, err := exec.Command("tar", "-tzf", path).CombinedOutput()
+			Expect(err).ToNot(HaveOccurred(), string(output))
+			return strings.Fields(string(output))
+		}
+	)
+	BeforeEach(func() {
+		var err error
+		baseDir, err = os.MkdirTemp("", "gp_common_go_libs_logs")
+		Expect(err).ToNot(HaveOccurred())
+		destDir = filepath.Join(baseDir, "collected")
+		until = time.Now().Truncate(time.Second)
+		since = until.Add(-2 * time.Hour)
+	})
+	AfterEach(func() {
+		_ = os.RemoveAll(baseDir)
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("CollectSegmentLogs", func() {
+		It("collects log files in the time window from each local segment", func() {
+			coordinatorDir := filepath.Join(baseDir, "gpseg-1")
+			segDir := filepath.Join(baseDir, "gpseg0")
+			mirrorDir := filepath.Join(baseDir, "mirror0")
+			old := since.Add(-24 * time.Hour)
+			// Before the window
+			writeLog(coordinatorDir, "pg_log", "gpdb-"+old.Format("2006-01-02_150405")+".csv", "old", old)
+			// In the window
+			writeLog(coordinatorDir, "pg_log", "gpdb-"+since.Add(-time.Hour).Format("2006-01-02_150405")+".csv", "current", since.Add(time.Hour))
+			// Started after the window, so it contains no messages from the window even though it was modified after since
+			writeLog(coordinatorDir, "pg_log", "gpdb-"+until.Add(time.Hour).Format("2006-01-02_150405")+".csv", "new", until.Add(2*time.Hour))
+			writeLog(segDir, "log", "gpdb-"+since.Format("2006-01-02_150405")+".csv", "segment", since.Add(time.Minute))
+			writeLog(mirrorDir, "log", "startup.log", "mirror", since.Add(time.Minute))
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Hostname: "localhost", DataDir: coordinatorDir},
+				{DbID: 2, ContentID: 0, Role: "p", Hostname: "localhost", DataDir: segDir},
+				{DbID: 3, ContentID: 0, Role: "m", Hostname: "localhost", DataDir: mirrorDir},
+			})
+
+			output := testCluster.CollectSegmentLogs(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, since, until, destDir, cluster.LogCollectionOptions{})
+
+			Expect(output.NumErrors).To(Equal(0), fmt.Sprintf("%v", output.FailedCommands))
+			Expect(output.Archives).To(HaveLen(2))
+			Expect(output.Archives[0].DbID).To(Equal(1))
+			Expect(output.Archives[0].Path).To(Equal(filepath.Join(destDir, "gpseg-1_dbid1_localhost_logs.tar.gz")))
+			Expect(listArchive(output.Archives[0].Path)).To(Equal([]string{"pg_log/gpdb-" + since.Add(-time.Hour).Format("2006-01-02_150405") + ".csv"}))
+			Expect(output.Archives[1].Content).To(Equal(0))
+			Expect(listArchive(output.Archives[1].Path)).To(Equal([]string{"log/gpdb-" + since.Format("2006-01-02_150405") + ".csv"}))
+		})
+		It("includes mirrors if the scope includes mirrors, and leaves out the oldest files over the size limit", func() {
+			segDir := filepath.Join(baseDir, "gpseg0")
+			mirrorDir := filepath.Join(baseDir, "mirror0")
+			writeLog(segDir, "log", "first.log", strings.Repeat("a", 100), since.Add(time.Minute))
+			writeLog(segDir, "log", "second.log", strings.Repeat("b", 100), since.Add(2*time.Minute))
+			writeLog(segDir, "log", "third.log", strings.Repeat("c", 100), since.Add(3*time.Minute))
+			writeLog(mirrorDir, "log", "mirror.log", "mirror", since.Add(time.Minute))
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Hostname: "localhost", DataDir: filepath.Join(baseDir, "gpseg-1")},
+				{DbID: 2, ContentID: 0, Role: "p", Hostname: "localhost", DataDir: segDir},
+				{DbID: 3, ContentID: 0, Role: "m", Hostname: "localhost", DataDir: mirrorDir},
+			})
+
+			output := testCluster.CollectSegmentLogs(cluster.ON_SEGMENTS|cluster.INCLUDE_MIRRORS, since, until, destDir, cluster.LogCollectionOptions{MaxBytesPerSegment: 250})
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.Archives).To(HaveLen(2))
+			Expect(listArchive(output.Archives[0].Path)).To(Equal([]string{"log/third.log", "log/second.log"}))
+			Expect(output.Archives[0].FilesSkipped).To(Equal(1))
+			Expect(listArchive(output.Archives[1].Path)).To(Equal([]string{"log/mirror.log"}))
+			Expect(output.Archives[1].FilesSkipped).To(Equal(0))
+		})
+		It("reports segments without a log directory and removes their archives", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Hostname: "localhost", DataDir: filepath.Join(baseDir, "gpseg-1")},
+				{DbID: 2, ContentID: 0, Role: "p", Hostname: "localhost", DataDir: baseDir},
+			})
+
+			output := testCluster.CollectSegmentLogs(cluster.ON_SEGMENTS, since, until, destDir, cluster.LogCollectionOptions{})
+
+			Expect(output.NumErrors).To(Equal(1))
+			Expect(output.FailedCommands[0].Stderr).To(ContainSubstring("No log directory found in " + baseDir))
+			Expect(output.Archives[0].Path).ToNot(BeAnExistingFile())
+		})
+		It("fetches logs from remote segments over ssh", func() {
+			operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "gpadmin"}, nil }
+			testExecutor := &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{Commands: []cluster.ShellCommand{{}}}}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Hostname: "cdw", DataDir: "/data/gpseg-1"},
+				{DbID: 2, ContentID: 0, Role: "p", Hostname: "sdw1", Address: "sdw1-admin", DataDir: "/data/gpseg0"},
+			})
+			testCluster.Executor = testExecutor
+			testCluster.AddressSelection = cluster.PreferAddress
+
+			testCluster.CollectSegmentLogs(cluster.ON_SEGMENTS, since, until, destDir, cluster.LogCollectionOptions{})
+
+			Expect(testExecutor.ClusterCommands[0]).To(HaveLen(1))
+			commandString := testExecutor.ClusterCommands[0][0].CommandString
+			Expect(commandString).To(HavePrefix("bash -c set -o pipefail; ssh -o StrictHostKeyChecking=no gpadmin@sdw1-admin 'bash -c '\\''cd '\\''\\'\\'''\\''/data/gpseg0"))
+			Expect(commandString).To(HaveSuffix("> '" + filepath.Join(destDir, "gpseg0_dbid2_sdw1_logs.tar.gz") + "'"))
+		})
+	})
+})
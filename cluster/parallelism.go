@@ -0,0 +1,105 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to deriving how many
+ * commands can safely run at once on each host from the host's resources,
+ * rather than each utility hardcoding a fan-out factor.
+ */
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+// The memory set aside for each concurrent command when limiting parallelism by memory
+const HOST_PARALLELISM_MEMORY_PER_COMMAND = 512 * 1024 * 1024
+
+/*
+ * HostResources describes the CPUs and memory of a host.  AvailableMemory is
+ * in bytes, and is ignored if zero.
+ */
+type HostResources struct {
+	NumCPU          int
+	AvailableMemory uint64
+}
+
+/*
+ * The hosts of a cluster usually have identical hardware, so hosts not in the
+ * cluster's HostResources are assumed to have the resources of the host the
+ * utility runs on.  Memory is only known on Linux; elsewhere, parallelism is
+ * limited by CPUs alone.
+ */
+func (cluster *Cluster) resourcesForHost(host string) HostResources {
+	if resources, ok := cluster.HostResources[host]; ok {
+		return resources
+	}
+	resources := HostResources{NumCPU: operating.System.NumCPU()}
+	if memInfo, err := operating.System.MemInfo(); err == nil {
+		resources.AvailableMemory = memInfo.Available
+	} else {
+		logDomain.Debug("Unable to determine available memory for parallelism on %s: %v", host, err)
+	}
+	return resources
+}
+
+/*
+ * GetHostParallelism returns how many commands should run at once on the
+ * host.  Each primary segment on the host (including the coordinator) is
+ * assumed to be busy with the operation too, e.g. loading the data a restore
+ * sends it, so one CPU is left for each of them, but commands always get at
+ * least half of the host's CPUs.  The result is further limited so that each
+ * command has HOST_PARALLELISM_MEMORY_PER_COMMAND bytes of the host's
+ * available memory, and is always at least 1.
+ */
+func (cluster *Cluster) GetHostParallelism(host string) int {
+	resources := cluster.resourcesForHost(host)
+	numPrimaries := 0
+	for _, segment := range cluster.ByHost[host] {
+		if segment.Role == "p" {
+			numPrimaries++
+		}
+	}
+	parallelism := resources.NumCPU - numPrimaries
+	if half := (resources.NumCPU + 1) / 2; parallelism < half {
+		parallelism = half
+	}
+	if resources.AvailableMemory > 0 {
+		if memoryLimit := int(resources.AvailableMemory / HOST_PARALLELISM_MEMORY_PER_COMMAND); memoryLimit < parallelism {
+			parallelism = memoryLimit
+		}
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return parallelism
+}
+
+// HostParallelism returns the result of GetHostParallelism for each host in the cluster
+func (cluster *Cluster) HostParallelism() map[string]int {
+	parallelism := make(map[string]int, len(cluster.Hostnames))
+	for _, host := range cluster.Hostnames {
+		parallelism[host] = cluster.GetHostParallelism(host)
+	}
+	return parallelism
+}
+
+/*
+ * UseHostParallelism limits the number of commands the cluster's executor runs
+ * at once on each host to the host's parallelism, replacing any limits set in
+ * MaxInFlightByHost, and returns the limits it set.  The limits are keyed by
+ * both the hostname and the address chosen by the cluster's AddressSelection,
+ * so that they apply to per-segment commands as well as per-host commands.
+ * Executors other than a GPDBExecutor are left unchanged.
+ */
+func (cluster *Cluster) UseHostParallelism() map[string]int {
+	parallelism := cluster.HostParallelism()
+	limits := make(map[string]int, 2*len(parallelism))
+	for host, limit := range parallelism {
+		limits[host] = limit
+		limits[cluster.GetAddressForHost(host)] = limit
+	}
+	if executor, ok := cluster.Executor.(*GPDBExecutor); ok {
+		executor.Options.MaxInFlightByHost = limits
+		logDomain.Verbose("Limiting commands per host to %v", parallelism)
+	}
+	return parallelism
+}
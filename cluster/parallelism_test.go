@@ -0,0 +1,92 @@
+package cluster_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/parallelism tests", func() {
+	const gigabyte = 1024 * 1024 * 1024
+	var testCluster *cluster.Cluster
+
+	BeforeEach(func() {
+		operating.System.NumCPU = func() int { return 16 }
+		operating.System.MemInfo = func() (operating.MemoryInfo, error) {
+			return operating.MemoryInfo{Total: 64 * gigabyte, Available: 32 * gigabyte}, nil
+		}
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "p", Hostname: "cdw", Address: "10.0.0.1"},
+			{DbID: 2, ContentID: 0, Role: "p", Hostname: "sdw1", Address: "10.0.0.2"},
+			{DbID: 3, ContentID: 1, Role: "p", Hostname: "sdw1", Address: "10.0.0.2"},
+			{DbID: 4, ContentID: 2, Role: "p", Hostname: "sdw1", Address: "10.0.0.2"},
+			{DbID: 5, ContentID: 3, Role: "p", Hostname: "sdw1", Address: "10.0.0.2"},
+			{DbID: 6, ContentID: 0, Role: "m", Hostname: "sdw2", Address: "10.0.0.3"},
+			{DbID: 7, ContentID: 1, Role: "m", Hostname: "sdw2", Address: "10.0.0.3"},
+		})
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("Cluster.GetHostParallelism", func() {
+		It("leaves one CPU for each primary on the host", func() {
+			Expect(testCluster.GetHostParallelism("cdw")).To(Equal(15))
+			Expect(testCluster.GetHostParallelism("sdw1")).To(Equal(12))
+		})
+		It("does not leave CPUs for mirrors", func() {
+			Expect(testCluster.GetHostParallelism("sdw2")).To(Equal(16))
+		})
+		It("uses at least half of the CPUs", func() {
+			operating.System.NumCPU = func() int { return 5 }
+
+			Expect(testCluster.GetHostParallelism("sdw1")).To(Equal(3))
+		})
+		It("limits parallelism by available memory", func() {
+			operating.System.MemInfo = func() (operating.MemoryInfo, error) {
+				return operating.MemoryInfo{Total: 64 * gigabyte, Available: 2 * gigabyte}, nil
+			}
+
+			Expect(testCluster.GetHostParallelism("sdw1")).To(Equal(4))
+		})
+		It("is limited by CPUs alone if memory cannot be determined", func() {
+			operating.System.MemInfo = func() (operating.MemoryInfo, error) {
+				return operating.MemoryInfo{}, errors.New("Unable to read memory information")
+			}
+
+			Expect(testCluster.GetHostParallelism("sdw2")).To(Equal(16))
+		})
+		It("is always at least 1", func() {
+			operating.System.NumCPU = func() int { return 1 }
+			operating.System.MemInfo = func() (operating.MemoryInfo, error) {
+				return operating.MemoryInfo{Available: 100 * 1024 * 1024}, nil
+			}
+
+			Expect(testCluster.GetHostParallelism("sdw1")).To(Equal(1))
+		})
+		It("uses the resources set for a host with different hardware", func() {
+			testCluster.HostResources = map[string]cluster.HostResources{"sdw1": {NumCPU: 64, AvailableMemory: 256 * gigabyte}}
+
+			Expect(testCluster.GetHostParallelism("sdw1")).To(Equal(60))
+			Expect(testCluster.GetHostParallelism("sdw2")).To(Equal(16))
+		})
+	})
+	Describe("Cluster.UseHostParallelism", func() {
+		It("sets the executor's per-host limits by hostname and address", func() {
+			executor := &cluster.GPDBExecutor{}
+			testCluster.Executor = executor
+			testCluster.AddressSelection = cluster.PreferAddress
+
+			parallelism := testCluster.UseHostParallelism()
+
+			Expect(parallelism).To(Equal(map[string]int{"cdw": 15, "sdw1": 12, "sdw2": 16}))
+			Expect(executor.Options.MaxInFlightByHost).To(Equal(map[string]int{
+				"cdw": 15, "10.0.0.1": 15,
+				"sdw1": 12, "10.0.0.2": 12,
+				"sdw2": 16, "10.0.0.3": 16,
+			}))
+		})
+	})
+})
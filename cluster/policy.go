@@ -0,0 +1,53 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to stopping the execution
+ * of cluster commands early when some of them fail.
+ */
+
+/*
+ * ExecutionOptions control whether GPDBExecutor.ExecuteClusterCommand keeps
+ * running a batch of commands after some of them fail, and how many of them it
+ * runs at once.  By default every command is started at once and is run to
+ * completion.
+ *
+ * FailFast aborts the batch after the first failure, and MaxErrors, if
+ * positive, aborts it once that many commands have failed; the AbortAfterErrors
+ * hooks set the same limit.  BreakOnHostError
+ * aborts the remaining commands on a host once one command on it has failed,
+ * e.g. because the host is unreachable, while commands on other hosts keep
+ * running; it only applies to commands whose host is known, which are those
+ * with Host set and those sent over ssh.
+ *
+ * Aborted commands are killed if they are running and are not run otherwise,
+ * and their Error is a CommandAbortedError.  They count toward NumErrors in
+ * the resulting RemoteOutput, but not toward MaxErrors.
+ *
+ * MaxInFlight, if positive, limits the number of commands running at once,
+ * and MaxInFlightPerHost, if positive, limits the number running at once on
+ * any one host, e.g. to stay under a host's ssh connection limit.
+ * MaxInFlightByHost overrides MaxInFlightPerHost for the hosts it contains;
+ * per-segment commands have no Host, so they are matched by the address they
+ * are sent to over ssh, and Cluster.UseHostParallelism sets it for both.  When
+ * any of these is set, commands are started in round-robin order across
+ * hosts; see scheduler.go.
+ */
+type ExecutionOptions struct {
+	FailFast           bool
+	MaxErrors          int
+	BreakOnHostError   bool
+	MaxInFlight        int
+	MaxInFlightPerHost int
+	MaxInFlightByHost  map[string]int
+}
+
+// Returns the host a command runs on, or "" if it cannot be determined
+func commandHost(command ShellCommand) string {
+	if command.Host != "" {
+		return command.Host
+	}
+	if command.Command == nil {
+		return ""
+	}
+	return sshDestinationAddress(command.Command.Args)
+}
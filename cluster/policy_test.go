@@ -0,0 +1,69 @@
+package cluster_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/policy tests", func() {
+	newHostCommand := func(host string, script string) cluster.ShellCommand {
+		return cluster.NewShellCommand(cluster.ON_HOSTS, -2, host, []string{"bash", "-c", script})
+	}
+	errorStrings := func(output *cluster.RemoteOutput) []string {
+		errs := make([]string, len(output.Commands))
+		for i, command := range output.Commands {
+			if command.Error != nil {
+				errs[i] = command.Error.Error()
+			}
+		}
+		return errs
+	}
+
+	Describe("ExecutionOptions", func() {
+		It("runs every command by default", func() {
+			executor := &cluster.GPDBExecutor{}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, []cluster.ShellCommand{
+				newHostCommand("sdw1", "exit 1"),
+				newHostCommand("sdw2", "sleep 0.2"),
+			})
+
+			Expect(errorStrings(output)).To(Equal([]string{"exit status 1", ""}))
+		})
+		It("aborts the remaining commands after the first failure with FailFast", func() {
+			executor := &cluster.GPDBExecutor{Options: cluster.ExecutionOptions{FailFast: true}}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, []cluster.ShellCommand{
+				newHostCommand("sdw1", "exit 1"),
+				newHostCommand("sdw2", "exec sleep 30"),
+			})
+
+			Expect(errorStrings(output)).To(Equal([]string{"exit status 1", "Command aborted: 1 commands failed"}))
+			Expect(output.NumErrors).To(Equal(2))
+		})
+		It("aborts the remaining commands once MaxErrors commands have failed", func() {
+			executor := &cluster.GPDBExecutor{Options: cluster.ExecutionOptions{MaxErrors: 2}}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, []cluster.ShellCommand{
+				newHostCommand("sdw1", "exit 1"),
+				newHostCommand("sdw2", "sleep 0.2; exit 2"),
+				newHostCommand("sdw3", "exec sleep 30"),
+			})
+
+			Expect(errorStrings(output)).To(Equal([]string{"exit status 1", "exit status 2", "Command aborted: 2 commands failed"}))
+		})
+		It("aborts only the remaining commands on a failed host with BreakOnHostError", func() {
+			executor := &cluster.GPDBExecutor{Options: cluster.ExecutionOptions{BreakOnHostError: true}}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, []cluster.ShellCommand{
+				newHostCommand("sdw1", "exit 255"),
+				newHostCommand("sdw1", "exec sleep 30"),
+				newHostCommand("sdw2", "sleep 0.2"),
+			})
+
+			Expect(errorStrings(output)).To(Equal([]string{"exit status 255", "Command aborted: An earlier command on host sdw1 failed", ""}))
+		})
+	})
+})
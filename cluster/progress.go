@@ -0,0 +1,89 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to reporting the progress
+ * of a batch of cluster commands while it runs.
+ */
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+/*
+ * A CommandProgress is the state of a batch of cluster commands after one of
+ * them completes.  Aborted commands count as completed and failed.
+ */
+type CommandProgress struct {
+	NumCompleted int
+	NumFailed    int
+	NumCommands  int
+	Elapsed      time.Duration
+}
+
+func (progress CommandProgress) String() string {
+	return fmt.Sprintf("%d of %d commands completed (%d failed)", progress.NumCompleted, progress.NumCommands, progress.NumFailed)
+}
+
+/*
+ * ProgressOptions determine how GPDBExecutor.ExecuteClusterCommand reports
+ * the progress of a batch.  If LogInterval is positive, a line such as
+ *   "120 of 480 commands completed (2 failed)"
+ * is logged at the Info level when a command completes at least LogInterval
+ * after the start of the batch or the last such line, and a final line is
+ * logged when the batch completes if any earlier line was, so that batches
+ * that finish quickly log nothing.  If Report is set, it is called after each
+ * command completes, e.g. to render a progress bar; as with CommandHooks, calls
+ * for a batch are never concurrent.
+ */
+type ProgressOptions struct {
+	LogInterval time.Duration
+	Report      func(progress CommandProgress)
+}
+
+type progressReporter struct {
+	options  ProgressOptions
+	progress CommandProgress
+	start    time.Time
+	lastLog  time.Time
+	logged   bool
+}
+
+func newProgressReporter(options ProgressOptions, numCommands int) *progressReporter {
+	if options.LogInterval <= 0 && options.Report == nil {
+		return nil
+	}
+	start := operating.System.Now()
+	return &progressReporter{
+		options:  options,
+		progress: CommandProgress{NumCommands: numCommands},
+		start:    start,
+		lastLog:  start,
+	}
+}
+
+func (reporter *progressReporter) commandCompleted(command ShellCommand) {
+	if reporter == nil {
+		return
+	}
+	now := operating.System.Now()
+	reporter.progress.NumCompleted++
+	if command.Error != nil {
+		reporter.progress.NumFailed++
+	}
+	reporter.progress.Elapsed = now.Sub(reporter.start)
+	if reporter.options.Report != nil {
+		reporter.options.Report(reporter.progress)
+	}
+	if reporter.options.LogInterval <= 0 {
+		return
+	}
+	finished := reporter.progress.NumCompleted == reporter.progress.NumCommands
+	if (finished && reporter.logged) || (!finished && now.Sub(reporter.lastLog) >= reporter.options.LogInterval) {
+		logDomain.Info("%s", reporter.progress)
+		reporter.lastLog = now
+		reporter.logged = true
+	}
+}
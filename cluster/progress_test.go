@@ -0,0 +1,63 @@
+package cluster_test
+
+import (
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/progress tests", func() {
+	newCommands := func() []cluster.ShellCommand {
+		return []cluster.ShellCommand{
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"bash", "-c", "true"}),
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 1, "", []string{"bash", "-c", "exit 1"}),
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 2, "", []string{"bash", "-c", "true"}),
+		}
+	}
+
+	Describe("CommandProgress", func() {
+		It("describes the completed and failed commands", func() {
+			progress := cluster.CommandProgress{NumCompleted: 120, NumFailed: 2, NumCommands: 480}
+
+			Expect(progress.String()).To(Equal("120 of 480 commands completed (2 failed)"))
+		})
+	})
+	Describe("GPDBExecutor.Progress", func() {
+		It("reports progress after each command completes", func() {
+			reported := make([]cluster.CommandProgress, 0)
+			executor := &cluster.GPDBExecutor{Progress: cluster.ProgressOptions{
+				Report: func(progress cluster.CommandProgress) { reported = append(reported, progress) },
+			}}
+
+			executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands())
+
+			Expect(reported).To(HaveLen(3))
+			for i, progress := range reported {
+				Expect(progress.NumCompleted).To(Equal(i + 1))
+				Expect(progress.NumCommands).To(Equal(3))
+			}
+			Expect(reported[2].NumFailed).To(Equal(1))
+			Expect(reported[2].Elapsed).To(BeNumerically(">", 0))
+		})
+		It("logs progress once the interval has passed, and a final line", func() {
+			executor := &cluster.GPDBExecutor{Progress: cluster.ProgressOptions{LogInterval: time.Nanosecond}}
+
+			executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands())
+
+			testhelper.ExpectRegexp(logfile, "[INFO]:-1 of 3 commands completed")
+			testhelper.ExpectRegexp(logfile, "[INFO]:-2 of 3 commands completed")
+			testhelper.ExpectRegexp(logfile, "[INFO]:-3 of 3 commands completed (1 failed)")
+		})
+		It("logs nothing if the batch completes within the interval", func() {
+			executor := &cluster.GPDBExecutor{Progress: cluster.ProgressOptions{LogInterval: time.Hour}}
+
+			executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, newCommands())
+
+			testhelper.NotExpectRegexp(logfile, "commands completed")
+		})
+	})
+})
@@ -0,0 +1,207 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to summarizing the results
+ * of a cluster command by host or by error.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * A HostSummary aggregates the results of all commands in a RemoteOutput that
+ * ran on (or against) a single host.  Errors contains each distinct error
+ * message once, in the order in which the commands were generated.
+ */
+type HostSummary struct {
+	Host          string
+	Contents      []int
+	NumCommands   int
+	NumErrors     int
+	Errors        []string
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+}
+
+func (summary HostSummary) String() string {
+	message := fmt.Sprintf("%s: %d of %d commands failed in %s", summary.Host, summary.NumErrors, summary.NumCommands, summary.MaxDuration)
+	if len(summary.Errors) > 0 {
+		message += ": " + strings.Join(summary.Errors, "; ")
+	}
+	return message
+}
+
+func commandErrorString(command ShellCommand) string {
+	errStr := command.Error.Error()
+	if stderr := strings.TrimSpace(command.Stderr); stderr != "" {
+		errStr += ": " + stderr
+	}
+	return errStr
+}
+
+/*
+ * RollupByHost returns one HostSummary per host in the output, with hosts that
+ * had failures first and hosts otherwise sorted by name, so that the hosts
+ * needing attention are listed first when a command fails on part of a large
+ * cluster.
+ *
+ * Commands generated per content do not record their host, so cluster is used
+ * to look up the host for each content; it may be nil if all commands were
+ * generated per host or per dbid.  Commands whose host cannot be determined
+ * are summarized under an empty host name.
+ */
+func (remoteOutput *RemoteOutput) RollupByHost(cluster *Cluster) []HostSummary {
+	summaries := make([]*HostSummary, 0)
+	byHost := make(map[string]*HostSummary)
+	seenErrors := make(map[string]map[string]bool)
+	for _, command := range remoteOutput.Commands {
+		host := command.Host
+		if host == "" && cluster != nil {
+			host = cluster.GetHostForContent(command.Content)
+		}
+		summary, ok := byHost[host]
+		if !ok {
+			summary = &HostSummary{Host: host, Contents: []int{}, Errors: []string{}}
+			byHost[host] = summary
+			seenErrors[host] = make(map[string]bool)
+			summaries = append(summaries, summary)
+		}
+		if command.Content != -2 {
+			summary.Contents = append(summary.Contents, command.Content)
+		}
+		summary.NumCommands++
+		summary.TotalDuration += command.Duration
+		if command.Duration > summary.MaxDuration {
+			summary.MaxDuration = command.Duration
+		}
+		if command.Error != nil {
+			summary.NumErrors++
+			errStr := commandErrorString(command)
+			if !seenErrors[host][errStr] {
+				seenErrors[host][errStr] = true
+				summary.Errors = append(summary.Errors, errStr)
+			}
+		}
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if (summaries[i].NumErrors > 0) != (summaries[j].NumErrors > 0) {
+			return summaries[i].NumErrors > 0
+		}
+		return summaries[i].Host < summaries[j].Host
+	})
+	rollup := make([]HostSummary, len(summaries))
+	for i, summary := range summaries {
+		sort.Ints(summary.Contents)
+		rollup[i] = *summary
+	}
+	return rollup
+}
+
+/*
+ * An ErrorGroup collects the failed commands in a RemoteOutput that failed with
+ * the same error.  Error is the normalized error text shared by the commands,
+ * and Contents and Hosts list where they ran, in the order in which the
+ * commands were generated; Contents is empty for commands generated per host.
+ */
+type ErrorGroup struct {
+	Error    string
+	Contents []int
+	Hosts    []string
+	Commands []*ShellCommand
+}
+
+/*
+ * The same failure on different segments usually differs only in the host
+ * name and data directory, so those are replaced with placeholders, and runs
+ * of whitespace are collapsed, before errors are compared.
+ */
+func normalizeCommandError(errStr string, host string, dataDir string) string {
+	if dataDir != "" {
+		errStr = strings.ReplaceAll(errStr, dataDir, "<datadir>")
+	}
+	if host != "" {
+		errStr = regexp.MustCompile(`\b`+regexp.QuoteMeta(host)+`\b`).ReplaceAllString(errStr, "<host>")
+	}
+	return strings.Join(strings.Fields(errStr), " ")
+}
+
+/*
+ * GroupErrors returns one ErrorGroup per distinct error among the failed
+ * commands in the output, ordered by the first command to fail with each one.
+ * As with RollupByHost, cluster is used to look up the host and data directory
+ * of commands generated per content, and may be nil.
+ */
+func (remoteOutput *RemoteOutput) GroupErrors(cluster *Cluster) []ErrorGroup {
+	groups := make([]*ErrorGroup, 0)
+	byError := make(map[string]*ErrorGroup)
+	for _, command := range remoteOutput.FailedCommands {
+		host := command.Host
+		dataDir := ""
+		if cluster != nil && command.Content != -2 {
+			if host == "" {
+				host = cluster.GetHostForContent(command.Content)
+			}
+			dataDir = cluster.GetDirForContent(command.Content)
+		}
+		errStr := normalizeCommandError(commandErrorString(*command), host, dataDir)
+		group, ok := byError[errStr]
+		if !ok {
+			group = &ErrorGroup{Error: errStr, Contents: []int{}, Hosts: []string{}, Commands: []*ShellCommand{}}
+			byError[errStr] = group
+			groups = append(groups, group)
+		}
+		if command.Content != -2 {
+			group.Contents = append(group.Contents, command.Content)
+		}
+		if host != "" && !containsString(group.Hosts, host) {
+			group.Hosts = append(group.Hosts, host)
+		}
+		group.Commands = append(group.Commands, command)
+	}
+	errorGroups := make([]ErrorGroup, len(groups))
+	for i, group := range groups {
+		errorGroups[i] = *group
+	}
+	return errorGroups
+}
+
+func containsString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * FormatContentRanges formats a list of contents compactly, collapsing runs of
+ * consecutive segment contents into ranges, e.g. "-1, 0-3, 7, 9-10".  The
+ * coordinator's content is always listed on its own.
+ */
+func FormatContentRanges(contents []int) string {
+	sorted := make([]int, len(contents))
+	copy(sorted, contents)
+	sort.Ints(sorted)
+	ranges := make([]string, 0)
+	for i := 0; i < len(sorted); {
+		j := i
+		for sorted[i] >= 0 && j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+		if sorted[j] == sorted[i] {
+			ranges = append(ranges, strconv.Itoa(sorted[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ", ")
+}
@@ -0,0 +1,128 @@
+package cluster_test
+
+import (
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/rollup tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1"}
+	segZero := cluster.SegConfig{DbID: 2, ContentID: 0, Role: "p", Port: 6000, Hostname: "sdw1", DataDir: "/data/gpseg0"}
+	segOne := cluster.SegConfig{DbID: 3, ContentID: 1, Role: "p", Port: 6001, Hostname: "sdw1", DataDir: "/data/gpseg1"}
+	segTwo := cluster.SegConfig{DbID: 4, ContentID: 2, Role: "p", Port: 6000, Hostname: "sdw2", DataDir: "/data/gpseg2"}
+	var testCluster *cluster.Cluster
+	BeforeEach(func() {
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, segZero, segOne, segTwo})
+	})
+	Describe("RollupByHost", func() {
+		It("summarizes per-content commands by host, listing failed hosts first", func() {
+			diskErr := errors.New("exit status 1")
+			commands := []cluster.ShellCommand{
+				{Content: -1, Duration: time.Second},
+				{Content: 0, Duration: 2 * time.Second, Error: diskErr, Stderr: "No space left on device\n"},
+				{Content: 1, Duration: 3 * time.Second, Error: diskErr, Stderr: "No space left on device\n"},
+				{Content: 2, Duration: time.Second},
+			}
+			output := cluster.NewRemoteOutput(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, 2, commands)
+
+			rollup := output.RollupByHost(testCluster)
+
+			Expect(rollup).To(Equal([]cluster.HostSummary{
+				{Host: "sdw1", Contents: []int{0, 1}, NumCommands: 2, NumErrors: 2, Errors: []string{"exit status 1: No space left on device"}, TotalDuration: 5 * time.Second, MaxDuration: 3 * time.Second},
+				{Host: "cdw", Contents: []int{-1}, NumCommands: 1, Errors: []string{}, TotalDuration: time.Second, MaxDuration: time.Second},
+				{Host: "sdw2", Contents: []int{2}, NumCommands: 1, Errors: []string{}, TotalDuration: time.Second, MaxDuration: time.Second},
+			}))
+		})
+		It("keeps each distinct error on a host", func() {
+			commands := []cluster.ShellCommand{
+				{Content: 0, Error: errors.New("exit status 1"), Stderr: "permission denied"},
+				{Content: 1, Error: errors.New("exit status 2")},
+			}
+			output := cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 2, commands)
+
+			rollup := output.RollupByHost(testCluster)
+
+			Expect(rollup).To(HaveLen(1))
+			Expect(rollup[0].Errors).To(Equal([]string{"exit status 1: permission denied", "exit status 2"}))
+			Expect(rollup[0].String()).To(Equal("sdw1: 2 of 2 commands failed in 0s: exit status 1: permission denied; exit status 2"))
+		})
+		It("uses the host recorded in per-host commands without a cluster", func() {
+			commands := []cluster.ShellCommand{
+				{Content: -2, Host: "sdw2", Duration: time.Second},
+				{Content: -2, Host: "sdw1", Duration: 2 * time.Second},
+			}
+			output := cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, commands)
+
+			rollup := output.RollupByHost(nil)
+
+			Expect(rollup).To(HaveLen(2))
+			Expect(rollup[0].Host).To(Equal("sdw1"))
+			Expect(rollup[0].Contents).To(BeEmpty())
+			Expect(rollup[1].Host).To(Equal("sdw2"))
+			Expect(rollup[1].String()).To(Equal("sdw2: 0 of 1 commands failed in 1s"))
+		})
+		It("returns an empty rollup for empty output", func() {
+			Expect(cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{}).RollupByHost(testCluster)).To(BeEmpty())
+		})
+	})
+	Describe("GroupErrors", func() {
+		It("groups failed commands by error, ignoring host names and data directories", func() {
+			commands := []cluster.ShellCommand{
+				{Content: 0, Error: errors.New("exit status 1"), Stderr: "cannot open /data/gpseg0/PG_VERSION on sdw1"},
+				{Content: 1, Error: errors.New("exit status 1"), Stderr: "cannot open /data/gpseg1/PG_VERSION on  sdw1\n"},
+				{Content: 2, Error: errors.New("exit status 2"), Stderr: "No space left on device"},
+				{Content: -1},
+				{Content: 2, Error: errors.New("exit status 1"), Stderr: "cannot open /data/gpseg2/PG_VERSION on sdw2"},
+			}
+			output := cluster.NewRemoteOutput(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, 4, commands)
+
+			groups := output.GroupErrors(testCluster)
+
+			Expect(groups).To(HaveLen(2))
+			Expect(groups[0].Error).To(Equal("exit status 1: cannot open <datadir>/PG_VERSION on <host>"))
+			Expect(groups[0].Contents).To(Equal([]int{0, 1, 2}))
+			Expect(groups[0].Hosts).To(Equal([]string{"sdw1", "sdw2"}))
+			Expect(groups[0].Commands).To(HaveLen(3))
+			Expect(groups[1].Error).To(Equal("exit status 2: No space left on device"))
+			Expect(groups[1].Contents).To(Equal([]int{2}))
+		})
+		It("groups per-host commands without a cluster", func() {
+			commands := []cluster.ShellCommand{
+				{Content: -2, Host: "sdw1", Error: errors.New("exit status 255"), Stderr: "ssh: connect to host sdw1 port 22: Connection refused"},
+				{Content: -2, Host: "sdw2", Error: errors.New("exit status 255"), Stderr: "ssh: connect to host sdw2 port 22: Connection refused"},
+			}
+			output := cluster.NewRemoteOutput(cluster.ON_HOSTS, 2, commands)
+
+			groups := output.GroupErrors(nil)
+
+			Expect(groups).To(Equal([]cluster.ErrorGroup{{
+				Error:    "exit status 255: ssh: connect to host <host> port 22: Connection refused",
+				Contents: []int{},
+				Hosts:    []string{"sdw1", "sdw2"},
+				Commands: output.FailedCommands,
+			}}))
+		})
+	})
+	Describe("FormatContentRanges", func() {
+		It("collapses consecutive segment contents into ranges", func() {
+			Expect(cluster.FormatContentRanges([]int{9, 0, 1, 2, 3, 7, 10})).To(Equal("0-3, 7, 9-10"))
+			Expect(cluster.FormatContentRanges([]int{0, -1, 1})).To(Equal("-1, 0-1"))
+			Expect(cluster.FormatContentRanges([]int{})).To(Equal(""))
+		})
+	})
+	Describe("ExecuteClusterCommand", func() {
+		It("records the duration of each command", func() {
+			executor := &cluster.GPDBExecutor{}
+			commandList := []cluster.ShellCommand{cluster.NewShellCommand(cluster.ON_HOSTS, -2, "localhost", []string{"sleep", "0.01"})}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, commandList)
+
+			Expect(output.Commands[0].Duration).To(BeNumerically(">=", 10*time.Millisecond))
+		})
+	})
+})
@@ -0,0 +1,165 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to synchronizing
+ * directories across the cluster using rsync.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+)
+
+type RsyncOptions struct {
+	Delete    bool     // Delete files in the destination that are not in the source
+	BwLimit   int      // Bandwidth limit in KB/s; 0 means unlimited
+	Excludes  []string // Patterns passed to --exclude
+	ExtraArgs []string // Any other arguments, passed through verbatim
+}
+
+/*
+ * Statistics parsed from the output of rsync --stats.  All sizes are in bytes.
+ */
+type RsyncStats struct {
+	NumFiles             int64
+	NumFilesTransferred  int64
+	TotalFileSize        int64
+	TotalTransferredSize int64
+}
+
+func (stats *RsyncStats) add(other RsyncStats) {
+	stats.NumFiles += other.NumFiles
+	stats.NumFilesTransferred += other.NumFilesTransferred
+	stats.TotalFileSize += other.TotalFileSize
+	stats.TotalTransferredSize += other.TotalTransferredSize
+}
+
+/*
+ * An RsyncOutput wraps the RemoteOutput of the rsync commands with the
+ * statistics for each command, in the same order as RemoteOutput.Commands,
+ * and the statistics summed across all successful commands.
+ */
+type RsyncOutput struct {
+	*RemoteOutput
+	Stats      []RsyncStats
+	TotalStats RsyncStats
+}
+
+/*
+ * Newer versions of rsync report "Number of regular files transferred" while
+ * older ones report "Number of files transferred"; numbers may contain commas.
+ */
+var rsyncStatsPatterns = []struct {
+	pattern *regexp.Regexp
+	field   func(stats *RsyncStats) *int64
+}{
+	{regexp.MustCompile(`(?m)^Number of files: ([\d,]+)`), func(stats *RsyncStats) *int64 { return &stats.NumFiles }},
+	{regexp.MustCompile(`(?m)^Number of (?:regular )?files transferred: ([\d,]+)`), func(stats *RsyncStats) *int64 { return &stats.NumFilesTransferred }},
+	{regexp.MustCompile(`(?m)^Total file size: ([\d,]+) bytes`), func(stats *RsyncStats) *int64 { return &stats.TotalFileSize }},
+	{regexp.MustCompile(`(?m)^Total transferred file size: ([\d,]+) bytes`), func(stats *RsyncStats) *int64 { return &stats.TotalTransferredSize }},
+}
+
+func ParseRsyncStats(output string) RsyncStats {
+	stats := RsyncStats{}
+	for _, stat := range rsyncStatsPatterns {
+		match := stat.pattern.FindStringSubmatch(output)
+		if match == nil {
+			continue
+		}
+		value, err := strconv.ParseInt(strings.Replace(match[1], ",", "", -1), 10, 64)
+		if err == nil {
+			*stat.field(&stats) = value
+		}
+	}
+	return stats
+}
+
+// Wrap a string in single quotes so that it is passed to a command unchanged by the shell
+func shellQuote(str string) string {
+	return "'" + strings.Replace(str, "'", `'\''`, -1) + "'"
+}
+
+var shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Joins the arguments into a command string, quoting only those that need it
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if shellSafePattern.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = shellQuote(arg)
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+/*
+ * The generated command checks that rsync is installed before running it, so
+ * that a missing rsync binary produces a clear error instead of just an exit
+ * status of 127.
+ */
+func (opts RsyncOptions) command(src string, dest string) string {
+	args := []string{"rsync", "-a", "--stats"}
+	if opts.Delete {
+		args = append(args, "--delete")
+	}
+	if opts.BwLimit > 0 {
+		args = append(args, fmt.Sprintf("--bwlimit=%d", opts.BwLimit))
+	}
+	for _, exclude := range opts.Excludes {
+		args = append(args, "--exclude="+shellQuote(exclude))
+	}
+	args = append(args, opts.ExtraArgs...)
+	args = append(args, shellQuote(src), shellQuote(dest))
+	return fmt.Sprintf(`if ! command -v rsync > /dev/null 2>&1; then echo "rsync is not installed on $(hostname)" >&2; exit 127; fi; %s`, strings.Join(args, " "))
+}
+
+/*
+ * SyncDirectory runs one rsync command per segment or host in scope, with each
+ * command run on the segment host (or, for ON_LOCAL, the coordinator host) via
+ * GenerateAndExecuteCommand.  As with GenerateCommandList, srcFunc and destFunc
+ * must both be either func(int) string or func(string) string, and return rsync
+ * locations in any form rsync accepts (e.g. "/path" or "host:/path").
+ */
+func (cluster *Cluster) SyncDirectory(scope Scope, srcFunc interface{}, destFunc interface{}, opts RsyncOptions) *RsyncOutput {
+	var generator interface{}
+	switch getSrc := srcFunc.(type) {
+	case func(content int) string:
+		getDest, ok := destFunc.(func(content int) string)
+		if !ok {
+			gplog.Fatal(nil, "Source and destination functions passed to SyncDirectory must have the same function header.")
+		}
+		generator = func(content int) string {
+			return opts.command(getSrc(content), getDest(content))
+		}
+	case func(host string) string:
+		getDest, ok := destFunc.(func(host string) string)
+		if !ok {
+			gplog.Fatal(nil, "Source and destination functions passed to SyncDirectory must have the same function header.")
+		}
+		generator = func(host string) string {
+			return opts.command(getSrc(host), getDest(host))
+		}
+	default:
+		gplog.Fatal(nil, "Source function passed to SyncDirectory had an invalid function header.")
+	}
+
+	remoteOutput := cluster.GenerateAndExecuteCommand("Synchronizing directories with rsync", scope, generator)
+	output := &RsyncOutput{
+		RemoteOutput: remoteOutput,
+		Stats:        make([]RsyncStats, len(remoteOutput.Commands)),
+	}
+	for i, command := range remoteOutput.Commands {
+		if command.Error != nil {
+			continue
+		}
+		output.Stats[i] = ParseRsyncStats(command.Stdout)
+		output.TotalStats.add(output.Stats[i])
+	}
+	return output
+}
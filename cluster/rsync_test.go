@@ -0,0 +1,125 @@
+package cluster_test
+
+import (
+	"os/user"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/rsync tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"}
+	remoteSegOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "remotehost1", DataDir: "/data/gpseg0", Role: "p"}
+	remoteSegTwo := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 20001, Hostname: "remotehost2", DataDir: "/data/gpseg1", Role: "p"}
+	statsOutput := `
+Number of files: 1,234 (reg: 1,200, dir: 34)
+Number of created files: 2
+Number of regular files transferred: 12
+Total file size: 1,048,576 bytes
+Total transferred file size: 4,096 bytes
+`
+	var (
+		testCluster  *cluster.Cluster
+		testExecutor *testhelper.TestExecutor
+	)
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		testExecutor = &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, remoteSegOne, remoteSegTwo})
+		testCluster.Executor = testExecutor
+	})
+	Describe("ParseRsyncStats", func() {
+		It("parses statistics from newer versions of rsync", func() {
+			Expect(cluster.ParseRsyncStats(statsOutput)).To(Equal(cluster.RsyncStats{
+				NumFiles:             1234,
+				NumFilesTransferred:  12,
+				TotalFileSize:        1048576,
+				TotalTransferredSize: 4096,
+			}))
+		})
+		It("parses statistics from older versions of rsync", func() {
+			output := "Number of files: 10\nNumber of files transferred: 3\nTotal file size: 100 bytes\nTotal transferred file size: 30 bytes\n"
+			Expect(cluster.ParseRsyncStats(output)).To(Equal(cluster.RsyncStats{
+				NumFiles:             10,
+				NumFilesTransferred:  3,
+				TotalFileSize:        100,
+				TotalTransferredSize: 30,
+			}))
+		})
+		It("returns empty statistics if there are none in the output", func() {
+			Expect(cluster.ParseRsyncStats("some other output")).To(Equal(cluster.RsyncStats{}))
+		})
+	})
+	Describe("SyncDirectory", func() {
+		It("generates an rsync command per segment with the given options", func() {
+			opts := cluster.RsyncOptions{Delete: true, BwLimit: 1000, Excludes: []string{"pg_log", "*.pid"}, ExtraArgs: []string{"--checksum"}}
+			testCluster.SyncDirectory(cluster.ON_SEGMENTS,
+				func(content int) string { return "coordinatorhost:/backup/" + testCluster.GetDirForContent(content) + "/" },
+				func(content int) string { return testCluster.GetDirForContent(content) },
+				opts)
+
+			commands := testExecutor.ClusterCommands[0]
+			Expect(commands).To(HaveLen(2))
+			Expect(commands[0].CommandString).To(Equal(`ssh -o StrictHostKeyChecking=no testUser@remotehost1 if ! command -v rsync > /dev/null 2>&1; then echo "rsync is not installed on $(hostname)" >&2; exit 127; fi; ` +
+				`rsync -a --stats --delete --bwlimit=1000 --exclude='pg_log' --exclude='*.pid' --checksum 'coordinatorhost:/backup//data/gpseg0/' '/data/gpseg0'`))
+		})
+		It("generates an rsync command per host", func() {
+			testCluster.SyncDirectory(cluster.ON_HOSTS|cluster.ON_LOCAL,
+				func(host string) string { return "/etc/gpconfig/" },
+				func(host string) string { return host + ":/etc/gpconfig/" },
+				cluster.RsyncOptions{})
+
+			commands := testExecutor.ClusterCommands[0]
+			Expect(commands).To(HaveLen(2))
+			Expect(commands[1].CommandString).To(HaveSuffix(`rsync -a --stats '/etc/gpconfig/' 'remotehost2:/etc/gpconfig/'`))
+			Expect(commands[1].CommandString).To(HavePrefix("bash -c"))
+		})
+		It("quotes paths containing single quotes", func() {
+			testCluster.SyncDirectory(cluster.ON_HOSTS,
+				func(host string) string { return "/tmp/it's" },
+				func(host string) string { return "/tmp/dest" },
+				cluster.RsyncOptions{})
+
+			Expect(testExecutor.ClusterCommands[0][0].CommandString).To(HaveSuffix(`'/tmp/it'\''s' '/tmp/dest'`))
+		})
+		It("aggregates statistics from successful commands", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{
+				NumErrors: 1,
+				Commands: []cluster.ShellCommand{
+					{Content: 0, Stdout: statsOutput},
+					{Content: 1, Stdout: statsOutput},
+					{Content: 2, Stdout: statsOutput, Stderr: "rsync error", Error: errors.New("exit status 23")},
+				},
+			}
+
+			output := testCluster.SyncDirectory(cluster.ON_SEGMENTS,
+				func(content int) string { return "/src" },
+				func(content int) string { return "/dest" },
+				cluster.RsyncOptions{})
+
+			Expect(output.NumErrors).To(Equal(1))
+			Expect(output.Stats).To(HaveLen(3))
+			Expect(output.Stats[0].NumFilesTransferred).To(Equal(int64(12)))
+			Expect(output.Stats[2]).To(Equal(cluster.RsyncStats{}))
+			Expect(output.TotalStats).To(Equal(cluster.RsyncStats{
+				NumFiles:             2468,
+				NumFilesTransferred:  24,
+				TotalFileSize:        2097152,
+				TotalTransferredSize: 8192,
+			}))
+		})
+		It("panics if the source and destination functions do not match", func() {
+			defer testhelper.ShouldPanicWithMessage("Source and destination functions passed to SyncDirectory must have the same function header.")
+			testCluster.SyncDirectory(cluster.ON_SEGMENTS,
+				func(content int) string { return "/src" },
+				func(host string) string { return "/dest" },
+				cluster.RsyncOptions{})
+		})
+	})
+})
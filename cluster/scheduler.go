@@ -0,0 +1,130 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to limiting how many
+ * cluster commands run at once, overall and on each host.
+ */
+
+import (
+	"fmt"
+	"sync"
+)
+
+/*
+ * A commandScheduler decides when each command of a batch may start, given the
+ * MaxInFlight and MaxInFlightPerHost ExecutionOptions.  Commands are started in
+ * round-robin order across hosts, so that the first commands to run are spread
+ * over as many distinct hosts as possible rather than all landing on the host
+ * that happens to come first in the command list.
+ *
+ * A nil commandScheduler lets every command start immediately.
+ */
+type commandScheduler struct {
+	maxInFlight        int
+	maxInFlightPerHost int
+	maxInFlightByHost  map[string]int
+	mutex              sync.Mutex
+	cond               *sync.Cond
+	// The scheduling key of each command, which is its host if known
+	keys []string
+	// Scheduling keys in the order they first appear in the command list
+	keyOrder     []string
+	pending      map[string][]int
+	inFlight     int
+	keyInFlight  map[string]int
+	nextKeyIndex int
+	granted      map[int]bool
+}
+
+func newCommandScheduler(options ExecutionOptions, hosts []string) *commandScheduler {
+	if options.MaxInFlight <= 0 && options.MaxInFlightPerHost <= 0 && len(options.MaxInFlightByHost) == 0 {
+		return nil
+	}
+	scheduler := &commandScheduler{
+		maxInFlight:        options.MaxInFlight,
+		maxInFlightPerHost: options.MaxInFlightPerHost,
+		maxInFlightByHost:  options.MaxInFlightByHost,
+		keys:               make([]string, len(hosts)),
+		keyOrder:           make([]string, 0),
+		pending:            make(map[string][]int),
+		keyInFlight:        make(map[string]int),
+		granted:            make(map[int]bool),
+	}
+	scheduler.cond = sync.NewCond(&scheduler.mutex)
+	for index, host := range hosts {
+		key := host
+		if key == "" {
+			// Commands whose host is unknown are not limited per host
+			key = fmt.Sprintf("#%d", index)
+		}
+		scheduler.keys[index] = key
+		if _, ok := scheduler.pending[key]; !ok {
+			scheduler.keyOrder = append(scheduler.keyOrder, key)
+		}
+		scheduler.pending[key] = append(scheduler.pending[key], index)
+	}
+	scheduler.schedule()
+	return scheduler
+}
+
+// Must be called with scheduler.mutex held
+func (scheduler *commandScheduler) schedule() {
+	numKeys := len(scheduler.keyOrder)
+	for scheduler.maxInFlight <= 0 || scheduler.inFlight < scheduler.maxInFlight {
+		scheduled := false
+		for i := 0; i < numKeys; i++ {
+			keyIndex := (scheduler.nextKeyIndex + i) % numKeys
+			key := scheduler.keyOrder[keyIndex]
+			if len(scheduler.pending[key]) == 0 {
+				continue
+			}
+			if limit := scheduler.hostLimit(key); limit > 0 && scheduler.keyInFlight[key] >= limit {
+				continue
+			}
+			index := scheduler.pending[key][0]
+			scheduler.pending[key] = scheduler.pending[key][1:]
+			scheduler.granted[index] = true
+			scheduler.inFlight++
+			scheduler.keyInFlight[key]++
+			scheduler.nextKeyIndex = (keyIndex + 1) % numKeys
+			scheduled = true
+			break
+		}
+		if !scheduled {
+			break
+		}
+	}
+	scheduler.cond.Broadcast()
+}
+
+func (scheduler *commandScheduler) hostLimit(key string) int {
+	if limit, ok := scheduler.maxInFlightByHost[key]; ok && limit > 0 {
+		return limit
+	}
+	return scheduler.maxInFlightPerHost
+}
+
+// Blocks until the command may start
+func (scheduler *commandScheduler) acquire(index int) {
+	if scheduler == nil {
+		return
+	}
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	for !scheduler.granted[index] {
+		scheduler.cond.Wait()
+	}
+}
+
+// Records that the command has finished, letting another command start in its place
+func (scheduler *commandScheduler) release(index int) {
+	if scheduler == nil {
+		return
+	}
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	delete(scheduler.granted, index)
+	scheduler.inFlight--
+	scheduler.keyInFlight[scheduler.keys[index]]--
+	scheduler.schedule()
+}
@@ -0,0 +1,119 @@
+package cluster_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/scheduler tests", func() {
+	var lockDir string
+
+	BeforeEach(func() {
+		var err error
+		lockDir, err = os.MkdirTemp("", "scheduler")
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		_ = os.RemoveAll(lockDir)
+	})
+
+	// Each command holds a lock on its host while it runs, and fails if another command already holds it
+	newLockingCommand := func(host string) cluster.ShellCommand {
+		lock := filepath.Join(lockDir, host)
+		script := fmt.Sprintf("mkdir %[1]s || exit 1; sleep 0.1; rmdir %[1]s", lock)
+		return cluster.NewShellCommand(cluster.ON_HOSTS, -2, host, []string{"bash", "-c", script})
+	}
+	newSleepCommand := func(host string) cluster.ShellCommand {
+		return cluster.NewShellCommand(cluster.ON_HOSTS, -2, host, []string{"bash", "-c", "sleep 0.1"})
+	}
+	startedHosts := func(executor *cluster.GPDBExecutor) *[]string {
+		started := make([]string, 0)
+		executor.Hooks = []cluster.CommandHooks{cluster.CommandHookFuncs{
+			Start: func(command cluster.ShellCommand) {
+				started = append(started, command.Host)
+			},
+		}}
+		return &started
+	}
+
+	Describe("ExecutionOptions.MaxInFlight", func() {
+		It("starts commands on distinct hosts first", func() {
+			executor := &cluster.GPDBExecutor{Options: cluster.ExecutionOptions{MaxInFlight: 2}}
+			started := startedHosts(executor)
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, []cluster.ShellCommand{
+				newSleepCommand("sdw1"),
+				newSleepCommand("sdw1"),
+				newSleepCommand("sdw2"),
+				newSleepCommand("sdw2"),
+			})
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(*started).To(HaveLen(4))
+			Expect((*started)[:2]).To(ConsistOf("sdw1", "sdw2"))
+		})
+	})
+	Describe("ExecutionOptions.MaxInFlightPerHost", func() {
+		It("never runs more commands at once on a host than the limit", func() {
+			executor := &cluster.GPDBExecutor{Options: cluster.ExecutionOptions{MaxInFlightPerHost: 1}}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, []cluster.ShellCommand{
+				newLockingCommand("sdw1"),
+				newLockingCommand("sdw1"),
+				newLockingCommand("sdw1"),
+				newLockingCommand("sdw2"),
+			})
+
+			Expect(output.NumErrors).To(Equal(0))
+			for _, command := range output.Commands {
+				Expect(command.Completed).To(BeTrue())
+			}
+		})
+		It("still aborts commands that have not started", func() {
+			executor := &cluster.GPDBExecutor{Options: cluster.ExecutionOptions{MaxInFlightPerHost: 1, FailFast: true}}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "sdw1", []string{"bash", "-c", "exit 1"}),
+				newLockingCommand("sdw1"),
+			})
+
+			Expect(output.NumErrors).To(Equal(2))
+			Expect(output.Commands[1].Error).To(MatchError("Command aborted: 1 commands failed"))
+		})
+	})
+	Describe("ExecutionOptions.MaxInFlightByHost", func() {
+		It("overrides MaxInFlightPerHost for the hosts it contains", func() {
+			executor := &cluster.GPDBExecutor{Options: cluster.ExecutionOptions{
+				MaxInFlightPerHost: 2,
+				MaxInFlightByHost:  map[string]int{"sdw1": 1},
+			}}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, []cluster.ShellCommand{
+				newLockingCommand("sdw1"),
+				newLockingCommand("sdw1"),
+				newLockingCommand("sdw1"),
+				newSleepCommand("sdw2"),
+				newSleepCommand("sdw2"),
+			})
+
+			Expect(output.NumErrors).To(Equal(0))
+		})
+		It("limits only the hosts it contains if MaxInFlightPerHost is not set", func() {
+			executor := &cluster.GPDBExecutor{Options: cluster.ExecutionOptions{MaxInFlightByHost: map[string]int{"sdw1": 1}}}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, []cluster.ShellCommand{
+				newLockingCommand("sdw1"),
+				newLockingCommand("sdw1"),
+				newSleepCommand("sdw2"),
+			})
+
+			Expect(output.NumErrors).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,351 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to keeping a persistent ssh
+ * session open to each host, so that commands sent to a host reuse an existing
+ * connection instead of each setting up a new one.
+ */
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+// The default interval between liveness checks of each session
+const DEFAULT_KEEPALIVE_INTERVAL = 30 * time.Second
+
+type HostSession struct {
+	Address     string
+	ControlPath string
+	Alive       bool
+	Error       error
+}
+
+/*
+ * A HostSessionManager uses OpenSSH connection multiplexing: for each host, a
+ * master connection is started in the background with a control socket in
+ * SocketDir, and any later ssh command to that host that is given the socket
+ * runs over the master connection, skipping key exchange and authentication.
+ * If a session has died, ssh falls back to opening a new connection, so
+ * commands never fail just because a session is unavailable.
+ *
+ * SocketDir should be a directory only accessible to the current user, and
+ * short enough that socket paths within it fit in the limit for Unix socket
+ * paths (around 100 characters).
+ */
+type HostSessionManager struct {
+	SocketDir         string
+	KeepaliveInterval time.Duration
+	user              string
+	sessions          map[string]*HostSession
+	mutex             sync.Mutex
+	stop              chan struct{}
+	stopped           chan struct{}
+}
+
+func NewHostSessionManager(socketDir string) *HostSessionManager {
+	currentUser, _ := operating.System.CurrentUser()
+	return &HostSessionManager{
+		SocketDir:         socketDir,
+		KeepaliveInterval: DEFAULT_KEEPALIVE_INTERVAL,
+		user:              currentUser.Username,
+		sessions:          make(map[string]*HostSession),
+	}
+}
+
+func (manager *HostSessionManager) destination(address string) string {
+	return fmt.Sprintf("%s@%s", manager.user, address)
+}
+
+func (manager *HostSessionManager) controlPath(address string) string {
+	return filepath.Join(manager.SocketDir, manager.destination(address))
+}
+
+func (manager *HostSessionManager) controlArgs(address string, args ...string) []string {
+	return append([]string{"-o", "ControlPath=" + manager.controlPath(address)}, args...)
+}
+
+// KeepaliveInterval is exported, so it is clamped to at least a second wherever it is used
+func (manager *HostSessionManager) keepaliveInterval() time.Duration {
+	if manager.KeepaliveInterval < time.Second {
+		return time.Second
+	}
+	return manager.KeepaliveInterval
+}
+
+func (manager *HostSessionManager) serverAliveInterval() int {
+	return int((manager.keepaliveInterval() + time.Second - 1) / time.Second)
+}
+
+/*
+ * ssh -f exits once the master connection is authenticated, leaving it in the
+ * background, and ControlPersist keeps it open after that first ssh exits.
+ * ServerAliveInterval makes the master notice a dead connection on its own,
+ * in addition to the checks made by the manager.  It is given in whole
+ * seconds, so KeepaliveInterval is rounded up, and is at least 1 second, as
+ * 0 would disable ssh's keepalives entirely.
+ */
+func (manager *HostSessionManager) openSession(address string) error {
+	args := manager.controlArgs(address, "-o", "BatchMode=yes")
+	args = append(args, hostKeyOptions()...)
+	args = append(args,
+		"-o", fmt.Sprintf("ConnectTimeout=%d", SSHConnectTimeout),
+		"-o", "ControlMaster=yes",
+		"-o", "ControlPersist=yes",
+		"-o", fmt.Sprintf("ServerAliveInterval=%d", manager.serverAliveInterval()),
+		"-f", "-N", manager.destination(address))
+	output, err := operating.System.ExecCommand("ssh", args...).CombinedOutput()
+	if err != nil {
+		return errors.Errorf("Unable to open ssh session to %s: %s", address, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (manager *HostSessionManager) controlCommand(address string, command string) error {
+	args := manager.controlArgs(address, "-O", command, manager.destination(address))
+	output, err := operating.System.ExecCommand("ssh", args...).CombinedOutput()
+	if err != nil {
+		return errors.Errorf("ssh session to %s failed %s: %s", address, command, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Runs fn for each address in parallel and returns the addresses for which it failed
+func forEachAddress(addresses []string, fn func(address string) error) map[string]error {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	failures := make(map[string]error)
+	for _, address := range addresses {
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			if err := fn(address); err != nil {
+				mutex.Lock()
+				failures[address] = err
+				mutex.Unlock()
+			}
+		}(address)
+	}
+	wg.Wait()
+	return failures
+}
+
+func (manager *HostSessionManager) setSessionState(address string, err error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	session, ok := manager.sessions[address]
+	if !ok {
+		session = &HostSession{Address: address, ControlPath: manager.controlPath(address)}
+		manager.sessions[address] = session
+	}
+	session.Alive = err == nil
+	session.Error = err
+}
+
+/*
+ * Open starts a session to each address in parallel.  Addresses that could not
+ * be reached are still tracked, so the next keepalive check will try them
+ * again, and the returned error lists all of them.
+ */
+func (manager *HostSessionManager) Open(addresses ...string) error {
+	failures := forEachAddress(addresses, func(address string) error {
+		err := manager.openSession(address)
+		manager.setSessionState(address, err)
+		return err
+	})
+	if len(failures) > 0 {
+		failedAddresses := make([]string, 0, len(failures))
+		for address := range failures {
+			failedAddresses = append(failedAddresses, address)
+		}
+		sort.Strings(failedAddresses)
+		return errors.Errorf("Unable to open ssh sessions to %d hosts: %s", len(failedAddresses), strings.Join(failedAddresses, ", "))
+	}
+	return nil
+}
+
+/*
+ * Check asks the master connection of each session whether it is still
+ * running, and tries to reopen any session that is not.  It returns the
+ * addresses whose sessions are still unavailable afterward.
+ */
+func (manager *HostSessionManager) Check() []string {
+	addresses := make([]string, 0)
+	for _, session := range manager.Sessions() {
+		addresses = append(addresses, session.Address)
+	}
+	failures := forEachAddress(addresses, func(address string) error {
+		err := manager.controlCommand(address, "check")
+		if err != nil {
+			logDomain.Verbose("ssh session to %s is not alive, reopening it", address)
+			err = manager.openSession(address)
+		}
+		manager.setSessionState(address, err)
+		return err
+	})
+	unavailable := make([]string, 0, len(failures))
+	for address := range failures {
+		unavailable = append(unavailable, address)
+	}
+	sort.Strings(unavailable)
+	return unavailable
+}
+
+/*
+ * StartKeepalive runs Check every KeepaliveInterval, or every second if it is
+ * shorter than that, in the background until Close is called.  Calling it
+ * more than once has no effect.
+ */
+func (manager *HostSessionManager) StartKeepalive() {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if manager.stop != nil {
+		return
+	}
+	manager.stop = make(chan struct{})
+	manager.stopped = make(chan struct{})
+	go func(stop chan struct{}, stopped chan struct{}) {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-operating.System.After(manager.keepaliveInterval()):
+				manager.Check()
+			}
+		}
+	}(manager.stop, manager.stopped)
+}
+
+/*
+ * Close stops the keepalive checks and shuts down the master connection of
+ * every session.  Errors are only logged, as a session that cannot be shut
+ * down has most likely already died.
+ */
+func (manager *HostSessionManager) Close() {
+	manager.mutex.Lock()
+	stop, stopped := manager.stop, manager.stopped
+	manager.stop, manager.stopped = nil, nil
+	manager.mutex.Unlock()
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+	sessions := manager.Sessions()
+	manager.mutex.Lock()
+	manager.sessions = make(map[string]*HostSession)
+	manager.mutex.Unlock()
+	for _, session := range sessions {
+		if !session.Alive {
+			continue
+		}
+		if err := manager.controlCommand(session.Address, "exit"); err != nil {
+			logDomain.Verbose("%v", err)
+		}
+	}
+}
+
+// Sessions returns a copy of the state of each session, ordered by address
+func (manager *HostSessionManager) Sessions() []HostSession {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	sessions := make([]HostSession, 0, len(manager.sessions))
+	for _, session := range manager.sessions {
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Address < sessions[j].Address
+	})
+	return sessions
+}
+
+// The ssh options that take a value, which must be skipped to find the destination
+const sshOptionsWithValues = "BbcDEeFIiJLlmOopQRSWw"
+
+func sshDestinationIndex(args []string) int {
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return i
+		}
+		if len(arg) == 2 && strings.ContainsRune(sshOptionsWithValues, rune(arg[1])) {
+			i++
+		}
+	}
+	return -1
+}
+
+// Returns the address an ssh command connects to, or "" if args is not an ssh command
+func sshDestinationAddress(args []string) string {
+	if len(args) < 2 || filepath.Base(args[0]) != "ssh" {
+		return ""
+	}
+	destinationIndex := sshDestinationIndex(args)
+	if destinationIndex == -1 {
+		return ""
+	}
+	address := args[destinationIndex]
+	if index := strings.LastIndex(address, "@"); index != -1 {
+		address = address[index+1:]
+	}
+	return address
+}
+
+/*
+ * commandForSession returns a copy of cmd that uses the session to its
+ * destination if cmd is an ssh command to a host with a live session, and cmd
+ * itself otherwise.  Commands are matched to sessions by address, so this
+ * works for commands from ConstructSSHCommand or built by hand.
+ */
+func (manager *HostSessionManager) commandForSession(cmd *exec.Cmd) *exec.Cmd {
+	address := sshDestinationAddress(cmd.Args)
+	if address == "" {
+		return cmd
+	}
+	manager.mutex.Lock()
+	session, ok := manager.sessions[address]
+	alive := ok && session.Alive
+	manager.mutex.Unlock()
+	if !alive {
+		return cmd
+	}
+	sessionCmd := operating.System.ExecCommand(cmd.Args[0], append(manager.controlArgs(address), cmd.Args[1:]...)...)
+	sessionCmd.Env = cmd.Env
+	sessionCmd.Dir = cmd.Dir
+	sessionCmd.Stdin = cmd.Stdin
+	return sessionCmd
+}
+
+/*
+ * OpenHostSessions opens a session to every host in the cluster other than
+ * the coordinator's own host, at the address chosen by the cluster's
+ * AddressSelection, and starts keepalive checks.  If the cluster's Executor is
+ * a GPDBExecutor, later cluster commands use the sessions automatically; the
+ * caller should Close the returned manager when done.  An error is returned
+ * along with the manager if any host could not be reached.
+ */
+func (cluster *Cluster) OpenHostSessions(socketDir string) (*HostSessionManager, error) {
+	manager := NewHostSessionManager(socketDir)
+	localHost := cluster.GetHostForContent(-1)
+	addresses := make([]string, 0)
+	for _, host := range cluster.Hostnames {
+		if host != localHost {
+			addresses = append(addresses, cluster.GetAddressForHost(host))
+		}
+	}
+	logDomain.Verbose("Opening ssh sessions to %d hosts", len(addresses))
+	err := manager.Open(addresses...)
+	manager.StartKeepalive()
+	if executor, ok := cluster.Executor.(*GPDBExecutor); ok {
+		executor.Sessions = manager
+	}
+	return manager, err
+}
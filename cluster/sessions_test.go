@@ -0,0 +1,198 @@
+package cluster_test
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * The fake ssh logs its arguments, succeeds for master and control commands
+ * unless the destination is listed in the unreachable or dead files, and
+ * otherwise runs the remote command locally.
+ */
+const fakeSSHScript = `#!/bin/bash
+echo "$*" >> "$FAKE_SSH_DIR/log"
+if [[ " $* " == *" -O check "* ]]; then
+	grep -qx "${@: -1}" "$FAKE_SSH_DIR/dead" 2>/dev/null && { echo "Control socket connect: No such file or directory" >&2; exit 255; }
+	exit 0
+elif [[ " $* " == *" -O "* ]]; then
+	exit 0
+elif [[ " $* " == *" -N "* ]]; then
+	grep -qx "${@: -1}" "$FAKE_SSH_DIR/unreachable" 2>/dev/null && { echo "ssh: Could not resolve hostname" >&2; exit 255; }
+	exit 0
+fi
+bash -c "${@: -1}"
+`
+
+var _ = Describe("cluster/sessions tests", func() {
+	var (
+		fakeSSHDir string
+		socketDir  string
+	)
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "gpadmin"}, nil }
+		fakeSSHDir = GinkgoT().TempDir()
+		socketDir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(fakeSSHDir, "ssh"), []byte(fakeSSHScript), 0755)).To(Succeed())
+		GinkgoT().Setenv("PATH", fakeSSHDir+":"+os.Getenv("PATH"))
+		GinkgoT().Setenv("FAKE_SSH_DIR", fakeSSHDir)
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	sshLog := func() []string {
+		contents, _ := os.ReadFile(filepath.Join(fakeSSHDir, "log"))
+		return strings.Split(strings.TrimSpace(string(contents)), "\n")
+	}
+	markHosts := func(filename string, destinations ...string) {
+		Expect(os.WriteFile(filepath.Join(fakeSSHDir, filename), []byte(strings.Join(destinations, "\n")+"\n"), 0644)).To(Succeed())
+	}
+
+	Describe("HostSessionManager", func() {
+		It("opens a master connection to each host", func() {
+			manager := cluster.NewHostSessionManager(socketDir)
+			Expect(manager.Open("sdw1", "sdw2")).To(Succeed())
+			Expect(manager.Sessions()).To(Equal([]cluster.HostSession{
+				{Address: "sdw1", ControlPath: filepath.Join(socketDir, "gpadmin@sdw1"), Alive: true},
+				{Address: "sdw2", ControlPath: filepath.Join(socketDir, "gpadmin@sdw2"), Alive: true},
+			}))
+			Expect(sshLog()).To(ContainElement(
+				"-o ControlPath=" + filepath.Join(socketDir, "gpadmin@sdw1") + " -o BatchMode=yes -o StrictHostKeyChecking=no -o ConnectTimeout=5 -o ControlMaster=yes -o ControlPersist=yes -o ServerAliveInterval=30 -f -N gpadmin@sdw1"))
+		})
+		DescribeTable("passes the keepalive interval to ssh in whole seconds, rounded up",
+			func(interval time.Duration, expected string) {
+				manager := cluster.NewHostSessionManager(socketDir)
+				manager.KeepaliveInterval = interval
+				Expect(manager.Open("sdw1")).To(Succeed())
+				Expect(sshLog()).To(ContainElement(ContainSubstring(" -o ServerAliveInterval=" + expected + " -f -N gpadmin@sdw1")))
+			},
+			Entry("whole seconds", 10*time.Second, "10"),
+			Entry("a fraction of a second over", 1500*time.Millisecond, "2"),
+			Entry("less than a second", 200*time.Millisecond, "1"),
+			Entry("no interval", time.Duration(0), "1"),
+		)
+		It("returns an error listing the hosts that could not be reached", func() {
+			markHosts("unreachable", "gpadmin@sdw2", "gpadmin@sdw3")
+			manager := cluster.NewHostSessionManager(socketDir)
+			err := manager.Open("sdw1", "sdw2", "sdw3")
+			Expect(err).To(MatchError("Unable to open ssh sessions to 2 hosts: sdw2, sdw3"))
+			sessions := manager.Sessions()
+			Expect(sessions).To(HaveLen(3))
+			Expect(sessions[0].Alive).To(BeTrue())
+			Expect(sessions[1].Alive).To(BeFalse())
+			Expect(sessions[1].Error).To(MatchError("Unable to open ssh session to sdw2: ssh: Could not resolve hostname"))
+		})
+		It("reopens sessions that are no longer alive", func() {
+			manager := cluster.NewHostSessionManager(socketDir)
+			Expect(manager.Open("sdw1", "sdw2", "sdw3")).To(Succeed())
+			markHosts("dead", "gpadmin@sdw2", "gpadmin@sdw3")
+			markHosts("unreachable", "gpadmin@sdw3")
+			Expect(os.Remove(filepath.Join(fakeSSHDir, "log"))).To(Succeed())
+
+			Expect(manager.Check()).To(Equal([]string{"sdw3"}))
+
+			sessions := manager.Sessions()
+			Expect(sessions[0].Alive).To(BeTrue())
+			Expect(sessions[1].Alive).To(BeTrue())
+			Expect(sessions[2].Alive).To(BeFalse())
+			Expect(sshLog()).To(ContainElement(HaveSuffix("-f -N gpadmin@sdw2")))
+			Expect(sshLog()).ToNot(ContainElement(HaveSuffix("-f -N gpadmin@sdw1")))
+		})
+		It("checks sessions every keepalive interval until closed", func() {
+			clock := testhelper.MockClock(time.Now())
+			manager := cluster.NewHostSessionManager(socketDir)
+			manager.KeepaliveInterval = time.Minute
+			Expect(manager.Open("sdw1")).To(Succeed())
+			manager.StartKeepalive()
+			Eventually(clock.NumWaiters).Should(Equal(1))
+			Expect(sshLog()).ToNot(ContainElement(HaveSuffix("-O check gpadmin@sdw1")))
+
+			clock.Advance(time.Minute)
+			Eventually(sshLog).Should(ContainElement(HaveSuffix("-O check gpadmin@sdw1")))
+			Eventually(clock.NumWaiters).Should(Equal(1))
+
+			manager.Close()
+			Expect(clock.Waits).To(HaveEach(time.Minute))
+			Expect(sshLog()).To(ContainElement(HaveSuffix("-O exit gpadmin@sdw1")))
+			Expect(manager.Sessions()).To(BeEmpty())
+		})
+		It("waits at least a second between checks if the keepalive interval is shorter", func() {
+			clock := testhelper.MockClock(time.Now())
+			manager := cluster.NewHostSessionManager(socketDir)
+			manager.KeepaliveInterval = 0
+			Expect(manager.Open("sdw1")).To(Succeed())
+			manager.StartKeepalive()
+			Eventually(clock.NumWaiters).Should(Equal(1))
+
+			clock.Advance(time.Second)
+			Eventually(sshLog).Should(ContainElement(HaveSuffix("-O check gpadmin@sdw1")))
+			Eventually(clock.NumWaiters).Should(Equal(1))
+
+			manager.Close()
+			Expect(clock.Waits).To(HaveEach(time.Second))
+		})
+		It("only closes sessions that are alive", func() {
+			markHosts("unreachable", "gpadmin@sdw2")
+			manager := cluster.NewHostSessionManager(socketDir)
+			_ = manager.Open("sdw1", "sdw2")
+			manager.Close()
+			Expect(sshLog()).To(ContainElement(HaveSuffix("-O exit gpadmin@sdw1")))
+			Expect(sshLog()).ToNot(ContainElement(HaveSuffix("-O exit gpadmin@sdw2")))
+		})
+	})
+	Describe("ExecuteClusterCommand with Sessions", func() {
+		It("sends ssh commands to hosts with live sessions over those sessions", func() {
+			markHosts("unreachable", "gpadmin@sdw2")
+			manager := cluster.NewHostSessionManager(socketDir)
+			_ = manager.Open("sdw1", "sdw2")
+			executor := &cluster.GPDBExecutor{Sessions: manager}
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "sdw1", cluster.ConstructSSHCommand(false, "sdw1", "echo one")),
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "sdw2", cluster.ConstructSSHCommand(false, "sdw2", "echo two")),
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "cdw", cluster.ConstructSSHCommand(true, "cdw", "echo three")),
+			}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, commandList)
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.Commands[0].Stdout).To(Equal("one\n"))
+			Expect(output.Commands[1].Stdout).To(Equal("two\n"))
+			Expect(output.Commands[2].Stdout).To(Equal("three\n"))
+			Expect(sshLog()).To(ContainElement("-o ControlPath=" + filepath.Join(socketDir, "gpadmin@sdw1") + " -o StrictHostKeyChecking=no gpadmin@sdw1 echo one"))
+			Expect(sshLog()).To(ContainElement("-o StrictHostKeyChecking=no gpadmin@sdw2 echo two"))
+		})
+	})
+	Describe("OpenHostSessions", func() {
+		It("opens sessions to every host other than the coordinator's and uses them for cluster commands", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Hostname: "cdw", DataDir: "/data/gpseg-1"},
+				{DbID: 2, ContentID: 0, Role: "p", Hostname: "sdw1", DataDir: "/data/gpseg0"},
+				{DbID: 3, ContentID: 1, Role: "p", Hostname: "sdw2", DataDir: "/data/gpseg1"},
+			})
+			manager, err := testCluster.OpenHostSessions(socketDir)
+			Expect(err).ToNot(HaveOccurred())
+			defer manager.Close()
+			sessions := manager.Sessions()
+			Expect(sessions).To(HaveLen(2))
+			Expect(sessions[0].Address).To(Equal("sdw1"))
+			Expect(sessions[1].Address).To(Equal("sdw2"))
+			Expect(testCluster.Executor.(*cluster.GPDBExecutor).Sessions).To(Equal(manager))
+
+			output := testCluster.GenerateAndExecuteCommand("Running commands", cluster.ON_SEGMENTS, func(content int) string {
+				return "echo segment"
+			})
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(sshLog()).To(ContainElement(HavePrefix("-o ControlPath=" + filepath.Join(socketDir, "gpadmin@sdw2") + " -o StrictHostKeyChecking=no gpadmin@sdw2")))
+		})
+	})
+})
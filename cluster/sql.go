@@ -0,0 +1,141 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to running SQL directly
+ * on each segment in utility mode.
+ */
+
+import (
+	"sync"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+// The maximum number of segments to which ExecuteSQLOnSegments connects at once
+const SEGMENT_SQL_BATCH_SIZE = 64
+
+/*
+ * This function opens a utility-mode connection to the given segment.  The
+ * user and other connection parameters are read from the environment as in
+ * dbconn.NewDBConnFromEnvironment, with the segment's host and port.  It is
+ * a variable so that it can be replaced for testing.
+ */
+var ConnectToSegment = func(dbname string, segment SegConfig, numConns int) (*dbconn.DBConn, error) {
+	connection := dbconn.NewDBConnFromEnvironment(dbname)
+	connection.Host = segment.Hostname
+	connection.Port = segment.Port
+	err := connection.ConnectInUtilityMode(numConns)
+	if err != nil {
+		return nil, err
+	}
+	return connection, nil
+}
+
+/*
+ * A SQLResult stores the rows returned by a query on a single segment, with
+ * each row stored as a map of column name to value, or the error encountered
+ * when connecting to the segment or running the query.
+ */
+type SQLResult struct {
+	Content int
+	DbID    int
+	Host    string
+	Port    int
+	Rows    []map[string]interface{}
+	Error   error
+}
+
+/*
+ * A SQLOutput is the SQL equivalent of a RemoteOutput; Results are ordered by
+ * content id, and FailedResults points to the results with errors.
+ */
+type SQLOutput struct {
+	NumErrors     int
+	Results       []SQLResult
+	FailedResults []*SQLResult
+}
+
+func runQueryOnSegment(dbname string, segment SegConfig, query string, numConns int) ([]map[string]interface{}, error) {
+	connection, err := ConnectToSegment(dbname, segment, numConns)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to connect to segment %d on host %s", segment.ContentID, segment.Hostname)
+	}
+	defer connection.Close()
+
+	rows, err := connection.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		row := make(map[string]interface{})
+		err = rows.MapScan(row)
+		if err != nil {
+			return nil, err
+		}
+		for column, value := range row {
+			if bytes, ok := value.([]byte); ok {
+				row[column] = string(bytes)
+			}
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
+
+/*
+ * ExecuteSQLOnSegments runs the query on every primary segment in parallel,
+ * connecting to each one in utility mode with numConnsPerSeg connections, and
+ * returns the results for each segment.  It connects to the database in
+ * PGDATABASE, or to template1 if that is not set, as every database has the
+ * same catalog tables that maintenance queries typically read.
+ */
+func (cluster *Cluster) ExecuteSQLOnSegments(query string, numConnsPerSeg int) *SQLOutput {
+	dbname := operating.System.Getenv("PGDATABASE")
+	if dbname == "" {
+		dbname = "template1"
+	}
+
+	primaries := make([]SegConfig, 0)
+	for _, content := range cluster.ContentIDs {
+		if content == -1 {
+			continue
+		}
+		primaries = append(primaries, *getSegmentByRole(cluster.ByContent[content]))
+	}
+	logDomain.Verbose("Executing query on %d segments", len(primaries))
+
+	output := &SQLOutput{Results: make([]SQLResult, len(primaries))}
+	batch := make(chan struct{}, SEGMENT_SQL_BATCH_SIZE)
+	var wg sync.WaitGroup
+	for i, segment := range primaries {
+		wg.Add(1)
+		go func(index int, segment SegConfig) {
+			defer wg.Done()
+			batch <- struct{}{}
+			defer func() { <-batch }()
+			rows, err := runQueryOnSegment(dbname, segment, query, numConnsPerSeg)
+			output.Results[index] = SQLResult{
+				Content: segment.ContentID,
+				DbID:    segment.DbID,
+				Host:    segment.Hostname,
+				Port:    segment.Port,
+				Rows:    rows,
+				Error:   err,
+			}
+		}(i, segment)
+	}
+	wg.Wait()
+
+	output.FailedResults = make([]*SQLResult, 0)
+	for i := range output.Results {
+		if output.Results[i].Error != nil {
+			output.NumErrors++
+			output.FailedResults = append(output.FailedResults, &output.Results[i])
+		}
+	}
+	return output
+}
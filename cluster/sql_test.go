@@ -0,0 +1,99 @@
+package cluster_test
+
+import (
+	"sync"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/sql tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1"}
+	primaryZero := cluster.SegConfig{DbID: 2, ContentID: 0, Role: "p", Port: 6000, Hostname: "sdw1", DataDir: "/data/primary/gpseg0"}
+	mirrorZero := cluster.SegConfig{DbID: 4, ContentID: 0, Role: "m", Port: 7000, Hostname: "sdw2", DataDir: "/data/mirror/gpseg0"}
+	primaryOne := cluster.SegConfig{DbID: 3, ContentID: 1, Role: "p", Port: 6000, Hostname: "sdw2", DataDir: "/data/primary/gpseg1"}
+	var (
+		testCluster      *cluster.Cluster
+		originalConnect  func(string, cluster.SegConfig, int) (*dbconn.DBConn, error)
+		segmentMocks     map[int]sqlmock.Sqlmock
+		connectedDBNames []string
+		connectErrs      map[int]error
+		mutex            sync.Mutex
+	)
+	BeforeEach(func() {
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, primaryZero, mirrorZero, primaryOne})
+		originalConnect = cluster.ConnectToSegment
+		segmentMocks = make(map[int]sqlmock.Sqlmock)
+		connectErrs = make(map[int]error)
+		connectedDBNames = []string{}
+		cluster.ConnectToSegment = func(dbname string, segment cluster.SegConfig, numConns int) (*dbconn.DBConn, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			connectedDBNames = append(connectedDBNames, dbname)
+			if err := connectErrs[segment.ContentID]; err != nil {
+				return nil, err
+			}
+			segmentConn, segmentMock := testhelper.CreateAndConnectMockDB(numConns)
+			segmentMocks[segment.ContentID] = segmentMock
+			rows := sqlmock.NewRows([]string{"content", "setting"}).AddRow(segment.ContentID, []byte("on"))
+			segmentMock.ExpectQuery("SHOW fsync").WillReturnRows(rows)
+			return segmentConn, nil
+		}
+	})
+	AfterEach(func() {
+		cluster.ConnectToSegment = originalConnect
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("ExecuteSQLOnSegments", func() {
+		It("runs the query on every primary and returns the rows for each segment", func() {
+			output := testCluster.ExecuteSQLOnSegments("SHOW fsync", 1)
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.FailedResults).To(BeEmpty())
+			Expect(output.Results).To(HaveLen(2))
+			Expect(output.Results[0].Content).To(Equal(0))
+			Expect(output.Results[0].DbID).To(Equal(2))
+			Expect(output.Results[0].Host).To(Equal("sdw1"))
+			Expect(output.Results[0].Rows).To(Equal([]map[string]interface{}{{"content": int64(0), "setting": "on"}}))
+			Expect(output.Results[1].Content).To(Equal(1))
+			Expect(output.Results[1].Port).To(Equal(6000))
+			Expect(output.Results[1].Rows).To(Equal([]map[string]interface{}{{"content": int64(1), "setting": "on"}}))
+			for _, segmentMock := range segmentMocks {
+				Expect(segmentMock.ExpectationsWereMet()).To(Succeed())
+			}
+		})
+		It("connects to template1 unless PGDATABASE is set", func() {
+			testCluster.ExecuteSQLOnSegments("SHOW fsync", 1)
+			Expect(connectedDBNames).To(Equal([]string{"template1", "template1"}))
+
+			connectedDBNames = []string{}
+			operating.System.Getenv = func(key string) string {
+				if key == "PGDATABASE" {
+					return "testdb"
+				}
+				return ""
+			}
+			testCluster.ExecuteSQLOnSegments("SHOW fsync", 1)
+			Expect(connectedDBNames).To(Equal([]string{"testdb", "testdb"}))
+		})
+		It("reports segments that could not be connected to", func() {
+			connectErrs[1] = errors.New("connection refused")
+
+			output := testCluster.ExecuteSQLOnSegments("SHOW fsync", 1)
+
+			Expect(output.NumErrors).To(Equal(1))
+			Expect(output.FailedResults).To(HaveLen(1))
+			Expect(output.FailedResults[0].Content).To(Equal(1))
+			Expect(output.FailedResults[0].Error).To(MatchError("Unable to connect to segment 1 on host sdw2: connection refused"))
+			Expect(output.Results[0].Error).ToNot(HaveOccurred())
+		})
+	})
+})
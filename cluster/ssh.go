@@ -0,0 +1,136 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to checking that the
+ * current user can ssh to every host in the cluster without a password.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+type SSHAccessStatus string
+
+const (
+	SSH_ACCESS_OK         SSHAccessStatus = "ok"
+	SSH_AUTH_FAILURE      SSHAccessStatus = "authentication failure"
+	SSH_HOST_KEY_CONFLICT SSHAccessStatus = "host key conflict"
+	SSH_HOST_KEY_UNKNOWN  SSHAccessStatus = "unknown host key"
+	SSH_NETWORK_FAILURE   SSHAccessStatus = "network failure"
+	SSH_UNKNOWN_FAILURE   SSHAccessStatus = "unknown failure"
+)
+
+// The number of seconds to wait for each host to accept the connection
+const SSHConnectTimeout = 5
+
+/*
+ * Each status is identified by matching OpenSSH's (fairly stable) error
+ * messages in the command's stderr.  Host key problems are checked first, as
+ * a changed host key may also cause ssh to refuse password authentication.
+ * A changed key is checked before an unknown one, as ssh also ends its
+ * warning about a changed key with "Host key verification failed".
+ */
+var sshFailurePatterns = []struct {
+	status   SSHAccessStatus
+	patterns []string
+}{
+	{SSH_HOST_KEY_CONFLICT, []string{"REMOTE HOST IDENTIFICATION HAS CHANGED"}},
+	{SSH_HOST_KEY_UNKNOWN, []string{"Host key verification failed", "host key is known for"}},
+	{SSH_AUTH_FAILURE, []string{"Permission denied", "Too many authentication failures"}},
+	{SSH_NETWORK_FAILURE, []string{"Could not resolve hostname", "Connection refused", "Connection timed out", "No route to host", "Network is unreachable", "Connection closed by", "Connection reset by"}},
+}
+
+type SSHAccessResult struct {
+	Host   string
+	Status SSHAccessStatus
+	Stderr string
+	Error  error
+	Hint   string
+}
+
+type SSHAccessReport struct {
+	User    string
+	Results []SSHAccessResult
+}
+
+func (report *SSHAccessReport) Failures() []SSHAccessResult {
+	failures := make([]SSHAccessResult, 0)
+	for _, result := range report.Results {
+		if result.Status != SSH_ACCESS_OK {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+func (report *SSHAccessReport) AllHostsReachable() bool {
+	return len(report.Failures()) == 0
+}
+
+func classifySSHFailure(stderr string) SSHAccessStatus {
+	for _, failure := range sshFailurePatterns {
+		for _, pattern := range failure.patterns {
+			if strings.Contains(stderr, pattern) {
+				return failure.status
+			}
+		}
+	}
+	return SSH_UNKNOWN_FAILURE
+}
+
+func sshRemediationHint(status SSHAccessStatus, user string, host string) string {
+	switch status {
+	case SSH_AUTH_FAILURE:
+		return fmt.Sprintf("Ensure that the public key of user %s is in ~/.ssh/authorized_keys on %s and that the remote user is correct, e.g. by running ssh-copy-id %s@%s", user, host, user, host)
+	case SSH_HOST_KEY_CONFLICT:
+		return fmt.Sprintf("The host key for %s does not match the one in ~/.ssh/known_hosts; if the host was reinstalled, remove the old key with ssh-keygen -R %s", host, host)
+	case SSH_HOST_KEY_UNKNOWN:
+		return fmt.Sprintf("There is no host key for %s in ~/.ssh/known_hosts and ssh did not add one (StrictHostKeyChecking=%s); after verifying the host's fingerprint, add its key with ssh-keyscan %s >> ~/.ssh/known_hosts", host, GetHostKeyPolicy(), host)
+	case SSH_NETWORK_FAILURE:
+		return fmt.Sprintf("Ensure that %s resolves to the correct address, is running, and accepts connections on the ssh port", host)
+	case SSH_UNKNOWN_FAILURE:
+		return fmt.Sprintf("Run ssh -v %s@%s true manually to diagnose the problem", user, host)
+	}
+	return ""
+}
+
+/*
+ * VerifySSHAccess attempts a non-interactive "ssh true" to every host in the
+ * given scope, which is always treated as per-host and remote, including the
+ * coordinator host if specified.  BatchMode ensures that hosts requiring a
+ * password fail immediately instead of prompting for one.  Each host is
+ * reached at the address chosen by the cluster's AddressSelection, with the
+ * same host key policy as the commands built by ConstructSSHCommand, so that
+ * the check succeeds exactly when those commands can connect.
+ */
+func (cluster *Cluster) VerifySSHAccess(scope Scope) *SSHAccessReport {
+	scope = (scope | ON_HOSTS) &^ ON_LOCAL
+	currentUser, _ := operating.System.CurrentUser()
+	user := currentUser.Username
+	commandList := cluster.GenerateCommandList(scope, func(host string) []string {
+		args := append([]string{"ssh"}, hostKeyOptions()...)
+		return append(args, "-o", "BatchMode=yes", "-o", fmt.Sprintf("ConnectTimeout=%d", SSHConnectTimeout), fmt.Sprintf("%s@%s", user, cluster.GetAddressForHost(host)), "true")
+	})
+	logDomain.Verbose("Verifying passwordless ssh access to %d hosts", len(commandList))
+	remoteOutput := cluster.ExecuteClusterCommand(scope, commandList)
+
+	report := &SSHAccessReport{User: user}
+	for _, command := range remoteOutput.Commands {
+		result := SSHAccessResult{
+			Host:   command.Host,
+			Status: SSH_ACCESS_OK,
+			Stderr: command.Stderr,
+			Error:  command.Error,
+		}
+		if command.Error != nil {
+			result.Status = classifySSHFailure(command.Stderr)
+			result.Hint = sshRemediationHint(result.Status, user, command.Host)
+			logDomain.Verbose("Unable to ssh to host %s (%s): %s", command.Host, result.Status, strings.TrimSpace(command.Stderr))
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
@@ -0,0 +1,108 @@
+package cluster_test
+
+import (
+	"os/user"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/ssh tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"}
+	remoteSegOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "remotehost1", DataDir: "/data/gpseg0", Role: "p"}
+	remoteSegTwo := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 20001, Hostname: "remotehost2", DataDir: "/data/gpseg1", Role: "p"}
+	var (
+		testCluster  *cluster.Cluster
+		testExecutor *testhelper.TestExecutor
+	)
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		testExecutor = &testhelper.TestExecutor{}
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, remoteSegOne, remoteSegTwo})
+		testCluster.Executor = testExecutor
+	})
+	Describe("VerifySSHAccess", func() {
+		It("runs a non-interactive ssh command against every host in scope", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{}
+
+			testCluster.VerifySSHAccess(cluster.ON_SEGMENTS | cluster.INCLUDE_COORDINATOR | cluster.ON_LOCAL)
+
+			Expect(testExecutor.NumClusterExecutions).To(Equal(1))
+			commands := testExecutor.ClusterCommands[0]
+			Expect(commands).To(HaveLen(3))
+			Expect(commands[0].Scope).To(Equal(cluster.ON_HOSTS | cluster.INCLUDE_COORDINATOR))
+			Expect(commands[0].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no -o BatchMode=yes -o ConnectTimeout=5 testUser@localhost true"))
+			Expect(commands[1].Host).To(Equal("remotehost1"))
+			Expect(commands[2].Host).To(Equal("remotehost2"))
+		})
+		It("classifies failures and suggests remediation for each host", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{
+				Commands: []cluster.ShellCommand{
+					{Host: "remotehost1", Stderr: "testUser@remotehost1: Permission denied (publickey,password).", Error: errors.New("exit status 255")},
+					{Host: "remotehost2", Stderr: "ssh: Could not resolve hostname remotehost2: Name or service not known", Error: errors.New("exit status 255")},
+					{Host: "remotehost3", Stderr: "@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @", Error: errors.New("exit status 255")},
+					{Host: "remotehost4", Stderr: "something unexpected", Error: errors.New("exit status 1")},
+					{Host: "remotehost5"},
+				},
+			}
+
+			report := testCluster.VerifySSHAccess(cluster.ON_HOSTS)
+
+			Expect(report.User).To(Equal("testUser"))
+			Expect(report.AllHostsReachable()).To(BeFalse())
+			Expect(report.Results).To(HaveLen(5))
+			Expect(report.Results[0].Status).To(Equal(cluster.SSH_AUTH_FAILURE))
+			Expect(report.Results[0].Hint).To(ContainSubstring("ssh-copy-id testUser@remotehost1"))
+			Expect(report.Results[1].Status).To(Equal(cluster.SSH_NETWORK_FAILURE))
+			Expect(report.Results[2].Status).To(Equal(cluster.SSH_HOST_KEY_CONFLICT))
+			Expect(report.Results[2].Hint).To(ContainSubstring("ssh-keygen -R remotehost3"))
+			Expect(report.Results[3].Status).To(Equal(cluster.SSH_UNKNOWN_FAILURE))
+			Expect(report.Results[3].Error).To(MatchError("exit status 1"))
+			Expect(report.Results[4].Status).To(Equal(cluster.SSH_ACCESS_OK))
+			Expect(report.Results[4].Hint).To(BeEmpty())
+			Expect(report.Failures()).To(HaveLen(4))
+		})
+		It("uses the host key policy of other ssh commands", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{}
+			cluster.SetHostKeyPolicy(cluster.HOST_KEY_ACCEPT_NEW)
+			defer cluster.SetHostKeyPolicy(cluster.HOST_KEY_ACCEPT_ANY)
+
+			testCluster.VerifySSHAccess(cluster.ON_HOSTS)
+
+			Expect(testExecutor.ClusterCommands[0][0].CommandString).To(Equal("ssh -o StrictHostKeyChecking=accept-new -o BatchMode=yes -o ConnectTimeout=5 testUser@remotehost1 true"))
+		})
+		It("distinguishes a changed host key from an unknown one", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{
+				Commands: []cluster.ShellCommand{
+					{Host: "remotehost1", Stderr: "@@@@@@@@@@@\n@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @\n@@@@@@@@@@@\nHost key for remotehost1 has changed and you have requested strict checking.\nHost key verification failed.", Error: errors.New("exit status 255")},
+					{Host: "remotehost2", Stderr: "Host key verification failed.", Error: errors.New("exit status 255")},
+					{Host: "remotehost3", Stderr: "No ED25519 host key is known for remotehost3 and you have requested strict checking.\nHost key verification failed.", Error: errors.New("exit status 255")},
+				},
+			}
+
+			report := testCluster.VerifySSHAccess(cluster.ON_HOSTS)
+
+			Expect(report.Results[0].Status).To(Equal(cluster.SSH_HOST_KEY_CONFLICT))
+			Expect(report.Results[0].Hint).To(ContainSubstring("does not match the one in ~/.ssh/known_hosts"))
+			Expect(report.Results[1].Status).To(Equal(cluster.SSH_HOST_KEY_UNKNOWN))
+			Expect(report.Results[1].Hint).To(Equal("There is no host key for remotehost2 in ~/.ssh/known_hosts and ssh did not add one (StrictHostKeyChecking=no); after verifying the host's fingerprint, add its key with ssh-keyscan remotehost2 >> ~/.ssh/known_hosts"))
+			Expect(report.Results[2].Status).To(Equal(cluster.SSH_HOST_KEY_UNKNOWN))
+		})
+		It("reports success when every host is reachable", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{
+				Commands: []cluster.ShellCommand{{Host: "remotehost1"}, {Host: "remotehost2"}},
+			}
+
+			report := testCluster.VerifySSHAccess(cluster.ON_HOSTS)
+
+			Expect(report.AllHostsReachable()).To(BeTrue())
+			Expect(report.Failures()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,241 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to starting and stopping
+ * segments with pg_ctl.
+ */
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// The default time pg_ctl waits for a segment to start or stop
+const DEFAULT_PG_CTL_TIMEOUT = 10 * time.Minute
+
+type StopMode string
+
+const (
+	STOP_MODE_SMART     StopMode = "smart"
+	STOP_MODE_FAST      StopMode = "fast"
+	STOP_MODE_IMMEDIATE StopMode = "immediate"
+)
+
+type SegmentState string
+
+const (
+	SEGMENT_RUNNING       SegmentState = "running"
+	SEGMENT_STOPPED       SegmentState = "stopped"
+	SEGMENT_STATE_UNKNOWN SegmentState = "unknown"
+)
+
+/*
+ * GPHome is the installation whose pg_ctl is used on each host; if it is not
+ * set, pg_ctl is run from the PATH.  ServerOptions are passed to postgres in
+ * addition to the segment's port, e.g. "-c gp_role=utility".
+ */
+type StartOptions struct {
+	GPHome        string
+	Timeout       time.Duration // Defaults to DEFAULT_PG_CTL_TIMEOUT
+	ServerOptions string
+}
+
+type SegmentStatus struct {
+	DbID    int
+	Content int
+	Host    string
+	Port    int
+	DataDir string
+	State   SegmentState
+}
+
+/*
+ * A SegmentControlOutput wraps the RemoteOutput of the pg_ctl commands with the
+ * state of each segment after its command ran, in the same order as
+ * RemoteOutput.Commands.
+ */
+type SegmentControlOutput struct {
+	*RemoteOutput
+	Segments []SegmentStatus
+}
+
+var pgCtlStatusPattern = regexp.MustCompile(`(?m)^pg_ctl status: (\d+)$`)
+
+/*
+ * Each script checks the segment's state with pg_ctl status before and after
+ * acting on it, so that e.g. starting a running segment succeeds without doing
+ * anything, and the final state is reported even if pg_ctl fails.  pg_ctl
+ * status exits with 0 if the server is running and 3 if it is not.  GPDB 7
+ * writes server logs to "log" and earlier versions to "pg_log", so the startup
+ * log is written to whichever exists.
+ */
+func segmentControlScript(pgCtl string, segment SegConfig, action string, actionArgs string, skipState SegmentState) string {
+	skipCondition := "false"
+	switch skipState {
+	case SEGMENT_RUNNING:
+		skipCondition = `$pgctl status -D "$datadir" >/dev/null 2>&1`
+	case SEGMENT_STOPPED:
+		skipCondition = `! $pgctl status -D "$datadir" >/dev/null 2>&1`
+	}
+	return fmt.Sprintf(`pgctl=%s
+datadir=%s
+logdir=log
+if [ -d "$datadir/pg_log" ]; then logdir=pg_log; fi
+if %s; then
+	echo "Segment is already %s"
+	rc=0
+else
+	$pgctl %s -D "$datadir" %s
+	rc=$?
+fi
+$pgctl status -D "$datadir" >/dev/null 2>&1
+echo "pg_ctl status: $?"
+exit $rc`, shellQuote(pgCtl), shellQuote(segment.DataDir), skipCondition, skipState, action, actionArgs)
+}
+
+func pgCtlPath(gphome string) string {
+	if gphome == "" {
+		return "pg_ctl"
+	}
+	return filepath.Join(gphome, "bin", "pg_ctl")
+}
+
+func (opts StartOptions) timeout() time.Duration {
+	if opts.Timeout <= 0 {
+		return DEFAULT_PG_CTL_TIMEOUT
+	}
+	return opts.Timeout
+}
+
+func startArgs(segment SegConfig, opts StartOptions) string {
+	serverOptions := fmt.Sprintf("-p %d", segment.Port)
+	if opts.ServerOptions != "" {
+		serverOptions += " " + opts.ServerOptions
+	}
+	return fmt.Sprintf(`-l "$datadir/$logdir/startup.log" -w -t %d -o %s`, int(opts.timeout().Seconds()), shellQuote(serverOptions))
+}
+
+func stopArgs(mode StopMode, timeout time.Duration) string {
+	return fmt.Sprintf("-m %s -w -t %d", mode, int(timeout.Seconds()))
+}
+
+/*
+ * The coordinator and standby (content -1) are started after all other
+ * segments and stopped before them, as gpstart and gpstop do, so the segments
+ * are acted on in two phases.  Within each phase, segments are acted on in
+ * parallel, in order of dbid.
+ */
+func (cluster *Cluster) controlSegments(scope Scope, coordinatorFirst bool, generateScript func(segment SegConfig) string) *SegmentControlOutput {
+	// The generator is called once per command, in the same order as the commands
+	allSegments := make([]*SegConfig, 0)
+	allCommands := cluster.GenerateCommandListPerDbid(scope, func(dbid int) []string {
+		segment := cluster.ByDbid[dbid]
+		allSegments = append(allSegments, segment)
+		return cluster.BuildSegmentCommand(scope, *segment, "bash -c "+shellQuote(generateScript(*segment)))
+	})
+	var coordinatorPhase, segmentPhase []int
+	for i, command := range allCommands {
+		if command.Content == -1 {
+			coordinatorPhase = append(coordinatorPhase, i)
+		} else {
+			segmentPhase = append(segmentPhase, i)
+		}
+	}
+	phases := [][]int{segmentPhase, coordinatorPhase}
+	if coordinatorFirst {
+		phases = [][]int{coordinatorPhase, segmentPhase}
+	}
+
+	commands := make([]ShellCommand, 0, len(allCommands))
+	segments := make([]SegmentStatus, 0, len(allCommands))
+	numErrors := 0
+	for _, phase := range phases {
+		if len(phase) == 0 {
+			continue
+		}
+		phaseCommands := make([]ShellCommand, len(phase))
+		for i, index := range phase {
+			phaseCommands[i] = allCommands[index]
+		}
+		phaseOutput := cluster.ExecuteClusterCommand(scope, phaseCommands)
+		numErrors += phaseOutput.NumErrors
+		for i, command := range phaseOutput.Commands {
+			commands = append(commands, command)
+			segments = append(segments, segmentStatus(*allSegments[phase[i]], command))
+		}
+	}
+	return &SegmentControlOutput{RemoteOutput: NewRemoteOutput(scope, numErrors, commands), Segments: segments}
+}
+
+func segmentStatus(segment SegConfig, command ShellCommand) SegmentStatus {
+	status := SegmentStatus{
+		DbID:    segment.DbID,
+		Content: segment.ContentID,
+		Host:    segment.Hostname,
+		Port:    segment.Port,
+		DataDir: segment.DataDir,
+		State:   SEGMENT_STATE_UNKNOWN,
+	}
+	if match := pgCtlStatusPattern.FindStringSubmatch(command.Stdout); match != nil {
+		switch code, _ := strconv.Atoi(match[1]); code {
+		case 0:
+			status.State = SEGMENT_RUNNING
+		case 3:
+			status.State = SEGMENT_STOPPED
+		}
+	}
+	return status
+}
+
+/*
+ * StartSegments starts each segment in scope that is not already running and
+ * waits for it to accept connections.  Mirrors and the standby are only
+ * included if scope includes mirrors, and the coordinator is only included if
+ * scope includes the coordinator.
+ */
+func (cluster *Cluster) StartSegments(scope Scope, opts StartOptions) *SegmentControlOutput {
+	logDomain.Verbose("Starting segments")
+	return cluster.controlSegments(scope, false, func(segment SegConfig) string {
+		return segmentControlScript(pgCtlPath(opts.GPHome), segment, "start", startArgs(segment, opts), SEGMENT_RUNNING)
+	})
+}
+
+/*
+ * StopSegments stops each segment in scope that is running with the given
+ * shutdown mode, using pg_ctl from the PATH, and waits for it to exit.
+ */
+func (cluster *Cluster) StopSegments(scope Scope, mode StopMode) *SegmentControlOutput {
+	return cluster.StopSegmentsWithOptions(scope, mode, StartOptions{})
+}
+
+// StopSegmentsWithOptions is like StopSegments, but uses the GPHome and Timeout in opts
+func (cluster *Cluster) StopSegmentsWithOptions(scope Scope, mode StopMode, opts StartOptions) *SegmentControlOutput {
+	logDomain.Verbose("Stopping segments in %s mode", mode)
+	return cluster.controlSegments(scope, true, func(segment SegConfig) string {
+		return segmentControlScript(pgCtlPath(opts.GPHome), segment, "stop", stopArgs(mode, opts.timeout()), SEGMENT_STOPPED)
+	})
+}
+
+/*
+ * RestartSegments stops each segment in scope that is running with the given
+ * shutdown mode and then starts them all, as gpstop -r does, rather than
+ * restarting each segment with pg_ctl restart.  Stopping the coordinator first
+ * keeps FTS from marking the primaries down and promoting their mirrors while
+ * they restart, and starting it last means it finds every segment running.
+ *
+ * If any segment fails to stop, no segment is started, and the output of the
+ * stop is returned so that the caller can see which segments failed; otherwise
+ * the output of the start is returned.
+ */
+func (cluster *Cluster) RestartSegments(scope Scope, mode StopMode, opts StartOptions) *SegmentControlOutput {
+	logDomain.Verbose("Restarting segments in %s mode", mode)
+	stopOutput := cluster.StopSegmentsWithOptions(scope, mode, opts)
+	if stopOutput.NumErrors > 0 {
+		logDomain.Verbose("Not starting segments, as %d segment(s) failed to stop", stopOutput.NumErrors)
+		return stopOutput
+	}
+	return cluster.StartSegments(scope, opts)
+}
@@ -0,0 +1,191 @@
+package cluster_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * The fake pg_ctl logs each action other than status, treats a segment as
+ * running if its data directory contains postmaster.pid, fails to start any
+ * segment whose data directory contains a file named "broken", and fails to
+ * stop any segment whose data directory contains a file named "stuck".
+ */
+const fakePgCtlScript = `#!/bin/bash
+action=$1
+shift
+args="$*"
+while [ $# -gt 0 ]; do
+	if [ "$1" == "-D" ]; then datadir=$2; shift; fi
+	shift
+done
+if [ "$action" != "status" ]; then echo "$action $args" >> "$FAKE_PG_CTL_LOG"; fi
+case $action in
+status)
+	[ -f "$datadir/postmaster.pid" ] && exit 0
+	exit 3;;
+start)
+	if [ -f "$datadir/broken" ]; then echo "pg_ctl: could not start server" >&2; exit 1; fi
+	touch "$datadir/postmaster.pid";;
+stop)
+	if [ -f "$datadir/stuck" ]; then echo "pg_ctl: server does not shut down" >&2; exit 1; fi
+	rm "$datadir/postmaster.pid";;
+esac
+`
+
+var _ = Describe("cluster/startstop tests", func() {
+	var (
+		gphome      string
+		baseDir     string
+		pgCtlLog    string
+		testCluster *cluster.Cluster
+		opts        cluster.StartOptions
+	)
+	dataDir := func(name string) string {
+		return filepath.Join(baseDir, name)
+	}
+	markRunning := func(names ...string) {
+		for _, name := range names {
+			Expect(os.WriteFile(filepath.Join(dataDir(name), "postmaster.pid"), []byte("1234"), 0644)).To(Succeed())
+		}
+	}
+	pgCtlCalls := func() []string {
+		contents, _ := os.ReadFile(pgCtlLog)
+		return strings.Split(strings.TrimSpace(string(contents)), "\n")
+	}
+	BeforeEach(func() {
+		gphome = GinkgoT().TempDir()
+		baseDir = GinkgoT().TempDir()
+		pgCtlLog = filepath.Join(baseDir, "pg_ctl.log")
+		Expect(os.MkdirAll(filepath.Join(gphome, "bin"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(gphome, "bin", "pg_ctl"), []byte(fakePgCtlScript), 0755)).To(Succeed())
+		GinkgoT().Setenv("FAKE_PG_CTL_LOG", pgCtlLog)
+		for _, name := range []string{"gpseg-1", "gpseg0", "mirror0"} {
+			Expect(os.MkdirAll(filepath.Join(dataDir(name), "log"), 0755)).To(Succeed())
+		}
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "localhost", DataDir: dataDir("gpseg-1")},
+			{DbID: 2, ContentID: 0, Role: "p", Port: 6000, Hostname: "localhost", DataDir: dataDir("gpseg0")},
+			{DbID: 3, ContentID: 0, Role: "m", Port: 7000, Hostname: "localhost", DataDir: dataDir("mirror0")},
+		})
+		opts = cluster.StartOptions{GPHome: gphome, Timeout: time.Minute}
+	})
+
+	Describe("StartSegments", func() {
+		It("starts segments before the coordinator and reports their state", func() {
+			output := testCluster.StartSegments(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, opts)
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.Segments).To(Equal([]cluster.SegmentStatus{
+				{DbID: 2, Content: 0, Host: "localhost", Port: 6000, DataDir: dataDir("gpseg0"), State: cluster.SEGMENT_RUNNING},
+				{DbID: 1, Content: -1, Host: "localhost", Port: 5432, DataDir: dataDir("gpseg-1"), State: cluster.SEGMENT_RUNNING},
+			}))
+			Expect(pgCtlCalls()).To(Equal([]string{
+				"start -D " + dataDir("gpseg0") + " -l " + dataDir("gpseg0") + "/log/startup.log -w -t 60 -o -p 6000",
+				"start -D " + dataDir("gpseg-1") + " -l " + dataDir("gpseg-1") + "/log/startup.log -w -t 60 -o -p 5432",
+			}))
+		})
+		It("skips running segments and includes mirrors if the scope includes mirrors", func() {
+			markRunning("gpseg0")
+			opts.ServerOptions = "-c gp_role=utility"
+
+			output := testCluster.StartSegments(cluster.ON_SEGMENTS|cluster.INCLUDE_MIRRORS, opts)
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.Segments).To(HaveLen(2))
+			Expect(output.Segments[0].State).To(Equal(cluster.SEGMENT_RUNNING))
+			Expect(output.Segments[1].DbID).To(Equal(3))
+			Expect(output.Segments[1].State).To(Equal(cluster.SEGMENT_RUNNING))
+			Expect(output.Commands[0].Stdout).To(ContainSubstring("Segment is already running"))
+			Expect(pgCtlCalls()).To(Equal([]string{
+				"start -D " + dataDir("mirror0") + " -l " + dataDir("mirror0") + "/log/startup.log -w -t 60 -o -p 7000 -c gp_role=utility",
+			}))
+		})
+		It("reports segments that failed to start", func() {
+			Expect(os.WriteFile(filepath.Join(dataDir("gpseg0"), "broken"), nil, 0644)).To(Succeed())
+
+			output := testCluster.StartSegments(cluster.ON_SEGMENTS, opts)
+
+			Expect(output.NumErrors).To(Equal(1))
+			Expect(output.FailedCommands[0].Stderr).To(ContainSubstring("could not start server"))
+			Expect(output.Segments[0].State).To(Equal(cluster.SEGMENT_STOPPED))
+		})
+	})
+	Describe("StopSegmentsWithOptions", func() {
+		It("stops the coordinator before the segments and skips stopped segments", func() {
+			markRunning("gpseg-1", "gpseg0")
+
+			output := testCluster.StopSegmentsWithOptions(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR|cluster.INCLUDE_MIRRORS, cluster.STOP_MODE_FAST, opts)
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.Segments).To(HaveLen(3))
+			Expect(output.Segments[0].DbID).To(Equal(1))
+			for _, segment := range output.Segments {
+				Expect(segment.State).To(Equal(cluster.SEGMENT_STOPPED))
+			}
+			Expect(pgCtlCalls()).To(Equal([]string{
+				"stop -D " + dataDir("gpseg-1") + " -m fast -w -t 60",
+				"stop -D " + dataDir("gpseg0") + " -m fast -w -t 60",
+			}))
+		})
+	})
+	Describe("StopSegments", func() {
+		It("uses pg_ctl from the PATH and the default timeout", func() {
+			GinkgoT().Setenv("PATH", filepath.Join(gphome, "bin")+":"+os.Getenv("PATH"))
+			markRunning("gpseg0")
+
+			output := testCluster.StopSegments(cluster.ON_SEGMENTS, cluster.STOP_MODE_SMART)
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(pgCtlCalls()).To(Equal([]string{"stop -D " + dataDir("gpseg0") + " -m smart -w -t 600"}))
+		})
+	})
+	Describe("RestartSegments", func() {
+		It("stops the coordinator first and starts it last", func() {
+			markRunning("gpseg-1", "gpseg0")
+
+			output := testCluster.RestartSegments(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, cluster.STOP_MODE_IMMEDIATE, opts)
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.Segments).To(HaveLen(2))
+			Expect(output.Segments[0].State).To(Equal(cluster.SEGMENT_RUNNING))
+			Expect(output.Segments[1].State).To(Equal(cluster.SEGMENT_RUNNING))
+			Expect(pgCtlCalls()).To(Equal([]string{
+				"stop -D " + dataDir("gpseg-1") + " -m immediate -w -t 60",
+				"stop -D " + dataDir("gpseg0") + " -m immediate -w -t 60",
+				"start -D " + dataDir("gpseg0") + " -l " + dataDir("gpseg0") + "/log/startup.log -w -t 60 -o -p 6000",
+				"start -D " + dataDir("gpseg-1") + " -l " + dataDir("gpseg-1") + "/log/startup.log -w -t 60 -o -p 5432",
+			}))
+		})
+		It("starts segments that were not running", func() {
+			output := testCluster.RestartSegments(cluster.ON_SEGMENTS, cluster.STOP_MODE_FAST, opts)
+
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.Segments[0].State).To(Equal(cluster.SEGMENT_RUNNING))
+			Expect(pgCtlCalls()).To(Equal([]string{
+				"start -D " + dataDir("gpseg0") + " -l " + dataDir("gpseg0") + "/log/startup.log -w -t 60 -o -p 6000",
+			}))
+		})
+		It("does not start any segment if a segment fails to stop", func() {
+			markRunning("gpseg-1", "gpseg0")
+			Expect(os.WriteFile(filepath.Join(dataDir("gpseg0"), "stuck"), nil, 0644)).To(Succeed())
+
+			output := testCluster.RestartSegments(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, cluster.STOP_MODE_FAST, opts)
+
+			Expect(output.NumErrors).To(Equal(1))
+			Expect(output.FailedCommands[0].Stderr).To(ContainSubstring("server does not shut down"))
+			Expect(output.Segments[0].State).To(Equal(cluster.SEGMENT_STOPPED))
+			Expect(output.Segments[1].State).To(Equal(cluster.SEGMENT_RUNNING))
+			for _, call := range pgCtlCalls() {
+				Expect(call).To(HavePrefix("stop "))
+			}
+		})
+	})
+})
@@ -0,0 +1,113 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to summarizing how long
+ * the commands of a cluster command took, to find hosts that slow it down.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The number of slowest hosts in the summary logged by CheckClusterError
+const numSlowestHostsToLog = 3
+
+/*
+ * A HostDuration is the time taken by the slowest command on a host, which is
+ * how long a cluster command waited for that host.
+ */
+type HostDuration struct {
+	Host     string
+	Duration time.Duration
+}
+
+/*
+ * RemoteOutputStats holds timing statistics for the completed commands in a
+ * RemoteOutput.  SlowestCommands and SlowestHosts are sorted from slowest to
+ * fastest.  Commands whose host is unknown, i.e. per-segment commands that
+ * were not sent over ssh, count toward the statistics but not SlowestHosts.
+ */
+type RemoteOutputStats struct {
+	NumCommands     int
+	TotalDuration   time.Duration
+	MinDuration     time.Duration
+	MaxDuration     time.Duration
+	AvgDuration     time.Duration
+	SlowestCommands []*ShellCommand
+	SlowestHosts    []HostDuration
+}
+
+func (stats RemoteOutputStats) String() string {
+	if stats.NumCommands == 0 {
+		return "0 commands completed"
+	}
+	message := fmt.Sprintf("%d commands completed (min %s, avg %s, max %s)",
+		stats.NumCommands, stats.MinDuration, stats.AvgDuration, stats.MaxDuration)
+	if len(stats.SlowestHosts) > 0 {
+		hosts := make([]string, len(stats.SlowestHosts))
+		for i, host := range stats.SlowestHosts {
+			hosts[i] = fmt.Sprintf("%s (%s)", host.Host, host.Duration)
+		}
+		message += "; slowest hosts: " + strings.Join(hosts, ", ")
+	}
+	return message
+}
+
+/*
+ * Stats returns timing statistics for the completed commands, including the
+ * numSlowest slowest commands and hosts.  A negative numSlowest is treated
+ * as 0.
+ */
+func (remoteOutput *RemoteOutput) Stats(numSlowest int) RemoteOutputStats {
+	if numSlowest < 0 {
+		numSlowest = 0
+	}
+	stats := RemoteOutputStats{SlowestCommands: []*ShellCommand{}, SlowestHosts: []HostDuration{}}
+	hostDurations := make(map[string]time.Duration)
+	for i := range remoteOutput.Commands {
+		command := &remoteOutput.Commands[i]
+		if !command.Completed {
+			continue
+		}
+		if stats.NumCommands == 0 || command.Duration < stats.MinDuration {
+			stats.MinDuration = command.Duration
+		}
+		if command.Duration > stats.MaxDuration {
+			stats.MaxDuration = command.Duration
+		}
+		stats.NumCommands++
+		stats.TotalDuration += command.Duration
+		stats.SlowestCommands = append(stats.SlowestCommands, command)
+		if host := commandHost(*command); host != "" {
+			if duration, ok := hostDurations[host]; !ok || command.Duration > duration {
+				hostDurations[host] = command.Duration
+			}
+		}
+	}
+	if stats.NumCommands > 0 {
+		stats.AvgDuration = stats.TotalDuration / time.Duration(stats.NumCommands)
+	}
+
+	sort.SliceStable(stats.SlowestCommands, func(i int, j int) bool {
+		return stats.SlowestCommands[i].Duration > stats.SlowestCommands[j].Duration
+	})
+	for host, duration := range hostDurations {
+		stats.SlowestHosts = append(stats.SlowestHosts, HostDuration{Host: host, Duration: duration})
+	}
+	sort.Slice(stats.SlowestHosts, func(i int, j int) bool {
+		if stats.SlowestHosts[i].Duration != stats.SlowestHosts[j].Duration {
+			return stats.SlowestHosts[i].Duration > stats.SlowestHosts[j].Duration
+		}
+		return stats.SlowestHosts[i].Host < stats.SlowestHosts[j].Host
+	})
+	if len(stats.SlowestCommands) > numSlowest {
+		stats.SlowestCommands = stats.SlowestCommands[:numSlowest]
+	}
+	if len(stats.SlowestHosts) > numSlowest {
+		stats.SlowestHosts = stats.SlowestHosts[:numSlowest]
+	}
+	return stats
+}
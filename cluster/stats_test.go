@@ -0,0 +1,73 @@
+package cluster_test
+
+import (
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/stats tests", func() {
+	newCompletedCommand := func(host string, duration time.Duration) cluster.ShellCommand {
+		return cluster.ShellCommand{Scope: cluster.ON_HOSTS, Content: -2, Host: host, Completed: true, Duration: duration}
+	}
+	var remoteOutput *cluster.RemoteOutput
+
+	BeforeEach(func() {
+		remoteOutput = cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{
+			newCompletedCommand("sdw1", 2*time.Second),
+			newCompletedCommand("sdw2", 9*time.Second),
+			newCompletedCommand("sdw1", 4*time.Second),
+			newCompletedCommand("sdw3", time.Second),
+			{Scope: cluster.ON_HOSTS, Content: -2, Host: "sdw4"},
+		})
+	})
+
+	Describe("RemoteOutput.Stats", func() {
+		It("returns statistics for the completed commands", func() {
+			stats := remoteOutput.Stats(2)
+
+			Expect(stats.NumCommands).To(Equal(4))
+			Expect(stats.TotalDuration).To(Equal(16 * time.Second))
+			Expect(stats.MinDuration).To(Equal(time.Second))
+			Expect(stats.MaxDuration).To(Equal(9 * time.Second))
+			Expect(stats.AvgDuration).To(Equal(4 * time.Second))
+		})
+		It("returns the slowest commands and hosts", func() {
+			stats := remoteOutput.Stats(2)
+
+			Expect(stats.SlowestCommands).To(HaveLen(2))
+			Expect(stats.SlowestCommands[0]).To(Equal(&remoteOutput.Commands[1]))
+			Expect(stats.SlowestCommands[1]).To(Equal(&remoteOutput.Commands[2]))
+			Expect(stats.SlowestHosts).To(Equal([]cluster.HostDuration{{Host: "sdw2", Duration: 9 * time.Second}, {Host: "sdw1", Duration: 4 * time.Second}}))
+			Expect(stats.String()).To(Equal("4 commands completed (min 1s, avg 4s, max 9s); slowest hosts: sdw2 (9s), sdw1 (4s)"))
+		})
+		It("treats a negative number of slowest commands as 0", func() {
+			stats := remoteOutput.Stats(-1)
+
+			Expect(stats.NumCommands).To(Equal(4))
+			Expect(stats.SlowestCommands).To(BeEmpty())
+			Expect(stats.SlowestHosts).To(BeEmpty())
+		})
+		It("returns empty statistics if no commands completed", func() {
+			stats := cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{}).Stats(3)
+
+			Expect(stats.NumCommands).To(Equal(0))
+			Expect(stats.SlowestHosts).To(BeEmpty())
+			Expect(stats.String()).To(Equal("0 commands completed"))
+		})
+	})
+	Describe("Cluster.LogPerformanceSummary", func() {
+		It("makes CheckClusterError log the statistics", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{})
+			testCluster.LogPerformanceSummary = true
+
+			testCluster.CheckClusterError(remoteOutput, "Got an error", func(host string) string { return "Error received" })
+
+			testhelper.ExpectRegexp(logfile, "Performance summary: 4 commands completed (min 1s, avg 4s, max 9s); slowest hosts: sdw2 (9s), sdw1 (4s), sdw3 (1s)")
+		})
+	})
+})
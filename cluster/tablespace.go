@@ -0,0 +1,116 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to the locations of
+ * user-defined tablespaces on each segment.
+ */
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+)
+
+/*
+ * A Tablespace stores the location of a single tablespace on a single segment,
+ * so there is one Tablespace per tablespace per dbid.
+ */
+type Tablespace struct {
+	Oid      uint32
+	Name     string
+	DbID     int
+	Location string
+}
+
+/*
+ * This function retrieves the location of every user-defined tablespace on
+ * each segment.  The built-in pg_default and pg_global tablespaces are stored
+ * in each segment's data directory, so they are not included.
+ *
+ * Before GPDB 6, tablespaces are stored in filespaces, so the location for
+ * every dbid, including mirrors, is read from pg_filespace_entry.  From GPDB 6
+ * onward, each location is read from the running segment itself, so only the
+ * locations for the coordinator and primaries are returned.
+ */
+func GetTablespaces(connection *dbconn.DBConn) ([]Tablespace, error) {
+	query := ""
+	if connection.Version.Before("6") {
+		query = `
+SELECT
+	t.oid,
+	t.spcname AS name,
+	e.fsedbid AS dbid,
+	e.fselocation AS location
+FROM pg_tablespace t
+JOIN pg_filespace f ON t.spcfsoid = f.oid
+JOIN pg_filespace_entry e ON f.oid = e.fsefsoid
+WHERE t.spcname NOT IN ('pg_default', 'pg_global')
+ORDER BY t.oid, e.fsedbid;`
+	} else {
+		query = `
+SELECT
+	t.oid,
+	t.spcname AS name,
+	s.dbid,
+	l.tblspc_loc AS location
+FROM pg_tablespace t, gp_tablespace_location(t.oid) l
+JOIN gp_segment_configuration s ON l.gp_segment_id = s.content AND s.role = 'p'
+WHERE t.spcname NOT IN ('pg_default', 'pg_global')
+ORDER BY t.oid, s.dbid;`
+	}
+
+	results := make([]Tablespace, 0)
+	err := connection.Select(&results, query)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func MustGetTablespaces(connection *dbconn.DBConn) []Tablespace {
+	tablespaces, err := GetTablespaces(connection)
+	gplog.FatalOnError(err)
+	return tablespaces
+}
+
+/*
+ * SetTablespaces replaces any tablespace information stored in the Cluster,
+ * so that the GetTablespaceDirs functions below can be used.
+ */
+func (cluster *Cluster) SetTablespaces(tablespaces []Tablespace) {
+	cluster.TablespacesByDbid = make(map[int][]Tablespace)
+	for _, tablespace := range tablespaces {
+		cluster.TablespacesByDbid[tablespace.DbID] = append(cluster.TablespacesByDbid[tablespace.DbID], tablespace)
+	}
+}
+
+func (cluster *Cluster) getTablespaceDirsForDbid(dbid int) []string {
+	dirs := make([]string, len(cluster.TablespacesByDbid[dbid]))
+	for i, tablespace := range cluster.TablespacesByDbid[dbid] {
+		dirs[i] = tablespace.Location
+	}
+	return dirs
+}
+
+func (cluster *Cluster) GetTablespaceDirsForContent(contentID int, role ...string) []string {
+	segConfig := getSegmentByRole(cluster.ByContent[contentID], role...)
+	if segConfig == nil {
+		return []string{}
+	}
+	return cluster.getTablespaceDirsForDbid(segConfig.DbID)
+}
+
+func (cluster *Cluster) GetTablespaceDirsForHost(hostname string) []string {
+	dirs := make([]string, 0)
+	for _, seg := range cluster.ByHost[hostname] {
+		dirs = append(dirs, cluster.getTablespaceDirsForDbid(seg.DbID)...)
+	}
+	return dirs
+}
+
+/*
+ * GetAllDirsForHost returns the data directories and the tablespace
+ * directories for every segment on the given host.
+ */
+func (cluster *Cluster) GetAllDirsForHost(hostname string) []string {
+	return append(cluster.GetDirsForHost(hostname), cluster.GetTablespaceDirsForHost(hostname)...)
+}
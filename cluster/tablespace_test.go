@@ -0,0 +1,80 @@
+package cluster_test
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/tablespace tests", func() {
+	header := []string{"oid", "name", "dbid", "location"}
+	Describe("GetTablespaces", func() {
+		It("queries filespace entries for GPDB 5", func() {
+			fakeResult := sqlmock.NewRows(header).AddRow(16384, "ts1", 1, "/data/ts1/gpseg-1").AddRow(16384, "ts1", 2, "/data/ts1/gpseg0")
+			mock.ExpectQuery("SELECT (.*) FROM pg_tablespace t JOIN pg_filespace (.*)").WillReturnRows(fakeResult)
+
+			results, err := cluster.GetTablespaces(connection)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]cluster.Tablespace{
+				{Oid: 16384, Name: "ts1", DbID: 1, Location: "/data/ts1/gpseg-1"},
+				{Oid: 16384, Name: "ts1", DbID: 2, Location: "/data/ts1/gpseg0"},
+			}))
+		})
+		It("queries gp_tablespace_location for GPDB 6 and later", func() {
+			testhelper.SetDBVersion(connection, "6.0.0")
+			fakeResult := sqlmock.NewRows(header).AddRow(16384, "ts1", 2, "/data/ts1/2")
+			mock.ExpectQuery("SELECT (.*) FROM pg_tablespace t, gp_tablespace_location(.*)").WillReturnRows(fakeResult)
+
+			results, err := cluster.GetTablespaces(connection)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]cluster.Tablespace{{Oid: 16384, Name: "ts1", DbID: 2, Location: "/data/ts1/2"}}))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnError(errors.New("some error"))
+
+			results, err := cluster.GetTablespaces(connection)
+
+			Expect(err).To(MatchError("some error"))
+			Expect(results).To(BeNil())
+		})
+	})
+	Describe("Tablespace directory functions", func() {
+		coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"}
+		primary := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "localhost", DataDir: "/data/primary/gpseg0", Role: "p"}
+		mirror := cluster.SegConfig{DbID: 3, ContentID: 0, Port: 21000, Hostname: "otherhost", DataDir: "/data/mirror/gpseg0", Role: "m"}
+		var testCluster *cluster.Cluster
+		BeforeEach(func() {
+			testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, primary, mirror})
+			testCluster.SetTablespaces([]cluster.Tablespace{
+				{Oid: 16384, Name: "ts1", DbID: 1, Location: "/data/ts1/1"},
+				{Oid: 16384, Name: "ts1", DbID: 2, Location: "/data/ts1/2"},
+				{Oid: 16385, Name: "ts2", DbID: 2, Location: "/data/ts2/2"},
+				{Oid: 16384, Name: "ts1", DbID: 3, Location: "/data/ts1/3"},
+			})
+		})
+		It("returns the tablespace directories for a content", func() {
+			Expect(testCluster.GetTablespaceDirsForContent(0)).To(Equal([]string{"/data/ts1/2", "/data/ts2/2"}))
+			Expect(testCluster.GetTablespaceDirsForContent(0, "m")).To(Equal([]string{"/data/ts1/3"}))
+			Expect(testCluster.GetTablespaceDirsForContent(5)).To(BeEmpty())
+		})
+		It("returns the tablespace directories for a host", func() {
+			Expect(testCluster.GetTablespaceDirsForHost("localhost")).To(Equal([]string{"/data/ts1/1", "/data/ts1/2", "/data/ts2/2"}))
+			Expect(testCluster.GetTablespaceDirsForHost("otherhost")).To(Equal([]string{"/data/ts1/3"}))
+			Expect(testCluster.GetTablespaceDirsForHost("nohost")).To(BeEmpty())
+		})
+		It("returns data and tablespace directories for a host", func() {
+			Expect(testCluster.GetAllDirsForHost("otherhost")).To(Equal([]string{"/data/mirror/gpseg0", "/data/ts1/3"}))
+		})
+		It("returns no tablespace directories if SetTablespaces was not called", func() {
+			newCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, primary})
+			Expect(newCluster.GetTablespaceDirsForHost("localhost")).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,87 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to generating cluster
+ * commands from text/template templates instead of generator functions.
+ */
+
+import (
+	"bytes"
+	"io"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// The fields available to templates for per-segment commands
+type segmentTemplateData struct {
+	ContentID int
+	DbID      int
+	DataDir   string
+	Port      int
+	Hostname  string
+}
+
+// The fields available to templates for per-host commands
+type hostTemplateData struct {
+	Hostname string
+}
+
+/*
+ * GenerateCommandListFromTemplate generates commands as GenerateSSHCommandList
+ * does, but from a text/template template such as
+ *   "pg_ctl -D {{.DataDir}} status"
+ * rather than from a generator function.  Per-segment templates may reference
+ * {{.ContentID}}, {{.DbID}}, {{.DataDir}}, {{.Port}}, and {{.Hostname}}, and
+ * generate one command per segment in scope as GenerateCommandListPerDbid
+ * does, so mirrors are included with INCLUDE_MIRRORS.  Per-host templates may
+ * only reference {{.Hostname}}.
+ *
+ * Values are substituted as they are, so a template should quote any value
+ * that may contain shell metacharacters.  An error is returned if the template
+ * cannot be parsed or references a field that is not available for the scope,
+ * in which case no commands are generated.
+ */
+func (cluster *Cluster) GenerateCommandListFromTemplate(scope Scope, tmpl string) ([]ShellCommand, error) {
+	parsed, err := template.New("command").Parse(tmpl)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid command template")
+	}
+	var renderErr error
+	render := func(data interface{}) string {
+		var command bytes.Buffer
+		if err := parsed.Execute(&command, data); err != nil && renderErr == nil {
+			renderErr = errors.Wrap(err, "Unable to generate command from template")
+		}
+		return command.String()
+	}
+
+	var commands []ShellCommand
+	if scopeIsHosts(scope) {
+		if err := parsed.Execute(io.Discard, hostTemplateData{}); err != nil {
+			return nil, errors.Wrap(err, "Invalid command template for per-host commands")
+		}
+		commands = cluster.GenerateCommandList(scope, func(host string) []string {
+			return cluster.BuildHostCommand(scope, host, render(hostTemplateData{Hostname: host}))
+		})
+	} else {
+		if err := parsed.Execute(io.Discard, segmentTemplateData{}); err != nil {
+			return nil, errors.Wrap(err, "Invalid command template for per-segment commands")
+		}
+		commands = cluster.GenerateCommandListPerDbid(scope, func(dbid int) []string {
+			segment := *cluster.ByDbid[dbid]
+			command := render(segmentTemplateData{
+				ContentID: segment.ContentID,
+				DbID:      segment.DbID,
+				DataDir:   segment.DataDir,
+				Port:      segment.Port,
+				Hostname:  segment.Hostname,
+			})
+			return cluster.BuildSegmentCommand(scope, segment, command)
+		})
+	}
+	if renderErr != nil {
+		return nil, renderErr
+	}
+	return commands, nil
+}
@@ -0,0 +1,69 @@
+package cluster_test
+
+import (
+	"os/user"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/template tests", func() {
+	var testCluster *cluster.Cluster
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"},
+			{DbID: 2, ContentID: 0, Port: 20000, Hostname: "localhost", DataDir: "/data/gpseg0", Role: "p"},
+			{DbID: 3, ContentID: 1, Port: 20001, Hostname: "remotehost1", DataDir: "/data/gpseg1", Role: "p"},
+			{DbID: 4, ContentID: 0, Port: 21000, Hostname: "remotehost1", DataDir: "/data/mirror/gpseg0", Role: "m"},
+		})
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("GenerateCommandListFromTemplate", func() {
+		It("substitutes the fields of each segment in scope", func() {
+			commands, err := testCluster.GenerateCommandListFromTemplate(cluster.ON_SEGMENTS|cluster.INCLUDE_MIRRORS,
+				"pg_ctl -D {{.DataDir}} -o '-p {{.Port}}' status # content {{.ContentID}} dbid {{.DbID}} on {{.Hostname}}")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(commands).To(HaveLen(3))
+			Expect(commands[0].Command.Args).To(Equal([]string{"bash", "-c", "pg_ctl -D /data/gpseg0 -o '-p 20000' status # content 0 dbid 2 on localhost"}))
+			Expect(commands[1].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "testUser@remotehost1",
+				"pg_ctl -D /data/gpseg1 -o '-p 20001' status # content 1 dbid 3 on remotehost1"}))
+			Expect(commands[2].Content).To(Equal(0))
+			Expect(commands[2].Host).To(Equal("remotehost1"))
+			Expect(commands[2].CommandString).To(ContainSubstring("pg_ctl -D /data/mirror/gpseg0 -o '-p 21000' status"))
+		})
+		It("substitutes the hostname for per-host commands", func() {
+			commands, err := testCluster.GenerateCommandListFromTemplate(cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR, "echo {{.Hostname}}")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(commands).To(HaveLen(2))
+			Expect(commands[0].Host).To(Equal("localhost"))
+			Expect(commands[0].Command.Args).To(Equal([]string{"bash", "-c", "echo localhost"}))
+			Expect(commands[1].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "testUser@remotehost1", "echo remotehost1"}))
+		})
+		It("returns an error for a template that cannot be parsed", func() {
+			_, err := testCluster.GenerateCommandListFromTemplate(cluster.ON_SEGMENTS, "ls {{.DataDir")
+
+			Expect(err).To(MatchError(ContainSubstring("Invalid command template")))
+		})
+		It("returns an error for an unknown field", func() {
+			_, err := testCluster.GenerateCommandListFromTemplate(cluster.ON_SEGMENTS, "ls {{.DataDirectory}}")
+
+			Expect(err).To(MatchError(ContainSubstring("Invalid command template for per-segment commands")))
+			Expect(err).To(MatchError(ContainSubstring("can't evaluate field DataDirectory")))
+		})
+		It("returns an error for a segment field in a per-host template", func() {
+			_, err := testCluster.GenerateCommandListFromTemplate(cluster.ON_HOSTS, "ls {{.DataDir}}")
+
+			Expect(err).To(MatchError(ContainSubstring("Invalid command template for per-host commands")))
+		})
+	})
+})
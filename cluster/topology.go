@@ -0,0 +1,188 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to saving the cluster
+ * topology to disk as JSON or YAML, loading it back, and comparing snapshots.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+ * A Cluster is serialized as just its list of segments, since the other
+ * fields can all be derived from them; the Executor is not serialized, and a
+ * loaded Cluster has the default one.
+ */
+type clusterSnapshot struct {
+	Segments []SegConfig `json:"segments" yaml:"segments"`
+}
+
+func (cluster *Cluster) MarshalJSON() ([]byte, error) {
+	return json.Marshal(clusterSnapshot{Segments: cluster.Segments})
+}
+
+func (cluster *Cluster) MarshalYAML() (interface{}, error) {
+	return clusterSnapshot{Segments: cluster.Segments}, nil
+}
+
+func newClusterFromSnapshot(snapshot clusterSnapshot) (*Cluster, error) {
+	hasCoordinator := false
+	for _, seg := range snapshot.Segments {
+		if seg.ContentID == -1 {
+			hasCoordinator = true
+			break
+		}
+	}
+	if len(snapshot.Segments) > 0 && !hasCoordinator {
+		return nil, errors.New("Segment configuration does not contain a coordinator (content -1)")
+	}
+	return NewCluster(snapshot.Segments), nil
+}
+
+func LoadClusterFromJSON(data []byte) (*Cluster, error) {
+	snapshot := clusterSnapshot{}
+	err := json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to parse cluster topology as JSON")
+	}
+	return newClusterFromSnapshot(snapshot)
+}
+
+func LoadClusterFromYAML(data []byte) (*Cluster, error) {
+	snapshot := clusterSnapshot{}
+	err := yaml.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to parse cluster topology as YAML")
+	}
+	return newClusterFromSnapshot(snapshot)
+}
+
+func isYAMLFile(filename string) bool {
+	extension := strings.ToLower(filepath.Ext(filename))
+	return extension == ".yaml" || extension == ".yml"
+}
+
+/*
+ * The format of the file is determined by its extension: .yaml and .yml files
+ * are YAML, and all other files are JSON.
+ */
+func LoadClusterFromFile(filename string) (*Cluster, error) {
+	data, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read cluster topology file %s", filename)
+	}
+	if isYAMLFile(filename) {
+		return LoadClusterFromYAML(data)
+	}
+	return LoadClusterFromJSON(data)
+}
+
+func (cluster *Cluster) WriteToFile(filename string) error {
+	var data []byte
+	var err error
+	if isYAMLFile(filename) {
+		data, err = yaml.Marshal(cluster)
+	} else {
+		data, err = json.MarshalIndent(cluster, "", "  ")
+	}
+	if err != nil {
+		return errors.Wrap(err, "Unable to serialize cluster topology")
+	}
+	file, err := operating.System.OpenFileWrite(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to open cluster topology file %s", filename)
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write cluster topology file %s", filename)
+	}
+	return nil
+}
+
+/*
+ * A SegmentDiff describes how a single segment, identified by its dbid,
+ * differs between two snapshots of a cluster.  Old is nil for segments that
+ * were added and New is nil for segments that were removed; otherwise,
+ * ChangedFields lists the names of the SegConfig fields that differ.
+ */
+type SegmentDiff struct {
+	DbID          int
+	Old           *SegConfig
+	New           *SegConfig
+	ChangedFields []string
+}
+
+func (diff SegmentDiff) String() string {
+	switch {
+	case diff.Old == nil:
+		return fmt.Sprintf("dbid %d: added (content %d on %s)", diff.DbID, diff.New.ContentID, diff.New.Hostname)
+	case diff.New == nil:
+		return fmt.Sprintf("dbid %d: removed (content %d on %s)", diff.DbID, diff.Old.ContentID, diff.Old.Hostname)
+	}
+	oldValues := reflect.ValueOf(*diff.Old)
+	newValues := reflect.ValueOf(*diff.New)
+	changes := make([]string, len(diff.ChangedFields))
+	for i, field := range diff.ChangedFields {
+		changes[i] = fmt.Sprintf("%s %v -> %v", field, oldValues.FieldByName(field).Interface(), newValues.FieldByName(field).Interface())
+	}
+	return fmt.Sprintf("dbid %d: %s", diff.DbID, strings.Join(changes, ", "))
+}
+
+/*
+ * DiffClusters compares two snapshots of a cluster, e.g. one loaded from disk
+ * and one retrieved from the database, and returns the differences ordered by
+ * dbid.  It returns an empty list if the topologies are the same.
+ */
+func DiffClusters(oldCluster *Cluster, newCluster *Cluster) []SegmentDiff {
+	oldByDbid := make(map[int]*SegConfig)
+	newByDbid := make(map[int]*SegConfig)
+	dbids := make([]int, 0)
+	for i := range oldCluster.Segments {
+		seg := &oldCluster.Segments[i]
+		oldByDbid[seg.DbID] = seg
+		dbids = append(dbids, seg.DbID)
+	}
+	for i := range newCluster.Segments {
+		seg := &newCluster.Segments[i]
+		newByDbid[seg.DbID] = seg
+		if _, ok := oldByDbid[seg.DbID]; !ok {
+			dbids = append(dbids, seg.DbID)
+		}
+	}
+	sort.Ints(dbids)
+
+	diffs := make([]SegmentDiff, 0)
+	segType := reflect.TypeOf(SegConfig{})
+	for _, dbid := range dbids {
+		oldSeg, newSeg := oldByDbid[dbid], newByDbid[dbid]
+		if oldSeg == nil || newSeg == nil {
+			diffs = append(diffs, SegmentDiff{DbID: dbid, Old: oldSeg, New: newSeg})
+			continue
+		}
+		changedFields := make([]string, 0)
+		oldValues, newValues := reflect.ValueOf(*oldSeg), reflect.ValueOf(*newSeg)
+		for i := 0; i < segType.NumField(); i++ {
+			if oldValues.Field(i).Interface() != newValues.Field(i).Interface() {
+				changedFields = append(changedFields, segType.Field(i).Name)
+			}
+		}
+		if len(changedFields) > 0 {
+			diffs = append(diffs, SegmentDiff{DbID: dbid, Old: oldSeg, New: newSeg, ChangedFields: changedFields})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,123 @@
+package cluster_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"gopkg.in/yaml.v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/topology tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Role: "p", PreferredRole: "p", Mode: "n", Status: "u", Port: 5432, Hostname: "cdw", Address: "cdw", DataDir: "/data/gpseg-1"}
+	primarySeg := cluster.SegConfig{DbID: 2, ContentID: 0, Role: "p", PreferredRole: "p", Mode: "s", Status: "u", Port: 6000, Hostname: "sdw1", Address: "sdw1", DataDir: "/data/primary/gpseg0"}
+	mirrorSeg := cluster.SegConfig{DbID: 3, ContentID: 0, Role: "m", PreferredRole: "m", Mode: "s", Status: "u", Port: 7000, Hostname: "sdw2", Address: "sdw2", DataDir: "/data/mirror/gpseg0"}
+	var testCluster *cluster.Cluster
+	BeforeEach(func() {
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, primarySeg, mirrorSeg})
+	})
+	Describe("JSON serialization", func() {
+		It("serializes the cluster as a list of segments", func() {
+			data, err := json.Marshal(cluster.NewCluster([]cluster.SegConfig{coordinatorSeg}))
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(`{"segments":[{"dbid":1,"content":-1,"role":"p","preferred_role":"p","mode":"n","status":"u","port":5432,"hostname":"cdw","address":"cdw","datadir":"/data/gpseg-1"}]}`))
+		})
+		It("reconstructs an equivalent cluster", func() {
+			data, err := json.Marshal(testCluster)
+			Expect(err).ToNot(HaveOccurred())
+
+			loadedCluster, err := cluster.LoadClusterFromJSON(data)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loadedCluster.Segments).To(Equal(testCluster.Segments))
+			Expect(loadedCluster.ContentIDs).To(Equal([]int{-1, 0}))
+			Expect(loadedCluster.GetHostForContent(0, "m")).To(Equal("sdw2"))
+			Expect(loadedCluster.Executor).To(Equal(&cluster.GPDBExecutor{}))
+		})
+		It("returns an error for invalid JSON", func() {
+			_, err := cluster.LoadClusterFromJSON([]byte("{"))
+			Expect(err).To(MatchError(ContainSubstring("Unable to parse cluster topology as JSON")))
+		})
+		It("returns an error if there is no coordinator", func() {
+			_, err := cluster.LoadClusterFromJSON([]byte(`{"segments":[{"dbid":2,"content":0}]}`))
+			Expect(err).To(MatchError("Segment configuration does not contain a coordinator (content -1)"))
+		})
+	})
+	Describe("YAML serialization", func() {
+		It("reconstructs an equivalent cluster", func() {
+			data, err := yaml.Marshal(testCluster)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(HavePrefix("segments:\n    - dbid: 1\n      content: -1\n"))
+
+			loadedCluster, err := cluster.LoadClusterFromYAML(data)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loadedCluster.Segments).To(Equal(testCluster.Segments))
+		})
+		It("returns an error for invalid YAML", func() {
+			_, err := cluster.LoadClusterFromYAML([]byte("segments: ["))
+			Expect(err).To(MatchError(ContainSubstring("Unable to parse cluster topology as YAML")))
+		})
+	})
+	Describe("WriteToFile and LoadClusterFromFile", func() {
+		var tempDir string
+		BeforeEach(func() {
+			var err error
+			tempDir, err = os.MkdirTemp("", "topology")
+			Expect(err).ToNot(HaveOccurred())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(tempDir)
+		})
+		DescribeTable("round-trips the topology based on the file extension",
+			func(filename string, expectedPrefix string) {
+				filePath := filepath.Join(tempDir, filename)
+				Expect(testCluster.WriteToFile(filePath)).To(Succeed())
+				contents, err := os.ReadFile(filePath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(HavePrefix(expectedPrefix))
+
+				loadedCluster, err := cluster.LoadClusterFromFile(filePath)
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(loadedCluster.Segments).To(Equal(testCluster.Segments))
+			},
+			Entry("JSON", "topology.json", "{\n  \"segments\": ["),
+			Entry("YAML", "topology.yaml", "segments:"),
+			Entry("YML", "topology.yml", "segments:"),
+		)
+		It("returns an error if the file does not exist", func() {
+			_, err := cluster.LoadClusterFromFile(filepath.Join(tempDir, "missing.json"))
+			Expect(err).To(MatchError(ContainSubstring("Unable to read cluster topology file")))
+		})
+	})
+	Describe("DiffClusters", func() {
+		It("returns no differences for identical topologies", func() {
+			otherCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, primarySeg, mirrorSeg})
+			Expect(cluster.DiffClusters(testCluster, otherCluster)).To(BeEmpty())
+		})
+		It("reports added, removed, and changed segments", func() {
+			failedPrimary := primarySeg
+			failedPrimary.Role = "m"
+			failedPrimary.Status = "d"
+			newSeg := cluster.SegConfig{DbID: 4, ContentID: 1, Role: "p", PreferredRole: "p", Port: 6001, Hostname: "sdw2", DataDir: "/data/primary/gpseg1"}
+			otherCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, failedPrimary, newSeg})
+
+			diffs := cluster.DiffClusters(testCluster, otherCluster)
+
+			Expect(diffs).To(HaveLen(3))
+			Expect(diffs[0].DbID).To(Equal(2))
+			Expect(diffs[0].ChangedFields).To(Equal([]string{"Role", "Status"}))
+			Expect(diffs[0].String()).To(Equal("dbid 2: Role p -> m, Status u -> d"))
+			Expect(diffs[1].New).To(BeNil())
+			Expect(diffs[1].String()).To(Equal("dbid 3: removed (content 0 on sdw2)"))
+			Expect(diffs[2].Old).To(BeNil())
+			Expect(diffs[2].String()).To(Equal("dbid 4: added (content 1 on sdw2)"))
+		})
+	})
+})
@@ -0,0 +1,109 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to tracing the execution
+ * of cluster commands.
+ */
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * The Tracer and Span interfaces are a minimal subset of the OpenTelemetry
+ * tracing API, so that callers can trace cluster commands with OpenTelemetry
+ * (or any other tracing library) by wrapping its tracer in a small adapter,
+ * without this package depending on it.
+ */
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+const (
+	TRACE_SPAN_CLUSTER_COMMAND = "cluster.ExecuteClusterCommand"
+	TRACE_SPAN_SHELL_COMMAND   = "cluster.ShellCommand"
+)
+
+func scopeDescription(scope Scope) string {
+	description := "segments"
+	if scopeIsHosts(scope) {
+		description = "hosts"
+	}
+	if scopeIncludesCoordinator(scope) {
+		description += ",coordinator"
+	}
+	if scopeIncludesMirrors(scope) {
+		description += ",mirrors"
+	}
+	if scopeIsStandby(scope) {
+		description += ",standby"
+	}
+	if scopeIsLocal(scope) {
+		description += ",local"
+	}
+	return description
+}
+
+func (executor *GPDBExecutor) startClusterSpan(scope Scope, numCommands int) (context.Context, Span) {
+	if executor.Tracer == nil {
+		return nil, nil
+	}
+	ctx := executor.TraceContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := executor.Tracer.Start(ctx, TRACE_SPAN_CLUSTER_COMMAND)
+	span.SetAttribute("scope", scopeDescription(scope))
+	span.SetAttribute("num_commands", numCommands)
+	return ctx, span
+}
+
+func (executor *GPDBExecutor) startCommandSpan(ctx context.Context, command ShellCommand) Span {
+	if executor.Tracer == nil {
+		return nil
+	}
+	_, span := executor.Tracer.Start(ctx, TRACE_SPAN_SHELL_COMMAND)
+	if scopeIsHosts(command.Scope) {
+		span.SetAttribute("host", command.Host)
+	} else {
+		span.SetAttribute("content", command.Content)
+	}
+	span.SetAttribute("command", command.CommandString)
+	return span
+}
+
+func endCommandSpan(span Span, command ShellCommand, duration time.Duration) {
+	if span == nil {
+		return
+	}
+	exitStatus := 0
+	if command.Error != nil {
+		exitStatus = -1
+		var exitErr *exec.ExitError
+		if errors.As(command.Error, &exitErr) {
+			exitStatus = exitErr.ExitCode()
+		}
+		span.RecordError(command.Error)
+	}
+	span.SetAttribute("exit_status", exitStatus)
+	span.SetAttribute("duration_ms", duration.Milliseconds())
+	span.End()
+}
+
+func endClusterSpan(span Span, numErrors int) {
+	if span == nil {
+		return
+	}
+	span.SetAttribute("num_errors", numErrors)
+	span.End()
+}
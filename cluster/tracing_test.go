@@ -0,0 +1,128 @@
+package cluster_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type testSpanKey struct{}
+
+type testSpan struct {
+	Name       string
+	Parent     *testSpan
+	Attributes map[string]interface{}
+	Errors     []error
+	Ended      bool
+	mutex      *sync.Mutex
+}
+
+func (span *testSpan) SetAttribute(key string, value interface{}) {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.Attributes[key] = value
+}
+
+func (span *testSpan) RecordError(err error) {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.Errors = append(span.Errors, err)
+}
+
+func (span *testSpan) End() {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.Ended = true
+}
+
+type testTracer struct {
+	Spans []*testSpan
+	mutex sync.Mutex
+}
+
+func (tracer *testTracer) Start(ctx context.Context, spanName string) (context.Context, cluster.Span) {
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	parent, _ := ctx.Value(testSpanKey{}).(*testSpan)
+	span := &testSpan{Name: spanName, Parent: parent, Attributes: make(map[string]interface{}), mutex: &tracer.mutex}
+	tracer.Spans = append(tracer.Spans, span)
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+var _ = Describe("cluster/tracing tests", func() {
+	var tracer *testTracer
+	BeforeEach(func() {
+		tracer = &testTracer{}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("ExecuteClusterCommand", func() {
+		It("creates a span for the execution and a child span for each command", func() {
+			testhelper.MockExecCommand("some output", "", 0)
+			testCluster := cluster.Cluster{Executor: &cluster.GPDBExecutor{Tracer: tracer}}
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"ssh", "remotehost1", "ls"}),
+				cluster.NewShellCommand(cluster.ON_SEGMENTS, 1, "", []string{"ssh", "remotehost2", "ls"}),
+			}
+
+			testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+
+			Expect(tracer.Spans).To(HaveLen(3))
+			clusterSpan := tracer.Spans[0]
+			Expect(clusterSpan.Name).To(Equal(cluster.TRACE_SPAN_CLUSTER_COMMAND))
+			Expect(clusterSpan.Parent).To(BeNil())
+			Expect(clusterSpan.Attributes).To(Equal(map[string]interface{}{"scope": "segments", "num_commands": 2, "num_errors": 0}))
+			Expect(clusterSpan.Ended).To(BeTrue())
+			contents := []interface{}{}
+			for _, span := range tracer.Spans[1:] {
+				Expect(span.Name).To(Equal(cluster.TRACE_SPAN_SHELL_COMMAND))
+				Expect(span.Parent).To(Equal(clusterSpan))
+				Expect(span.Attributes).To(HaveKeyWithValue("exit_status", 0))
+				Expect(span.Attributes).To(HaveKey("duration_ms"))
+				Expect(span.Attributes).ToNot(HaveKey("host"))
+				Expect(span.Errors).To(BeEmpty())
+				Expect(span.Ended).To(BeTrue())
+				contents = append(contents, span.Attributes["content"])
+			}
+			Expect(contents).To(ConsistOf(0, 1))
+		})
+		It("records the host, exit status, and error of failed per-host commands", func() {
+			testhelper.MockExecCommand("", "some error", 2)
+			testCluster := cluster.Cluster{Executor: &cluster.GPDBExecutor{Tracer: tracer}}
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR, -2, "remotehost1", []string{"ssh", "remotehost1", "ls"}),
+			}
+
+			testCluster.ExecuteClusterCommand(cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR, commandList)
+
+			Expect(tracer.Spans).To(HaveLen(2))
+			Expect(tracer.Spans[0].Attributes).To(HaveKeyWithValue("scope", "hosts,coordinator"))
+			Expect(tracer.Spans[0].Attributes).To(HaveKeyWithValue("num_errors", 1))
+			commandSpan := tracer.Spans[1]
+			Expect(commandSpan.Attributes).To(HaveKeyWithValue("host", "remotehost1"))
+			Expect(commandSpan.Attributes).To(HaveKeyWithValue("command", "ssh remotehost1 ls"))
+			Expect(commandSpan.Attributes).To(HaveKeyWithValue("exit_status", 2))
+			Expect(commandSpan.Errors).To(HaveLen(1))
+			Expect(commandSpan.Errors[0]).To(MatchError("exit status 2"))
+		})
+		It("creates spans under the executor's trace context", func() {
+			testhelper.MockExecCommand("", "", 0)
+			parentCtx, parentSpan := tracer.Start(context.Background(), "parent")
+			testCluster := cluster.Cluster{Executor: &cluster.GPDBExecutor{Tracer: tracer, TraceContext: parentCtx}}
+
+			testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS, []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"ls"}),
+			})
+
+			Expect(tracer.Spans).To(HaveLen(3))
+			Expect(tracer.Spans[1].Parent).To(Equal(parentSpan))
+		})
+	})
+})
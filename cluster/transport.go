@@ -0,0 +1,198 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to choosing how commands
+ * are sent to the hosts or containers on which segments run.
+ */
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+/*
+ * A CommandTarget describes where a command should run.  Segment is the
+ * segment the command is for, or nil for per-host commands; for per-content
+ * commands it is the content's primary.  Address is the address chosen by the
+ * cluster's AddressSelection, and Local is true if the command is for the
+ * coordinator host or the scope includes ON_LOCAL.
+ */
+type CommandTarget struct {
+	Host    string
+	Address string
+	Segment *SegConfig
+	Local   bool
+}
+
+/*
+ * A Transport builds the command that runs a shell command string on a target.
+ * A Cluster with a nil Transport uses SSHTransport{}, as commands were always
+ * sent over ssh before Transport existed.
+ *
+ * Commands run by bash locally and in containers, and by the user's login
+ * shell over ssh, so commands that need bash on every transport should be
+ * wrapped in "bash -c".
+ */
+type Transport interface {
+	BuildCommand(target CommandTarget, cmd string) []string
+}
+
+// LocalTransport runs every command on this host, e.g. for single-host clusters or tests
+type LocalTransport struct{}
+
+func (LocalTransport) BuildCommand(_ CommandTarget, cmd string) []string {
+	return []string{"bash", "-c", cmd}
+}
+
+/*
+ * SSHTransport runs local commands with bash and remote commands over ssh, as
+ * User (the current user if not set), with any extra Options passed to ssh
+ * before the destination, e.g. []string{"-o", "ConnectTimeout=10"}.  Host keys
+ * are checked according to the policy set with SetHostKeyPolicy, as ssh uses
+ * the first value given for an option and so ignores one in Options.
+ */
+type SSHTransport struct {
+	User    string
+	Options []string
+}
+
+func (transport SSHTransport) BuildCommand(target CommandTarget, cmd string) []string {
+	if target.Local {
+		return []string{"bash", "-c", cmd}
+	}
+	user := transport.User
+	if user == "" {
+		currentUser, _ := operating.System.CurrentUser()
+		user = currentUser.Username
+	}
+	args := append([]string{"ssh"}, hostKeyOptions()...)
+	args = append(args, transport.Options...)
+	return append(args, fmt.Sprintf("%s@%s", user, target.Address), cmd)
+}
+
+/*
+ * KubectlTransport runs every command with "kubectl exec" in the pod returned
+ * by PodName, including commands for the coordinator, as the utility may not
+ * be running in the coordinator's pod.  A nil PodName uses PodNameFromHostname.
+ * Namespace, Context, and Container are passed to kubectl if they are set.
+ */
+type KubectlTransport struct {
+	Namespace string
+	Context   string
+	Container string
+	PodName   func(target CommandTarget) string
+}
+
+/*
+ * The hostname of a pod in a StatefulSet is the pod's name, so the pod for a
+ * target is the first label of its hostname, e.g. "segment-a-0" for the host
+ * "segment-a-0.segment-a.gpdb.svc.cluster.local".
+ */
+func PodNameFromHostname(target CommandTarget) string {
+	return strings.SplitN(target.Host, ".", 2)[0]
+}
+
+func (transport KubectlTransport) BuildCommand(target CommandTarget, cmd string) []string {
+	podName := PodNameFromHostname
+	if transport.PodName != nil {
+		podName = transport.PodName
+	}
+	args := []string{"kubectl"}
+	if transport.Context != "" {
+		args = append(args, "--context", transport.Context)
+	}
+	if transport.Namespace != "" {
+		args = append(args, "--namespace", transport.Namespace)
+	}
+	args = append(args, "exec", podName(target))
+	if transport.Container != "" {
+		args = append(args, "--container", transport.Container)
+	}
+	return append(args, "--", "bash", "-c", cmd)
+}
+
+func (cluster *Cluster) transport() Transport {
+	if cluster.Transport == nil {
+		return SSHTransport{}
+	}
+	return cluster.Transport
+}
+
+/*
+ * GPHomeForHost returns the GPDB installation directory to use on host: its
+ * entry in GPHomeByHost if it has one, e.g. during an upgrade that installs
+ * the new version on one host at a time, or GPHome otherwise.
+ */
+func (cluster *Cluster) GPHomeForHost(host string) string {
+	if gphome, ok := cluster.GPHomeByHost[host]; ok {
+		return gphome
+	}
+	return cluster.GPHome
+}
+
+/*
+ * Non-interactive ssh sessions do not run the user's login scripts, so
+ * commands that need GPDB executables or libraries fail unless
+ * greenplum_path.sh is sourced first.  If the host has a GPDB installation
+ * directory, every command built for it sources that directory's
+ * greenplum_path.sh before running cmd, whether it runs locally or remotely.
+ * Only the first line of a command of several lines depends on the sourcing
+ * succeeding, so such commands should be wrapped with "bash -c".
+ */
+func (cluster *Cluster) buildCommand(target CommandTarget, cmd string) []string {
+	if gphome := cluster.GPHomeForHost(target.Host); gphome != "" {
+		cmd = fmt.Sprintf("source %s && %s", shellQuote(filepath.Join(gphome, "greenplum_path.sh")), cmd)
+	}
+	return cluster.transport().BuildCommand(target, cmd)
+}
+
+func (cluster *Cluster) isLocalHost(host string, scope Scope) bool {
+	return host == cluster.GetHostForContent(-1) || scopeIsLocal(scope)
+}
+
+// BuildSegmentCommand returns the command that runs cmd on the segment's host using the cluster's Transport
+func (cluster *Cluster) BuildSegmentCommand(scope Scope, segment SegConfig, cmd string) []string {
+	target := CommandTarget{
+		Host:    segment.Hostname,
+		Address: cluster.GetAddressForSegment(segment),
+		Segment: &segment,
+		Local:   cluster.isLocalHost(segment.Hostname, scope),
+	}
+	return cluster.buildCommand(target, cmd)
+}
+
+/*
+ * BuildContentCommand returns the command that runs cmd on the host of the
+ * content's primary, or on the host of the standby coordinator for content -1
+ * with ON_STANDBY.
+ */
+func (cluster *Cluster) BuildContentCommand(scope Scope, content int, cmd string) []string {
+	role := "p"
+	if content == -1 && scopeIsStandby(scope) {
+		role = "m"
+	}
+	host := cluster.GetHostForContent(content, role)
+	target := CommandTarget{
+		Host:    host,
+		Address: cluster.GetAddressForContent(content, role),
+		Local:   cluster.isLocalHost(host, scope),
+	}
+	if segment := getSegmentByRole(cluster.ByContent[content], role); segment != nil {
+		segmentCopy := *segment
+		target.Segment = &segmentCopy
+	}
+	return cluster.buildCommand(target, cmd)
+}
+
+// BuildHostCommand returns the command that runs cmd on the host using the cluster's Transport
+func (cluster *Cluster) BuildHostCommand(scope Scope, host string, cmd string) []string {
+	target := CommandTarget{
+		Host:    host,
+		Address: cluster.GetAddressForHost(host),
+		Local:   cluster.isLocalHost(host, scope),
+	}
+	return cluster.buildCommand(target, cmd)
+}
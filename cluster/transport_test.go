@@ -0,0 +1,107 @@
+package cluster_test
+
+import (
+	"os/user"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/transport tests", func() {
+	var testCluster *cluster.Cluster
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "gpadmin"}, nil }
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "p", Hostname: "cdw.gpdb.svc", Address: "cdw-admin", DataDir: "/data/gpseg-1"},
+			{DbID: 2, ContentID: 0, Role: "p", Hostname: "segment-a-0.gpdb.svc", Address: "sdw1-admin", DataDir: "/data/gpseg0"},
+			{DbID: 3, ContentID: 0, Role: "m", Hostname: "segment-b-0.gpdb.svc", Address: "sdw2-admin", DataDir: "/data/mirror0"},
+		})
+		testCluster.AddressSelection = cluster.PreferAddress
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("SSHTransport", func() {
+		It("is used by default", func() {
+			commands := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(content int) string { return "ls" })
+
+			Expect(commands[0].Command.Args).To(Equal([]string{"bash", "-c", "ls"}))
+			Expect(commands[1].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "gpadmin@sdw1-admin", "ls"}))
+		})
+		It("uses the given user and options", func() {
+			testCluster.Transport = cluster.SSHTransport{User: "admin", Options: []string{"-o", "ConnectTimeout=10"}}
+
+			command := testCluster.BuildHostCommand(cluster.ON_HOSTS, "segment-b-0.gpdb.svc", "ls")
+
+			Expect(command).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "-o", "ConnectTimeout=10", "admin@sdw2-admin", "ls"}))
+		})
+		It("runs commands locally if the scope is local", func() {
+			command := testCluster.BuildContentCommand(cluster.ON_SEGMENTS|cluster.ON_LOCAL, 0, "ls")
+
+			Expect(command).To(Equal([]string{"bash", "-c", "ls"}))
+		})
+	})
+	Describe("LocalTransport", func() {
+		It("runs every command locally", func() {
+			testCluster.Transport = cluster.LocalTransport{}
+
+			commands := testCluster.GenerateSSHCommandList(cluster.ON_HOSTS, func(host string) string { return "hostname" })
+
+			for _, command := range commands {
+				Expect(command.Command.Args).To(Equal([]string{"bash", "-c", "hostname"}))
+			}
+		})
+	})
+	Describe("KubectlTransport", func() {
+		It("runs every command in the pod named by the segment's hostname", func() {
+			testCluster.Transport = cluster.KubectlTransport{Namespace: "gpdb", Container: "greenplum"}
+
+			commands := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(content int) string { return "ls" })
+
+			Expect(commands[0].Command.Args).To(Equal([]string{"kubectl", "--namespace", "gpdb", "exec", "cdw", "--container", "greenplum", "--", "bash", "-c", "ls"}))
+			Expect(commands[1].Command.Args).To(Equal([]string{"kubectl", "--namespace", "gpdb", "exec", "segment-a-0", "--container", "greenplum", "--", "bash", "-c", "ls"}))
+		})
+		It("uses a custom pod name function", func() {
+			testCluster.Transport = cluster.KubectlTransport{
+				Context: "prod",
+				PodName: func(target cluster.CommandTarget) string {
+					return "gpdb-" + target.Segment.Role
+				},
+			}
+
+			mirror := testCluster.ByDbid[3]
+			command := testCluster.BuildSegmentCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_MIRRORS, *mirror, "ls")
+
+			Expect(command).To(Equal([]string{"kubectl", "--context", "prod", "exec", "gpdb-m", "--", "bash", "-c", "ls"}))
+		})
+	})
+	Describe("Cluster.GPHome", func() {
+		It("sources greenplum_path.sh before every command", func() {
+			testCluster.GPHome = "/usr/local/greenplum-db"
+
+			commands := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(content int) string { return "postgres --version" })
+
+			Expect(commands[0].Command.Args).To(Equal([]string{"bash", "-c", "source '/usr/local/greenplum-db/greenplum_path.sh' && postgres --version"}))
+			Expect(commands[1].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "gpadmin@sdw1-admin", "source '/usr/local/greenplum-db/greenplum_path.sh' && postgres --version"}))
+		})
+		It("uses the installation directory for the host if one is set", func() {
+			testCluster.GPHome = "/usr/local/greenplum-db"
+			testCluster.GPHomeByHost = map[string]string{"segment-b-0.gpdb.svc": "/opt/gpdb's new version"}
+
+			mirror := testCluster.ByDbid[3]
+			command := testCluster.BuildSegmentCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_MIRRORS, *mirror, "ls")
+
+			Expect(command[4]).To(Equal(`source '/opt/gpdb'\''s new version/greenplum_path.sh' && ls`))
+			Expect(testCluster.GPHomeForHost("segment-a-0.gpdb.svc")).To(Equal("/usr/local/greenplum-db"))
+		})
+		It("does not change commands if it is not set", func() {
+			command := testCluster.BuildHostCommand(cluster.ON_HOSTS, "segment-b-0.gpdb.svc", "ls")
+
+			Expect(command).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "gpadmin@sdw2-admin", "ls"}))
+		})
+	})
+})
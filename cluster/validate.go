@@ -0,0 +1,213 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to validating the
+ * topology of a cluster, e.g. before expansion or recovery.
+ */
+
+import (
+	"fmt"
+	"sort"
+)
+
+type FindingType string
+
+const (
+	PRIMARY_AND_MIRROR_ON_SAME_HOST FindingType = "primary and mirror on same host"
+	MISSING_MIRROR                  FindingType = "missing mirror"
+	DUPLICATE_DBID                  FindingType = "duplicate dbid"
+	DUPLICATE_PORT                  FindingType = "duplicate port"
+	UNBALANCED_PRIMARIES            FindingType = "unbalanced primaries"
+	ROLE_NOT_PREFERRED_ROLE         FindingType = "role is not preferred role"
+	MISSING_COORDINATOR             FindingType = "missing coordinator"
+)
+
+/*
+ * A Finding describes a single problem with the cluster topology.  Content,
+ * Host, and DbIDs are only set if they are meaningful for the finding's Type;
+ * otherwise they are -2, "", and nil respectively.
+ */
+type Finding struct {
+	Type    FindingType
+	Content int
+	Host    string
+	DbIDs   []int
+	Message string
+}
+
+func (finding Finding) String() string {
+	return fmt.Sprintf("%s: %s", finding.Type, finding.Message)
+}
+
+/*
+ * Validate checks the cluster for common topology problems and returns a
+ * Finding for each one, or an empty list if none are found.  The checks for
+ * mirrors only apply to segments, as the standby coordinator is commonly on
+ * the same host as the coordinator in small clusters.  A configuration
+ * without a coordinator, such as one holding only mirrors, is reported, so
+ * callers that need the whole cluster should validate it.
+ */
+func (cluster *Cluster) Validate() []Finding {
+	findings := make([]Finding, 0)
+	findings = append(findings, cluster.validateCoordinator()...)
+	findings = append(findings, cluster.validateMirrors()...)
+	findings = append(findings, cluster.validateDuplicates()...)
+	findings = append(findings, cluster.validatePrimaryBalance()...)
+	findings = append(findings, cluster.validateRoles()...)
+	return findings
+}
+
+func (cluster *Cluster) validateCoordinator() []Finding {
+	if len(cluster.Segments) == 0 || cluster.GetCoordinator() != nil {
+		return []Finding{}
+	}
+	return []Finding{{
+		Type:    MISSING_COORDINATOR,
+		Content: -1,
+		Host:    "",
+		DbIDs:   nil,
+		Message: "Segment configuration does not contain a coordinator (content -1)",
+	}}
+}
+
+func (cluster *Cluster) validateMirrors() []Finding {
+	findings := make([]Finding, 0)
+	hasMirrors := cluster.HasMirrors()
+	for _, content := range cluster.ContentIDs {
+		if content == -1 {
+			continue
+		}
+		segments := cluster.ByContent[content]
+		if len(segments) == 1 && hasMirrors {
+			findings = append(findings, Finding{
+				Type:    MISSING_MIRROR,
+				Content: content,
+				Host:    "",
+				DbIDs:   []int{segments[0].DbID},
+				Message: fmt.Sprintf("Content %d has no mirror", content),
+			})
+		}
+		if len(segments) == 2 && segments[0].Hostname == segments[1].Hostname {
+			findings = append(findings, Finding{
+				Type:    PRIMARY_AND_MIRROR_ON_SAME_HOST,
+				Content: content,
+				Host:    segments[0].Hostname,
+				DbIDs:   []int{segments[0].DbID, segments[1].DbID},
+				Message: fmt.Sprintf("The primary and mirror for content %d are both on host %s", content, segments[0].Hostname),
+			})
+		}
+	}
+	return findings
+}
+
+func (cluster *Cluster) validateDuplicates() []Finding {
+	findings := make([]Finding, 0)
+	byDbid := make(map[int][]int)
+	dbids := make([]int, 0)
+	for _, segment := range cluster.Segments {
+		if len(byDbid[segment.DbID]) == 0 {
+			dbids = append(dbids, segment.DbID)
+		}
+		byDbid[segment.DbID] = append(byDbid[segment.DbID], segment.ContentID)
+	}
+	for _, dbid := range dbids {
+		if len(byDbid[dbid]) > 1 {
+			findings = append(findings, Finding{
+				Type:    DUPLICATE_DBID,
+				Content: -2,
+				Host:    "",
+				DbIDs:   []int{dbid},
+				Message: fmt.Sprintf("Dbid %d is used by %d segments", dbid, len(byDbid[dbid])),
+			})
+		}
+	}
+
+	for _, host := range cluster.Hostnames {
+		byPort := make(map[int][]int)
+		ports := make([]int, 0)
+		for _, segment := range cluster.ByHost[host] {
+			if len(byPort[segment.Port]) == 0 {
+				ports = append(ports, segment.Port)
+			}
+			byPort[segment.Port] = append(byPort[segment.Port], segment.DbID)
+		}
+		for _, port := range ports {
+			if len(byPort[port]) > 1 {
+				findings = append(findings, Finding{
+					Type:    DUPLICATE_PORT,
+					Content: -2,
+					Host:    host,
+					DbIDs:   byPort[port],
+					Message: fmt.Sprintf("Port %d is used by %d segments on host %s", port, len(byPort[port]), host),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+/*
+ * Only hosts with at least one segment (primary or mirror) are considered, so
+ * that a dedicated coordinator host is not reported as having too few primaries.
+ */
+func (cluster *Cluster) validatePrimaryBalance() []Finding {
+	primariesPerHost := make(map[string]int)
+	for _, content := range cluster.ContentIDs {
+		if content == -1 {
+			continue
+		}
+		for i, segment := range cluster.ByContent[content] {
+			if _, ok := primariesPerHost[segment.Hostname]; !ok {
+				primariesPerHost[segment.Hostname] = 0
+			}
+			if i == 0 {
+				primariesPerHost[segment.Hostname]++
+			}
+		}
+	}
+	if len(primariesPerHost) < 2 {
+		return []Finding{}
+	}
+	counts := make([]int, 0, len(primariesPerHost))
+	for _, count := range primariesPerHost {
+		counts = append(counts, count)
+	}
+	sort.Ints(counts)
+	minimum, maximum := counts[0], counts[len(counts)-1]
+	if minimum == maximum {
+		return []Finding{}
+	}
+
+	findings := make([]Finding, 0)
+	for _, host := range cluster.Hostnames {
+		count, ok := primariesPerHost[host]
+		if !ok || count != maximum {
+			continue
+		}
+		findings = append(findings, Finding{
+			Type:    UNBALANCED_PRIMARIES,
+			Content: -2,
+			Host:    host,
+			DbIDs:   nil,
+			Message: fmt.Sprintf("Host %s has %d primaries, while other hosts have as few as %d", host, count, minimum),
+		})
+	}
+	return findings
+}
+
+func (cluster *Cluster) validateRoles() []Finding {
+	findings := make([]Finding, 0)
+	for _, segment := range cluster.Segments {
+		if segment.Role == "" || segment.PreferredRole == "" || segment.Role == segment.PreferredRole {
+			continue
+		}
+		findings = append(findings, Finding{
+			Type:    ROLE_NOT_PREFERRED_ROLE,
+			Content: segment.ContentID,
+			Host:    segment.Hostname,
+			DbIDs:   []int{segment.DbID},
+			Message: fmt.Sprintf("Segment with dbid %d has role %s but preferred role %s; the cluster may need to be rebalanced", segment.DbID, segment.Role, segment.PreferredRole),
+		})
+	}
+	return findings
+}
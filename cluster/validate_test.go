@@ -0,0 +1,116 @@
+package cluster_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/validate tests", func() {
+	coordinator := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1", Role: "p", PreferredRole: "p"}
+	standby := cluster.SegConfig{DbID: 8, ContentID: -1, Port: 5432, Hostname: "scdw", DataDir: "/data/standby", Role: "m", PreferredRole: "m"}
+	primaryZero := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 6000, Hostname: "sdw1", DataDir: "/data/primary/gpseg0", Role: "p", PreferredRole: "p"}
+	primaryOne := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 6000, Hostname: "sdw2", DataDir: "/data/primary/gpseg1", Role: "p", PreferredRole: "p"}
+	mirrorZero := cluster.SegConfig{DbID: 4, ContentID: 0, Port: 7000, Hostname: "sdw2", DataDir: "/data/mirror/gpseg0", Role: "m", PreferredRole: "m"}
+	mirrorOne := cluster.SegConfig{DbID: 5, ContentID: 1, Port: 7000, Hostname: "sdw1", DataDir: "/data/mirror/gpseg1", Role: "m", PreferredRole: "m"}
+
+	findingTypes := func(findings []cluster.Finding) []cluster.FindingType {
+		types := make([]cluster.FindingType, len(findings))
+		for i, finding := range findings {
+			types[i] = finding.Type
+		}
+		return types
+	}
+
+	Describe("Validate", func() {
+		It("returns no findings for a valid mirrored cluster", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinator, standby, primaryZero, mirrorZero, primaryOne, mirrorOne})
+			Expect(testCluster.Validate()).To(BeEmpty())
+		})
+		It("returns no findings for a valid mirrorless cluster", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinator, primaryZero, primaryOne})
+			Expect(testCluster.Validate()).To(BeEmpty())
+		})
+		It("reports a primary and mirror on the same host", func() {
+			sameHostMirror := mirrorZero
+			sameHostMirror.Hostname = "sdw1"
+			sameHostMirror.Port = 7001
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinator, primaryZero, sameHostMirror, primaryOne, mirrorOne})
+
+			findings := testCluster.Validate()
+
+			Expect(findings).To(HaveLen(1))
+			Expect(findings[0]).To(Equal(cluster.Finding{
+				Type:    cluster.PRIMARY_AND_MIRROR_ON_SAME_HOST,
+				Content: 0,
+				Host:    "sdw1",
+				DbIDs:   []int{2, 4},
+				Message: "The primary and mirror for content 0 are both on host sdw1",
+			}))
+		})
+		It("reports contents that are missing mirrors", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinator, primaryZero, mirrorZero, primaryOne})
+
+			findings := testCluster.Validate()
+
+			Expect(findingTypes(findings)).To(Equal([]cluster.FindingType{cluster.MISSING_MIRROR}))
+			Expect(findings[0].Content).To(Equal(1))
+			Expect(findings[0].DbIDs).To(Equal([]int{3}))
+		})
+		It("reports duplicate dbids and duplicate ports on a host", func() {
+			duplicate := primaryOne
+			duplicate.DbID = 2
+			duplicate.Hostname = "sdw1"
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinator, primaryZero, duplicate})
+
+			findings := testCluster.Validate()
+
+			Expect(findingTypes(findings)).To(Equal([]cluster.FindingType{cluster.DUPLICATE_DBID, cluster.DUPLICATE_PORT}))
+			Expect(findings[0].DbIDs).To(Equal([]int{2}))
+			Expect(findings[1].Host).To(Equal("sdw1"))
+			Expect(findings[1].Message).To(Equal("Port 6000 is used by 2 segments on host sdw1"))
+		})
+		It("reports hosts with more primaries than others", func() {
+			primaryTwo := cluster.SegConfig{DbID: 6, ContentID: 2, Port: 6001, Hostname: "sdw1", DataDir: "/data/primary/gpseg2", Role: "p", PreferredRole: "p"}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinator, primaryZero, primaryOne, primaryTwo})
+
+			findings := testCluster.Validate()
+
+			Expect(findingTypes(findings)).To(Equal([]cluster.FindingType{cluster.UNBALANCED_PRIMARIES}))
+			Expect(findings[0].Host).To(Equal("sdw1"))
+			Expect(findings[0].String()).To(Equal("unbalanced primaries: Host sdw1 has 2 primaries, while other hosts have as few as 1"))
+		})
+		It("reports segments that are not in their preferred role after failover", func() {
+			failedOverPrimary := primaryZero
+			failedOverPrimary.Role = "m"
+			failedOverMirror := mirrorZero
+			failedOverMirror.Role = "p"
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinator, failedOverPrimary, failedOverMirror, primaryOne, mirrorOne})
+
+			findings := testCluster.Validate()
+
+			Expect(findingTypes(findings)).To(ContainElements(cluster.ROLE_NOT_PREFERRED_ROLE, cluster.UNBALANCED_PRIMARIES))
+			roleFindings := []cluster.Finding{}
+			for _, finding := range findings {
+				if finding.Type == cluster.ROLE_NOT_PREFERRED_ROLE {
+					roleFindings = append(roleFindings, finding)
+				}
+			}
+			Expect(roleFindings).To(HaveLen(2))
+			Expect(roleFindings[0].DbIDs).To(Equal([]int{2}))
+			Expect(roleFindings[1].DbIDs).To(Equal([]int{4}))
+		})
+		It("reports a segment configuration without a coordinator", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{mirrorZero, mirrorOne})
+
+			findings := testCluster.Validate()
+
+			Expect(findingTypes(findings)).To(Equal([]cluster.FindingType{cluster.MISSING_COORDINATOR}))
+			Expect(findings[0].Content).To(Equal(-1))
+		})
+		It("returns no findings for an empty segment configuration", func() {
+			Expect(cluster.NewCluster([]cluster.SegConfig{}).Validate()).To(BeEmpty())
+		})
+	})
+})
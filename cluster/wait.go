@@ -0,0 +1,188 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to waiting for segments to
+ * reach a given state, as recorded in gp_segment_configuration.
+ */
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/retry"
+	"github.com/pkg/errors"
+)
+
+// The default time WaitForState waits between queries of gp_segment_configuration
+const DEFAULT_WAIT_INTERVAL = time.Second
+
+// AllSegmentsUp holds if every segment is marked up
+func AllSegmentsUp(segments []SegConfig) bool {
+	for _, segment := range segments {
+		if segment.Status != "u" {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * AllSegmentsSynchronized holds if every segment is marked up and every
+ * segment that has a mirror or standby is synchronized with it.  Segments
+ * without one are never marked synchronized, so only their status is checked.
+ */
+func AllSegmentsSynchronized(segments []SegConfig) bool {
+	numPerContent := make(map[int]int, 0)
+	for _, segment := range segments {
+		numPerContent[segment.ContentID]++
+	}
+	for _, segment := range segments {
+		if segment.Status != "u" || (numPerContent[segment.ContentID] > 1 && segment.Mode != "s") {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * A WaitTimeoutError is returned by WaitForState if the segments did not reach
+ * the desired state in time.  Segments contains the segments that did not
+ * conform to the predicate the last time the configuration was queried, and
+ * LastErr contains the error from the last query, if it failed.
+ */
+type WaitTimeoutError struct {
+	Timeout  time.Duration
+	Segments []SegConfig
+	LastErr  error
+}
+
+func (err *WaitTimeoutError) Error() string {
+	message := fmt.Sprintf("Timed out after %s waiting for segments to reach the desired state", err.Timeout)
+	if len(err.Segments) > 0 {
+		descriptions := make([]string, len(err.Segments))
+		for i, segment := range err.Segments {
+			descriptions[i] = fmt.Sprintf("dbid %d (content %d, %s:%d) has status %s and mode %s",
+				segment.DbID, segment.ContentID, segment.Hostname, segment.Port, segment.Status, segment.Mode)
+		}
+		message += fmt.Sprintf("; %d segment(s) did not: %s", len(err.Segments), strings.Join(descriptions, ", "))
+	}
+	if err.LastErr != nil {
+		message += fmt.Sprintf("; the last query of the segment configuration failed: %v", err.LastErr)
+	}
+	return message
+}
+
+func (err *WaitTimeoutError) Unwrap() error {
+	return err.LastErr
+}
+
+// Returns only the segments in the cluster, or all segments for an empty cluster
+func (cluster *Cluster) filterSegments(segments []SegConfig) []SegConfig {
+	if len(cluster.ByDbid) == 0 {
+		return segments
+	}
+	filtered := make([]SegConfig, 0, len(segments))
+	for _, segment := range segments {
+		if _, ok := cluster.ByDbid[segment.DbID]; ok {
+			filtered = append(filtered, segment)
+		}
+	}
+	return filtered
+}
+
+/*
+ * Predicates are evaluated on all of the segments at once, so to report which
+ * segments are holding things up, the predicate is evaluated separately on the
+ * segments for each content, and then on each segment of a content that does
+ * not conform.  If no single segment fails the predicate, as when a primary
+ * and mirror are up but not synchronized, the whole content is reported.  This
+ * is exact for predicates that check each segment or content independently,
+ * like AllSegmentsUp, and approximate for predicates that depend on the
+ * cluster as a whole.
+ */
+func nonConformingSegments(segments []SegConfig, predicate func([]SegConfig) bool) []SegConfig {
+	contents := make([]int, 0)
+	byContent := make(map[int][]SegConfig, 0)
+	for _, segment := range segments {
+		if _, ok := byContent[segment.ContentID]; !ok {
+			contents = append(contents, segment.ContentID)
+		}
+		byContent[segment.ContentID] = append(byContent[segment.ContentID], segment)
+	}
+	nonConforming := make([]SegConfig, 0)
+	for _, content := range contents {
+		group := byContent[content]
+		if predicate(group) {
+			continue
+		}
+		failed := make([]SegConfig, 0)
+		for _, segment := range group {
+			if !predicate([]SegConfig{segment}) {
+				failed = append(failed, segment)
+			}
+		}
+		if len(failed) == 0 {
+			failed = group
+		}
+		nonConforming = append(nonConforming, failed...)
+	}
+	return nonConforming
+}
+
+/*
+ * WaitForState queries gp_segment_configuration every interval until predicate
+ * holds for the segments in the cluster, or for all segments if the cluster is
+ * empty, and returns the segments from the last query.  Failed queries are
+ * logged and retried, as the coordinator may be briefly unavailable while
+ * segments fail over.  If the predicate does not hold within timeout, it
+ * returns a *WaitTimeoutError.
+ */
+func (cluster *Cluster) WaitForState(conn *dbconn.DBConn, predicate func([]SegConfig) bool, timeout time.Duration, interval time.Duration) ([]SegConfig, error) {
+	if interval <= 0 {
+		interval = DEFAULT_WAIT_INTERVAL
+	}
+	opts := retry.Options{
+		Operation:  "Segment state check",
+		MaxElapsed: timeout,
+		Backoff:    retry.Constant{Interval: interval},
+	}
+	if timeout <= 0 {
+		opts.MaxAttempts = 1
+	}
+	start := operating.System.Now()
+	var segments []SegConfig
+	var lastErr error
+	err := retry.Do(context.Background(), opts, func(attempt int) error {
+		var queried []SegConfig
+		queried, lastErr = GetSegmentConfiguration(conn, true)
+		if lastErr != nil {
+			return errors.Wrap(lastErr, "Unable to query segment configuration")
+		}
+		segments = cluster.filterSegments(queried)
+		if !predicate(segments) {
+			return errors.Errorf("%d segment(s) have not reached the desired state", len(nonConformingSegments(segments, predicate)))
+		}
+		return nil
+	})
+	if err != nil {
+		return segments, &WaitTimeoutError{Timeout: timeout, Segments: nonConformingSegments(segments, predicate), LastErr: lastErr}
+	}
+	logDomain.Verbose("Segments reached the desired state after %s", operating.System.Now().Sub(start))
+	return segments, nil
+}
+
+// WaitForSegmentsUp waits until all segments in the cluster are marked up
+func (cluster *Cluster) WaitForSegmentsUp(conn *dbconn.DBConn, timeout time.Duration, interval time.Duration) error {
+	_, err := cluster.WaitForState(conn, AllSegmentsUp, timeout, interval)
+	return err
+}
+
+// WaitForSegmentsSynchronized waits until all segments in the cluster are up and synchronized
+func (cluster *Cluster) WaitForSegmentsSynchronized(conn *dbconn.DBConn, timeout time.Duration, interval time.Duration) error {
+	_, err := cluster.WaitForState(conn, AllSegmentsSynchronized, timeout, interval)
+	return err
+}
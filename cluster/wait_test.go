@@ -0,0 +1,102 @@
+package cluster_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/wait tests", func() {
+	var (
+		clock       *testhelper.FakeClock
+		coordinator cluster.SegConfig
+		primary     cluster.SegConfig
+		mirror      cluster.SegConfig
+		testCluster *cluster.Cluster
+	)
+	BeforeEach(func() {
+		clock = testhelper.MockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		clock.AutoAdvance = true
+		coordinator = cluster.SegConfig{DbID: 1, ContentID: -1, Role: "p", PreferredRole: "p", Mode: "n", Status: "u", Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1"}
+		primary = cluster.SegConfig{DbID: 2, ContentID: 0, Role: "p", PreferredRole: "p", Mode: "s", Status: "u", Port: 6000, Hostname: "sdw1", DataDir: "/data/gpseg0"}
+		mirror = cluster.SegConfig{DbID: 3, ContentID: 0, Role: "m", PreferredRole: "m", Mode: "s", Status: "u", Port: 7000, Hostname: "sdw2", DataDir: "/data/mirror0"}
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinator, primary, mirror})
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("AllSegmentsSynchronized", func() {
+		It("only requires segments with a mirror to be synchronized", func() {
+			Expect(cluster.AllSegmentsSynchronized([]cluster.SegConfig{coordinator, primary, mirror})).To(BeTrue())
+			mirror.Mode = "n"
+			Expect(cluster.AllSegmentsSynchronized([]cluster.SegConfig{coordinator, primary, mirror})).To(BeFalse())
+		})
+		It("requires all segments to be up", func() {
+			coordinator.Status = "d"
+			Expect(cluster.AllSegmentsSynchronized([]cluster.SegConfig{coordinator})).To(BeFalse())
+		})
+	})
+	Describe("WaitForState", func() {
+		It("polls until the predicate holds", func() {
+			mirror.Status = "d"
+			testhelper.ExpectSegmentConfigQuery(mock, []cluster.SegConfig{coordinator, primary, mirror})
+			mock.ExpectQuery(`SELECT (.*) FROM gp_segment_configuration`).WillReturnError(errors.New("connection reset"))
+			mirror.Status = "u"
+			testhelper.ExpectSegmentConfigQuery(mock, []cluster.SegConfig{coordinator, primary, mirror})
+
+			segments, err := testCluster.WaitForState(connection, cluster.AllSegmentsUp, time.Minute, 5*time.Second)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segments).To(Equal([]cluster.SegConfig{coordinator, primary, mirror}))
+			Expect(clock.Waits).To(Equal([]time.Duration{5 * time.Second, 5 * time.Second}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			testhelper.ExpectRegexp(logfile, "Segment state check attempt 1 failed: 1 segment(s) have not reached the desired state; retrying in 5s")
+			testhelper.ExpectRegexp(logfile, "Segment state check attempt 2 failed: Unable to query segment configuration: connection reset; retrying in 5s")
+			testhelper.ExpectRegexp(logfile, "Segments reached the desired state after 10s")
+		})
+		It("only checks segments in the cluster", func() {
+			downSegment := cluster.SegConfig{DbID: 4, ContentID: 1, Role: "p", PreferredRole: "p", Mode: "n", Status: "d", Port: 6001, Hostname: "sdw2"}
+			testhelper.ExpectSegmentConfigQuery(mock, []cluster.SegConfig{coordinator, primary, downSegment, mirror})
+
+			segments, err := testCluster.WaitForState(connection, cluster.AllSegmentsUp, time.Minute, time.Second)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segments).To(Equal([]cluster.SegConfig{coordinator, primary, mirror}))
+		})
+		It("returns the segments that did not conform when it times out", func() {
+			mirror.Mode = "n"
+			primary.Mode = "n"
+			for i := 0; i < 4; i++ {
+				testhelper.ExpectSegmentConfigQuery(mock, []cluster.SegConfig{coordinator, primary, mirror})
+			}
+
+			segments, err := testCluster.WaitForState(connection, cluster.AllSegmentsSynchronized, 25*time.Second, 10*time.Second)
+
+			Expect(segments).To(Equal([]cluster.SegConfig{coordinator, primary, mirror}))
+			Expect(clock.Waits).To(Equal([]time.Duration{10 * time.Second, 10 * time.Second, 5 * time.Second}))
+			var timeoutErr *cluster.WaitTimeoutError
+			Expect(errors.As(err, &timeoutErr)).To(BeTrue())
+			Expect(timeoutErr.Segments).To(Equal([]cluster.SegConfig{primary, mirror}))
+			Expect(timeoutErr.LastErr).ToNot(HaveOccurred())
+			Expect(err).To(MatchError("Timed out after 25s waiting for segments to reach the desired state; 2 segment(s) did not: " +
+				"dbid 2 (content 0, sdw1:6000) has status u and mode n, dbid 3 (content 0, sdw2:7000) has status u and mode n"))
+		})
+		It("includes the last query error when it times out", func() {
+			for i := 0; i < 2; i++ {
+				mock.ExpectQuery(`SELECT (.*) FROM gp_segment_configuration`).WillReturnError(errors.New("connection refused"))
+			}
+
+			err := testCluster.WaitForSegmentsUp(connection, time.Second, 0)
+
+			Expect(err).To(MatchError("Timed out after 1s waiting for segments to reach the desired state; the last query of the segment configuration failed: connection refused"))
+			Expect(errors.Unwrap(err)).To(MatchError("connection refused"))
+		})
+	})
+})
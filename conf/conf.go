@@ -0,0 +1,319 @@
+package conf
+
+/*
+ * This file contains structs and functions for loading configuration values
+ * from layered sources: defaults, configuration files, environment variables,
+ * and command-line flags, in increasing order of precedence.
+ */
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SOURCE_DEFAULT     = "default"
+	SOURCE_ENVIRONMENT = "environment"
+	SOURCE_FLAG        = "flag"
+)
+
+type fileLayer struct {
+	filename string
+	values   map[string]interface{}
+}
+
+/*
+ * Keys are case-insensitive, and nested keys in configuration files are
+ * joined with dots, so that the value of "port" in the following file has the
+ * key "backup.port":
+ *
+ *   backup:
+ *     port: 5432
+ *
+ * An environment variable for a key is named by the key in upper case, with
+ * dots and dashes replaced by underscores and the environment prefix
+ * prepended, e.g. GPBACKUP_BACKUP_PORT for the prefix "GPBACKUP".  A flag for
+ * a key is named
+ * by the key with dots replaced by dashes, e.g. --backup-port, and only flags
+ * that were explicitly passed on the command line override other sources.
+ * Environment variables and flags are looked up whenever a value is requested,
+ * so they need not be known in advance.
+ */
+type Config struct {
+	defaults  map[string]interface{}
+	files     []fileLayer
+	envPrefix string
+	useEnv    bool
+	flags     *flag.FlagSet
+}
+
+func New() *Config {
+	return &Config{defaults: make(map[string]interface{})}
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(key)
+}
+
+func (config *Config) SetDefault(key string, value interface{}) {
+	config.defaults[normalizeKey(key)] = value
+}
+
+func flattenValues(prefix string, values map[string]interface{}, flattened map[string]interface{}) {
+	for key, value := range values {
+		fullKey := normalizeKey(prefix + key)
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenValues(fullKey+".", nested, flattened)
+		} else {
+			flattened[fullKey] = value
+		}
+	}
+}
+
+/*
+ * LoadFile reads a YAML configuration file, which may also be in JSON format
+ * as JSON is a subset of YAML.  Files loaded later take precedence over files
+ * loaded earlier, so a system-wide file can be overridden by a per-user one.
+ */
+func (config *Config) LoadFile(filename string) error {
+	if strings.ToLower(filepath.Ext(filename)) == ".toml" {
+		return errors.Errorf("Unable to load configuration file %s: TOML files are not supported", filename)
+	}
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read configuration file %s", filename)
+	}
+	values := make(map[string]interface{})
+	err = yaml.Unmarshal(contents, &values)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to parse configuration file %s", filename)
+	}
+	layer := fileLayer{filename: filename, values: make(map[string]interface{})}
+	flattenValues("", values, layer.values)
+	config.files = append(config.files, layer)
+	return nil
+}
+
+func (config *Config) MustLoadFile(filename string) {
+	err := config.LoadFile(filename)
+	gplog.FatalOnError(err)
+}
+
+/*
+ * LoadOptionalFile is as LoadFile, but does nothing if the file does not
+ * exist, for configuration files that users are not required to create.
+ */
+func (config *Config) LoadOptionalFile(filename string) error {
+	_, err := operating.System.Stat(filename)
+	if err != nil && operating.System.IsNotExist(err) {
+		return nil
+	}
+	return config.LoadFile(filename)
+}
+
+func (config *Config) LoadEnvironment(prefix string) {
+	config.envPrefix = prefix
+	config.useEnv = true
+}
+
+func (config *Config) LoadFlags(flags *flag.FlagSet) {
+	config.flags = flags
+}
+
+func (config *Config) envVarName(key string) string {
+	name := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	if config.envPrefix != "" {
+		name = strings.ToUpper(config.envPrefix) + "_" + name
+	}
+	return name
+}
+
+func (config *Config) lookupFlag(key string) (string, bool) {
+	if config.flags == nil {
+		return "", false
+	}
+	flagName := strings.ReplaceAll(key, ".", "-")
+	value, found := "", false
+	config.flags.Visit(func(f *flag.Flag) {
+		if strings.ToLower(f.Name) == flagName {
+			value, found = f.Value.String(), true
+		}
+	})
+	return value, found
+}
+
+func (config *Config) lookup(key string) (interface{}, string, bool) {
+	key = normalizeKey(key)
+	if value, ok := config.lookupFlag(key); ok {
+		return value, SOURCE_FLAG, true
+	}
+	if config.useEnv {
+		if value, ok := operating.System.LookupEnv(config.envVarName(key)); ok {
+			return value, SOURCE_ENVIRONMENT, true
+		}
+	}
+	for i := len(config.files) - 1; i >= 0; i-- {
+		if value, ok := config.files[i].values[key]; ok {
+			return value, config.files[i].filename, true
+		}
+	}
+	if value, ok := config.defaults[key]; ok {
+		return value, SOURCE_DEFAULT, true
+	}
+	return nil, "", false
+}
+
+func (config *Config) IsSet(key string) bool {
+	_, _, ok := config.lookup(key)
+	return ok
+}
+
+/*
+ * Source returns where the value for key was found: SOURCE_FLAG,
+ * SOURCE_ENVIRONMENT, SOURCE_DEFAULT, or the name of a configuration file.  It
+ * returns an empty string if key is not set.
+ */
+func (config *Config) Source(key string) string {
+	_, source, _ := config.lookup(key)
+	return source
+}
+
+/*
+ * Keys returns the keys set in defaults and configuration files, in sorted
+ * order.  Keys set only through environment variables or flags are not known
+ * in advance and so are not included.
+ */
+func (config *Config) Keys() []string {
+	keySet := make(map[string]bool)
+	for key := range config.defaults {
+		keySet[key] = true
+	}
+	for _, layer := range config.files {
+		for key := range layer.values {
+			keySet[key] = true
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/*
+ * The following functions return the value for key converted to the given
+ * type.  Values from environment variables and flags are always strings, so
+ * they are parsed, while values from files and defaults may already be of the
+ * right type.  If key is not set, the zero value of the type is returned with
+ * no error.
+ */
+
+func (config *Config) GetString(key string) string {
+	value, _, ok := config.lookup(key)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func (config *Config) GetInt(key string) (int, error) {
+	value, source, ok := config.lookup(key)
+	if !ok {
+		return 0, nil
+	}
+	switch typedValue := value.(type) {
+	case int:
+		return typedValue, nil
+	case int64:
+		return int(typedValue), nil
+	case string:
+		result, err := strconv.Atoi(strings.TrimSpace(typedValue))
+		if err != nil {
+			return 0, invalidValueError(key, source, value, "an integer")
+		}
+		return result, nil
+	}
+	return 0, invalidValueError(key, source, value, "an integer")
+}
+
+func (config *Config) GetBool(key string) (bool, error) {
+	value, source, ok := config.lookup(key)
+	if !ok {
+		return false, nil
+	}
+	switch typedValue := value.(type) {
+	case bool:
+		return typedValue, nil
+	case string:
+		result, err := strconv.ParseBool(strings.TrimSpace(typedValue))
+		if err != nil {
+			return false, invalidValueError(key, source, value, "a boolean")
+		}
+		return result, nil
+	}
+	return false, invalidValueError(key, source, value, "a boolean")
+}
+
+func (config *Config) GetDuration(key string) (time.Duration, error) {
+	value, source, ok := config.lookup(key)
+	if !ok {
+		return 0, nil
+	}
+	switch typedValue := value.(type) {
+	case time.Duration:
+		return typedValue, nil
+	case string:
+		result, err := time.ParseDuration(strings.TrimSpace(typedValue))
+		if err != nil {
+			return 0, invalidValueError(key, source, value, "a duration")
+		}
+		return result, nil
+	}
+	return 0, invalidValueError(key, source, value, "a duration")
+}
+
+/*
+ * A list in a configuration file is returned as is, while a string value is
+ * split on commas, e.g. --include-schema=public,sales.
+ */
+func (config *Config) GetStringSlice(key string) []string {
+	value, _, ok := config.lookup(key)
+	if !ok {
+		return nil
+	}
+	switch typedValue := value.(type) {
+	case []string:
+		return typedValue
+	case []interface{}:
+		result := make([]string, 0, len(typedValue))
+		for _, element := range typedValue {
+			result = append(result, fmt.Sprintf("%v", element))
+		}
+		return result
+	case string:
+		if typedValue == "" {
+			return []string{}
+		}
+		result := strings.Split(typedValue, ",")
+		for i := range result {
+			result[i] = strings.TrimSpace(result[i])
+		}
+		return result
+	}
+	return []string{fmt.Sprintf("%v", value)}
+}
+
+func invalidValueError(key string, source string, value interface{}, expected string) error {
+	return errors.Errorf("Invalid value %q for configuration key %s from %s: expected %s", fmt.Sprintf("%v", value), key, source, expected)
+}
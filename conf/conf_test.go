@@ -0,0 +1,178 @@
+package conf_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/conf"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestConf(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "conf tests")
+}
+
+var _ = BeforeSuite(func() {
+	testhelper.SetupTestLogger()
+})
+
+func writeConfigFile(dir string, name string, contents string) string {
+	filename := filepath.Join(dir, name)
+	Expect(os.WriteFile(filename, []byte(contents), 0644)).To(Succeed())
+	return filename
+}
+
+var _ = Describe("conf tests", func() {
+	var (
+		config  *conf.Config
+		tempDir string
+	)
+	BeforeEach(func() {
+		config = conf.New()
+		tempDir = GinkgoT().TempDir()
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("LoadFile", func() {
+		It("flattens nested keys with dots", func() {
+			filename := writeConfigFile(tempDir, "config.yaml", "dbname: postgres\nbackup:\n  jobs: 4\n  Compress: true\n  schemas: [public, sales]\n")
+			Expect(config.LoadFile(filename)).To(Succeed())
+			Expect(config.GetString("dbname")).To(Equal("postgres"))
+			Expect(config.GetInt("backup.jobs")).To(Equal(4))
+			Expect(config.GetBool("BACKUP.COMPRESS")).To(BeTrue())
+			Expect(config.GetStringSlice("backup.schemas")).To(Equal([]string{"public", "sales"}))
+			Expect(config.Keys()).To(Equal([]string{"backup.compress", "backup.jobs", "backup.schemas", "dbname"}))
+		})
+		It("loads JSON files", func() {
+			filename := writeConfigFile(tempDir, "config.json", `{"backup": {"jobs": 2}}`)
+			Expect(config.LoadFile(filename)).To(Succeed())
+			Expect(config.GetInt("backup.jobs")).To(Equal(2))
+		})
+		It("gives later files precedence over earlier ones", func() {
+			systemFile := writeConfigFile(tempDir, "system.yaml", "jobs: 1\ndbname: postgres\n")
+			userFile := writeConfigFile(tempDir, "user.yaml", "jobs: 8\n")
+			Expect(config.LoadFile(systemFile)).To(Succeed())
+			Expect(config.LoadFile(userFile)).To(Succeed())
+			Expect(config.GetInt("jobs")).To(Equal(8))
+			Expect(config.Source("jobs")).To(Equal(userFile))
+			Expect(config.Source("dbname")).To(Equal(systemFile))
+		})
+		It("loads files through operating.System", func() {
+			testhelper.NewFixtureFS().
+				WithFile("/etc/gp/system.yaml", "jobs: 1\n", 0644).
+				WithFile("/home/gpadmin/.gp/user.yaml", "jobs: 8\n", 0600).
+				Install()
+			Expect(config.LoadFile("/etc/gp/system.yaml")).To(Succeed())
+			Expect(config.LoadOptionalFile("/home/gpadmin/.gp/user.yaml")).To(Succeed())
+			Expect(config.LoadOptionalFile("/home/gpadmin/.gp/missing.yaml")).To(Succeed())
+			Expect(config.GetInt("jobs")).To(Equal(8))
+		})
+		It("returns an error if the file cannot be read", func() {
+			operating.System.ReadFile = func(filename string) ([]byte, error) { return nil, errors.New("permission denied") }
+			err := config.LoadFile("/tmp/config.yaml")
+			Expect(err).To(MatchError("Unable to read configuration file /tmp/config.yaml: permission denied"))
+		})
+		It("returns an error if the file cannot be parsed", func() {
+			filename := writeConfigFile(tempDir, "config.yaml", "jobs: [1\n")
+			err := config.LoadFile(filename)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Unable to parse configuration file " + filename))
+		})
+		It("returns an error for TOML files", func() {
+			err := config.LoadFile("/tmp/config.toml")
+			Expect(err).To(MatchError("Unable to load configuration file /tmp/config.toml: TOML files are not supported"))
+		})
+		It("does nothing for a missing optional file", func() {
+			Expect(config.LoadOptionalFile(filepath.Join(tempDir, "missing.yaml"))).To(Succeed())
+			Expect(config.Keys()).To(BeEmpty())
+		})
+		It("loads an optional file that exists", func() {
+			filename := writeConfigFile(tempDir, "config.yaml", "jobs: 3\n")
+			Expect(config.LoadOptionalFile(filename)).To(Succeed())
+			Expect(config.GetInt("jobs")).To(Equal(3))
+		})
+	})
+	Describe("layering", func() {
+		var flags *flag.FlagSet
+		BeforeEach(func() {
+			filename := writeConfigFile(tempDir, "config.yaml", "backup:\n  jobs: 4\n  dir: /data/backups\n")
+			Expect(config.LoadFile(filename)).To(Succeed())
+			config.SetDefault("backup.jobs", 1)
+			config.SetDefault("backup.timeout", "30s")
+			config.LoadEnvironment("gpbackup")
+			flags = flag.NewFlagSet("gpbackup", flag.ContinueOnError)
+			flags.Int("backup-jobs", 1, "")
+			flags.String("backup-dir", "", "")
+			config.LoadFlags(flags)
+		})
+		It("uses defaults for keys that are not otherwise set", func() {
+			Expect(config.GetDuration("backup.timeout")).To(Equal(30 * time.Second))
+			Expect(config.Source("backup.timeout")).To(Equal(conf.SOURCE_DEFAULT))
+		})
+		It("overrides files with environment variables", func() {
+			operating.WithEnv(map[string]string{"GPBACKUP_BACKUP_JOBS": "6"}, func() {
+				Expect(config.GetInt("backup.jobs")).To(Equal(6))
+				Expect(config.Source("backup.jobs")).To(Equal(conf.SOURCE_ENVIRONMENT))
+			})
+		})
+		It("overrides environment variables with flags that were passed", func() {
+			Expect(flags.Parse([]string{"--backup-jobs=12"})).To(Succeed())
+			operating.WithEnv(map[string]string{"GPBACKUP_BACKUP_JOBS": "6", "GPBACKUP_BACKUP_DIR": "/env"}, func() {
+				Expect(config.GetInt("backup.jobs")).To(Equal(12))
+				Expect(config.Source("backup.jobs")).To(Equal(conf.SOURCE_FLAG))
+				Expect(config.GetString("backup.dir")).To(Equal("/env"))
+			})
+		})
+		It("does not use the defaults of flags that were not passed", func() {
+			Expect(flags.Parse([]string{})).To(Succeed())
+			Expect(config.GetString("backup.dir")).To(Equal("/data/backups"))
+		})
+		It("looks up keys that only exist in the environment", func() {
+			Expect(config.IsSet("plugin.path")).To(BeFalse())
+			operating.WithEnv(map[string]string{"GPBACKUP_PLUGIN_PATH": "/usr/local/plugin"}, func() {
+				Expect(config.IsSet("plugin.path")).To(BeTrue())
+				Expect(config.GetString("plugin.path")).To(Equal("/usr/local/plugin"))
+			})
+		})
+	})
+	Describe("typed getters", func() {
+		It("returns zero values for keys that are not set", func() {
+			Expect(config.GetString("missing")).To(Equal(""))
+			Expect(config.GetInt("missing")).To(Equal(0))
+			Expect(config.GetBool("missing")).To(BeFalse())
+			Expect(config.GetDuration("missing")).To(Equal(time.Duration(0)))
+			Expect(config.GetStringSlice("missing")).To(BeNil())
+			Expect(config.Source("missing")).To(Equal(""))
+		})
+		It("parses string values", func() {
+			config.SetDefault("jobs", " 5 ")
+			config.SetDefault("compress", "false")
+			config.SetDefault("schemas", "public, sales")
+			Expect(config.GetInt("jobs")).To(Equal(5))
+			Expect(config.GetBool("compress")).To(BeFalse())
+			Expect(config.GetStringSlice("schemas")).To(Equal([]string{"public", "sales"}))
+		})
+		It("returns an error for values of the wrong type", func() {
+			config.SetDefault("jobs", "many")
+			config.SetDefault("compress", 1)
+			config.SetDefault("timeout", "soon")
+			_, err := config.GetInt("jobs")
+			Expect(err).To(MatchError(`Invalid value "many" for configuration key jobs from default: expected an integer`))
+			_, err = config.GetBool("compress")
+			Expect(err).To(MatchError(`Invalid value "1" for configuration key compress from default: expected a boolean`))
+			_, err = config.GetDuration("timeout")
+			Expect(err).To(MatchError(`Invalid value "soon" for configuration key timeout from default: expected a duration`))
+		})
+	})
+})
@@ -0,0 +1,180 @@
+package conf
+
+/*
+ * This file contains functions for describing configuration with a struct
+ * schema, from which defaults are set and into which values are decoded.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * A schema is a struct whose fields are tagged with the configuration key they
+ * hold, e.g.
+ *
+ *   type BackupConfig struct {
+ *     Dbname  string        `conf:"dbname" required:"true"`
+ *     Jobs    int           `conf:"jobs" default:"1"`
+ *     Timeout time.Duration `conf:"timeout" default:"30s"`
+ *     Schemas []string      `conf:"include-schema"`
+ *     Plugin  PluginConfig  `conf:"plugin"`
+ *   }
+ *
+ * A field without a conf tag has the lowercased field name as its key, and a
+ * field tagged with conf:"-" is ignored.  The fields of a nested struct have
+ * keys prefixed with the key of the struct, e.g. "plugin.executablepath".
+ * Supported field types are strings, booleans, integers, floats,
+ * time.Duration, string slices, and nested structs of those.
+ */
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+type schemaField struct {
+	key          string
+	field        reflect.StructField
+	value        reflect.Value
+	defaultValue string
+	hasDefault   bool
+	required     bool
+}
+
+func schemaFields(prefix string, structValue reflect.Value) []schemaField {
+	fields := make([]schemaField, 0)
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		key := field.Tag.Get("conf")
+		if key == "-" {
+			continue
+		} else if key == "" {
+			key = field.Name
+		}
+		key = normalizeKey(prefix + key)
+		if field.Type.Kind() == reflect.Struct {
+			fields = append(fields, schemaFields(key+".", structValue.Field(i))...)
+			continue
+		}
+		defaultValue, hasDefault := field.Tag.Lookup("default")
+		fields = append(fields, schemaField{
+			key:          key,
+			field:        field,
+			value:        structValue.Field(i),
+			defaultValue: defaultValue,
+			hasDefault:   hasDefault,
+			required:     field.Tag.Get("required") == "true",
+		})
+	}
+	return fields
+}
+
+func schemaStruct(schema interface{}) (reflect.Value, error) {
+	value := reflect.ValueOf(schema)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, errors.Errorf("Configuration schema must be a non-nil pointer to a struct, got %T", schema)
+	}
+	return value.Elem(), nil
+}
+
+/*
+ * SetDefaults sets a default for each field in schema that has a default tag.
+ * As with values from environment variables, defaults are parsed when they
+ * are requested, so an invalid default is reported by Decode.
+ */
+func (config *Config) SetDefaults(schema interface{}) error {
+	structValue, err := schemaStruct(schema)
+	if err != nil {
+		return err
+	}
+	for _, field := range schemaFields("", structValue) {
+		if field.hasDefault {
+			config.SetDefault(field.key, field.defaultValue)
+		}
+	}
+	return nil
+}
+
+/*
+ * Decode sets each field in destination to the value of its key, leaving
+ * fields whose keys are not set unchanged, and validates that every required
+ * key is set.  All invalid and missing values are reported in one error, so
+ * that a user can fix them all at once.
+ */
+func (config *Config) Decode(destination interface{}) error {
+	structValue, err := schemaStruct(destination)
+	if err != nil {
+		return err
+	}
+	problems := make([]string, 0)
+	for _, field := range schemaFields("", structValue) {
+		if !config.IsSet(field.key) && field.hasDefault {
+			config.SetDefault(field.key, field.defaultValue)
+		}
+		if !config.IsSet(field.key) {
+			if field.required {
+				problems = append(problems, fmt.Sprintf("Required configuration key %s is not set", field.key))
+			}
+			continue
+		}
+		err := config.decodeField(field)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func (config *Config) decodeField(field schemaField) error {
+	value, source, _ := config.lookup(field.key)
+	switch {
+	case field.field.Type == durationType:
+		duration, err := config.GetDuration(field.key)
+		if err != nil {
+			return err
+		}
+		field.value.SetInt(int64(duration))
+	case field.field.Type.Kind() == reflect.String:
+		field.value.SetString(config.GetString(field.key))
+	case field.field.Type.Kind() == reflect.Bool:
+		result, err := config.GetBool(field.key)
+		if err != nil {
+			return err
+		}
+		field.value.SetBool(result)
+	case field.value.CanInt():
+		result, err := strconv.ParseInt(config.GetString(field.key), 10, field.field.Type.Bits())
+		if err != nil {
+			return invalidValueError(field.key, source, value, "an integer")
+		}
+		field.value.SetInt(result)
+	case field.value.CanUint():
+		result, err := strconv.ParseUint(config.GetString(field.key), 10, field.field.Type.Bits())
+		if err != nil {
+			return invalidValueError(field.key, source, value, "a non-negative integer")
+		}
+		field.value.SetUint(result)
+	case field.value.CanFloat():
+		result, err := strconv.ParseFloat(config.GetString(field.key), field.field.Type.Bits())
+		if err != nil {
+			return invalidValueError(field.key, source, value, "a number")
+		}
+		field.value.SetFloat(result)
+	case field.field.Type.Kind() == reflect.Slice && field.field.Type.Elem().Kind() == reflect.String:
+		field.value.Set(reflect.ValueOf(config.GetStringSlice(field.key)).Convert(field.field.Type))
+	default:
+		return errors.Errorf("Configuration key %s has unsupported type %s", field.key, field.field.Type)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package conf_test
+
+import (
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/conf"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type pluginConfig struct {
+	ExecutablePath string
+	Retries        uint8 `default:"3"`
+}
+
+type backupConfig struct {
+	Dbname   string        `conf:"dbname" required:"true"`
+	Jobs     int           `conf:"jobs" default:"1"`
+	Compress bool          `conf:"compress" default:"true"`
+	Ratio    float64       `conf:"ratio"`
+	Timeout  time.Duration `conf:"timeout" default:"30s"`
+	Schemas  []string      `conf:"include-schema"`
+	Plugin   pluginConfig  `conf:"plugin"`
+	Ignored  string        `conf:"-"`
+}
+
+var _ = Describe("conf schema tests", func() {
+	var config *conf.Config
+	BeforeEach(func() {
+		config = conf.New()
+	})
+
+	Describe("SetDefaults", func() {
+		It("sets defaults from the default tags of the schema", func() {
+			Expect(config.SetDefaults(&backupConfig{})).To(Succeed())
+			Expect(config.Keys()).To(Equal([]string{"compress", "jobs", "plugin.retries", "timeout"}))
+			Expect(config.GetDuration("timeout")).To(Equal(30 * time.Second))
+		})
+		It("returns an error if the schema is not a pointer to a struct", func() {
+			err := config.SetDefaults(backupConfig{})
+			Expect(err).To(MatchError("Configuration schema must be a non-nil pointer to a struct, got conf_test.backupConfig"))
+		})
+	})
+	Describe("Decode", func() {
+		It("decodes values of each supported type into the schema", func() {
+			config.SetDefault("dbname", "postgres")
+			config.SetDefault("jobs", 4)
+			config.SetDefault("compress", "false")
+			config.SetDefault("ratio", 0.5)
+			config.SetDefault("include-schema", []interface{}{"public", "sales"})
+			config.SetDefault("plugin.executablepath", "/usr/local/bin/plugin")
+			config.SetDefault("ignored", "value")
+			result := backupConfig{}
+			Expect(config.Decode(&result)).To(Succeed())
+			Expect(result).To(Equal(backupConfig{
+				Dbname:   "postgres",
+				Jobs:     4,
+				Compress: false,
+				Ratio:    0.5,
+				Timeout:  30 * time.Second,
+				Schemas:  []string{"public", "sales"},
+				Plugin:   pluginConfig{ExecutablePath: "/usr/local/bin/plugin", Retries: 3},
+			}))
+		})
+		It("decodes values from the environment", func() {
+			config.LoadEnvironment("GPBACKUP")
+			result := backupConfig{}
+			operating.WithEnv(map[string]string{"GPBACKUP_DBNAME": "testdb", "GPBACKUP_PLUGIN_RETRIES": "5", "GPBACKUP_INCLUDE_SCHEMA": "a,b"}, func() {
+				Expect(config.Decode(&result)).To(Succeed())
+			})
+			Expect(result.Dbname).To(Equal("testdb"))
+			Expect(result.Plugin.Retries).To(Equal(uint8(5)))
+			Expect(result.Schemas).To(Equal([]string{"a", "b"}))
+		})
+		It("leaves fields unchanged if their keys are not set", func() {
+			config.SetDefault("dbname", "postgres")
+			result := backupConfig{Ratio: 2.5}
+			Expect(config.Decode(&result)).To(Succeed())
+			Expect(result.Ratio).To(Equal(2.5))
+		})
+		It("reports every invalid or missing value", func() {
+			config.SetDefault("jobs", "many")
+			config.SetDefault("plugin.retries", 300)
+			err := config.Decode(&backupConfig{})
+			Expect(err).To(MatchError("Required configuration key dbname is not set\n" +
+				`Invalid value "many" for configuration key jobs from default: expected an integer` + "\n" +
+				`Invalid value "300" for configuration key plugin.retries from default: expected a non-negative integer`))
+		})
+		It("returns an error for unsupported field types", func() {
+			type badConfig struct {
+				Values map[string]string
+			}
+			config.SetDefault("values", "a=b")
+			err := config.Decode(&badConfig{})
+			Expect(err).To(MatchError("Configuration key values has unsupported type map[string]string"))
+		})
+	})
+})
@@ -0,0 +1,73 @@
+package confedit
+
+/*
+ * This file contains functions shared by the postgresql.conf and pg_hba.conf
+ * editors for reading configuration files and writing them back atomically.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+func readLines(filename string) ([]string, error) {
+	lines, err := iohelper.ReadLinesFromFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read configuration file %s", filename)
+	}
+	return lines, nil
+}
+
+/*
+ * The lines are written to a temporary file in the same directory, which is
+ * given the mode and ownership of the original file and synced to disk before
+ * being renamed over it, so that the server and other readers see either the
+ * old file or the new one and never a partial file.  If filename is a symbolic
+ * link, the file it points to is replaced and the link is kept.
+ */
+func writeLinesAtomically(filename string, lines []string) (err error) {
+	target, err := filepath.EvalSymlinks(filename)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to resolve configuration file %s", filename)
+	}
+	info, err := operating.System.Stat(target)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to stat configuration file %s", target)
+	}
+	tempFile, err := operating.System.TempFile(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "Unable to create temporary file for %s", target)
+	}
+	defer func() {
+		if err != nil {
+			_ = tempFile.Close()
+			_ = operating.System.Remove(tempFile.Name())
+		}
+	}()
+
+	contents := strings.Join(lines, "\n") + "\n"
+	if _, err = tempFile.WriteString(contents); err != nil {
+		return errors.Wrapf(err, "Unable to write temporary file for %s", target)
+	}
+	if err = tempFile.Sync(); err != nil {
+		return errors.Wrapf(err, "Unable to sync temporary file for %s", target)
+	}
+	if err = tempFile.Close(); err != nil {
+		return errors.Wrapf(err, "Unable to close temporary file for %s", target)
+	}
+	if err = operating.System.Chmod(tempFile.Name(), info.Mode().Perm()); err != nil {
+		return errors.Wrapf(err, "Unable to set permissions of temporary file for %s", target)
+	}
+	if err = copyOwnership(tempFile.Name(), info); err != nil {
+		return errors.Wrapf(err, "Unable to set ownership of temporary file for %s", target)
+	}
+	if err = os.Rename(tempFile.Name(), target); err != nil {
+		return errors.Wrapf(err, "Unable to replace configuration file %s", target)
+	}
+	return nil
+}
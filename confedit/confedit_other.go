@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package confedit
+
+import (
+	"os"
+)
+
+// File ownership is not available here, so only the mode is preserved
+func copyOwnership(filename string, original os.FileInfo) error {
+	return nil
+}
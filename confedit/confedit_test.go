@@ -0,0 +1,74 @@
+package confedit_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/greenplum-db/gp-common-go-libs/confedit"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestConfEdit(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "confedit tests")
+}
+
+var _ = BeforeSuite(func() {
+	testhelper.SetupTestLogger()
+})
+
+func writeConfFile(dir string, name string, contents string) string {
+	filename := filepath.Join(dir, name)
+	Expect(os.MkdirAll(filepath.Dir(filename), 0755)).To(Succeed())
+	Expect(os.WriteFile(filename, []byte(contents), 0600)).To(Succeed())
+	return filename
+}
+
+func readConfFile(filename string) string {
+	contents, err := os.ReadFile(filename)
+	Expect(err).ToNot(HaveOccurred())
+	return string(contents)
+}
+
+var _ = Describe("confedit tests", func() {
+	var dataDir string
+	BeforeEach(func() {
+		dataDir = GinkgoT().TempDir()
+	})
+
+	Describe("Save", func() {
+		It("keeps the mode of the file and leaves no temporary files", func() {
+			filename := writeConfFile(dataDir, "postgresql.conf", "port = 5432\n")
+			conf, err := confedit.LoadPostgresqlConf(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(conf.Set("port", "6000")).To(Succeed())
+
+			Expect(conf.Save()).To(Succeed())
+
+			info, err := os.Stat(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			entries, _ := os.ReadDir(dataDir)
+			Expect(entries).To(HaveLen(1))
+		})
+		It("replaces the target of a symbolic link and keeps the link", func() {
+			target := writeConfFile(dataDir, "conf/pg_hba.conf", "local all all trust\n")
+			link := filepath.Join(dataDir, "pg_hba.conf")
+			Expect(os.Symlink(target, link)).To(Succeed())
+			hba, err := confedit.LoadHBAConf(link)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hba.AddRule(confedit.HBARule{Type: "host", Databases: []string{"all"}, Users: []string{"gpadmin"}, Address: "10.0.0.0/8", Method: "trust"})).To(Succeed())
+
+			Expect(hba.Save()).To(Succeed())
+
+			info, err := os.Lstat(link)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode() & os.ModeSymlink).ToNot(BeZero())
+			Expect(readConfFile(target)).To(Equal("local all all trust\nhost all gpadmin 10.0.0.0/8 trust\n"))
+		})
+	})
+})
@@ -0,0 +1,30 @@
+//go:build linux || darwin
+
+package confedit
+
+import (
+	"os"
+	"syscall"
+)
+
+/*
+ * The temporary file is owned by the current user, so it is only changed if
+ * the original file has a different owner or group, e.g. when root edits a
+ * file owned by gpadmin.  Failing to preserve ownership is an error, as the
+ * server may not be able to read a file that has changed owners.
+ */
+func copyOwnership(filename string, original os.FileInfo) error {
+	originalStat, ok := original.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || (stat.Uid == originalStat.Uid && stat.Gid == originalStat.Gid) {
+		return nil
+	}
+	return os.Chown(filename, int(originalStat.Uid), int(originalStat.Gid))
+}
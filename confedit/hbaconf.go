@@ -0,0 +1,274 @@
+package confedit
+
+/*
+ * This file contains structs and functions related to reading and editing
+ * pg_hba.conf.
+ */
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	hbaTypes = map[string]bool{
+		"local": true, "host": true, "hostssl": true, "hostnossl": true, "hostgssenc": true, "hostnogssenc": true,
+	}
+	hbaMethods = map[string]bool{
+		"trust": true, "reject": true, "scram-sha-256": true, "md5": true, "password": true, "gss": true, "sspi": true,
+		"ident": true, "peer": true, "ldap": true, "radius": true, "cert": true, "pam": true, "bsd": true,
+	}
+)
+
+/*
+ * An HBARule is one record of pg_hba.conf.  Databases and Users hold the
+ * comma-separated entries of their fields as written, so keywords like "all"
+ * and "replication", group names starting with "+", and file references
+ * starting with "@" are kept as they are, and names that would otherwise be
+ * keywords must be quoted with double quotes.
+ *
+ * Address is empty for local rules.  It may be a CIDR address, a host name, or
+ * a keyword like "samenet", or an IP address with the netmask in Mask.
+ * Options holds the authentication options, e.g. "ldapserver=ldap.example.com".
+ */
+type HBARule struct {
+	Type      string
+	Databases []string
+	Users     []string
+	Address   string
+	Mask      string
+	Method    string
+	Options   []string
+}
+
+func (rule HBARule) Validate() error {
+	if !hbaTypes[rule.Type] {
+		return errors.Errorf("Invalid connection type %q", rule.Type)
+	}
+	if len(rule.Databases) == 0 || len(rule.Users) == 0 {
+		return errors.New("At least one database and one user must be given")
+	}
+	if rule.Type == "local" {
+		if rule.Address != "" || rule.Mask != "" {
+			return errors.New("An address cannot be given for local connections")
+		}
+	} else if err := validateHBAAddress(rule.Address, rule.Mask); err != nil {
+		return err
+	}
+	if !hbaMethods[rule.Method] {
+		return errors.Errorf("Invalid authentication method %q", rule.Method)
+	}
+	for _, option := range rule.Options {
+		if !strings.Contains(option, "=") {
+			return errors.Errorf("Invalid authentication option %q; options must be of the form name=value", option)
+		}
+	}
+	return nil
+}
+
+func validateHBAAddress(address string, mask string) error {
+	switch {
+	case address == "":
+		return errors.New("An address must be given for host connections")
+	case strings.Contains(address, "/"):
+		if _, _, err := net.ParseCIDR(address); err != nil {
+			return errors.Errorf("Invalid CIDR address %q", address)
+		}
+		if mask != "" {
+			return errors.New("A netmask cannot be given along with a CIDR address")
+		}
+	case net.ParseIP(address) != nil:
+		if net.ParseIP(mask) == nil {
+			return errors.Errorf("IP address %q must be given with a netmask or in CIDR notation", address)
+		}
+	case mask != "":
+		return errors.Errorf("A netmask cannot be given along with host name %q", address)
+	}
+	return nil
+}
+
+func (rule HBARule) String() string {
+	fields := []string{rule.Type, strings.Join(rule.Databases, ","), strings.Join(rule.Users, ",")}
+	if rule.Address != "" {
+		fields = append(fields, rule.Address)
+	}
+	if rule.Mask != "" {
+		fields = append(fields, rule.Mask)
+	}
+	fields = append(fields, rule.Method)
+	fields = append(fields, rule.Options...)
+	return strings.Join(fields, " ")
+}
+
+// Splits a line into fields at whitespace outside double quotes, stopping at a comment
+func splitHBAFields(line string) ([]string, error) {
+	fields := make([]string, 0)
+	var field strings.Builder
+	inQuotes := false
+	for _, char := range line {
+		switch {
+		case char == '"':
+			inQuotes = !inQuotes
+			field.WriteRune(char)
+		case inQuotes:
+			field.WriteRune(char)
+		case char == '#':
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+			}
+			return fields, nil
+		case char == ' ' || char == '\t':
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(char)
+		}
+	}
+	if inQuotes {
+		return nil, errors.New("unterminated quoted string")
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// Returns nil for blank lines and comments
+func parseHBALine(line string) (*HBARule, error) {
+	fields, err := splitHBAFields(line)
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	rule := &HBARule{Type: fields[0]}
+	minFields := 4
+	if rule.Type != "local" {
+		minFields = 5
+	}
+	if len(fields) < minFields {
+		return nil, errors.Errorf("expected at least %d fields, got %d", minFields, len(fields))
+	}
+	rule.Databases = strings.Split(fields[1], ",")
+	rule.Users = strings.Split(fields[2], ",")
+	rest := fields[3:]
+	if rule.Type != "local" {
+		rule.Address, rest = rest[0], rest[1:]
+		if net.ParseIP(rule.Address) != nil && len(rest) > 1 && net.ParseIP(rest[0]) != nil {
+			rule.Mask, rest = rest[0], rest[1:]
+		}
+	}
+	rule.Method, rule.Options = rest[0], rest[1:]
+	if len(rule.Options) == 0 {
+		rule.Options = nil
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+/*
+ * An HBAConf holds the rules in pg_hba.conf along with the rest of its lines,
+ * so that comments and formatting are kept when it is saved.  No changes are
+ * written until Save is called.
+ */
+type HBAConf struct {
+	filename string
+	lines    []string
+	rules    []HBARule
+	// The index in lines of each rule
+	ruleLines []int
+}
+
+func LoadHBAConf(filename string) (*HBAConf, error) {
+	lines, err := readLines(filename)
+	if err != nil {
+		return nil, err
+	}
+	hba := &HBAConf{filename: filename, lines: lines}
+	if err := hba.parse(); err != nil {
+		return nil, err
+	}
+	return hba, nil
+}
+
+func (hba *HBAConf) parse() error {
+	hba.rules = make([]HBARule, 0)
+	hba.ruleLines = make([]int, 0)
+	for i, line := range hba.lines {
+		rule, err := parseHBALine(line)
+		if err != nil {
+			return errors.Errorf("Invalid rule in %s on line %d: %v", hba.filename, i+1, err)
+		}
+		if rule != nil {
+			hba.rules = append(hba.rules, *rule)
+			hba.ruleLines = append(hba.ruleLines, i)
+		}
+	}
+	return nil
+}
+
+// Rules returns the rules in the order in which the server checks them
+func (hba *HBAConf) Rules() []HBARule {
+	return append([]HBARule{}, hba.rules...)
+}
+
+/*
+ * AddRule appends the rule to the end of the file if an identical rule is not
+ * already present.  The server uses the first rule that matches a connection,
+ * so the new rule only applies to connections that no existing rule matches.
+ */
+func (hba *HBAConf) AddRule(rule HBARule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	for _, existing := range hba.rules {
+		if existing.String() == rule.String() {
+			return nil
+		}
+	}
+	hba.lines = append(hba.lines, rule.String())
+	if err := hba.parse(); err != nil {
+		// e.g. a database name with a space that was not quoted
+		hba.lines = hba.lines[:len(hba.lines)-1]
+		_ = hba.parse()
+		return err
+	}
+	return nil
+}
+
+// RemoveRules removes every rule for which match returns true, and returns how many were removed
+func (hba *HBAConf) RemoveRules(match func(rule HBARule) bool) int {
+	remove := make(map[int]bool)
+	for i, rule := range hba.rules {
+		if match(rule) {
+			remove[hba.ruleLines[i]] = true
+		}
+	}
+	if len(remove) == 0 {
+		return 0
+	}
+	lines := make([]string, 0, len(hba.lines)-len(remove))
+	for i, line := range hba.lines {
+		if !remove[i] {
+			lines = append(lines, line)
+		}
+	}
+	hba.lines = lines
+	_ = hba.parse()
+	return len(remove)
+}
+
+// RemoveRule removes every rule identical to the given one, and returns whether any were removed
+func (hba *HBAConf) RemoveRule(rule HBARule) bool {
+	return hba.RemoveRules(func(existing HBARule) bool {
+		return existing.String() == rule.String()
+	}) > 0
+}
+
+func (hba *HBAConf) Save() error {
+	return writeLinesAtomically(hba.filename, hba.lines)
+}
@@ -0,0 +1,97 @@
+package confedit_test
+
+import (
+	"path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/confedit"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("confedit/hbaconf tests", func() {
+	var (
+		dataDir  string
+		filename string
+	)
+	BeforeEach(func() {
+		dataDir = GinkgoT().TempDir()
+		filename = writeConfFile(dataDir, "pg_hba.conf", `# TYPE  DATABASE  USER  ADDRESS  METHOD
+local    all       gpadmin                 ident
+host     all       gpadmin  127.0.0.1/32   trust
+host     "all",postgres  +admins  10.0.0.0 255.0.0.0  ldap ldapserver=ldap.example.com # ldap
+hostssl  replication  all   samenet        scram-sha-256
+`)
+	})
+	load := func() *confedit.HBAConf {
+		hba, err := confedit.LoadHBAConf(filename)
+		Expect(err).ToNot(HaveOccurred())
+		return hba
+	}
+
+	Describe("LoadHBAConf", func() {
+		It("parses each rule", func() {
+			Expect(load().Rules()).To(Equal([]confedit.HBARule{
+				{Type: "local", Databases: []string{"all"}, Users: []string{"gpadmin"}, Method: "ident"},
+				{Type: "host", Databases: []string{"all"}, Users: []string{"gpadmin"}, Address: "127.0.0.1/32", Method: "trust"},
+				{Type: "host", Databases: []string{`"all"`, "postgres"}, Users: []string{"+admins"}, Address: "10.0.0.0", Mask: "255.0.0.0", Method: "ldap", Options: []string{"ldapserver=ldap.example.com"}},
+				{Type: "hostssl", Databases: []string{"replication"}, Users: []string{"all"}, Address: "samenet", Method: "scram-sha-256"},
+			}))
+		})
+		It("returns an error for invalid rules", func() {
+			writeConfFile(dataDir, "pg_hba.conf", "local all all trust\nhost all all 10.0.0.1 trust\n")
+
+			_, err := confedit.LoadHBAConf(filename)
+
+			Expect(err).To(MatchError("Invalid rule in " + filename + ` on line 2: IP address "10.0.0.1" must be given with a netmask or in CIDR notation`))
+		})
+	})
+	Describe("HBARule.Validate", func() {
+		DescribeTable("returns an error for invalid rules",
+			func(rule confedit.HBARule, message string) {
+				Expect(rule.Validate()).To(MatchError(message))
+			},
+			Entry("invalid type", confedit.HBARule{Type: "remote", Databases: []string{"all"}, Users: []string{"all"}, Method: "trust"}, `Invalid connection type "remote"`),
+			Entry("no users", confedit.HBARule{Type: "local", Databases: []string{"all"}, Method: "trust"}, "At least one database and one user must be given"),
+			Entry("local with an address", confedit.HBARule{Type: "local", Databases: []string{"all"}, Users: []string{"all"}, Address: "samehost", Method: "trust"}, "An address cannot be given for local connections"),
+			Entry("host without an address", confedit.HBARule{Type: "host", Databases: []string{"all"}, Users: []string{"all"}, Method: "trust"}, "An address must be given for host connections"),
+			Entry("invalid CIDR address", confedit.HBARule{Type: "host", Databases: []string{"all"}, Users: []string{"all"}, Address: "10.0.0.0/40", Method: "trust"}, `Invalid CIDR address "10.0.0.0/40"`),
+			Entry("invalid method", confedit.HBARule{Type: "host", Databases: []string{"all"}, Users: []string{"all"}, Address: "::1/128", Method: "kerberos"}, `Invalid authentication method "kerberos"`),
+			Entry("invalid option", confedit.HBARule{Type: "host", Databases: []string{"all"}, Users: []string{"all"}, Address: "::1/128", Method: "ldap", Options: []string{"ldapserver"}}, `Invalid authentication option "ldapserver"; options must be of the form name=value`),
+		)
+	})
+	Describe("AddRule", func() {
+		It("appends rules that are not already present", func() {
+			hba := load()
+			rule := confedit.HBARule{Type: "host", Databases: []string{"all"}, Users: []string{"gpadmin"}, Address: "192.168.0.0/16", Method: "scram-sha-256"}
+
+			Expect(hba.AddRule(rule)).To(Succeed())
+			Expect(hba.AddRule(rule)).To(Succeed())
+			Expect(hba.AddRule(confedit.HBARule{Type: "host", Databases: []string{"all"}, Users: []string{"gpadmin"}, Address: "127.0.0.1/32", Method: "trust"})).To(Succeed())
+			Expect(hba.Save()).To(Succeed())
+
+			Expect(readConfFile(filename)).To(HaveSuffix("scram-sha-256\nhost all gpadmin 192.168.0.0/16 scram-sha-256\n"))
+			Expect(load().Rules()).To(HaveLen(5))
+		})
+		It("does not add invalid rules", func() {
+			hba := load()
+
+			err := hba.AddRule(confedit.HBARule{Type: "host", Databases: []string{"my db"}, Users: []string{"all"}, Address: "::1/128", Method: "trust"})
+
+			Expect(err).To(HaveOccurred())
+			Expect(hba.Rules()).To(HaveLen(4))
+		})
+	})
+	Describe("RemoveRules", func() {
+		It("removes matching rules and keeps other lines", func() {
+			hba := load()
+
+			Expect(hba.RemoveRules(func(rule confedit.HBARule) bool { return rule.Type == "host" })).To(Equal(2))
+			Expect(hba.RemoveRule(confedit.HBARule{Type: "local", Databases: []string{"all"}, Users: []string{"gpadmin"}, Method: "ident"})).To(BeTrue())
+			Expect(hba.RemoveRule(confedit.HBARule{Type: "local", Databases: []string{"all"}, Users: []string{"gpadmin"}, Method: "ident"})).To(BeFalse())
+			Expect(hba.Save()).To(Succeed())
+
+			Expect(readConfFile(filepath.Join(dataDir, "pg_hba.conf"))).To(Equal("# TYPE  DATABASE  USER  ADDRESS  METHOD\nhostssl  replication  all   samenet        scram-sha-256\n"))
+		})
+	})
+})
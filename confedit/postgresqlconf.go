@@ -0,0 +1,350 @@
+package confedit
+
+/*
+ * This file contains structs and functions related to reading and editing
+ * postgresql.conf and the files it includes.
+ */
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+// The server stops following includes at the same depth
+const MAX_INCLUDE_DEPTH = 10
+
+var (
+	settingPattern     = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_$]*(?:\.[A-Za-z_][A-Za-z0-9_$]*)*)\s*=?\s*`)
+	settingNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(?:\.[A-Za-z_][A-Za-z0-9_$]*)*$`)
+)
+
+/*
+ * A Setting is one line that sets a parameter.  Names are lower-cased, as the
+ * server treats them case-insensitively, and Value has any quoting removed.
+ * Line is 1-based.
+ */
+type Setting struct {
+	Name     string
+	Value    string
+	Filename string
+	Line     int
+}
+
+type confFile struct {
+	filename string
+	lines    []string
+	modified bool
+	// The files included by the include directive on each line, in order
+	includes map[int][]*confFile
+}
+
+/*
+ * A PostgresqlConf holds postgresql.conf and every file it includes, directly
+ * or indirectly, with include, include_if_exists, or include_dir.  As on the
+ * server, a parameter set more than once takes the value that is processed
+ * last, and the files included by a directive are processed at the position
+ * of the directive.
+ *
+ * Edits change the lines setting each parameter and leave all other lines,
+ * including comments, unchanged.  No files are written until Save is called.
+ */
+type PostgresqlConf struct {
+	main *confFile
+}
+
+// A parsedSetting is a parameter or include directive, with any comment after it
+type parsedSetting struct {
+	name    string
+	value   string
+	comment string
+}
+
+func LoadPostgresqlConf(filename string) (*PostgresqlConf, error) {
+	main, err := loadConfFile(filename, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresqlConf{main: main}, nil
+}
+
+func loadConfFile(filename string, depth int) (*confFile, error) {
+	if depth > MAX_INCLUDE_DEPTH {
+		return nil, errors.Errorf("Unable to include configuration file %s: maximum nesting depth exceeded", filename)
+	}
+	lines, err := readLines(filename)
+	if err != nil {
+		return nil, err
+	}
+	file := &confFile{filename: filename, lines: lines, includes: make(map[int][]*confFile)}
+	for i, line := range lines {
+		setting, err := parseSettingLine(line)
+		if err != nil {
+			return nil, errors.Errorf("Syntax error in %s on line %d: %v", filename, i+1, err)
+		}
+		if setting == nil {
+			continue
+		}
+		filenames, err := resolveInclude(filename, setting.name, setting.value)
+		if err != nil {
+			return nil, errors.Errorf("Unable to process %s on line %d of %s: %v", setting.name, i+1, filename, err)
+		}
+		for _, includeName := range filenames {
+			included, err := loadConfFile(includeName, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			file.includes[i] = append(file.includes[i], included)
+		}
+	}
+	return file, nil
+}
+
+/*
+ * Returns the files included by an include directive, or nil if name is a
+ * parameter.  Relative paths are relative to the directory of the including
+ * file, and include_dir includes the files in the directory ending in ".conf"
+ * that do not start with ".", in order of name.
+ */
+func resolveInclude(filename string, name string, value string) ([]string, error) {
+	if !isIncludeDirective(name) {
+		return nil, nil
+	}
+	path := value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(filename), path)
+	}
+	switch name {
+	case "include":
+		return []string{path}, nil
+	case "include_if_exists":
+		if _, err := operating.System.Stat(path); operating.System.IsNotExist(err) {
+			return nil, nil
+		}
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	filenames := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && strings.HasSuffix(entry.Name(), ".conf") {
+			filenames = append(filenames, filepath.Join(path, entry.Name()))
+		}
+	}
+	return filenames, nil
+}
+
+/*
+ * Returns nil for blank lines and comments.  Values may be quoted with single
+ * quotes, in which case a quote is escaped by doubling it or with a backslash,
+ * or unquoted, in which case they end at whitespace or a comment.
+ */
+func parseSettingLine(line string) (*parsedSetting, error) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return nil, nil
+	}
+	match := settingPattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil, errors.Errorf("invalid parameter name in %q", trimmed)
+	}
+	setting := &parsedSetting{name: strings.ToLower(match[1])}
+	rest := line[len(match[0]):]
+	if strings.HasPrefix(rest, "'") {
+		value, length, err := parseQuotedValue(rest)
+		if err != nil {
+			return nil, err
+		}
+		setting.value = value
+		rest = rest[length:]
+	} else {
+		end := strings.IndexAny(rest, " \t#")
+		if end == -1 {
+			end = len(rest)
+		}
+		setting.value = rest[:end]
+		rest = rest[end:]
+	}
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil, errors.Errorf("unexpected %q after value of %s", rest, setting.name)
+	}
+	setting.comment = rest
+	return setting, nil
+}
+
+// Returns the unquoted value and the length of the quoted value in str
+func parseQuotedValue(str string) (string, int, error) {
+	var value strings.Builder
+	for i := 1; i < len(str); i++ {
+		switch str[i] {
+		case '\'':
+			if i+1 < len(str) && str[i+1] == '\'' {
+				value.WriteByte('\'')
+				i++
+				continue
+			}
+			return value.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(str) {
+				i++
+				switch str[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case 't':
+					value.WriteByte('\t')
+				case 'r':
+					value.WriteByte('\r')
+				default:
+					value.WriteByte(str[i])
+				}
+				continue
+			}
+		}
+		value.WriteByte(str[i])
+	}
+	return "", 0, errors.New("unterminated quoted string")
+}
+
+func quoteValue(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+type settingLocation struct {
+	file  *confFile
+	index int
+}
+
+// Calls fn for each setting in the order in which the server processes them
+func (file *confFile) walk(fn func(setting Setting, location settingLocation)) {
+	for i, line := range file.lines {
+		parsed, _ := parseSettingLine(line)
+		if parsed == nil {
+			continue
+		}
+		if isIncludeDirective(parsed.name) {
+			for _, included := range file.includes[i] {
+				included.walk(fn)
+			}
+			continue
+		}
+		fn(Setting{Name: parsed.name, Value: parsed.value, Filename: file.filename, Line: i + 1}, settingLocation{file: file, index: i})
+	}
+}
+
+func isIncludeDirective(name string) bool {
+	return name == "include" || name == "include_if_exists" || name == "include_dir"
+}
+
+// Get returns the setting that takes effect for the named parameter
+func (conf *PostgresqlConf) Get(name string) (Setting, bool) {
+	name = strings.ToLower(name)
+	var result Setting
+	found := false
+	conf.main.walk(func(setting Setting, _ settingLocation) {
+		if setting.Name == name {
+			result, found = setting, true
+		}
+	})
+	return result, found
+}
+
+// Settings returns the setting that takes effect for each parameter, in order of name
+func (conf *PostgresqlConf) Settings() []Setting {
+	byName := make(map[string]Setting)
+	conf.main.walk(func(setting Setting, _ settingLocation) {
+		byName[setting.Name] = setting
+	})
+	settings := make([]Setting, 0, len(byName))
+	for _, setting := range byName {
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Name < settings[j].Name
+	})
+	return settings
+}
+
+/*
+ * Set changes the line that sets the named parameter's effective value, in
+ * whichever file it is in, keeping any comment at the end of the line.  If the
+ * parameter is not set, a line is added to the end of postgresql.conf.  The
+ * value is always quoted, which the server accepts for parameters of any type.
+ */
+func (conf *PostgresqlConf) Set(name string, value string) error {
+	if !settingNamePattern.MatchString(name) || isIncludeDirective(strings.ToLower(name)) {
+		return errors.Errorf("Invalid parameter name %q", name)
+	}
+	name = strings.ToLower(name)
+	var last *settingLocation
+	conf.main.walk(func(setting Setting, location settingLocation) {
+		if setting.Name == name {
+			last = &location
+		}
+	})
+	line := fmt.Sprintf("%s = %s", name, quoteValue(value))
+	if last == nil {
+		conf.main.lines = append(conf.main.lines, line)
+		conf.main.modified = true
+		return nil
+	}
+	parsed, _ := parseSettingLine(last.file.lines[last.index])
+	if parsed.comment != "" {
+		line += "\t" + parsed.comment
+	}
+	last.file.lines[last.index] = line
+	last.file.modified = true
+	return nil
+}
+
+/*
+ * Unset comments out every line that sets the named parameter, in every file,
+ * so that the server uses the parameter's default value.  It returns false if
+ * the parameter was not set.
+ */
+func (conf *PostgresqlConf) Unset(name string) bool {
+	name = strings.ToLower(name)
+	found := false
+	conf.main.walk(func(setting Setting, location settingLocation) {
+		if setting.Name == name {
+			location.file.lines[location.index] = "#" + location.file.lines[location.index]
+			location.file.modified = true
+			found = true
+		}
+	})
+	return found
+}
+
+// Save writes every file that has been edited
+func (conf *PostgresqlConf) Save() error {
+	var saveErr error
+	saved := make(map[*confFile]bool)
+	var save func(file *confFile)
+	save = func(file *confFile) {
+		if saveErr != nil || saved[file] {
+			return
+		}
+		saved[file] = true
+		if file.modified {
+			if saveErr = writeLinesAtomically(file.filename, file.lines); saveErr == nil {
+				file.modified = false
+			}
+		}
+		for i := range file.lines {
+			for _, included := range file.includes[i] {
+				save(included)
+			}
+		}
+	}
+	save(conf.main)
+	return saveErr
+}
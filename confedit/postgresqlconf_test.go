@@ -0,0 +1,130 @@
+package confedit_test
+
+import (
+	"path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/confedit"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("confedit/postgresqlconf tests", func() {
+	var (
+		dataDir  string
+		filename string
+	)
+	BeforeEach(func() {
+		dataDir = GinkgoT().TempDir()
+		filename = filepath.Join(dataDir, "postgresql.conf")
+	})
+	load := func() *confedit.PostgresqlConf {
+		conf, err := confedit.LoadPostgresqlConf(filename)
+		Expect(err).ToNot(HaveOccurred())
+		return conf
+	}
+	get := func(conf *confedit.PostgresqlConf, name string) confedit.Setting {
+		setting, found := conf.Get(name)
+		Expect(found).To(BeTrue())
+		return setting
+	}
+
+	Describe("LoadPostgresqlConf", func() {
+		It("parses quoted and unquoted values", func() {
+			writeConfFile(dataDir, "postgresql.conf", `# comment
+port = 5432		# the port
+Shared_Buffers 128MB
+log_line_prefix = 'it''s %m \'here\''
+#max_connections = 100
+`)
+			conf := load()
+
+			Expect(conf.Settings()).To(Equal([]confedit.Setting{
+				{Name: "log_line_prefix", Value: "it's %m 'here'", Filename: filename, Line: 4},
+				{Name: "port", Value: "5432", Filename: filename, Line: 2},
+				{Name: "shared_buffers", Value: "128MB", Filename: filename, Line: 3},
+			}))
+			_, found := conf.Get("max_connections")
+			Expect(found).To(BeFalse())
+		})
+		It("follows includes in the order the server processes them", func() {
+			writeConfFile(dataDir, "postgresql.conf", "port = 5432\ninclude 'tuning.conf'\ninclude_dir 'conf.d'\ninclude_if_exists 'missing.conf'\nwork_mem = 32MB\n")
+			tuningFile := writeConfFile(dataDir, "tuning.conf", "port = 6000\nwork_mem = 64MB\n")
+			writeConfFile(dataDir, "conf.d/01.conf", "shared_buffers = 1GB\n")
+			overrideFile := writeConfFile(dataDir, "conf.d/02.conf", "shared_buffers = 2GB\n")
+			writeConfFile(dataDir, "conf.d/.hidden.conf", "shared_buffers = 3GB\n")
+			conf := load()
+
+			Expect(get(conf, "port")).To(Equal(confedit.Setting{Name: "port", Value: "6000", Filename: tuningFile, Line: 1}))
+			Expect(get(conf, "shared_buffers")).To(Equal(confedit.Setting{Name: "shared_buffers", Value: "2GB", Filename: overrideFile, Line: 1}))
+			Expect(get(conf, "work_mem")).To(Equal(confedit.Setting{Name: "work_mem", Value: "32MB", Filename: filename, Line: 5}))
+		})
+		It("returns an error for a missing include file", func() {
+			writeConfFile(dataDir, "postgresql.conf", "include 'missing.conf'\n")
+
+			_, err := confedit.LoadPostgresqlConf(filename)
+
+			Expect(err).To(MatchError(HavePrefix("Unable to read configuration file " + filepath.Join(dataDir, "missing.conf"))))
+		})
+		It("returns an error for files that include themselves", func() {
+			writeConfFile(dataDir, "postgresql.conf", "include 'postgresql.conf'\n")
+
+			_, err := confedit.LoadPostgresqlConf(filename)
+
+			Expect(err).To(MatchError(ContainSubstring("maximum nesting depth exceeded")))
+		})
+		It("returns an error for invalid lines", func() {
+			writeConfFile(dataDir, "postgresql.conf", "port = 5432\nlog_line_prefix = 'unterminated\n")
+
+			_, err := confedit.LoadPostgresqlConf(filename)
+
+			Expect(err).To(MatchError("Syntax error in " + filename + " on line 2: unterminated quoted string"))
+		})
+	})
+	Describe("Set", func() {
+		It("changes the effective setting in place and keeps its comment", func() {
+			writeConfFile(dataDir, "postgresql.conf", "port = 5432\ninclude 'tuning.conf'\n")
+			tuningFile := writeConfFile(dataDir, "tuning.conf", "# tuning\nwork_mem = 32MB # per sort\n")
+			conf := load()
+
+			Expect(conf.Set("WORK_MEM", "64MB")).To(Succeed())
+			Expect(conf.Save()).To(Succeed())
+
+			Expect(readConfFile(filename)).To(Equal("port = 5432\ninclude 'tuning.conf'\n"))
+			Expect(readConfFile(tuningFile)).To(Equal("# tuning\nwork_mem = '64MB'\t# per sort\n"))
+		})
+		It("appends settings that are not set and quotes values", func() {
+			writeConfFile(dataDir, "postgresql.conf", "port = 5432\n#log_line_prefix = ''\n")
+			conf := load()
+
+			Expect(conf.Set("log_line_prefix", `%m 'gp' \`)).To(Succeed())
+			Expect(conf.Save()).To(Succeed())
+
+			Expect(readConfFile(filename)).To(Equal("port = 5432\n#log_line_prefix = ''\nlog_line_prefix = '%m ''gp'' \\\\'\n"))
+			Expect(get(load(), "log_line_prefix")).To(HaveField("Value", `%m 'gp' \`))
+		})
+		It("rejects invalid names", func() {
+			writeConfFile(dataDir, "postgresql.conf", "")
+			conf := load()
+
+			Expect(conf.Set("port = 1; x", "1")).To(MatchError(`Invalid parameter name "port = 1; x"`))
+			Expect(conf.Set("include", "other.conf")).To(MatchError(`Invalid parameter name "include"`))
+		})
+	})
+	Describe("Unset", func() {
+		It("comments out every line setting the parameter", func() {
+			writeConfFile(dataDir, "postgresql.conf", "port = 5432\ninclude 'tuning.conf'\nport = 6000\n")
+			tuningFile := writeConfFile(dataDir, "tuning.conf", "port = 7000\n")
+			conf := load()
+
+			Expect(conf.Unset("port")).To(BeTrue())
+			Expect(conf.Unset("work_mem")).To(BeFalse())
+			Expect(conf.Save()).To(Succeed())
+
+			Expect(readConfFile(filename)).To(Equal("#port = 5432\ninclude 'tuning.conf'\n#port = 6000\n"))
+			Expect(readConfFile(tuningFile)).To(Equal("#port = 7000\n"))
+			_, found := load().Get("port")
+			Expect(found).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,25 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to deciding how long to
+ * wait between attempts to connect to the database.  The policies are those
+ * of the retry package, which ConnectWithContext uses to retry connections;
+ * they are aliased here so that callers need not import it.
+ */
+
+import (
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/retry"
+)
+
+type BackoffPolicy = retry.Policy
+
+type ConstantBackoff = retry.Constant
+
+type ExponentialBackoff = retry.Exponential
+
+// BackoffSchedule returns the delays before each of attempts 2 through maxAttempts
+func BackoffSchedule(policy BackoffPolicy, maxAttempts int) []time.Duration {
+	return retry.Schedule(policy, maxAttempts)
+}
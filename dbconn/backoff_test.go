@@ -0,0 +1,30 @@
+package dbconn_test
+
+import (
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/backoff tests", func() {
+	DescribeTable("BackoffSchedule",
+		func(policy dbconn.BackoffPolicy, expected []time.Duration) {
+			Expect(dbconn.BackoffSchedule(policy, 5)).To(Equal(expected))
+		},
+		Entry("waits the same interval before every retry", dbconn.ConstantBackoff{Interval: time.Second},
+			[]time.Duration{time.Second, time.Second, time.Second, time.Second}),
+		Entry("doubles the delay by default", dbconn.ExponentialBackoff{Initial: time.Second},
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}),
+		Entry("uses a custom multiplier", dbconn.ExponentialBackoff{Initial: 100 * time.Millisecond, Multiplier: 1.5},
+			[]time.Duration{100 * time.Millisecond, 150 * time.Millisecond, 225 * time.Millisecond, 337500 * time.Microsecond}),
+		Entry("caps the delay at Max", dbconn.ExponentialBackoff{Initial: time.Second, Max: 3 * time.Second},
+			[]time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}),
+	)
+	It("never delays the first attempt", func() {
+		Expect(dbconn.ConstantBackoff{Interval: time.Second}.Delay(1)).To(Equal(time.Duration(0)))
+		Expect(dbconn.ExponentialBackoff{Initial: time.Second}.Delay(1)).To(Equal(time.Duration(0)))
+	})
+})
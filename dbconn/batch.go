@@ -0,0 +1,202 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to executing many
+ * statements on a connection in a single round trip.
+ */
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/pkg/errors"
+)
+
+type BatchStatement struct {
+	Query string
+	Args  []interface{}
+}
+
+type BatchResult struct {
+	RowsAffected int64
+	Err          error
+}
+
+var ErrStatementNotExecuted = errors.New("Statement was not executed because another statement in the batch failed")
+
+/*
+ * A batchEncodingError is returned when the arguments of a statement cannot be
+ * encoded for a pipelined batch.  Arguments are encoded before anything is
+ * sent, so no statement in the batch has been executed.
+ */
+type batchEncodingError struct {
+	statementNum int
+	err          error
+}
+
+func (err *batchEncodingError) Error() string {
+	return err.err.Error()
+}
+
+// errNotPipelined is returned when the connection cannot send a pipelined batch and statements must be run one at a time
+var errNotPipelined = errors.New("Connection does not support pipelined batches")
+
+/*
+ * ExecBatch executes statements in order on the given connection and returns
+ * one BatchResult per statement.  Execution stops at the first statement that
+ * fails; that statement's result holds its error, every later statement's
+ * result holds ErrStatementNotExecuted, and the returned error identifies the
+ * failed statement.  If a statement's arguments cannot be encoded for a
+ * pipelined batch, the batch is not sent at all, so every other statement's
+ * result holds ErrStatementNotExecuted.
+ *
+ * Outside a transaction, all statements are sent in a single round trip, and
+ * the server runs them as one implicit transaction, so if any statement fails
+ * none of the statements take effect, and the results of the statements
+ * before it report 0 rows affected.  This also means that statements that
+ * cannot run in a transaction block, such as VACUUM, cannot be batched.
+ *
+ * Inside a transaction, or with a driver other than pgx, the statements are
+ * executed one at a time instead, and statements before a failed statement are
+ * not undone unless the caller rolls back the transaction.
+ */
+func (dbconn *DBConn) ExecBatch(statements []BatchStatement, whichConn ...int) (results []BatchResult, err error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if len(statements) == 0 {
+		return []BatchResult{}, nil
+	}
+	dbconn.tracker.startQuery(connNum, fmt.Sprintf("<batch of %d statements> %s", len(statements), statements[0].Query))
+	defer func() { dbconn.tracker.finishQuery(connNum, err) }()
+
+	results = make([]BatchResult, len(statements))
+	numExecuted := 0
+	if dbconn.Tx[connNum] == nil {
+		numExecuted, err = dbconn.execPipelinedBatch(connNum, statements, results)
+	}
+	if dbconn.Tx[connNum] != nil || err == errNotPipelined {
+		numExecuted, err = dbconn.execSequentialBatch(connNum, statements, results)
+	}
+	var encodingErr *batchEncodingError
+	if errors.As(err, &encodingErr) {
+		for i := range results {
+			results[i].Err = ErrStatementNotExecuted
+		}
+		results[encodingErr.statementNum].Err = encodingErr.err
+		return results, errors.Errorf("Statement %d of %d in batch failed: %v", encodingErr.statementNum+1, len(statements), encodingErr.err)
+	}
+	if err != nil {
+		if numExecuted >= len(statements) {
+			return results, err
+		}
+		results[numExecuted].Err = err
+		for i := numExecuted + 1; i < len(statements); i++ {
+			results[i].Err = ErrStatementNotExecuted
+		}
+		return results, errors.Errorf("Statement %d of %d in batch failed: %v", numExecuted+1, len(statements), err)
+	}
+	return results, nil
+}
+
+func (dbconn *DBConn) execSequentialBatch(connNum int, statements []BatchStatement, results []BatchResult) (int, error) {
+	for i, statement := range statements {
+		var result interface{ RowsAffected() (int64, error) }
+		var err error
+		if dbconn.Tx[connNum] != nil {
+			result, err = dbconn.Tx[connNum].Exec(statement.Query, statement.Args...)
+		} else {
+			result, err = dbconn.ConnPool[connNum].Exec(statement.Query, statement.Args...)
+		}
+		if err != nil {
+			return i, err
+		}
+		results[i].RowsAffected, _ = result.RowsAffected()
+	}
+	return len(statements), nil
+}
+
+/*
+ * Statements are sent without being described first, so that a statement may
+ * depend on an object created by an earlier statement in the same batch, and
+ * arguments are sent in text format with the type inferred from their Go type.
+ * The arguments of every statement are encoded before the batch is sent, so
+ * that an argument that cannot be encoded fails the batch without executing
+ * any of it.
+ */
+func (dbconn *DBConn) execPipelinedBatch(connNum int, statements []BatchStatement, results []BatchResult) (int, error) {
+	sqlConn, err := dbconn.ConnPool[connNum].Conn(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	defer sqlConn.Close()
+	numExecuted := 0
+	err = sqlConn.Raw(func(driverConn interface{}) error {
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errNotPipelined
+		}
+		connInfo := pgxConn.Conn().ConnInfo()
+		values := make([][][]byte, len(statements))
+		oids := make([][]uint32, len(statements))
+		for i, statement := range statements {
+			var encodeErr error
+			values[i], oids[i], encodeErr = encodeBatchArgs(connInfo, statement.Args)
+			if encodeErr != nil {
+				return &batchEncodingError{statementNum: i, err: encodeErr}
+			}
+		}
+		batch := &pgconn.Batch{}
+		for i, statement := range statements {
+			batch.ExecParams(statement.Query, values[i], oids[i], nil, nil)
+		}
+		resultReader := pgxConn.Conn().PgConn().ExecBatch(context.Background(), batch)
+		for numExecuted < len(statements) && resultReader.NextResult() {
+			tag, err := resultReader.ResultReader().Close()
+			if err != nil {
+				_ = resultReader.Close()
+				return err
+			}
+			results[numExecuted].RowsAffected = tag.RowsAffected()
+			numExecuted++
+		}
+		return resultReader.Close()
+	})
+	if err != nil {
+		// The failure rolled back the implicit transaction, undoing every statement executed before it
+		for i := 0; i < numExecuted; i++ {
+			results[i].RowsAffected = 0
+		}
+	}
+	return numExecuted, err
+}
+
+func encodeBatchArgs(connInfo *pgtype.ConnInfo, args []interface{}) ([][]byte, []uint32, error) {
+	values := make([][]byte, len(args))
+	oids := make([]uint32, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			continue
+		}
+		dataType, ok := connInfo.DataTypeForValue(arg)
+		if !ok {
+			return nil, nil, errors.Errorf("Cannot encode argument %d of type %T", i+1, arg)
+		}
+		value := pgtype.NewValue(dataType.Value)
+		if err := value.Set(arg); err != nil {
+			return nil, nil, errors.Wrapf(err, "Cannot encode argument %d", i+1)
+		}
+		encoder, ok := value.(pgtype.TextEncoder)
+		if !ok {
+			return nil, nil, errors.Errorf("Cannot encode argument %d of type %T as text", i+1, arg)
+		}
+		encoded, err := encoder.EncodeText(connInfo, nil)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "Cannot encode argument %d", i+1)
+		}
+		values[i] = encoded
+		oids[i] = dataType.OID
+	}
+	return values, oids, nil
+}
@@ -0,0 +1,161 @@
+package dbconn_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * serveFakeBackend answers a single pgx connection as a server would answer
+ * pipelined statements, reporting 2 rows affected by each statement and
+ * failing the first statement of each batch whose query contains failOn, so
+ * that pipelined batches can be tested without a database.
+ */
+func serveFakeBackend(listener net.Listener, failOn string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	backend := pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn)
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	_ = backend.Send(&pgproto3.AuthenticationOk{})
+	_ = backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	_ = backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	failed := false
+	query := ""
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		var reply pgproto3.BackendMessage
+		switch msg := msg.(type) {
+		case *pgproto3.Parse:
+			query = msg.Query
+			if !failed && strings.Contains(query, failOn) {
+				failed = true
+				_ = backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "42P01", Message: `relation "bar" does not exist`})
+				continue
+			}
+			reply = &pgproto3.ParseComplete{}
+		case *pgproto3.Bind:
+			reply = &pgproto3.BindComplete{}
+		case *pgproto3.Describe:
+			reply = &pgproto3.NoData{}
+		case *pgproto3.Execute:
+			reply = &pgproto3.CommandComplete{CommandTag: []byte(fmt.Sprintf("%s 2", strings.Fields(query)[0]))}
+		case *pgproto3.Sync:
+			failed = false
+			_ = backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			continue
+		case *pgproto3.Terminate:
+			return
+		}
+		if reply != nil && !failed {
+			_ = backend.Send(reply)
+		}
+	}
+}
+
+var _ = Describe("dbconn/batch tests", func() {
+	statements := []dbconn.BatchStatement{
+		{Query: "CREATE TABLE foo(i int)"},
+		{Query: "INSERT INTO foo VALUES ($1), ($2)", Args: []interface{}{1, 2}},
+		{Query: "COMMENT ON TABLE foo IS 'bar'"},
+	}
+	Describe("DBConn.ExecBatch", func() {
+		It("returns a result for each statement", func() {
+			mock.ExpectExec("CREATE TABLE foo").WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectExec("INSERT INTO foo").WithArgs(1, 2).WillReturnResult(testhelper.TestResult{Rows: 2})
+			mock.ExpectExec("COMMENT ON TABLE foo").WillReturnResult(testhelper.TestResult{Rows: 0})
+
+			results, err := connection.ExecBatch(statements)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]dbconn.BatchResult{{RowsAffected: 0}, {RowsAffected: 2}, {RowsAffected: 0}}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("stops at the first failed statement", func() {
+			mock.ExpectExec("CREATE TABLE foo").WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectExec("INSERT INTO foo").WithArgs(1, 2).WillReturnError(errors.New(`relation "foo" does not exist`))
+
+			results, err := connection.ExecBatch(statements)
+
+			Expect(err).To(MatchError(`Statement 2 of 3 in batch failed: relation "foo" does not exist`))
+			Expect(results).To(HaveLen(3))
+			Expect(results[0].Err).ToNot(HaveOccurred())
+			Expect(results[1].Err).To(MatchError(`relation "foo" does not exist`))
+			Expect(results[2].Err).To(Equal(dbconn.ErrStatementNotExecuted))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("executes the statements in the transaction in progress", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+			mock.ExpectExec("CREATE TABLE foo").WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectCommit()
+
+			_, err := connection.ExecBatch(statements[:1])
+			connection.MustCommit()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		Context("over a pgx connection", func() {
+			var pgxConnection *dbconn.DBConn
+			connectToFakeBackend := func(failOn string) {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).ToNot(HaveOccurred())
+				DeferCleanup(listener.Close)
+				go serveFakeBackend(listener, failOn)
+				config, err := pgx.ParseConfig(fmt.Sprintf("host=127.0.0.1 port=%d user=gpadmin database=testdb sslmode=disable", listener.Addr().(*net.TCPAddr).Port))
+				Expect(err).ToNot(HaveOccurred())
+				db := sqlx.NewDb(stdlib.OpenDB(*config), "pgx")
+				DeferCleanup(db.Close)
+				pgxConnection = &dbconn.DBConn{ConnPool: []*sqlx.DB{db}, Tx: make([]*sqlx.Tx, 1), NumConns: 1}
+			}
+			pipelinedStatements := []dbconn.BatchStatement{
+				{Query: "INSERT INTO foo VALUES (1), (2)"},
+				{Query: "UPDATE bar SET i = 3"},
+				{Query: "DELETE FROM foo"},
+			}
+			It("returns the rows affected by each statement", func() {
+				connectToFakeBackend("no statement matches this")
+
+				results, err := pgxConnection.ExecBatch(pipelinedStatements)
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(results).To(Equal([]dbconn.BatchResult{{RowsAffected: 2}, {RowsAffected: 2}, {RowsAffected: 2}}))
+			})
+			It("reports no rows affected by statements rolled back by a later failure", func() {
+				connectToFakeBackend("UPDATE bar")
+
+				results, err := pgxConnection.ExecBatch(pipelinedStatements)
+
+				Expect(err).To(MatchError(ContainSubstring(`Statement 2 of 3 in batch failed: ERROR: relation "bar" does not exist`)))
+				Expect(results[0]).To(Equal(dbconn.BatchResult{RowsAffected: 0}))
+				Expect(results[1].Err).To(MatchError(ContainSubstring(`relation "bar" does not exist`)))
+				Expect(results[2].Err).To(Equal(dbconn.ErrStatementNotExecuted))
+			})
+		})
+		It("does nothing for an empty batch", func() {
+			results, err := connection.ExecBatch([]dbconn.BatchStatement{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(BeEmpty())
+		})
+	})
+})
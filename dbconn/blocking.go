@@ -0,0 +1,198 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to finding the sessions
+ * that are waiting on locks held by other sessions, e.g. to explain why a
+ * utility appears to hang.
+ */
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+/*
+ * A BlockedSession describes one session waiting for a lock and one session
+ * holding a conflicting lock.  A session blocked by several sessions appears
+ * once for each of them.  PIDs are those of the coordinator backends, and
+ * WaitSeconds is the time since the blocked session's query started.
+ */
+type BlockedSession struct {
+	BlockedPID    int     `db:"blockedpid"`
+	BlockedUser   string  `db:"blockeduser"`
+	BlockedQuery  string  `db:"blockedquery"`
+	WaitSeconds   float64 `db:"waitseconds"`
+	BlockingPID   int     `db:"blockingpid"`
+	BlockingUser  string  `db:"blockinguser"`
+	BlockingQuery string  `db:"blockingquery"`
+	LockType      string  `db:"locktype"`
+	LockMode      string  `db:"lockmode"`
+	Relation      string  `db:"relation"`
+}
+
+func (session BlockedSession) String() string {
+	lock := fmt.Sprintf("%s lock", session.LockMode)
+	if session.Relation != "" {
+		lock += fmt.Sprintf(" on %s", session.Relation)
+	}
+	return fmt.Sprintf("pid %d has been waiting %.0fs for %s held by pid %d (%s)",
+		session.BlockedPID, session.WaitSeconds, lock, session.BlockingPID, querySnippet(session.BlockingQuery))
+}
+
+/*
+ * Locks are matched on every lock tag column and on the segment, and sessions
+ * are matched by session ID, so that a lock conflict on any segment is reported
+ * against the coordinator backends of the sessions involved.  GPDB 5 names the
+ * pid and query columns of pg_stat_activity differently.
+ */
+func blockingQuery(version GPDBVersion) string {
+	pidColumn, queryColumn := "pid", "query"
+	if version.Before("6") {
+		pidColumn, queryColumn = "procpid", "current_query"
+	}
+	return fmt.Sprintf(`
+SELECT DISTINCT
+	blocked.%[1]s AS blockedpid,
+	blocked.usename AS blockeduser,
+	blocked.%[2]s AS blockedquery,
+	coalesce(extract(epoch FROM now() - blocked.query_start), 0) AS waitseconds,
+	blocking.%[1]s AS blockingpid,
+	blocking.usename AS blockinguser,
+	blocking.%[2]s AS blockingquery,
+	blockedlock.locktype AS locktype,
+	blockedlock.mode AS lockmode,
+	coalesce(blockedlock.relation::regclass::text, '') AS relation
+FROM pg_catalog.pg_locks blockedlock
+	JOIN pg_catalog.pg_locks blockinglock ON blockinglock.granted
+		AND blockinglock.mppsessionid <> blockedlock.mppsessionid
+		AND blockinglock.gp_segment_id = blockedlock.gp_segment_id
+		AND blockinglock.locktype = blockedlock.locktype
+		AND blockinglock.database IS NOT DISTINCT FROM blockedlock.database
+		AND blockinglock.relation IS NOT DISTINCT FROM blockedlock.relation
+		AND blockinglock.page IS NOT DISTINCT FROM blockedlock.page
+		AND blockinglock.tuple IS NOT DISTINCT FROM blockedlock.tuple
+		AND blockinglock.virtualxid IS NOT DISTINCT FROM blockedlock.virtualxid
+		AND blockinglock.transactionid IS NOT DISTINCT FROM blockedlock.transactionid
+		AND blockinglock.classid IS NOT DISTINCT FROM blockedlock.classid
+		AND blockinglock.objid IS NOT DISTINCT FROM blockedlock.objid
+		AND blockinglock.objsubid IS NOT DISTINCT FROM blockedlock.objsubid
+	JOIN pg_catalog.pg_stat_activity blocked ON blocked.sess_id = blockedlock.mppsessionid
+	JOIN pg_catalog.pg_stat_activity blocking ON blocking.sess_id = blockinglock.mppsessionid
+WHERE NOT blockedlock.granted
+ORDER BY blockedpid, blockingpid`, pidColumn, queryColumn)
+}
+
+/*
+ * DiagnoseBlocking returns every session that is waiting for a lock, paired
+ * with each session holding a conflicting lock, ordered by the PIDs of the
+ * blocked and blocking sessions.  The query bypasses the query cache, as its
+ * results are only useful while they are current.
+ */
+func DiagnoseBlocking(connection *DBConn, whichConn ...int) ([]BlockedSession, error) {
+	rows, err := connection.Query(blockingQuery(connection.Version), whichConn...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := make([]BlockedSession, 0)
+	for rows.Next() {
+		var session BlockedSession
+		if err := rows.StructScan(&session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+/*
+ * A BlockingWatchdog periodically checks whether any query on a DBConn's
+ * connections has been waiting for a lock for longer than a threshold, and
+ * logs a warning naming the session that holds the lock.  It is created by
+ * StartBlockingWatchdog and runs until Stop is called.
+ */
+type BlockingWatchdog struct {
+	watched   *DBConn
+	monitor   *DBConn
+	threshold time.Duration
+	interval  time.Duration
+	mutex     sync.Mutex
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+/*
+ * StartBlockingWatchdog checks the connections of dbconn for blocked queries
+ * every interval.  Because a blocked connection cannot be used to diagnose
+ * itself, the checks are run on the first connection of monitor, which should
+ * be a separate DBConn that the caller does not otherwise use while the
+ * watchdog is running.  Queries are matched to connections by backend PID, so
+ * only connections made with the pgx driver are watched.
+ */
+func (dbconn *DBConn) StartBlockingWatchdog(monitor *DBConn, threshold time.Duration, interval time.Duration) *BlockingWatchdog {
+	watchdog := &BlockingWatchdog{
+		watched:   dbconn,
+		monitor:   monitor,
+		threshold: threshold,
+		interval:  interval,
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go func(stop chan struct{}) {
+		defer close(watchdog.stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-operating.System.After(watchdog.interval):
+				watchdog.Check()
+			}
+		}
+	}(watchdog.stop)
+	return watchdog
+}
+
+/*
+ * Check runs a single check immediately and returns the blocked sessions on
+ * the watched connections that have waited longer than the threshold, each of
+ * which is also logged as a warning.  Errors running the check are logged at
+ * the Verbose level, as the watchdog is only a diagnostic aid.
+ */
+func (watchdog *BlockingWatchdog) Check() []BlockedSession {
+	watchdog.mutex.Lock()
+	defer watchdog.mutex.Unlock()
+	connNums := make(map[int]int)
+	for _, state := range watchdog.watched.DescribePool() {
+		connNums[int(state.BackendPID)] = state.ConnNum
+	}
+	sessions, err := DiagnoseBlocking(watchdog.monitor, 0)
+	if err != nil {
+		logDomain.Verbose("Unable to check for blocked queries: %v", err)
+		return nil
+	}
+	overdue := make([]BlockedSession, 0)
+	for _, session := range sessions {
+		connNum, ok := connNums[session.BlockedPID]
+		if !ok || session.WaitSeconds < watchdog.threshold.Seconds() {
+			continue
+		}
+		logDomain.Warn("Query on connection %d is blocked: %s", connNum, session)
+		overdue = append(overdue, session)
+	}
+	return overdue
+}
+
+// Stop stops the periodic checks, waiting for a check in progress to finish.  Calling it more than once has no effect.
+func (watchdog *BlockingWatchdog) Stop() {
+	watchdog.mutex.Lock()
+	stop := watchdog.stop
+	watchdog.stop = nil
+	watchdog.mutex.Unlock()
+	if stop != nil {
+		close(stop)
+		<-watchdog.stopped
+	}
+}
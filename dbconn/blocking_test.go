@@ -0,0 +1,113 @@
+package dbconn_test
+
+import (
+	"fmt"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/blocking tests", func() {
+	blockingColumns := []string{"blockedpid", "blockeduser", "blockedquery", "waitseconds", "blockingpid", "blockinguser", "blockingquery", "locktype", "lockmode", "relation"}
+
+	Describe("DiagnoseBlocking", func() {
+		It("uses the GPDB 5 column names of pg_stat_activity", func() {
+			mock.ExpectQuery(`blocked\.procpid AS blockedpid,.*blocked\.current_query AS blockedquery`).
+				WillReturnRows(sqlmock.NewRows(blockingColumns))
+
+			sessions, err := dbconn.DiagnoseBlocking(connection)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sessions).To(BeEmpty())
+		})
+		It("uses the GPDB 6 and later column names of pg_stat_activity", func() {
+			connection, mock = testhelper.CreateAndConnectMockDBWithVersion(1, "7.0.0")
+			mock.ExpectQuery(`blocked\.pid AS blockedpid,.*blocked\.query AS blockedquery`).
+				WillReturnRows(sqlmock.NewRows(blockingColumns))
+
+			_, err := dbconn.DiagnoseBlocking(connection)
+
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("returns each blocked session with the session blocking it", func() {
+			mock.ExpectQuery("FROM pg_catalog.pg_locks blockedlock").WillReturnRows(sqlmock.NewRows(blockingColumns).
+				AddRow(100, "gpadmin", "SELECT * FROM foo", 65.2, 200, "alice", "LOCK TABLE foo", "relation", "AccessShareLock", "public.foo").
+				AddRow(101, "gpadmin", "COMMIT", 1.0, 200, "alice", "LOCK TABLE foo", "transactionid", "ShareLock", ""))
+
+			sessions, err := dbconn.DiagnoseBlocking(connection)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sessions).To(HaveLen(2))
+			Expect(sessions[0]).To(Equal(dbconn.BlockedSession{BlockedPID: 100, BlockedUser: "gpadmin", BlockedQuery: "SELECT * FROM foo", WaitSeconds: 65.2,
+				BlockingPID: 200, BlockingUser: "alice", BlockingQuery: "LOCK TABLE foo", LockType: "relation", LockMode: "AccessShareLock", Relation: "public.foo"}))
+			Expect(sessions[0].String()).To(Equal("pid 100 has been waiting 65s for AccessShareLock lock on public.foo held by pid 200 (LOCK TABLE foo)"))
+			Expect(sessions[1].String()).To(Equal("pid 101 has been waiting 1s for ShareLock lock held by pid 200 (LOCK TABLE foo)"))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("FROM pg_catalog.pg_locks blockedlock").WillReturnError(fmt.Errorf("permission denied"))
+
+			_, err := dbconn.DiagnoseBlocking(connection)
+
+			Expect(err).To(MatchError("permission denied"))
+		})
+	})
+	Describe("BlockingWatchdog", func() {
+		var (
+			monitor     *dbconn.DBConn
+			monitorMock sqlmock.Sqlmock
+			logCapture  *testhelper.LogCapture
+		)
+
+		// The mock driver reports a backend PID of 0 for every connection
+		expectBlockedQuery := func(waitSeconds float64) {
+			monitorMock.ExpectQuery("FROM pg_catalog.pg_locks blockedlock").WillReturnRows(sqlmock.NewRows(blockingColumns).
+				AddRow(0, "gpadmin", "SELECT * FROM foo", waitSeconds, 200, "alice", "LOCK TABLE foo", "relation", "AccessShareLock", "public.foo").
+				AddRow(300, "bob", "SELECT 1", 600.0, 200, "alice", "LOCK TABLE foo", "relation", "AccessShareLock", "public.foo"))
+		}
+		BeforeEach(func() {
+			monitor, monitorMock = testhelper.CreateAndConnectMockDB(1)
+			logCapture = testhelper.SetupTestLogCapture()
+		})
+		AfterEach(func() {
+			operating.System = operating.InitializeSystemFunctions()
+		})
+
+		It("warns about watched queries that have waited longer than the threshold", func() {
+			watchdog := connection.StartBlockingWatchdog(monitor, time.Minute, time.Hour)
+			defer watchdog.Stop()
+			expectBlockedQuery(65)
+
+			blocked := watchdog.Check()
+
+			Expect(blocked).To(HaveLen(1))
+			Expect(blocked[0].BlockedPID).To(Equal(0))
+			Expect(logCapture).To(testhelper.HaveLoggedWarn("Query on connection 0 is blocked: pid 0 has been waiting 65s for AccessShareLock lock on public.foo held by pid 200"))
+		})
+		It("ignores watched queries that have not waited longer than the threshold", func() {
+			watchdog := connection.StartBlockingWatchdog(monitor, time.Minute, time.Hour)
+			defer watchdog.Stop()
+			expectBlockedQuery(30)
+
+			Expect(watchdog.Check()).To(BeEmpty())
+			Expect(logCapture).ToNot(testhelper.HaveLoggedWarn("is blocked"))
+		})
+		It("checks every interval until stopped", func() {
+			clock := testhelper.MockClock(time.Date(2017, time.January, 1, 1, 1, 1, 1, time.Local))
+			watchdog := connection.StartBlockingWatchdog(monitor, time.Minute, 10*time.Second)
+			expectBlockedQuery(65)
+
+			Eventually(clock.NumWaiters).Should(Equal(1))
+			clock.Advance(10 * time.Second)
+			Eventually(monitorMock.ExpectationsWereMet).Should(Succeed())
+			watchdog.Stop()
+
+			Expect(logCapture).To(testhelper.HaveLoggedWarn("Query on connection 0 is blocked"))
+		})
+	})
+})
@@ -0,0 +1,132 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to cancelling the queries
+ * running on a DBConn's connections, e.g. when the utility is interrupted, so
+ * that the server stops work whose results will never be read.
+ */
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// How long to wait for the server to accept each cancel request
+const cancelRequestTimeout = 10 * time.Second
+
+func (tracker *connTracker) setCancelFunc(connNum int, cancelFunc func(ctx context.Context) error) {
+	if tracker == nil {
+		return
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if connNum >= 0 && connNum < len(tracker.cancelFuncs) {
+		tracker.cancelFuncs[connNum] = cancelFunc
+	}
+}
+
+func (tracker *connTracker) cancelFunc(connNum int) func(ctx context.Context) error {
+	if tracker == nil {
+		return nil
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if connNum < 0 || connNum >= len(tracker.cancelFuncs) {
+		return nil
+	}
+	return tracker.cancelFuncs[connNum]
+}
+
+/*
+ * A cancel request is sent over a new network connection with the backend's
+ * PID and secret key, as libpq's PQcancel does, so the connection it cancels
+ * may be busy running a query; pgx cancels queries the same way when their
+ * context is cancelled.  As with getBackendPID, drivers other than pgx cannot
+ * cancel queries and return nil.
+ */
+func getCancelFunc(conn *sqlx.DB) func(ctx context.Context) error {
+	var cancelFunc func(ctx context.Context) error
+	sqlConn, err := conn.Conn(context.Background())
+	if err != nil {
+		return nil
+	}
+	defer sqlConn.Close()
+	_ = sqlConn.Raw(func(driverConn interface{}) error {
+		if pgxConn, ok := driverConn.(*stdlib.Conn); ok {
+			cancelFunc = pgxConn.Conn().PgConn().CancelRequest
+		}
+		return nil
+	})
+	return cancelFunc
+}
+
+/*
+ * CancelAll asks the server to cancel the query running on each connection in
+ * the pool that DescribePool reports as active, including those whose rows
+ * from Query are still being read, without waiting for the queries to stop;
+ * each canceled query returns an error to the goroutine running it.
+ * Connections that are idle, including those idle in a transaction, are left
+ * alone, so that the transaction can still be rolled back.  An error is returned if any of the cancel requests could not be
+ * sent, after attempting all of them.
+ */
+func (dbconn *DBConn) CancelAll() error {
+	active := make([]int, 0)
+	for _, state := range dbconn.DescribePool() {
+		if state.Status == ConnStatusActive || state.Status == ConnStatusActiveInTx {
+			active = append(active, state.ConnNum)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	logDomain.Verbose("Cancelling queries on %d connections", len(active))
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	numFailed := 0
+	for _, connNum := range active {
+		wg.Add(1)
+		go func(connNum int) {
+			defer wg.Done()
+			err := errors.New("the driver does not support cancel requests")
+			if cancelFunc := dbconn.tracker.cancelFunc(connNum); cancelFunc != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), cancelRequestTimeout)
+				defer cancel()
+				err = cancelFunc(ctx)
+			}
+			if err != nil {
+				logDomain.Verbose("Unable to cancel the query on connection %d: %v", connNum, err)
+				mutex.Lock()
+				numFailed++
+				mutex.Unlock()
+			}
+		}(connNum)
+	}
+	wg.Wait()
+	if numFailed > 0 {
+		return errors.Errorf("Unable to cancel the queries on %d of %d connections", numFailed, len(active))
+	}
+	return nil
+}
+
+/*
+ * CancelAllOnShutdown adds a cleanup function to handler that calls CancelAll,
+ * logging any error as a warning.  Cleanup functions run in the reverse of
+ * the order they were added, so this should be called after adding any
+ * cleanup function that closes the connection or rolls back its transactions,
+ * so that the queries are canceled first and those functions need not wait for
+ * them to finish.
+ */
+func (dbconn *DBConn) CancelAllOnShutdown(handler *gplog.ShutdownHandler) {
+	handler.AddCleanup(func() {
+		if err := dbconn.CancelAll(); err != nil {
+			logDomain.Warn("%v", err)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package dbconn_test
+
+import (
+	"os"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/cancel tests", func() {
+	// Runs a query that takes a while on the mock connection, returning a channel closed when it finishes
+	startSlowQuery := func() chan struct{} {
+		mock.ExpectExec("SELECT pg_sleep").WillDelayFor(200 * time.Millisecond).WillReturnResult(testhelper.TestResult{Rows: 0})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = connection.Exec("SELECT pg_sleep(1)")
+		}()
+		Eventually(func() string { return connection.DescribePool()[0].Status }).Should(Equal(dbconn.ConnStatusActive))
+		return done
+	}
+
+	Describe("DBConn.CancelAll", func() {
+		It("does nothing if no queries are running", func() {
+			Expect(connection.CancelAll()).To(Succeed())
+		})
+		It("leaves connections that are idle in a transaction alone", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+
+			Expect(connection.CancelAll()).To(Succeed())
+		})
+		It("returns an error if a running query cannot be canceled", func() {
+			done := startSlowQuery()
+
+			err := connection.CancelAll()
+
+			Expect(err).To(MatchError("Unable to cancel the queries on 1 of 1 connections"))
+			Eventually(done).Should(BeClosed())
+		})
+	})
+	Describe("DBConn.CancelAll with open rows", func() {
+		It("cancels the query of a connection whose rows are still being read", func() {
+			mock.ExpectQuery("SELECT i").WillReturnRows(sqlmock.NewRows([]string{"i"}).AddRow(1).AddRow(2))
+			rows, err := connection.Query("SELECT i FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rows.Next()).To(BeTrue())
+
+			Expect(connection.CancelAll()).To(MatchError("Unable to cancel the queries on 1 of 1 connections"))
+
+			Expect(rows.Close()).To(Succeed())
+			Expect(connection.CancelAll()).To(Succeed())
+		})
+	})
+	Describe("DBConn.CancelAllOnShutdown", func() {
+		var handler *gplog.ShutdownHandler
+		AfterEach(func() {
+			handler.Stop()
+			operating.System = operating.InitializeSystemFunctions()
+			gplog.SetErrorCode(0)
+		})
+		It("cancels running queries when the utility is interrupted", func() {
+			capture := testhelper.SetupTestLogCapture()
+			signals := make(chan os.Signal, 1)
+			operating.System.NotifySignals = func(sigs ...os.Signal) (<-chan os.Signal, func()) { return signals, func() {} }
+			exited := make(chan bool, 1)
+			gplog.SetExitFunc(func() { exited <- true })
+			handler = gplog.GracefulShutdown()
+			connection.CancelAllOnShutdown(handler)
+			done := startSlowQuery()
+
+			signals <- os.Interrupt
+
+			Eventually(exited).Should(Receive())
+			Expect(capture).To(testhelper.HaveLoggedWarn("Unable to cancel the queries on 1 of 1 connections"))
+			Eventually(done).Should(BeClosed())
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package dbconn
+
+/*
+ * This file contains functions for reading large result sets in batches
+ * through a server-side cursor, instead of loading them into memory at once.
+ */
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var cursorCounter uint64
+
+/*
+ * SelectCursor runs query through a cursor, fetching batchSize rows at a time,
+ * and calls fn once for each row with rows positioned on that row, so fn can
+ * call rows.Scan or rows.StructScan but must not call rows.Next or rows.Close.
+ * If fn returns an error, no more rows are fetched and that error is returned.
+ *
+ * Cursors can only be used within a transaction.  If a transaction is already
+ * in progress on the connection, the cursor is declared in it and the
+ * transaction is left open afterward; otherwise, the cursor is declared in a
+ * new transaction that is committed once all rows have been read, or rolled
+ * back on error.
+ */
+func (dbconn *DBConn) SelectCursor(query string, batchSize int, fn func(rows *sqlx.Rows) error, whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if batchSize <= 0 {
+		return errors.Errorf("Cursor batch size must be positive, got %d", batchSize)
+	}
+	cursorName := fmt.Sprintf("gp_cursor_%d", atomic.AddUint64(&cursorCounter, 1))
+	if dbconn.Tx[connNum] != nil {
+		err := dbconn.readCursor(connNum, cursorName, query, batchSize, fn)
+		if err != nil {
+			// The transaction belongs to the caller, so make sure the cursor does not outlive this call
+			_, _ = dbconn.Exec("CLOSE "+cursorName, connNum)
+		}
+		return err
+	}
+	return dbconn.WithTransaction(func(tx Queryer) error {
+		return dbconn.readCursor(connNum, cursorName, query, batchSize, fn)
+	}, connNum)
+}
+
+func (dbconn *DBConn) readCursor(connNum int, cursorName string, query string, batchSize int, fn func(rows *sqlx.Rows) error) error {
+	_, err := dbconn.Exec(fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", cursorName, query), connNum)
+	if err != nil {
+		return err
+	}
+	for {
+		numRows, err := dbconn.fetchFromCursor(connNum, cursorName, batchSize, fn)
+		if err != nil {
+			return err
+		}
+		if numRows < batchSize {
+			break
+		}
+	}
+	_, err = dbconn.Exec("CLOSE "+cursorName, connNum)
+	return err
+}
+
+func (dbconn *DBConn) fetchFromCursor(connNum int, cursorName string, batchSize int, fn func(rows *sqlx.Rows) error) (int, error) {
+	rows, err := dbconn.Query(fmt.Sprintf("FETCH FORWARD %d FROM %s", batchSize, cursorName), connNum)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	numRows := 0
+	for rows.Next() {
+		numRows++
+		if err := fn(rows.Rows); err != nil {
+			return numRows, err
+		}
+	}
+	return numRows, rows.Err()
+}
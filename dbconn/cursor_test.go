@@ -0,0 +1,120 @@
+package dbconn_test
+
+import (
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/cursor tests", func() {
+	type relation struct {
+		Oid  uint32
+		Name string
+	}
+	relationRows := func(relations ...relation) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"oid", "name"})
+		for _, rel := range relations {
+			rows.AddRow(rel.Oid, rel.Name)
+		}
+		return rows
+	}
+	collect := func(results *[]relation) func(rows *sqlx.Rows) error {
+		return func(rows *sqlx.Rows) error {
+			var rel relation
+			if err := rows.StructScan(&rel); err != nil {
+				return err
+			}
+			*results = append(*results, rel)
+			return nil
+		}
+	}
+	query := "SELECT oid, relname AS name FROM pg_class"
+	declare := regexp.QuoteMeta("DECLARE gp_cursor_") + `\d+` + regexp.QuoteMeta(" NO SCROLL CURSOR FOR "+query)
+	fetch := `FETCH FORWARD 2 FROM gp_cursor_\d+`
+	closeCursor := `CLOSE gp_cursor_\d+`
+
+	Describe("DBConn.SelectCursor", func() {
+		It("fetches rows in batches in a new transaction", func() {
+			ExpectBegin(mock)
+			mock.ExpectExec(declare).WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectQuery(fetch).WillReturnRows(relationRows(relation{1, "a"}, relation{2, "b"}))
+			mock.ExpectQuery(fetch).WillReturnRows(relationRows(relation{3, "c"}))
+			mock.ExpectExec(closeCursor).WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectCommit()
+			results := make([]relation, 0)
+
+			err := connection.SelectCursor(query, 2, collect(&results))
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]relation{{1, "a"}, {2, "b"}, {3, "c"}}))
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("fetches until an empty batch if the last batch is full", func() {
+			ExpectBegin(mock)
+			mock.ExpectExec(declare).WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectQuery(fetch).WillReturnRows(relationRows(relation{1, "a"}, relation{2, "b"}))
+			mock.ExpectQuery(fetch).WillReturnRows(relationRows())
+			mock.ExpectExec(closeCursor).WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectCommit()
+			results := make([]relation, 0)
+
+			Expect(connection.SelectCursor(query, 2, collect(&results))).To(Succeed())
+			Expect(results).To(HaveLen(2))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("uses the transaction in progress and leaves it open", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+			mock.ExpectExec(declare).WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectQuery(fetch).WillReturnRows(relationRows(relation{1, "a"}))
+			mock.ExpectExec(closeCursor).WillReturnResult(testhelper.TestResult{Rows: 0})
+			results := make([]relation, 0)
+
+			Expect(connection.SelectCursor(query, 2, collect(&results))).To(Succeed())
+			Expect(results).To(HaveLen(1))
+			Expect(connection.Tx[0]).ToNot(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("stops fetching and rolls back if the function returns an error", func() {
+			ExpectBegin(mock)
+			mock.ExpectExec(declare).WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectQuery(fetch).WillReturnRows(relationRows(relation{1, "a"}, relation{2, "b"}))
+			mock.ExpectRollback()
+			numCalls := 0
+
+			err := connection.SelectCursor(query, 2, func(rows *sqlx.Rows) error {
+				numCalls++
+				return errors.New("cannot process row")
+			})
+
+			Expect(err).To(MatchError("cannot process row"))
+			Expect(numCalls).To(Equal(1))
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("closes the cursor in the transaction in progress on error", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+			mock.ExpectExec(declare).WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectQuery(fetch).WillReturnError(errors.New("canceling statement due to user request"))
+			mock.ExpectExec(closeCursor).WillReturnResult(testhelper.TestResult{Rows: 0})
+
+			err := connection.SelectCursor(query, 2, func(rows *sqlx.Rows) error { return nil })
+
+			Expect(err).To(MatchError("canceling statement due to user request"))
+			Expect(connection.Tx[0]).ToNot(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error for a batch size that is not positive", func() {
+			err := connection.SelectCursor(query, 0, func(rows *sqlx.Rows) error { return nil })
+			Expect(err).To(MatchError("Cursor batch size must be positive, got 0"))
+		})
+	})
+})
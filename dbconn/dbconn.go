@@ -11,9 +11,11 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/retry"
 
 	/*
 	 * We previously used github.com/lib/pq as our Postgres driver,
@@ -25,6 +27,9 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Messages from this package are logged to the "dbconn" domain, so their verbosity can be set separately
+var logDomain = gplog.Domain("dbconn")
+
 /*
  * While the sqlx.DB struct (and indirectly the sql.DB struct) maintains its own
  * connection pool, there is no guarantee of session-level consistency between
@@ -46,11 +51,64 @@ type DBConn struct {
 	NumConns int
 	Driver   DBDriver
 	User     string
-	DBName   string
-	Host     string
-	Port     int
-	Tx       []*sqlx.Tx
-	Version  GPDBVersion
+	// If set, every connection switches to this role after connecting; see role.go
+	Role              string
+	DBName            string
+	Host              string
+	Port              int
+	Tx                []*sqlx.Tx
+	IsolationLevel    IsolationLevel
+	SSL               SSLOptions
+	Kerberos          KerberosConfig
+	Version           GPDBVersion
+	Encoding          EncodingInfo
+	TranscodeToUTF8   bool
+	OnLossyConversion func(warning LossyConversionWarning)
+	tracker           *connTracker
+	queryCache        *queryCache
+	resultLimits      ResultLimits
+	leases            *connLeases
+	// The connection string used by Connect, so that Resize can open more connections
+	connStr string
+}
+
+/*
+ * Queryer is the subset of DBConn methods used to run queries, so that code
+ * which only needs to run queries can accept a Queryer and be tested with a
+ * fake such as the one in the dbconnfakes package instead of a DBConn.
+ */
+type Queryer interface {
+	Exec(query string, whichConn ...int) (sql.Result, error)
+	ExecContext(queryContext context.Context, query string, whichConn ...int) (sql.Result, error)
+	Get(destination interface{}, query string, whichConn ...int) error
+	GetWithArgs(destination interface{}, query string, args ...interface{}) error
+	Select(destination interface{}, query string, whichConn ...int) error
+	SelectWithArgs(destination interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, destination interface{}, query string, whichConn ...int) error
+	Query(query string, whichConn ...int) (*Rows, error)
+	QueryWithArgs(query string, args ...interface{}) (*Rows, error)
+	QueryContext(ctx context.Context, query string, whichConn ...int) (*Rows, error)
+}
+
+/*
+ * Connection is the subset of DBConn methods used by code that manages its own
+ * transactions or depends on the database version, in addition to running
+ * queries.  DBConn implements it, and the dbconnfakes package provides a fake.
+ */
+type Connection interface {
+	Queryer
+	Begin(whichConn ...int) error
+	Commit(whichConn ...int) error
+	Rollback(whichConn ...int) error
+	GetVersion() GPDBVersion
+}
+
+/*
+ * Implemented by DBConn and the Queryer passed to WithTransaction, so that the
+ * string selection helpers can transcode results for either one.
+ */
+type resultTranscoder interface {
+	transcodeResults(query string, results []string) []string
 }
 
 /*
@@ -77,16 +135,17 @@ func NewDBConnFromEnvironment(dbname string) *DBConn {
 		gplog.Fatal(errors.New("No database provided"), "")
 	}
 
-	username := operating.System.Getenv("PGUSER")
+	env := operating.Environment()
+	username := env["PGUSER"]
 	if username == "" {
 		currentUser, _ := operating.System.CurrentUser()
 		username = currentUser.Username
 	}
-	host := operating.System.Getenv("PGHOST")
+	host := env["PGHOST"]
 	if host == "" {
 		host, _ = operating.System.Hostname()
 	}
-	port, err := strconv.Atoi(operating.System.Getenv("PGPORT"))
+	port, err := strconv.Atoi(env["PGPORT"])
 	if err != nil {
 		port = 5432
 	}
@@ -117,15 +176,30 @@ func NewDBConn(dbname, username, host string, port int) *DBConn {
 		Port:     port,
 		Tx:       nil,
 		Version:  GPDBVersion{},
+		tracker:  &connTracker{},
 	}
 }
 
+// GetVersion returns dbconn.Version, so that the version is available through Connection
+func (dbconn *DBConn) GetVersion() GPDBVersion {
+	return dbconn.Version
+}
+
 func (dbconn *DBConn) MustBegin(whichConn ...int) {
 	err := dbconn.Begin(whichConn...)
 	gplog.FatalOnError(err)
 }
 
+// Transactions use dbconn.IsolationLevel, or SERIALIZABLE if it is not set
 func (dbconn *DBConn) Begin(whichConn ...int) error {
+	isolationLevel := dbconn.IsolationLevel
+	if isolationLevel == "" {
+		isolationLevel = ISOLATION_SERIALIZABLE
+	}
+	return dbconn.BeginWithIsolationLevel(isolationLevel, whichConn...)
+}
+
+func (dbconn *DBConn) BeginWithIsolationLevel(isolationLevel IsolationLevel, whichConn ...int) error {
 	connNum := dbconn.ValidateConnNum(whichConn...)
 	if dbconn.Tx[connNum] != nil {
 		return errors.New("Cannot begin transaction; there is already a transaction in progress")
@@ -135,7 +209,8 @@ func (dbconn *DBConn) Begin(whichConn ...int) error {
 	if err != nil {
 		return err
 	}
-	_, err = dbconn.Exec("SET TRANSACTION ISOLATION LEVEL SERIALIZABLE", connNum)
+	dbconn.tracker.setTransaction(connNum, true)
+	_, err = dbconn.Exec(fmt.Sprintf("SET TRANSACTION ISOLATION LEVEL %s", isolationLevel), connNum)
 	return err
 }
 
@@ -149,7 +224,22 @@ func (dbconn *DBConn) Close() {
 		dbconn.ConnPool = nil
 		dbconn.Tx = nil
 		dbconn.NumConns = 0
+		dbconn.tracker.reset(0)
+		dbconn.leases.close()
+		dbconn.InvalidateQueryCache()
+	}
+}
+
+// Opens a single connection for the pool
+func (dbconn *DBConn) openConnection(connStr string) (*sqlx.DB, error) {
+	conn, err := dbconn.Driver.Connect("pgx", connStr)
+	err = dbconn.handleConnectionError(err)
+	if err != nil {
+		return nil, err
 	}
+	conn.SetMaxOpenConns(1)
+	conn.SetMaxIdleConns(1)
+	return conn, nil
 }
 
 func (dbconn *DBConn) MustCommit(whichConn ...int) {
@@ -164,6 +254,7 @@ func (dbconn *DBConn) Commit(whichConn ...int) error {
 	}
 	err := dbconn.Tx[connNum].Commit()
 	dbconn.Tx[connNum] = nil
+	dbconn.tracker.setTransaction(connNum, false)
 	return err
 }
 
@@ -179,6 +270,7 @@ func (dbconn *DBConn) Rollback(whichConn ...int) error {
 	}
 	err := dbconn.Tx[connNum].Rollback()
 	dbconn.Tx[connNum] = nil
+	dbconn.tracker.setTransaction(connNum, false)
 	return err
 }
 
@@ -197,13 +289,13 @@ func (dbconn *DBConn) Connect(numConns int, utilityMode ...bool) error {
 
 	dbname := EscapeConnectionParam(dbconn.DBName)
 	user := EscapeConnectionParam(dbconn.User)
-	krbsrvname := operating.System.Getenv("PGKRBSRVNAME")
-	if krbsrvname == "" {
-		krbsrvname = "postgres"
+	kerberosParams, err := dbconn.Kerberos.connectionParams()
+	if err != nil {
+		return err
 	}
-	sslmode := operating.System.Getenv("PGSSLMODE")
-	if sslmode == "" {
-		sslmode = "prefer"
+	sslParams, err := dbconn.SSL.connectionParams()
+	if err != nil {
+		return err
 	}
 	// This string takes in the literal user/database names. They do not need
 	// to be escaped or quoted.
@@ -212,8 +304,8 @@ func (dbconn *DBConn) Connect(numConns int, utilityMode ...bool) error {
 	// the same object again, then querying for the object in the same
 	// connection will generate a cache lookup failure. To disable pgx's
 	// automatic prepared statement cache we set statement_cache_capacity to 0.
-	connStr := fmt.Sprintf(`user='%s' dbname='%s' krbsrvname='%s' host=%s port=%d sslmode='%s' statement_cache_capacity=0`,
-		user, dbname, krbsrvname, dbconn.Host, dbconn.Port, sslmode)
+	connStr := fmt.Sprintf(`user='%s' dbname='%s'%s host=%s port=%d%s statement_cache_capacity=0`,
+		user, dbname, kerberosParams, dbconn.Host, dbconn.Port, sslParams)
 
 	dbconn.ConnPool = make([]*sqlx.DB, numConns)
 	if len(utilityMode) > 1 {
@@ -241,22 +333,36 @@ func (dbconn *DBConn) Connect(numConns int, utilityMode ...bool) error {
 	}
 
 	for i := 0; i < numConns; i++ {
-		conn, err := dbconn.Driver.Connect("pgx", connStr)
-		err = dbconn.handleConnectionError(err)
+		conn, err := dbconn.openConnection(connStr)
 		if err != nil {
 			return err
 		}
-		conn.SetMaxOpenConns(1)
-		conn.SetMaxIdleConns(1)
 		dbconn.ConnPool[i] = conn
 	}
+	dbconn.connStr = connStr
 	dbconn.Tx = make([]*sqlx.Tx, numConns)
 	dbconn.NumConns = numConns
+	if dbconn.tracker == nil {
+		dbconn.tracker = &connTracker{}
+	}
+	dbconn.tracker.reset(numConns)
+	dbconn.leases = newConnLeases(numConns)
+	for i, conn := range dbconn.ConnPool {
+		dbconn.tracker.setBackendPID(i, getBackendPID(conn))
+		dbconn.tracker.setCancelFunc(i, getCancelFunc(conn))
+	}
 	version, err := InitializeVersion(dbconn)
 	if err != nil {
 		return errors.Wrap(err, "Failed to determine database version")
 	}
 	dbconn.Version = version
+	for i, conn := range dbconn.ConnPool {
+		if err := dbconn.setSessionRole(conn); err != nil {
+			return err
+		}
+		dbconn.tracker.setRole(i, dbconn.Role)
+	}
+	dbconn.Encoding = getEncodingInfo(dbconn.ConnPool[0])
 	return nil
 }
 
@@ -269,6 +375,122 @@ func (dbconn *DBConn) ConnectInUtilityMode(numConns int) error {
 	return dbconn.Connect(numConns, true)
 }
 
+/*
+ * ConnectProgress is passed to the ConnectOptions.Progress callback before each
+ * connection attempt, so that callers can report e.g. "attempting to connect
+ * (3/10)".  LastError is nil on the first attempt.
+ */
+type ConnectProgress struct {
+	Attempt     int
+	MaxAttempts int
+	Elapsed     time.Duration
+	LastError   error
+}
+
+/*
+ * If Backoff is nil, attempts are retried every RetryInterval; otherwise
+ * RetryInterval is ignored.
+ */
+type ConnectOptions struct {
+	MaxAttempts   int // Values less than 1 are treated as 1
+	RetryInterval time.Duration
+	Backoff       BackoffPolicy
+	UtilityMode   bool
+	Progress      func(progress ConnectProgress)
+}
+
+/*
+ * Errors indicating that the database or role is missing or that the
+ * credentials are wrong will not be fixed by retrying, so fail immediately.
+ */
+func isPermanentConnectionError(err error) bool {
+	return strings.Contains(err.Error(), "does not exist") || strings.Contains(err.Error(), "authentication failed")
+}
+
+/*
+ * ConnectWithContext behaves like Connect, but retries failed connection
+ * attempts according to opts and returns as soon as ctx is done, so that a
+ * caller can abort a connection to an unreachable host (e.g. on Ctrl-C).
+ *
+ * Because the driver's Connect function cannot be interrupted, each attempt is
+ * made on a copy of the DBConn in a separate goroutine; if ctx is done before an
+ * attempt finishes, that attempt's connections are closed once it completes and
+ * the DBConn itself is left unconnected.
+ */
+func (dbconn *DBConn) ConnectWithContext(ctx context.Context, numConns int, opts ConnectOptions) error {
+	// Misconfigured SSL or Kerberos options will not be fixed by retrying
+	if err := dbconn.Kerberos.Validate(); err != nil {
+		return err
+	}
+	if err := dbconn.SSL.Validate(); err != nil {
+		return err
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	backoff := opts.Backoff
+	if backoff == nil {
+		backoff = ConstantBackoff{Interval: opts.RetryInterval}
+	}
+	start := operating.System.Now()
+	var lastErr error
+	err := retry.Do(ctx, retry.Options{
+		Operation:   "Connection",
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+		Retryable:   func(err error) bool { return !isPermanentConnectionError(err) },
+	}, func(attempt int) error {
+		if opts.Progress != nil {
+			opts.Progress(ConnectProgress{
+				Attempt:     attempt,
+				MaxAttempts: maxAttempts,
+				Elapsed:     operating.System.Now().Sub(start),
+				LastError:   lastErr,
+			})
+		}
+
+		attemptConn := *dbconn
+		attemptConn.tracker = &connTracker{}
+		result := make(chan error, 1)
+		go func() {
+			result <- attemptConn.Connect(numConns, opts.UtilityMode)
+		}()
+		select {
+		case <-ctx.Done():
+			go func() {
+				<-result
+				attemptConn.Close()
+			}()
+			return ctx.Err()
+		case lastErr = <-result:
+		}
+		if lastErr == nil {
+			*dbconn = attemptConn
+			return nil
+		}
+		attemptConn.Close()
+		return lastErr
+	})
+	if err != nil && ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "Connection attempt canceled")
+	}
+	return err
+}
+
+/*
+ * If the connection attempt is canceled, this exits without a stack trace,
+ * as cancellation is a user action rather than an internal error.
+ */
+func (dbconn *DBConn) MustConnectWithContext(ctx context.Context, numConns int, opts ConnectOptions) {
+	err := dbconn.ConnectWithContext(ctx, numConns, opts)
+	if err != nil && ctx.Err() != nil {
+		gplog.FatalWithoutPanic("%v", err)
+		return
+	}
+	gplog.FatalOnError(err)
+}
+
 func (dbconn *DBConn) handleConnectionError(err error) error {
 	if err != nil {
 		if strings.Contains(err.Error(), "does not exist") {
@@ -296,8 +518,10 @@ func (dbconn *DBConn) handleConnectionError(err error) error {
  * requiring that to be ensured at the call site.
  */
 
-func (dbconn *DBConn) Exec(query string, whichConn ...int) (sql.Result, error) {
+func (dbconn *DBConn) Exec(query string, whichConn ...int) (result sql.Result, err error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	dbconn.tracker.startQuery(connNum, query)
+	defer func() { dbconn.tracker.finishQuery(connNum, err) }()
 	if dbconn.Tx[connNum] != nil {
 		return dbconn.Tx[connNum].Exec(query)
 	}
@@ -309,8 +533,10 @@ func (dbconn *DBConn) MustExec(query string, whichConn ...int) {
 	gplog.FatalOnError(err)
 }
 
-func (dbconn *DBConn) ExecContext(queryContext context.Context, query string, whichConn ...int) (sql.Result, error) {
+func (dbconn *DBConn) ExecContext(queryContext context.Context, query string, whichConn ...int) (result sql.Result, err error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	dbconn.tracker.startQuery(connNum, query)
+	defer func() { dbconn.tracker.finishQuery(connNum, err) }()
 	if dbconn.Tx[connNum] != nil {
 		return dbconn.Tx[connNum].ExecContext(queryContext, query)
 	}
@@ -323,64 +549,90 @@ func (dbconn *DBConn) MustExecContext(queryContext context.Context, query string
 }
 
 func (dbconn *DBConn) GetWithArgs(destination interface{}, query string, args ...interface{}) error {
-	if dbconn.Tx[0] != nil {
-		return dbconn.Tx[0].Get(destination, query, args...)
-	}
-	return dbconn.ConnPool[0].Get(destination, query, args...)
+	return dbconn.getWithArgsOnConn(0, destination, query, args...)
+}
+
+func (dbconn *DBConn) getWithArgsOnConn(connNum int, destination interface{}, query string, args ...interface{}) error {
+	return dbconn.withQueryCache(connNum, destination, query, args, func() (err error) {
+		dbconn.tracker.startQuery(connNum, query)
+		defer func() { dbconn.tracker.finishQuery(connNum, err) }()
+		if dbconn.Tx[connNum] != nil {
+			return dbconn.Tx[connNum].Get(destination, query, args...)
+		}
+		return dbconn.ConnPool[connNum].Get(destination, query, args...)
+	})
 }
 
 func (dbconn *DBConn) Get(destination interface{}, query string, whichConn ...int) error {
 	connNum := dbconn.ValidateConnNum(whichConn...)
-	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Get(destination, query)
-	}
-	return dbconn.ConnPool[connNum].Get(destination, query)
+	return dbconn.getWithArgsOnConn(connNum, destination, query)
 }
 
 func (dbconn *DBConn) SelectWithArgs(destination interface{}, query string, args ...interface{}) error {
-	if dbconn.Tx[0] != nil {
-		return dbconn.Tx[0].Select(destination, query, args...)
-	}
-	return dbconn.ConnPool[0].Select(destination, query, args...)
+	return dbconn.selectWithArgsOnConn(0, destination, query, args...)
+}
+
+func (dbconn *DBConn) selectWithArgsOnConn(connNum int, destination interface{}, query string, args ...interface{}) error {
+	return dbconn.withQueryCache(connNum, destination, query, args, func() (err error) {
+		dbconn.tracker.startQuery(connNum, query)
+		defer func() { dbconn.tracker.finishQuery(connNum, err) }()
+		return dbconn.selectOnConn(context.Background(), connNum, destination, query, args...)
+	})
 }
 
 func (dbconn *DBConn) Select(destination interface{}, query string, whichConn ...int) error {
 	connNum := dbconn.ValidateConnNum(whichConn...)
-	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Select(destination, query)
-	}
-	return dbconn.ConnPool[connNum].Select(destination, query)
+	return dbconn.selectWithArgsOnConn(connNum, destination, query)
 }
 
-func (dbconn *DBConn) SelectContext(ctx context.Context, destination interface{}, query string, whichConn ...int) error {
+func (dbconn *DBConn) SelectContext(ctx context.Context, destination interface{}, query string, whichConn ...int) (err error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
-	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].SelectContext(ctx, destination, query)
-	}
-	return dbconn.ConnPool[connNum].SelectContext(ctx, destination, query)
+	dbconn.tracker.startQuery(connNum, query)
+	defer func() { dbconn.tracker.finishQuery(connNum, err) }()
+	return dbconn.selectOnConn(ctx, connNum, destination, query)
 }
 
-func (dbconn *DBConn) QueryWithArgs(query string, args ...interface{}) (*sqlx.Rows, error) {
-	if dbconn.Tx[0] != nil {
-		return dbconn.Tx[0].Queryx(query, args...)
+func (dbconn *DBConn) QueryWithArgs(query string, args ...interface{}) (*Rows, error) {
+	return dbconn.queryWithArgsOnConn(0, query, args...)
+}
+
+func (dbconn *DBConn) queryWithArgsOnConn(connNum int, query string, args ...interface{}) (*Rows, error) {
+	dbconn.tracker.startQuery(connNum, query)
+	var rows *sqlx.Rows
+	var err error
+	if dbconn.Tx[connNum] != nil {
+		rows, err = dbconn.Tx[connNum].Queryx(query, args...)
+	} else {
+		rows, err = dbconn.ConnPool[connNum].Queryx(query, args...)
 	}
-	return dbconn.ConnPool[0].Queryx(query, args...)
+	return dbconn.tracker.trackRows(connNum, rows, err)
 }
 
-func (dbconn *DBConn) Query(query string, whichConn ...int) (*sqlx.Rows, error) {
+// The connection is active until the returned rows are closed or read to the end
+func (dbconn *DBConn) Query(query string, whichConn ...int) (*Rows, error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	dbconn.tracker.startQuery(connNum, query)
+	var rows *sqlx.Rows
+	var err error
 	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Queryx(query)
+		rows, err = dbconn.Tx[connNum].Queryx(query)
+	} else {
+		rows, err = dbconn.ConnPool[connNum].Queryx(query)
 	}
-	return dbconn.ConnPool[connNum].Queryx(query)
+	return dbconn.tracker.trackRows(connNum, rows, err)
 }
 
-func (dbconn *DBConn) QueryContext(ctx context.Context, query string, whichConn ...int) (*sqlx.Rows, error) {
+func (dbconn *DBConn) QueryContext(ctx context.Context, query string, whichConn ...int) (*Rows, error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	dbconn.tracker.startQuery(connNum, query)
+	var rows *sqlx.Rows
+	var err error
 	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].QueryxContext(ctx, query)
+		rows, err = dbconn.Tx[connNum].QueryxContext(ctx, query)
+	} else {
+		rows, err = dbconn.ConnPool[connNum].QueryxContext(ctx, query)
 	}
-	return dbconn.ConnPool[connNum].QueryxContext(ctx, query)
+	return dbconn.tracker.trackRows(connNum, rows, err)
 }
 
 /*
@@ -421,13 +673,13 @@ func EscapeConnectionParam(param string) string {
  * rows available to be returned, and we don't want to silently ignore that if
  * only one row was expected for a given query but multiple were returned.
  */
-func MustSelectString(connection *DBConn, query string, whichConn ...int) string {
+func MustSelectString(connection Queryer, query string, whichConn ...int) string {
 	str, err := SelectString(connection, query, whichConn...)
 	gplog.FatalOnError(err)
 	return str
 }
 
-func SelectString(connection *DBConn, query string, whichConn ...int) (string, error) {
+func SelectString(connection Queryer, query string, whichConn ...int) (string, error) {
 	results, err := SelectStringSlice(connection, query, whichConn...)
 	if err != nil {
 		return "", err
@@ -450,16 +702,18 @@ func SelectString(connection *DBConn, query string, whichConn ...int) (string, e
  * It also gives a nicer error message in the event that a query is called with
  * multiple columns, where using a generic struct gives an opaque "missing
  * destination name" error.
+ *
+ * These helpers accept any Queryer, so they can also be called with the
+ * Queryer passed to WithTransaction or with a fake.
  */
-func MustSelectStringSlice(connection *DBConn, query string, whichConn ...int) []string {
+func MustSelectStringSlice(connection Queryer, query string, whichConn ...int) []string {
 	str, err := SelectStringSlice(connection, query, whichConn...)
 	gplog.FatalOnError(err)
 	return str
 }
 
-func SelectStringSlice(connection *DBConn, query string, whichConn ...int) ([]string, error) {
-	connNum := connection.ValidateConnNum(whichConn...)
-	rows, err := connection.Query(query, connNum)
+func SelectStringSlice(connection Queryer, query string, whichConn ...int) ([]string, error) {
+	rows, err := connection.Query(query, whichConn...)
 	if err != nil {
 		return []string{}, err
 	}
@@ -478,6 +732,9 @@ func SelectStringSlice(connection *DBConn, query string, whichConn ...int) ([]st
 	if rows.Rows.Err() != nil {
 		return []string{}, rows.Rows.Err()
 	}
+	if transcoder, ok := connection.(resultTranscoder); ok {
+		retval = transcoder.transcodeResults(query, retval)
+	}
 	return retval, nil
 }
 
@@ -487,13 +744,13 @@ func SelectStringSlice(connection *DBConn, query string, whichConn ...int) ([]st
  * as SelectString and SelectStringSlice; see the comments for those functions,
  * above, for more details.
  */
-func MustSelectInt(connection *DBConn, query string, whichConn ...int) int {
+func MustSelectInt(connection Queryer, query string, whichConn ...int) int {
 	str, err := SelectInt(connection, query, whichConn...)
 	gplog.FatalOnError(err)
 	return str
 }
 
-func SelectInt(connection *DBConn, query string, whichConn ...int) (int, error) {
+func SelectInt(connection Queryer, query string, whichConn ...int) (int, error) {
 	results, err := SelectIntSlice(connection, query, whichConn...)
 	if err != nil {
 		return 0, err
@@ -506,15 +763,14 @@ func SelectInt(connection *DBConn, query string, whichConn ...int) (int, error)
 	return 0, nil
 }
 
-func MustSelectIntSlice(connection *DBConn, query string, whichConn ...int) []int {
+func MustSelectIntSlice(connection Queryer, query string, whichConn ...int) []int {
 	str, err := SelectIntSlice(connection, query, whichConn...)
 	gplog.FatalOnError(err)
 	return str
 }
 
-func SelectIntSlice(connection *DBConn, query string, whichConn ...int) ([]int, error) {
-	connNum := connection.ValidateConnNum(whichConn...)
-	rows, err := connection.Query(query, connNum)
+func SelectIntSlice(connection Queryer, query string, whichConn ...int) ([]int, error) {
+	rows, err := connection.Query(query, whichConn...)
 	if err != nil {
 		return []int{}, err
 	}
@@ -29,6 +29,16 @@ func ExpectBegin(mock sqlmock.Sqlmock) {
 	mock.ExpectExec("SET TRANSACTION(.*)").WillReturnResult(fakeResult)
 }
 
+// A driver whose Connect blocks until release is closed, then fails
+type blockingDriver struct {
+	release chan struct{}
+}
+
+func (driver *blockingDriver) Connect(driverName string, dataSourceName string) (*sqlx.DB, error) {
+	<-driver.release
+	return nil, fmt.Errorf("connection released")
+}
+
 func TestDBConn(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "dbconn tests")
@@ -57,6 +67,25 @@ var _ = Describe("dbconn/dbconn tests", func() {
 			connection = dbconn.NewDBConnFromEnvironment("testdb")
 			Expect(connection.DBName).To(Equal("testdb"))
 		})
+		It("gets the user, host, and port from the environment", func() {
+			operating.WithEnv(map[string]string{"PGUSER": "envuser", "PGHOST": "envhost", "PGPORT": "6543"}, func() {
+				connection = dbconn.NewDBConnFromEnvironment("testdb")
+			})
+			Expect(connection.User).To(Equal("envuser"))
+			Expect(connection.Host).To(Equal("envhost"))
+			Expect(connection.Port).To(Equal(6543))
+		})
+		It("defaults to the current user, hostname, and port 5432 if the environment is empty", func() {
+			operating.System.Hostname = func() (string, error) { return "testhost", nil }
+			defer func() { operating.System = operating.InitializeSystemFunctions() }()
+			operating.WithEnv(map[string]string{"PGUSER": "", "PGHOST": "", "PGPORT": ""}, func() {
+				connection = dbconn.NewDBConnFromEnvironment("testdb")
+			})
+			currentUser, _ := operating.System.CurrentUser()
+			Expect(connection.User).To(Equal(currentUser.Username))
+			Expect(connection.Host).To(Equal("testhost"))
+			Expect(connection.Port).To(Equal(5432))
+		})
 		It("gets the DB info", func() {
 			connection = dbconn.NewDBConn("testdb", "testuser", "mars", 1234)
 			Expect(connection.DBName).To(Equal("testdb"))
@@ -149,6 +178,122 @@ var _ = Describe("dbconn/dbconn tests", func() {
 			Expect(err.Error()).To(Equal(`Database "testdb" does not exist on testhost:5432, exiting`))
 		})
 	})
+	Describe("DBConn.ConnectWithContext", func() {
+		refusedErr := fmt.Errorf("pq: connection refused")
+		It("connects on the first attempt and reports progress", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			progress := make([]dbconn.ConnectProgress, 0)
+
+			err := connection.ConnectWithContext(context.Background(), 2, dbconn.ConnectOptions{
+				MaxAttempts: 3,
+				Progress:    func(p dbconn.ConnectProgress) { progress = append(progress, p) },
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connection.NumConns).To(Equal(2))
+			Expect(connection.Version.Is("6")).To(BeTrue())
+			Expect(progress).To(HaveLen(1))
+			Expect(progress[0].Attempt).To(Equal(1))
+			Expect(progress[0].MaxAttempts).To(Equal(3))
+			Expect(progress[0].LastError).ToNot(HaveOccurred())
+		})
+		It("retries failed attempts, passing the previous error to the progress callback", func() {
+			connection, mock = testhelper.CreateMockDBConn(refusedErr, refusedErr)
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			progress := make([]dbconn.ConnectProgress, 0)
+
+			err := connection.ConnectWithContext(context.Background(), 1, dbconn.ConnectOptions{
+				MaxAttempts:   3,
+				RetryInterval: time.Millisecond,
+				Progress:      func(p dbconn.ConnectProgress) { progress = append(progress, p) },
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connection.NumConns).To(Equal(1))
+			Expect(progress).To(HaveLen(3))
+			Expect(progress[2].Attempt).To(Equal(3))
+			Expect(progress[2].LastError).To(MatchError(ContainSubstring("Connection refused")))
+		})
+		It("returns the last error after running out of attempts", func() {
+			connection, mock = testhelper.CreateMockDBConn(refusedErr, refusedErr)
+
+			err := connection.ConnectWithContext(context.Background(), 1, dbconn.ConnectOptions{MaxAttempts: 2, RetryInterval: time.Millisecond})
+
+			Expect(err).To(MatchError(ContainSubstring("Connection refused")))
+			Expect(connection.ConnPool).To(BeNil())
+			Expect(connection.NumConns).To(Equal(0))
+		})
+		It("does not retry if the database does not exist", func() {
+			connection, mock = testhelper.CreateMockDBConn(fmt.Errorf("pq: database \"testdb\" does not exist"))
+			numAttempts := 0
+
+			err := connection.ConnectWithContext(context.Background(), 1, dbconn.ConnectOptions{
+				MaxAttempts: 5,
+				Progress:    func(p dbconn.ConnectProgress) { numAttempts++ },
+			})
+
+			Expect(err).To(MatchError(`Database "testdb" does not exist on testhost:5432, exiting`))
+			Expect(numAttempts).To(Equal(1))
+		})
+		It("returns immediately if the context is canceled during an attempt", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			release := make(chan struct{})
+			defer close(release)
+			connection.Driver = &blockingDriver{release: release}
+			ctx, cancel := context.WithCancel(context.Background())
+
+			err := connection.ConnectWithContext(ctx, 1, dbconn.ConnectOptions{
+				Progress: func(p dbconn.ConnectProgress) { cancel() },
+			})
+
+			Expect(err).To(MatchError("Connection attempt canceled: context canceled"))
+			Expect(connection.ConnPool).To(BeNil())
+		})
+		It("waits between attempts according to the backoff policy", func() {
+			defer func() { operating.System = operating.InitializeSystemFunctions() }()
+			clock := testhelper.MockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			clock.AutoAdvance = true
+			connection, mock = testhelper.CreateMockDBConn(refusedErr, refusedErr, refusedErr)
+			progress := make([]dbconn.ConnectProgress, 0)
+
+			err := connection.ConnectWithContext(context.Background(), 1, dbconn.ConnectOptions{
+				MaxAttempts: 3,
+				Backoff:     dbconn.ExponentialBackoff{Initial: time.Minute},
+				Progress:    func(p dbconn.ConnectProgress) { progress = append(progress, p) },
+			})
+
+			Expect(err).To(MatchError(ContainSubstring("Connection refused")))
+			Expect(clock.Waits).To(Equal([]time.Duration{time.Minute, 2 * time.Minute}))
+			Expect(progress[2].Elapsed).To(Equal(3 * time.Minute))
+		})
+		It("does not retry until the clock reaches the next attempt", func() {
+			defer func() { operating.System = operating.InitializeSystemFunctions() }()
+			clock := testhelper.MockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			connection, mock = testhelper.CreateMockDBConn(refusedErr)
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			result := make(chan error, 1)
+
+			go func() {
+				result <- connection.ConnectWithContext(context.Background(), 1, dbconn.ConnectOptions{MaxAttempts: 2, RetryInterval: time.Hour})
+			}()
+
+			Eventually(clock.NumWaiters).Should(Equal(1))
+			clock.Advance(59 * time.Minute)
+			Consistently(result, 10*time.Millisecond).ShouldNot(Receive())
+			clock.Advance(time.Minute)
+			Eventually(result).Should(Receive(BeNil()))
+		})
+		It("returns if the context is canceled while waiting to retry", func() {
+			connection, mock = testhelper.CreateMockDBConn(refusedErr, refusedErr)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := connection.ConnectWithContext(ctx, 1, dbconn.ConnectOptions{MaxAttempts: 3, RetryInterval: time.Hour})
+
+			Expect(err).To(MatchError("Connection attempt canceled: context canceled"))
+		})
+	})
 	Describe("DBConn.Close", func() {
 		BeforeEach(func() {
 			connection, mock = testhelper.CreateMockDBConn()
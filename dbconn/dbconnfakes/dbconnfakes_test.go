@@ -0,0 +1,123 @@
+package dbconnfakes_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn/dbconnfakes"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDBConnFakes(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "dbconnfakes tests")
+}
+
+type relation struct {
+	Schema string
+	Name   string `db:"relname"`
+	Size   int64
+}
+
+var _ = Describe("dbconnfakes tests", func() {
+	var fake *dbconnfakes.FakeQueryer
+	BeforeEach(func() {
+		fake = dbconnfakes.NewFakeQueryer()
+	})
+	Describe("FakeQueryer", func() {
+		It("can be used as a dbconn.Queryer", func() {
+			var queryer dbconn.Queryer = fake
+			Expect(queryer).ToNot(BeNil())
+		})
+		It("selects registered rows into a slice of structs", func() {
+			fake.WhenQueryContains("FROM pg_class").ReturnRows([]string{"schema", "relname", "size"},
+				[]interface{}{"public", "foo", 8192},
+				[]interface{}{"public", "bar", 0},
+			)
+
+			results := make([]relation, 0)
+			err := fake.Select(&results, "SELECT n.nspname AS schema, c.relname, 0 AS size FROM pg_class c")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]relation{{"public", "foo", 8192}, {"public", "bar", 0}}))
+		})
+		It("gets a single value", func() {
+			fake.WhenQueryMatches(`^SHOW gp_\w+$`).ReturnRows([]string{"setting"}, []interface{}{"on"})
+
+			var setting string
+			err := fake.Get(&setting, "SHOW gp_enable_gpperfmon")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(setting).To(Equal("on"))
+		})
+		It("uses the first matching response", func() {
+			fake.WhenQueryContains("pg_class WHERE").ReturnRows([]string{"count"}, []interface{}{1})
+			fake.WhenQueryContains("pg_class").ReturnRows([]string{"count"}, []interface{}{10})
+
+			var count int
+			Expect(fake.Get(&count, "SELECT count(*) FROM pg_class WHERE relkind = 'r'")).To(Succeed())
+			Expect(count).To(Equal(1))
+			Expect(fake.Get(&count, "SELECT count(*) FROM pg_class")).To(Succeed())
+			Expect(count).To(Equal(10))
+		})
+		It("returns registered errors and errors for unregistered queries", func() {
+			fake.WhenQueryContains("DROP").ReturnError(errors.New("permission denied"))
+
+			_, err := fake.Exec("DROP TABLE foo")
+			Expect(err).To(MatchError("permission denied"))
+
+			_, err = fake.Exec("TRUNCATE foo")
+			Expect(err).To(MatchError("No response registered for query: TRUNCATE foo"))
+		})
+		It("returns the number of rows affected", func() {
+			fake.WhenQueryContains("DELETE").ReturnRowsAffected(3)
+
+			result, err := fake.ExecContext(context.Background(), "DELETE FROM foo", 1)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RowsAffected()).To(Equal(int64(3)))
+		})
+		It("returns rows that can be iterated", func() {
+			fake.WhenQueryContains("generate_series").ReturnRows([]string{"i"}, []interface{}{1}, []interface{}{2})
+
+			rows, err := fake.Query("SELECT generate_series(1, 2) AS i")
+			Expect(err).ToNot(HaveOccurred())
+			defer rows.Close()
+			values := []int{}
+			for rows.Next() {
+				var value int
+				Expect(rows.Scan(&value)).To(Succeed())
+				values = append(values, value)
+			}
+			Expect(values).To(Equal([]int{1, 2}))
+		})
+		It("panics if a row does not match the columns", func() {
+			Expect(func() {
+				fake.WhenQueryContains("foo").ReturnRows([]string{"a", "b"}, []interface{}{1})
+			}).To(PanicWith("Row 0 has 1 values but 2 columns were given"))
+		})
+	})
+	Describe("Call assertions", func() {
+		It("records every query run, with its arguments and connection number", func() {
+			fake.WhenQueryContains("SELECT").ReturnRows([]string{"relname"})
+			names := make([]string, 0)
+
+			_ = fake.Select(&names, "SELECT relname FROM pg_class", 2)
+			_ = fake.SelectWithArgs(&names, "SELECT relname FROM pg_class WHERE relnamespace = $1", 2200)
+			_, _ = fake.Exec("VACUUM")
+
+			Expect(fake.Calls()).To(Equal([]dbconnfakes.FakeCall{
+				{Query: "SELECT relname FROM pg_class", ConnNum: 2},
+				{Query: "SELECT relname FROM pg_class WHERE relnamespace = $1", Args: []interface{}{2200}},
+				{Query: "VACUUM"},
+			}))
+			Expect(fake.CallCount("pg_class")).To(Equal(2))
+			Expect(fake.ReceivedQuery("VACUUM")).To(BeTrue())
+			Expect(fake.ReceivedQuery("ANALYZE")).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,95 @@
+package dbconnfakes
+
+/*
+ * This file contains a fake implementation of dbconn.Connection, for testing
+ * code that manages transactions or depends on the database version without
+ * needing sqlmock or a live database.
+ */
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A FakeConnection answers queries like a FakeQueryer and tracks whether a
+ * transaction is in progress on each connection number, returning the same
+ * errors as a DBConn for beginning a transaction twice or committing without
+ * one.  BEGIN, COMMIT, and ROLLBACK are recorded as calls, so tests can assert
+ * on them with ReceivedQuery, and can make them fail by registering an error
+ * response for them, e.g. fake.WhenQueryContains("COMMIT").ReturnError(err).
+ */
+type FakeConnection struct {
+	*FakeQueryer
+	Version       dbconn.GPDBVersion
+	inTransaction map[int]bool
+}
+
+// versionStr is a semantic version in the form X.Y.Z, as for dbconn.NewVersion
+func NewFakeConnection(versionStr string) *FakeConnection {
+	return &FakeConnection{
+		FakeQueryer:   NewFakeQueryer(),
+		Version:       dbconn.NewVersion(versionStr),
+		inTransaction: make(map[int]bool),
+	}
+}
+
+func (fake *FakeConnection) transactionCommand(command string, connNum int) error {
+	fake.record(command, connNum)
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	for _, response := range fake.responses {
+		if response.matches(command) {
+			return response.err
+		}
+	}
+	return nil
+}
+
+// InTransaction returns whether a transaction is in progress on the given connection
+func (fake *FakeConnection) InTransaction(whichConn ...int) bool {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return fake.inTransaction[connNum(whichConn)]
+}
+
+func (fake *FakeConnection) setTransaction(connNum int, inTransaction bool) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.inTransaction[connNum] = inTransaction
+}
+
+/*
+ * dbconn.Connection functions
+ */
+
+func (fake *FakeConnection) Begin(whichConn ...int) error {
+	if fake.InTransaction(whichConn...) {
+		return errors.New("Cannot begin transaction; there is already a transaction in progress")
+	}
+	if err := fake.transactionCommand("BEGIN", connNum(whichConn)); err != nil {
+		return err
+	}
+	fake.setTransaction(connNum(whichConn), true)
+	return nil
+}
+
+func (fake *FakeConnection) Commit(whichConn ...int) error {
+	if !fake.InTransaction(whichConn...) {
+		return errors.New("Cannot commit transaction; there is no transaction in progress")
+	}
+	fake.setTransaction(connNum(whichConn), false)
+	return fake.transactionCommand("COMMIT", connNum(whichConn))
+}
+
+func (fake *FakeConnection) Rollback(whichConn ...int) error {
+	if !fake.InTransaction(whichConn...) {
+		return errors.New("Cannot rollback transaction; there is no transaction in progress")
+	}
+	fake.setTransaction(connNum(whichConn), false)
+	return fake.transactionCommand("ROLLBACK", connNum(whichConn))
+}
+
+func (fake *FakeConnection) GetVersion() dbconn.GPDBVersion {
+	return fake.Version
+}
@@ -0,0 +1,49 @@
+package dbconnfakes_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn/dbconnfakes"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconnfakes/fakeconnection tests", func() {
+	var fake *dbconnfakes.FakeConnection
+	BeforeEach(func() {
+		fake = dbconnfakes.NewFakeConnection("6.20.0")
+	})
+	Describe("FakeConnection", func() {
+		It("can be used as a dbconn.Connection", func() {
+			var connection dbconn.Connection = fake
+			Expect(connection.GetVersion().AtLeast("6")).To(BeTrue())
+		})
+		It("can be passed to the dbconn selection helpers", func() {
+			fake.WhenQueryContains("current_database").ReturnRows([]string{"datname"}, []interface{}{"testdb"})
+
+			Expect(dbconn.SelectString(fake, "SELECT current_database()")).To(Equal("testdb"))
+		})
+		It("tracks transactions on each connection", func() {
+			Expect(fake.Begin(1)).To(Succeed())
+			Expect(fake.InTransaction(1)).To(BeTrue())
+			Expect(fake.InTransaction()).To(BeFalse())
+			Expect(fake.Begin(1)).To(MatchError("Cannot begin transaction; there is already a transaction in progress"))
+
+			Expect(fake.Commit(1)).To(Succeed())
+			Expect(fake.InTransaction(1)).To(BeFalse())
+			Expect(fake.Commit(1)).To(MatchError("Cannot commit transaction; there is no transaction in progress"))
+			Expect(fake.Rollback()).To(MatchError("Cannot rollback transaction; there is no transaction in progress"))
+
+			Expect(fake.Calls()).To(Equal([]dbconnfakes.FakeCall{{Query: "BEGIN", ConnNum: 1}, {Query: "COMMIT", ConnNum: 1}}))
+		})
+		It("returns errors registered for transaction commands", func() {
+			fake.WhenQueryContains("COMMIT").ReturnError(errors.New("could not serialize access"))
+
+			Expect(fake.Begin()).To(Succeed())
+			Expect(fake.Commit()).To(MatchError("could not serialize access"))
+			Expect(fake.InTransaction()).To(BeFalse())
+			Expect(fake.ReceivedQuery("COMMIT")).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,319 @@
+package dbconnfakes
+
+/*
+ * This file contains a fake implementation of dbconn.Queryer that returns
+ * canned results from memory, for testing code that runs queries without
+ * needing sqlmock or a live database.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A FakeQueryer answers each query with the response registered for the first
+ * matching pattern, in the order in which the responses were registered, and
+ * returns an error for queries that match no response.  Results are scanned
+ * into destinations by sqlx exactly as they are for a DBConn, so struct fields
+ * are matched to columns using the same db tags.
+ *
+ * Every query run is recorded, whether or not it matched a response, so that
+ * tests can assert on the queries their code ran.
+ */
+type FakeQueryer struct {
+	db        *sqlx.DB
+	mutex     sync.Mutex
+	responses []*FakeResponse
+	calls     []FakeCall
+}
+
+type FakeCall struct {
+	Query   string
+	Args    []interface{}
+	ConnNum int
+}
+
+type FakeResponse struct {
+	matches      func(query string) bool
+	columns      []string
+	rows         [][]driver.Value
+	rowsAffected int64
+	err          error
+}
+
+func NewFakeQueryer() *FakeQueryer {
+	fake := &FakeQueryer{}
+	fake.db = sqlx.NewDb(sql.OpenDB(&fakeConnector{fake: fake}), "dbconnfakes")
+	return fake
+}
+
+func (fake *FakeQueryer) addResponse(matches func(query string) bool) *FakeResponse {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	response := &FakeResponse{matches: matches, columns: []string{}, rows: [][]driver.Value{}}
+	fake.responses = append(fake.responses, response)
+	return response
+}
+
+// WhenQueryContains registers a response for queries containing substring
+func (fake *FakeQueryer) WhenQueryContains(substring string) *FakeResponse {
+	return fake.addResponse(func(query string) bool {
+		return strings.Contains(query, substring)
+	})
+}
+
+// WhenQueryMatches registers a response for queries matching the given regular expression
+func (fake *FakeQueryer) WhenQueryMatches(pattern string) *FakeResponse {
+	queryRegexp := regexp.MustCompile(pattern)
+	return fake.addResponse(queryRegexp.MatchString)
+}
+
+/*
+ * ReturnRows sets the columns and rows returned by the query.  Values may be
+ * of any type accepted as a query argument by database/sql, e.g. int or string.
+ */
+func (response *FakeResponse) ReturnRows(columns []string, rows ...[]interface{}) *FakeResponse {
+	response.columns = columns
+	response.rows = make([][]driver.Value, len(rows))
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			panic(fmt.Sprintf("Row %d has %d values but %d columns were given", i, len(row), len(columns)))
+		}
+		response.rows[i] = make([]driver.Value, len(row))
+		for j, value := range row {
+			converted, err := driver.DefaultParameterConverter.ConvertValue(value)
+			if err != nil {
+				panic(fmt.Sprintf("Cannot use value %v in row %d: %v", value, i, err))
+			}
+			response.rows[i][j] = converted
+		}
+	}
+	return response
+}
+
+// ReturnRowsAffected sets the number of rows reported as affected by Exec
+func (response *FakeResponse) ReturnRowsAffected(rowsAffected int64) *FakeResponse {
+	response.rowsAffected = rowsAffected
+	return response
+}
+
+func (response *FakeResponse) ReturnError(err error) *FakeResponse {
+	response.err = err
+	return response
+}
+
+func (fake *FakeQueryer) respond(query string) (*FakeResponse, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	for _, response := range fake.responses {
+		if response.matches(query) {
+			if response.err != nil {
+				return nil, response.err
+			}
+			return response, nil
+		}
+	}
+	return nil, errors.Errorf("No response registered for query: %s", query)
+}
+
+func (fake *FakeQueryer) record(query string, connNum int, args ...interface{}) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.calls = append(fake.calls, FakeCall{Query: query, Args: args, ConnNum: connNum})
+}
+
+/*
+ * Call assertion functions
+ */
+
+func (fake *FakeQueryer) Calls() []FakeCall {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return append([]FakeCall{}, fake.calls...)
+}
+
+// CallCount returns the number of queries run that contained substring
+func (fake *FakeQueryer) CallCount(substring string) int {
+	count := 0
+	for _, call := range fake.Calls() {
+		if strings.Contains(call.Query, substring) {
+			count++
+		}
+	}
+	return count
+}
+
+func (fake *FakeQueryer) ReceivedQuery(substring string) bool {
+	return fake.CallCount(substring) > 0
+}
+
+/*
+ * dbconn.Queryer functions
+ */
+
+func connNum(whichConn []int) int {
+	if len(whichConn) == 0 {
+		return 0
+	}
+	return whichConn[0]
+}
+
+func (fake *FakeQueryer) Exec(query string, whichConn ...int) (sql.Result, error) {
+	fake.record(query, connNum(whichConn))
+	return fake.db.Exec(query)
+}
+
+func (fake *FakeQueryer) ExecContext(queryContext context.Context, query string, whichConn ...int) (sql.Result, error) {
+	fake.record(query, connNum(whichConn))
+	return fake.db.ExecContext(queryContext, query)
+}
+
+func (fake *FakeQueryer) Get(destination interface{}, query string, whichConn ...int) error {
+	fake.record(query, connNum(whichConn))
+	return fake.db.Get(destination, query)
+}
+
+func (fake *FakeQueryer) GetWithArgs(destination interface{}, query string, args ...interface{}) error {
+	fake.record(query, 0, args...)
+	return fake.db.Get(destination, query, args...)
+}
+
+func (fake *FakeQueryer) Select(destination interface{}, query string, whichConn ...int) error {
+	fake.record(query, connNum(whichConn))
+	return fake.db.Select(destination, query)
+}
+
+func (fake *FakeQueryer) SelectWithArgs(destination interface{}, query string, args ...interface{}) error {
+	fake.record(query, 0, args...)
+	return fake.db.Select(destination, query, args...)
+}
+
+func (fake *FakeQueryer) SelectContext(ctx context.Context, destination interface{}, query string, whichConn ...int) error {
+	fake.record(query, connNum(whichConn))
+	return fake.db.SelectContext(ctx, destination, query)
+}
+
+func (fake *FakeQueryer) Query(query string, whichConn ...int) (*dbconn.Rows, error) {
+	fake.record(query, connNum(whichConn))
+	return wrapRows(fake.db.Queryx(query))
+}
+
+func (fake *FakeQueryer) QueryWithArgs(query string, args ...interface{}) (*dbconn.Rows, error) {
+	fake.record(query, 0, args...)
+	return wrapRows(fake.db.Queryx(query, args...))
+}
+
+func (fake *FakeQueryer) QueryContext(ctx context.Context, query string, whichConn ...int) (*dbconn.Rows, error) {
+	fake.record(query, connNum(whichConn))
+	return wrapRows(fake.db.QueryxContext(ctx, query))
+}
+
+func wrapRows(rows *sqlx.Rows, err error) (*dbconn.Rows, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &dbconn.Rows{Rows: rows}, nil
+}
+
+/*
+ * The following types implement a minimal database/sql driver that looks up
+ * responses in the FakeQueryer, so that sqlx can scan results as usual.
+ */
+
+type fakeConnector struct {
+	fake *FakeQueryer
+}
+
+func (connector *fakeConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return &fakeConn{fake: connector.fake}, nil
+}
+
+func (connector *fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(_ string) (driver.Conn, error) {
+	return nil, errors.New("The dbconnfakes driver can only be used through a FakeQueryer")
+}
+
+type fakeConn struct {
+	fake *FakeQueryer
+}
+
+func (conn *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{fake: conn.fake, query: query}, nil
+}
+
+func (conn *fakeConn) Close() error {
+	return nil
+}
+
+func (conn *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("Transactions are not supported by FakeQueryer")
+}
+
+type fakeStmt struct {
+	fake  *FakeQueryer
+	query string
+}
+
+func (stmt *fakeStmt) Close() error {
+	return nil
+}
+
+// Returning -1 tells database/sql not to check the number of arguments
+func (stmt *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (stmt *fakeStmt) Exec(_ []driver.Value) (driver.Result, error) {
+	response, err := stmt.fake.respond(stmt.query)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(response.rowsAffected), nil
+}
+
+func (stmt *fakeStmt) Query(_ []driver.Value) (driver.Rows, error) {
+	response, err := stmt.fake.respond(stmt.query)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: response.columns, rows: response.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	index   int
+}
+
+func (rows *fakeRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *fakeRows) Close() error {
+	return nil
+}
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if rows.index >= len(rows.rows) {
+		return io.EOF
+	}
+	copy(dest, rows.rows[rows.index])
+	rows.index++
+	return nil
+}
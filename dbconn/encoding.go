@@ -0,0 +1,169 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to handling databases
+ * whose encoding is not UTF-8.
+ */
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/text/encoding/charmap"
+)
+
+/*
+ * EncodingInfo holds the server_encoding and client_encoding reported by the
+ * server when the connection was established.  Both are empty if they could
+ * not be determined, e.g. when using a mock driver.
+ */
+type EncodingInfo struct {
+	ServerEncoding string
+	ClientEncoding string
+}
+
+// An unknown client encoding is assumed to be UTF-8, so that results are left unchanged
+func (info EncodingInfo) IsUTF8() bool {
+	return info.ClientEncoding == "" || info.ClientEncoding == "UTF8"
+}
+
+/*
+ * A LossyConversionWarning is reported when transcoding query results to
+ * UTF-8 replaces one or more characters that have no valid representation
+ * with the Unicode replacement character.
+ */
+type LossyConversionWarning struct {
+	Encoding    string
+	Query       string
+	NumReplaced int
+}
+
+func (warning LossyConversionWarning) String() string {
+	return fmt.Sprintf("Replaced %d characters that are not valid in encoding %s with U+FFFD when converting the results of query %s to UTF-8",
+		warning.NumReplaced, warning.Encoding, querySnippet(warning.Query))
+}
+
+var charmapsByEncoding = map[string]*charmap.Charmap{
+	"LATIN1":     charmap.ISO8859_1,
+	"LATIN2":     charmap.ISO8859_2,
+	"LATIN3":     charmap.ISO8859_3,
+	"LATIN4":     charmap.ISO8859_4,
+	"LATIN5":     charmap.ISO8859_9,
+	"LATIN6":     charmap.ISO8859_10,
+	"LATIN7":     charmap.ISO8859_13,
+	"LATIN8":     charmap.ISO8859_14,
+	"LATIN9":     charmap.ISO8859_15,
+	"LATIN10":    charmap.ISO8859_16,
+	"ISO_8859_5": charmap.ISO8859_5,
+	"ISO_8859_6": charmap.ISO8859_6,
+	"ISO_8859_7": charmap.ISO8859_7,
+	"ISO_8859_8": charmap.ISO8859_8,
+	"KOI8R":      charmap.KOI8R,
+	"KOI8U":      charmap.KOI8U,
+	"WIN866":     charmap.CodePage866,
+	"WIN874":     charmap.Windows874,
+	"WIN1250":    charmap.Windows1250,
+	"WIN1251":    charmap.Windows1251,
+	"WIN1252":    charmap.Windows1252,
+	"WIN1253":    charmap.Windows1253,
+	"WIN1254":    charmap.Windows1254,
+	"WIN1255":    charmap.Windows1255,
+	"WIN1256":    charmap.Windows1256,
+	"WIN1257":    charmap.Windows1257,
+	"WIN1258":    charmap.Windows1258,
+}
+
+/*
+ * TranscodeToUTF8 converts value from the given PostgreSQL encoding to UTF-8,
+ * returning the converted string and the number of characters that had to be
+ * replaced with U+FFFD.
+ *
+ * SQL_ASCII databases may contain data in any encoding, and commonly contain
+ * UTF-8, so for SQL_ASCII (and any other encoding not supported here) valid
+ * UTF-8 sequences are kept and every other non-ASCII byte is replaced.
+ */
+func TranscodeToUTF8(value string, encoding string) (string, int) {
+	if encoding == "" || encoding == "UTF8" {
+		return value, 0
+	}
+	if encodingMap, ok := charmapsByEncoding[encoding]; ok {
+		numReplaced := 0
+		var builder strings.Builder
+		for i := 0; i < len(value); i++ {
+			char := encodingMap.DecodeByte(value[i])
+			if char == utf8.RuneError {
+				numReplaced++
+			}
+			builder.WriteRune(char)
+		}
+		return builder.String(), numReplaced
+	}
+
+	if utf8.ValidString(value) {
+		return value, 0
+	}
+	numReplaced := 0
+	var builder strings.Builder
+	for len(value) > 0 {
+		char, size := utf8.DecodeRuneInString(value)
+		if char == utf8.RuneError && size == 1 {
+			numReplaced++
+		}
+		builder.WriteRune(char)
+		value = value[size:]
+	}
+	return builder.String(), numReplaced
+}
+
+/*
+ * If TranscodeToUTF8 is set on a DBConn, the string selection helpers convert
+ * their results from the client encoding to UTF-8 and report lossy conversions
+ * to this function, which logs a warning by default.  Other query functions
+ * return results unchanged; callers scanning into structs can convert fields
+ * with TranscodeToUTF8 directly.
+ */
+func (dbconn *DBConn) transcodeResults(query string, results []string) []string {
+	if !dbconn.TranscodeToUTF8 || dbconn.Encoding.IsUTF8() {
+		return results
+	}
+	numReplaced := 0
+	for i, result := range results {
+		var replaced int
+		results[i], replaced = TranscodeToUTF8(result, dbconn.Encoding.ClientEncoding)
+		numReplaced += replaced
+	}
+	if numReplaced > 0 {
+		warning := LossyConversionWarning{Encoding: dbconn.Encoding.ClientEncoding, Query: query, NumReplaced: numReplaced}
+		if dbconn.OnLossyConversion != nil {
+			dbconn.OnLossyConversion(warning)
+		} else {
+			logDomain.Warn("%s", warning)
+		}
+	}
+	return results
+}
+
+/*
+ * As with getBackendPID, the encodings are read from the parameters the server
+ * reported when the connection was established, so no query is issued.
+ */
+func getEncodingInfo(conn *sqlx.DB) EncodingInfo {
+	info := EncodingInfo{}
+	sqlConn, err := conn.Conn(context.Background())
+	if err != nil {
+		return info
+	}
+	defer sqlConn.Close()
+	_ = sqlConn.Raw(func(driverConn interface{}) error {
+		if pgxConn, ok := driverConn.(*stdlib.Conn); ok {
+			info.ServerEncoding = pgxConn.Conn().PgConn().ParameterStatus("server_encoding")
+			info.ClientEncoding = pgxConn.Conn().PgConn().ParameterStatus("client_encoding")
+		}
+		return nil
+	})
+	return info
+}
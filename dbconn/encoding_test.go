@@ -0,0 +1,97 @@
+package dbconn_test
+
+import (
+	"database/sql/driver"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/encoding tests", func() {
+	Describe("TranscodeToUTF8", func() {
+		It("leaves UTF-8 strings unchanged", func() {
+			Expect(dbconn.TranscodeToUTF8("café", "UTF8")).To(Equal("café"))
+			Expect(dbconn.TranscodeToUTF8("caf\xe9", "")).To(Equal("caf\xe9"))
+		})
+		It("converts single-byte encodings", func() {
+			result, numReplaced := dbconn.TranscodeToUTF8("caf\xe9", "LATIN1")
+			Expect(result).To(Equal("café"))
+			Expect(numReplaced).To(Equal(0))
+
+			result, numReplaced = dbconn.TranscodeToUTF8("\x80 \xa4", "WIN1252")
+			Expect(result).To(Equal("€ ¤"))
+			Expect(numReplaced).To(Equal(0))
+
+			result, _ = dbconn.TranscodeToUTF8("\xd0\xd2\xc9\xd7\xc5\xd4", "KOI8R")
+			Expect(result).To(Equal("привет"))
+		})
+		It("counts bytes that are not defined in the encoding", func() {
+			result, numReplaced := dbconn.TranscodeToUTF8("a\x81b", "WIN1252")
+			Expect(result).To(Equal("a�b"))
+			Expect(numReplaced).To(Equal(1))
+		})
+		It("keeps valid UTF-8 and replaces other bytes in SQL_ASCII strings", func() {
+			Expect(dbconn.TranscodeToUTF8("café", "SQL_ASCII")).To(Equal("café"))
+
+			result, numReplaced := dbconn.TranscodeToUTF8("café caf\xe9 \xff", "SQL_ASCII")
+			Expect(result).To(Equal("café caf� �"))
+			Expect(numReplaced).To(Equal(2))
+		})
+	})
+	Describe("SelectStringSlice with transcoding", func() {
+		var warnings []dbconn.LossyConversionWarning
+		BeforeEach(func() {
+			warnings = nil
+			connection.Encoding = dbconn.EncodingInfo{ServerEncoding: "LATIN1", ClientEncoding: "LATIN1"}
+			connection.OnLossyConversion = func(warning dbconn.LossyConversionWarning) {
+				warnings = append(warnings, warning)
+			}
+		})
+		AfterEach(func() {
+			connection.Encoding = dbconn.EncodingInfo{}
+			connection.TranscodeToUTF8 = false
+			connection.OnLossyConversion = nil
+		})
+		expectRows := func(values ...string) {
+			rows := sqlmock.NewRows([]string{"name"})
+			for _, value := range values {
+				rows.AddRow(driver.Value([]byte(value)))
+			}
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(rows)
+		}
+		It("returns results unchanged unless transcoding is enabled", func() {
+			expectRows("caf\xe9")
+			Expect(dbconn.MustSelectStringSlice(connection, "SELECT name FROM foo")).To(Equal([]string{"caf\xe9"}))
+		})
+		It("converts results to UTF-8 when transcoding is enabled", func() {
+			connection.TranscodeToUTF8 = true
+			expectRows("caf\xe9", "na\xefve")
+			Expect(dbconn.MustSelectStringSlice(connection, "SELECT name FROM foo")).To(Equal([]string{"café", "naïve"}))
+			Expect(warnings).To(BeEmpty())
+		})
+		It("reports lossy conversions", func() {
+			connection.TranscodeToUTF8 = true
+			connection.Encoding.ClientEncoding = "SQL_ASCII"
+			expectRows("caf\xe9", "\xff\xfe")
+			results := dbconn.MustSelectStringSlice(connection, "SELECT name FROM foo")
+
+			Expect(results).To(Equal([]string{"caf�", "��"}))
+			Expect(warnings).To(Equal([]dbconn.LossyConversionWarning{{Encoding: "SQL_ASCII", Query: "SELECT name FROM foo", NumReplaced: 3}}))
+		})
+		It("logs a warning by default", func() {
+			_, _, logfile := testhelper.SetupTestLogger()
+			connection.TranscodeToUTF8 = true
+			connection.OnLossyConversion = nil
+			connection.Encoding.ClientEncoding = "SQL_ASCII"
+			expectRows("\xff")
+
+			dbconn.MustSelectStringSlice(connection, "SELECT name FROM foo")
+
+			testhelper.ExpectRegexp(logfile, "[WARNING]:-Replaced 1 characters that are not valid in encoding SQL_ASCII with U+FFFD when converting the results of query SELECT name FROM foo to UTF-8")
+		})
+	})
+})
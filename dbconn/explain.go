@@ -0,0 +1,192 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to safely running EXPLAIN
+ * and EXPLAIN ANALYZE and parsing the resulting query plans.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultExplainTimeout = 30 * time.Second
+
+type ExplainOptions struct {
+	Analyze bool          // Run EXPLAIN ANALYZE, which executes the query
+	Verbose bool          // Run EXPLAIN VERBOSE
+	Timeout time.Duration // The statement_timeout for the EXPLAIN; defaults to DefaultExplainTimeout
+	ConnNum int           // The connection on which to run the EXPLAIN
+}
+
+/*
+ * A PlanNode is a single node of a query plan, e.g. "Seq Scan on foo", along
+ * with the planner's estimates for it and any additional detail lines such as
+ * "Filter: (a = 1)".  ActualTotalTime and ActualRows are only set for plans
+ * produced by EXPLAIN ANALYZE on versions that report them per node.
+ */
+type PlanNode struct {
+	Description     string
+	StartupCost     float64
+	TotalCost       float64
+	Rows            int64
+	Width           int
+	ActualTotalTime float64
+	ActualRows      int64
+	Details         []string
+	Children        []*PlanNode
+}
+
+/*
+ * A Plan stores the parsed plan tree along with the raw EXPLAIN output.
+ * Summary contains plan-level lines that are not part of any node, such as
+ * "Optimizer: Postgres query optimizer" or "Execution time: 1.234 ms".
+ */
+type Plan struct {
+	Root    *PlanNode
+	Summary []string
+	Lines   []string
+}
+
+var (
+	explainCommentRegex      = regexp.MustCompile(`(?s)/\*.*?\*/|--[^\n]*`)
+	explainModifyingRegex    = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|MERGE|TRUNCATE|INTO)\b`)
+	explainReadOnlyStmtRegex = regexp.MustCompile(`(?i)^\(*\s*(SELECT|WITH|VALUES|TABLE)\b`)
+	planCostRegex            = regexp.MustCompile(`^(.*?)\s+\(cost=([\d.]+)\.\.([\d.]+) rows=(\d+) width=(\d+)\)(?:\s+\(actual time=[\d.]+\.\.([\d.]+) rows=(\d+) loops=\d+\))?`)
+)
+
+/*
+ * Because EXPLAIN ANALYZE actually executes the statement, it is only allowed
+ * for statements that look like queries.  This check is deliberately
+ * conservative (e.g. a SELECT referencing a column named "into" is refused),
+ * and the read-only transaction in SafeExplain remains the real safeguard.
+ */
+func isDataModifyingStatement(query string) bool {
+	stripped := strings.TrimSpace(explainCommentRegex.ReplaceAllString(query, " "))
+	if !explainReadOnlyStmtRegex.MatchString(stripped) {
+		return true
+	}
+	return explainModifyingRegex.MatchString(stripped)
+}
+
+/*
+ * SafeExplain runs EXPLAIN on the given query inside a read-only transaction
+ * with a statement_timeout, so that it can be used for diagnostics on busy or
+ * production systems, and returns the parsed plan.  The transaction is always
+ * rolled back, and an error is returned if there is already a transaction in
+ * progress on the connection.  EXPLAIN ANALYZE is refused for statements that
+ * may modify data.
+ */
+func (dbconn *DBConn) SafeExplain(query string, opts ExplainOptions) (*Plan, error) {
+	if opts.Analyze && isDataModifyingStatement(query) {
+		return nil, errors.New("Refusing to run EXPLAIN ANALYZE on a statement that may modify data")
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExplainTimeout
+	}
+	connNum := dbconn.ValidateConnNum(opts.ConnNum)
+
+	err := dbconn.Begin(connNum)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to begin transaction for EXPLAIN")
+	}
+	defer func() { _ = dbconn.Rollback(connNum) }()
+	_, err = dbconn.Exec("SET TRANSACTION READ ONLY", connNum)
+	if err != nil {
+		return nil, err
+	}
+	_, err = dbconn.Exec(fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds()), connNum)
+	if err != nil {
+		return nil, err
+	}
+
+	explain := "EXPLAIN"
+	if opts.Analyze {
+		explain += " ANALYZE"
+	}
+	if opts.Verbose {
+		explain += " VERBOSE"
+	}
+	lines, err := SelectStringSlice(dbconn, fmt.Sprintf("%s %s", explain, query), connNum)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePlan(lines)
+}
+
+func newPlanNode(text string) *PlanNode {
+	node := &PlanNode{Description: strings.TrimSpace(text), Details: []string{}, Children: []*PlanNode{}}
+	match := planCostRegex.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil {
+		return node
+	}
+	node.Description = match[1]
+	node.StartupCost, _ = strconv.ParseFloat(match[2], 64)
+	node.TotalCost, _ = strconv.ParseFloat(match[3], 64)
+	node.Rows, _ = strconv.ParseInt(match[4], 10, 64)
+	node.Width, _ = strconv.Atoi(match[5])
+	if match[6] != "" {
+		node.ActualTotalTime, _ = strconv.ParseFloat(match[6], 64)
+		node.ActualRows, _ = strconv.ParseInt(match[7], 10, 64)
+	}
+	return node
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+/*
+ * ParsePlan parses text-format EXPLAIN output, one line per element of lines,
+ * into a Plan.  Child nodes are the lines beginning with "->", and their depth
+ * is determined by their indentation; any other indented line is a detail of
+ * the closest preceding node at a lower indentation.
+ */
+func ParsePlan(lines []string) (*Plan, error) {
+	if len(lines) == 0 {
+		return nil, errors.New("EXPLAIN returned no output")
+	}
+	type stackEntry struct {
+		node   *PlanNode
+		indent int // The column at which the node's text starts
+	}
+	plan := &Plan{Root: newPlanNode(lines[0]), Summary: []string{}, Lines: lines}
+	stack := []stackEntry{{plan.Root, leadingSpaces(lines[0])}}
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := leadingSpaces(line)
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "->") {
+			for len(stack) > 1 && stack[len(stack)-1].indent > indent {
+				stack = stack[:len(stack)-1]
+			}
+			node := newPlanNode(strings.TrimPrefix(trimmed, "->"))
+			parent := stack[len(stack)-1].node
+			parent.Children = append(parent.Children, node)
+			textIndent := indent + len(trimmed) - len(strings.TrimLeft(strings.TrimPrefix(trimmed, "->"), " "))
+			stack = append(stack, stackEntry{node, textIndent})
+			continue
+		}
+		if indent <= stack[0].indent {
+			plan.Summary = append(plan.Summary, trimmed)
+			continue
+		}
+		owner := stack[0].node
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i].indent < indent {
+				owner = stack[i].node
+				break
+			}
+		}
+		owner.Details = append(owner.Details, trimmed)
+	}
+	return plan, nil
+}
@@ -0,0 +1,146 @@
+package dbconn_test
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/explain tests", func() {
+	planLines := []string{
+		"Gather Motion 3:1  (slice1; segments: 3)  (cost=0.00..431.00 rows=2 width=8)",
+		"  ->  Hash Join  (cost=0.00..431.00 rows=1 width=8)",
+		"        Hash Cond: (foo.a = bar.a)",
+		"        ->  Seq Scan on foo  (cost=0.00..431.00 rows=1 width=4)",
+		"              Filter: (a > 1)",
+		"        ->  Hash  (cost=431.00..431.00 rows=1 width=4)",
+		"              ->  Seq Scan on bar  (cost=0.00..431.00 rows=1 width=4)",
+		"Optimizer: Pivotal Optimizer (GPORCA)",
+	}
+	expectExplain := func(explain string, lines []string) {
+		fakeResult := testhelper.TestResult{Rows: 0}
+		ExpectBegin(mock)
+		mock.ExpectExec("SET TRANSACTION READ ONLY").WillReturnResult(fakeResult)
+		mock.ExpectExec("SET LOCAL statement_timeout = 30000").WillReturnResult(fakeResult)
+		rows := sqlmock.NewRows([]string{"QUERY PLAN"})
+		for _, line := range lines {
+			rows.AddRow(line)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta(explain)).WillReturnRows(rows)
+		mock.ExpectRollback()
+	}
+	Describe("DBConn.SafeExplain", func() {
+		It("runs EXPLAIN in a read-only transaction with a timeout and rolls it back", func() {
+			expectExplain("EXPLAIN SELECT * FROM foo", planLines)
+
+			plan, err := connection.SafeExplain("SELECT * FROM foo", dbconn.ExplainOptions{})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.Root.Description).To(Equal("Gather Motion 3:1  (slice1; segments: 3)"))
+			Expect(plan.Lines).To(Equal(planLines))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(connection.Tx[0]).To(BeNil())
+		})
+		It("uses the given timeout and runs EXPLAIN ANALYZE VERBOSE for a query", func() {
+			fakeResult := testhelper.TestResult{Rows: 0}
+			ExpectBegin(mock)
+			mock.ExpectExec("SET TRANSACTION READ ONLY").WillReturnResult(fakeResult)
+			mock.ExpectExec("SET LOCAL statement_timeout = 5000").WillReturnResult(fakeResult)
+			mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN ANALYZE VERBOSE WITH x AS (SELECT 1) SELECT * FROM x")).WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow("Result  (cost=0.00..0.01 rows=1 width=4) (actual time=0.002..0.003 rows=1 loops=1)"))
+			mock.ExpectRollback()
+
+			plan, err := connection.SafeExplain("WITH x AS (SELECT 1) SELECT * FROM x", dbconn.ExplainOptions{Analyze: true, Verbose: true, Timeout: 5 * time.Second})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.Root.ActualTotalTime).To(Equal(0.003))
+			Expect(plan.Root.ActualRows).To(Equal(int64(1)))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		DescribeTable("refuses to run EXPLAIN ANALYZE on data-modifying statements",
+			func(query string) {
+				plan, err := connection.SafeExplain(query, dbconn.ExplainOptions{Analyze: true})
+				Expect(err).To(MatchError("Refusing to run EXPLAIN ANALYZE on a statement that may modify data"))
+				Expect(plan).To(BeNil())
+			},
+			Entry("INSERT", "INSERT INTO foo VALUES (1)"),
+			Entry("UPDATE with a leading comment", "/* comment */ UPDATE foo SET a = 1"),
+			Entry("DELETE", "delete from foo"),
+			Entry("a data-modifying CTE", "WITH x AS (DELETE FROM foo RETURNING *) SELECT * FROM x"),
+			Entry("SELECT INTO", "SELECT * INTO bar FROM foo"),
+			Entry("CREATE TABLE AS", "CREATE TABLE bar AS SELECT * FROM foo"),
+		)
+		It("allows EXPLAIN without ANALYZE on data-modifying statements", func() {
+			expectExplain("EXPLAIN DELETE FROM foo", []string{"Delete  (cost=0.00..1.00 rows=1 width=6)"})
+
+			plan, err := connection.SafeExplain("DELETE FROM foo", dbconn.ExplainOptions{})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.Root.Description).To(Equal("Delete"))
+		})
+		It("returns an error if a transaction is already in progress", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+
+			_, err := connection.SafeExplain("SELECT 1", dbconn.ExplainOptions{})
+
+			Expect(err).To(MatchError("Unable to begin transaction for EXPLAIN: Cannot begin transaction; there is already a transaction in progress"))
+		})
+		It("rolls back the transaction if the EXPLAIN fails", func() {
+			fakeResult := testhelper.TestResult{Rows: 0}
+			ExpectBegin(mock)
+			mock.ExpectExec("SET TRANSACTION READ ONLY").WillReturnResult(fakeResult)
+			mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(fakeResult)
+			mock.ExpectQuery("EXPLAIN").WillReturnError(fmt.Errorf("canceling statement due to statement timeout"))
+			mock.ExpectRollback()
+
+			_, err := connection.SafeExplain("SELECT pg_sleep(100)", dbconn.ExplainOptions{})
+
+			Expect(err).To(MatchError("canceling statement due to statement timeout"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(connection.Tx[0]).To(BeNil())
+		})
+	})
+	Describe("ParsePlan", func() {
+		It("parses a plan into a tree of nodes", func() {
+			plan, err := dbconn.ParsePlan(planLines)
+
+			Expect(err).ToNot(HaveOccurred())
+			root := plan.Root
+			Expect(root.TotalCost).To(Equal(431.0))
+			Expect(root.Rows).To(Equal(int64(2)))
+			Expect(root.Width).To(Equal(8))
+			Expect(root.Children).To(HaveLen(1))
+			hashJoin := root.Children[0]
+			Expect(hashJoin.Description).To(Equal("Hash Join"))
+			Expect(hashJoin.Details).To(Equal([]string{"Hash Cond: (foo.a = bar.a)"}))
+			Expect(hashJoin.Children).To(HaveLen(2))
+			Expect(hashJoin.Children[0].Description).To(Equal("Seq Scan on foo"))
+			Expect(hashJoin.Children[0].Details).To(Equal([]string{"Filter: (a > 1)"}))
+			Expect(hashJoin.Children[0].Children).To(BeEmpty())
+			hash := hashJoin.Children[1]
+			Expect(hash.Description).To(Equal("Hash"))
+			Expect(hash.StartupCost).To(Equal(431.0))
+			Expect(hash.Children).To(HaveLen(1))
+			Expect(hash.Children[0].Description).To(Equal("Seq Scan on bar"))
+			Expect(plan.Summary).To(Equal([]string{"Optimizer: Pivotal Optimizer (GPORCA)"}))
+		})
+		It("keeps the whole line as the description of a node without costs", func() {
+			plan, err := dbconn.ParsePlan([]string{"Result", "  One-Time Filter: false"})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.Root.Description).To(Equal("Result"))
+			Expect(plan.Root.Details).To(Equal([]string{"One-Time Filter: false"}))
+		})
+		It("returns an error for empty output", func() {
+			_, err := dbconn.ParsePlan([]string{})
+			Expect(err).To(MatchError("EXPLAIN returned no output"))
+		})
+	})
+})
@@ -0,0 +1,106 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to validating identifiers
+ * before using them to create database objects.
+ */
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Identifiers longer than NAMEDATALEN-1 bytes are silently truncated by the server
+const NAMEDATALEN = 64
+
+type IdentifierProblem string
+
+const (
+	IdentifierEmpty           IdentifierProblem = "is empty"
+	IdentifierTooLong         IdentifierProblem = "is too long"
+	IdentifierReservedWord    IdentifierProblem = "is a reserved word"
+	IdentifierInvalidEncoding IdentifierProblem = "contains invalid characters"
+)
+
+type IdentifierError struct {
+	Identifier string
+	Problem    IdentifierProblem
+	Detail     string
+}
+
+func (err *IdentifierError) Error() string {
+	message := fmt.Sprintf(`Identifier "%s" %s`, err.Identifier, err.Problem)
+	if err.Detail != "" {
+		message += ": " + err.Detail
+	}
+	return message
+}
+
+/*
+ * These are the keywords that cannot be used as unquoted identifiers in any
+ * supported version.  LATERAL only became reserved in PostgreSQL 9.3, so it is
+ * only reserved from GPDB 6 onward.
+ */
+var reservedWords = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true, "array": true,
+	"as": true, "asc": true, "asymmetric": true, "both": true, "case": true, "cast": true,
+	"check": true, "collate": true, "column": true, "constraint": true, "create": true,
+	"current_date": true, "current_role": true, "current_time": true, "current_timestamp": true,
+	"current_user": true, "default": true, "deferrable": true, "desc": true, "distinct": true,
+	"distributed": true, "do": true, "else": true, "end": true, "except": true, "false": true,
+	"for": true, "foreign": true, "from": true, "grant": true, "group": true, "having": true,
+	"in": true, "initially": true, "intersect": true, "into": true, "leading": true,
+	"limit": true, "localtime": true, "localtimestamp": true, "not": true, "null": true,
+	"offset": true, "on": true, "only": true, "or": true, "order": true, "placing": true,
+	"primary": true, "references": true, "returning": true, "scatter": true, "select": true,
+	"session_user": true, "some": true, "symmetric": true, "table": true, "then": true,
+	"to": true, "trailing": true, "true": true, "union": true, "unique": true, "user": true,
+	"using": true, "when": true, "where": true, "with": true,
+}
+
+var reservedWordsSinceGPDB6 = map[string]bool{
+	"lateral": true,
+}
+
+func isReservedWord(name string, version GPDBVersion) bool {
+	lowerName := strings.ToLower(name)
+	if reservedWords[lowerName] {
+		return true
+	}
+	// A zero version means the version is unknown, so assume the latest
+	return reservedWordsSinceGPDB6[lowerName] && (version.VersionString == "" || version.AtLeast("6"))
+}
+
+/*
+ * ValidateIdentifierForVersion checks that name can be used to create an
+ * object in the given version of GPDB, and returns an *IdentifierError
+ * describing the first problem found, if any.  Reserved words can still be
+ * used if they are quoted, so callers that always quote identifiers may
+ * choose to ignore errors with the IdentifierReservedWord problem.
+ */
+func ValidateIdentifierForVersion(name string, version GPDBVersion) error {
+	if name == "" {
+		return &IdentifierError{Identifier: name, Problem: IdentifierEmpty}
+	}
+	if !utf8.ValidString(name) {
+		return &IdentifierError{Identifier: name, Problem: IdentifierInvalidEncoding, Detail: "identifier is not valid UTF-8"}
+	}
+	for _, char := range name {
+		if char == 0 || unicode.IsControl(char) {
+			return &IdentifierError{Identifier: name, Problem: IdentifierInvalidEncoding, Detail: fmt.Sprintf("identifier contains control character %U", char)}
+		}
+	}
+	if len(name) >= NAMEDATALEN {
+		return &IdentifierError{Identifier: name, Problem: IdentifierTooLong, Detail: fmt.Sprintf("identifier is %d bytes, but the maximum is %d bytes", len(name), NAMEDATALEN-1)}
+	}
+	if isReservedWord(name, version) {
+		return &IdentifierError{Identifier: name, Problem: IdentifierReservedWord, Detail: "identifier must be quoted to be used"}
+	}
+	return nil
+}
+
+func (dbconn *DBConn) ValidateIdentifier(name string) error {
+	return ValidateIdentifierForVersion(name, dbconn.Version)
+}
@@ -0,0 +1,72 @@
+package dbconn_test
+
+import (
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/identifier tests", func() {
+	expectProblem := func(err error, problem dbconn.IdentifierProblem) {
+		var identifierErr *dbconn.IdentifierError
+		Expect(errors.As(err, &identifierErr)).To(BeTrue())
+		Expect(identifierErr.Problem).To(Equal(problem))
+	}
+	Describe("ValidateIdentifierForVersion", func() {
+		version := dbconn.NewVersion("6.0.0")
+		It("accepts a valid identifier", func() {
+			Expect(dbconn.ValidateIdentifierForVersion("staging_schema", version)).To(Succeed())
+			Expect(dbconn.ValidateIdentifierForVersion("表", version)).To(Succeed())
+		})
+		It("rejects an empty identifier", func() {
+			err := dbconn.ValidateIdentifierForVersion("", version)
+			expectProblem(err, dbconn.IdentifierEmpty)
+			Expect(err).To(MatchError(`Identifier "" is empty`))
+		})
+		It("accepts an identifier of NAMEDATALEN-1 bytes and rejects a longer one", func() {
+			Expect(dbconn.ValidateIdentifierForVersion(strings.Repeat("a", 63), version)).To(Succeed())
+
+			err := dbconn.ValidateIdentifierForVersion(strings.Repeat("a", 64), version)
+
+			expectProblem(err, dbconn.IdentifierTooLong)
+			Expect(err.Error()).To(HaveSuffix("is too long: identifier is 64 bytes, but the maximum is 63 bytes"))
+		})
+		It("counts the length of multibyte identifiers in bytes", func() {
+			err := dbconn.ValidateIdentifierForVersion(strings.Repeat("表", 22), version)
+			expectProblem(err, dbconn.IdentifierTooLong)
+		})
+		It("rejects reserved words regardless of case", func() {
+			err := dbconn.ValidateIdentifierForVersion("Select", version)
+			expectProblem(err, dbconn.IdentifierReservedWord)
+			Expect(err).To(MatchError(`Identifier "Select" is a reserved word: identifier must be quoted to be used`))
+			expectProblem(dbconn.ValidateIdentifierForVersion("distributed", version), dbconn.IdentifierReservedWord)
+		})
+		It("only treats version-specific reserved words as reserved in those versions", func() {
+			Expect(dbconn.ValidateIdentifierForVersion("lateral", dbconn.NewVersion("5.28.0"))).To(Succeed())
+			expectProblem(dbconn.ValidateIdentifierForVersion("lateral", version), dbconn.IdentifierReservedWord)
+			expectProblem(dbconn.ValidateIdentifierForVersion("lateral", dbconn.GPDBVersion{}), dbconn.IdentifierReservedWord)
+		})
+		It("rejects identifiers with invalid encoding or control characters", func() {
+			err := dbconn.ValidateIdentifierForVersion("bad\xffname", version)
+			expectProblem(err, dbconn.IdentifierInvalidEncoding)
+			Expect(err.Error()).To(HaveSuffix("identifier is not valid UTF-8"))
+
+			err = dbconn.ValidateIdentifierForVersion("bad\x00name", version)
+			expectProblem(err, dbconn.IdentifierInvalidEncoding)
+			Expect(err.Error()).To(HaveSuffix("identifier contains control character U+0000"))
+		})
+	})
+	Describe("DBConn.ValidateIdentifier", func() {
+		It("validates identifiers for the connection's version", func() {
+			testhelper.SetDBVersion(connection, "5.28.0")
+			Expect(connection.ValidateIdentifier("lateral")).To(Succeed())
+			testhelper.SetDBVersion(connection, "7.0.0")
+			expectProblem(connection.ValidateIdentifier("lateral"), dbconn.IdentifierReservedWord)
+		})
+	})
+})
@@ -0,0 +1,138 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to configuring Kerberos
+ * (GSSAPI) authentication for database connections.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * KerberosConfig configures Kerberos authentication for a DBConn.
+ *
+ * ServiceName is the Kerberos service name of the server, as in the libpq
+ * parameter krbsrvname; if it is not set, PGKRBSRVNAME is used, and
+ * "postgres" if that is not set either.  CCache is the credential cache to
+ * authenticate with, in any form accepted by KRB5CCNAME.  GSSAPI libraries
+ * only look for the cache in KRB5CCNAME, so it is not used when connecting
+ * until Kinit or UseCCache sets that variable.  Keytab and Principal are only
+ * used by Kinit, to put a ticket for Principal into CCache before connecting.
+ *
+ * GSSEncMode has the same meaning as the libpq parameter gssencmode, but the
+ * driver cannot encrypt connections with GSSAPI, so only "disable" and
+ * "prefer" are accepted; SSLOptions should be used to encrypt connections.
+ *
+ * The driver only supports GSSAPI authentication if the utility has
+ * registered a provider with pgconn.RegisterGSSProvider.
+ */
+type KerberosConfig struct {
+	ServiceName string
+	Principal   string
+	Keytab      string
+	CCache      string
+	GSSEncMode  string
+}
+
+func (config KerberosConfig) serviceName() string {
+	if config.ServiceName != "" {
+		return config.ServiceName
+	}
+	if krbsrvname := operating.System.Getenv("PGKRBSRVNAME"); krbsrvname != "" {
+		return krbsrvname
+	}
+	return "postgres"
+}
+
+// Returns the path of a file-based credential cache, or "" for other types
+func (config KerberosConfig) ccacheFile() string {
+	if strings.HasPrefix(config.CCache, "FILE:") {
+		return strings.TrimPrefix(config.CCache, "FILE:")
+	}
+	if strings.Contains(config.CCache, ":") {
+		return ""
+	}
+	return config.CCache
+}
+
+// Validate is called by Connect, but may be called earlier to check user input
+func (config KerberosConfig) Validate() error {
+	switch config.GSSEncMode {
+	case "", "disable", "prefer":
+	case "require":
+		return errors.New(`GSSAPI encryption is not supported; use an SSL mode of "require" or higher to encrypt connections`)
+	default:
+		return errors.Errorf("Invalid GSSAPI encryption mode %q", config.GSSEncMode)
+	}
+	if config.Keytab != "" {
+		if config.Principal == "" {
+			return errors.New("A Kerberos principal must be provided along with a keytab")
+		}
+		if err := checkReadable("Kerberos keytab", config.Keytab); err != nil {
+			return err
+		}
+	} else if ccacheFile := config.ccacheFile(); ccacheFile != "" {
+		// Without a keytab, tickets must already be in the cache
+		if err := checkReadable("Kerberos credential cache", ccacheFile); err != nil {
+			return errors.Errorf("%v; run kinit to acquire a ticket", err)
+		}
+	}
+	return nil
+}
+
+// Returns the Kerberos parameters to add to a connection string, with a leading space
+func (config KerberosConfig) connectionParams() (string, error) {
+	if err := config.Validate(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(" krbsrvname='%s'", EscapeConnectionParam(config.serviceName())), nil
+}
+
+/*
+ * UseCCache sets KRB5CCNAME to CCache, if it is set, so that connections
+ * authenticate with the tickets in that cache.  The environment is shared by
+ * the whole process, so this changes the cache used by every DBConn, and by
+ * any other code using GSSAPI, not only those with this config; a utility
+ * that connects with different credential caches must not connect with them
+ * concurrently.
+ */
+func (config KerberosConfig) UseCCache() error {
+	if config.CCache == "" {
+		return nil
+	}
+	if err := operating.System.Setenv("KRB5CCNAME", config.CCache); err != nil {
+		return errors.Wrap(err, "Unable to set Kerberos credential cache")
+	}
+	return nil
+}
+
+/*
+ * Kinit acquires a ticket for Principal using Keytab, storing it in CCache if
+ * that is set and in the default credential cache otherwise, so that a utility
+ * running unattended can authenticate without a ticket having been acquired
+ * beforehand.  If CCache is set, Kinit then calls UseCCache, with the same
+ * process-wide effect.
+ */
+func (config KerberosConfig) Kinit() error {
+	if config.Keytab == "" || config.Principal == "" {
+		return errors.New("A Kerberos principal and keytab must be provided to acquire a ticket")
+	}
+	if err := checkReadable("Kerberos keytab", config.Keytab); err != nil {
+		return err
+	}
+	args := []string{"-k", "-t", config.Keytab}
+	if config.CCache != "" {
+		args = append(args, "-c", config.CCache)
+	}
+	args = append(args, config.Principal)
+	output, err := operating.System.ExecCommand("kinit", args...).CombinedOutput()
+	if err != nil {
+		return errors.Errorf("Unable to acquire Kerberos ticket for %s: %s: %v", config.Principal, strings.TrimSpace(string(output)), err)
+	}
+	return config.UseCCache()
+}
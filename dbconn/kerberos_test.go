@@ -0,0 +1,142 @@
+package dbconn_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/kerberos tests", func() {
+	var (
+		krbDir     string
+		keytabFile string
+		ccacheFile string
+	)
+	BeforeEach(func() {
+		krbDir = GinkgoT().TempDir()
+		keytabFile = filepath.Join(krbDir, "gpadmin.keytab")
+		ccacheFile = filepath.Join(krbDir, "krb5cc_gpadmin")
+		Expect(os.WriteFile(keytabFile, []byte("keytab"), 0600)).To(Succeed())
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("KerberosConfig.Validate", func() {
+		It("accepts a readable keytab with a principal", func() {
+			Expect(dbconn.KerberosConfig{Principal: "gpadmin@EXAMPLE.COM", Keytab: keytabFile}.Validate()).To(Succeed())
+		})
+		It("returns an error for a keytab without a principal", func() {
+			Expect(dbconn.KerberosConfig{Keytab: keytabFile}.Validate()).To(MatchError("A Kerberos principal must be provided along with a keytab"))
+		})
+		It("returns an error for a missing keytab", func() {
+			missingFile := filepath.Join(krbDir, "missing.keytab")
+			err := dbconn.KerberosConfig{Principal: "gpadmin", Keytab: missingFile}.Validate()
+			Expect(err).To(MatchError(HavePrefix("Unable to read Kerberos keytab file " + missingFile + ": ")))
+		})
+		It("returns an error suggesting kinit for a missing credential cache", func() {
+			err := dbconn.KerberosConfig{CCache: "FILE:" + ccacheFile}.Validate()
+			Expect(err).To(MatchError(And(
+				HavePrefix("Unable to read Kerberos credential cache file "+ccacheFile+": "),
+				HaveSuffix("; run kinit to acquire a ticket"))))
+		})
+		It("does not check credential caches that are not files", func() {
+			Expect(dbconn.KerberosConfig{CCache: "KEYRING:persistent:1000"}.Validate()).To(Succeed())
+		})
+		It("returns an error if GSSAPI encryption is required", func() {
+			Expect(dbconn.KerberosConfig{GSSEncMode: "require"}.Validate()).To(MatchError(`GSSAPI encryption is not supported; use an SSL mode of "require" or higher to encrypt connections`))
+			Expect(dbconn.KerberosConfig{GSSEncMode: "prefer"}.Validate()).To(Succeed())
+			Expect(dbconn.KerberosConfig{GSSEncMode: "sometimes"}.Validate()).To(MatchError(`Invalid GSSAPI encryption mode "sometimes"`))
+		})
+	})
+	Describe("KerberosConfig.UseCCache", func() {
+		var env map[string]string
+		BeforeEach(func() {
+			env = map[string]string{}
+			operating.System.Setenv = func(key string, value string) error {
+				env[key] = value
+				return nil
+			}
+		})
+		It("sets KRB5CCNAME to the credential cache", func() {
+			Expect(dbconn.KerberosConfig{CCache: "KEYRING:persistent:1000"}.UseCCache()).To(Succeed())
+			Expect(env).To(Equal(map[string]string{"KRB5CCNAME": "KEYRING:persistent:1000"}))
+		})
+		It("leaves KRB5CCNAME unchanged without a credential cache", func() {
+			Expect(dbconn.KerberosConfig{}.UseCCache()).To(Succeed())
+			Expect(env).To(BeEmpty())
+		})
+	})
+	Describe("KerberosConfig.Kinit", func() {
+		var env map[string]string
+		BeforeEach(func() {
+			env = map[string]string{}
+			operating.System.Setenv = func(key string, value string) error {
+				env[key] = value
+				return nil
+			}
+		})
+		It("acquires a ticket into the credential cache and uses it", func() {
+			runner := testhelper.MockExecCommand("", "", 0)
+			config := dbconn.KerberosConfig{Principal: "gpadmin@EXAMPLE.COM", Keytab: keytabFile, CCache: ccacheFile}
+
+			Expect(config.Kinit()).To(Succeed())
+
+			Expect(runner.Commands).To(Equal([][]string{{"kinit", "-k", "-t", keytabFile, "-c", ccacheFile, "gpadmin@EXAMPLE.COM"}}))
+			Expect(env).To(Equal(map[string]string{"KRB5CCNAME": ccacheFile}))
+		})
+		It("returns the output of kinit if it fails", func() {
+			testhelper.MockExecCommand("", "kinit: Keytab contains no suitable keys for gpadmin@EXAMPLE.COM", 1)
+			config := dbconn.KerberosConfig{Principal: "gpadmin@EXAMPLE.COM", Keytab: keytabFile}
+
+			err := config.Kinit()
+
+			Expect(err).To(MatchError("Unable to acquire Kerberos ticket for gpadmin@EXAMPLE.COM: kinit: Keytab contains no suitable keys for gpadmin@EXAMPLE.COM: exit status 1"))
+			Expect(env).To(BeEmpty())
+		})
+		It("returns an error without a keytab", func() {
+			Expect(dbconn.KerberosConfig{Principal: "gpadmin"}.Kinit()).To(MatchError("A Kerberos principal and keytab must be provided to acquire a ticket"))
+		})
+	})
+	Describe("DBConn.Connect", func() {
+		var driver *recordingDriver
+		BeforeEach(func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver = &recordingDriver{DBDriver: connection.Driver}
+			connection.Driver = driver
+		})
+		It("passes the service name without changing the environment", func() {
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			Expect(os.WriteFile(ccacheFile, []byte("tickets"), 0600)).To(Succeed())
+			connection.Kerberos = dbconn.KerberosConfig{ServiceName: "gpdb", CCache: ccacheFile}
+			operating.System.Setenv = func(key string, value string) error {
+				Fail("Connect should not set " + key)
+				return nil
+			}
+
+			Expect(connection.Connect(1)).To(Succeed())
+
+			Expect(driver.dataSourceNames[0]).To(ContainSubstring(" krbsrvname='gpdb' "))
+		})
+		It("uses PGKRBSRVNAME if no service name is given", func() {
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			GinkgoT().Setenv("PGKRBSRVNAME", "greenplum")
+
+			Expect(connection.Connect(1)).To(Succeed())
+
+			Expect(driver.dataSourceNames[0]).To(ContainSubstring(" krbsrvname='greenplum' "))
+		})
+		It("does not connect if the configuration is invalid", func() {
+			connection.Kerberos = dbconn.KerberosConfig{Keytab: keytabFile}
+
+			Expect(connection.Connect(1)).To(MatchError("A Kerberos principal must be provided along with a keytab"))
+			Expect(driver.dataSourceNames).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,213 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to resizing the connection
+ * pool and to leasing its connections to goroutines.
+ */
+
+import (
+	"sync"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+ * connLeases records which connections are leased with Acquire, so that two
+ * goroutines are never given the same connection.  A nil connLeases (e.g. in
+ * a DBConn that has not been connected) has no connections to lease.
+ */
+type connLeases struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	leased []bool
+	closed bool
+}
+
+func newConnLeases(numConns int) *connLeases {
+	leases := &connLeases{leased: make([]bool, numConns)}
+	leases.cond = sync.NewCond(&leases.mutex)
+	return leases
+}
+
+// Must be called with leases.mutex held.  Returns -1 if every connection is leased.
+func (leases *connLeases) leaseFree() int {
+	for connNum, leased := range leases.leased {
+		if !leased {
+			leases.leased[connNum] = true
+			return connNum
+		}
+	}
+	return -1
+}
+
+func (leases *connLeases) close() {
+	if leases == nil {
+		return
+	}
+	leases.mutex.Lock()
+	defer leases.mutex.Unlock()
+	leases.closed = true
+	leases.leased = nil
+	leases.cond.Broadcast()
+}
+
+/*
+ * Acquire leases a connection that no other goroutine has leased and returns
+ * its number, blocking until one is released if every connection is leased.
+ * The caller must pass the number to every function it calls on the DBConn
+ * and must call Release when done.  An error is returned if the pool is not
+ * connected or is closed while waiting.
+ *
+ * Leases only protect connections from other callers of Acquire, so a pool
+ * whose connections are leased should not also be used by connection number
+ * without a lease.
+ */
+func (dbconn *DBConn) Acquire() (int, error) {
+	leases := dbconn.leases
+	if leases == nil {
+		return -1, errors.New("Cannot acquire a connection; the connection pool is not connected")
+	}
+	leases.mutex.Lock()
+	defer leases.mutex.Unlock()
+	for {
+		if leases.closed {
+			return -1, errors.New("Cannot acquire a connection; the connection pool is closed")
+		}
+		if connNum := leases.leaseFree(); connNum != -1 {
+			return connNum, nil
+		}
+		leases.cond.Wait()
+	}
+}
+
+func (dbconn *DBConn) MustAcquire() int {
+	connNum, err := dbconn.Acquire()
+	gplog.FatalOnError(err)
+	return connNum
+}
+
+// TryAcquire leases a connection like Acquire, but returns false instead of blocking if every connection is leased
+func (dbconn *DBConn) TryAcquire() (int, bool) {
+	leases := dbconn.leases
+	if leases == nil {
+		return -1, false
+	}
+	leases.mutex.Lock()
+	defer leases.mutex.Unlock()
+	if leases.closed {
+		return -1, false
+	}
+	connNum := leases.leaseFree()
+	return connNum, connNum != -1
+}
+
+/*
+ * Release returns a leased connection to the pool.  If a transaction is still
+ * in progress on the connection, it is rolled back, and if a role set with
+ * SetRole is still in effect, it is reset, so that the next goroutine to lease
+ * the connection does not unknowingly run its queries inside the transaction
+ * or as the role.
+ * Releasing a connection that is not leased is a programming error, so it is
+ * fatal.
+ */
+func (dbconn *DBConn) Release(connNum int) {
+	leases := dbconn.leases
+	if leases == nil {
+		gplog.Fatal(errors.Errorf("Cannot release connection %d; the connection pool is not connected", connNum), "")
+		return
+	}
+	leases.mutex.Lock()
+	defer leases.mutex.Unlock()
+	if leases.closed {
+		return
+	}
+	if connNum < 0 || connNum >= len(leases.leased) || !leases.leased[connNum] {
+		gplog.Fatal(errors.Errorf("Cannot release connection %d; it is not leased", connNum), "")
+		return
+	}
+	if dbconn.Tx[connNum] != nil {
+		logDomain.Warn("Connection %d was released with a transaction in progress; rolling back", connNum)
+		if err := dbconn.Rollback(connNum); err != nil {
+			logDomain.Warn("Cannot roll back transaction on connection %d: %v", connNum, err)
+		}
+	}
+	if role := dbconn.tracker.role(connNum); role != dbconn.Role {
+		logDomain.Warn("Connection %d was released with role %s set; resetting role", connNum, role)
+		if err := dbconn.ResetRole(connNum); err != nil {
+			logDomain.Warn("Cannot reset role on connection %d: %v", connNum, err)
+		}
+	}
+	leases.leased[connNum] = false
+	leases.cond.Signal()
+}
+
+/*
+ * Resize opens or closes connections so that the pool has numConns
+ * connections, using the same connection parameters as Connect.  Connections
+ * are removed from the end of the pool, and an error is returned without
+ * changing the pool if any of them is leased or has a transaction in progress.
+ *
+ * Resize must not be called while other goroutines are running queries on the
+ * DBConn, e.g. it may be called between the phases of a parallel operation.
+ */
+func (dbconn *DBConn) Resize(numConns int) error {
+	if numConns < 1 {
+		return errors.Errorf("Must specify a connection pool size that is a positive integer")
+	}
+	if dbconn.ConnPool == nil || dbconn.leases == nil {
+		return errors.New("The database connection must be open to resize the connection pool")
+	}
+	leases := dbconn.leases
+	leases.mutex.Lock()
+	defer leases.mutex.Unlock()
+	for connNum := numConns; connNum < dbconn.NumConns; connNum++ {
+		if leases.leased[connNum] {
+			return errors.Errorf("Cannot remove connection %d from the pool; it is leased", connNum)
+		}
+		if dbconn.Tx[connNum] != nil {
+			return errors.Errorf("Cannot remove connection %d from the pool; it has a transaction in progress", connNum)
+		}
+	}
+
+	newConns := make([]*sqlx.DB, 0)
+	for connNum := dbconn.NumConns; connNum < numConns; connNum++ {
+		conn, err := dbconn.openConnection(dbconn.connStr)
+		if err == nil {
+			newConns = append(newConns, conn)
+			err = dbconn.setSessionRole(conn)
+		}
+		if err != nil {
+			for _, newConn := range newConns {
+				_ = newConn.Close()
+			}
+			return err
+		}
+	}
+	for _, conn := range dbconn.ConnPool[minInt(numConns, dbconn.NumConns):] {
+		_ = conn.Close()
+	}
+
+	logDomain.Verbose("Resizing connection pool from %d to %d connections", dbconn.NumConns, numConns)
+	oldNumConns := dbconn.NumConns
+	dbconn.ConnPool = append(dbconn.ConnPool[:minInt(numConns, oldNumConns)], newConns...)
+	dbconn.Tx = append(dbconn.Tx[:minInt(numConns, oldNumConns)], make([]*sqlx.Tx, len(newConns))...)
+	leases.leased = append(leases.leased[:minInt(numConns, oldNumConns)], make([]bool, len(newConns))...)
+	dbconn.NumConns = numConns
+	dbconn.tracker.resize(numConns)
+	for connNum := oldNumConns; connNum < numConns; connNum++ {
+		dbconn.tracker.setBackendPID(connNum, getBackendPID(dbconn.ConnPool[connNum]))
+		dbconn.tracker.setCancelFunc(connNum, getCancelFunc(dbconn.ConnPool[connNum]))
+		dbconn.tracker.setRole(connNum, dbconn.Role)
+	}
+	leases.cond.Broadcast()
+	return nil
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,128 @@
+package dbconn_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/lease tests", func() {
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateAndConnectMockDB(2)
+	})
+
+	Describe("DBConn.Acquire", func() {
+		It("leases each connection to only one caller at a time", func() {
+			first, err := connection.Acquire()
+			Expect(err).ToNot(HaveOccurred())
+			second, err := connection.Acquire()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect([]int{first, second}).To(ConsistOf(0, 1))
+			_, ok := connection.TryAcquire()
+			Expect(ok).To(BeFalse())
+		})
+		It("blocks until a connection is released", func() {
+			connection.MustAcquire()
+			second := connection.MustAcquire()
+			acquired := make(chan int, 1)
+
+			go func() {
+				acquired <- connection.MustAcquire()
+			}()
+
+			Consistently(acquired, 20*time.Millisecond).ShouldNot(Receive())
+			connection.Release(second)
+			Eventually(acquired).Should(Receive(Equal(second)))
+		})
+		It("returns an error to waiting callers when the pool is closed", func() {
+			connection.MustAcquire()
+			connection.MustAcquire()
+			result := make(chan error, 1)
+
+			go func() {
+				_, err := connection.Acquire()
+				result <- err
+			}()
+
+			Consistently(result, 20*time.Millisecond).ShouldNot(Receive())
+			connection.Close()
+			Eventually(result).Should(Receive(MatchError("Cannot acquire a connection; the connection pool is closed")))
+		})
+	})
+	Describe("DBConn.Release", func() {
+		It("rolls back a transaction left in progress", func() {
+			_, _, logfile := testhelper.SetupTestLogger()
+			connNum := connection.MustAcquire()
+			ExpectBegin(mock)
+			mock.ExpectRollback()
+			connection.MustBegin(connNum)
+
+			connection.Release(connNum)
+
+			Expect(connection.Tx[connNum]).To(BeNil())
+			testhelper.ExpectRegexp(logfile, fmt.Sprintf("Connection %d was released with a transaction in progress; rolling back", connNum))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("panics if the connection is not leased", func() {
+			defer testhelper.ShouldPanicWithMessage("Cannot release connection 1; it is not leased")
+			connection.Release(1)
+		})
+	})
+	Describe("DBConn.Resize", func() {
+		It("adds connections that can be leased", func() {
+			connection.MustAcquire()
+			connection.MustAcquire()
+
+			Expect(connection.Resize(3)).To(Succeed())
+
+			Expect(connection.NumConns).To(Equal(3))
+			Expect(connection.ConnPool).To(HaveLen(3))
+			Expect(connection.Tx).To(HaveLen(3))
+			Expect(connection.DescribePool()).To(HaveLen(3))
+			Expect(connection.MustAcquire()).To(Equal(2))
+		})
+		It("removes connections from the end of the pool", func() {
+			Expect(connection.Resize(1)).To(Succeed())
+
+			Expect(connection.NumConns).To(Equal(1))
+			Expect(connection.ConnPool).To(HaveLen(1))
+			Expect(connection.DescribePool()).To(HaveLen(1))
+		})
+		It("does not remove leased connections", func() {
+			connection.MustAcquire()
+			connection.MustAcquire()
+
+			err := connection.Resize(1)
+
+			Expect(err).To(MatchError("Cannot remove connection 1 from the pool; it is leased"))
+			Expect(connection.NumConns).To(Equal(2))
+		})
+		It("does not remove connections with a transaction in progress", func() {
+			ExpectBegin(mock)
+			connection.MustBegin(1)
+
+			err := connection.Resize(1)
+
+			Expect(err).To(MatchError("Cannot remove connection 1 from the pool; it has a transaction in progress"))
+		})
+		It("leaves the pool unchanged if a connection cannot be opened", func() {
+			connection.Driver = &testhelper.TestDriver{ErrToReturn: fmt.Errorf("too many connections")}
+
+			err := connection.Resize(4)
+
+			Expect(err).To(MatchError("too many connections (testhost:5432)"))
+			Expect(connection.NumConns).To(Equal(2))
+			Expect(connection.ConnPool).To(HaveLen(2))
+		})
+		It("returns an error if the pool is not connected", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+
+			Expect(connection.Resize(2)).To(MatchError("The database connection must be open to resize the connection pool"))
+		})
+	})
+})
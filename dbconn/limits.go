@@ -0,0 +1,190 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to limiting the size of
+ * query results, so that a query that unexpectedly returns a huge result (e.g.
+ * because of catalog bloat) fails instead of exhausting the utility's memory.
+ */
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+/*
+ * ResultLimits are the largest results that Select, SelectWithArgs, and
+ * SelectContext will read.  MaxBytes is compared with the approximate size of
+ * the scanned values, counting the length of strings and byte slices and the
+ * in-memory size of other values.  A zero limit means no limit.
+ */
+type ResultLimits struct {
+	MaxRows  int
+	MaxBytes int64
+}
+
+/*
+ * A ResultLimitError is returned when a query's result exceeds one of the
+ * ResultLimits.  Limit is the limit that was exceeded, and Unit is "rows" or
+ * "bytes".
+ */
+type ResultLimitError struct {
+	Query string
+	Limit int64
+	Unit  string
+}
+
+func (err *ResultLimitError) Error() string {
+	return fmt.Sprintf("Query returned more than %d %s, the limit set with SetResultLimits: %s", err.Limit, err.Unit, querySnippet(err.Query))
+}
+
+/*
+ * SetResultLimits sets the limits for queries run with Select, SelectWithArgs,
+ * and SelectContext on every connection, replacing any previous limits.  When
+ * a result exceeds a limit, the query fails with a *ResultLimitError, and the
+ * destination slice holds the rows read before the limit was exceeded.  Get
+ * and GetWithArgs read only one row, so they are not limited.
+ */
+func (dbconn *DBConn) SetResultLimits(limits ResultLimits) {
+	dbconn.resultLimits = limits
+}
+
+/*
+ * limitedRows wraps the rows of a query so that reading them fails once a
+ * limit is exceeded, and implements the interface sqlx uses for scanning, so
+ * that the rows can be scanned into a slice as sqlx's Select does.
+ */
+type limitedRows struct {
+	*sqlx.Rows
+	query    string
+	limits   ResultLimits
+	numRows  int
+	numBytes int64
+	err      error
+}
+
+func (rows *limitedRows) Next() bool {
+	if rows.err != nil || !rows.Rows.Next() {
+		return false
+	}
+	rows.numRows++
+	if rows.limits.MaxRows > 0 && rows.numRows > rows.limits.MaxRows {
+		rows.err = &ResultLimitError{Query: rows.query, Limit: int64(rows.limits.MaxRows), Unit: "rows"}
+		return false
+	}
+	return true
+}
+
+func (rows *limitedRows) Scan(dest ...interface{}) error {
+	if err := rows.Rows.Scan(dest...); err != nil {
+		return err
+	}
+	if rows.limits.MaxBytes > 0 {
+		for _, value := range dest {
+			rows.numBytes += approximateSize(reflect.ValueOf(value))
+		}
+		if rows.numBytes > rows.limits.MaxBytes {
+			rows.err = &ResultLimitError{Query: rows.query, Limit: rows.limits.MaxBytes, Unit: "bytes"}
+			return rows.err
+		}
+	}
+	return nil
+}
+
+func (rows *limitedRows) Err() error {
+	if rows.err != nil {
+		return rows.err
+	}
+	return rows.Rows.Err()
+}
+
+/*
+ * Scanned values are reached through the pointers passed to Scan, which are
+ * not counted themselves.  Pointers within values (e.g. a time.Time's
+ * location) are not followed, as they do not hold data from the result.
+ */
+func approximateSize(value reflect.Value) int64 {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return 0
+		}
+		value = value.Elem()
+	}
+	return valueSize(value)
+}
+
+func valueSize(value reflect.Value) int64 {
+	switch value.Kind() {
+	case reflect.String:
+		return int64(value.Len())
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return int64(value.Len())
+		}
+		size := int64(0)
+		for i := 0; i < value.Len(); i++ {
+			size += valueSize(value.Index(i))
+		}
+		return size
+	case reflect.Struct:
+		size := int64(0)
+		for i := 0; i < value.NumField(); i++ {
+			size += valueSize(value.Field(i))
+		}
+		return size
+	default:
+		return int64(value.Type().Size())
+	}
+}
+
+/*
+ * selectOnConn runs the query on the connection, or in its transaction if one
+ * is in progress, and scans its rows into destination as sqlx's Select does,
+ * but fails if the result exceeds the DBConn's limits.
+ */
+func (dbconn *DBConn) selectOnConn(ctx context.Context, connNum int, destination interface{}, query string, args ...interface{}) error {
+	var queryer sqlx.QueryerContext = dbconn.ConnPool[connNum]
+	if dbconn.Tx[connNum] != nil {
+		queryer = dbconn.Tx[connNum]
+	}
+	dest := reflect.ValueOf(destination)
+	if dbconn.resultLimits == (ResultLimits{}) || dest.Kind() != reflect.Ptr || dest.Elem().Kind() != reflect.Slice {
+		// sqlx returns an error for destinations that are not pointers to slices
+		return sqlx.SelectContext(ctx, queryer, destination, query, args...)
+	}
+	rows, err := queryer.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	limited := &limitedRows{Rows: rows, query: query, limits: dbconn.resultLimits}
+	if isStruct(dest.Elem().Type().Elem()) {
+		return sqlx.StructScan(limited, destination)
+	}
+	return scanScalars(limited, destination)
+}
+
+// Types that implement sql.Scanner, such as sql.NullString, are scanned as scalars
+func isStruct(elemType reflect.Type) bool {
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	_, isScanner := reflect.New(elemType).Interface().(interface{ Scan(src interface{}) error })
+	return elemType.Kind() == reflect.Struct && !isScanner
+}
+
+// sqlx.StructScan only scans structs, so single-column results are scanned here
+func scanScalars(rows *limitedRows, destination interface{}) error {
+	slice := reflect.ValueOf(destination).Elem()
+	elemType := slice.Type().Elem()
+	for rows.Next() {
+		value := reflect.New(elemType)
+		if err := rows.Scan(value.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, value.Elem()))
+	}
+	return rows.Err()
+}
@@ -0,0 +1,96 @@
+package dbconn_test
+
+import (
+	"context"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/limits tests", func() {
+	type relation struct {
+		Schema string
+		Name   string
+	}
+	relationRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"schema", "name"}).
+			AddRow("public", "foo").
+			AddRow("public", "bar").
+			AddRow("public", "baz")
+	}
+	Describe("DBConn.SetResultLimits", func() {
+		It("does not limit results if no limits are set", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(relationRows())
+			relations := make([]relation, 0)
+
+			err := connection.Select(&relations, "SELECT nspname AS schema, relname AS name FROM pg_class")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(relations).To(HaveLen(3))
+		})
+		It("reads results within the limits", func() {
+			connection.SetResultLimits(dbconn.ResultLimits{MaxRows: 3, MaxBytes: 100})
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(relationRows())
+			relations := make([]relation, 0)
+
+			err := connection.Select(&relations, "SELECT nspname AS schema, relname AS name FROM pg_class")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(relations).To(Equal([]relation{{"public", "foo"}, {"public", "bar"}, {"public", "baz"}}))
+		})
+		It("returns an error if the result has too many rows", func() {
+			connection.SetResultLimits(dbconn.ResultLimits{MaxRows: 2})
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(relationRows())
+			relations := make([]relation, 0)
+
+			err := connection.Select(&relations, "SELECT nspname AS schema, relname AS name FROM pg_class")
+
+			Expect(err).To(MatchError("Query returned more than 2 rows, the limit set with SetResultLimits: SELECT nspname AS schema, relname AS name FROM pg_class"))
+			var limitErr *dbconn.ResultLimitError
+			Expect(errors.As(err, &limitErr)).To(BeTrue())
+			Expect(limitErr.Unit).To(Equal("rows"))
+			Expect(relations).To(HaveLen(2))
+		})
+		It("returns an error if the result is too large", func() {
+			connection.SetResultLimits(dbconn.ResultLimits{MaxBytes: 20})
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(relationRows())
+			relations := make([]relation, 0)
+
+			err := connection.Select(&relations, "SELECT nspname AS schema, relname AS name FROM pg_class")
+
+			Expect(err).To(MatchError("Query returned more than 20 bytes, the limit set with SetResultLimits: SELECT nspname AS schema, relname AS name FROM pg_class"))
+			Expect(relations).To(Equal([]relation{{"public", "foo"}, {"public", "bar"}}))
+		})
+		It("limits results scanned into slices of scalars", func() {
+			connection.SetResultLimits(dbconn.ResultLimits{MaxRows: 2})
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("foo").AddRow("bar").AddRow("baz"))
+			names := make([]string, 0)
+
+			err := connection.SelectWithArgs(&names, "SELECT relname FROM pg_class WHERE relkind = $1", "r")
+
+			Expect(err).To(MatchError(ContainSubstring("Query returned more than 2 rows")))
+			Expect(names).To(Equal([]string{"foo", "bar"}))
+		})
+		It("limits results of SelectContext", func() {
+			connection.SetResultLimits(dbconn.ResultLimits{MaxRows: 1})
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(relationRows())
+			relations := make([]relation, 0)
+
+			err := connection.SelectContext(context.Background(), &relations, "SELECT nspname AS schema, relname AS name FROM pg_class")
+
+			Expect(err).To(MatchError(ContainSubstring("Query returned more than 1 rows")))
+		})
+		It("does not limit Get", func() {
+			connection.SetResultLimits(dbconn.ResultLimits{MaxBytes: 1})
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("foo"))
+			var name string
+
+			Expect(connection.Get(&name, "SELECT relname FROM pg_class LIMIT 1")).To(Succeed())
+			Expect(name).To(Equal("foo"))
+		})
+	})
+})
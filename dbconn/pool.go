@@ -0,0 +1,271 @@
+package dbconn
+
+/*
+ * This file contains structs and functions for inspecting the state of the
+ * connections in a DBConn's connection pool, for debugging purposes.
+ */
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// The status values mirror the "state" column of pg_stat_activity.
+const (
+	ConnStatusIdle         = "idle"
+	ConnStatusActive       = "active"
+	ConnStatusIdleInTx     = "idle in transaction"
+	ConnStatusActiveInTx   = "active in transaction"
+	maxQuerySnippetLength  = 100
+	querySnippetTruncation = "..."
+)
+
+/*
+ * A ConnState is a point-in-time description of a single connection in the
+ * pool.  BackendPID is 0 if the driver does not expose it (e.g. sqlmock),
+ * TxStart is the zero time if no transaction is in progress, and Role is empty
+ * if the connection has the role of the user it connected as.
+ */
+type ConnState struct {
+	ConnNum    int
+	Status     string
+	BackendPID uint32
+	TxStart    time.Time
+	Role       string
+	LastQuery  string
+	LastError  error
+}
+
+func (state ConnState) String() string {
+	str := fmt.Sprintf("conn %d (pid %d): %s", state.ConnNum, state.BackendPID, state.Status)
+	if !state.TxStart.IsZero() {
+		str += fmt.Sprintf(" since %s", state.TxStart.Format("20060102:15:04:05"))
+	}
+	if state.Role != "" {
+		str += fmt.Sprintf(", role: %s", state.Role)
+	}
+	if state.LastQuery != "" {
+		str += fmt.Sprintf(", last query: %s", state.LastQuery)
+	}
+	if state.LastError != nil {
+		str += fmt.Sprintf(", last error: %v", state.LastError)
+	}
+	return str
+}
+
+/*
+ * connTracker holds the mutable per-connection bookkeeping behind DescribePool.
+ * Each connection is normally used by a single goroutine, but DescribePool may
+ * be called from any goroutine (e.g. a signal handler), so access is guarded.
+ * A nil connTracker (e.g. in a DBConn not created by NewDBConn) ignores updates.
+ */
+type connTracker struct {
+	mutex  sync.Mutex
+	states []ConnState
+	// The functions that cancel each connection's running query; see cancel.go
+	cancelFuncs []func(ctx context.Context) error
+}
+
+func (tracker *connTracker) reset(numConns int) {
+	if tracker == nil {
+		return
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if numConns == 0 {
+		tracker.states = nil
+		tracker.cancelFuncs = nil
+		return
+	}
+	tracker.states = make([]ConnState, numConns)
+	tracker.cancelFuncs = make([]func(ctx context.Context) error, numConns)
+	for i := range tracker.states {
+		tracker.states[i] = ConnState{ConnNum: i, Status: ConnStatusIdle}
+	}
+}
+
+// Adds idle states for new connections or drops the states of removed ones
+func (tracker *connTracker) resize(numConns int) {
+	if tracker == nil {
+		return
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for i := len(tracker.states); i < numConns; i++ {
+		tracker.states = append(tracker.states, ConnState{ConnNum: i, Status: ConnStatusIdle})
+	}
+	tracker.states = tracker.states[:numConns]
+	for i := len(tracker.cancelFuncs); i < numConns; i++ {
+		tracker.cancelFuncs = append(tracker.cancelFuncs, nil)
+	}
+	tracker.cancelFuncs = tracker.cancelFuncs[:numConns]
+}
+
+func (tracker *connTracker) update(connNum int, updateFunc func(state *ConnState)) {
+	if tracker == nil {
+		return
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if connNum < 0 || connNum >= len(tracker.states) {
+		return
+	}
+	updateFunc(&tracker.states[connNum])
+}
+
+func (tracker *connTracker) startQuery(connNum int, query string) {
+	tracker.update(connNum, func(state *ConnState) {
+		state.LastQuery = querySnippet(query)
+		if state.TxStart.IsZero() {
+			state.Status = ConnStatusActive
+		} else {
+			state.Status = ConnStatusActiveInTx
+		}
+	})
+}
+
+func (tracker *connTracker) finishQuery(connNum int, err error) {
+	tracker.update(connNum, func(state *ConnState) {
+		state.LastError = err
+		if state.TxStart.IsZero() {
+			state.Status = ConnStatusIdle
+		} else {
+			state.Status = ConnStatusIdleInTx
+		}
+	})
+}
+
+/*
+ * Rows are the rows returned by Query, QueryWithArgs, and QueryContext.  The
+ * server is still producing rows while they are read, so the connection is
+ * reported as active, and its query is canceled by CancelAll, until the rows
+ * are closed or read to the end.  Otherwise they can be used exactly as the
+ * embedded *sqlx.Rows.
+ */
+type Rows struct {
+	*sqlx.Rows
+	finish func(err error)
+	once   sync.Once
+}
+
+// Wraps rows from a query started on the given connection, finishing the query immediately if it failed
+func (tracker *connTracker) trackRows(connNum int, rows *sqlx.Rows, err error) (*Rows, error) {
+	if err != nil {
+		tracker.finishQuery(connNum, err)
+		return nil, err
+	}
+	return &Rows{Rows: rows, finish: func(err error) { tracker.finishQuery(connNum, err) }}, nil
+}
+
+func (rows *Rows) Next() bool {
+	if rows.Rows.Next() {
+		return true
+	}
+	rows.done()
+	return false
+}
+
+func (rows *Rows) Close() error {
+	err := rows.Rows.Close()
+	rows.done()
+	return err
+}
+
+func (rows *Rows) done() {
+	rows.once.Do(func() {
+		if rows.finish != nil {
+			rows.finish(rows.Rows.Err())
+		}
+	})
+}
+
+func (tracker *connTracker) setTransaction(connNum int, inTx bool) {
+	tracker.update(connNum, func(state *ConnState) {
+		if inTx {
+			state.TxStart = operating.System.Now()
+			state.Status = ConnStatusIdleInTx
+		} else {
+			state.TxStart = time.Time{}
+			state.Status = ConnStatusIdle
+		}
+	})
+}
+
+func (tracker *connTracker) setBackendPID(connNum int, pid uint32) {
+	tracker.update(connNum, func(state *ConnState) {
+		state.BackendPID = pid
+	})
+}
+
+func (tracker *connTracker) setRole(connNum int, role string) {
+	tracker.update(connNum, func(state *ConnState) {
+		state.Role = role
+	})
+}
+
+func (tracker *connTracker) role(connNum int) string {
+	var role string
+	tracker.update(connNum, func(state *ConnState) {
+		role = state.Role
+	})
+	return role
+}
+
+// Long queries are cut at a character boundary, so that multi-byte characters are not split
+func querySnippet(query string) string {
+	snippet := strings.Join(strings.Fields(query), " ")
+	if len(snippet) > maxQuerySnippetLength {
+		cut := maxQuerySnippetLength - len(querySnippetTruncation)
+		for cut > 0 && !utf8.RuneStart(snippet[cut]) {
+			cut--
+		}
+		snippet = snippet[:cut] + querySnippetTruncation
+	}
+	return snippet
+}
+
+/*
+ * Retrieve the backend PID from the underlying pgx connection without issuing
+ * a query, so that this works against mock drivers as well; drivers other than
+ * pgx simply report a PID of 0.
+ */
+func getBackendPID(conn *sqlx.DB) uint32 {
+	var pid uint32
+	sqlConn, err := conn.Conn(context.Background())
+	if err != nil {
+		return 0
+	}
+	defer sqlConn.Close()
+	_ = sqlConn.Raw(func(driverConn interface{}) error {
+		if pgxConn, ok := driverConn.(*stdlib.Conn); ok {
+			pid = pgxConn.Conn().PgConn().PID()
+		}
+		return nil
+	})
+	return pid
+}
+
+/*
+ * DescribePool returns the current state of every connection in the pool, in
+ * connection number order.  It is safe to call concurrently with queries on
+ * the pool and does not itself use any connection, so it can be used to explain
+ * why a shutdown is blocked while all connections are busy.
+ */
+func (dbconn *DBConn) DescribePool() []ConnState {
+	if dbconn.tracker == nil {
+		return []ConnState{}
+	}
+	dbconn.tracker.mutex.Lock()
+	defer dbconn.tracker.mutex.Unlock()
+	states := make([]ConnState, len(dbconn.tracker.states))
+	copy(states, dbconn.tracker.states)
+	return states
+}
@@ -0,0 +1,147 @@
+package dbconn_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/pool tests", func() {
+	txStart := time.Date(2017, time.January, 1, 1, 1, 1, 1, time.Local)
+	BeforeEach(func() {
+		operating.System.Now = func() time.Time { return txStart }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("DBConn.DescribePool", func() {
+		It("describes every connection as idle after connecting", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			testhelper.ExpectVersionQuery(mock, "5.1.0")
+			connection.MustConnect(2)
+
+			states := connection.DescribePool()
+			Expect(states).To(HaveLen(2))
+			for i, state := range states {
+				Expect(state.ConnNum).To(Equal(i))
+				Expect(state.TxStart.IsZero()).To(BeTrue())
+				Expect(state.LastError).ToNot(HaveOccurred())
+			}
+			Expect(states[1].Status).To(Equal(dbconn.ConnStatusIdle))
+		})
+		It("records the last query and error for a connection", func() {
+			mock.ExpectExec("DROP TABLE foo").WillReturnError(fmt.Errorf("table does not exist"))
+
+			_, _ = connection.Exec("DROP TABLE foo")
+
+			state := connection.DescribePool()[0]
+			Expect(state.Status).To(Equal(dbconn.ConnStatusIdle))
+			Expect(state.LastQuery).To(Equal("DROP TABLE foo"))
+			Expect(state.LastError).To(MatchError("table does not exist"))
+		})
+		It("clears the last error after a successful query", func() {
+			mock.ExpectExec("DROP TABLE foo").WillReturnError(fmt.Errorf("table does not exist"))
+			mock.ExpectExec("CREATE TABLE foo").WillReturnResult(testhelper.TestResult{Rows: 0})
+
+			_, _ = connection.Exec("DROP TABLE foo")
+			_, _ = connection.Exec("CREATE TABLE foo(i int)")
+
+			state := connection.DescribePool()[0]
+			Expect(state.LastQuery).To(Equal("CREATE TABLE foo(i int)"))
+			Expect(state.LastError).ToNot(HaveOccurred())
+		})
+		It("truncates long queries and collapses whitespace", func() {
+			longQuery := "SELECT\n\t" + strings.Repeat("a", 200)
+			mock.ExpectExec("SELECT").WillReturnResult(testhelper.TestResult{Rows: 0})
+
+			_, _ = connection.Exec(longQuery)
+
+			snippet := connection.DescribePool()[0].LastQuery
+			Expect(snippet).To(HaveLen(100))
+			Expect(snippet).To(HavePrefix("SELECT aaa"))
+			Expect(snippet).To(HaveSuffix("..."))
+		})
+		It("does not split multi-byte characters when truncating", func() {
+			mock.ExpectExec("SELECT").WillReturnResult(testhelper.TestResult{Rows: 0})
+
+			_, _ = connection.Exec("SELECT '" + strings.Repeat("é", 100) + "'")
+
+			snippet := connection.DescribePool()[0].LastQuery
+			Expect(utf8.ValidString(snippet)).To(BeTrue())
+			Expect(snippet).To(Equal("SELECT '" + strings.Repeat("é", 44) + "..."))
+		})
+		It("reports transaction state and start time", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+
+			state := connection.DescribePool()[0]
+			Expect(state.Status).To(Equal(dbconn.ConnStatusIdleInTx))
+			Expect(state.TxStart).To(Equal(txStart))
+
+			mock.ExpectCommit()
+			connection.MustCommit()
+
+			state = connection.DescribePool()[0]
+			Expect(state.Status).To(Equal(dbconn.ConnStatusIdle))
+			Expect(state.TxStart.IsZero()).To(BeTrue())
+		})
+		It("reports a connection as active until its rows are read to the end", func() {
+			mock.ExpectQuery("SELECT i").WillReturnRows(sqlmock.NewRows([]string{"i"}).AddRow(1).AddRow(2))
+
+			rows, err := connection.Query("SELECT i FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connection.DescribePool()[0].Status).To(Equal(dbconn.ConnStatusActive))
+
+			Expect(rows.Next()).To(BeTrue())
+			Expect(rows.Next()).To(BeTrue())
+			Expect(connection.DescribePool()[0].Status).To(Equal(dbconn.ConnStatusActive))
+			Expect(rows.Next()).To(BeFalse())
+			Expect(connection.DescribePool()[0].Status).To(Equal(dbconn.ConnStatusIdle))
+		})
+		It("records the error that ended reading the rows", func() {
+			mock.ExpectQuery("SELECT i").WillReturnRows(sqlmock.NewRows([]string{"i"}).AddRow(1).RowError(0, fmt.Errorf("row error")))
+
+			rows, err := connection.Query("SELECT i FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rows.Next()).To(BeFalse())
+
+			state := connection.DescribePool()[0]
+			Expect(state.Status).To(Equal(dbconn.ConnStatusIdle))
+			Expect(state.LastError).To(MatchError("row error"))
+			Expect(rows.Close()).To(Succeed())
+		})
+		It("returns an empty list for a closed connection", func() {
+			connection.Close()
+			Expect(connection.DescribePool()).To(BeEmpty())
+		})
+		It("returns an empty list for a DBConn that was not created by NewDBConn", func() {
+			Expect((&dbconn.DBConn{}).DescribePool()).To(BeEmpty())
+		})
+	})
+	Describe("ConnState.String", func() {
+		It("formats an idle connection", func() {
+			state := dbconn.ConnState{ConnNum: 1, Status: dbconn.ConnStatusIdle, BackendPID: 1234}
+			Expect(state.String()).To(Equal("conn 1 (pid 1234): idle"))
+		})
+		It("formats a connection in a transaction with a failed query", func() {
+			state := dbconn.ConnState{
+				ConnNum:    0,
+				Status:     dbconn.ConnStatusIdleInTx,
+				BackendPID: 1234,
+				TxStart:    txStart,
+				LastQuery:  "SELECT 1",
+				LastError:  fmt.Errorf("some error"),
+			}
+			Expect(state.String()).To(Equal("conn 0 (pid 1234): idle in transaction since 20170101:01:01:01, last query: SELECT 1, last error: some error"))
+		})
+	})
+})
@@ -0,0 +1,161 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to caching the results of
+ * queries that are run repeatedly, such as catalog lookups.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+type queryCacheEntry struct {
+	query   string
+	value   reflect.Value
+	expires time.Time // The zero time if the entry does not expire
+}
+
+/*
+ * queryCache is shared by every connection in the pool, since they are all
+ * connected to the same database, so access is guarded.
+ */
+type queryCache struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	entries map[string]queryCacheEntry
+}
+
+func queryCacheKey(query string, args []interface{}) string {
+	return fmt.Sprintf("%q %#v", query, args)
+}
+
+/*
+ * EnableQueryCache caches the results of Get, GetWithArgs, Select, and
+ * SelectWithArgs, keyed by the query and its arguments, so that running the
+ * same query again returns the cached result without a round trip to the
+ * database.  Results expire after ttl, or never if ttl is 0.
+ *
+ * Queries run inside a transaction never use or populate the cache, as their
+ * results may depend on uncommitted changes or the transaction's snapshot.
+ * The cache is not invalidated when the database is modified, so callers that
+ * change the objects they query must call InvalidateQueryCache or
+ * InvalidateCachedQuery afterward.
+ *
+ * Results are copied into and out of the cache, but values that refer to other
+ * memory (e.g. a slice inside a struct) are shared, so they must not be
+ * modified by the caller.
+ *
+ * Enabling the cache when it is already enabled discards any cached results.
+ */
+func (dbconn *DBConn) EnableQueryCache(ttl time.Duration) {
+	dbconn.queryCache = &queryCache{ttl: ttl, entries: make(map[string]queryCacheEntry)}
+}
+
+func (dbconn *DBConn) DisableQueryCache() {
+	dbconn.queryCache = nil
+}
+
+// InvalidateQueryCache discards every cached result
+func (dbconn *DBConn) InvalidateQueryCache() {
+	cache := dbconn.queryCache
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.entries = make(map[string]queryCacheEntry)
+}
+
+// InvalidateCachedQuery discards the cached results of the query, for any arguments
+func (dbconn *DBConn) InvalidateCachedQuery(query string) {
+	cache := dbconn.queryCache
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for key, entry := range cache.entries {
+		if entry.query == query {
+			delete(cache.entries, key)
+		}
+	}
+}
+
+/*
+ * Calls run to execute the query unless a cached result is available, in which
+ * case the result is stored in destination instead.  As with sqlx, the rows of
+ * a result selected into a slice are appended to the slice.
+ */
+func (dbconn *DBConn) withQueryCache(connNum int, destination interface{}, query string, args []interface{}, run func() error) error {
+	cache := dbconn.queryCache
+	dest := reflect.ValueOf(destination)
+	if cache == nil || dbconn.Tx[connNum] != nil || dest.Kind() != reflect.Ptr || dest.IsNil() {
+		return run()
+	}
+	key := queryCacheKey(query, args)
+	if cache.load(key, dest.Elem()) {
+		logDomain.Debug("Using cached result for query: %s", query)
+		return nil
+	}
+	start := 0
+	if dest.Elem().Kind() == reflect.Slice {
+		start = dest.Elem().Len()
+	}
+	if err := run(); err != nil {
+		return err
+	}
+	cache.store(key, query, dest.Elem(), start)
+	return nil
+}
+
+func (cache *queryCache) load(key string, dest reflect.Value) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, ok := cache.entries[key]
+	if !ok {
+		return false
+	}
+	if !entry.expires.IsZero() && !operating.System.Now().Before(entry.expires) {
+		delete(cache.entries, key)
+		return false
+	}
+	// The same query may be run with destinations of different types
+	if entry.value.Type() != dest.Type() {
+		return false
+	}
+	if dest.Kind() == reflect.Slice {
+		dest.Set(reflect.AppendSlice(dest, entry.value))
+	} else {
+		dest.Set(entry.value)
+	}
+	return true
+}
+
+func (cache *queryCache) store(key string, query string, dest reflect.Value, start int) {
+	var value reflect.Value
+	if dest.Kind() == reflect.Slice {
+		rows := dest.Slice(start, dest.Len())
+		value = reflect.AppendSlice(reflect.MakeSlice(dest.Type(), 0, rows.Len()), rows)
+	} else {
+		value = reflect.New(dest.Type()).Elem()
+		value.Set(dest)
+	}
+	entry := queryCacheEntry{query: query, value: value}
+	now := operating.System.Now()
+	if cache.ttl > 0 {
+		entry.expires = now.Add(cache.ttl)
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for existingKey, existing := range cache.entries {
+		if !existing.expires.IsZero() && !now.Before(existing.expires) {
+			delete(cache.entries, existingKey)
+		}
+	}
+	cache.entries[key] = entry
+}
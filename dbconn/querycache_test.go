@@ -0,0 +1,150 @@
+package dbconn_test
+
+import (
+	"fmt"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/querycache tests", func() {
+	type namespace struct {
+		Oid  int
+		Name string
+	}
+	const namespaceQuery = "SELECT oid, nspname AS name FROM pg_namespace"
+	var clock *testhelper.FakeClock
+	BeforeEach(func() {
+		clock = testhelper.MockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		connection.EnableQueryCache(time.Minute)
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	expectNamespaceQuery := func() {
+		mock.ExpectQuery("SELECT oid, nspname").WillReturnRows(sqlmock.NewRows([]string{"oid", "name"}).
+			AddRow(2200, "public").AddRow(11, "pg_catalog"))
+	}
+
+	Describe("DBConn.EnableQueryCache", func() {
+		It("returns cached results for repeated queries", func() {
+			expectNamespaceQuery()
+			first := make([]namespace, 0)
+			second := make([]namespace, 0)
+
+			Expect(connection.Select(&first, namespaceQuery)).To(Succeed())
+			first[0].Name = "modified"
+			Expect(connection.Select(&second, namespaceQuery)).To(Succeed())
+
+			Expect(second).To(Equal([]namespace{{2200, "public"}, {11, "pg_catalog"}}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("caches results separately for each set of arguments", func() {
+			mock.ExpectQuery("SELECT oid").WithArgs("public").WillReturnRows(sqlmock.NewRows([]string{"oid"}).AddRow(2200))
+			mock.ExpectQuery("SELECT oid").WithArgs("pg_catalog").WillReturnRows(sqlmock.NewRows([]string{"oid"}).AddRow(11))
+			query := "SELECT oid FROM pg_namespace WHERE nspname = $1"
+			var public, catalog, cached int
+
+			Expect(connection.GetWithArgs(&public, query, "public")).To(Succeed())
+			Expect(connection.GetWithArgs(&catalog, query, "pg_catalog")).To(Succeed())
+			Expect(connection.GetWithArgs(&cached, query, "public")).To(Succeed())
+
+			Expect([]int{public, catalog, cached}).To(Equal([]int{2200, 11, 2200}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("appends cached rows to the destination like an uncached query", func() {
+			expectNamespaceQuery()
+			results := make([]namespace, 0)
+
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+
+			Expect(results).To(HaveLen(4))
+			Expect(results[2]).To(Equal(namespace{2200, "public"}))
+		})
+		It("runs the query again after the results expire", func() {
+			expectNamespaceQuery()
+			expectNamespaceQuery()
+			results := make([]namespace, 0)
+
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+			clock.Advance(time.Minute)
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not cache failed queries", func() {
+			mock.ExpectQuery("SELECT oid, nspname").WillReturnError(fmt.Errorf("relation does not exist"))
+			expectNamespaceQuery()
+			results := make([]namespace, 0)
+
+			Expect(connection.Select(&results, namespaceQuery)).ToNot(Succeed())
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+
+			Expect(results).To(HaveLen(2))
+		})
+		It("is bypassed inside transactions", func() {
+			expectNamespaceQuery()
+			ExpectBegin(mock)
+			expectNamespaceQuery()
+			mock.ExpectCommit()
+			results := make([]namespace, 0)
+
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+			Expect(connection.WithTransaction(func(queryer dbconn.Queryer) error {
+				return queryer.Select(&results, namespaceQuery)
+			})).To(Succeed())
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("DBConn.InvalidateCachedQuery", func() {
+		It("discards the cached results of the query", func() {
+			expectNamespaceQuery()
+			mock.ExpectQuery("SELECT count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+			expectNamespaceQuery()
+			results := make([]namespace, 0)
+			var count int
+
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+			Expect(connection.Get(&count, "SELECT count(*) FROM pg_namespace")).To(Succeed())
+			connection.InvalidateCachedQuery(namespaceQuery)
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+			Expect(connection.Get(&count, "SELECT count(*) FROM pg_namespace")).To(Succeed())
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("DBConn.InvalidateQueryCache", func() {
+		It("discards every cached result", func() {
+			expectNamespaceQuery()
+			expectNamespaceQuery()
+			results := make([]namespace, 0)
+
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+			connection.InvalidateQueryCache()
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("DBConn.DisableQueryCache", func() {
+		It("runs every query", func() {
+			expectNamespaceQuery()
+			expectNamespaceQuery()
+			results := make([]namespace, 0)
+
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+			connection.DisableQueryCache()
+			Expect(connection.Select(&results, namespaceQuery)).To(Succeed())
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
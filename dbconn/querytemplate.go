@@ -0,0 +1,123 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to queries whose text
+ * depends on the version of the database, e.g. because a catalog table was
+ * added or changed in a later major version.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/gpversion"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A QueryVariant is the text of a query for the versions that satisfy
+ * Constraint, which is a constraint as accepted by GPDBVersion.Satisfies, e.g.
+ * "<6" or ">=6,<7".
+ */
+type QueryVariant struct {
+	Constraint string
+	Query      string
+}
+
+/*
+ * A QueryTemplate holds the variants of a query for different versions, in
+ * place of an if/else chain on the connection's version, e.g.
+ *   var segmentQuery = dbconn.QueryTemplate{
+ *   	Name:     "segment configuration",
+ *   	Versions: []string{"5", "6", "7"},
+ *   	Variants: []dbconn.QueryVariant{
+ *   		{Constraint: "<6", Query: "SELECT ... FROM pg_filespace_entry ..."},
+ *   		{Constraint: ">=6", Query: "SELECT ... FROM gp_segment_configuration ..."},
+ *   	},
+ *   }
+ * Variants are checked in order and the first one whose constraint the version
+ * satisfies is used, so a general variant may follow more specific ones, as
+ * the cases of a switch statement do.
+ *
+ * Versions lists the versions the query must support, for Validate to check.
+ * Each is a version as accepted by NewVersion, or a major or major.minor
+ * version, which stands for its first release, e.g. "6" for 6.0.0.
+ */
+type QueryTemplate struct {
+	Name     string
+	Versions []string
+	Variants []QueryVariant
+}
+
+/*
+ * Validate returns an error if any variant's constraint is invalid or if any
+ * of the template's Versions is not covered by a variant.  As templates are
+ * typically package-level variables, Validate is meant to be called from the
+ * calling package's tests, so that a missing variant is found before the query
+ * is run against that version.
+ */
+func (template QueryTemplate) Validate() error {
+	for _, variant := range template.Variants {
+		if _, err := gpversion.ParseRange(variant.Constraint); err != nil {
+			return errors.Wrapf(err, "Invalid variant of query %s", template.Name)
+		}
+	}
+	uncovered := make([]string, 0)
+	for _, versionStr := range template.Versions {
+		version, err := declaredVersion(versionStr)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid version for query %s", template.Name)
+		}
+		if _, err := template.variantFor(version); err != nil {
+			uncovered = append(uncovered, versionStr)
+		}
+	}
+	if len(uncovered) > 0 {
+		return errors.Errorf("Query %s has no variant for version(s) %s", template.Name, strings.Join(uncovered, ", "))
+	}
+	return nil
+}
+
+func declaredVersion(versionStr string) (GPDBVersion, error) {
+	if numDigits := len(strings.Split(versionStr, ".")); numDigits < 3 {
+		versionStr += strings.Repeat(".0", 3-numDigits)
+	}
+	semVer, err := semver.Parse(versionStr)
+	if err != nil {
+		return GPDBVersion{}, err
+	}
+	return GPDBVersion{VersionString: versionStr, SemVer: semVer}, nil
+}
+
+func (template QueryTemplate) variantFor(version GPDBVersion) (QueryVariant, error) {
+	for _, variant := range template.Variants {
+		if satisfied, err := version.Satisfies(variant.Constraint); err == nil && satisfied {
+			return variant, nil
+		}
+	}
+	return QueryVariant{}, errors.Errorf("Query %s has no variant for version %s", template.Name, version.VersionString)
+}
+
+/*
+ * Render returns the query for the given version.  If args are given, the
+ * query is used as a format string for them, as with fmt.Sprintf; otherwise it
+ * is returned as it is, so that queries without args need not escape "%".
+ */
+func (template QueryTemplate) Render(version GPDBVersion, args ...interface{}) (string, error) {
+	variant, err := template.variantFor(version)
+	if err != nil {
+		return "", err
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(variant.Query, args...), nil
+	}
+	return variant.Query, nil
+}
+
+func (template QueryTemplate) MustRender(version GPDBVersion, args ...interface{}) string {
+	query, err := template.Render(version, args...)
+	gplog.FatalOnError(err)
+	return query
+}
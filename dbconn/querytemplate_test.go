@@ -0,0 +1,70 @@
+package dbconn_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/querytemplate tests", func() {
+	template := dbconn.QueryTemplate{
+		Name:     "segment directories",
+		Versions: []string{"5", "6", "7"},
+		Variants: []dbconn.QueryVariant{
+			{Constraint: "<6", Query: "SELECT fselocation FROM pg_filespace_entry"},
+			{Constraint: ">=6.20,<7", Query: "SELECT datadir FROM gp_segment_configuration WHERE role = '%s'"},
+			{Constraint: ">=6", Query: "SELECT datadir FROM gp_segment_configuration WHERE content >= 0"},
+		},
+	}
+	Describe("QueryTemplate.Render", func() {
+		It("returns the first variant the version satisfies", func() {
+			Expect(template.Render(dbconn.NewVersion("5.28.0"))).To(Equal("SELECT fselocation FROM pg_filespace_entry"))
+			Expect(template.Render(dbconn.NewVersion("6.1.0"))).To(Equal("SELECT datadir FROM gp_segment_configuration WHERE content >= 0"))
+			Expect(template.Render(dbconn.NewVersion("7.0.0"))).To(Equal("SELECT datadir FROM gp_segment_configuration WHERE content >= 0"))
+		})
+		It("formats the query with the args", func() {
+			Expect(template.Render(dbconn.NewVersion("6.25.3"), "p")).To(Equal("SELECT datadir FROM gp_segment_configuration WHERE role = 'p'"))
+		})
+		It("leaves the query unchanged if there are no args", func() {
+			Expect(template.Render(dbconn.NewVersion("6.25.3"))).To(Equal("SELECT datadir FROM gp_segment_configuration WHERE role = '%s'"))
+		})
+		It("returns an error if no variant matches the version", func() {
+			gpdb6Only := dbconn.QueryTemplate{Name: "segment directories", Variants: template.Variants[1:]}
+
+			_, err := gpdb6Only.Render(dbconn.NewVersion("5.28.0"))
+
+			Expect(err).To(MatchError("Query segment directories has no variant for version 5.28.0"))
+		})
+		It("panics in MustRender if no variant matches the version", func() {
+			gpdb6Only := dbconn.QueryTemplate{Name: "segment directories", Variants: template.Variants[1:]}
+			defer testhelper.ShouldPanicWithMessage("Query segment directories has no variant for version 5.28.0")
+			gpdb6Only.MustRender(dbconn.NewVersion("5.28.0"))
+		})
+	})
+	Describe("QueryTemplate.Validate", func() {
+		It("succeeds if every version is covered", func() {
+			Expect(template.Validate()).To(Succeed())
+		})
+		It("returns an error naming the versions that are not covered", func() {
+			incomplete := dbconn.QueryTemplate{
+				Name:     "resource groups",
+				Versions: []string{"5", "6", "6.20", "7.1.0"},
+				Variants: []dbconn.QueryVariant{{Constraint: ">=6.20,<7", Query: "SELECT 1"}},
+			}
+
+			Expect(incomplete.Validate()).To(MatchError("Query resource groups has no variant for version(s) 5, 6, 7.1.0"))
+		})
+		It("returns an error for an invalid constraint", func() {
+			invalid := dbconn.QueryTemplate{Name: "bad", Variants: []dbconn.QueryVariant{{Constraint: "", Query: "SELECT 1"}}}
+
+			Expect(invalid.Validate()).To(MatchError(`Invalid variant of query bad: Invalid version constraint ""`))
+		})
+		It("returns an error for an invalid version", func() {
+			invalid := dbconn.QueryTemplate{Name: "bad", Versions: []string{"six"}}
+
+			Expect(invalid.Validate()).To(MatchError(ContainSubstring("Invalid version for query bad")))
+		})
+	})
+})
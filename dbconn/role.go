@@ -0,0 +1,120 @@
+package dbconn
+
+/*
+ * This file contains functions related to switching the role that queries on
+ * a connection run as, e.g. so that a utility connected as a superuser can
+ * create objects owned by another role without opening a second DBConn.
+ */
+
+import (
+	"fmt"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+ * ConnectAs connects as Connect does, then switches every connection to the
+ * given role, which the connecting user must be a member of.  The role is
+ * kept in dbconn.Role, so connections added by Resize also switch to it and
+ * ResetRole returns to it rather than to the connecting user.
+ */
+func (dbconn *DBConn) ConnectAs(role string, numConns int, utilityMode ...bool) error {
+	if err := validateQuotedIdentifier(role, dbconn.Version); err != nil {
+		return errors.Wrap(err, "Invalid role name")
+	}
+	dbconn.Role = role
+	return dbconn.Connect(numConns, utilityMode...)
+}
+
+func (dbconn *DBConn) MustConnectAs(role string, numConns int, utilityMode ...bool) {
+	err := dbconn.ConnectAs(role, numConns, utilityMode...)
+	gplog.FatalOnError(err)
+}
+
+// Switches a newly opened connection to dbconn.Role, if one is set
+func (dbconn *DBConn) setSessionRole(conn *sqlx.DB) error {
+	if dbconn.Role == "" {
+		return nil
+	}
+	if _, err := conn.Exec(fmt.Sprintf("SET ROLE %s", quoteIdentifier(dbconn.Role))); err != nil {
+		return errors.Wrapf(err, "Unable to set role %s", dbconn.Role)
+	}
+	return nil
+}
+
+func (dbconn *DBConn) MustSetRole(role string, whichConn ...int) {
+	err := dbconn.SetRole(role, whichConn...)
+	gplog.FatalOnError(err)
+}
+
+/*
+ * SetRole switches the given connection to role until ResetRole is called or
+ * the connection is released.  If it is called in a transaction that is then
+ * rolled back, the server also reverts the role when the transaction is rolled
+ * back; calling ResetRole afterward is harmless.
+ */
+func (dbconn *DBConn) SetRole(role string, whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if err := validateQuotedIdentifier(role, dbconn.Version); err != nil {
+		return errors.Wrap(err, "Invalid role name")
+	}
+	if _, err := dbconn.Exec(fmt.Sprintf("SET ROLE %s", quoteIdentifier(role)), connNum); err != nil {
+		return errors.Wrapf(err, "Unable to set role %s on connection %d", role, connNum)
+	}
+	dbconn.tracker.setRole(connNum, role)
+	return nil
+}
+
+func (dbconn *DBConn) MustResetRole(whichConn ...int) {
+	err := dbconn.ResetRole(whichConn...)
+	gplog.FatalOnError(err)
+}
+
+// ResetRole switches the given connection back to dbconn.Role, or to the connecting user if it is not set
+func (dbconn *DBConn) ResetRole(whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	query := "RESET ROLE"
+	if dbconn.Role != "" {
+		query = fmt.Sprintf("SET ROLE %s", quoteIdentifier(dbconn.Role))
+	}
+	_, err := dbconn.Exec(query, connNum)
+	if err == nil {
+		dbconn.tracker.setRole(connNum, dbconn.Role)
+	}
+	return err
+}
+
+/*
+ * WithRole switches the given connection to role, calls fn with a Queryer
+ * that runs all of its queries on that connection, and then resets the role,
+ * even if fn returns an error or panics.  The error from fn is returned, or
+ * the error from resetting the role if fn succeeds; if both fail, the reset
+ * error is logged as a warning.
+ *
+ * WithRole may be called inside WithTransaction, using the same connection;
+ * if the transaction has been aborted by an error in fn, the role cannot be
+ * reset until it is rolled back, which reverts the role in any case.
+ */
+func (dbconn *DBConn) WithRole(role string, fn func(conn Queryer) error, whichConn ...int) (err error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if err = dbconn.SetRole(role, connNum); err != nil {
+		return err
+	}
+	defer func() {
+		recovered := recover()
+		resetErr := dbconn.ResetRole(connNum)
+		if resetErr != nil {
+			if err != nil || recovered != nil {
+				logDomain.Warn("Cannot reset role on connection %d: %v", connNum, resetErr)
+			} else {
+				err = resetErr
+			}
+		}
+		if recovered != nil {
+			panic(recovered)
+		}
+	}()
+	return fn(connQueryer{dbconn: dbconn, connNum: connNum})
+}
@@ -0,0 +1,147 @@
+package dbconn_test
+
+import (
+	"fmt"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/role tests", func() {
+	fakeResult := testhelper.TestResult{Rows: 0}
+	Describe("DBConn.ConnectAs", func() {
+		BeforeEach(func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+		})
+		It("switches every connection to the role", func() {
+			mock.ExpectExec(`SET ROLE "owner"`).WillReturnResult(fakeResult)
+			mock.ExpectExec(`SET ROLE "owner"`).WillReturnResult(fakeResult)
+
+			Expect(connection.ConnectAs("owner", 2)).To(Succeed())
+
+			Expect(connection.Role).To(Equal("owner"))
+			Expect(connection.DescribePool()[1].Role).To(Equal("owner"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if the role cannot be set", func() {
+			mock.ExpectExec(`SET ROLE "owner"`).WillReturnError(errors.New(`permission denied to set role "owner"`))
+
+			err := connection.ConnectAs("owner", 1)
+
+			Expect(err).To(MatchError(`Unable to set role owner: permission denied to set role "owner"`))
+		})
+		It("returns an error if the role name is invalid", func() {
+			err := connection.ConnectAs("", 1)
+
+			Expect(err).To(MatchError(`Invalid role name: Identifier "" is empty`))
+		})
+	})
+	Describe("DBConn.SetRole", func() {
+		It("quotes the role name", func() {
+			mock.ExpectExec(`SET ROLE "Owner""s role"`).WillReturnResult(fakeResult)
+
+			Expect(connection.SetRole(`Owner"s role`)).To(Succeed())
+
+			Expect(connection.DescribePool()[0].Role).To(Equal(`Owner"s role`))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("DBConn.ResetRole", func() {
+		It("resets the role to the connecting user", func() {
+			mock.ExpectExec(`SET ROLE "owner"`).WillReturnResult(fakeResult)
+			mock.ExpectExec("RESET ROLE").WillReturnResult(fakeResult)
+			connection.MustSetRole("owner")
+
+			Expect(connection.ResetRole()).To(Succeed())
+
+			Expect(connection.DescribePool()[0].Role).To(Equal(""))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("resets the role to the role the DBConn connected as", func() {
+			connection.Role = "admin"
+			mock.ExpectExec(`SET ROLE "admin"`).WillReturnResult(fakeResult)
+
+			Expect(connection.ResetRole()).To(Succeed())
+
+			Expect(connection.DescribePool()[0].Role).To(Equal("admin"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("DBConn.WithRole", func() {
+		It("runs the function as the role and then resets the role", func() {
+			mock.ExpectExec(`SET ROLE "owner"`).WillReturnResult(fakeResult)
+			mock.ExpectExec("CREATE TABLE foo").WillReturnResult(fakeResult)
+			mock.ExpectExec("RESET ROLE").WillReturnResult(fakeResult)
+
+			err := connection.WithRole("owner", func(conn dbconn.Queryer) error {
+				_, err := conn.Exec("CREATE TABLE foo(i int)")
+				return err
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("resets the role and returns the error if the function fails", func() {
+			mock.ExpectExec(`SET ROLE "owner"`).WillReturnResult(fakeResult)
+			mock.ExpectExec("RESET ROLE").WillReturnResult(fakeResult)
+
+			err := connection.WithRole("owner", func(conn dbconn.Queryer) error {
+				return errors.New("function failed")
+			})
+
+			Expect(err).To(MatchError("function failed"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("resets the role if the function panics", func() {
+			mock.ExpectExec(`SET ROLE "owner"`).WillReturnResult(fakeResult)
+			mock.ExpectExec("RESET ROLE").WillReturnResult(fakeResult)
+
+			Expect(func() {
+				_ = connection.WithRole("owner", func(conn dbconn.Queryer) error {
+					panic("function panicked")
+				})
+			}).To(PanicWith("function panicked"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns the error from resetting the role if the function succeeds", func() {
+			mock.ExpectExec(`SET ROLE "owner"`).WillReturnResult(fakeResult)
+			mock.ExpectExec("RESET ROLE").WillReturnError(errors.New("connection lost"))
+
+			err := connection.WithRole("owner", func(conn dbconn.Queryer) error { return nil })
+
+			Expect(err).To(MatchError("connection lost"))
+			Expect(connection.DescribePool()[0].Role).To(Equal("owner"))
+		})
+		It("does not call the function if the role cannot be set", func() {
+			mock.ExpectExec(`SET ROLE "owner"`).WillReturnError(errors.New(`role "owner" does not exist`))
+			called := false
+
+			err := connection.WithRole("owner", func(conn dbconn.Queryer) error {
+				called = true
+				return nil
+			})
+
+			Expect(err).To(MatchError(`Unable to set role owner on connection 0: role "owner" does not exist`))
+			Expect(called).To(BeFalse())
+		})
+	})
+	Describe("DBConn.Release", func() {
+		It("resets a role left set on the connection", func() {
+			_, _, logfile := testhelper.SetupTestLogger()
+			connNum := connection.MustAcquire()
+			mock.ExpectExec(`SET ROLE "owner"`).WillReturnResult(fakeResult)
+			mock.ExpectExec("RESET ROLE").WillReturnResult(fakeResult)
+			connection.MustSetRole("owner", connNum)
+
+			connection.Release(connNum)
+
+			testhelper.ExpectRegexp(logfile, fmt.Sprintf("Connection %d was released with role owner set; resetting role", connNum))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
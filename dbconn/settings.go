@@ -0,0 +1,176 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to reading server
+ * configuration parameters and converting their values, which SHOW displays
+ * with units such as "128MB" or "5min", to Go types.
+ */
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A Setting is a server configuration parameter as SHOW displays it.  Its
+ * accessors convert Value to the parameter's type, returning an error if the
+ * value cannot be converted.  Description is empty for settings returned by
+ * ShowSetting.
+ */
+type Setting struct {
+	Name        string `db:"name"`
+	Value       string `db:"setting"`
+	Description string `db:"description"`
+}
+
+var (
+	settingNamePattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)?$`)
+	settingValuePattern = regexp.MustCompile(`^\s*(-?[0-9]+(?:\.[0-9]+)?)\s*([A-Za-z]*)\s*$`)
+)
+
+// The units SHOW uses for memory and time parameters, as multiples of bytes and durations
+var (
+	sizeUnits = map[string]int64{
+		"B":  1,
+		"kB": 1 << 10,
+		"MB": 1 << 20,
+		"GB": 1 << 30,
+		"TB": 1 << 40,
+	}
+	durationUnits = map[string]time.Duration{
+		"us":  time.Microsecond,
+		"ms":  time.Millisecond,
+		"s":   time.Second,
+		"min": time.Minute,
+		"h":   time.Hour,
+		"d":   24 * time.Hour,
+	}
+)
+
+// The server accepts any unique prefix of these words, but SHOW displays booleans as "on" or "off"
+func (setting Setting) Bool() (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(setting.Value)) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, errors.Errorf("Unable to parse value %q of setting %s as a boolean", setting.Value, setting.Name)
+}
+
+func (setting Setting) Int() (int64, error) {
+	value, err := strconv.ParseInt(strings.TrimSpace(setting.Value), 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("Unable to parse value %q of setting %s as an integer", setting.Value, setting.Name)
+	}
+	return value, nil
+}
+
+func (setting Setting) Float() (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(setting.Value), 64)
+	if err != nil {
+		return 0, errors.Errorf("Unable to parse value %q of setting %s as a number", setting.Value, setting.Name)
+	}
+	return value, nil
+}
+
+/*
+ * Bytes returns the value of a memory parameter, such as work_mem, in bytes.
+ * SHOW displays sizes in the largest unit that divides them evenly, e.g.
+ * "8kB", "128MB", or "1GB", whatever unit the parameter is defined in.  A
+ * value without a unit, such as the 0 or -1 that SHOW displays for a disabled
+ * limit, is returned as it is, so that it can be compared with the special
+ * values described in the server documentation.
+ */
+func (setting Setting) Bytes() (int64, error) {
+	number, unit, ok := splitSettingValue(setting.Value)
+	multiplier, known := sizeUnits[unit]
+	if !ok || (unit != "" && !known) {
+		return 0, errors.Errorf("Unable to parse value %q of setting %s as a size", setting.Value, setting.Name)
+	}
+	if unit == "" {
+		multiplier = 1
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+/*
+ * Duration returns the value of a time parameter, such as statement_timeout.
+ * As with Bytes, a value without a unit is returned as it is, so 0 and -1
+ * are returned as durations of 0 and -1 nanoseconds.
+ */
+func (setting Setting) Duration() (time.Duration, error) {
+	number, unit, ok := splitSettingValue(setting.Value)
+	multiplier, known := durationUnits[unit]
+	if !ok || (unit != "" && !known) {
+		return 0, errors.Errorf("Unable to parse value %q of setting %s as a duration", setting.Value, setting.Name)
+	}
+	if unit == "" {
+		multiplier = 1
+	}
+	return time.Duration(number * float64(multiplier)), nil
+}
+
+func splitSettingValue(value string) (float64, string, bool) {
+	match := settingValuePattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, "", false
+	}
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return number, match[2], true
+}
+
+/*
+ * ShowAllSettings returns every configuration parameter of the session on the
+ * given connection, as SHOW ALL displays them, keyed by parameter name.
+ */
+func (dbconn *DBConn) ShowAllSettings(whichConn ...int) (map[string]Setting, error) {
+	settings := make([]Setting, 0)
+	err := dbconn.Select(&settings, "SHOW ALL", whichConn...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read server settings")
+	}
+	settingsByName := make(map[string]Setting, len(settings))
+	for _, setting := range settings {
+		settingsByName[setting.Name] = setting
+	}
+	return settingsByName, nil
+}
+
+func (dbconn *DBConn) MustShowAllSettings(whichConn ...int) map[string]Setting {
+	settings, err := dbconn.ShowAllSettings(whichConn...)
+	gplog.FatalOnError(err)
+	return settings
+}
+
+/*
+ * ShowSetting returns a single configuration parameter of the session on the
+ * given connection.  Parameter names are not quoted, as SHOW treats them
+ * case-insensitively, so names that are not plain or dotted identifiers are
+ * rejected rather than sent to the server.
+ */
+func (dbconn *DBConn) ShowSetting(name string, whichConn ...int) (Setting, error) {
+	if !settingNamePattern.MatchString(name) {
+		return Setting{}, errors.Errorf("Invalid setting name %q", name)
+	}
+	setting := Setting{Name: name}
+	err := dbconn.Get(&setting.Value, "SHOW "+name, whichConn...)
+	if err != nil {
+		return Setting{}, errors.Wrapf(err, "Unable to read setting %s", name)
+	}
+	return setting, nil
+}
+
+func (dbconn *DBConn) MustShowSetting(name string, whichConn ...int) Setting {
+	setting, err := dbconn.ShowSetting(name, whichConn...)
+	gplog.FatalOnError(err)
+	return setting
+}
@@ -0,0 +1,120 @@
+package dbconn_test
+
+import (
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/settings tests", func() {
+	Describe("Setting accessors", func() {
+		DescribeTable("Bool", func(value string, expected bool) {
+			result, err := dbconn.Setting{Name: "fsync", Value: value}.Bool()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(expected))
+		},
+			Entry("on", "on", true),
+			Entry("off", "off", false),
+			Entry("true in upper case", "TRUE", true),
+			Entry("0", "0", false),
+		)
+		DescribeTable("Bytes", func(value string, expected int64) {
+			result, err := dbconn.Setting{Name: "work_mem", Value: value}.Bytes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(expected))
+		},
+			Entry("bytes", "512B", int64(512)),
+			Entry("kilobytes", "8kB", int64(8192)),
+			Entry("megabytes", "128MB", int64(128*1024*1024)),
+			Entry("gigabytes", "2GB", int64(2*1024*1024*1024)),
+			Entry("terabytes", "1TB", int64(1024*1024*1024*1024)),
+			Entry("a disabled limit", "-1", int64(-1)),
+			Entry("zero", "0", int64(0)),
+		)
+		DescribeTable("Duration", func(value string, expected time.Duration) {
+			result, err := dbconn.Setting{Name: "statement_timeout", Value: value}.Duration()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(expected))
+		},
+			Entry("microseconds", "500us", 500*time.Microsecond),
+			Entry("milliseconds", "200ms", 200*time.Millisecond),
+			Entry("seconds", "30s", 30*time.Second),
+			Entry("minutes", "5min", 5*time.Minute),
+			Entry("hours", "1h", time.Hour),
+			Entry("days", "1d", 24*time.Hour),
+			Entry("a disabled timeout", "0", time.Duration(0)),
+		)
+		It("parses integers and numbers", func() {
+			Expect(dbconn.Setting{Name: "max_connections", Value: "250"}.Int()).To(Equal(int64(250)))
+			Expect(dbconn.Setting{Name: "random_page_cost", Value: "1.5"}.Float()).To(Equal(1.5))
+		})
+		It("returns an error for values of the wrong type", func() {
+			_, err := dbconn.Setting{Name: "work_mem", Value: "5min"}.Bytes()
+			Expect(err).To(MatchError(`Unable to parse value "5min" of setting work_mem as a size`))
+			_, err = dbconn.Setting{Name: "statement_timeout", Value: "128MB"}.Duration()
+			Expect(err).To(MatchError(`Unable to parse value "128MB" of setting statement_timeout as a duration`))
+			_, err = dbconn.Setting{Name: "work_mem", Value: "128MB"}.Int()
+			Expect(err).To(MatchError(`Unable to parse value "128MB" of setting work_mem as an integer`))
+			_, err = dbconn.Setting{Name: "search_path", Value: "public"}.Bool()
+			Expect(err).To(MatchError(`Unable to parse value "public" of setting search_path as a boolean`))
+		})
+	})
+	Describe("DBConn.ShowAllSettings", func() {
+		It("returns the settings keyed by name", func() {
+			rows := sqlmock.NewRows([]string{"name", "setting", "description"}).
+				AddRow("statement_timeout", "5min", "Sets the maximum allowed duration of any statement.").
+				AddRow("work_mem", "128MB", "Sets the maximum memory to be used for query workspaces.")
+			mock.ExpectQuery("SHOW ALL").WillReturnRows(rows)
+
+			settings, err := connection.ShowAllSettings()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(settings).To(HaveLen(2))
+			Expect(settings["work_mem"].Bytes()).To(Equal(int64(128 * 1024 * 1024)))
+			Expect(settings["statement_timeout"].Duration()).To(Equal(5 * time.Minute))
+			Expect(settings["statement_timeout"].Description).To(Equal("Sets the maximum allowed duration of any statement."))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("SHOW ALL").WillReturnError(errors.New("connection lost"))
+
+			_, err := connection.ShowAllSettings()
+
+			Expect(err).To(MatchError("Unable to read server settings: connection lost"))
+		})
+	})
+	Describe("DBConn.ShowSetting", func() {
+		It("returns the setting", func() {
+			mock.ExpectQuery("SHOW work_mem").WillReturnRows(sqlmock.NewRows([]string{"work_mem"}).AddRow("64kB"))
+
+			setting, err := connection.ShowSetting("work_mem")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(setting).To(Equal(dbconn.Setting{Name: "work_mem", Value: "64kB"}))
+		})
+		It("accepts custom settings with dotted names", func() {
+			mock.ExpectQuery(`SHOW gp_extension\.enabled`).WillReturnRows(sqlmock.NewRows([]string{"gp_extension.enabled"}).AddRow("on"))
+
+			setting := connection.MustShowSetting("gp_extension.enabled")
+
+			Expect(setting.Bool()).To(BeTrue())
+		})
+		It("rejects names that are not identifiers", func() {
+			_, err := connection.ShowSetting("work_mem; DROP TABLE foo")
+
+			Expect(err).To(MatchError(`Invalid setting name "work_mem; DROP TABLE foo"`))
+		})
+		It("returns an error if the setting does not exist", func() {
+			mock.ExpectQuery("SHOW no_such_setting").WillReturnError(errors.New(`unrecognized configuration parameter "no_such_setting"`))
+
+			_, err := connection.ShowSetting("no_such_setting")
+
+			Expect(err).To(MatchError(`Unable to read setting no_such_setting: unrecognized configuration parameter "no_such_setting"`))
+		})
+	})
+})
@@ -0,0 +1,118 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to configuring SSL/TLS for
+ * database connections.
+ */
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+type SSLMode string
+
+const (
+	SSLMODE_DISABLE     SSLMode = "disable"
+	SSLMODE_ALLOW       SSLMode = "allow"
+	SSLMODE_PREFER      SSLMode = "prefer"
+	SSLMODE_REQUIRE     SSLMode = "require"
+	SSLMODE_VERIFY_CA   SSLMode = "verify-ca"
+	SSLMODE_VERIFY_FULL SSLMode = "verify-full"
+)
+
+/*
+ * SSLOptions configures the SSL/TLS settings of a DBConn, with the same
+ * meanings as the libpq connection parameters sslmode, sslcert, sslkey,
+ * sslrootcert, and sslpassword.  If Mode is not set, PGSSLMODE is used, and
+ * "prefer" if that is not set either; any other option that is not set is
+ * taken from the corresponding PGSSL* environment variable by the driver.
+ *
+ * Cert and Key must be set together, and all files that are set are checked
+ * to be readable before connecting, so that a misconfigured path is reported
+ * as such rather than as a failed handshake.
+ */
+type SSLOptions struct {
+	Mode     SSLMode
+	Cert     string
+	Key      string
+	RootCert string
+	Password string
+}
+
+func (options SSLOptions) mode() SSLMode {
+	if options.Mode != "" {
+		return options.Mode
+	}
+	if sslmode := operating.System.Getenv("PGSSLMODE"); sslmode != "" {
+		return SSLMode(sslmode)
+	}
+	return SSLMODE_PREFER
+}
+
+func checkReadable(description string, filename string) error {
+	file, err := operating.System.OpenFileRead(filename, os.O_RDONLY, 0)
+	if err != nil {
+		return errors.Errorf("Unable to read %s file %s: %v", description, filename, err)
+	}
+	_ = file.Close()
+	return nil
+}
+
+// Validate is called by Connect, but may be called earlier to check user input
+func (options SSLOptions) Validate() error {
+	switch options.mode() {
+	case SSLMODE_DISABLE, SSLMODE_ALLOW, SSLMODE_PREFER, SSLMODE_REQUIRE, SSLMODE_VERIFY_CA, SSLMODE_VERIFY_FULL:
+	default:
+		return errors.Errorf("Invalid SSL mode %q", options.mode())
+	}
+	if options.Cert != "" && options.Key == "" {
+		return errors.New("An SSL key must be provided along with an SSL certificate")
+	}
+	if options.Key != "" && options.Cert == "" {
+		return errors.New("An SSL certificate must be provided along with an SSL key")
+	}
+	files := []struct {
+		description string
+		filename    string
+	}{
+		{"SSL certificate", options.Cert},
+		{"SSL key", options.Key},
+		{"SSL root certificate", options.RootCert},
+	}
+	for _, file := range files {
+		if file.filename == "" {
+			continue
+		}
+		if err := checkReadable(file.description, file.filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the SSL parameters to add to a connection string, with a leading space
+func (options SSLOptions) connectionParams() (string, error) {
+	if err := options.Validate(); err != nil {
+		return "", err
+	}
+	params := fmt.Sprintf(" sslmode='%s'", EscapeConnectionParam(string(options.mode())))
+	optionalParams := []struct {
+		name  string
+		value string
+	}{
+		{"sslcert", options.Cert},
+		{"sslkey", options.Key},
+		{"sslrootcert", options.RootCert},
+		{"sslpassword", options.Password},
+	}
+	for _, param := range optionalParams {
+		if param.value != "" {
+			params += fmt.Sprintf(" %s='%s'", param.name, EscapeConnectionParam(param.value))
+		}
+	}
+	return params, nil
+}
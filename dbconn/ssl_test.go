@@ -0,0 +1,110 @@
+package dbconn_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/jmoiron/sqlx"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type recordingDriver struct {
+	dbconn.DBDriver
+	dataSourceNames []string
+}
+
+func (driver *recordingDriver) Connect(driverName string, dataSourceName string) (*sqlx.DB, error) {
+	driver.dataSourceNames = append(driver.dataSourceNames, dataSourceName)
+	return driver.DBDriver.Connect(driverName, dataSourceName)
+}
+
+var _ = Describe("dbconn/ssl tests", func() {
+	var (
+		certDir  string
+		certFile string
+		keyFile  string
+		rootFile string
+	)
+	BeforeEach(func() {
+		certDir = GinkgoT().TempDir()
+		certFile = filepath.Join(certDir, "client.crt")
+		keyFile = filepath.Join(certDir, "client.key")
+		rootFile = filepath.Join(certDir, "root.crt")
+		for _, filename := range []string{certFile, keyFile, rootFile} {
+			Expect(os.WriteFile(filename, []byte("contents"), 0600)).To(Succeed())
+		}
+	})
+
+	Describe("SSLOptions.Validate", func() {
+		It("accepts readable certificate files", func() {
+			options := dbconn.SSLOptions{Mode: dbconn.SSLMODE_VERIFY_FULL, Cert: certFile, Key: keyFile, RootCert: rootFile}
+			Expect(options.Validate()).To(Succeed())
+		})
+		It("accepts empty options", func() {
+			Expect(dbconn.SSLOptions{}.Validate()).To(Succeed())
+		})
+		It("returns an error for an invalid mode", func() {
+			Expect(dbconn.SSLOptions{Mode: "always"}.Validate()).To(MatchError(`Invalid SSL mode "always"`))
+		})
+		It("returns an error for an invalid mode from PGSSLMODE", func() {
+			GinkgoT().Setenv("PGSSLMODE", "sometimes")
+			Expect(dbconn.SSLOptions{}.Validate()).To(MatchError(`Invalid SSL mode "sometimes"`))
+		})
+		It("returns an error if only one of the certificate and key is given", func() {
+			Expect(dbconn.SSLOptions{Cert: certFile}.Validate()).To(MatchError("An SSL key must be provided along with an SSL certificate"))
+			Expect(dbconn.SSLOptions{Key: keyFile}.Validate()).To(MatchError("An SSL certificate must be provided along with an SSL key"))
+		})
+		It("returns an error for a missing file", func() {
+			missingFile := filepath.Join(certDir, "missing.crt")
+			err := dbconn.SSLOptions{RootCert: missingFile}.Validate()
+			Expect(err).To(MatchError(HavePrefix("Unable to read SSL root certificate file " + missingFile + ": ")))
+		})
+	})
+	Describe("DBConn.Connect", func() {
+		var driver *recordingDriver
+		BeforeEach(func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver = &recordingDriver{DBDriver: connection.Driver}
+			connection.Driver = driver
+		})
+		It("passes the SSL options in the connection string", func() {
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			connection.SSL = dbconn.SSLOptions{Mode: dbconn.SSLMODE_VERIFY_CA, Cert: certFile, Key: keyFile, RootCert: rootFile, Password: `pass'word`}
+
+			Expect(connection.Connect(1)).To(Succeed())
+
+			Expect(driver.dataSourceNames).To(HaveLen(1))
+			Expect(driver.dataSourceNames[0]).To(ContainSubstring(
+				"sslmode='verify-ca' sslcert='" + certFile + "' sslkey='" + keyFile + "' sslrootcert='" + rootFile + `' sslpassword='pass\'word'`))
+		})
+		It("uses PGSSLMODE if no mode is given", func() {
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			GinkgoT().Setenv("PGSSLMODE", "require")
+
+			Expect(connection.Connect(1)).To(Succeed())
+
+			Expect(driver.dataSourceNames[0]).To(ContainSubstring(" sslmode='require' statement_cache_capacity=0"))
+		})
+		It("defaults to prefer", func() {
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			GinkgoT().Setenv("PGSSLMODE", "")
+
+			Expect(connection.Connect(1)).To(Succeed())
+
+			Expect(driver.dataSourceNames[0]).To(ContainSubstring(" sslmode='prefer' statement_cache_capacity=0"))
+		})
+		It("does not connect if the options are invalid", func() {
+			connection.SSL = dbconn.SSLOptions{Cert: certFile}
+
+			err := connection.Connect(1)
+
+			Expect(err).To(MatchError("An SSL key must be provided along with an SSL certificate"))
+			Expect(driver.dataSourceNames).To(BeEmpty())
+			Expect(connection.ConnPool).To(BeNil())
+		})
+	})
+})
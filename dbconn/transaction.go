@@ -0,0 +1,177 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to running a function
+ * inside a transaction that is always committed or rolled back afterward, and
+ * to savepoints within a transaction.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/pkg/errors"
+)
+
+type IsolationLevel string
+
+const (
+	ISOLATION_READ_COMMITTED  IsolationLevel = "READ COMMITTED"
+	ISOLATION_REPEATABLE_READ IsolationLevel = "REPEATABLE READ"
+	ISOLATION_SERIALIZABLE    IsolationLevel = "SERIALIZABLE"
+)
+
+/*
+ * A connQueryer runs every query on a single connection of a DBConn, ignoring
+ * any connection number passed to it, so that the function passed to
+ * WithTransaction cannot accidentally run queries outside the transaction.
+ */
+type connQueryer struct {
+	dbconn  *DBConn
+	connNum int
+}
+
+func (queryer connQueryer) Exec(query string, _ ...int) (sql.Result, error) {
+	return queryer.dbconn.Exec(query, queryer.connNum)
+}
+
+func (queryer connQueryer) ExecContext(queryContext context.Context, query string, _ ...int) (sql.Result, error) {
+	return queryer.dbconn.ExecContext(queryContext, query, queryer.connNum)
+}
+
+func (queryer connQueryer) Get(destination interface{}, query string, _ ...int) error {
+	return queryer.dbconn.Get(destination, query, queryer.connNum)
+}
+
+func (queryer connQueryer) GetWithArgs(destination interface{}, query string, args ...interface{}) error {
+	return queryer.dbconn.getWithArgsOnConn(queryer.connNum, destination, query, args...)
+}
+
+func (queryer connQueryer) Select(destination interface{}, query string, _ ...int) error {
+	return queryer.dbconn.Select(destination, query, queryer.connNum)
+}
+
+func (queryer connQueryer) SelectWithArgs(destination interface{}, query string, args ...interface{}) error {
+	return queryer.dbconn.selectWithArgsOnConn(queryer.connNum, destination, query, args...)
+}
+
+func (queryer connQueryer) SelectContext(ctx context.Context, destination interface{}, query string, _ ...int) error {
+	return queryer.dbconn.SelectContext(ctx, destination, query, queryer.connNum)
+}
+
+func (queryer connQueryer) Query(query string, _ ...int) (*Rows, error) {
+	return queryer.dbconn.Query(query, queryer.connNum)
+}
+
+func (queryer connQueryer) QueryWithArgs(query string, args ...interface{}) (*Rows, error) {
+	return queryer.dbconn.queryWithArgsOnConn(queryer.connNum, query, args...)
+}
+
+func (queryer connQueryer) QueryContext(ctx context.Context, query string, _ ...int) (*Rows, error) {
+	return queryer.dbconn.QueryContext(ctx, query, queryer.connNum)
+}
+
+func (queryer connQueryer) transcodeResults(query string, results []string) []string {
+	return queryer.dbconn.transcodeResults(query, results)
+}
+
+/*
+ * WithTransaction begins a transaction on the given connection, calls fn with
+ * a Queryer that runs all of its queries in that transaction, and commits the
+ * transaction if fn returns nil.  If fn returns an error or panics, the
+ * transaction is rolled back and the error is returned or the panic continues,
+ * so that the connection is never left with a transaction in progress.
+ */
+func (dbconn *DBConn) WithTransaction(fn func(tx Queryer) error, whichConn ...int) (err error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	rollback := func() {
+		if dbconn.Tx[connNum] == nil {
+			return
+		}
+		if rollbackErr := dbconn.Rollback(connNum); rollbackErr != nil {
+			logDomain.Warn("Cannot roll back transaction on connection %d: %v", connNum, rollbackErr)
+		}
+	}
+	if dbconn.Tx[connNum] != nil {
+		return errors.New("Cannot begin transaction; there is already a transaction in progress")
+	}
+	if err = dbconn.Begin(connNum); err != nil {
+		rollback()
+		return err
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			rollback()
+			panic(recovered)
+		}
+	}()
+	if err = fn(connQueryer{dbconn: dbconn, connNum: connNum}); err != nil {
+		rollback()
+		return err
+	}
+	return dbconn.Commit(connNum)
+}
+
+/*
+ * Savepoints let a single failed statement in a large transaction be retried
+ * or skipped without aborting the whole transaction: set a savepoint before the
+ * statement, roll back to it if the statement fails, and release it otherwise.
+ * Savepoints can only be used on a connection with a transaction in progress.
+ */
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// Reserved words are allowed, as the name is always quoted
+func validateQuotedIdentifier(name string, version GPDBVersion) error {
+	err := ValidateIdentifierForVersion(name, version)
+	var identifierErr *IdentifierError
+	if errors.As(err, &identifierErr) && identifierErr.Problem == IdentifierReservedWord {
+		return nil
+	}
+	return err
+}
+
+func (dbconn *DBConn) savepointCommand(command string, name string, whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if dbconn.Tx[connNum] == nil {
+		return errors.Errorf("Cannot %s; there is no transaction in progress", strings.ToLower(command))
+	}
+	if err := validateQuotedIdentifier(name, dbconn.Version); err != nil {
+		return errors.Wrap(err, "Invalid savepoint name")
+	}
+	_, err := dbconn.Exec(fmt.Sprintf("%s %s", command, quoteIdentifier(name)), connNum)
+	return err
+}
+
+func (dbconn *DBConn) MustSavepoint(name string, whichConn ...int) {
+	err := dbconn.Savepoint(name, whichConn...)
+	gplog.FatalOnError(err)
+}
+
+func (dbconn *DBConn) Savepoint(name string, whichConn ...int) error {
+	return dbconn.savepointCommand("SAVEPOINT", name, whichConn...)
+}
+
+func (dbconn *DBConn) MustRollbackToSavepoint(name string, whichConn ...int) {
+	err := dbconn.RollbackToSavepoint(name, whichConn...)
+	gplog.FatalOnError(err)
+}
+
+// The savepoint is kept after rolling back to it, so it can be rolled back to again
+func (dbconn *DBConn) RollbackToSavepoint(name string, whichConn ...int) error {
+	return dbconn.savepointCommand("ROLLBACK TO SAVEPOINT", name, whichConn...)
+}
+
+func (dbconn *DBConn) MustReleaseSavepoint(name string, whichConn ...int) {
+	err := dbconn.ReleaseSavepoint(name, whichConn...)
+	gplog.FatalOnError(err)
+}
+
+func (dbconn *DBConn) ReleaseSavepoint(name string, whichConn ...int) error {
+	return dbconn.savepointCommand("RELEASE SAVEPOINT", name, whichConn...)
+}
@@ -0,0 +1,162 @@
+package dbconn_test
+
+import (
+	"database/sql/driver"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/transaction tests", func() {
+	expectBeginWithIsolationLevel := func(isolationLevel string) {
+		mock.ExpectBegin()
+		mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL " + isolationLevel).WillReturnResult(testhelper.TestResult{Rows: 0})
+	}
+	Describe("DBConn.Begin", func() {
+		It("uses the connection's isolation level if one is set", func() {
+			connection.IsolationLevel = dbconn.ISOLATION_READ_COMMITTED
+			expectBeginWithIsolationLevel("READ COMMITTED")
+
+			Expect(connection.Begin()).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("uses the given isolation level", func() {
+			expectBeginWithIsolationLevel("REPEATABLE READ")
+
+			Expect(connection.BeginWithIsolationLevel(dbconn.ISOLATION_REPEATABLE_READ)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("DBConn.WithTransaction", func() {
+		It("commits the transaction if the function succeeds", func() {
+			ExpectBegin(mock)
+			mock.ExpectExec("CREATE TABLE foo").WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectQuery("SELECT count").WithArgs("foo").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectCommit()
+			var count int
+
+			err := connection.WithTransaction(func(tx dbconn.Queryer) error {
+				if _, err := tx.Exec("CREATE TABLE foo(i int)"); err != nil {
+					return err
+				}
+				return tx.GetWithArgs(&count, "SELECT count(*) FROM pg_class WHERE relname = $1", "foo")
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(1))
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("can be used with the selection helpers", func() {
+			ExpectBegin(mock)
+			mock.ExpectQuery("SELECT relname").WillReturnRows(sqlmock.NewRows([]string{"relname"}).AddRow("foo"))
+			mock.ExpectCommit()
+			var relname string
+
+			err := connection.WithTransaction(func(tx dbconn.Queryer) (err error) {
+				relname, err = dbconn.SelectString(tx, "SELECT relname FROM pg_class")
+				return err
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(relname).To(Equal("foo"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rolls back the transaction and returns the error if the function fails", func() {
+			ExpectBegin(mock)
+			mock.ExpectExec("CREATE TABLE foo").WillReturnError(errors.New("relation \"foo\" already exists"))
+			mock.ExpectRollback()
+
+			err := connection.WithTransaction(func(tx dbconn.Queryer) error {
+				_, err := tx.Exec("CREATE TABLE foo(i int)")
+				return err
+			})
+
+			Expect(err).To(MatchError(`relation "foo" already exists`))
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rolls back the transaction if the function panics", func() {
+			ExpectBegin(mock)
+			mock.ExpectRollback()
+
+			Expect(func() {
+				_ = connection.WithTransaction(func(tx dbconn.Queryer) error {
+					panic("failed mid-way")
+				})
+			}).To(PanicWith("failed mid-way"))
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("runs all queries on the given connection", func() {
+			connection, mock = testhelper.CreateAndConnectMockDB(2)
+			ExpectBegin(mock)
+			mock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow(driver.Value("foo")))
+			mock.ExpectCommit()
+
+			err := connection.WithTransaction(func(tx dbconn.Queryer) error {
+				Expect(connection.Tx[0]).To(BeNil())
+				Expect(connection.Tx[1]).ToNot(BeNil())
+				names := make([]string, 0)
+				return tx.Select(&names, "SELECT name FROM foo", 0)
+			}, 1)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not end a transaction that is already in progress", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+			called := false
+
+			err := connection.WithTransaction(func(tx dbconn.Queryer) error {
+				called = true
+				return nil
+			})
+
+			Expect(err).To(MatchError("Cannot begin transaction; there is already a transaction in progress"))
+			Expect(called).To(BeFalse())
+			Expect(connection.Tx[0]).ToNot(BeNil())
+		})
+	})
+	Describe("Savepoints", func() {
+		BeforeEach(func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+		})
+		It("sets, rolls back to, and releases a savepoint in the transaction", func() {
+			mock.ExpectExec(`SAVEPOINT "before_ddl"`).WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectExec(`ROLLBACK TO SAVEPOINT "before_ddl"`).WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectExec(`RELEASE SAVEPOINT "before_ddl"`).WillReturnResult(testhelper.TestResult{Rows: 0})
+
+			connection.MustSavepoint("before_ddl")
+			connection.MustRollbackToSavepoint("before_ddl")
+			connection.MustReleaseSavepoint("before_ddl")
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("quotes savepoint names", func() {
+			mock.ExpectExec(`SAVEPOINT "select ""1"""`).WillReturnResult(testhelper.TestResult{Rows: 0})
+
+			Expect(connection.Savepoint(`select "1"`)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rejects invalid savepoint names", func() {
+			err := connection.Savepoint("")
+			Expect(err).To(MatchError(`Invalid savepoint name: Identifier "" is empty`))
+		})
+		It("returns an error outside a transaction", func() {
+			mock.ExpectRollback()
+			connection.MustRollback()
+
+			err := connection.RollbackToSavepoint("before_ddl")
+
+			Expect(err).To(MatchError("Cannot rollback to savepoint; there is no transaction in progress"))
+		})
+	})
+})
@@ -1,16 +1,16 @@
 package dbconn
 
 import (
-	"regexp"
-	"strings"
-
 	"github.com/blang/semver"
+	"github.com/greenplum-db/gp-common-go-libs/gpversion"
 )
 
-type GPDBVersion struct {
-	VersionString string
-	SemVer        semver.Version
-}
+/*
+ * Version parsing and comparison live in the gpversion package so that they
+ * can be used without a database connection; GPDBVersion is kept as an alias
+ * for existing callers.
+ */
+type GPDBVersion = gpversion.Version
 
 /*
  * This constructor is intended as a convenience function for testing and
@@ -23,48 +23,21 @@ type GPDBVersion struct {
  * and the function will panic.
  */
 func NewVersion(versionStr string) GPDBVersion {
-	version := GPDBVersion{
+	return GPDBVersion{
 		VersionString: versionStr,
 		SemVer:        semver.MustParse(versionStr),
 	}
-	return version
 }
 
-func InitializeVersion(dbconn *DBConn) (dbversion GPDBVersion, err error) {
-	err = dbconn.Get(&dbversion, "SELECT pg_catalog.version() AS versionstring")
+func InitializeVersion(dbconn Queryer) (dbversion GPDBVersion, err error) {
+	var versionOutput string
+	err = dbconn.Get(&versionOutput, "SELECT pg_catalog.version() AS versionstring")
 	if err != nil {
 		return
 	}
-	versionStart := strings.Index(dbversion.VersionString, "(Greenplum Database ") + len("(Greenplum Database ")
-	versionEnd := strings.Index(dbversion.VersionString, ")")
-	dbversion.VersionString = dbversion.VersionString[versionStart:versionEnd]
-
-	pattern := regexp.MustCompile(`\d+\.\d+\.\d+`)
-	threeDigitVersion := pattern.FindStringSubmatch(dbversion.VersionString)[0]
-	dbversion.SemVer, err = semver.Make(threeDigitVersion)
-	return
+	return gpversion.Parse(versionOutput)
 }
 
 func StringToSemVerRange(versionStr string) semver.Range {
-	numDigits := len(strings.Split(versionStr, "."))
-	if numDigits < 3 {
-		versionStr += ".x"
-	}
-	validRange := semver.MustParseRange(versionStr)
-	return validRange
-}
-
-func (dbversion GPDBVersion) Before(targetVersion string) bool {
-	validRange := StringToSemVerRange("<" + targetVersion)
-	return validRange(dbversion.SemVer)
-}
-
-func (dbversion GPDBVersion) AtLeast(targetVersion string) bool {
-	validRange := StringToSemVerRange(">=" + targetVersion)
-	return validRange(dbversion.SemVer)
-}
-
-func (dbversion GPDBVersion) Is(targetVersion string) bool {
-	validRange := StringToSemVerRange("==" + targetVersion)
-	return validRange(dbversion.SemVer)
+	return gpversion.StringToRange(versionStr)
 }
@@ -3,6 +3,7 @@ package dbconn_test
 import (
 	"github.com/blang/semver"
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -39,6 +40,20 @@ var _ = Describe("dbconn/version tests", func() {
 			Expect(resultRange(v501)).To(BeFalse())
 		})
 	})
+	Describe("InitializeVersion", func() {
+		DescribeTable("parses the version string returned by each major version",
+			func(versionStr string) {
+				testhelper.ExpectFullVersionQuery(mock, versionStr)
+				version, err := dbconn.InitializeVersion(connection)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(version.VersionString).To(HavePrefix(versionStr + " build"))
+				Expect(version.SemVer).To(Equal(semver.MustParse(versionStr)))
+			},
+			Entry("GPDB 5", "5.28.0"),
+			Entry("GPDB 6", "6.20.0"),
+			Entry("GPDB 7", "7.0.0"),
+		)
+	})
 	Describe("Before", func() {
 		It("returns true when comparing 4.3 to 5", func() {
 			connection.Version = fake43
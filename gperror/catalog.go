@@ -0,0 +1,116 @@
+package gperror
+
+/*
+ * This file contains structs and functions related to looking up error
+ * messages by error code in per-locale message catalogs.
+ */
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+// Messages registered for DEFAULT_LOCALE are used when no translation is available
+const DEFAULT_LOCALE = "en"
+
+// A Catalog maps error codes to fmt format strings
+type Catalog map[ErrorCode]string
+
+var (
+	catalogMutex  sync.RWMutex
+	catalogs      = map[string]Catalog{}
+	currentLocale = DEFAULT_LOCALE
+)
+
+/*
+ * RegisterCatalog adds the messages in catalog to those for locale, replacing
+ * any already registered for the same codes, so that a product can register
+ * its own messages and then override some of them.  Locales are of the form
+ * used by LANG without the encoding, e.g. "ja" or "pt_BR".
+ */
+func RegisterCatalog(locale string, catalog Catalog) {
+	catalogMutex.Lock()
+	defer catalogMutex.Unlock()
+	if catalogs[locale] == nil {
+		catalogs[locale] = Catalog{}
+	}
+	for code, message := range catalog {
+		catalogs[locale][code] = message
+	}
+}
+
+func SetLocale(locale string) {
+	catalogMutex.Lock()
+	defer catalogMutex.Unlock()
+	currentLocale = locale
+}
+
+func GetLocale() string {
+	catalogMutex.RLock()
+	defer catalogMutex.RUnlock()
+	return currentLocale
+}
+
+/*
+ * LocaleFromEnvironment returns the locale for messages set in the environment,
+ * checking LC_ALL, LC_MESSAGES, and LANG in that order as gettext does, with
+ * any encoding or modifier removed, e.g. "de_DE" for "de_DE.UTF-8@euro".  The
+ * "C" and "POSIX" locales are returned as DEFAULT_LOCALE.  Callers that want
+ * to honor the environment can pass the result to SetLocale.
+ */
+func LocaleFromEnvironment() string {
+	for _, variable := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := operating.System.Getenv(variable)
+		if locale == "" {
+			continue
+		}
+		locale = strings.SplitN(locale, ".", 2)[0]
+		locale = strings.SplitN(locale, "@", 2)[0]
+		if locale == "C" || locale == "POSIX" {
+			return DEFAULT_LOCALE
+		}
+		return locale
+	}
+	return DEFAULT_LOCALE
+}
+
+/*
+ * Message returns the format string for code in the current locale, falling
+ * back to the locale's language without a region (e.g. "pt" for "pt_BR") and
+ * then to DEFAULT_LOCALE.  The second return value is false if no message has
+ * been registered for code in any of those locales.
+ */
+func Message(code ErrorCode) (string, bool) {
+	catalogMutex.RLock()
+	defer catalogMutex.RUnlock()
+	locales := []string{currentLocale}
+	if language := strings.SplitN(currentLocale, "_", 2)[0]; language != currentLocale {
+		locales = append(locales, language)
+	}
+	locales = append(locales, DEFAULT_LOCALE)
+	for _, locale := range locales {
+		if message, ok := catalogs[locale][code]; ok {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+/*
+ * NewFromCatalog creates an Error using the message registered for code.  If
+ * no message has been registered, the arguments are still included in the
+ * error so that no information is lost.
+ */
+func NewFromCatalog(errorCode ErrorCode, args ...any) Error {
+	message, ok := Message(errorCode)
+	if !ok {
+		message = "no message registered for this error code"
+		if len(args) > 0 {
+			message += strings.Repeat(" %v", len(args))
+		}
+	}
+	return &GpError{ErrorCode: errorCode, Err: fmt.Errorf(message, args...)}
+}
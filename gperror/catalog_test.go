@@ -0,0 +1,99 @@
+package gperror_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/greenplum-db/gp-common-go-libs/gperror"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+var _ = Describe("gperror/catalog", func() {
+	const (
+		diskFull     = gperror.ErrorCode(1001)
+		hostDown     = gperror.ErrorCode(1002)
+		untranslated = gperror.ErrorCode(1003)
+		unregistered = gperror.ErrorCode(1999)
+		overridden   = gperror.ErrorCode(1004)
+		regionalOnly = gperror.ErrorCode(1005)
+		languageOnly = gperror.ErrorCode(1006)
+	)
+
+	BeforeEach(func() {
+		gperror.RegisterCatalog(gperror.DEFAULT_LOCALE, gperror.Catalog{
+			diskFull:     "disk %s is full",
+			hostDown:     "host %s is unreachable",
+			untranslated: "only in English: %d",
+			overridden:   "original message",
+			languageOnly: "English message",
+		})
+		gperror.RegisterCatalog("de", gperror.Catalog{
+			diskFull:     "Festplatte %s ist voll",
+			languageOnly: "deutsche Meldung",
+		})
+		gperror.RegisterCatalog("de_AT", gperror.Catalog{
+			regionalOnly: "österreichische Meldung",
+		})
+	})
+	AfterEach(func() {
+		gperror.SetLocale(gperror.DEFAULT_LOCALE)
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("NewFromCatalog", func() {
+		It("formats the message for the default locale", func() {
+			err := gperror.NewFromCatalog(diskFull, "/data1")
+			Expect(err.GetCode()).To(Equal(diskFull))
+			Expect(err.Error()).To(Equal("ERROR[1001] disk /data1 is full"))
+		})
+		It("uses the message for the current locale", func() {
+			gperror.SetLocale("de")
+			Expect(gperror.NewFromCatalog(diskFull, "/data1")).To(MatchError("ERROR[1001] Festplatte /data1 ist voll"))
+		})
+		It("falls back to the language and then the default locale", func() {
+			gperror.SetLocale("de_AT")
+			Expect(gperror.NewFromCatalog(regionalOnly)).To(MatchError("ERROR[1005] österreichische Meldung"))
+			Expect(gperror.NewFromCatalog(languageOnly)).To(MatchError("ERROR[1006] deutsche Meldung"))
+			Expect(gperror.NewFromCatalog(untranslated, 42)).To(MatchError("ERROR[1003] only in English: 42"))
+		})
+		It("keeps the arguments for unregistered codes", func() {
+			Expect(gperror.NewFromCatalog(unregistered, "sdw1", 5)).To(MatchError("ERROR[1999] no message registered for this error code sdw1 5"))
+			Expect(gperror.NewFromCatalog(unregistered)).To(MatchError("ERROR[1999] no message registered for this error code"))
+		})
+	})
+
+	Describe("RegisterCatalog", func() {
+		It("overrides previously registered messages for the same code", func() {
+			gperror.RegisterCatalog(gperror.DEFAULT_LOCALE, gperror.Catalog{overridden: "product-specific message"})
+
+			message, ok := gperror.Message(overridden)
+
+			Expect(ok).To(BeTrue())
+			Expect(message).To(Equal("product-specific message"))
+			Expect(gperror.NewFromCatalog(hostDown, "sdw2")).To(MatchError("ERROR[1002] host sdw2 is unreachable"))
+		})
+	})
+
+	Describe("LocaleFromEnvironment", func() {
+		var env map[string]string
+		BeforeEach(func() {
+			env = map[string]string{}
+			operating.System.Getenv = func(key string) string { return env[key] }
+		})
+		It("uses LC_ALL, then LC_MESSAGES, then LANG", func() {
+			env["LANG"] = "fr_FR.UTF-8"
+			Expect(gperror.LocaleFromEnvironment()).To(Equal("fr_FR"))
+			env["LC_MESSAGES"] = "de_DE.ISO-8859-1@euro"
+			Expect(gperror.LocaleFromEnvironment()).To(Equal("de_DE"))
+			env["LC_ALL"] = "ja_JP.eucJP"
+			Expect(gperror.LocaleFromEnvironment()).To(Equal("ja_JP"))
+		})
+		It("returns the default locale for C, POSIX, or an unset locale", func() {
+			Expect(gperror.LocaleFromEnvironment()).To(Equal(gperror.DEFAULT_LOCALE))
+			env["LANG"] = "C.UTF-8"
+			Expect(gperror.LocaleFromEnvironment()).To(Equal(gperror.DEFAULT_LOCALE))
+			env["LANG"] = "POSIX"
+			Expect(gperror.LocaleFromEnvironment()).To(Equal(gperror.DEFAULT_LOCALE))
+		})
+	})
+})
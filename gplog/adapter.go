@@ -0,0 +1,158 @@
+package gplog
+
+/*
+ * This file contains structs and functions related to routing the logs of
+ * third-party libraries through gplog, using the logr interface.  The adapter
+ * for the standard library's log/slog package is in slog.go.
+ */
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+/*
+ * Messages from other libraries are logged with the same prefixes, files, and
+ * verbosity settings as gplog's own messages, but errors they log do not set
+ * the error code, as a library logging an error does not necessarily mean
+ * that the utility failed.
+ */
+func logFromAdapter(verbosity int, isWarning bool, message string) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if logger == nil {
+		return
+	}
+	switch {
+	case isWarning:
+		writeToSinks(LOGERROR, "WARNING", message)
+		_ = logger.logFile.Output(1, GetLogPrefix("WARNING")+message)
+		_ = logger.logStdout.Output(1, colorizeStdout(YELLOW, GetShellLogPrefix("WARNING")+message))
+	case verbosity == LOGERROR:
+		writeToSinks(LOGERROR, "ERROR", message)
+		_ = logger.logFile.Output(1, GetLogPrefix("ERROR")+message)
+		_ = logger.logStderr.Output(1, Colorize(RED, GetShellLogPrefix("ERROR")+message))
+	default:
+		level := getVerbosityString(verbosity)
+		writeToSinks(verbosity, level, message)
+		if logger.fileVerbosity >= verbosity {
+			_ = logger.logFile.Output(1, GetLogPrefix(level)+message)
+		}
+		if logger.shellVerbosity >= verbosity {
+			_ = logger.logStdout.Output(1, GetShellLogPrefix(level)+message)
+		}
+	}
+}
+
+func adapterLevelEnabled(verbosity int) bool {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if logger == nil {
+		return false
+	}
+	return logger.fileVerbosity >= verbosity || logger.shellVerbosity >= verbosity
+}
+
+// Values are quoted only if needed to keep each key=value pair unambiguous
+func formatAdapterValue(value interface{}) string {
+	var str string
+	switch v := value.(type) {
+	case string:
+		str = v
+	case error:
+		str = v.Error()
+	case fmt.Stringer:
+		str = v.String()
+	default:
+		str = fmt.Sprintf("%+v", v)
+	}
+	if str == "" || strings.ContainsAny(str, " \t\n\"=") {
+		return strconv.Quote(str)
+	}
+	return str
+}
+
+func appendKeyValues(message string, keysAndValues []interface{}) string {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprintf("%v", keysAndValues[i])
+		if i+1 == len(keysAndValues) {
+			message += fmt.Sprintf(" %s=%s", key, formatAdapterValue("<missing value>"))
+			break
+		}
+		message += fmt.Sprintf(" %s=%s", key, formatAdapterValue(keysAndValues[i+1]))
+	}
+	return message
+}
+
+/*
+ * A logrSink implements logr.LogSink.  logr's V-levels map onto gplog's
+ * verbosity levels: V(0) is INFO, V(1) is VERBOSE, and V(2) and above are
+ * DEBUG.  Names added with WithName are joined with "/" and prepended to each
+ * message, and key/value pairs are appended to it as key=value.
+ */
+type logrSink struct {
+	name          string
+	keysAndValues []interface{}
+}
+
+/*
+ * NewLogrSink returns a logr.LogSink that logs through gplog, for use with
+ * logr.New by libraries that accept a logr.Logger.  gplog must be initialized
+ * before anything is logged; until then, messages are discarded.
+ */
+func NewLogrSink() logr.LogSink {
+	return &logrSink{}
+}
+
+func logrVerbosity(level int) int {
+	switch {
+	case level <= 0:
+		return LOGINFO
+	case level == 1:
+		return LOGVERBOSE
+	}
+	return LOGDEBUG
+}
+
+func (sink *logrSink) Init(info logr.RuntimeInfo) {}
+
+func (sink *logrSink) Enabled(level int) bool {
+	return adapterLevelEnabled(logrVerbosity(level))
+}
+
+func (sink *logrSink) format(msg string, keysAndValues []interface{}) string {
+	if sink.name != "" {
+		msg = sink.name + ": " + msg
+	}
+	msg = appendKeyValues(msg, sink.keysAndValues)
+	return appendKeyValues(msg, keysAndValues)
+}
+
+func (sink *logrSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	logFromAdapter(logrVerbosity(level), false, sink.format(msg, keysAndValues))
+}
+
+func (sink *logrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	if err != nil {
+		keysAndValues = append([]interface{}{"error", err}, keysAndValues...)
+	}
+	logFromAdapter(LOGERROR, false, sink.format(msg, keysAndValues))
+}
+
+func (sink *logrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	newKeysAndValues := make([]interface{}, 0, len(sink.keysAndValues)+len(keysAndValues))
+	newKeysAndValues = append(newKeysAndValues, sink.keysAndValues...)
+	newKeysAndValues = append(newKeysAndValues, keysAndValues...)
+	return &logrSink{name: sink.name, keysAndValues: newKeysAndValues}
+}
+
+func (sink *logrSink) WithName(name string) logr.LogSink {
+	newName := name
+	if sink.name != "" {
+		newName = sink.name + "/" + name
+	}
+	return &logrSink{name: newName, keysAndValues: sink.keysAndValues}
+}
@@ -0,0 +1,53 @@
+package gplog_test
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("gplog/adapter tests", func() {
+	var (
+		capture *testhelper.LogCapture
+		logger  logr.Logger
+	)
+	BeforeEach(func() {
+		capture = testhelper.SetupTestLogCapture()
+		gplog.SetErrorCode(0)
+		logger = logr.New(gplog.NewLogrSink())
+	})
+	AfterEach(func() {
+		gplog.SetErrorCode(0)
+	})
+	Describe("NewLogrSink", func() {
+		It("logs messages with names and key/value pairs", func() {
+			logger.WithName("client").WithName("watch").WithValues("namespace", "default").Info("Starting watch", "resource", "pods", "selector", "app=gpdb")
+
+			Expect(capture).To(testhelper.HaveLoggedInfo(`client/watch: Starting watch namespace=default resource=pods selector="app=gpdb"`))
+			Expect(capture.Stdout).To(gbytes.Say(`client/watch: Starting watch`))
+		})
+		It("maps V-levels onto gplog verbosity levels", func() {
+			gplog.SetVerbosity(gplog.LOGINFO)
+			gplog.SetLogFileVerbosity(gplog.LOGVERBOSE)
+
+			logger.V(1).Info("verbose message")
+			logger.V(2).Info("debug message")
+
+			Expect(capture).To(testhelper.HaveLoggedDebug("verbose message"))
+			Expect(capture).ToNot(testhelper.HaveLoggedDebug("debug message"))
+			Expect(logger.V(1).Enabled()).To(BeTrue())
+			Expect(logger.V(2).Enabled()).To(BeFalse())
+		})
+		It("logs errors without changing the error code", func() {
+			logger.Error(errors.New("connection reset"), "Request failed", "attempt", 3)
+
+			Expect(capture).To(testhelper.HaveLoggedError(`Request failed error="connection reset" attempt=3`))
+			Expect(capture.Stderr).To(gbytes.Say("Request failed"))
+			Expect(gplog.GetErrorCode()).To(Equal(0))
+		})
+	})
+})
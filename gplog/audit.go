@@ -0,0 +1,191 @@
+package gplog
+
+/*
+ * This file contains structs and functions related to writing an audit trail
+ * of security-sensitive actions, separate from the regular log file.
+ */
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Each AuditRecord is written to the audit log as a single line of JSON.  Hash
+ * is the SHA-256 of the previous record's hash followed by the JSON encoding of
+ * this record with an empty Hash, so that modifying, removing, or reordering
+ * any record breaks the chain for every record after it.  The first record in
+ * a file has an empty PrevHash.
+ */
+type AuditRecord struct {
+	Timestamp string                 `json:"timestamp"`
+	Program   string                 `json:"program"`
+	User      string                 `json:"user"`
+	Pid       int                    `json:"pid"`
+	Event     string                 `json:"event"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+	PrevHash  string                 `json:"prev_hash"`
+	Hash      string                 `json:"hash"`
+}
+
+type auditLogger struct {
+	file     io.WriteCloser
+	fileName string
+	program  string
+	user     string
+	lastHash string
+}
+
+var (
+	auditLog   *auditLogger
+	auditMutex sync.Mutex
+)
+
+func hashAuditRecord(record AuditRecord) (string, error) {
+	record.Hash = ""
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(record.PrevHash), encoded...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+/*
+ * InitializeAuditLogging enables Audit, appending records to
+ * <auditdir>/<program>_audit.log; as with InitializeLogging, an empty auditdir
+ * means ~/gpAdminLogs.  The file name does not include the date so that a
+ * single chain covers every run of the utility, and if the file already exists
+ * the chain is continued from its last record.  Calling it again while audit
+ * logging is enabled has no effect.
+ */
+func InitializeAuditLogging(program string, auditdir string) {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	if auditLog != nil {
+		return
+	}
+	currentUser, _ := operating.System.CurrentUser()
+	if auditdir == "" {
+		auditdir = fmt.Sprintf("%s/gpAdminLogs", currentUser.HomeDir)
+	}
+	createLogDirectory(auditdir)
+	fileName := fmt.Sprintf("%s/%s_audit.log", auditdir, program)
+
+	lastHash := ""
+	contents, err := operating.System.ReadFile(fileName)
+	if err != nil && !operating.System.IsNotExist(err) {
+		abort(errors.Errorf("Cannot read audit log %s: %v", fileName, err))
+	}
+	if lines := bytes.Split(bytes.TrimSpace(contents), []byte("\n")); len(lines[len(lines)-1]) > 0 {
+		var lastRecord AuditRecord
+		if err := json.Unmarshal(lines[len(lines)-1], &lastRecord); err != nil {
+			abort(errors.Errorf("Cannot continue audit log %s: last record is not valid: %v", fileName, err))
+		}
+		lastHash = lastRecord.Hash
+	}
+
+	auditLog = &auditLogger{
+		file:     openLogFile(fileName),
+		fileName: fileName,
+		program:  program,
+		user:     currentUser.Username,
+		lastHash: lastHash,
+	}
+}
+
+// CloseAuditLogging closes the audit log, after which Audit has no effect until audit logging is initialized again
+func CloseAuditLogging() {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	if auditLog == nil {
+		return
+	}
+	_ = auditLog.file.Close()
+	auditLog = nil
+}
+
+func GetAuditLogFilePath() string {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	if auditLog == nil {
+		return ""
+	}
+	return auditLog.fileName
+}
+
+/*
+ * Audit records that event occurred, with any details in fields, which must be
+ * encodable as JSON.  Secrets such as passwords should never be passed in
+ * fields; record that a password was changed, not what it was changed to.
+ * Audit does nothing unless InitializeAuditLogging has been called, so that
+ * libraries can call it unconditionally.  Failing to write an audit record is
+ * treated as fatal, as continuing would leave a gap in the trail.
+ */
+func Audit(event string, fields map[string]interface{}) {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	if auditLog == nil {
+		return
+	}
+	record := AuditRecord{
+		Timestamp: operating.System.Now().Format(time.RFC3339Nano),
+		Program:   auditLog.program,
+		User:      auditLog.user,
+		Pid:       operating.System.Getpid(),
+		Event:     event,
+		Fields:    fields,
+		PrevHash:  auditLog.lastHash,
+	}
+	hash, err := hashAuditRecord(record)
+	if err != nil {
+		abort(errors.Wrapf(err, "Cannot write audit record for event %s", event))
+	}
+	record.Hash = hash
+	line, _ := json.Marshal(record)
+	if _, err = auditLog.file.Write(append(line, '\n')); err != nil {
+		abort(errors.Wrapf(err, "Cannot write audit record for event %s", event))
+	}
+	auditLog.lastHash = hash
+}
+
+/*
+ * VerifyAuditLog reads an audit log and checks that every record's hash is
+ * correct and chains to the record before it, returning the number of valid
+ * records and an error identifying the first record that does not.
+ */
+func VerifyAuditLog(reader io.Reader) (int, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	prevHash := ""
+	numRecords := 0
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return numRecords, errors.Errorf("Audit record %d is not valid: %v", numRecords+1, err)
+		}
+		if record.PrevHash != prevHash {
+			return numRecords, errors.Errorf("Audit record %d does not follow the previous record", numRecords+1)
+		}
+		hash, err := hashAuditRecord(record)
+		if err != nil || hash != record.Hash {
+			return numRecords, errors.Errorf("Audit record %d has been modified", numRecords+1)
+		}
+		prevHash = record.Hash
+		numRecords++
+	}
+	return numRecords, scanner.Err()
+}
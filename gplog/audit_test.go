@@ -0,0 +1,113 @@
+package gplog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gplog/audit tests", func() {
+	var (
+		auditDir    string
+		readRecords = func() []gplog.AuditRecord {
+			contents, err := os.ReadFile(gplog.GetAuditLogFilePath())
+			Expect(err).ToNot(HaveOccurred())
+			records := make([]gplog.AuditRecord, 0)
+			for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+				var record gplog.AuditRecord
+				Expect(json.Unmarshal([]byte(line), &record)).To(Succeed())
+				records = append(records, record)
+			}
+			return records
+		}
+	)
+	BeforeEach(func() {
+		var err error
+		auditDir, err = os.MkdirTemp("", "gplog_audit")
+		Expect(err).ToNot(HaveOccurred())
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "gpadmin", HomeDir: auditDir}, nil }
+		operating.System.Getpid = func() int { return 1234 }
+		operating.System.Now = func() time.Time { return time.Date(2024, time.January, 1, 1, 1, 1, 0, time.UTC) }
+	})
+	AfterEach(func() {
+		gplog.CloseAuditLogging()
+		operating.System = operating.InitializeSystemFunctions()
+		_ = os.RemoveAll(auditDir)
+	})
+	Describe("Audit", func() {
+		It("does nothing if audit logging is not initialized", func() {
+			gplog.Audit("password_changed", nil)
+			Expect(gplog.GetAuditLogFilePath()).To(Equal(""))
+		})
+		It("writes hash-chained records to the audit log", func() {
+			gplog.InitializeAuditLogging("gpssh", "")
+			Expect(gplog.GetAuditLogFilePath()).To(Equal(filepath.Join(auditDir, "gpAdminLogs", "gpssh_audit.log")))
+
+			gplog.Audit("password_changed", map[string]interface{}{"role": "gpadmin"})
+			gplog.Audit("ddl_executed", map[string]interface{}{"statement": "DROP TABLE foo", "rows": 0})
+
+			records := readRecords()
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Timestamp).To(Equal("2024-01-01T01:01:01Z"))
+			Expect(records[0].Program).To(Equal("gpssh"))
+			Expect(records[0].User).To(Equal("gpadmin"))
+			Expect(records[0].Pid).To(Equal(1234))
+			Expect(records[0].Event).To(Equal("password_changed"))
+			Expect(records[0].Fields).To(Equal(map[string]interface{}{"role": "gpadmin"}))
+			Expect(records[0].PrevHash).To(Equal(""))
+			Expect(records[0].Hash).To(HaveLen(64))
+			Expect(records[1].PrevHash).To(Equal(records[0].Hash))
+		})
+		It("continues the chain from an existing audit log", func() {
+			gplog.InitializeAuditLogging("gpssh", auditDir)
+			gplog.Audit("first", nil)
+			gplog.CloseAuditLogging()
+
+			gplog.InitializeAuditLogging("gpssh", auditDir)
+			gplog.Audit("second", nil)
+
+			records := readRecords()
+			Expect(records).To(HaveLen(2))
+			Expect(records[1].PrevHash).To(Equal(records[0].Hash))
+		})
+	})
+	Describe("VerifyAuditLog", func() {
+		var contents []byte
+		BeforeEach(func() {
+			gplog.InitializeAuditLogging("gpssh", auditDir)
+			gplog.Audit("first", map[string]interface{}{"count": 1})
+			gplog.Audit("second", nil)
+			gplog.Audit("third", nil)
+			var err error
+			contents, err = os.ReadFile(gplog.GetAuditLogFilePath())
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("accepts an unmodified audit log", func() {
+			numRecords, err := gplog.VerifyAuditLog(bytes.NewReader(contents))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(numRecords).To(Equal(3))
+		})
+		It("detects a modified record", func() {
+			modified := strings.Replace(string(contents), `"count":1`, `"count":2`, 1)
+			numRecords, err := gplog.VerifyAuditLog(strings.NewReader(modified))
+			Expect(err).To(MatchError("Audit record 1 has been modified"))
+			Expect(numRecords).To(Equal(0))
+		})
+		It("detects a removed record", func() {
+			lines := strings.Split(string(contents), "\n")
+			removed := strings.Join(append(lines[:1], lines[2:]...), "\n")
+			numRecords, err := gplog.VerifyAuditLog(strings.NewReader(removed))
+			Expect(err).To(MatchError("Audit record 2 does not follow the previous record"))
+			Expect(numRecords).To(Equal(1))
+		})
+	})
+})
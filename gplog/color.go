@@ -0,0 +1,67 @@
+package gplog
+
+/*
+ * This file contains structs and functions related to deciding whether to
+ * colorize console output, depending on where the output is going.
+ */
+
+import (
+	"io"
+	"os"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+type ColorMode int
+
+const (
+	COLOR_NEVER ColorMode = iota
+	COLOR_ALWAYS
+	COLOR_AUTO
+)
+
+/*
+ * SetColorMode sets whether to colorize the output to the shell console, as
+ * SetColorize does, but also allows COLOR_AUTO, which colorizes stdout and
+ * stderr separately, only if each one is a terminal that can display colors,
+ * so that e.g. output redirected to a file does not contain escape sequences.
+ * COLOR_AUTO also disables colorization if the NO_COLOR environment variable
+ * is set to a non-empty value, as described at https://no-color.org.
+ *
+ * On Windows, the console's processing of escape sequences is enabled if
+ * necessary; with COLOR_AUTO, output is not colorized if that fails, e.g. on
+ * versions of Windows before Windows 10.
+ *
+ * The mode is applied to the current logger's output when this is called, so
+ * it must be called again after replacing the logger.
+ */
+func SetColorMode(mode ColorMode) {
+	logger.colorMode = mode
+	logger.colorStdout = shouldColorize(mode, logger.logStdout.Writer())
+	logger.colorStderr = shouldColorize(mode, logger.logStderr.Writer())
+}
+
+func GetColorMode() ColorMode {
+	if logger == nil {
+		return COLOR_NEVER
+	}
+	return logger.colorMode
+}
+
+func shouldColorize(mode ColorMode, writer io.Writer) bool {
+	switch mode {
+	case COLOR_ALWAYS:
+		if file, ok := writer.(*os.File); ok {
+			_ = enableVirtualTerminal(file)
+		}
+		return true
+	case COLOR_AUTO:
+		if operating.System.Getenv("NO_COLOR") != "" {
+			return false
+		}
+		file, ok := writer.(*os.File)
+		return ok && isTerminal(file) && enableVirtualTerminal(file)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package gplog
+
+import (
+	"os"
+)
+
+// A terminal is a character device; this also matches e.g. /dev/null, where escape sequences are harmless
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Terminals on these platforms process escape sequences without being asked to
+func enableVirtualTerminal(file *os.File) bool {
+	return true
+}
@@ -0,0 +1,89 @@
+package gplog_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gplog/color tests", func() {
+	var files []*os.File
+	useFiles := func(stdout *os.File, stderr *os.File) {
+		files = append(files, stdout, stderr)
+		gplog.SetLogger(gplog.NewLogger(stdout, stderr, stderr, "gbytes.Buffer", gplog.LOGINFO, "testProgram"))
+	}
+	openFile := func(path string) *os.File {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+		Expect(err).ToNot(HaveOccurred())
+		return file
+	}
+	BeforeEach(func() {
+		files = nil
+	})
+	AfterEach(func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+		testhelper.SetupTestLogger()
+	})
+
+	Describe("SetColorMode", func() {
+		It("colorizes output with COLOR_ALWAYS even if it is not a terminal", func() {
+			testhelper.SetupTestLogger()
+
+			gplog.SetColorMode(gplog.COLOR_ALWAYS)
+
+			Expect(gplog.GetColorMode()).To(Equal(gplog.COLOR_ALWAYS))
+			Expect(gplog.GetColorize()).To(BeTrue())
+			Expect(gplog.Colorize(gplog.RED, "error")).To(Equal("\x1b[31merror\x1b[0m"))
+		})
+		It("does not colorize output with COLOR_AUTO if it is not a file", func() {
+			testhelper.SetupTestLogger()
+
+			gplog.SetColorMode(gplog.COLOR_AUTO)
+
+			Expect(gplog.GetColorize()).To(BeFalse())
+			Expect(gplog.Colorize(gplog.RED, "error")).To(Equal("error"))
+		})
+		It("does not colorize output with COLOR_AUTO if it is redirected to a regular file", func() {
+			logPath := filepath.Join(GinkgoT().TempDir(), "output.log")
+			useFiles(openFile(logPath), openFile(logPath))
+
+			gplog.SetColorMode(gplog.COLOR_AUTO)
+
+			Expect(gplog.GetColorize()).To(BeFalse())
+		})
+		It("colorizes each stream with COLOR_AUTO only if it is a character device", func() {
+			logPath := filepath.Join(GinkgoT().TempDir(), "output.log")
+			useFiles(openFile(logPath), openFile(os.DevNull))
+
+			gplog.SetColorMode(gplog.COLOR_AUTO)
+
+			Expect(gplog.GetColorize()).To(BeTrue())
+			Expect(gplog.Colorize(gplog.RED, "error")).To(Equal("\x1b[31merror\x1b[0m"))
+		})
+		It("does not colorize output with COLOR_AUTO if NO_COLOR is set", func() {
+			useFiles(openFile(os.DevNull), openFile(os.DevNull))
+
+			operating.WithEnv(map[string]string{"NO_COLOR": "1"}, func() {
+				gplog.SetColorMode(gplog.COLOR_AUTO)
+			})
+
+			Expect(gplog.GetColorize()).To(BeFalse())
+		})
+		It("is set by SetColorize", func() {
+			testhelper.SetupTestLogger()
+
+			gplog.SetColorize(true)
+			Expect(gplog.GetColorMode()).To(Equal(gplog.COLOR_ALWAYS))
+			gplog.SetColorize(false)
+			Expect(gplog.GetColorMode()).To(Equal(gplog.COLOR_NEVER))
+			Expect(gplog.GetColorize()).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,27 @@
+//go:build windows
+
+package gplog
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Only consoles have a console mode, unlike the NUL device, which is also a character device
+func isTerminal(file *os.File) bool {
+	var mode uint32
+	return windows.GetConsoleMode(windows.Handle(file.Fd()), &mode) == nil
+}
+
+func enableVirtualTerminal(file *os.File) bool {
+	handle := windows.Handle(file.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -0,0 +1,168 @@
+package gplog
+
+/*
+ * This file contains structs and functions related to log domains, which allow
+ * the verbosity of messages from one subsystem to be set independently of the
+ * verbosity of all other messages.
+ */
+
+import (
+	"fmt"
+)
+
+/*
+ * A LogDomain logs messages on behalf of a named subsystem, e.g. "cluster" or
+ * "dbconn".  Its output functions behave like the package-level functions of
+ * the same names, except that Info, Verbose, Debug, and Custom messages are
+ * filtered by the domain's verbosity if one has been set with
+ * SetDomainVerbosity or SetDomainLogFileVerbosity, and by the logger's
+ * verbosity otherwise.  Warn and Error messages are always printed.
+ *
+ * Domains need not be registered; any two LogDomains with the same name share
+ * the same verbosity settings.
+ */
+type LogDomain struct {
+	name string
+}
+
+/*
+ * The verbosities set for a domain, or -1 for either one if it has not been
+ * set and the logger's verbosity should be used.
+ */
+type domainVerbosity struct {
+	shell int
+	file  int
+}
+
+func Domain(name string) LogDomain {
+	return LogDomain{name: name}
+}
+
+func (domain LogDomain) Name() string {
+	return domain.name
+}
+
+/*
+ * Must be called with logMutex held.  Returns the shell and logfile verbosity
+ * that apply to messages logged to the given domain.
+ */
+func getDomainVerbosities(name string) (int, int) {
+	shellVerbosity, fileVerbosity := logger.shellVerbosity, logger.fileVerbosity
+	if verbosity, ok := logger.domainVerbosities[name]; ok {
+		if verbosity.shell != -1 {
+			shellVerbosity = verbosity.shell
+		}
+		if verbosity.file != -1 {
+			fileVerbosity = verbosity.file
+		}
+	}
+	return shellVerbosity, fileVerbosity
+}
+
+func setDomainVerbosity(name string, shellVerbosity *int, fileVerbosity *int) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if logger.domainVerbosities == nil {
+		logger.domainVerbosities = make(map[string]domainVerbosity)
+	}
+	verbosity, ok := logger.domainVerbosities[name]
+	if !ok {
+		verbosity = domainVerbosity{shell: -1, file: -1}
+	}
+	if shellVerbosity != nil {
+		verbosity.shell = *shellVerbosity
+	}
+	if fileVerbosity != nil {
+		verbosity.file = *fileVerbosity
+	}
+	logger.domainVerbosities[name] = verbosity
+}
+
+// SetDomainVerbosity sets the shell verbosity of messages logged to the named domain
+func SetDomainVerbosity(name string, verbosity int) {
+	setDomainVerbosity(name, &verbosity, nil)
+}
+
+// SetDomainLogFileVerbosity sets the logfile verbosity of messages logged to the named domain
+func SetDomainLogFileVerbosity(name string, verbosity int) {
+	setDomainVerbosity(name, nil, &verbosity)
+}
+
+// ResetDomainVerbosity makes the named domain use the logger's verbosities again
+func ResetDomainVerbosity(name string) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	delete(logger.domainVerbosities, name)
+}
+
+// GetDomainVerbosity returns the shell verbosity that applies to the named domain
+func GetDomainVerbosity(name string) int {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	shellVerbosity, _ := getDomainVerbosities(name)
+	return shellVerbosity
+}
+
+// GetDomainLogFileVerbosity returns the logfile verbosity that applies to the named domain
+func GetDomainLogFileVerbosity(name string) int {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	_, fileVerbosity := getDomainVerbosities(name)
+	return fileVerbosity
+}
+
+func (domain LogDomain) output(verbosity int, s string, v ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	shellVerbosity, fileVerbosity := getDomainVerbosities(domain.name)
+	level := getVerbosityString(verbosity)
+	writeToSinks(verbosity, level, fmt.Sprintf(s, v...))
+	if fileVerbosity >= verbosity {
+		message := GetLogPrefix(level) + fmt.Sprintf(s, v...)
+		_ = logger.logFile.Output(1, message)
+	}
+	if shellVerbosity >= verbosity {
+		message := GetShellLogPrefix(level) + fmt.Sprintf(s, v...)
+		_ = logger.logStdout.Output(1, message)
+	}
+}
+
+func (domain LogDomain) Info(s string, v ...interface{}) {
+	domain.output(LOGINFO, s, v...)
+}
+
+func (domain LogDomain) Verbose(s string, v ...interface{}) {
+	domain.output(LOGVERBOSE, s, v...)
+}
+
+func (domain LogDomain) Debug(s string, v ...interface{}) {
+	domain.output(LOGDEBUG, s, v...)
+}
+
+func (domain LogDomain) Warn(s string, v ...interface{}) {
+	Warn(s, v...)
+}
+
+func (domain LogDomain) Error(s string, v ...interface{}) {
+	Error(s, v...)
+}
+
+// Custom behaves like the package-level Custom, using the domain's verbosities
+func (domain LogDomain) Custom(customFileVerbosity int, customShellVerbosity int, s string, v ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	shellVerbosity, fileVerbosity := getDomainVerbosities(domain.name)
+	writeToSinks(customFileVerbosity, getVerbosityString(customFileVerbosity), fmt.Sprintf(s, v...))
+	var message string
+	if fileVerbosity >= customFileVerbosity {
+		message = GetLogPrefix(getVerbosityString(customFileVerbosity)) + fmt.Sprintf(s, v...)
+		_ = logger.logFile.Output(1, message)
+	}
+	if customShellVerbosity == LOGERROR {
+		message = GetShellLogPrefix("ERROR") + fmt.Sprintf(s, v...)
+		_ = logger.logStderr.Output(1, Colorize(RED, message))
+	} else if shellVerbosity >= customShellVerbosity {
+		message = GetShellLogPrefix(getVerbosityString(customShellVerbosity)) + fmt.Sprintf(s, v...)
+		_ = logger.logStdout.Output(1, message)
+	}
+}
@@ -0,0 +1,88 @@
+package gplog_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("gplog/domain tests", func() {
+	var (
+		stdout  *gbytes.Buffer
+		stderr  *gbytes.Buffer
+		logfile *gbytes.Buffer
+	)
+	clusterLog := gplog.Domain("cluster")
+	dbconnLog := gplog.Domain("dbconn")
+	BeforeEach(func() {
+		stdout, stderr, logfile = testhelper.SetupTestLogger()
+	})
+
+	Describe("Domain output functions", func() {
+		It("use the logger's verbosity if the domain's has not been set", func() {
+			clusterLog.Info("cluster info")
+			clusterLog.Verbose("cluster verbose")
+			Expect(stdout).To(gbytes.Say("cluster info"))
+			Expect(stdout).ToNot(gbytes.Say("cluster verbose"))
+			Expect(logfile).To(gbytes.Say("cluster info"))
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-cluster verbose`))
+		})
+		It("use the domain's verbosity if it has been set", func() {
+			gplog.SetDomainVerbosity("cluster", gplog.LOGDEBUG)
+			clusterLog.Debug("cluster debug")
+			dbconnLog.Debug("dbconn debug")
+			Expect(stdout).To(gbytes.Say(`\[DEBUG\]:-cluster debug`))
+			Expect(stdout).ToNot(gbytes.Say("dbconn debug"))
+		})
+		It("can lower a domain's verbosity below the logger's", func() {
+			gplog.SetDomainVerbosity("dbconn", gplog.LOGERROR)
+			gplog.SetDomainLogFileVerbosity("dbconn", gplog.LOGINFO)
+			dbconnLog.Info("dbconn info")
+			dbconnLog.Verbose("dbconn verbose")
+			clusterLog.Info("cluster info")
+			Expect(stdout).ToNot(gbytes.Say("dbconn info"))
+			Expect(stdout).To(gbytes.Say("cluster info"))
+			Expect(logfile).To(gbytes.Say("dbconn info"))
+			Expect(logfile).ToNot(gbytes.Say("dbconn verbose"))
+		})
+		It("always print warnings and errors", func() {
+			gplog.SetDomainVerbosity("dbconn", gplog.LOGERROR)
+			dbconnLog.Warn("dbconn warning")
+			dbconnLog.Error("dbconn error")
+			Expect(stdout).To(gbytes.Say(`\[WARNING\]:-dbconn warning`))
+			Expect(stderr).To(gbytes.Say(`\[ERROR\]:-dbconn error`))
+			gplog.SetErrorCode(0)
+		})
+		It("filter Custom messages by the domain's verbosity", func() {
+			gplog.SetDomainVerbosity("cluster", gplog.LOGVERBOSE)
+			clusterLog.Custom(gplog.LOGERROR, gplog.LOGVERBOSE, "cluster custom")
+			Expect(stdout).To(gbytes.Say(`\[DEBUG\]:-cluster custom`))
+			Expect(logfile).To(gbytes.Say(`\[ERROR\]:-cluster custom`))
+		})
+	})
+	Describe("Domain verbosity settings", func() {
+		It("returns the logger's verbosities until the domain's are set", func() {
+			Expect(gplog.GetDomainVerbosity("cluster")).To(Equal(gplog.LOGINFO))
+			Expect(gplog.GetDomainLogFileVerbosity("cluster")).To(Equal(gplog.LOGDEBUG))
+			gplog.SetDomainVerbosity("cluster", gplog.LOGVERBOSE)
+			Expect(gplog.GetDomainVerbosity("cluster")).To(Equal(gplog.LOGVERBOSE))
+			Expect(gplog.GetDomainLogFileVerbosity("cluster")).To(Equal(gplog.LOGDEBUG))
+			gplog.SetVerbosity(gplog.LOGERROR)
+			Expect(gplog.GetDomainVerbosity("cluster")).To(Equal(gplog.LOGVERBOSE))
+		})
+		It("reverts to the logger's verbosities when reset", func() {
+			gplog.SetDomainVerbosity("cluster", gplog.LOGDEBUG)
+			gplog.SetDomainLogFileVerbosity("cluster", gplog.LOGERROR)
+			gplog.ResetDomainVerbosity("cluster")
+			Expect(gplog.GetDomainVerbosity("cluster")).To(Equal(gplog.LOGINFO))
+			Expect(gplog.GetDomainLogFileVerbosity("cluster")).To(Equal(gplog.LOGDEBUG))
+		})
+		It("does not carry over to a new logger", func() {
+			gplog.SetDomainVerbosity("cluster", gplog.LOGDEBUG)
+			testhelper.SetupTestLogger()
+			Expect(gplog.GetDomainVerbosity("cluster")).To(Equal(gplog.LOGINFO))
+		})
+	})
+})
@@ -0,0 +1,229 @@
+package gplog
+
+/*
+ * This file contains structs and functions related to recording a named reason
+ * for a utility's exit, in addition to its numeric error code.
+ */
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * An ExitReason is a short, stable, machine-readable name for why a utility
+ * exited, e.g. "disk_full", so that wrappers and automation can act on the
+ * precise failure without parsing log messages.  Each reason is registered
+ * with the error code to exit with when it is set.
+ */
+type ExitReason string
+
+const (
+	EXIT_SUCCESS               ExitReason = "success"
+	EXIT_COMPLETED_WITH_ERRORS ExitReason = "completed_with_errors"
+	EXIT_FATAL                 ExitReason = "fatal"
+)
+
+/*
+ * These reasons are not registered by default, as their error codes vary
+ * between utilities; see RegisterErrorCategory.
+ */
+const (
+	EXIT_CONNECTION_FAILURE ExitReason = "connection_failure"
+	EXIT_PERMISSION_DENIED  ExitReason = "permission_denied"
+	EXIT_USER_CANCEL        ExitReason = "user_cancel"
+	EXIT_PARTIAL_SUCCESS    ExitReason = "partial_success"
+)
+
+type errorCategory struct {
+	reason  ExitReason
+	matches func(err error) bool
+}
+
+var (
+	exitReasonCodes = map[ExitReason]int{
+		EXIT_SUCCESS:               0,
+		EXIT_COMPLETED_WITH_ERRORS: 1,
+		EXIT_FATAL:                 2,
+	}
+	// Empty unless SetExitReason has been called, in which case Error and Fatal do not change errorCode
+	exitReason        ExitReason
+	exitReasonDetails string
+	// Checked in registration order by Fatal to map an error to an exit reason
+	errorCategories []errorCategory
+	/*
+	 * The matchers used for the predefined categories when none is passed to
+	 * RegisterErrorCategory.  Partial success is not an error, so it has no
+	 * matcher and must be set with SetExitReason.
+	 */
+	defaultErrorMatchers = map[ExitReason]func(err error) bool{
+		EXIT_CONNECTION_FAILURE: func(err error) bool {
+			var netErr net.Error
+			return errors.As(err, &netErr) || errors.Is(err, syscall.ECONNREFUSED)
+		},
+		EXIT_PERMISSION_DENIED: func(err error) bool {
+			return errors.Is(err, os.ErrPermission)
+		},
+		EXIT_USER_CANCEL: func(err error) bool {
+			return errors.Is(err, context.Canceled)
+		},
+	}
+)
+
+/*
+ * RegisterExitReason registers reason so that it can be passed to
+ * SetExitReason.  Utilities should register their reasons at startup, and use
+ * error codes above 2 for them so that the default codes keep their meanings.
+ */
+func RegisterExitReason(reason ExitReason, code int) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	exitReasonCodes[reason] = code
+}
+
+/*
+ * RegisterErrorCategory registers reason with the given error code, as
+ * RegisterExitReason does, and also maps errors to it: when Fatal or
+ * FatalOnError is called with an error for which matches returns true, the
+ * exit reason is set to reason, with the error as its details, unless a reason
+ * has already been set.  If matches is nil, the default matcher for the
+ * predefined reasons is used, e.g. errors.Is(err, os.ErrPermission) for
+ * EXIT_PERMISSION_DENIED; other reasons with no matcher are only registered.
+ * Categories are checked in the order they were registered.
+ */
+func RegisterErrorCategory(reason ExitReason, code int, matches func(err error) bool) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	exitReasonCodes[reason] = code
+	if matches == nil {
+		matches = defaultErrorMatchers[reason]
+	}
+	if matches != nil {
+		errorCategories = append(errorCategories, errorCategory{reason: reason, matches: matches})
+	}
+}
+
+// ResetErrorCategories removes the mappings added by RegisterErrorCategory, leaving the reasons registered
+func ResetErrorCategories() {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	errorCategories = nil
+}
+
+/*
+ * Sets the exit reason and error code for a fatal error, using the first
+ * category that matches the error or, if none does, the default error code.
+ */
+func setFatalErrorCode(err error) {
+	if exitReason != "" {
+		return
+	}
+	if err != nil {
+		for _, category := range errorCategories {
+			if category.matches(err) {
+				exitReason = category.reason
+				exitReasonDetails = err.Error()
+				errorCode = exitReasonCodes[category.reason]
+				return
+			}
+		}
+	}
+	errorCode = 2
+}
+
+/*
+ * Returns the reason registered with the given error code, or "" if there is
+ * none.  If several reasons share the code, the first in alphabetical order is
+ * returned, so that the result does not depend on map ordering.
+ */
+func reasonForCode(code int) ExitReason {
+	reasons := make([]string, 0)
+	for reason, reasonCode := range exitReasonCodes {
+		if reasonCode == code {
+			reasons = append(reasons, string(reason))
+		}
+	}
+	if len(reasons) == 0 {
+		return ""
+	}
+	sort.Strings(reasons)
+	return ExitReason(reasons[0])
+}
+
+/*
+ * SetExitReason records why the utility is going to exit and sets the error
+ * code to the one registered for reason.  Once a reason is set, subsequent
+ * calls to Error and Fatal log as usual but leave the reason and error code
+ * unchanged, so a reason should be set just before the Fatal call it explains.
+ * Setting an unregistered reason is a programming error and panics.
+ */
+func SetExitReason(reason ExitReason, details string) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	code, ok := exitReasonCodes[reason]
+	if !ok {
+		abort(errors.Errorf("Exit reason %s has not been registered", reason))
+	}
+	exitReason = reason
+	exitReasonDetails = details
+	errorCode = code
+}
+
+/*
+ * GetExitReason returns the reason and details set with SetExitReason or, if
+ * none was set, the default reason for the current error code.
+ */
+func GetExitReason() (ExitReason, string) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	return currentExitReason()
+}
+
+func currentExitReason() (ExitReason, string) {
+	if exitReason != "" {
+		return exitReason, exitReasonDetails
+	}
+	switch errorCode {
+	case 0:
+		return EXIT_SUCCESS, ""
+	case 1:
+		return EXIT_COMPLETED_WITH_ERRORS, ""
+	default:
+		return EXIT_FATAL, ""
+	}
+}
+
+// Set the error code for Error and Fatal unless a specific exit reason has been set
+func setDefaultErrorCode(code int) {
+	if exitReason == "" {
+		errorCode = code
+	}
+}
+
+func exitSummary() string {
+	reason, details := currentExitReason()
+	return fmt.Sprintf("exit_reason=%s exit_code=%d details=%q", reason, errorCode, details)
+}
+
+/*
+ * LogExitSummary writes a single line with the exit reason, error code, and
+ * details to the log file, in a key=value format that is easy to parse, e.g.
+ *   exit_reason=disk_full exit_code=3 details="/data1 has 0 bytes free"
+ * Utilities should call this just before exiting; FatalWithoutPanic calls it
+ * automatically.
+ */
+func LogExitSummary() {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	writeExitSummary()
+}
+
+func writeExitSummary() {
+	_ = logger.logFile.Output(1, GetLogPrefix("INFO")+exitSummary())
+}
@@ -0,0 +1,151 @@
+package gplog_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("gplog/exitreason tests", func() {
+	const diskFull = gplog.ExitReason("disk_full")
+	var capture *testhelper.LogCapture
+	BeforeEach(func() {
+		capture = testhelper.SetupTestLogCapture()
+		gplog.RegisterExitReason(diskFull, 3)
+		gplog.SetErrorCode(0)
+	})
+	AfterEach(func() {
+		gplog.SetErrorCode(0)
+	})
+	Describe("GetExitReason", func() {
+		It("returns the default reason for the error code if no reason was set", func() {
+			Expect(gplog.GetExitReason()).To(Equal(gplog.EXIT_SUCCESS))
+			gplog.Error("error")
+			reason, details := gplog.GetExitReason()
+			Expect(reason).To(Equal(gplog.EXIT_COMPLETED_WITH_ERRORS))
+			Expect(details).To(Equal(""))
+			gplog.SetErrorCode(2)
+			Expect(gplog.GetExitReason()).To(Equal(gplog.EXIT_FATAL))
+		})
+	})
+	Describe("SetExitReason", func() {
+		It("sets the reason, details, and registered error code", func() {
+			gplog.SetExitReason(diskFull, "/data1 has 0 bytes free")
+
+			reason, details := gplog.GetExitReason()
+			Expect(reason).To(Equal(diskFull))
+			Expect(details).To(Equal("/data1 has 0 bytes free"))
+			Expect(gplog.GetErrorCode()).To(Equal(3))
+		})
+		It("is not overridden by later errors", func() {
+			gplog.SetExitReason(diskFull, "")
+			gplog.Error("error")
+			defer func() {
+				_ = recover()
+				Expect(gplog.GetErrorCode()).To(Equal(3))
+				Expect(gplog.GetExitReason()).To(Equal(diskFull))
+			}()
+			gplog.Fatal(nil, "fatal")
+		})
+		It("is cleared by SetErrorCode", func() {
+			gplog.SetExitReason(diskFull, "")
+			gplog.SetErrorCode(1)
+			Expect(gplog.GetExitReason()).To(Equal(gplog.EXIT_COMPLETED_WITH_ERRORS))
+		})
+		It("panics for an unregistered reason", func() {
+			defer testhelper.ShouldPanicWithMessage("Exit reason unknown_reason has not been registered")
+			gplog.SetExitReason("unknown_reason", "")
+		})
+	})
+	Describe("RegisterErrorCategory", func() {
+		fatalErrorCode := func(err error) int {
+			defer func() { _ = recover() }()
+			gplog.Fatal(err, "")
+			return -1
+		}
+		AfterEach(func() {
+			gplog.ResetErrorCategories()
+		})
+		It("maps errors matching a predefined category to its error code", func() {
+			gplog.RegisterErrorCategory(gplog.EXIT_PERMISSION_DENIED, 4, nil)
+			gplog.RegisterErrorCategory(gplog.EXIT_USER_CANCEL, 5, nil)
+
+			fatalErrorCode(errors.Wrap(context.Canceled, "interrupted"))
+
+			Expect(gplog.GetErrorCode()).To(Equal(5))
+			reason, details := gplog.GetExitReason()
+			Expect(reason).To(Equal(gplog.EXIT_USER_CANCEL))
+			Expect(details).To(Equal("interrupted: context canceled"))
+		})
+		It("maps errors with a custom matcher", func() {
+			gplog.RegisterErrorCategory(diskFull, 3, func(err error) bool {
+				return strings.Contains(err.Error(), "No space left on device")
+			})
+
+			fatalErrorCode(fmt.Errorf("write /data1/foo: No space left on device"))
+
+			Expect(gplog.GetErrorCode()).To(Equal(3))
+			reason, _ := gplog.GetExitReason()
+			Expect(reason).To(Equal(diskFull))
+		})
+		It("uses the default error code for errors matching no category", func() {
+			gplog.RegisterErrorCategory(gplog.EXIT_PERMISSION_DENIED, 4, nil)
+
+			fatalErrorCode(fmt.Errorf("syntax error"))
+
+			Expect(gplog.GetErrorCode()).To(Equal(2))
+			Expect(gplog.GetExitReason()).To(Equal(gplog.EXIT_FATAL))
+		})
+		It("does not override a reason that is already set", func() {
+			gplog.RegisterErrorCategory(gplog.EXIT_PERMISSION_DENIED, 4, nil)
+			gplog.SetExitReason(diskFull, "")
+
+			fatalErrorCode(os.ErrPermission)
+
+			Expect(gplog.GetErrorCode()).To(Equal(3))
+		})
+	})
+	Describe("FatalWithCode", func() {
+		It("sets the error code and the reason registered with it", func() {
+			defer func() {
+				Expect(recover()).To(ContainSubstring("permission denied: cannot write"))
+				Expect(gplog.GetErrorCode()).To(Equal(3))
+				reason, details := gplog.GetExitReason()
+				Expect(reason).To(Equal(diskFull))
+				Expect(details).To(Equal("permission denied"))
+			}()
+			gplog.FatalWithCode(3, os.ErrPermission, "cannot write")
+		})
+		It("sets an error code with no registered reason", func() {
+			defer func() {
+				_ = recover()
+				Expect(gplog.GetErrorCode()).To(Equal(42))
+				Expect(gplog.GetExitReason()).To(Equal(gplog.EXIT_FATAL))
+			}()
+			gplog.FatalWithCode(42, nil, "cannot continue")
+		})
+	})
+	Describe("LogExitSummary", func() {
+		It("logs the reason, error code, and details", func() {
+			gplog.SetExitReason(diskFull, `/data1 has "0" bytes free`)
+			gplog.LogExitSummary()
+			Expect(capture).To(testhelper.HaveLoggedInfo(`exit_reason=disk_full exit_code=3 details="/data1 has \"0\" bytes free"`))
+			Expect(capture.Stdout.Contents()).To(BeEmpty())
+		})
+		It("is logged by FatalWithoutPanic", func() {
+			gplog.SetExitFunc(func() {})
+			gplog.FatalWithoutPanic("cannot continue")
+			Expect(capture.Entries()).To(Equal([]testhelper.LogEntry{
+				{Level: "CRITICAL", Message: "cannot continue"},
+				{Level: "INFO", Message: `exit_reason=fatal exit_code=2 details=""`},
+			}))
+		})
+	})
+})
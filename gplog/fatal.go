@@ -0,0 +1,69 @@
+package gplog
+
+/*
+ * This file contains structs and functions related to "library mode", in
+ * which Fatal-level messages are logged but do not end the program, for
+ * long-running processes that embed packages which call Fatal.
+ */
+
+var (
+	// If false, Fatal and FatalWithoutPanic call fatalHandler instead of panicking or exiting
+	panicOnFatal = true
+	fatalHandler func(err error)
+)
+
+/*
+ * A FatalError is passed to the handler set with SetFatalHandler.  Message is
+ * the logged message without its prefix, and Err is the error passed to Fatal,
+ * if any.
+ */
+type FatalError struct {
+	Err          error
+	Message      string
+	panicMessage string
+}
+
+func (err *FatalError) Error() string {
+	return err.Message
+}
+
+func (err *FatalError) Unwrap() error {
+	return err.Err
+}
+
+/*
+ * SetPanicOnFatal(false) puts the logger in library mode: Fatal, FatalWithCode,
+ * FatalOnError, and FatalWithoutPanic still log their messages at the CRITICAL
+ * level, print them to stderr, and set the error code, but then call the
+ * handler set with SetFatalHandler, if any, and return to their caller instead
+ * of panicking or exiting.
+ *
+ * Code written for utilities assumes that Fatal does not return, e.g. it may
+ * use a nil result right after calling FatalOnError, so library mode is only
+ * safe for callers whose handler ends the failed component itself, e.g. by
+ * cancelling its context or calling runtime.Goexit in its goroutine.
+ */
+func SetPanicOnFatal(shouldPanic bool) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	panicOnFatal = shouldPanic
+}
+
+// SetFatalHandler sets the function called for Fatal-level messages in library mode; see SetPanicOnFatal.
+func SetFatalHandler(handler func(err error)) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	fatalHandler = handler
+}
+
+// Called without logMutex held, so that the handler may log
+func handleFatal(fatalErr *FatalError) {
+	logMutex.Lock()
+	shouldPanic, handler := panicOnFatal, fatalHandler
+	logMutex.Unlock()
+	if shouldPanic {
+		abort(fatalErr.panicMessage)
+	} else if handler != nil {
+		handler(fatalErr)
+	}
+}
@@ -0,0 +1,77 @@
+package gplog_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gplog/fatal tests", func() {
+	var (
+		capture  *testhelper.LogCapture
+		handled  []error
+		exitCode int
+	)
+	BeforeEach(func() {
+		capture = testhelper.SetupTestLogCapture()
+		gplog.SetErrorCode(0)
+		handled = nil
+		exitCode = 0
+		gplog.SetExitFunc(func() { exitCode = 1 })
+		gplog.SetPanicOnFatal(false)
+		gplog.SetFatalHandler(func(err error) {
+			handled = append(handled, err)
+		})
+	})
+	AfterEach(func() {
+		gplog.SetPanicOnFatal(true)
+		gplog.SetFatalHandler(nil)
+		gplog.SetErrorCode(0)
+	})
+	Describe("SetPanicOnFatal", func() {
+		It("calls the handler instead of panicking", func() {
+			cause := fmt.Errorf("connection refused")
+
+			gplog.Fatal(cause, "Cannot connect to segment %d", 3)
+
+			Expect(handled).To(HaveLen(1))
+			Expect(handled[0]).To(MatchError("connection refused: Cannot connect to segment 3"))
+			Expect(errors.Is(handled[0], cause)).To(BeTrue())
+			var fatalErr *gplog.FatalError
+			Expect(errors.As(handled[0], &fatalErr)).To(BeTrue())
+			Expect(gplog.GetErrorCode()).To(Equal(2))
+		})
+		It("still logs the message at the CRITICAL level and prints it to stderr", func() {
+			gplog.FatalOnError(fmt.Errorf("out of memory"))
+
+			Expect(capture).To(testhelper.HaveLoggedCritical("out of memory"))
+			Expect(string(capture.Stderr.Contents())).To(ContainSubstring("out of memory"))
+		})
+		It("calls the handler instead of exiting from FatalWithoutPanic", func() {
+			gplog.FatalWithoutPanic("cannot continue")
+
+			Expect(exitCode).To(Equal(0))
+			Expect(handled).To(HaveLen(1))
+			Expect(handled[0]).To(MatchError("cannot continue"))
+		})
+		It("allows the handler to log", func() {
+			gplog.SetFatalHandler(func(err error) {
+				gplog.Warn("Restarting component after error: %v", err)
+			})
+
+			gplog.Fatal(nil, "component failed")
+
+			Expect(capture).To(testhelper.HaveLoggedWarn("Restarting component after error: component failed"))
+		})
+		It("panics again once re-enabled", func() {
+			gplog.SetPanicOnFatal(true)
+			defer testhelper.ShouldPanicWithMessage("component failed")
+
+			gplog.Fatal(nil, "component failed")
+		})
+	})
+})
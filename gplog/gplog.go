@@ -12,6 +12,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/greenplum-db/gp-common-go-libs/operating"
 	"github.com/pkg/errors"
@@ -42,6 +43,19 @@ var (
 	 */
 	logFileNameFunc LogFileNameFunc
 	exitFunc        ExitFunc
+	/*
+	 * The layouts and time zone of the timestamps in the default log prefix and
+	 * log file name.  A nil time zone leaves timestamps in the zone returned by
+	 * operating.System.Now, which is local time.
+	 */
+	prefixTimestampFormat   = DEFAULT_PREFIX_TIMESTAMP_FORMAT
+	fileNameTimestampFormat = DEFAULT_FILE_NAME_TIMESTAMP_FORMAT
+	outputTimeZone          *time.Location
+)
+
+const (
+	DEFAULT_PREFIX_TIMESTAMP_FORMAT    = "20060102:15:04:05"
+	DEFAULT_FILE_NAME_TIMESTAMP_FORMAT = "20060102"
 )
 
 const (
@@ -105,12 +119,17 @@ type GpLogger struct {
 	logStderr          *log.Logger
 	logFile            *log.Logger
 	logFileName        string
+	program            string
 	shellVerbosity     int
 	fileVerbosity      int
 	header             string
 	logPrefixFunc      LogPrefixFunc
 	shellLogPrefixFunc LogPrefixFunc
-	colorize           bool
+	colorMode          ColorMode
+	colorStdout        bool
+	colorStderr        bool
+	domainVerbosities  map[string]domainVerbosity
+	sinks              []writerSink
 }
 
 /*
@@ -145,7 +164,7 @@ func GenerateLogFileName(program, logdir string) string {
 	if logFileNameFunc != nil {
 		logfile = logFileNameFunc(program, logdir)
 	} else {
-		timestamp := operating.System.Now().Format("20060102")
+		timestamp := outputTime().Format(fileNameTimestampFormat)
 		logfile = fmt.Sprintf("%s/%s_%s.log", logdir, program, timestamp)
 	}
 	return logfile
@@ -172,12 +191,14 @@ func NewLogger(stdout io.Writer, stderr io.Writer, logFile io.Writer, logFileNam
 		logStderr:          log.New(stderr, "", 0),
 		logFile:            log.New(logFile, "", 0),
 		logFileName:        logFileName,
+		program:            program,
 		shellVerbosity:     shellVerbosity,
 		fileVerbosity:      fileVerbosity,
 		header:             GetHeader(program),
 		logPrefixFunc:      nil,
 		shellLogPrefixFunc: nil,
-		colorize:           false,
+		colorMode:          COLOR_NEVER,
+		domainVerbosities:  make(map[string]domainVerbosity),
 	}
 }
 
@@ -207,8 +228,13 @@ func SetShellLogPrefixFunc(logPrefixFunc func(string) string) {
 // yellow   - for WARNING levels
 // green    - for INFO levels produced via Success function call only
 // no color - for all other levels
+// SetColorize(true) is equivalent to SetColorMode(COLOR_ALWAYS); see color.go for COLOR_AUTO.
 func SetColorize(shouldColorize bool) {
-	logger.colorize = shouldColorize
+	if shouldColorize {
+		SetColorMode(COLOR_ALWAYS)
+	} else {
+		SetColorMode(COLOR_NEVER)
+	}
 }
 
 // GetColorize returns whether the colorization of shell console output has been enabled
@@ -216,19 +242,58 @@ func GetColorize() bool {
 	if logger == nil {
 		return false
 	}
-	return logger.colorize
+	return logger.colorStdout || logger.colorStderr
 }
 
 func SetLogFileNameFunc(fileNameFunc func(string, string) string) {
 	logFileNameFunc = fileNameFunc
 }
 
+/*
+ * SetOutputTimestampFormat sets the time.Format layout of the timestamp in the
+ * default log prefix, e.g. time.RFC3339 to include the UTC offset so that logs
+ * written on hosts in different time zones can be correlated.  An empty layout
+ * restores the default.
+ */
+func SetOutputTimestampFormat(layout string) {
+	if layout == "" {
+		layout = DEFAULT_PREFIX_TIMESTAMP_FORMAT
+	}
+	prefixTimestampFormat = layout
+}
+
+// SetLogFileTimestampFormat sets the layout of the date in the default log file name; an empty layout restores the default.
+func SetLogFileTimestampFormat(layout string) {
+	if layout == "" {
+		layout = DEFAULT_FILE_NAME_TIMESTAMP_FORMAT
+	}
+	fileNameTimestampFormat = layout
+}
+
+/*
+ * SetOutputTimeZone sets the time zone of the timestamps in the default log
+ * prefix and log file name, e.g. time.UTC.  A nil location restores local
+ * time.  It should be called before InitializeLogging so that the log file
+ * name uses the same time zone as the messages in it.
+ */
+func SetOutputTimeZone(location *time.Location) {
+	outputTimeZone = location
+}
+
+func outputTime() time.Time {
+	now := operating.System.Now()
+	if outputTimeZone != nil {
+		return now.In(outputTimeZone)
+	}
+	return now
+}
+
 func SetExitFunc(pExitFunc func()) {
 	exitFunc = pExitFunc
 }
 
 func defaultLogPrefixFunc(level string) string {
-	logTimestamp := operating.System.Now().Format("20060102:15:04:05")
+	logTimestamp := outputTime().Format(prefixTimestampFormat)
 	return fmt.Sprintf("%s %s", logTimestamp, fmt.Sprintf(logger.header, level))
 }
 
@@ -290,8 +355,11 @@ func GetErrorCode() int {
 	return errorCode
 }
 
+// SetErrorCode also clears any reason set with SetExitReason
 func SetErrorCode(code int) {
 	errorCode = code
+	exitReason = ""
+	exitReasonDetails = ""
 }
 
 func getVerbosityString(verbosity int) string {
@@ -315,6 +383,7 @@ func getVerbosityString(verbosity int) string {
 func Info(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
+	writeToSinks(LOGINFO, "INFO", fmt.Sprintf(s, v...))
 	if logger.fileVerbosity >= LOGINFO {
 		message := GetLogPrefix("INFO") + fmt.Sprintf(s, v...)
 		_ = logger.logFile.Output(1, message)
@@ -328,28 +397,31 @@ func Info(s string, v ...interface{}) {
 func Success(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
+	writeToSinks(LOGINFO, "INFO", fmt.Sprintf(s, v...))
 	if logger.fileVerbosity >= LOGINFO {
 		message := GetLogPrefix("INFO") + fmt.Sprintf(s, v...)
 		_ = logger.logFile.Output(1, message)
 	}
 	if logger.shellVerbosity >= LOGINFO {
 		message := GetShellLogPrefix("INFO") + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, Colorize(GREEN, message))
+		_ = logger.logStdout.Output(1, colorizeStdout(GREEN, message))
 	}
 }
 
 func Warn(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
+	writeToSinks(LOGERROR, "WARNING", fmt.Sprintf(s, v...))
 	message := GetLogPrefix("WARNING") + fmt.Sprintf(s, v...)
 	_ = logger.logFile.Output(1, message)
 	message = GetShellLogPrefix("WARNING") + fmt.Sprintf(s, v...)
-	_ = logger.logStdout.Output(1, Colorize(YELLOW, message))
+	_ = logger.logStdout.Output(1, colorizeStdout(YELLOW, message))
 }
 
 func Verbose(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
+	writeToSinks(LOGVERBOSE, "DEBUG", fmt.Sprintf(s, v...))
 	if logger.fileVerbosity >= LOGVERBOSE {
 		message := GetLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
 		_ = logger.logFile.Output(1, message)
@@ -363,6 +435,7 @@ func Verbose(s string, v ...interface{}) {
 func Debug(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
+	writeToSinks(LOGDEBUG, "DEBUG", fmt.Sprintf(s, v...))
 	if logger.fileVerbosity >= LOGDEBUG {
 		message := GetLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
 		_ = logger.logFile.Output(1, message)
@@ -376,7 +449,8 @@ func Debug(s string, v ...interface{}) {
 func Error(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
-	errorCode = 1
+	writeToSinks(LOGERROR, "ERROR", fmt.Sprintf(s, v...))
+	setDefaultErrorCode(1)
 	message := GetLogPrefix("ERROR") + fmt.Sprintf(s, v...)
 	_ = logger.logFile.Output(1, message)
 	message = GetShellLogPrefix("ERROR") + fmt.Sprintf(s, v...)
@@ -385,8 +459,36 @@ func Error(s string, v ...interface{}) {
 
 func Fatal(err error, s string, v ...interface{}) {
 	logMutex.Lock()
-	defer logMutex.Unlock()
-	errorCode = 2
+	setFatalErrorCode(err)
+	fatalErr := logFatal(err, s, v...)
+	logMutex.Unlock()
+	handleFatal(fatalErr)
+}
+
+/*
+ * FatalWithCode behaves like Fatal, but exits with the given error code, which
+ * overrides any exit reason already set or mapped from err.  The exit reason
+ * becomes the one registered with the code, if any, with err as its details.
+ */
+func FatalWithCode(code int, err error, s string, v ...interface{}) {
+	logMutex.Lock()
+	errorCode = code
+	exitReason = reasonForCode(code)
+	exitReasonDetails = ""
+	if exitReason != "" && err != nil {
+		exitReasonDetails = err.Error()
+	}
+	fatalErr := logFatal(err, s, v...)
+	logMutex.Unlock()
+	handleFatal(fatalErr)
+}
+
+/*
+ * Logs the message and returns the error for handleFatal.  Must be called with
+ * logMutex held, and directly from Fatal or FatalWithCode so that the stack
+ * trace starts at their caller.
+ */
+func logFatal(err error, s string, v ...interface{}) *FatalError {
 	message := ""
 	stackTraceStr := ""
 	if err != nil {
@@ -399,14 +501,19 @@ func Fatal(err error, s string, v ...interface{}) {
 	message += strings.TrimSpace(fmt.Sprintf(s, v...))
 	fullMessage := GetLogPrefix("CRITICAL") + message
 	_ = logger.logFile.Output(1, fullMessage+stackTraceStr)
+	writeToSinks(LOGERROR, "CRITICAL", message+stackTraceStr)
 	fullMessage = GetShellLogPrefix("CRITICAL") + message
+	if !panicOnFatal {
+		// Nothing will recover the panic message to print it, so it is printed here
+		_ = logger.logStderr.Output(1, Colorize(RED, fullMessage))
+	}
 	// messages for panic are not colorized to allow any recover logic to inspect the actual fullMessage
 	// if the fullMessage needs to be output to the shell console, the caller should colorize it explicitly, if desired
+	panicMessage := fullMessage
 	if logger.shellVerbosity >= LOGVERBOSE {
-		abort(fullMessage + stackTraceStr)
-	} else {
-		abort(fullMessage)
+		panicMessage += stackTraceStr
 	}
+	return &FatalError{Err: err, Message: message, panicMessage: panicMessage}
 }
 
 /*
@@ -416,6 +523,7 @@ func Fatal(err error, s string, v ...interface{}) {
 func Custom(customFileVerbosity int, customShellVerbosity int, s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
+	writeToSinks(customFileVerbosity, getVerbosityString(customFileVerbosity), fmt.Sprintf(s, v...))
 	var message string
 	if logger.fileVerbosity >= customFileVerbosity {
 		message = GetLogPrefix(getVerbosityString(customFileVerbosity)) + fmt.Sprintf(s, v...)
@@ -442,22 +550,30 @@ func FatalOnError(err error, output ...string) {
 
 func FatalWithoutPanic(s string, v ...interface{}) {
 	logMutex.Lock()
-	defer logMutex.Unlock()
-	errorCode = 2
+	writeToSinks(LOGERROR, "CRITICAL", fmt.Sprintf(s, v...))
+	setDefaultErrorCode(2)
 	message := GetLogPrefix("CRITICAL") + fmt.Sprintf(s, v...)
 	_ = logger.logFile.Output(1, message)
 	message = GetShellLogPrefix("CRITICAL") + fmt.Sprintf(s, v...)
 	_ = logger.logStderr.Output(1, Colorize(RED, message))
-	exitFunc()
+	writeExitSummary()
+	exits, handler := panicOnFatal, fatalHandler
+	logMutex.Unlock()
+	if exits {
+		exitFunc()
+	} else if handler != nil {
+		handler(&FatalError{Message: fmt.Sprintf(s, v...)})
+	}
 }
 
 type stackTracer interface {
 	StackTrace() errors.StackTrace
 }
 
+// The first two frames, logFatal and Fatal or FatalWithCode, are skipped
 func formatStackTrace(err error) string {
 	st := err.(stackTracer).StackTrace()
-	message := fmt.Sprintf("%+v", st[1:])
+	message := fmt.Sprintf("%+v", st[2:])
 	return message
 }
 
@@ -505,7 +621,7 @@ func createLogDirectory(dirname string) {
 }
 
 func defaultExit() {
-	os.Exit(1)
+	operating.System.Exit(1)
 }
 
 // color returns special characters that should be prepended to a string to make it of a particular color on the console
@@ -517,10 +633,18 @@ func color(c Color) string {
 }
 
 // Colorize wraps a string with special characters so that the string has a provided color when output to the console
-// colorization happens only if the logger flag `colorize` is set to true. The function is exported to allow
+// colorization happens only if colorization of stderr is enabled; see SetColorMode. The function is exported to allow
 // colorization outside the logging methods, such as when recovering from a `panic` when Fatal messages are logged.
 func Colorize(c Color, text string) string {
-	if logger.colorize {
+	return colorizeIf(logger.colorStderr, c, text)
+}
+
+func colorizeStdout(c Color, text string) string {
+	return colorizeIf(logger.colorStdout, c, text)
+}
+
+func colorizeIf(shouldColorize bool, c Color, text string) string {
+	if shouldColorize {
 		return color(c) + text + color(NONE)
 	}
 	return text
@@ -118,6 +118,29 @@ var _ = Describe("logger/log tests", func() {
 				gplog.InitializeLogging("testProgram", "/tmp/log_dir")
 				Expect(calledWith).To(Equal("/tmp/log_dir/testProgram_20170101.log"))
 			})
+			It("creates the log directory and file in a deterministic environment", func() {
+				env, cleanup := testhelper.SetupDeterministicEnvironment()
+				defer cleanup()
+
+				gplog.InitializeLogging("testProgram", "/tmp/log_dir")
+				gplog.Debug("test message")
+
+				testhelper.ExpectTree(env.Files, "/tmp", "log_dir/", "log_dir/testProgram_20170101.log")
+				testhelper.ExpectFileContent(env.Files, "/tmp/log_dir/testProgram_20170101.log", "20170101:01:01:01 testProgram:testUser:testHost:000000-[DEBUG]:-test message\n")
+			})
+			It("appends to an existing log file in a fixture", func() {
+				env, cleanup := testhelper.SetupDeterministicEnvironment()
+				defer cleanup()
+				testhelper.NewFixtureFS().
+					WithDir("/tmp/log_dir", 0700).
+					WithFile("/tmp/log_dir/testProgram_20170101.log", "earlier message\n", 0644).
+					Populate(env.Files)
+
+				gplog.InitializeLogging("testProgram", "/tmp/log_dir")
+				gplog.Debug("test message")
+
+				testhelper.ExpectFileContent(env.Files, "/tmp/log_dir/testProgram_20170101.log", "earlier message\n20170101:01:01:01 testProgram:testUser:testHost:000000-[DEBUG]:-test message\n")
+			})
 			It("panics if given a non-writable log directory", func() {
 				operating.System.Stat = func(name string) (os.FileInfo, error) { return fakeInfo, errors.New("permission denied") }
 				defer testhelper.ShouldPanicWithMessage("permission denied")
@@ -131,6 +154,17 @@ var _ = Describe("logger/log tests", func() {
 				gplog.InitializeLogging("testProgram", "/tmp/log_dir")
 			})
 		})
+		Context("Default exit function", func() {
+			It("exits through operating.System.Exit", func() {
+				exitCode := -1
+				operating.System.Exit = func(code int) { exitCode = code }
+				gplog.InitializeLogging("testProgram", "/tmp/log_dir")
+				_, stderr, _ = testhelper.SetupTestLogger()
+
+				gplog.FatalWithoutPanic("fatal without panic")
+				Expect(exitCode).To(Equal(1))
+			})
+		})
 	})
 	Describe("GetLogPrefix", func() {
 		It("returns a prefix for the current time", func() {
@@ -151,6 +185,34 @@ var _ = Describe("logger/log tests", func() {
 			Expect(expectedMessage).To(Equal(prefix))
 			gplog.SetLogPrefixFunc(nil)
 		})
+		It("returns a prefix with the configured timestamp format and time zone", func() {
+			operating.System.Now = func() time.Time {
+				return time.Date(2017, time.January, 1, 1, 1, 1, 1, time.FixedZone("PST", -8*60*60))
+			}
+			gplog.SetOutputTimestampFormat(time.RFC3339)
+			gplog.SetOutputTimeZone(time.UTC)
+			defer gplog.SetOutputTimestampFormat("")
+			defer gplog.SetOutputTimeZone(nil)
+
+			prefix := gplog.GetLogPrefix("INFO")
+			Expect(prefix).To(Equal("2017-01-01T09:01:01Z testProgram:testUser:testHost:000000-[INFO]:-"))
+		})
+	})
+	Describe("GenerateLogFileName", func() {
+		It("uses the current date in the file name", func() {
+			Expect(gplog.GenerateLogFileName("testProgram", "/tmp/log_dir")).To(Equal("/tmp/log_dir/testProgram_20170101.log"))
+		})
+		It("uses the configured timestamp format and time zone in the file name", func() {
+			operating.System.Now = func() time.Time {
+				return time.Date(2017, time.January, 1, 20, 0, 0, 0, time.FixedZone("PST", -8*60*60))
+			}
+			gplog.SetLogFileTimestampFormat("2006-01-02")
+			gplog.SetOutputTimeZone(time.UTC)
+			defer gplog.SetLogFileTimestampFormat("")
+			defer gplog.SetOutputTimeZone(nil)
+
+			Expect(gplog.GenerateLogFileName("testProgram", "/tmp/log_dir")).To(Equal("/tmp/log_dir/testProgram_2017-01-02.log"))
+		})
 	})
 	Describe("GetShellLogPrefix", func() {
 		It("returns a prefix for the current time", func() {
@@ -1086,4 +1148,45 @@ var _ = Describe("logger/log tests", func() {
 			})
 		})
 	})
+	Describe("Log capture", func() {
+		var capture *testhelper.LogCapture
+		BeforeEach(func() {
+			capture = testhelper.SetupTestLogCapture()
+		})
+		It("records messages at each level without their prefixes", func() {
+			gplog.Info("info %d", 1)
+			gplog.Verbose("verbose")
+			gplog.Warn("warning")
+			gplog.Error("error")
+			defer testhelper.ShouldPanicWithMessage("fatal")
+			defer func() {
+				Expect(capture.Entries()).To(Equal([]testhelper.LogEntry{
+					{Level: "INFO", Message: "info 1"},
+					{Level: "DEBUG", Message: "verbose"},
+					{Level: "WARNING", Message: "warning"},
+					{Level: "ERROR", Message: "error"},
+					{Level: "CRITICAL", Message: "fatal"},
+				}))
+			}()
+			gplog.Fatal(nil, "fatal")
+		})
+		It("matches messages by level and substring", func() {
+			gplog.Warn("Skipping table %s", "public.foo")
+			gplog.Error("Unable to connect to segment 2")
+
+			Expect(capture).To(testhelper.HaveLoggedWarn("public.foo"))
+			Expect(capture).To(testhelper.HaveLoggedError("segment 2"))
+			Expect(capture).ToNot(testhelper.HaveLoggedError("public.foo"))
+			Expect(capture).ToNot(testhelper.HaveLoggedInfo("segment 2"))
+			Expect(capture.Stderr).To(gbytes.Say("Unable to connect to segment 2"))
+		})
+		It("records lines with a custom prefix without a level", func() {
+			gplog.SetLogPrefixFunc(func(level string) string { return "custom: " })
+			gplog.Info("message")
+
+			Expect(capture.Entries()).To(Equal([]testhelper.LogEntry{{Level: "", Message: "custom: message"}}))
+			capture.Reset()
+			Expect(capture.Entries()).To(BeEmpty())
+		})
+	})
 })
@@ -0,0 +1,66 @@
+package gplog
+
+/*
+ * This file contains structs and functions related to safely sharing one log
+ * file between multiple processes on the same host.
+ */
+
+import (
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * Log files are always opened with O_APPEND and each message is passed to the
+ * file in a single Write call, so messages from different processes are not
+ * interleaved on local filesystems.  That guarantee does not hold for writes
+ * to network filesystems or for very large messages, so a sharedFileWriter
+ * additionally holds an exclusive advisory lock on the file for each write.
+ *
+ * Writers that are not files (such as the buffers used in tests) cannot be
+ * locked and are written to directly.
+ */
+type fileDescriptor interface {
+	Fd() uintptr
+}
+
+type sharedFileWriter struct {
+	writer io.Writer
+}
+
+func (w *sharedFileWriter) Write(p []byte) (int, error) {
+	file, ok := w.writer.(fileDescriptor)
+	if !ok {
+		return w.writer.Write(p)
+	}
+	if err := lockFile(file.Fd()); err != nil {
+		return 0, errors.Wrap(err, "Unable to lock log file")
+	}
+	defer func() { _ = unlockFile(file.Fd()) }()
+	return w.writer.Write(p)
+}
+
+/*
+ * EnableSharedLogFile makes logging to a log file that is shared with other
+ * processes reliable, for utilities that start helper processes which all log
+ * to the same file.  Each write to the log file is made while holding a lock
+ * on the file, and processIdentifier (if not empty) is added after the PID in
+ * the default log prefix so that lines written by different helpers can be
+ * told apart, e.g. "gpbackup:gpadmin:cdw:012345(helper)-[INFO]:-".  A custom
+ * prefix function set with SetLogPrefixFunc should include its own identifier.
+ *
+ * This must be called after the logger has been initialized, and should be
+ * called by every process writing to the file.
+ */
+func EnableSharedLogFile(processIdentifier string) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if _, ok := logger.logFile.Writer().(*sharedFileWriter); !ok {
+		logger.logFile.SetOutput(&sharedFileWriter{writer: logger.logFile.Writer()})
+	}
+	if processIdentifier != "" {
+		logger.header = strings.TrimSuffix(GetHeader(logger.program), "-[%s]:-") + "(" + processIdentifier + ")-[%s]:-"
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package gplog
+
+// File locking is not supported here, so only O_APPEND protects shared log files
+func lockFile(fd uintptr) error {
+	return nil
+}
+
+func unlockFile(fd uintptr) error {
+	return nil
+}
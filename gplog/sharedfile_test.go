@@ -0,0 +1,128 @@
+//go:build linux || darwin
+
+package gplog_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("gplog/sharedfile tests", func() {
+	var (
+		logDir   string
+		logPath  string
+		logFile  *os.File
+		cleanup  func()
+		readFile = func() string {
+			contents, err := os.ReadFile(logPath)
+			Expect(err).ToNot(HaveOccurred())
+			return string(contents)
+		}
+	)
+	BeforeEach(func() {
+		var err error
+		logDir, err = os.MkdirTemp("", "gplog_shared")
+		Expect(err).ToNot(HaveOccurred())
+		logPath = filepath.Join(logDir, "testProgram.log")
+		logFile, err = os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		Expect(err).ToNot(HaveOccurred())
+
+		var env *testhelper.DeterministicEnvironment
+		env, cleanup = testhelper.SetupDeterministicEnvironment()
+		env.Pid = 42
+		gplog.SetLogger(gplog.NewLogger(gbytes.NewBuffer(), gbytes.NewBuffer(), logFile, logPath, gplog.LOGINFO, "testProgram"))
+	})
+	AfterEach(func() {
+		_ = logFile.Close()
+		_ = os.RemoveAll(logDir)
+		cleanup()
+		testhelper.SetupTestLogger()
+	})
+	Describe("EnableSharedLogFile", func() {
+		It("adds the process identifier after the PID in the log prefix", func() {
+			gplog.EnableSharedLogFile("helper_3")
+
+			gplog.Info("test message")
+
+			Expect(readFile()).To(Equal("20170101:01:01:01 testProgram:testUser:testHost:000042(helper_3)-[INFO]:-test message\n"))
+		})
+		It("leaves the log prefix unchanged if no identifier is given", func() {
+			gplog.EnableSharedLogFile("")
+
+			gplog.Info("test message")
+
+			Expect(readFile()).To(Equal("20170101:01:01:01 testProgram:testUser:testHost:000042-[INFO]:-test message\n"))
+		})
+		It("can be called more than once", func() {
+			gplog.EnableSharedLogFile("helper_1")
+			gplog.EnableSharedLogFile("helper_2")
+
+			gplog.Info("test message")
+
+			Expect(readFile()).To(Equal("20170101:01:01:01 testProgram:testUser:testHost:000042(helper_2)-[INFO]:-test message\n"))
+		})
+		It("waits for other processes to release the log file before writing", func() {
+			gplog.EnableSharedLogFile("helper")
+			// Locks on separate open file descriptions conflict even within one process
+			otherHandle, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+			Expect(err).ToNot(HaveOccurred())
+			defer otherHandle.Close()
+			Expect(syscall.Flock(int(otherHandle.Fd()), syscall.LOCK_EX)).To(Succeed())
+
+			done := make(chan bool)
+			go func() {
+				defer GinkgoRecover()
+				gplog.Info("after unlock")
+				close(done)
+			}()
+			Consistently(done, 100*time.Millisecond).ShouldNot(BeClosed())
+			_, err = otherHandle.WriteString("other process line\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(syscall.Flock(int(otherHandle.Fd()), syscall.LOCK_UN)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+
+			lines := strings.Split(strings.TrimSpace(readFile()), "\n")
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(Equal("other process line"))
+			Expect(lines[1]).To(HaveSuffix("(helper)-[INFO]:-after unlock"))
+		})
+		It("writes complete lines when logging concurrently", func() {
+			gplog.EnableSharedLogFile("helper")
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					gplog.Info("message %d %s", i, strings.Repeat("x", 8192))
+				}(i)
+			}
+			wg.Wait()
+
+			lines := strings.Split(strings.TrimSpace(readFile()), "\n")
+			Expect(lines).To(HaveLen(10))
+			for _, line := range lines {
+				Expect(line).To(MatchRegexp(`\(helper\)-\[INFO\]:-message \d x+$`))
+				Expect(line).To(HaveSuffix(strings.Repeat("x", 8192)))
+			}
+		})
+		It("writes directly to log destinations that are not files", func() {
+			buffer := gbytes.NewBuffer()
+			gplog.SetLogger(gplog.NewLogger(gbytes.NewBuffer(), gbytes.NewBuffer(), buffer, "", gplog.LOGINFO, "testProgram"))
+			gplog.EnableSharedLogFile("helper")
+
+			gplog.Info("test message")
+
+			Expect(buffer).To(gbytes.Say(`testProgram:testUser:testHost:000042\(helper\)-\[INFO\]:-test message`))
+		})
+	})
+})
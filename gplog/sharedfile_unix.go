@@ -0,0 +1,20 @@
+//go:build linux || darwin
+
+package gplog
+
+import (
+	"syscall"
+)
+
+func lockFile(fd uintptr) error {
+	for {
+		err := syscall.Flock(int(fd), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+func unlockFile(fd uintptr) error {
+	return syscall.Flock(int(fd), syscall.LOCK_UN)
+}
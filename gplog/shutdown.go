@@ -0,0 +1,127 @@
+package gplog
+
+/*
+ * This file contains structs and functions related to cleaning up and exiting
+ * when a utility is interrupted with SIGINT or SIGTERM.
+ */
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+/*
+ * A ShutdownHandler runs cleanup functions and exits when the utility receives
+ * SIGINT or SIGTERM.  It is created by GracefulShutdown and handles at most one
+ * shutdown; Stop restores the default signal behavior once the utility no
+ * longer needs its cleanup to run, e.g. when it is about to exit normally.
+ */
+type ShutdownHandler struct {
+	mutex    sync.Mutex
+	cleanups []func()
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+/*
+ * GracefulShutdown starts handling SIGINT and SIGTERM.  When either is
+ * received, the cleanup functions passed here or to AddCleanup are run in the
+ * reverse of the order they were added, as deferred calls are, so that e.g. a
+ * transaction is rolled back before its connection is closed.  The program
+ * then exits via the function set with SetExitFunc, with the exit reason set
+ * to EXIT_USER_CANCEL if that reason has been registered.
+ *
+ * A cleanup function that panics, e.g. by calling Fatal, is logged and does
+ * not prevent the remaining functions from running.  If a second signal is
+ * received while the cleanup functions are running, the program exits without
+ * waiting for them to finish, so that a hung cleanup can still be interrupted.
+ */
+func GracefulShutdown(cleanups ...func()) *ShutdownHandler {
+	handler := &ShutdownHandler{
+		cleanups: cleanups,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	signals, stopSignals := operating.System.NotifySignals(os.Interrupt, syscall.SIGTERM)
+	go func(stop chan struct{}) {
+		defer close(handler.stopped)
+		defer stopSignals()
+		select {
+		case <-stop:
+			return
+		case sig := <-signals:
+			handler.shutDown(sig, signals)
+		}
+	}(handler.stop)
+	return handler
+}
+
+// AddCleanup adds a function to run on shutdown, e.g. once the resource it cleans up has been created.
+func (handler *ShutdownHandler) AddCleanup(cleanup func()) {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+	handler.cleanups = append(handler.cleanups, cleanup)
+}
+
+/*
+ * Stop stops handling signals without running the cleanup functions.  If a
+ * shutdown is already in progress, it waits for the shutdown to finish, which
+ * only returns if the exit function does.  Calling it more than once has no
+ * effect.
+ */
+func (handler *ShutdownHandler) Stop() {
+	handler.mutex.Lock()
+	stop := handler.stop
+	handler.stop = nil
+	handler.mutex.Unlock()
+	if stop != nil {
+		close(stop)
+		<-handler.stopped
+	}
+}
+
+func (handler *ShutdownHandler) shutDown(sig os.Signal, signals <-chan os.Signal) {
+	Warn("Received %s; cleaning up before exiting", sig)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		handler.runCleanups()
+	}()
+	select {
+	case <-finished:
+	case sig := <-signals:
+		Warn("Received %s again; exiting without finishing cleanup", sig)
+	}
+
+	logMutex.Lock()
+	if _, ok := exitReasonCodes[EXIT_USER_CANCEL]; ok && exitReason == "" {
+		exitReason = EXIT_USER_CANCEL
+		exitReasonDetails = fmt.Sprintf("received %s", sig)
+		errorCode = exitReasonCodes[EXIT_USER_CANCEL]
+	}
+	writeExitSummary()
+	exit := exitFunc
+	logMutex.Unlock()
+	exit()
+}
+
+func (handler *ShutdownHandler) runCleanups() {
+	handler.mutex.Lock()
+	cleanups := make([]func(), len(handler.cleanups))
+	copy(cleanups, handler.cleanups)
+	handler.mutex.Unlock()
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		func() {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					Warn("Cleanup function panicked during shutdown: %v", recovered)
+				}
+			}()
+			cleanups[i]()
+		}()
+	}
+}
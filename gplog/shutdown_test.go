@@ -0,0 +1,108 @@
+package gplog_test
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gplog/shutdown tests", func() {
+	var (
+		capture       *testhelper.LogCapture
+		handler       *gplog.ShutdownHandler
+		signals       chan os.Signal
+		notified      []os.Signal
+		signalsActive bool
+		exited        chan bool
+		cleanedUp     chan string
+	)
+	BeforeEach(func() {
+		capture = testhelper.SetupTestLogCapture()
+		gplog.SetErrorCode(0)
+		signals = make(chan os.Signal, 2)
+		exited = make(chan bool, 1)
+		cleanedUp = make(chan string, 3)
+		operating.System.NotifySignals = func(sigs ...os.Signal) (<-chan os.Signal, func()) {
+			notified = sigs
+			signalsActive = true
+			return signals, func() { signalsActive = false }
+		}
+		gplog.SetExitFunc(func() { exited <- true })
+	})
+	AfterEach(func() {
+		handler.Stop()
+		operating.System = operating.InitializeSystemFunctions()
+		gplog.SetErrorCode(0)
+	})
+	Describe("GracefulShutdown", func() {
+		It("handles SIGINT and SIGTERM", func() {
+			handler = gplog.GracefulShutdown()
+
+			Expect(notified).To(ConsistOf(os.Interrupt, syscall.SIGTERM))
+		})
+		It("runs the cleanup functions in reverse order and then exits", func() {
+			handler = gplog.GracefulShutdown(func() { cleanedUp <- "first" })
+			handler.AddCleanup(func() { cleanedUp <- "second" })
+
+			signals <- syscall.SIGTERM
+
+			Eventually(exited).Should(Receive())
+			Expect(cleanedUp).To(Receive(Equal("second")))
+			Expect(cleanedUp).To(Receive(Equal("first")))
+			Expect(capture).To(testhelper.HaveLoggedWarn("Received terminated; cleaning up before exiting"))
+			handler.Stop()
+			Expect(signalsActive).To(BeFalse())
+		})
+		It("runs the remaining cleanup functions if one panics", func() {
+			handler = gplog.GracefulShutdown(func() { cleanedUp <- "first" }, func() { panic("cannot roll back") })
+
+			signals <- os.Interrupt
+
+			Eventually(exited).Should(Receive())
+			Expect(cleanedUp).To(Receive(Equal("first")))
+			Expect(capture).To(testhelper.HaveLoggedWarn("Cleanup function panicked during shutdown: cannot roll back"))
+		})
+		It("exits without waiting for the cleanup functions on a second signal", func() {
+			block := make(chan struct{})
+			defer close(block)
+			handler = gplog.GracefulShutdown(func() { <-block })
+
+			signals <- os.Interrupt
+			Consistently(exited, "20ms").ShouldNot(Receive())
+			signals <- os.Interrupt
+
+			Eventually(exited).Should(Receive())
+			Expect(capture).To(testhelper.HaveLoggedWarn("Received interrupt again; exiting without finishing cleanup"))
+		})
+		It("sets the exit reason to EXIT_USER_CANCEL if it is registered", func() {
+			gplog.RegisterErrorCategory(gplog.EXIT_USER_CANCEL, 130, nil)
+			defer gplog.ResetErrorCategories()
+			handler = gplog.GracefulShutdown()
+
+			signals <- os.Interrupt
+
+			Eventually(exited).Should(Receive())
+			reason, details := gplog.GetExitReason()
+			Expect(reason).To(Equal(gplog.EXIT_USER_CANCEL))
+			Expect(details).To(Equal("received interrupt"))
+			Expect(gplog.GetErrorCode()).To(Equal(130))
+		})
+	})
+	Describe("ShutdownHandler.Stop", func() {
+		It("stops handling signals without running the cleanup functions", func() {
+			handler = gplog.GracefulShutdown(func() { cleanedUp <- "first" })
+
+			handler.Stop()
+			handler.Stop()
+
+			Expect(signalsActive).To(BeFalse())
+			Expect(cleanedUp).ToNot(Receive())
+			Expect(exited).ToNot(Receive())
+		})
+	})
+})
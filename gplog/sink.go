@@ -0,0 +1,90 @@
+package gplog
+
+/*
+ * This file contains structs and functions related to duplicating log
+ * messages to additional writers at runtime.
+ */
+
+import (
+	"io"
+	"strings"
+)
+
+/*
+ * A SinkID identifies a sink added with AddWriterSink, so that it can be
+ * removed without comparing writers, which would panic for writers of
+ * non-comparable types such as funcs or structs containing slices.
+ */
+type SinkID int
+
+type writerSink struct {
+	id        SinkID
+	writer    io.Writer
+	verbosity int
+}
+
+var nextSinkID SinkID
+
+/*
+ * AddWriterSink makes every message at or below the given verbosity also be
+ * written to w, in the same format as the log file, so that e.g. a service
+ * embedding a utility can stream its logs to a client while the log file and
+ * terminal output continue unchanged.  The sink's verbosity is independent of
+ * the logger's and of any domain's verbosity.  The returned SinkID is passed
+ * to RemoveWriterSink to stop writing to w; adding the same writer twice adds
+ * two sinks, each of which must be removed.
+ *
+ * Each message is passed to w in a single Write call while output is locked,
+ * so w must not block for long and must not log through gplog.  If a write
+ * fails, as when a client has disconnected, the sink is removed.
+ */
+func AddWriterSink(w io.Writer, verbosity int) SinkID {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	nextSinkID++
+	logger.sinks = append(logger.sinks, writerSink{id: nextSinkID, writer: w, verbosity: verbosity})
+	return nextSinkID
+}
+
+// RemoveWriterSink stops writing messages to the given sink; it does nothing if the sink was already removed
+func RemoveWriterSink(id SinkID) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	removeWriterSink(id)
+}
+
+func removeWriterSink(id SinkID) {
+	sinks := make([]writerSink, 0, len(logger.sinks))
+	for _, sink := range logger.sinks {
+		if sink.id != id {
+			sinks = append(sinks, sink)
+		}
+	}
+	logger.sinks = sinks
+}
+
+/*
+ * Must be called with logMutex held.  Messages at LOGERROR verbosity, such as
+ * warnings and errors, are written to every sink.
+ */
+func writeToSinks(verbosity int, level string, message string) {
+	if len(logger.sinks) == 0 {
+		return
+	}
+	line := GetLogPrefix(level) + message
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	failed := make([]SinkID, 0)
+	for _, sink := range logger.sinks {
+		if sink.verbosity < verbosity {
+			continue
+		}
+		if _, err := io.WriteString(sink.writer, line); err != nil {
+			failed = append(failed, sink.id)
+		}
+	}
+	for _, id := range failed {
+		removeWriterSink(id)
+	}
+}
@@ -0,0 +1,129 @@
+package gplog_test
+
+import (
+	"errors"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("connection closed")
+}
+
+// writerFunc is not comparable, so comparing it as an io.Writer would panic
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+var _ = Describe("gplog/sink tests", func() {
+	var (
+		stdout  *gbytes.Buffer
+		logfile *gbytes.Buffer
+		sink    *gbytes.Buffer
+	)
+	BeforeEach(func() {
+		stdout, _, logfile = testhelper.SetupTestLogger()
+		sink = gbytes.NewBuffer()
+	})
+
+	Describe("AddWriterSink", func() {
+		It("writes messages at or below the sink's verbosity in the log file format", func() {
+			gplog.AddWriterSink(sink, gplog.LOGVERBOSE)
+
+			gplog.Info("info message")
+			gplog.Verbose("verbose message")
+			gplog.Debug("debug message")
+			gplog.Warn("warn message")
+			gplog.Error("error message")
+
+			Expect(sink).To(gbytes.Say(`\[INFO\]:-info message\n`))
+			Expect(sink).To(gbytes.Say(`\[DEBUG\]:-verbose message\n`))
+			Expect(sink).To(gbytes.Say(`\[WARNING\]:-warn message\n`))
+			Expect(sink).To(gbytes.Say(`\[ERROR\]:-error message\n`))
+			Expect(sink.Contents()).ToNot(ContainSubstring("debug message"))
+		})
+		It("does not change the output to the terminal or log file", func() {
+			gplog.AddWriterSink(sink, gplog.LOGDEBUG)
+
+			gplog.Verbose("verbose message")
+
+			Expect(sink).To(gbytes.Say("verbose message"))
+			Expect(logfile).To(gbytes.Say("verbose message"))
+			Expect(stdout.Contents()).To(BeEmpty())
+		})
+		It("uses the sink's verbosity for domain messages", func() {
+			gplog.SetDomainLogFileVerbosity("cluster", gplog.LOGERROR)
+			gplog.AddWriterSink(sink, gplog.LOGDEBUG)
+
+			gplog.Domain("cluster").Debug("domain message")
+
+			Expect(sink).To(gbytes.Say(`\[DEBUG\]:-domain message`))
+			Expect(logfile.Contents()).To(BeEmpty())
+		})
+		It("removes a sink that fails to write", func() {
+			failing := &failingWriter{}
+			gplog.AddWriterSink(failing, gplog.LOGINFO)
+			gplog.AddWriterSink(sink, gplog.LOGINFO)
+
+			gplog.Info("first message")
+			gplog.Info("second message")
+
+			Expect(failing.writes).To(Equal(1))
+			Expect(sink).To(gbytes.Say("first message"))
+			Expect(sink).To(gbytes.Say("second message"))
+		})
+	})
+	Describe("RemoveWriterSink", func() {
+		It("stops writing messages to the sink", func() {
+			other := gbytes.NewBuffer()
+			id := gplog.AddWriterSink(sink, gplog.LOGINFO)
+			gplog.AddWriterSink(other, gplog.LOGINFO)
+
+			gplog.RemoveWriterSink(id)
+			gplog.Info("info message")
+
+			Expect(sink.Contents()).To(BeEmpty())
+			Expect(other).To(gbytes.Say("info message"))
+		})
+		It("removes only the given sink when a writer is added more than once", func() {
+			first := gplog.AddWriterSink(sink, gplog.LOGINFO)
+			gplog.AddWriterSink(sink, gplog.LOGINFO)
+
+			gplog.RemoveWriterSink(first)
+			gplog.Info("info message")
+
+			Expect(sink).To(gbytes.Say("info message"))
+			Expect(sink).ToNot(gbytes.Say("info message"))
+		})
+		It("removes sinks whose writers are not comparable", func() {
+			lines := make([]string, 0)
+			funcWriter := writerFunc(func(p []byte) (int, error) {
+				lines = append(lines, string(p))
+				return len(p), nil
+			})
+			funcID := gplog.AddWriterSink(funcWriter, gplog.LOGINFO)
+			failingID := gplog.AddWriterSink(writerFunc(func(p []byte) (int, error) {
+				return 0, errors.New("connection closed")
+			}), gplog.LOGINFO)
+
+			gplog.Info("first message")
+			gplog.RemoveWriterSink(funcID)
+			gplog.RemoveWriterSink(failingID)
+			gplog.Info("second message")
+
+			Expect(lines).To(HaveLen(1))
+			Expect(lines[0]).To(ContainSubstring("first message"))
+		})
+	})
+})
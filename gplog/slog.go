@@ -0,0 +1,102 @@
+//go:build go1.21
+
+package gplog
+
+/*
+ * This file contains structs and functions related to routing logs written
+ * with the standard library's log/slog package through gplog.  It is only
+ * built with Go 1.21 and later, which added log/slog.
+ */
+
+import (
+	"context"
+	"log/slog"
+)
+
+/*
+ * A slogHandler implements slog.Handler.  slog's levels map onto gplog's
+ * verbosity levels: DEBUG is DEBUG, INFO is INFO, WARN is WARNING, and ERROR
+ * is ERROR; levels in between are rounded down.  Attributes are appended to
+ * each message as key=value, with group names prepended to keys as "group.",
+ * followed by trace_id and span_id if the context passed to the logger holds
+ * a trace; see SetTraceContextExtractor.
+ */
+type slogHandler struct {
+	attrs  []interface{}
+	prefix string
+}
+
+/*
+ * NewSlogHandler returns a slog.Handler that logs through gplog, e.g. for use
+ * with slog.New or slog.SetDefault.  gplog must be initialized before anything
+ * is logged; until then, messages are discarded.
+ */
+func NewSlogHandler() slog.Handler {
+	return &slogHandler{}
+}
+
+func slogVerbosity(level slog.Level) (int, bool) {
+	switch {
+	case level >= slog.LevelError:
+		return LOGERROR, false
+	case level >= slog.LevelWarn:
+		return LOGINFO, true
+	case level >= slog.LevelInfo:
+		return LOGINFO, false
+	}
+	return LOGDEBUG, false
+}
+
+func (handler *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	verbosity, _ := slogVerbosity(level)
+	return adapterLevelEnabled(verbosity)
+}
+
+func (handler *slogHandler) appendAttr(keysAndValues []interface{}, prefix string, attr slog.Attr) []interface{} {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return keysAndValues
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			keysAndValues = handler.appendAttr(keysAndValues, prefix, groupAttr)
+		}
+		return keysAndValues
+	}
+	return append(keysAndValues, prefix+attr.Key, attr.Value.String())
+}
+
+func (handler *slogHandler) Handle(ctx context.Context, record slog.Record) error {
+	keysAndValues := append([]interface{}{}, handler.attrs...)
+	record.Attrs(func(attr slog.Attr) bool {
+		keysAndValues = handler.appendAttr(keysAndValues, handler.prefix, attr)
+		return true
+	})
+	if traceID, spanID := extractTraceIDs(ctx); traceID != "" {
+		keysAndValues = append(keysAndValues, "trace_id", traceID)
+		if spanID != "" {
+			keysAndValues = append(keysAndValues, "span_id", spanID)
+		}
+	}
+	verbosity, isWarning := slogVerbosity(record.Level)
+	logFromAdapter(verbosity, isWarning, appendKeyValues(record.Message, keysAndValues))
+	return nil
+}
+
+func (handler *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	newAttrs := append([]interface{}{}, handler.attrs...)
+	for _, attr := range attrs {
+		newAttrs = handler.appendAttr(newAttrs, handler.prefix, attr)
+	}
+	return &slogHandler{attrs: newAttrs, prefix: handler.prefix}
+}
+
+func (handler *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return handler
+	}
+	return &slogHandler{attrs: handler.attrs, prefix: handler.prefix + name + "."}
+}
@@ -0,0 +1,55 @@
+//go:build go1.21
+
+package gplog_test
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gplog/slog tests", func() {
+	var (
+		capture *testhelper.LogCapture
+		logger  *slog.Logger
+	)
+	BeforeEach(func() {
+		capture = testhelper.SetupTestLogCapture()
+		gplog.SetErrorCode(0)
+		logger = slog.New(gplog.NewSlogHandler())
+	})
+	AfterEach(func() {
+		gplog.SetErrorCode(0)
+	})
+	Describe("NewSlogHandler", func() {
+		It("logs messages with attributes and groups", func() {
+			logger.With("server", "metrics").WithGroup("request").Info("Handled request", "path", "/status", slog.Group("response", "code", 200))
+
+			Expect(capture).To(testhelper.HaveLoggedInfo("Handled request server=metrics request.path=/status request.response.code=200"))
+		})
+		It("maps slog levels onto gplog levels", func() {
+			gplog.SetLogFileVerbosity(gplog.LOGINFO)
+
+			logger.Debug("debug message")
+			logger.Warn("warning message")
+			logger.Error("error message", "err", "timeout")
+
+			Expect(capture).ToNot(testhelper.HaveLoggedDebug("debug message"))
+			Expect(capture).To(testhelper.HaveLoggedWarn("warning message"))
+			Expect(capture).To(testhelper.HaveLoggedError("error message err=timeout"))
+			Expect(gplog.GetErrorCode()).To(Equal(0))
+			Expect(logger.Enabled(context.Background(), slog.LevelDebug)).To(BeFalse())
+		})
+		It("appends the trace and span IDs from the context", func() {
+			ctx := gplog.ContextWithTraceIDs(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+
+			logger.InfoContext(ctx, "Handled request", "path", "/status")
+
+			Expect(capture).To(testhelper.HaveLoggedInfo("Handled request path=/status trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7"))
+		})
+	})
+})
@@ -0,0 +1,150 @@
+package gplog
+
+/*
+ * This file contains structs and functions related to correlating log messages
+ * with distributed traces, e.g. ones collected by an OpenTelemetry collector.
+ */
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+/*
+ * A TraceContextExtractor returns the trace and span IDs of the span stored in
+ * ctx, or empty strings if there is none.  gplog does not depend on any
+ * tracing library, so callers using OpenTelemetry register an extractor like
+ * the following with SetTraceContextExtractor:
+ *
+ *	func(ctx context.Context) (string, string) {
+ *		spanContext := trace.SpanContextFromContext(ctx)
+ *		if !spanContext.IsValid() {
+ *			return "", ""
+ *		}
+ *		return spanContext.TraceID().String(), spanContext.SpanID().String()
+ *	}
+ */
+type TraceContextExtractor func(ctx context.Context) (traceID string, spanID string)
+
+type traceIDsKey struct{}
+
+type traceIDs struct {
+	traceID string
+	spanID  string
+}
+
+var (
+	traceExtractorMutex sync.Mutex
+	traceExtractor      TraceContextExtractor
+)
+
+/*
+ * SetTraceContextExtractor sets the function used to find the trace and span
+ * IDs in a context, or restores the default if extractor is nil.  The default
+ * only finds IDs stored with ContextWithTraceIDs.
+ */
+func SetTraceContextExtractor(extractor TraceContextExtractor) {
+	traceExtractorMutex.Lock()
+	defer traceExtractorMutex.Unlock()
+	traceExtractor = extractor
+}
+
+/*
+ * ContextWithTraceIDs returns a copy of ctx holding the given IDs, for callers
+ * that propagate trace IDs themselves rather than with a tracing library.
+ */
+func ContextWithTraceIDs(ctx context.Context, traceID string, spanID string) context.Context {
+	return context.WithValue(ctx, traceIDsKey{}, traceIDs{traceID: traceID, spanID: spanID})
+}
+
+func defaultTraceContextExtractor(ctx context.Context) (string, string) {
+	ids, _ := ctx.Value(traceIDsKey{}).(traceIDs)
+	return ids.traceID, ids.spanID
+}
+
+func extractTraceIDs(ctx context.Context) (string, string) {
+	if ctx == nil {
+		return "", ""
+	}
+	traceExtractorMutex.Lock()
+	extractor := traceExtractor
+	traceExtractorMutex.Unlock()
+	if extractor == nil {
+		extractor = defaultTraceContextExtractor
+	}
+	return extractor(ctx)
+}
+
+/*
+ * A TraceLogger logs messages like the package-level functions, or like a
+ * LogDomain if created with LogDomain.WithTraceContext, with the trace and
+ * span IDs of its context prepended to each message as e.g.
+ * "[trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7] ".
+ * If the context has no trace, messages are logged unchanged.
+ */
+type TraceLogger struct {
+	tag    string
+	domain *LogDomain
+}
+
+func traceTag(ctx context.Context) string {
+	traceID, spanID := extractTraceIDs(ctx)
+	if traceID == "" {
+		return ""
+	}
+	if spanID == "" {
+		return fmt.Sprintf("[trace_id=%s] ", traceID)
+	}
+	return fmt.Sprintf("[trace_id=%s span_id=%s] ", traceID, spanID)
+}
+
+// WithTraceContext returns a TraceLogger for the trace in ctx
+func WithTraceContext(ctx context.Context) TraceLogger {
+	return TraceLogger{tag: traceTag(ctx)}
+}
+
+// WithTraceContext returns a TraceLogger for the trace in ctx that logs to the domain
+func (domain LogDomain) WithTraceContext(ctx context.Context) TraceLogger {
+	return TraceLogger{tag: traceTag(ctx), domain: &domain}
+}
+
+func (traced TraceLogger) format(s string, v ...interface{}) string {
+	return traced.tag + fmt.Sprintf(s, v...)
+}
+
+func (traced TraceLogger) Info(s string, v ...interface{}) {
+	if traced.domain != nil {
+		traced.domain.Info("%s", traced.format(s, v...))
+		return
+	}
+	Info("%s", traced.format(s, v...))
+}
+
+func (traced TraceLogger) Success(s string, v ...interface{}) {
+	Success("%s", traced.format(s, v...))
+}
+
+func (traced TraceLogger) Verbose(s string, v ...interface{}) {
+	if traced.domain != nil {
+		traced.domain.Verbose("%s", traced.format(s, v...))
+		return
+	}
+	Verbose("%s", traced.format(s, v...))
+}
+
+func (traced TraceLogger) Debug(s string, v ...interface{}) {
+	if traced.domain != nil {
+		traced.domain.Debug("%s", traced.format(s, v...))
+		return
+	}
+	Debug("%s", traced.format(s, v...))
+}
+
+func (traced TraceLogger) Warn(s string, v ...interface{}) {
+	Warn("%s", traced.format(s, v...))
+}
+
+func (traced TraceLogger) Error(s string, v ...interface{}) {
+	Error("%s", traced.format(s, v...))
+}
@@ -0,0 +1,64 @@
+package gplog_test
+
+import (
+	"context"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("gplog/trace tests", func() {
+	var (
+		stdout  *gbytes.Buffer
+		stderr  *gbytes.Buffer
+		logfile *gbytes.Buffer
+		ctx     context.Context
+	)
+	BeforeEach(func() {
+		stdout, stderr, logfile = testhelper.SetupTestLogger()
+		ctx = gplog.ContextWithTraceIDs(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+	})
+	AfterEach(func() {
+		gplog.SetTraceContextExtractor(nil)
+		gplog.ResetDomainVerbosity("cluster")
+	})
+
+	Describe("WithTraceContext", func() {
+		It("prepends the trace and span IDs to each message", func() {
+			gplog.WithTraceContext(ctx).Info("Starting backup of %d tables", 3)
+			gplog.WithTraceContext(ctx).Warn("Table %s is empty", "public.foo")
+
+			testhelper.ExpectRegexp(stdout, "[INFO]:-[trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7] Starting backup of 3 tables")
+			testhelper.ExpectRegexp(logfile, "[INFO]:-[trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7] Starting backup of 3 tables")
+			testhelper.ExpectRegexp(stdout, "[WARNING]:-[trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7] Table public.foo is empty")
+		})
+		It("logs messages unchanged if the context has no trace", func() {
+			gplog.WithTraceContext(context.Background()).Error("Backup failed")
+
+			testhelper.ExpectRegexp(stderr, "[ERROR]:-Backup failed")
+		})
+		It("uses the extractor set with SetTraceContextExtractor", func() {
+			gplog.SetTraceContextExtractor(func(ctx context.Context) (string, string) {
+				return "0af7651916cd43dd8448eb211c80319c", ""
+			})
+
+			gplog.WithTraceContext(context.Background()).Info("Starting restore")
+
+			testhelper.ExpectRegexp(stdout, "[INFO]:-[trace_id=0af7651916cd43dd8448eb211c80319c] Starting restore")
+		})
+	})
+	Describe("LogDomain.WithTraceContext", func() {
+		It("uses the domain's verbosity", func() {
+			gplog.SetDomainVerbosity("cluster", gplog.LOGVERBOSE)
+
+			gplog.Domain("cluster").WithTraceContext(ctx).Verbose("Running command on 4 segments")
+			gplog.WithTraceContext(ctx).Verbose("Unrelated message")
+
+			testhelper.ExpectRegexp(stdout, "[DEBUG]:-[trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7] Running command on 4 segments")
+			Expect(stdout).ToNot(gbytes.Say("Unrelated message"))
+		})
+	})
+})
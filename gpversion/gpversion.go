@@ -0,0 +1,250 @@
+package gpversion
+
+/*
+ * This file contains structs and functions for parsing and comparing
+ * Greenplum and Cloudberry versions, as reported by "SELECT version()" or by
+ * "postgres --gp-version", without needing a database connection.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+const (
+	PRODUCT_GREENPLUM         = "Greenplum Database"
+	PRODUCT_CLOUDBERRY        = "Cloudberry Database"
+	PRODUCT_APACHE_CLOUDBERRY = "Apache Cloudberry"
+)
+
+/*
+ * VersionString is the version as the server reports it, e.g.
+ * "6.20.0 build commit:abc123", and SemVer is its three-digit release version,
+ * so that e.g. a GPDB 4.3.0.0 server has the SemVer 4.3.0.  The beta or
+ * release candidate suffix of a pre-release version, e.g. "beta.3" for
+ * 7.0.0-beta.3, is kept separately in Prerelease, and only Compare takes it
+ * into account; Before, AtLeast, Is, and Satisfies compare release versions,
+ * so that a 7.0.0 beta has all of the features of 7.0.0.
+ */
+type Version struct {
+	Product       string
+	VersionString string
+	SemVer        semver.Version
+	Prerelease    string
+	Build         string
+}
+
+var (
+	productPattern = regexp.MustCompile(`\(?(` + regexp.QuoteMeta(PRODUCT_GREENPLUM) + `|` + regexp.QuoteMeta(PRODUCT_CLOUDBERRY) + `|` + regexp.QuoteMeta(PRODUCT_APACHE_CLOUDBERRY) + `)\)?\s+([^)]*)`)
+	versionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?(?:\.\d+)*(?:[-_]?((?:alpha|beta|rc)[0-9.]*))?`)
+	buildPattern   = regexp.MustCompile(`\bbuild\s+(\S+)`)
+)
+
+/*
+ * Parse accepts the full output of "SELECT version()" or of
+ * "postgres --gp-version", just the portion of either following the product
+ * name, or a bare version such as "6.20.0" or "7.0.0-beta.3".
+ */
+func Parse(versionOutput string) (Version, error) {
+	version := Version{}
+	versionString := strings.TrimSpace(versionOutput)
+	if match := productPattern.FindStringSubmatch(versionString); match != nil {
+		version.Product = match[1]
+		versionString = strings.TrimSpace(match[2])
+	}
+	version.VersionString = versionString
+	match := versionPattern.FindStringSubmatch(versionString)
+	if match == nil {
+		return Version{}, errors.Errorf("Unable to parse version from %q", versionOutput)
+	}
+	patch := match[3]
+	if patch == "" {
+		patch = "0"
+	}
+	semVer, err := semver.Make(fmt.Sprintf("%s.%s.%s", match[1], match[2], patch))
+	if err != nil {
+		return Version{}, errors.Wrapf(err, "Unable to parse version from %q", versionOutput)
+	}
+	version.SemVer = semVer
+	version.Prerelease = strings.Trim(match[4], ".")
+	if buildMatch := buildPattern.FindStringSubmatch(versionString); buildMatch != nil {
+		version.Build = buildMatch[1]
+	}
+	return version, nil
+}
+
+/*
+ * New is intended as a convenience function for testing and setting defaults.
+ * Passing an invalid version is considered programmer error, so New panics
+ * instead of returning an error.
+ */
+func New(versionStr string) Version {
+	version, err := Parse(versionStr)
+	if err != nil {
+		panic(err)
+	}
+	return version
+}
+
+/*
+ * FromInstallation returns the version of the Greenplum installation in gphome
+ * by running its postgres binary, for utilities that need to check a version
+ * before a cluster is running or without connecting to it.
+ */
+func FromInstallation(gphome string) (Version, error) {
+	postgresPath := filepath.Join(gphome, "bin", "postgres")
+	output, err := operating.System.ExecCommand(postgresPath, "--gp-version").CombinedOutput()
+	if err != nil {
+		return Version{}, errors.Wrapf(err, "Unable to get version from %s: %s", postgresPath, strings.TrimSpace(string(output)))
+	}
+	return Parse(string(output))
+}
+
+func (version Version) String() string {
+	if version.VersionString != "" {
+		return version.VersionString
+	}
+	return version.releaseString()
+}
+
+// The release version with any pre-release suffix, e.g. "7.0.0-beta.3"
+func (version Version) releaseString() string {
+	if version.Prerelease == "" {
+		return version.SemVer.String()
+	}
+	return version.SemVer.String() + "-" + version.Prerelease
+}
+
+/*
+ * Compare returns -1, 0, or 1 if version is less than, equal to, or greater
+ * than other, treating a pre-release as less than its release, as in semantic
+ * versioning.  Builds are not compared.
+ */
+func (version Version) Compare(other Version) int {
+	versionSemVer, err := semver.Parse(version.releaseString())
+	if err != nil {
+		versionSemVer = version.SemVer
+	}
+	otherSemVer, err := semver.Parse(other.releaseString())
+	if err != nil {
+		otherSemVer = other.SemVer
+	}
+	return versionSemVer.Compare(otherSemVer)
+}
+
+func (version Version) Before(targetVersion string) bool {
+	return StringToRange("<" + targetVersion)(version.SemVer)
+}
+
+func (version Version) AtLeast(targetVersion string) bool {
+	return StringToRange(">=" + targetVersion)(version.SemVer)
+}
+
+func (version Version) Is(targetVersion string) bool {
+	return StringToRange("==" + targetVersion)(version.SemVer)
+}
+
+/*
+ * Satisfies reports whether version is in the range given by constraint, e.g.
+ * ">=6.20,<7" or "<6 || >=7.1".  As with Before and AtLeast, a version with
+ * fewer than three digits matches all versions with that prefix.
+ */
+func (version Version) Satisfies(constraint string) (bool, error) {
+	versionRange, err := ParseRange(constraint)
+	if err != nil {
+		return false, err
+	}
+	return versionRange(version.SemVer), nil
+}
+
+/*
+ * ParseRange converts a constraint as accepted by Satisfies to a
+ * semver.Range.  Comparisons within a constraint are separated by commas or
+ * spaces, and alternatives by "||".
+ */
+func ParseRange(constraint string) (semver.Range, error) {
+	alternatives := make([]string, 0)
+	for _, alternative := range strings.Split(constraint, "||") {
+		comparisons := strings.FieldsFunc(alternative, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(comparisons) == 0 {
+			return nil, errors.Errorf("Invalid version constraint %q", constraint)
+		}
+		for i, comparison := range comparisons {
+			comparisons[i] = expandComparison(comparison)
+		}
+		alternatives = append(alternatives, strings.Join(comparisons, " "))
+	}
+	versionRange, err := semver.ParseRange(strings.Join(alternatives, " || "))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid version constraint %q", constraint)
+	}
+	return versionRange, nil
+}
+
+// Versions with fewer than three digits are given a wildcard, e.g. "<7" becomes "<7.x"
+func expandComparison(comparison string) string {
+	versionStart := strings.IndexFunc(comparison, func(r rune) bool {
+		return r >= '0' && r <= '9'
+	})
+	if versionStart == -1 {
+		return comparison
+	}
+	operator, versionStr := comparison[:versionStart], comparison[versionStart:]
+	if operator == "" {
+		operator = "=="
+	}
+	if numDigits := len(strings.Split(versionStr, ".")); numDigits < 3 {
+		versionStr += ".x"
+	}
+	return operator + versionStr
+}
+
+/*
+ * StringToRange is as ParseRange, but panics if the constraint is invalid, as
+ * constraints are typically constants in the calling code.
+ */
+func StringToRange(versionStr string) semver.Range {
+	versionRange, err := ParseRange(versionStr)
+	if err != nil {
+		panic(err)
+	}
+	return versionRange
+}
+
+type versionJSON struct {
+	Product string `json:"product,omitempty"`
+	Version string `json:"version"`
+	Build   string `json:"build,omitempty"`
+}
+
+func (version Version) MarshalJSON() ([]byte, error) {
+	return json.Marshal(versionJSON{Product: version.Product, Version: version.releaseString(), Build: version.Build})
+}
+
+func (version *Version) UnmarshalJSON(data []byte) error {
+	parsed := versionJSON{}
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return err
+	}
+	versionString := parsed.Version
+	if parsed.Build != "" {
+		versionString += " build " + parsed.Build
+	}
+	result, err := Parse(versionString)
+	if err != nil {
+		return err
+	}
+	result.Product = parsed.Product
+	*version = result
+	return nil
+}
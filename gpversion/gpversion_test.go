@@ -0,0 +1,136 @@
+package gpversion_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/greenplum-db/gp-common-go-libs/gpversion"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGPVersion(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "gpversion tests")
+}
+
+var _ = Describe("gpversion tests", func() {
+	Describe("Parse", func() {
+		DescribeTable("parses version output",
+			func(output string, product string, versionString string, semVer string, prerelease string, build string) {
+				version, err := gpversion.Parse(output)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(version).To(Equal(gpversion.Version{
+					Product:       product,
+					VersionString: versionString,
+					SemVer:        semver.MustParse(semVer),
+					Prerelease:    prerelease,
+					Build:         build,
+				}))
+			},
+			Entry("from SELECT version() for GPDB 6",
+				"PostgreSQL 9.4.26 (Greenplum Database 6.20.0 build commit:abc123) on x86_64-unknown-linux-gnu, compiled by gcc",
+				gpversion.PRODUCT_GREENPLUM, "6.20.0 build commit:abc123", "6.20.0", "", "commit:abc123"),
+			Entry("from SELECT version() for a GPDB 7 beta",
+				"PostgreSQL 12.12 (Greenplum Database 7.0.0-beta.3 build dev) on x86_64-pc-linux-gnu",
+				gpversion.PRODUCT_GREENPLUM, "7.0.0-beta.3 build dev", "7.0.0", "beta.3", "dev"),
+			Entry("from SELECT version() for Cloudberry",
+				"PostgreSQL 14.4 (Cloudberry Database 1.5.0 build commit:def456) on x86_64-pc-linux-gnu",
+				gpversion.PRODUCT_CLOUDBERRY, "1.5.0 build commit:def456", "1.5.0", "", "commit:def456"),
+			Entry("from postgres --gp-version",
+				"postgres (Greenplum Database) 6.26.2 build commit:0123abc\n",
+				gpversion.PRODUCT_GREENPLUM, "6.26.2 build commit:0123abc", "6.26.2", "", "commit:0123abc"),
+			Entry("with four digits", "(Greenplum Database 4.3.0.0)", gpversion.PRODUCT_GREENPLUM, "4.3.0.0", "4.3.0", "", ""),
+			Entry("with a release candidate suffix", "5.0.0-rc1", "", "5.0.0-rc1", "5.0.0", "rc1", ""),
+			Entry("with two digits", "6.20", "", "6.20", "6.20.0", "", ""),
+		)
+		It("returns an error if there is no version", func() {
+			_, err := gpversion.Parse("PostgreSQL (Greenplum Database unknown)")
+			Expect(err).To(MatchError(`Unable to parse version from "PostgreSQL (Greenplum Database unknown)"`))
+		})
+	})
+	Describe("New", func() {
+		It("panics on an invalid version", func() {
+			defer testhelper.ShouldPanicWithMessage(`Unable to parse version from "six"`)
+			gpversion.New("six")
+		})
+	})
+	Describe("comparisons", func() {
+		v6 := gpversion.New("6.20.0")
+		v7beta := gpversion.New("7.0.0-beta.3")
+		v7 := gpversion.New("7.0.0")
+		It("compares release versions with Before, AtLeast, and Is", func() {
+			Expect(v6.Before("7")).To(BeTrue())
+			Expect(v6.AtLeast("6.20")).To(BeTrue())
+			Expect(v6.AtLeast("6.21")).To(BeFalse())
+			Expect(v6.Is("6")).To(BeTrue())
+			Expect(v7beta.AtLeast("7")).To(BeTrue())
+			Expect(v7beta.Is("7.0.0")).To(BeTrue())
+		})
+		It("orders pre-releases before their release with Compare", func() {
+			Expect(v6.Compare(v7beta)).To(Equal(-1))
+			Expect(v7beta.Compare(v7)).To(Equal(-1))
+			Expect(v7.Compare(v7beta)).To(Equal(1))
+			Expect(v7.Compare(gpversion.New("7.0.0 build dev"))).To(Equal(0))
+			Expect(gpversion.New("7.0.0-beta.2").Compare(v7beta)).To(Equal(-1))
+		})
+		DescribeTable("checks range constraints with Satisfies",
+			func(version gpversion.Version, constraint string, expected bool) {
+				result, err := version.Satisfies(constraint)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(expected))
+			},
+			Entry("within an open range", v6, ">=6.20,<7", true),
+			Entry("above an open range", v7, ">=6.20,<7", false),
+			Entry("with spaces between comparisons", v6, ">=6.20 <7", true),
+			Entry("with alternatives", v7, "<6 || >=7", true),
+			Entry("with no operator", v6, "6", true),
+			Entry("with a full version", v6, "<=6.20.0", true),
+		)
+		It("returns an error for an invalid constraint", func() {
+			_, err := v6.Satisfies(">=six")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix(`Invalid version constraint ">=six"`))
+			_, err = v6.Satisfies(">=6 || ")
+			Expect(err).To(MatchError(`Invalid version constraint ">=6 || "`))
+		})
+	})
+	Describe("JSON", func() {
+		It("marshals and unmarshals a version", func() {
+			version, err := gpversion.Parse("PostgreSQL 12.12 (Greenplum Database 7.0.0-beta.3 build dev) on x86_64-pc-linux-gnu")
+			Expect(err).ToNot(HaveOccurred())
+			data, err := json.Marshal(version)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(`{"product":"Greenplum Database","version":"7.0.0-beta.3","build":"dev"}`))
+			result := gpversion.Version{}
+			Expect(json.Unmarshal(data, &result)).To(Succeed())
+			Expect(result).To(Equal(version))
+		})
+		It("returns an error for an invalid version", func() {
+			result := gpversion.Version{}
+			err := json.Unmarshal([]byte(`{"version":"unknown"}`), &result)
+			Expect(err).To(MatchError(`Unable to parse version from "unknown"`))
+		})
+	})
+	Describe("FromInstallation", func() {
+		AfterEach(func() {
+			operating.System = operating.InitializeSystemFunctions()
+		})
+		It("parses the output of postgres --gp-version", func() {
+			runner := testhelper.MockExecCommand("postgres (Greenplum Database) 6.26.2 build commit:0123abc\n", "", 0)
+			version, err := gpversion.FromInstallation("/usr/local/greenplum-db")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(version.SemVer).To(Equal(semver.MustParse("6.26.2")))
+			Expect(runner.Commands).To(Equal([][]string{{"/usr/local/greenplum-db/bin/postgres", "--gp-version"}}))
+		})
+		It("returns an error if postgres cannot be run", func() {
+			testhelper.MockExecCommand("", "error while loading shared libraries", 127)
+			_, err := gpversion.FromInstallation("/usr/local/greenplum-db")
+			Expect(err).To(MatchError("Unable to get version from /usr/local/greenplum-db/bin/postgres: error while loading shared libraries: exit status 127"))
+		})
+	})
+})
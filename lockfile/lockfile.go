@@ -0,0 +1,311 @@
+package lockfile
+
+/*
+ * This file contains structs and functions for preventing utilities that
+ * must not run at the same time (e.g. an expansion and a backup) from running
+ * concurrently against the same cluster, using lock files in the coordinator
+ * data directory.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A LockRecord is the content of a lock file, identifying the process that
+ * holds the lock so that other utilities can report who holds it and whether
+ * it has been left behind by a process that has died.
+ */
+type LockRecord struct {
+	Name      string    `json:"name"`
+	Program   string    `json:"program"`
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (record LockRecord) String() string {
+	return fmt.Sprintf("%s (pid %d on host %s) since %s", record.Program, record.PID, record.Host, record.Timestamp.Format("20060102:15:04:05"))
+}
+
+// Timestamps are compared with Equal, as they lose their location when written to the lock file
+func (record LockRecord) sameAs(other LockRecord) bool {
+	return record.Name == other.Name && record.Program == other.Program && record.PID == other.PID &&
+		record.Host == other.Host && record.Timestamp.Equal(other.Timestamp)
+}
+
+/*
+ * A LockHeldError is returned when a lock cannot be acquired because it, or a
+ * lock that conflicts with it, is held by a live process or by a process on
+ * another host, whose liveness cannot be checked.
+ */
+type LockHeldError struct {
+	Name   string
+	Holder LockRecord
+}
+
+func (err *LockHeldError) Error() string {
+	if err.Holder.Name != err.Name {
+		return fmt.Sprintf("Cannot acquire lock %s; the conflicting lock %s is held by %s", err.Name, err.Holder.Name, err.Holder)
+	}
+	return fmt.Sprintf("Cannot acquire lock %s; it is held by %s", err.Name, err.Holder)
+}
+
+/*
+ * A ClusterLock is a lock acquired with AcquireClusterLock, which is held
+ * until Close is called.  Close may be called more than once and on a nil
+ * ClusterLock, so it is safe to defer immediately after acquiring the lock
+ * and also to call explicitly.
+ */
+type ClusterLock struct {
+	Record   LockRecord
+	path     string
+	mutex    sync.Mutex
+	released bool
+}
+
+func lockPath(coordinatorDataDir string, name string) string {
+	return filepath.Join(coordinatorDataDir, fmt.Sprintf(".%s.lock", name))
+}
+
+func validateName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return errors.Errorf(`Invalid lock name "%s"`, name)
+	}
+	return nil
+}
+
+/*
+ * AcquireClusterLock acquires the named lock in the coordinator data directory,
+ * e.g. AcquireClusterLock(dataDir, "gpexpand", "gpbackup") for a utility that
+ * must not run while gpexpand or gpbackup is running.  It fails with a
+ * LockHeldError without waiting if the lock or any of the conflicting locks
+ * is held.
+ *
+ * A lock left behind by a process on this host that is no longer running is
+ * removed with a warning.  A lock held by a process on another host, e.g. a
+ * utility run on the standby coordinator host against a shared directory, is
+ * assumed to be live and must be removed manually if it is not.
+ *
+ * The lock itself is acquired before the conflicting locks are checked, so if
+ * two utilities that conflict with each other start at the same time, at
+ * least one of them fails rather than both succeeding.
+ */
+func AcquireClusterLock(coordinatorDataDir string, name string, conflicts ...string) (*ClusterLock, error) {
+	for _, lockName := range append([]string{name}, conflicts...) {
+		if err := validateName(lockName); err != nil {
+			return nil, err
+		}
+	}
+	record, err := newLockRecord(name)
+	if err != nil {
+		return nil, err
+	}
+	lock := &ClusterLock{Record: record, path: lockPath(coordinatorDataDir, name)}
+	if err := createLockFile(lock.path, record); err != nil {
+		return nil, err
+	}
+	for _, conflict := range conflicts {
+		holder, held, err := readLiveLock(lockPath(coordinatorDataDir, conflict))
+		if err == nil && held {
+			err = &LockHeldError{Name: name, Holder: holder}
+		}
+		if err != nil {
+			_ = lock.Close()
+			return nil, err
+		}
+	}
+	gplog.Verbose("Acquired lock %s in %s", name, coordinatorDataDir)
+	return lock, nil
+}
+
+func MustAcquireClusterLock(coordinatorDataDir string, name string, conflicts ...string) *ClusterLock {
+	lock, err := AcquireClusterLock(coordinatorDataDir, name, conflicts...)
+	gplog.FatalOnError(err)
+	return lock
+}
+
+/*
+ * WithClusterLock acquires the lock as AcquireClusterLock does, calls fn, and
+ * releases the lock, even if fn panics (e.g. via gplog.Fatal).  The error from
+ * fn is returned, or the error from releasing the lock if fn succeeds.
+ */
+func WithClusterLock(coordinatorDataDir string, name string, conflicts []string, fn func() error) (err error) {
+	lock, err := AcquireClusterLock(coordinatorDataDir, name, conflicts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := lock.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	return fn()
+}
+
+/*
+ * ReadClusterLock returns the record of the named lock and whether it is held.
+ * A lock left behind by a dead process on this host is reported as not held,
+ * but is not removed.
+ */
+func ReadClusterLock(coordinatorDataDir string, name string) (LockRecord, bool, error) {
+	if err := validateName(name); err != nil {
+		return LockRecord{}, false, err
+	}
+	record, err := readLockFile(lockPath(coordinatorDataDir, name))
+	if err != nil || record == nil {
+		return LockRecord{}, false, err
+	}
+	held, err := isLive(*record)
+	return *record, held, err
+}
+
+/*
+ * Close releases the lock by removing its lock file.  The file is only
+ * removed if it still holds this lock's record, so that a lock that was
+ * removed as stale and then acquired by another process is not released.
+ */
+func (lock *ClusterLock) Close() error {
+	if lock == nil {
+		return nil
+	}
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	if lock.released {
+		return nil
+	}
+	lock.released = true
+	record, err := readLockFile(lock.path)
+	if err != nil {
+		return err
+	}
+	if record == nil || !record.sameAs(lock.Record) {
+		gplog.Warn("Lock %s was removed by another process before it was released", lock.Record.Name)
+		return nil
+	}
+	if err := operating.System.Remove(lock.path); err != nil {
+		return errors.Wrapf(err, "Unable to remove lock file %s", lock.path)
+	}
+	gplog.Verbose("Released lock %s", lock.Record.Name)
+	return nil
+}
+
+func newLockRecord(name string) (LockRecord, error) {
+	host, err := operating.System.Hostname()
+	if err != nil {
+		return LockRecord{}, errors.Wrap(err, "Unable to determine hostname for lock")
+	}
+	return LockRecord{
+		Name:      name,
+		Program:   filepath.Base(os.Args[0]),
+		PID:       operating.System.Getpid(),
+		Host:      host,
+		Timestamp: operating.System.Now(),
+	}, nil
+}
+
+/*
+ * The record is written to a temporary file that is then hard linked to the
+ * lock path, which fails if the lock file already exists, so that other
+ * processes never see a partially written lock file.  If the existing lock
+ * is stale, it is removed and creation is retried once.
+ */
+func createLockFile(path string, record LockRecord) error {
+	contents, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	tempFile, err := operating.System.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "Unable to create lock file %s", path)
+	}
+	defer func() { _ = operating.System.Remove(tempFile.Name()) }()
+	_, err = tempFile.Write(contents)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write lock file %s", path)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		err = operating.System.Link(tempFile.Name(), path)
+		if err == nil {
+			return nil
+		}
+		if !os.IsExist(err) {
+			return errors.Wrapf(err, "Unable to create lock file %s", path)
+		}
+		holder, held, err := readLiveLock(path)
+		if err != nil {
+			return err
+		}
+		if held {
+			return &LockHeldError{Name: record.Name, Holder: holder}
+		}
+	}
+	return errors.Errorf("Unable to create lock file %s; it was recreated by another process", path)
+}
+
+/*
+ * Returns the record in the lock file and whether it is held by a live
+ * process.  A stale lock file is removed, unless it has been replaced since it
+ * was read.
+ */
+func readLiveLock(path string) (LockRecord, bool, error) {
+	record, err := readLockFile(path)
+	if err != nil || record == nil {
+		return LockRecord{}, false, err
+	}
+	live, err := isLive(*record)
+	if err != nil || live {
+		return *record, live, err
+	}
+	current, err := readLockFile(path)
+	if err != nil || current == nil || !current.sameAs(*record) {
+		return LockRecord{}, false, err
+	}
+	gplog.Warn("Removing stale lock %s held by %s, which is no longer running", record.Name, *record)
+	if err := operating.System.Remove(path); err != nil && !operating.System.IsNotExist(err) {
+		return LockRecord{}, false, errors.Wrapf(err, "Unable to remove stale lock file %s", path)
+	}
+	return *record, false, nil
+}
+
+// Returns nil if the lock file does not exist
+func readLockFile(path string) (*LockRecord, error) {
+	contents, err := operating.System.ReadFile(path)
+	if err != nil {
+		if operating.System.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Unable to read lock file %s", path)
+	}
+	record := &LockRecord{}
+	if err := json.Unmarshal(contents, record); err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse lock file %s; remove it if no utility is running", path)
+	}
+	return record, nil
+}
+
+func isLive(record LockRecord) (bool, error) {
+	host, err := operating.System.Hostname()
+	if err != nil {
+		return false, errors.Wrap(err, "Unable to determine hostname for lock")
+	}
+	if record.Host != host {
+		return true, nil
+	}
+	return processExists(record.PID), nil
+}
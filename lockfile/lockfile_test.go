@@ -0,0 +1,189 @@
+package lockfile_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/lockfile"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestLockFile(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "lockfile tests")
+}
+
+var _ = Describe("lockfile tests", func() {
+	var (
+		dataDir    string
+		logCapture *testhelper.LogCapture
+	)
+	writeLockFile := func(name string, pid int, host string) {
+		record := lockfile.LockRecord{Name: name, Program: name, PID: pid, Host: host, Timestamp: time.Date(2017, time.January, 1, 1, 1, 1, 0, time.Local)}
+		contents, err := json.Marshal(record)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dataDir, "."+name+".lock"), contents, 0600)).To(Succeed())
+	}
+	lockFileExists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dataDir, "."+name+".lock"))
+		return err == nil
+	}
+
+	BeforeEach(func() {
+		dataDir = GinkgoT().TempDir()
+		logCapture = testhelper.SetupTestLogCapture()
+		operating.System.Hostname = func() (string, error) { return "cdw", nil }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("AcquireClusterLock", func() {
+		It("writes a lock record identifying this process", func() {
+			lock, err := lockfile.AcquireClusterLock(dataDir, "gpexpand")
+			Expect(err).ToNot(HaveOccurred())
+			defer lock.Close()
+
+			record, held, err := lockfile.ReadClusterLock(dataDir, "gpexpand")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(held).To(BeTrue())
+			Expect(record.PID).To(Equal(os.Getpid()))
+			Expect(record.Host).To(Equal("cdw"))
+			Expect(record.Timestamp.Equal(lock.Record.Timestamp)).To(BeTrue())
+		})
+		It("refuses a lock that is already held", func() {
+			lock := lockfile.MustAcquireClusterLock(dataDir, "gpexpand")
+			defer lock.Close()
+
+			_, err := lockfile.AcquireClusterLock(dataDir, "gpexpand")
+
+			var heldErr *lockfile.LockHeldError
+			Expect(errors.As(err, &heldErr)).To(BeTrue())
+			Expect(heldErr.Holder.PID).To(Equal(os.Getpid()))
+			Expect(err.Error()).To(HavePrefix("Cannot acquire lock gpexpand; it is held by"))
+		})
+		It("refuses a lock while a conflicting lock is held, and does not leave its own lock behind", func() {
+			writeLockFile("gpbackup", os.Getpid(), "cdw")
+
+			_, err := lockfile.AcquireClusterLock(dataDir, "gpexpand", "gpbackup")
+
+			Expect(err).To(MatchError(HavePrefix("Cannot acquire lock gpexpand; the conflicting lock gpbackup is held by gpbackup (pid")))
+			Expect(lockFileExists("gpexpand")).To(BeFalse())
+			Expect(lockFileExists("gpbackup")).To(BeTrue())
+		})
+		It("removes a stale lock left by a process that is no longer running", func() {
+			operating.System.Signal = func(process *os.Process, sig os.Signal) error { return os.ErrProcessDone }
+			writeLockFile("gpexpand", 999999, "cdw")
+
+			lock, err := lockfile.AcquireClusterLock(dataDir, "gpexpand")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lock.Record.PID).To(Equal(os.Getpid()))
+			Expect(logCapture).To(testhelper.HaveLoggedWarn("Removing stale lock gpexpand held by gpexpand (pid 999999 on host cdw)"))
+		})
+		It("treats a lock held on another host as live", func() {
+			operating.System.Signal = func(process *os.Process, sig os.Signal) error { return os.ErrProcessDone }
+			writeLockFile("gpexpand", 999999, "scdw")
+
+			_, err := lockfile.AcquireClusterLock(dataDir, "gpexpand")
+
+			Expect(err).To(MatchError(HavePrefix("Cannot acquire lock gpexpand; it is held by gpexpand (pid 999999 on host scdw)")))
+		})
+		It("returns an error if the lock file cannot be linked into place, and removes its temporary file", func() {
+			operating.System.Link = func(oldname string, newname string) error {
+				return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EPERM}
+			}
+
+			_, err := lockfile.AcquireClusterLock(dataDir, "gpexpand")
+
+			Expect(err).To(MatchError(ContainSubstring("Unable to create lock file " + filepath.Join(dataDir, ".gpexpand.lock"))))
+			entries, err := os.ReadDir(dataDir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+		It("retries once if the lock file exists but is gone by the time it is read", func() {
+			numLinks := 0
+			operating.System.Link = func(oldname string, newname string) error {
+				numLinks++
+				if numLinks == 1 {
+					return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrExist}
+				}
+				return os.Link(oldname, newname)
+			}
+
+			lock, err := lockfile.AcquireClusterLock(dataDir, "gpexpand")
+
+			Expect(err).ToNot(HaveOccurred())
+			defer lock.Close()
+			Expect(numLinks).To(Equal(2))
+			Expect(lockFileExists("gpexpand")).To(BeTrue())
+		})
+		It("gives up if the lock file keeps being recreated", func() {
+			operating.System.Link = func(oldname string, newname string) error {
+				return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrExist}
+			}
+
+			_, err := lockfile.AcquireClusterLock(dataDir, "gpexpand")
+
+			Expect(err).To(MatchError("Unable to create lock file " + filepath.Join(dataDir, ".gpexpand.lock") + "; it was recreated by another process"))
+		})
+		It("returns an error for an invalid lock name", func() {
+			_, err := lockfile.AcquireClusterLock(dataDir, "../gpexpand")
+
+			Expect(err).To(MatchError(`Invalid lock name "../gpexpand"`))
+		})
+	})
+	Describe("ClusterLock.Close", func() {
+		It("releases the lock and may be called more than once", func() {
+			lock := lockfile.MustAcquireClusterLock(dataDir, "gpexpand")
+
+			Expect(lock.Close()).To(Succeed())
+			Expect(lock.Close()).To(Succeed())
+
+			Expect(lockFileExists("gpexpand")).To(BeFalse())
+		})
+		It("does not remove a lock acquired by another process", func() {
+			lock := lockfile.MustAcquireClusterLock(dataDir, "gpexpand")
+			writeLockFile("gpexpand", 5678, "cdw")
+
+			Expect(lock.Close()).To(Succeed())
+
+			Expect(lockFileExists("gpexpand")).To(BeTrue())
+			Expect(logCapture).To(testhelper.HaveLoggedWarn("Lock gpexpand was removed by another process before it was released"))
+		})
+		It("does nothing for a nil lock", func() {
+			var lock *lockfile.ClusterLock
+			Expect(lock.Close()).To(Succeed())
+		})
+	})
+	Describe("WithClusterLock", func() {
+		It("holds the lock while calling the function", func() {
+			err := lockfile.WithClusterLock(dataDir, "gpexpand", nil, func() error {
+				_, held, err := lockfile.ReadClusterLock(dataDir, "gpexpand")
+				Expect(held).To(BeTrue())
+				return err
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lockFileExists("gpexpand")).To(BeFalse())
+		})
+		It("releases the lock if the function panics", func() {
+			Expect(func() {
+				_ = lockfile.WithClusterLock(dataDir, "gpexpand", nil, func() error {
+					panic("fatal error")
+				})
+			}).To(PanicWith("fatal error"))
+
+			Expect(lockFileExists("gpexpand")).To(BeFalse())
+		})
+	})
+})
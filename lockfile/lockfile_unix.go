@@ -0,0 +1,23 @@
+//go:build linux || darwin
+
+package lockfile
+
+import (
+	"syscall"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Signal 0 checks whether a process exists without affecting it.  EPERM means
+ * that the process exists but belongs to another user.
+ */
+func processExists(pid int) bool {
+	process, err := operating.System.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = operating.System.Signal(process, syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -6,11 +6,16 @@ package operating
  */
 
 import (
+	"context"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"os/signal"
 	"os/user"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -41,6 +46,97 @@ func OpenFileWrite(name string, flag int, perm os.FileMode) (io.WriteCloser, err
 	return writer, err
 }
 
+/*
+ * Process signaling is done through methods on *os.Process, so these functions
+ * wrap those methods to allow them to be mocked out as well.
+ */
+
+func Signal(process *os.Process, sig os.Signal) error {
+	return process.Signal(sig)
+}
+
+func Kill(process *os.Process) error {
+	return process.Kill()
+}
+
+/*
+ * NotifySignals relays the given signals to the returned channel, as
+ * signal.Notify does, until the returned function is called to stop relaying
+ * them.  The channel is buffered, so a signal arriving while the receiver is
+ * busy is not lost.
+ */
+func NotifySignals(signals ...os.Signal) (<-chan os.Signal, func()) {
+	channel := make(chan os.Signal, 1)
+	signal.Notify(channel, signals...)
+	return channel, func() { signal.Stop(channel) }
+}
+
+/*
+ * Structs and functions for mocking out the environment
+ */
+
+/*
+ * Environment returns a snapshot of the current environment, as seen through
+ * System.Environ, as a map of variable names to values.
+ */
+func Environment() map[string]string {
+	env := make(map[string]string)
+	for _, keyValue := range System.Environ() {
+		if key, value, found := strings.Cut(keyValue, "="); found {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+/*
+ * WithEnv runs fn with the given variables overriding the environment as seen
+ * through System.Getenv, System.LookupEnv, and System.Environ, then restores the
+ * previous functions.  The real process environment is never modified, so this
+ * is safe to use in tests that run in parallel processes, but it is not safe to
+ * call while other goroutines are reading the environment through System.
+ *
+ * An override with an empty value is treated as set to an empty string, not as
+ * unset.  Calls to WithEnv may be nested, with inner overrides taking priority.
+ */
+func WithEnv(env map[string]string, fn func()) {
+	oldGetenv, oldLookupEnv, oldEnviron := System.Getenv, System.LookupEnv, System.Environ
+	defer func() {
+		System.Getenv, System.LookupEnv, System.Environ = oldGetenv, oldLookupEnv, oldEnviron
+	}()
+
+	lookupEnv := func(key string) (string, bool) {
+		if value, ok := env[key]; ok {
+			return value, true
+		}
+		return oldLookupEnv(key)
+	}
+	System.LookupEnv = lookupEnv
+	System.Getenv = func(key string) string {
+		value, _ := lookupEnv(key)
+		return value
+	}
+	System.Environ = func() []string {
+		environ := make([]string, 0)
+		for _, keyValue := range oldEnviron() {
+			key, _, _ := strings.Cut(keyValue, "=")
+			if _, ok := env[key]; !ok {
+				environ = append(environ, keyValue)
+			}
+		}
+		keys := make([]string, 0, len(env))
+		for key := range env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			environ = append(environ, key+"="+env[key])
+		}
+		return environ
+	}
+	fn()
+}
+
 /*
  * SystemFunctions holds function pointers for built-in functions that will need
  * to be mocked out for unit testing.  All built-in functions manipulating the
@@ -51,55 +147,88 @@ func OpenFileWrite(name string, flag int, perm os.FileMode) (io.WriteCloser, err
  * All function pointers in SystemFunctions refer directly to built-in functions
  * except for OpenFileRead and OpenFileWrite, which both refer to os.OpenFile but
  * return either an io.ReadCloser or io.WriteCloser instead of an *os.File, to make
- * mocking file opening in tests easier.
+ * mocking file opening in tests easier, Signal and Kill, which wrap the
+ * corresponding *os.Process methods, NotifySignals, which wraps signal.Notify
+ * so that tests can deliver signals on their own channel, and the resource
+ * functions DiskFree, MemInfo, LoadAvg, and NumCPU defined in resources.go.
  */
 
 type SystemFunctions struct {
-	Chmod         func(name string, mode os.FileMode) error
-	CurrentUser   func() (*user.User, error)
-	Exit          func(code int)
-	Getenv        func(key string) string
-	Getpid        func() int
-	Glob          func(pattern string) (matches []string, err error)
-	Hostname      func() (string, error)
-	IsNotExist    func(err error) bool
-	LookupEnv     func(key string) (string, bool)
-	MkdirAll      func(path string, perm os.FileMode) error
-	Now           func() time.Time
-	OpenFileRead  func(name string, flag int, perm os.FileMode) (ReadCloserAt, error)
-	OpenFileWrite func(name string, flag int, perm os.FileMode) (io.WriteCloser, error)
-	ReadFile      func(filename string) ([]byte, error)
-	Remove        func(name string) error
-	RemoveAll     func(name string) error
-	Stat          func(name string) (os.FileInfo, error)
-	Stdin         ReadCloserAt
-	Stdout        io.WriteCloser
-	TempFile      func(dir, pattern string) (f *os.File, err error)
-	Local         *time.Location
+	After              func(d time.Duration) <-chan time.Time
+	Chmod              func(name string, mode os.FileMode) error
+	CurrentUser        func() (*user.User, error)
+	DiskFree           func(path string) (DiskUsage, error)
+	Environ            func() []string
+	ExecCommand        func(name string, arg ...string) *exec.Cmd
+	ExecCommandContext func(ctx context.Context, name string, arg ...string) *exec.Cmd
+	Exit               func(code int)
+	FindProcess        func(pid int) (*os.Process, error)
+	Getenv             func(key string) string
+	Getpid             func() int
+	Glob               func(pattern string) (matches []string, err error)
+	Hostname           func() (string, error)
+	IsNotExist         func(err error) bool
+	Kill               func(process *os.Process) error
+	Link               func(oldname string, newname string) error
+	LoadAvg            func() (LoadAverage, error)
+	LookupEnv          func(key string) (string, bool)
+	MemInfo            func() (MemoryInfo, error)
+	MkdirAll           func(path string, perm os.FileMode) error
+	Now                func() time.Time
+	NotifySignals      func(signals ...os.Signal) (<-chan os.Signal, func())
+	NumCPU             func() int
+	OpenFileRead       func(name string, flag int, perm os.FileMode) (ReadCloserAt, error)
+	OpenFileWrite      func(name string, flag int, perm os.FileMode) (io.WriteCloser, error)
+	ReadFile           func(filename string) ([]byte, error)
+	Remove             func(name string) error
+	RemoveAll          func(name string) error
+	Setenv             func(key string, value string) error
+	Signal             func(process *os.Process, sig os.Signal) error
+	StartProcess       func(name string, argv []string, attr *os.ProcAttr) (*os.Process, error)
+	Stat               func(name string) (os.FileInfo, error)
+	Stdin              ReadCloserAt
+	Stdout             io.WriteCloser
+	TempFile           func(dir, pattern string) (f *os.File, err error)
+	Local              *time.Location
 }
 
 func InitializeSystemFunctions() *SystemFunctions {
 	return &SystemFunctions{
-		Chmod:         os.Chmod,
-		CurrentUser:   user.Current,
-		Exit:          os.Exit,
-		Getenv:        os.Getenv,
-		Getpid:        os.Getpid,
-		Glob:          filepath.Glob,
-		Hostname:      os.Hostname,
-		IsNotExist:    os.IsNotExist,
-		MkdirAll:      os.MkdirAll,
-		LookupEnv:     os.LookupEnv,
-		Now:           time.Now,
-		OpenFileRead:  OpenFileRead,
-		OpenFileWrite: OpenFileWrite,
-		ReadFile:      ioutil.ReadFile,
-		Remove:        os.Remove,
-		RemoveAll:     os.RemoveAll,
-		Stat:          os.Stat,
-		Stdin:         os.Stdin,
-		Stdout:        os.Stdout,
-		TempFile:      ioutil.TempFile,
-		Local:         time.Local,
+		After:              time.After,
+		Chmod:              os.Chmod,
+		CurrentUser:        user.Current,
+		DiskFree:           DiskFree,
+		Environ:            os.Environ,
+		ExecCommand:        exec.Command,
+		ExecCommandContext: exec.CommandContext,
+		Exit:               os.Exit,
+		FindProcess:        os.FindProcess,
+		Getenv:             os.Getenv,
+		Getpid:             os.Getpid,
+		Glob:               filepath.Glob,
+		Hostname:           os.Hostname,
+		IsNotExist:         os.IsNotExist,
+		Kill:               Kill,
+		Link:               os.Link,
+		LoadAvg:            LoadAvg,
+		MemInfo:            MemInfo,
+		MkdirAll:           os.MkdirAll,
+		LookupEnv:          os.LookupEnv,
+		Now:                time.Now,
+		NotifySignals:      NotifySignals,
+		NumCPU:             NumCPU,
+		OpenFileRead:       OpenFileRead,
+		OpenFileWrite:      OpenFileWrite,
+		ReadFile:           ioutil.ReadFile,
+		Remove:             os.Remove,
+		RemoveAll:          os.RemoveAll,
+		Setenv:             os.Setenv,
+		Signal:             Signal,
+		StartProcess:       os.StartProcess,
+		Stat:               os.Stat,
+		Stdin:              os.Stdin,
+		Stdout:             os.Stdout,
+		TempFile:           ioutil.TempFile,
+		Local:              time.Local,
 	}
 }
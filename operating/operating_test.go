@@ -0,0 +1,94 @@
+package operating_test
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestOperating(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "operating tests")
+}
+
+var _ = Describe("operating/operating tests", func() {
+	BeforeEach(func() {
+		operating.System.Environ = func() []string { return []string{"PGUSER=gpadmin", "PGPORT=5432", "HOME=/home/gpadmin"} }
+		operating.System.LookupEnv = func(key string) (string, bool) {
+			switch key {
+			case "PGUSER":
+				return "gpadmin", true
+			case "PGPORT":
+				return "5432", true
+			}
+			return "", false
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("Environment", func() {
+		It("returns the environment as a map", func() {
+			Expect(operating.Environment()).To(Equal(map[string]string{"PGUSER": "gpadmin", "PGPORT": "5432", "HOME": "/home/gpadmin"}))
+		})
+		It("keeps everything after the first equals sign in the value", func() {
+			operating.System.Environ = func() []string { return []string{"PGOPTIONS=-c search_path=public"} }
+			Expect(operating.Environment()).To(Equal(map[string]string{"PGOPTIONS": "-c search_path=public"}))
+		})
+	})
+	Describe("WithEnv", func() {
+		It("overrides and adds variables for the duration of the function", func() {
+			operating.WithEnv(map[string]string{"PGUSER": "testuser", "PGHOST": "testhost"}, func() {
+				Expect(operating.System.Getenv("PGUSER")).To(Equal("testuser"))
+				Expect(operating.System.Getenv("PGHOST")).To(Equal("testhost"))
+				Expect(operating.System.Getenv("PGPORT")).To(Equal("5432"))
+				Expect(operating.Environment()).To(Equal(map[string]string{"PGUSER": "testuser", "PGHOST": "testhost", "PGPORT": "5432", "HOME": "/home/gpadmin"}))
+			})
+		})
+		It("treats an empty override as set to an empty string", func() {
+			operating.WithEnv(map[string]string{"PGUSER": ""}, func() {
+				value, ok := operating.System.LookupEnv("PGUSER")
+				Expect(ok).To(BeTrue())
+				Expect(value).To(Equal(""))
+			})
+		})
+		It("gives priority to inner overrides when nested", func() {
+			operating.WithEnv(map[string]string{"PGUSER": "outer", "PGHOST": "outerhost"}, func() {
+				operating.WithEnv(map[string]string{"PGUSER": "inner"}, func() {
+					Expect(operating.System.Getenv("PGUSER")).To(Equal("inner"))
+					Expect(operating.System.Getenv("PGHOST")).To(Equal("outerhost"))
+				})
+				Expect(operating.System.Getenv("PGUSER")).To(Equal("outer"))
+			})
+		})
+		It("restores the environment functions afterwards, even if the function panics", func() {
+			func() {
+				defer func() { _ = recover() }()
+				operating.WithEnv(map[string]string{"PGUSER": "testuser"}, func() {
+					panic("some panic")
+				})
+			}()
+			value, ok := operating.System.LookupEnv("PGUSER")
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("gpadmin"))
+			Expect(operating.Environment()).To(HaveKeyWithValue("PGUSER", "gpadmin"))
+		})
+	})
+	Describe("NotifySignals", func() {
+		It("relays signals to the channel until stopped", func() {
+			signals, stop := operating.NotifySignals(syscall.SIGHUP)
+			defer stop()
+
+			process, err := os.FindProcess(os.Getpid())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(process.Signal(syscall.SIGHUP)).To(Succeed())
+
+			Eventually(signals).Should(Receive(Equal(syscall.SIGHUP)))
+		})
+	})
+})
@@ -0,0 +1,111 @@
+package operating
+
+/*
+ * This file contains structs and functions for querying the disk, memory, and
+ * CPU resources of the current host.  The memory and load functions read from
+ * /proc and so are only supported on Linux; the parsing functions are exported
+ * separately so that the same files can be parsed after being read from remote
+ * hosts.
+ */
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// All sizes are in bytes.
+type DiskUsage struct {
+	Total     uint64
+	Free      uint64
+	Available uint64 // Free space available to unprivileged users
+}
+
+type MemoryInfo struct {
+	Total     uint64
+	Free      uint64
+	Available uint64 // Estimate of memory available without swapping, per the kernel
+	SwapTotal uint64
+	SwapFree  uint64
+}
+
+type LoadAverage struct {
+	One     float64
+	Five    float64
+	Fifteen float64
+}
+
+func MemInfo() (MemoryInfo, error) {
+	contents, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return MemoryInfo{}, errors.Wrap(err, "Unable to read memory information")
+	}
+	return ParseMemInfo(contents)
+}
+
+func ParseMemInfo(contents []byte) (MemoryInfo, error) {
+	info := MemoryInfo{}
+	fields := map[string]*uint64{
+		"MemTotal":     &info.Total,
+		"MemFree":      &info.Free,
+		"MemAvailable": &info.Available,
+		"SwapTotal":    &info.SwapTotal,
+		"SwapFree":     &info.SwapFree,
+	}
+	// Lines are of the form "MemTotal:       16310504 kB"
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.Fields(scanner.Text())
+		if len(line) < 2 {
+			continue
+		}
+		field, ok := fields[strings.TrimSuffix(line[0], ":")]
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseUint(line[1], 10, 64)
+		if err != nil {
+			return info, errors.Errorf("Unable to parse memory information line %q: %v", scanner.Text(), err)
+		}
+		if len(line) == 3 && line[2] == "kB" {
+			value *= 1024
+		}
+		*field = value
+	}
+	return info, nil
+}
+
+func LoadAvg() (LoadAverage, error) {
+	contents, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return LoadAverage{}, errors.Wrap(err, "Unable to read load average")
+	}
+	return ParseLoadAvg(contents)
+}
+
+func ParseLoadAvg(contents []byte) (LoadAverage, error) {
+	load := LoadAverage{}
+	// The file is of the form "0.52 0.58 0.59 1/1007 12345"
+	fields := strings.Fields(string(contents))
+	if len(fields) < 3 {
+		return load, errors.Errorf("Unable to parse load average %q", strings.TrimSpace(string(contents)))
+	}
+	averages := []*float64{&load.One, &load.Five, &load.Fifteen}
+	for i, average := range averages {
+		var err error
+		*average, err = strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return LoadAverage{}, errors.Errorf("Unable to parse load average %q: %v", strings.TrimSpace(string(contents)), err)
+		}
+	}
+	return load, nil
+}
+
+func NumCPU() int {
+	return runtime.NumCPU()
+}
@@ -0,0 +1,66 @@
+package operating_test
+
+import (
+	"os"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("operating/resources tests", func() {
+	Describe("ParseMemInfo", func() {
+		It("parses the memory fields and converts them to bytes", func() {
+			contents := `MemTotal:       16310504 kB
+MemFree:         1234567 kB
+MemAvailable:    8765432 kB
+Buffers:          123456 kB
+SwapTotal:       2097148 kB
+SwapFree:        2097000 kB
+HugePages_Total:       0
+`
+			info, err := operating.ParseMemInfo([]byte(contents))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info).To(Equal(operating.MemoryInfo{
+				Total:     16310504 * 1024,
+				Free:      1234567 * 1024,
+				Available: 8765432 * 1024,
+				SwapTotal: 2097148 * 1024,
+				SwapFree:  2097000 * 1024,
+			}))
+		})
+		It("returns an error if a field cannot be parsed", func() {
+			_, err := operating.ParseMemInfo([]byte("MemTotal:       lots kB\n"))
+			Expect(err).To(MatchError(ContainSubstring(`Unable to parse memory information line "MemTotal:       lots kB"`)))
+		})
+	})
+	Describe("ParseLoadAvg", func() {
+		It("parses the one, five, and fifteen minute load averages", func() {
+			load, err := operating.ParseLoadAvg([]byte("0.52 1.58 2.59 1/1007 12345\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(load).To(Equal(operating.LoadAverage{One: 0.52, Five: 1.58, Fifteen: 2.59}))
+		})
+		It("returns an error if there are too few fields", func() {
+			_, err := operating.ParseLoadAvg([]byte("0.52\n"))
+			Expect(err).To(MatchError(`Unable to parse load average "0.52"`))
+		})
+		It("returns an error if a field is not a number", func() {
+			_, err := operating.ParseLoadAvg([]byte("0.52 high 2.59 1/1007 12345"))
+			Expect(err).To(MatchError(ContainSubstring(`Unable to parse load average "0.52 high 2.59 1/1007 12345"`)))
+		})
+	})
+	Describe("DiskFree", func() {
+		It("returns the disk usage of the filesystem containing a path", func() {
+			usage, err := operating.DiskFree(os.TempDir())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(usage.Total).To(BeNumerically(">", 0))
+			Expect(usage.Free).To(BeNumerically("<=", usage.Total))
+			Expect(usage.Available).To(BeNumerically("<=", usage.Free))
+		})
+		It("returns an error for a nonexistent path", func() {
+			_, err := operating.DiskFree("/nonexistent/path")
+			Expect(err).To(MatchError(ContainSubstring("Unable to get disk usage for /nonexistent/path")))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package operating
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+func DiskFree(path string) (DiskUsage, error) {
+	return DiskUsage{}, errors.Errorf("Unable to get disk usage for %s: not supported on %s", path, runtime.GOOS)
+}
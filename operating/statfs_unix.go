@@ -0,0 +1,24 @@
+//go:build linux || darwin
+
+package operating
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+func DiskFree(path string) (DiskUsage, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return DiskUsage{}, errors.Wrapf(err, "Unable to get disk usage for %s", path)
+	}
+	// Bsize is a different integer type on different platforms
+	blockSize := uint64(stat.Bsize)
+	return DiskUsage{
+		Total:     stat.Blocks * blockSize,
+		Free:      stat.Bfree * blockSize,
+		Available: stat.Bavail * blockSize,
+	}, nil
+}
@@ -0,0 +1,198 @@
+package retry
+
+/*
+ * This file contains structs and functions related to retrying operations
+ * that may fail transiently, e.g. connecting to a database that is starting
+ * up, so that every package waits between attempts, gives up, and logs its
+ * attempts the same way.
+ */
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+var logDomain = gplog.Domain("retry")
+
+/*
+ * A Policy returns how long to wait before the given attempt, where attempt 1
+ * is the first attempt and so is never delayed.  Policies other than Jitter
+ * are deterministic, so that a retry schedule can be checked in tests with
+ * Schedule instead of by waiting for it to play out.
+ */
+type Policy interface {
+	Delay(attempt int) time.Duration
+}
+
+type Constant struct {
+	Interval time.Duration
+}
+
+func (backoff Constant) Delay(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+	return backoff.Interval
+}
+
+/*
+ * Exponential waits Initial before the second attempt and multiplies the delay
+ * by Multiplier for each attempt after that, up to Max if Max is greater than
+ * 0 and otherwise up to the longest time.Duration.  A Multiplier less than 1
+ * is treated as 2.
+ */
+type Exponential struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+func (backoff Exponential) Delay(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+	multiplier := backoff.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	maxDelay := backoff.Max
+	if maxDelay <= 0 {
+		maxDelay = math.MaxInt64
+	}
+	delay := float64(backoff.Initial)
+	for i := 2; i < attempt; i++ {
+		delay *= multiplier
+		if delay >= float64(maxDelay) {
+			return maxDelay
+		}
+	}
+	if delay > float64(maxDelay) {
+		return maxDelay
+	}
+	return time.Duration(delay)
+}
+
+/*
+ * Jitter shortens each delay of Policy by a random part of up to Fraction of
+ * it, so that many processes retrying against the same server at once, e.g.
+ * one per segment, spread their attempts out rather than retrying in step.  A
+ * Fraction of 1 gives "full jitter", where each delay is anywhere from 0 to
+ * the delay of Policy; Fractions outside [0, 1] are clamped to that range.
+ * Rand returns a number in [0, 1) and defaults to math/rand's Float64; tests
+ * can set it to make the delays deterministic.
+ */
+type Jitter struct {
+	Policy   Policy
+	Fraction float64
+	Rand     func() float64
+}
+
+func (backoff Jitter) Delay(attempt int) time.Duration {
+	delay := backoff.Policy.Delay(attempt)
+	fraction := backoff.Fraction
+	if fraction <= 0 || delay <= 0 {
+		return delay
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	random := rand.Float64
+	if backoff.Rand != nil {
+		random = backoff.Rand
+	}
+	return delay - time.Duration(float64(delay)*fraction*random())
+}
+
+// Schedule returns the delays before each of attempts 2 through maxAttempts
+func Schedule(policy Policy, maxAttempts int) []time.Duration {
+	schedule := make([]time.Duration, 0)
+	for attempt := 2; attempt <= maxAttempts; attempt++ {
+		schedule = append(schedule, policy.Delay(attempt))
+	}
+	return schedule
+}
+
+/*
+ * Options determine how Do retries an operation.  Operation names it in log
+ * messages, e.g. "Connection" gives "Connection attempt 2 of 5 failed: ...".
+ *
+ * Do stops after MaxAttempts attempts, or once MaxElapsed has passed since the
+ * first attempt started; the wait before the last attempt is shortened so that
+ * it starts at MaxElapsed, so that the operation is tried once more at the
+ * deadline.  If neither is greater than 0, the operation is attempted once.
+ *
+ * Backoff defaults to retrying immediately.  If Retryable is set, errors for
+ * which it returns false are returned without retrying, e.g. authentication
+ * failures that retrying will not fix; otherwise all errors are retried.
+ */
+type Options struct {
+	Operation   string
+	MaxAttempts int
+	MaxElapsed  time.Duration
+	Backoff     Policy
+	Retryable   func(err error) bool
+}
+
+func (opts Options) attemptString(attempt int) string {
+	operation := opts.Operation
+	if operation == "" {
+		operation = "Operation"
+	}
+	if opts.MaxAttempts > 0 {
+		return fmt.Sprintf("%s attempt %d of %d", operation, attempt, opts.MaxAttempts)
+	}
+	return fmt.Sprintf("%s attempt %d", operation, attempt)
+}
+
+/*
+ * Do calls fn, passing it the number of the attempt starting from 1, until it
+ * returns nil or opts say to stop, and returns the error from the last
+ * attempt.  Each failed attempt is logged at the Verbose level.  Waits use
+ * operating.System.After, so that they can be skipped with a fake clock in
+ * tests.  If ctx is done when an attempt fails or while waiting to retry, Do
+ * returns immediately: with the attempt's error in the first case and with
+ * ctx.Err() in the second.  Do does not interrupt an attempt itself, so fn
+ * should also return promptly once ctx is done.
+ */
+func Do(ctx context.Context, opts Options, fn func(attempt int) error) error {
+	backoff := opts.Backoff
+	if backoff == nil {
+		backoff = Constant{}
+	}
+	start := operating.System.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+		stop := ctx.Err() != nil || (opts.Retryable != nil && !opts.Retryable(err)) ||
+			(opts.MaxAttempts > 0 && attempt >= opts.MaxAttempts) || (opts.MaxAttempts <= 0 && opts.MaxElapsed <= 0)
+		delay := time.Duration(0)
+		if !stop {
+			delay = backoff.Delay(attempt + 1)
+			if opts.MaxElapsed > 0 {
+				remaining := opts.MaxElapsed - operating.System.Now().Sub(start)
+				stop = remaining <= 0
+				if delay > remaining {
+					delay = remaining
+				}
+			}
+		}
+		if stop {
+			logDomain.Verbose("%s failed: %v", opts.attemptString(attempt), err)
+			return err
+		}
+		logDomain.Verbose("%s failed: %v; retrying in %s", opts.attemptString(attempt), err, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-operating.System.After(delay):
+		}
+	}
+}
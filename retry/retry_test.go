@@ -0,0 +1,176 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/retry"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestRetry(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Retry Suite")
+}
+
+var _ = Describe("retry tests", func() {
+	var (
+		clock      *testhelper.FakeClock
+		logCapture *testhelper.LogCapture
+		refusedErr = errors.New("connection refused")
+	)
+	BeforeEach(func() {
+		clock = testhelper.MockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		clock.AutoAdvance = true
+		logCapture = testhelper.SetupTestLogCapture()
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	// failUntil returns a function for Do that fails until the given attempt
+	failUntil := func(succeedOn int, attempts *[]int) func(attempt int) error {
+		return func(attempt int) error {
+			*attempts = append(*attempts, attempt)
+			if attempt < succeedOn {
+				return refusedErr
+			}
+			return nil
+		}
+	}
+
+	DescribeTable("Schedule",
+		func(policy retry.Policy, expected []time.Duration) {
+			Expect(retry.Schedule(policy, 5)).To(Equal(expected))
+		},
+		Entry("waits the same interval before every retry", retry.Constant{Interval: time.Second},
+			[]time.Duration{time.Second, time.Second, time.Second, time.Second}),
+		Entry("doubles the delay by default", retry.Exponential{Initial: time.Second},
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}),
+		Entry("caps the delay at Max", retry.Exponential{Initial: time.Second, Max: 3 * time.Second},
+			[]time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}),
+		Entry("shortens delays by up to Fraction with jitter", retry.Jitter{Policy: retry.Exponential{Initial: time.Second}, Fraction: 0.5, Rand: func() float64 { return 0.5 }},
+			[]time.Duration{750 * time.Millisecond, 1500 * time.Millisecond, 3 * time.Second, 6 * time.Second}),
+		Entry("clamps the jitter fraction to 1", retry.Jitter{Policy: retry.Constant{Interval: time.Second}, Fraction: 2, Rand: func() float64 { return 0.25 }},
+			[]time.Duration{750 * time.Millisecond, 750 * time.Millisecond, 750 * time.Millisecond, 750 * time.Millisecond}),
+		Entry("leaves delays unchanged with no jitter fraction", retry.Jitter{Policy: retry.Constant{Interval: time.Second}},
+			[]time.Duration{time.Second, time.Second, time.Second, time.Second}),
+	)
+	Describe("Exponential", func() {
+		It("stops growing at the longest duration if Max is not set", func() {
+			backoff := retry.Exponential{Initial: time.Second}
+
+			Expect(backoff.Delay(100)).To(Equal(time.Duration(math.MaxInt64)))
+			Expect(backoff.Delay(10000)).To(Equal(time.Duration(math.MaxInt64)))
+		})
+	})
+	Describe("Jitter", func() {
+		It("never delays the first attempt", func() {
+			Expect(retry.Jitter{Policy: retry.Constant{Interval: time.Second}, Fraction: 1}.Delay(1)).To(Equal(time.Duration(0)))
+		})
+		It("keeps delays within the fraction by default", func() {
+			for attempt := 2; attempt < 100; attempt++ {
+				Expect(retry.Jitter{Policy: retry.Constant{Interval: time.Second}, Fraction: 0.2}.Delay(attempt)).To(BeNumerically("~", 900*time.Millisecond, 100*time.Millisecond))
+			}
+		})
+	})
+	Describe("Do", func() {
+		It("returns as soon as an attempt succeeds", func() {
+			attempts := make([]int, 0)
+
+			err := retry.Do(context.Background(), retry.Options{MaxAttempts: 5, Backoff: retry.Exponential{Initial: time.Second}}, failUntil(3, &attempts))
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(attempts).To(Equal([]int{1, 2, 3}))
+			Expect(clock.Waits).To(Equal([]time.Duration{time.Second, 2 * time.Second}))
+		})
+		It("returns the last error after MaxAttempts attempts and logs each of them", func() {
+			attempts := make([]int, 0)
+
+			err := retry.Do(context.Background(), retry.Options{Operation: "Connection", MaxAttempts: 2, Backoff: retry.Constant{Interval: time.Minute}}, failUntil(5, &attempts))
+
+			Expect(err).To(Equal(refusedErr))
+			Expect(attempts).To(Equal([]int{1, 2}))
+			Expect(logCapture).To(testhelper.HaveLoggedDebug("Connection attempt 1 of 2 failed: connection refused; retrying in 1m0s"))
+			Expect(logCapture).To(testhelper.HaveLoggedDebug("Connection attempt 2 of 2 failed: connection refused"))
+		})
+		It("makes a single attempt if neither MaxAttempts nor MaxElapsed is set", func() {
+			attempts := make([]int, 0)
+
+			err := retry.Do(context.Background(), retry.Options{}, failUntil(2, &attempts))
+
+			Expect(err).To(Equal(refusedErr))
+			Expect(attempts).To(Equal([]int{1}))
+			Expect(logCapture).To(testhelper.HaveLoggedDebug("Operation attempt 1 failed: connection refused"))
+		})
+		It("shortens the last wait so that the last attempt starts at MaxElapsed", func() {
+			attempts := make([]int, 0)
+
+			err := retry.Do(context.Background(), retry.Options{MaxElapsed: 25 * time.Second, Backoff: retry.Constant{Interval: 10 * time.Second}}, failUntil(10, &attempts))
+
+			Expect(err).To(Equal(refusedErr))
+			Expect(attempts).To(Equal([]int{1, 2, 3, 4}))
+			Expect(clock.Waits).To(Equal([]time.Duration{10 * time.Second, 10 * time.Second, 5 * time.Second}))
+		})
+		It("stops at whichever of MaxAttempts and MaxElapsed comes first", func() {
+			attempts := make([]int, 0)
+
+			err := retry.Do(context.Background(), retry.Options{MaxAttempts: 2, MaxElapsed: time.Hour, Backoff: retry.Constant{Interval: time.Second}}, failUntil(10, &attempts))
+
+			Expect(err).To(Equal(refusedErr))
+			Expect(attempts).To(Equal([]int{1, 2}))
+		})
+		It("does not retry errors that are not retryable", func() {
+			authErr := errors.New("authentication failed")
+			numAttempts := 0
+
+			err := retry.Do(context.Background(), retry.Options{
+				MaxAttempts: 5,
+				Retryable:   func(err error) bool { return err != authErr },
+			}, func(attempt int) error {
+				numAttempts++
+				return authErr
+			})
+
+			Expect(err).To(Equal(authErr))
+			Expect(numAttempts).To(Equal(1))
+			Expect(clock.Waits).To(BeEmpty())
+		})
+		It("returns the attempt's error without waiting if the context is done when the attempt fails", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			numAttempts := 0
+
+			err := retry.Do(ctx, retry.Options{MaxAttempts: 5, Backoff: retry.Constant{Interval: time.Hour}}, func(attempt int) error {
+				numAttempts++
+				cancel()
+				return refusedErr
+			})
+
+			Expect(err).To(Equal(refusedErr))
+			Expect(numAttempts).To(Equal(1))
+			Expect(clock.Waits).To(BeEmpty())
+		})
+		It("returns the context's error if the context is done while waiting to retry", func() {
+			clock.AutoAdvance = false
+			ctx, cancel := context.WithCancel(context.Background())
+			attempts := make([]int, 0)
+			result := make(chan error, 1)
+
+			go func() {
+				result <- retry.Do(ctx, retry.Options{MaxAttempts: 5, Backoff: retry.Constant{Interval: time.Hour}}, failUntil(5, &attempts))
+			}()
+
+			Eventually(clock.NumWaiters).Should(Equal(1))
+			cancel()
+			Eventually(result).Should(Receive(Equal(context.Canceled)))
+			Expect(attempts).To(Equal([]int{1}))
+		})
+	})
+})
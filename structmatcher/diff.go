@@ -0,0 +1,117 @@
+package structmatcher
+
+/*
+ * This file contains the Difference type used to report mismatches found by
+ * StructMatcher and MatchStruct, and functions for registering custom
+ * comparators for types and fields whose values need not be exactly equal.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * A Difference describes a single mismatch between two structs.  FieldPath is
+ * in the same format as in the failure messages, e.g. "NestedSlice[0].Field1",
+ * and is empty for a mismatch on unexported fields of the top level struct.
+ * For a mismatch on the keys of a map or on a slice compared without regard to
+ * order, Expected and Actual hold the keys or elements that differ.
+ */
+type Difference struct {
+	FieldPath string
+	Expected  interface{}
+	Actual    interface{}
+	Message   string
+}
+
+var _ fmt.Stringer = Difference{}
+
+func (diff Difference) String() string {
+	return diff.Message
+}
+
+func mismatchMessages(diffs []Difference) []string {
+	messages := make([]string, 0, len(diffs))
+	for _, diff := range diffs {
+		messages = append(messages, diff.Message)
+	}
+	return messages
+}
+
+/*
+ * Diff returns the differences between two structs, with the same filtering
+ * behavior as StructMatcher.  It returns an empty slice if the structs match.
+ */
+func Diff(expected, actual interface{}, shouldFilter bool, filterInclude bool, filterFields ...string) []Difference {
+	return structDiff(expected, actual, matchOptions{}, shouldFilter, filterInclude, filterFields...)
+}
+
+/*
+ * A Comparator returns true if actual should be considered to match expected.
+ * Both values are of the type of the field being compared.
+ */
+type Comparator func(expected interface{}, actual interface{}) bool
+
+var (
+	comparatorMutex sync.RWMutex
+	comparators     = make(map[reflect.Type]Comparator)
+)
+
+/*
+ * RegisterComparator replaces the comparison of every field of type T, and of
+ * every element of type T in a slice or map, with compare.  Fields of type *T
+ * are not affected.  Like Register, it is typically called from a test suite's
+ * init or BeforeSuite function, and registering a comparator for a type that
+ * already has one replaces it.
+ */
+func RegisterComparator[T any](compare func(expected T, actual T) bool) {
+	valueType := reflect.TypeOf((*T)(nil)).Elem()
+	comparatorMutex.Lock()
+	defer comparatorMutex.Unlock()
+	comparators[valueType] = ComparatorFor(compare)
+}
+
+func UnregisterComparator[T any]() {
+	comparatorMutex.Lock()
+	defer comparatorMutex.Unlock()
+	delete(comparators, reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func lookupComparator(valueType reflect.Type) Comparator {
+	comparatorMutex.RLock()
+	defer comparatorMutex.RUnlock()
+	return comparators[valueType]
+}
+
+/*
+ * The following functions return comparators for common cases, for use with
+ * RegisterComparator or Matcher.UsingFieldComparator.
+ */
+
+func TimesWithin(tolerance time.Duration) func(expected time.Time, actual time.Time) bool {
+	return func(expected time.Time, actual time.Time) bool {
+		difference := actual.Sub(expected)
+		return difference <= tolerance && difference >= -tolerance
+	}
+}
+
+func StringsEqualFold(expected string, actual string) bool {
+	return strings.EqualFold(expected, actual)
+}
+
+/*
+ * ComparatorFor adapts a typed comparison function to a Comparator, so that
+ * functions such as TimesWithin can be passed to UsingFieldComparator.  The
+ * resulting Comparator reports a mismatch if either value is not of type T.
+ */
+func ComparatorFor[T any](compare func(expected T, actual T) bool) Comparator {
+	return func(expected interface{}, actual interface{}) bool {
+		expectedValue, expectedOk := expected.(T)
+		actualValue, actualOk := actual.(T)
+		return expectedOk && actualOk && compare(expectedValue, actualValue)
+	}
+}
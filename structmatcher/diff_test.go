@@ -0,0 +1,138 @@
+package structmatcher_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/structmatcher"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("structmatcher diff and comparator functions", func() {
+	type SimpleStruct struct {
+		Field1 int
+		Field2 string
+	}
+	type TimedStruct struct {
+		Name        string
+		Created     time.Time
+		NestedSlice []SimpleStruct
+		Struct      SimpleStruct
+	}
+	baseTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	Describe("structmatcher.Diff", func() {
+		It("returns no differences for the same structs", func() {
+			struct1 := SimpleStruct{Field1: 0, Field2: "message1"}
+			struct2 := SimpleStruct{Field1: 0, Field2: "message1"}
+			Expect(structmatcher.Diff(&struct1, &struct2, false, false)).To(BeEmpty())
+		})
+		It("returns the path and values of each mismatched field", func() {
+			struct1 := TimedStruct{Name: "a", NestedSlice: []SimpleStruct{{Field1: 3}}, Struct: SimpleStruct{Field2: "x"}}
+			struct2 := TimedStruct{Name: "b", NestedSlice: []SimpleStruct{{Field1: 4}}, Struct: SimpleStruct{Field2: "y"}}
+			diffs := structmatcher.Diff(&struct1, &struct2, false, false)
+			Expect(diffs).To(HaveLen(3))
+			Expect(diffs[0].FieldPath).To(Equal("Name"))
+			Expect(diffs[0].Expected).To(Equal("a"))
+			Expect(diffs[0].Actual).To(Equal("b"))
+			Expect(diffs[1].FieldPath).To(Equal("NestedSlice[0].Field1"))
+			Expect(diffs[1].Expected).To(Equal(3))
+			Expect(diffs[1].Actual).To(Equal(4))
+			Expect(diffs[2].FieldPath).To(Equal("Struct.Field2"))
+			Expect(diffs[2].Message).To(Equal("Mismatch on field Struct.Field2\nExpected\n    <string>: y\nto equal\n    <string>: x"))
+		})
+		It("has the same messages as StructMatcher", func() {
+			struct1 := TimedStruct{Name: "a", NestedSlice: []SimpleStruct{{Field1: 3}}}
+			struct2 := TimedStruct{Name: "b", NestedSlice: []SimpleStruct{{Field1: 4}}}
+			diffs := structmatcher.Diff(&struct1, &struct2, true, false, "Name")
+			Expect(diffs).To(HaveLen(1))
+			Expect(diffs[0].String()).To(Equal(structmatcher.StructMatcher(&struct1, &struct2, true, false, "Name")[0]))
+		})
+	})
+	Describe("Matcher.Diff", func() {
+		It("applies the matcher's options", func() {
+			struct1 := TimedStruct{Name: "a", NestedSlice: []SimpleStruct{{Field1: 1}, {Field1: 2}}}
+			struct2 := TimedStruct{Name: "b", NestedSlice: []SimpleStruct{{Field1: 2}, {Field1: 1}}}
+			diffs := structmatcher.MatchStruct(struct1).ExcludingFields("Name").IgnoringSliceOrder("NestedSlice").Diff(struct2)
+			Expect(diffs).To(BeEmpty())
+		})
+		It("returns unmatched elements of slices compared without regard to order", func() {
+			struct1 := TimedStruct{NestedSlice: []SimpleStruct{{Field1: 1}, {Field1: 2}}}
+			struct2 := TimedStruct{NestedSlice: []SimpleStruct{{Field1: 3}, {Field1: 1}}}
+			diffs := structmatcher.MatchStruct(struct1).IgnoringSliceOrder("NestedSlice").Diff(struct2)
+			Expect(diffs).To(HaveLen(1))
+			Expect(diffs[0].FieldPath).To(Equal("NestedSlice"))
+			Expect(diffs[0].Expected).To(Equal([]interface{}{SimpleStruct{Field1: 2}}))
+			Expect(diffs[0].Actual).To(Equal([]interface{}{SimpleStruct{Field1: 3}}))
+		})
+	})
+	Describe("Matcher.UsingFieldComparator", func() {
+		It("uses the comparator for the given field", func() {
+			struct1 := TimedStruct{Name: "Table", Created: baseTime}
+			struct2 := TimedStruct{Name: "TABLE", Created: baseTime.Add(time.Second)}
+			Expect(struct2).ToNot(structmatcher.MatchStruct(struct1))
+			Expect(struct2).To(structmatcher.MatchStruct(struct1).
+				UsingFieldComparator("Name", structmatcher.ComparatorFor(structmatcher.StringsEqualFold)).
+				UsingFieldComparator("Created", structmatcher.ComparatorFor(structmatcher.TimesWithin(time.Minute))))
+		})
+		It("uses the comparator for a nested field", func() {
+			struct1 := TimedStruct{Struct: SimpleStruct{Field2: "abc"}, NestedSlice: []SimpleStruct{{Field2: "def"}}}
+			struct2 := TimedStruct{Struct: SimpleStruct{Field2: "ABC"}, NestedSlice: []SimpleStruct{{Field2: "DEF"}}}
+			matcher := structmatcher.MatchStruct(struct1).UsingFieldComparator("Struct.Field2", structmatcher.ComparatorFor(structmatcher.StringsEqualFold))
+			diffs := matcher.Diff(struct2)
+			Expect(diffs).To(HaveLen(1))
+			Expect(diffs[0].FieldPath).To(Equal("NestedSlice[0].Field2"))
+			matcher.UsingFieldComparator("NestedSlice.Field2", structmatcher.ComparatorFor(structmatcher.StringsEqualFold))
+			Expect(struct2).To(matcher)
+		})
+		It("reports a mismatch when the comparator fails", func() {
+			struct1 := TimedStruct{Name: "Table"}
+			struct2 := TimedStruct{Name: "View"}
+			diffs := structmatcher.MatchStruct(struct1).UsingFieldComparator("Name", structmatcher.ComparatorFor(structmatcher.StringsEqualFold)).Diff(struct2)
+			Expect(diffs).To(Equal([]structmatcher.Difference{{
+				FieldPath: "Name",
+				Expected:  "Table",
+				Actual:    "View",
+				Message:   "Mismatch on field Name\nExpected\n    <string>: View\nto match with custom comparator\n    <string>: Table",
+			}}))
+		})
+	})
+	Describe("structmatcher.RegisterComparator", func() {
+		AfterEach(func() {
+			structmatcher.UnregisterComparator[time.Time]()
+			structmatcher.UnregisterComparator[SimpleStruct]()
+		})
+		It("uses the comparator for all fields of the registered type", func() {
+			struct1 := TimedStruct{Name: "a", Created: baseTime}
+			struct2 := TimedStruct{Name: "a", Created: baseTime.Add(time.Second)}
+			Expect(structmatcher.StructMatcher(&struct1, &struct2, false, false)).ToNot(BeEmpty())
+			structmatcher.RegisterComparator(structmatcher.TimesWithin(time.Minute))
+			Expect(structmatcher.StructMatcher(&struct1, &struct2, false, false)).To(BeEmpty())
+			struct2.Created = baseTime.Add(time.Hour)
+			diffs := structmatcher.Diff(&struct1, &struct2, false, false)
+			Expect(diffs).To(HaveLen(1))
+			Expect(diffs[0].FieldPath).To(Equal("Created"))
+		})
+		It("uses the comparator for elements of the registered type", func() {
+			structmatcher.RegisterComparator(func(expected SimpleStruct, actual SimpleStruct) bool {
+				return expected.Field1 == actual.Field1 && strings.EqualFold(expected.Field2, actual.Field2)
+			})
+			struct1 := TimedStruct{NestedSlice: []SimpleStruct{{Field1: 1, Field2: "a"}, {Field1: 2, Field2: "b"}}}
+			struct2 := TimedStruct{NestedSlice: []SimpleStruct{{Field1: 2, Field2: "B"}, {Field1: 1, Field2: "A"}}}
+			Expect(struct2).To(structmatcher.MatchStruct(struct1).IgnoringSliceOrder("NestedSlice"))
+			diffs := structmatcher.Diff(&struct1, &struct2, false, false)
+			Expect(diffs).To(HaveLen(2))
+			Expect(diffs[0].FieldPath).To(Equal("NestedSlice[0]"))
+			Expect(diffs[1].FieldPath).To(Equal("NestedSlice[1]"))
+		})
+		It("prefers a field comparator to a type comparator", func() {
+			structmatcher.RegisterComparator(func(expected time.Time, actual time.Time) bool { return false })
+			struct1 := TimedStruct{Created: baseTime}
+			struct2 := TimedStruct{Created: baseTime}
+			Expect(struct2).ToNot(structmatcher.MatchStruct(struct1).ExcludingFields("Name"))
+			Expect(struct2).To(structmatcher.MatchStruct(struct1).UsingFieldComparator("Created", structmatcher.ComparatorFor(time.Time.Equal)))
+		})
+	})
+})
@@ -0,0 +1,111 @@
+package structmatcher
+
+/*
+ * This file contains functions for registering struct types for faster
+ * matching in StructMatcher and MatchStruct.
+ */
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+/*
+ * For a registered type, the field layout is computed once at registration
+ * instead of on every comparison, and matching structs are detected without
+ * going through Gomega for every field.  Only mismatches fall back to the
+ * reflective path, which is still needed to produce the detailed messages.
+ */
+type registeredType struct {
+	fieldIndices   map[string]int
+	exportedFields []bool
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[reflect.Type]*registeredType)
+)
+
+/*
+ * Register enables the fast path for comparisons of structs of type T (or of
+ * pointers to T).  It is safe to call more than once for the same type, and
+ * is typically called from a test suite's init or BeforeSuite function.
+ */
+func Register[T any]() {
+	structType := reflect.TypeOf((*T)(nil)).Elem()
+	if structType.Kind() != reflect.Struct {
+		panic("structmatcher.Register called with non-struct type " + structType.String())
+	}
+	registered := &registeredType{
+		fieldIndices:   make(map[string]int, structType.NumField()),
+		exportedFields: make([]bool, structType.NumField()),
+	}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		registered.fieldIndices[field.Name] = i
+		registered.exportedFields[i] = field.PkgPath == ""
+	}
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[structType] = registered
+}
+
+func IsRegistered[T any]() bool {
+	return lookupRegisteredType(reflect.TypeOf((*T)(nil)).Elem()) != nil
+}
+
+func lookupRegisteredType(structType reflect.Type) *registeredType {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	return registry[structType]
+}
+
+/*
+ * Returns true only if the structs are known to match; a false return means
+ * that the reflective path must be used to determine whether they do.  Fields
+ * are compared as a whole, so a field that is equal also matches under any
+ * nested filter on that field.
+ */
+func (registered *registeredType) structsMatch(expected reflect.Value, actual reflect.Value, shouldFilter bool, filterInclude bool, filterFields ...string) bool {
+	if !shouldFilter {
+		return reflect.DeepEqual(expected.Interface(), actual.Interface())
+	}
+	filterMap := make(map[string]bool, len(filterFields))
+	for _, field := range filterFields {
+		fieldName := field
+		if index := strings.Index(field, "."); index != -1 {
+			if !filterInclude {
+				// Excluding a nested field still requires comparing the rest of the parent field
+				continue
+			}
+			fieldName = field[:index]
+		}
+		filterMap[fieldName] = true
+	}
+	for name, i := range registered.fieldIndices {
+		if (filterInclude && !filterMap[name]) || (!filterInclude && filterMap[name]) {
+			continue
+		}
+		if !registered.exportedFields[i] {
+			return false
+		}
+		if !reflect.DeepEqual(expected.Field(i).Interface(), actual.Field(i).Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+func fastStructsMatch(expected interface{}, actual interface{}, shouldFilter bool, filterInclude bool, filterFields ...string) bool {
+	expectedStruct := reflect.Indirect(reflect.ValueOf(expected))
+	actualStruct := reflect.Indirect(reflect.ValueOf(actual))
+	if !expectedStruct.IsValid() || !actualStruct.IsValid() || expectedStruct.Type() != actualStruct.Type() {
+		return false
+	}
+	registered := lookupRegisteredType(expectedStruct.Type())
+	if registered == nil {
+		return false
+	}
+	return registered.structsMatch(expectedStruct, actualStruct, shouldFilter, filterInclude, filterFields...)
+}
@@ -0,0 +1,119 @@
+package structmatcher_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/greenplum-db/gp-common-go-libs/structmatcher"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type RegisteredInner struct {
+	Field1 int
+	Field2 string
+}
+
+type RegisteredStruct struct {
+	Name        string
+	Oid         uint32
+	Options     []string
+	NestedSlice []RegisteredInner
+	PtrStruct   *RegisteredInner
+}
+
+type RegisteredOpaqueStruct struct {
+	PublicField  string
+	privateField string
+}
+
+type UnregisteredStruct struct {
+	Field1 int
+}
+
+func init() {
+	structmatcher.Register[RegisteredStruct]()
+	structmatcher.Register[RegisteredOpaqueStruct]()
+}
+
+var _ = Describe("structmatcher/register tests", func() {
+	Describe("Register", func() {
+		It("records that a type is registered", func() {
+			Expect(structmatcher.IsRegistered[RegisteredStruct]()).To(BeTrue())
+			Expect(structmatcher.IsRegistered[UnregisteredStruct]()).To(BeFalse())
+		})
+		It("panics when given a non-struct type", func() {
+			Expect(func() { structmatcher.Register[int]() }).To(PanicWith("structmatcher.Register called with non-struct type int"))
+		})
+	})
+	Describe("Matching registered types", func() {
+		var expected RegisteredStruct
+		BeforeEach(func() {
+			expected = RegisteredStruct{
+				Name:        "foo",
+				Oid:         1,
+				Options:     []string{"a", "b"},
+				NestedSlice: []RegisteredInner{{Field1: 3}},
+				PtrStruct:   &RegisteredInner{Field1: 7},
+			}
+		})
+		It("matches equal structs and pointers to structs", func() {
+			actual := expected
+			actual.PtrStruct = &RegisteredInner{Field1: 7}
+			Expect(actual).To(structmatcher.MatchStruct(expected))
+			Expect(&actual).To(structmatcher.MatchStruct(&expected))
+		})
+		It("reports the same mismatches as for unregistered types", func() {
+			actual := expected
+			actual.NestedSlice = []RegisteredInner{{Field1: 4}}
+			mismatches := structmatcher.StructMatcher(&expected, &actual, false, false)
+			Expect(mismatches).To(Equal([]string{"Mismatch on field NestedSlice[0].Field1\nExpected\n    <int>: 4\nto equal\n    <int>: 3"}))
+		})
+		It("ignores excluded fields", func() {
+			actual := expected
+			actual.Oid = 2
+			actual.NestedSlice = []RegisteredInner{{Field1: 3, Field2: "different"}}
+			Expect(actual).To(structmatcher.MatchStruct(expected).ExcludingFields("Oid", "NestedSlice.Field2"))
+			Expect(actual).ToNot(structmatcher.MatchStruct(expected).ExcludingFields("Oid"))
+		})
+		It("only compares included fields", func() {
+			actual := RegisteredStruct{Name: "foo", NestedSlice: []RegisteredInner{{Field1: 3}}}
+			Expect(actual).To(structmatcher.MatchStruct(expected).IncludingFields("Name", "NestedSlice.Field1"))
+			Expect(actual).ToNot(structmatcher.MatchStruct(expected).IncludingFields("Name", "Oid"))
+		})
+		It("compares unexported fields", func() {
+			struct1 := RegisteredOpaqueStruct{PublicField: "foo", privateField: "bar"}
+			struct2 := RegisteredOpaqueStruct{PublicField: "foo", privateField: "baz"}
+			Expect(struct1).To(structmatcher.MatchStruct(struct1))
+			Expect(struct2).ToNot(structmatcher.MatchStruct(struct1))
+			Expect(struct2).To(structmatcher.MatchStruct(struct1).IncludingFields("PublicField"))
+		})
+	})
+})
+
+func newBenchmarkStruct() RegisteredStruct {
+	registered := RegisteredStruct{Name: "foo", Oid: 1, Options: []string{"a", "b"}, PtrStruct: &RegisteredInner{Field1: 7}}
+	for i := 0; i < 10; i++ {
+		registered.NestedSlice = append(registered.NestedSlice, RegisteredInner{Field1: i, Field2: fmt.Sprintf("field%d", i)})
+	}
+	return registered
+}
+
+type UnregisteredBenchmarkStruct RegisteredStruct
+
+func BenchmarkMatchStructRegistered(b *testing.B) {
+	RegisterTestingT(b)
+	expected, actual := newBenchmarkStruct(), newBenchmarkStruct()
+	for i := 0; i < b.N; i++ {
+		structmatcher.StructMatcher(&expected, &actual, false, false)
+	}
+}
+
+func BenchmarkMatchStructUnregistered(b *testing.B) {
+	RegisterTestingT(b)
+	expected, actual := UnregisteredBenchmarkStruct(newBenchmarkStruct()), UnregisteredBenchmarkStruct(newBenchmarkStruct())
+	for i := 0; i < b.N; i++ {
+		structmatcher.StructMatcher(&expected, &actual, false, false)
+	}
+}
@@ -7,9 +7,12 @@ package structmatcher
 import (
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/format"
 	"github.com/onsi/gomega/types"
 )
 
@@ -19,12 +22,172 @@ import (
  * To filter on a field "fieldname" in struct "structname", pass in "fieldname".
  * To filter on a field "fieldname" in a nested struct under field "structfield", pass in "structfield.fieldname".
  * This function assumes structs will only ever be nested one level deep.
+ * Slices and maps whose elements are structs or pointers to structs are compared
+ * element by element, with mismatches reported as e.g. "field[0].fieldname" or
+ * "field[key].fieldname", and filters apply to the fields of their elements.
+ * Comparisons of types registered with Register skip the reflective path when the structs match.
  */
 func StructMatcher(expected, actual interface{}, shouldFilter bool, filterInclude bool, filterFields ...string) []string {
-	return structMatcher(reflect.ValueOf(expected), reflect.ValueOf(actual), "", shouldFilter, filterInclude, filterFields...)
+	return mismatchMessages(structDiff(expected, actual, matchOptions{}, shouldFilter, filterInclude, filterFields...))
 }
 
-func structMatcher(expected, actual reflect.Value, fieldPath string, shouldFilter bool, filterInclude bool, filterFields ...string) []string {
+/*
+ * Options that can only be set through MatchStruct, to avoid changing the
+ * signature of StructMatcher.  Fields in unorderedFields and fieldComparators
+ * are named by their path without slice indices or map keys, e.g.
+ * "NestedSlice" or "Struct.NestedSlice".
+ */
+type matchOptions struct {
+	unorderedFields  map[string]bool
+	fieldComparators map[string]Comparator
+}
+
+var fieldPathIndexPattern = regexp.MustCompile(`\[[^\]]*\]`)
+
+func fieldPattern(fieldPath string, fieldName string) string {
+	return fieldPathIndexPattern.ReplaceAllString(fieldPath, "") + fieldName
+}
+
+func (opts matchOptions) ignoresOrder(fieldPath string, fieldName string) bool {
+	return opts.unorderedFields[fieldPattern(fieldPath, fieldName)]
+}
+
+// A comparator for a specific field takes precedence over one for its type
+func (opts matchOptions) comparatorFor(fieldPath string, fieldName string, fieldType reflect.Type) Comparator {
+	if compare, ok := opts.fieldComparators[fieldPattern(fieldPath, fieldName)]; ok {
+		return compare
+	}
+	return lookupComparator(fieldType)
+}
+
+func structDiff(expected, actual interface{}, opts matchOptions, shouldFilter bool, filterInclude bool, filterFields ...string) []Difference {
+	if fastStructsMatch(expected, actual, shouldFilter, filterInclude, filterFields...) {
+		return []Difference{}
+	}
+	return structMatcher(reflect.ValueOf(expected), reflect.ValueOf(actual), "", opts, shouldFilter, filterInclude, filterFields...)
+}
+
+// Slices and maps of structs or pointers to structs are compared element by element
+func hasStructElements(value reflect.Value) bool {
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Map {
+		return false
+	}
+	elemType := value.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	return elemType.Kind() == reflect.Struct
+}
+
+func isNilPtr(value reflect.Value) bool {
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
+
+/*
+ * Compares two values with gomega.Equal(), which produces the failure message
+ * for the Difference if they are not equal.
+ */
+func valueDiff(expected, actual interface{}, fieldPath string, description string) []Difference {
+	messages := InterceptGomegaFailures(func() {
+		Expect(actual).To(Equal(expected), "%s", description)
+	})
+	if len(messages) == 0 {
+		return []Difference{}
+	}
+	return []Difference{{FieldPath: fieldPath, Expected: expected, Actual: actual, Message: messages[0]}}
+}
+
+func fieldDiff(expected, actual interface{}, fieldPath string) []Difference {
+	return valueDiff(expected, actual, fieldPath, fmt.Sprintf("Mismatch on field %s", fieldPath))
+}
+
+func comparatorDiff(expected, actual interface{}, fieldPath string, compare Comparator) []Difference {
+	if compare(expected, actual) {
+		return []Difference{}
+	}
+	message := fmt.Sprintf("Mismatch on field %s\n%s", fieldPath, format.Message(actual, "to match with custom comparator", expected))
+	return []Difference{{FieldPath: fieldPath, Expected: expected, Actual: actual, Message: message}}
+}
+
+/*
+ * Compares two elements of a slice or map, recursing into them if they are
+ * structs; a nil pointer is only compared as a whole.
+ */
+func elementMatcher(expected, actual reflect.Value, elementPath string, opts matchOptions, shouldFilter bool, filterInclude bool, filterFields ...string) []Difference {
+	if compare := lookupComparator(expected.Type()); compare != nil {
+		return comparatorDiff(expected.Interface(), actual.Interface(), elementPath, compare)
+	}
+	if isNilPtr(expected) || isNilPtr(actual) {
+		return fieldDiff(expected.Interface(), actual.Interface(), elementPath)
+	}
+	return structMatcher(expected, actual, elementPath+".", opts, shouldFilter, filterInclude, filterFields...)
+}
+
+func sortedMapKeys(value reflect.Value) []reflect.Value {
+	keys := value.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprintf("%v", keys[i].Interface()) < fmt.Sprintf("%v", keys[j].Interface())
+	})
+	return keys
+}
+
+func mapMatcher(expected, actual reflect.Value, fieldPath string, opts matchOptions, shouldFilter bool, filterInclude bool, filterFields ...string) []Difference {
+	expectedKeys := make([]interface{}, 0)
+	actualKeys := make([]interface{}, 0)
+	for _, key := range sortedMapKeys(expected) {
+		expectedKeys = append(expectedKeys, key.Interface())
+	}
+	for _, key := range sortedMapKeys(actual) {
+		actualKeys = append(actualKeys, key.Interface())
+	}
+	diffs := valueDiff(expectedKeys, actualKeys, fieldPath, fmt.Sprintf("Mismatch on keys of field %s", fieldPath))
+	for _, key := range sortedMapKeys(expected) {
+		actualValue := actual.MapIndex(key)
+		if !actualValue.IsValid() {
+			continue
+		}
+		elementPath := fmt.Sprintf("%s[%v]", fieldPath, key.Interface())
+		diffs = append(diffs, elementMatcher(expected.MapIndex(key), actualValue, elementPath, opts, shouldFilter, filterInclude, filterFields...)...)
+	}
+	return diffs
+}
+
+/*
+ * Each expected element is paired with the first unpaired actual element that
+ * matches it, and any elements left unpaired on either side are reported
+ * together.
+ */
+func unorderedSliceMatcher(expected, actual reflect.Value, fieldPath string, opts matchOptions, shouldFilter bool, filterInclude bool, filterFields ...string) []Difference {
+	compareElements := hasStructElements(expected) || lookupComparator(expected.Type().Elem()) != nil
+	paired := make([]bool, actual.Len())
+	unmatchedExpected := make([]interface{}, 0)
+	for i := 0; i < expected.Len(); i++ {
+		found := false
+		for j := 0; j < actual.Len() && !found; j++ {
+			if paired[j] {
+				continue
+			}
+			if compareElements {
+				found = len(elementMatcher(expected.Index(i), actual.Index(j), fieldPath, opts, shouldFilter, filterInclude, filterFields...)) == 0
+			} else {
+				found = reflect.DeepEqual(expected.Index(i).Interface(), actual.Index(j).Interface())
+			}
+			paired[j] = found
+		}
+		if !found {
+			unmatchedExpected = append(unmatchedExpected, expected.Index(i).Interface())
+		}
+	}
+	unmatchedActual := make([]interface{}, 0)
+	for j := 0; j < actual.Len(); j++ {
+		if !paired[j] {
+			unmatchedActual = append(unmatchedActual, actual.Index(j).Interface())
+		}
+	}
+	return valueDiff(unmatchedExpected, unmatchedActual, fieldPath, fmt.Sprintf("Mismatch on field %s ignoring order; unmatched elements", fieldPath))
+}
+
+func structMatcher(expected, actual reflect.Value, fieldPath string, opts matchOptions, shouldFilter bool, filterInclude bool, filterFields ...string) []Difference {
 	// Add field names for the top-level struct to a filter map, and split off nested field names to pass down to nested structs
 	filterMap := make(map[string]bool)
 	nestedFilterFields := make([]string, 0)
@@ -42,65 +205,60 @@ func structMatcher(expected, actual reflect.Value, fieldPath string, shouldFilte
 	}
 	expectedStruct := reflect.Indirect(expected)
 	actualStruct := reflect.Indirect(actual)
-	mismatches := []string{}
-	mismatches = append(mismatches, InterceptGomegaFailures(func() {
-		structCanInterface := true
-		for i := 0; i < expectedStruct.NumField(); i++ {
-			expectedField := reflect.Indirect(expectedStruct.Field(i))
-			actualField := reflect.Indirect(actualStruct.Field(i))
-			fieldName := actualStruct.Type().Field(i).Name
-			// If we're including, skip this field if the name doesn't match; if we're excluding, skip if it does match
-			if shouldFilter && ((filterInclude && !filterMap[fieldName]) || (!filterInclude && filterMap[fieldName])) {
-				continue
+	diffs := []Difference{}
+	structCanInterface := true
+	for i := 0; i < expectedStruct.NumField(); i++ {
+		expectedField := reflect.Indirect(expectedStruct.Field(i))
+		actualField := reflect.Indirect(actualStruct.Field(i))
+		fieldName := actualStruct.Type().Field(i).Name
+		// If we're including, skip this field if the name doesn't match; if we're excluding, skip if it does match
+		if shouldFilter && ((filterInclude && !filterMap[fieldName]) || (!filterInclude && filterMap[fieldName])) {
+			continue
+		}
+		actualFieldIsNonemptySlice := actualField.Kind() == reflect.Slice && !actualField.IsNil() && actualField.Len() > 0
+		expectedFieldIsNonemptySlice := expectedField.Kind() == reflect.Slice && !expectedField.IsNil() && expectedField.Len() > 0
+		fieldIsStructSlice := actualFieldIsNonemptySlice && expectedFieldIsNonemptySlice && actualField.Len() == expectedField.Len() && hasStructElements(actualField)
+		fieldIsStructMap := actualField.Kind() == reflect.Map && !actualField.IsNil() && !expectedField.IsNil() && hasStructElements(actualField)
+		fieldIsUnorderedSlice := actualField.Kind() == reflect.Slice && opts.ignoresOrder(fieldPath, fieldName) && expectedStruct.Field(i).CanInterface()
+
+		expectedFieldIsNilPtr := expectedStruct.Field(i).Kind() == reflect.Ptr && expectedStruct.Field(i).IsNil()
+		actualFieldIsNilPtr := actualStruct.Field(i).Kind() == reflect.Ptr && actualStruct.Field(i).IsNil()
+
+		subFieldPath := fmt.Sprintf("%s%s", fieldPath, fieldName)
+		compare := opts.comparatorFor(fieldPath, fieldName, expectedStruct.Field(i).Type())
+		if compare != nil && expectedStruct.Field(i).CanInterface() {
+			diffs = append(diffs, comparatorDiff(expectedStruct.Field(i).Interface(), actualStruct.Field(i).Interface(), subFieldPath, compare)...)
+		} else if fieldIsUnorderedSlice && !actualFieldIsNilPtr && !expectedFieldIsNilPtr {
+			diffs = append(diffs, unorderedSliceMatcher(expectedField, actualField, subFieldPath, opts, shouldFilter, filterInclude, nestedFilterFields...)...)
+		} else if fieldIsStructSlice && expectedStruct.Field(i).CanInterface() {
+			for j := 0; j < actualField.Len(); j++ {
+				elementPath := fmt.Sprintf("%s[%d]", subFieldPath, j)
+				diffs = append(diffs, elementMatcher(expectedField.Index(j), actualField.Index(j), elementPath, opts, shouldFilter, filterInclude, nestedFilterFields...)...)
 			}
-			actualFieldIsNonemptySlice := actualField.Kind() == reflect.Slice && !actualField.IsNil() && actualField.Len() > 0
-			expectedFieldIsNonemptySlice := expectedField.Kind() == reflect.Slice && !expectedField.IsNil() && expectedField.Len() > 0
-			fieldIsStructSlice := actualFieldIsNonemptySlice && expectedFieldIsNonemptySlice && actualField.Len() == expectedField.Len() && actualField.Index(0).Kind() == reflect.Struct
-
-			expectedFieldIsNilPtr := expectedStruct.Field(i).Kind() == reflect.Ptr && expectedStruct.Field(i).IsNil()
-			actualFieldIsNilPtr := actualStruct.Field(i).Kind() == reflect.Ptr && actualStruct.Field(i).IsNil()
-
-			if fieldIsStructSlice {
-				for j := 0; j < actualField.Len(); j++ {
-					expectedStructField := expectedStruct.Field(i).Index(j)
-					actualStructField := actualStruct.Field(i).Index(j)
-					subFieldPath := fmt.Sprintf("%s%s[%d].", fieldPath, fieldName, j)
-					mismatches = append(mismatches, structMatcher(expectedStructField, actualStructField, subFieldPath, shouldFilter, filterInclude, nestedFilterFields...)...)
-				}
-			} else if actualFieldIsNilPtr != expectedFieldIsNilPtr {
-				expectedValue := expectedStruct.Field(i).Interface()
-				actualValue := actualStruct.Field(i).Interface()
-				Expect(actualValue).To(Equal(expectedValue), "Mismatch on field %s%s", fieldPath, fieldName)
-			} else if expectedStruct.Field(i).CanInterface() {
-				if actualField.Kind() == reflect.Struct {
-					expectedStructField := expectedStruct.Field(i)
-					actualStructField := actualStruct.Field(i)
-					subFieldPath := fmt.Sprintf("%s%s.", fieldPath, fieldName)
-					mismatches = append(mismatches, structMatcher(expectedStructField, actualStructField, subFieldPath, shouldFilter, filterInclude, nestedFilterFields...)...)
-				} else {
-					expectedValue := expectedStruct.Field(i).Interface()
-					actualValue := actualStruct.Field(i).Interface()
-					Expect(actualValue).To(Equal(expectedValue), "Mismatch on field %s%s", fieldPath, fieldName)
-				}
+		} else if fieldIsStructMap && expectedStruct.Field(i).CanInterface() {
+			diffs = append(diffs, mapMatcher(expectedField, actualField, subFieldPath, opts, shouldFilter, filterInclude, nestedFilterFields...)...)
+		} else if actualFieldIsNilPtr != expectedFieldIsNilPtr {
+			diffs = append(diffs, fieldDiff(expectedStruct.Field(i).Interface(), actualStruct.Field(i).Interface(), subFieldPath)...)
+		} else if expectedStruct.Field(i).CanInterface() {
+			if actualField.Kind() == reflect.Struct {
+				diffs = append(diffs, structMatcher(expectedStruct.Field(i), actualStruct.Field(i), subFieldPath+".", opts, shouldFilter, filterInclude, nestedFilterFields...)...)
 			} else {
-				structCanInterface = false
+				diffs = append(diffs, fieldDiff(expectedStruct.Field(i).Interface(), actualStruct.Field(i).Interface(), subFieldPath)...)
 			}
-
+		} else {
+			structCanInterface = false
 		}
-		if !structCanInterface {
-			extra := []interface{}{
-				"Mismatch on unexported field within top level struct",
-			}
-			if fieldPath != "" {
-				structName := fieldPath[0 : len(fieldPath)-1] // remove trailing dot.
-				extra = []interface{}{
-					"Mismatch on unexported field within %s", structName,
-				}
-			}
-			Expect(actualStruct.Interface()).To(Equal(expectedStruct.Interface()), extra...)
+	}
+	if !structCanInterface {
+		structName := ""
+		description := "Mismatch on unexported field within top level struct"
+		if fieldPath != "" {
+			structName = fieldPath[0 : len(fieldPath)-1] // remove trailing dot.
+			description = fmt.Sprintf("Mismatch on unexported field within %s", structName)
 		}
-	})...)
-	return mismatches
+		diffs = append(diffs, valueDiff(expectedStruct.Interface(), actualStruct.Interface(), structName, description)...)
+	}
+	return diffs
 }
 
 // Deprecated: Use structmatcher.MatchStruct() GomegaMatcher
@@ -119,10 +277,12 @@ func ExpectStructsToMatchIncluding(expected interface{}, actual interface{}, inc
 }
 
 type Matcher struct {
-	expected        interface{}
-	includingFields []string
-	excludingFields []string
-	mismatches      []string
+	expected         interface{}
+	includingFields  []string
+	excludingFields  []string
+	unorderedFields  []string
+	fieldComparators map[string]Comparator
+	diffs            []Difference
 }
 
 var _ types.GomegaMatcher = &Matcher{}
@@ -134,18 +294,29 @@ func MatchStruct(expected interface{}) *Matcher {
 }
 
 func (m *Matcher) Match(actual interface{}) (success bool, err error) {
+	m.diffs = m.Diff(actual)
+	return len(m.diffs) == 0, nil
+}
+
+/*
+ * Diff compares actual to the expected struct as Match does, but returns the
+ * differences found instead of a success value.
+ */
+func (m *Matcher) Diff(actual interface{}) []Difference {
+	opts := matchOptions{unorderedFields: make(map[string]bool), fieldComparators: m.fieldComparators}
+	for _, field := range m.unorderedFields {
+		opts.unorderedFields[field] = true
+	}
 	if m.includingFields != nil {
-		m.mismatches = StructMatcher(m.expected, actual, true, true, m.includingFields...)
+		return structDiff(m.expected, actual, opts, true, true, m.includingFields...)
 	} else if m.excludingFields != nil {
-		m.mismatches = StructMatcher(m.expected, actual, true, false, m.excludingFields...)
-	} else {
-		m.mismatches = StructMatcher(m.expected, actual, false, false)
+		return structDiff(m.expected, actual, opts, true, false, m.excludingFields...)
 	}
-	return len(m.mismatches) == 0, nil
+	return structDiff(m.expected, actual, opts, false, false)
 }
 
 func (m *Matcher) FailureMessage(actual interface{}) (message string) {
-	return "Expected structs to match but:\n" + strings.Join(m.mismatches, "\n")
+	return "Expected structs to match but:\n" + strings.Join(mismatchMessages(m.diffs), "\n")
 }
 
 func (m *Matcher) NegatedFailureMessage(actual interface{}) (message string) {
@@ -161,3 +332,26 @@ func (m *Matcher) ExcludingFields(fields ...string) *Matcher {
 	m.excludingFields = fields
 	return m
 }
+
+/*
+ * IgnoringSliceOrder compares the given slice fields without regard to the
+ * order of their elements, e.g. for catalog objects queried without an ORDER
+ * BY.  Nested fields are named with dots, as with IncludingFields.
+ */
+func (m *Matcher) IgnoringSliceOrder(fields ...string) *Matcher {
+	m.unorderedFields = append(m.unorderedFields, fields...)
+	return m
+}
+
+/*
+ * UsingFieldComparator compares the given field with compare instead of
+ * gomega.Equal(), e.g. to allow a timestamp to differ within a tolerance.
+ * Nested fields are named with dots, as with IncludingFields.
+ */
+func (m *Matcher) UsingFieldComparator(field string, compare Comparator) *Matcher {
+	if m.fieldComparators == nil {
+		m.fieldComparators = make(map[string]Comparator)
+	}
+	m.fieldComparators[field] = compare
+	return m
+}
@@ -326,4 +326,92 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 				"    }"))
 		})
 	})
+
+	Describe("Maps, slices of pointers, and unordered slices", func() {
+		type CollectionStruct struct {
+			Name        string
+			Map         map[string]SimpleStruct
+			PtrMap      map[int]*SimpleStruct
+			PtrSlice    []*SimpleStruct
+			NestedSlice []SimpleStruct
+			Names       []string
+		}
+		It("returns mismatches in nested struct maps by key", func() {
+			struct1 := CollectionStruct{Map: map[string]SimpleStruct{"a": {Field1: 1}, "b": {Field1: 2}}}
+			struct2 := CollectionStruct{Map: map[string]SimpleStruct{"a": {Field1: 1}, "b": {Field1: 3}}}
+			mismatches := structmatcher.StructMatcher(&struct1, &struct2, false, false)
+			Expect(mismatches).To(Equal([]string{"Mismatch on field Map[b].Field1\nExpected\n    <int>: 3\nto equal\n    <int>: 2"}))
+		})
+		It("returns mismatches on the keys of nested struct maps", func() {
+			struct1 := CollectionStruct{Map: map[string]SimpleStruct{"a": {Field1: 1}, "b": {Field1: 2}}}
+			struct2 := CollectionStruct{Map: map[string]SimpleStruct{"a": {Field1: 1}, "c": {Field1: 2}}}
+			mismatches := structmatcher.StructMatcher(&struct1, &struct2, false, false)
+			Expect(mismatches).To(HaveLen(1))
+			Expect(mismatches[0]).To(HavePrefix("Mismatch on keys of field Map"))
+		})
+		It("returns mismatches in maps of pointers to structs", func() {
+			struct1 := CollectionStruct{PtrMap: map[int]*SimpleStruct{1: {Field2: "one"}, 2: nil}}
+			struct2 := CollectionStruct{PtrMap: map[int]*SimpleStruct{1: {Field2: "uno"}, 2: nil}}
+			mismatches := structmatcher.StructMatcher(&struct1, &struct2, false, false)
+			Expect(mismatches).To(Equal([]string{"Mismatch on field PtrMap[1].Field2\nExpected\n    <string>: uno\nto equal\n    <string>: one"}))
+		})
+		It("applies filters to the fields of nested struct maps", func() {
+			struct1 := CollectionStruct{Map: map[string]SimpleStruct{"a": {Field1: 1, Field2: "one"}}}
+			struct2 := CollectionStruct{Map: map[string]SimpleStruct{"a": {Field1: 2, Field2: "one"}}}
+			Expect(struct2).To(structmatcher.MatchStruct(struct1).ExcludingFields("Map.Field1"))
+			Expect(struct2).ToNot(structmatcher.MatchStruct(struct1).ExcludingFields("Map.Field2"))
+		})
+		It("returns mismatches in slices of pointers to structs", func() {
+			struct1 := CollectionStruct{PtrSlice: []*SimpleStruct{{Field1: 1}, {Field1: 2}}}
+			struct2 := CollectionStruct{PtrSlice: []*SimpleStruct{{Field1: 1}, {Field1: 4}}}
+			mismatches := structmatcher.StructMatcher(&struct1, &struct2, false, false)
+			Expect(mismatches).To(Equal([]string{"Mismatch on field PtrSlice[1].Field1\nExpected\n    <int>: 4\nto equal\n    <int>: 2"}))
+		})
+		It("returns mismatches on nil elements in slices of pointers to structs", func() {
+			struct1 := CollectionStruct{PtrSlice: []*SimpleStruct{{Field1: 1}, nil}}
+			struct2 := CollectionStruct{PtrSlice: []*SimpleStruct{{Field1: 1}, {Field1: 2}}}
+			mismatches := structmatcher.StructMatcher(&struct1, &struct2, false, false)
+			Expect(mismatches).To(HaveLen(1))
+			Expect(mismatches[0]).To(HavePrefix("Mismatch on field PtrSlice[1]\n"))
+		})
+		It("matches slices with elements in a different order when ignoring slice order", func() {
+			struct1 := CollectionStruct{
+				NestedSlice: []SimpleStruct{{Field1: 1}, {Field1: 2}, {Field1: 2}},
+				PtrSlice:    []*SimpleStruct{{Field1: 1}, {Field1: 2}},
+				Names:       []string{"a", "b"},
+			}
+			struct2 := CollectionStruct{
+				NestedSlice: []SimpleStruct{{Field1: 2}, {Field1: 1}, {Field1: 2}},
+				PtrSlice:    []*SimpleStruct{{Field1: 2}, {Field1: 1}},
+				Names:       []string{"b", "a"},
+			}
+			Expect(struct2).ToNot(structmatcher.MatchStruct(struct1))
+			Expect(struct2).To(structmatcher.MatchStruct(struct1).IgnoringSliceOrder("NestedSlice", "PtrSlice", "Names"))
+		})
+		It("reports unmatched elements when ignoring slice order", func() {
+			struct1 := CollectionStruct{Names: []string{"a", "b", "c"}}
+			struct2 := CollectionStruct{Names: []string{"c", "a", "d"}}
+			messages := InterceptGomegaFailures(func() {
+				Expect(struct2).To(structmatcher.MatchStruct(struct1).IgnoringSliceOrder("Names"))
+			})
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0]).To(ContainSubstring("Mismatch on field Names ignoring order; unmatched elements"))
+			Expect(messages[0]).To(ContainSubstring(`[<string>"d"]`))
+			Expect(messages[0]).To(ContainSubstring(`[<string>"b"]`))
+		})
+		It("applies filters when ignoring the order of nested struct slices", func() {
+			struct1 := CollectionStruct{NestedSlice: []SimpleStruct{{Field1: 1, Field2: "one"}, {Field1: 2, Field2: "two"}}}
+			struct2 := CollectionStruct{NestedSlice: []SimpleStruct{{Field1: 2, Field2: "deux"}, {Field1: 1, Field2: "un"}}}
+			Expect(struct2).To(structmatcher.MatchStruct(struct1).ExcludingFields("NestedSlice.Field2").IgnoringSliceOrder("NestedSlice"))
+		})
+		It("ignores the order of slices within nested structs by their dotted path", func() {
+			type OuterStruct struct {
+				Inner CollectionStruct
+			}
+			struct1 := OuterStruct{Inner: CollectionStruct{Names: []string{"a", "b"}}}
+			struct2 := OuterStruct{Inner: CollectionStruct{Names: []string{"b", "a"}}}
+			Expect(struct2).ToNot(structmatcher.MatchStruct(struct1).IgnoringSliceOrder("Names"))
+			Expect(struct2).To(structmatcher.MatchStruct(struct1).IgnoringSliceOrder("Inner.Names"))
+		})
+	})
 })
@@ -0,0 +1,99 @@
+package testhelper
+
+/*
+ * This file contains a fake clock for testing code that waits, retries, or
+ * times out through operating.System.
+ */
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+type clockWaiter struct {
+	deadline time.Time
+	channel  chan time.Time
+}
+
+/*
+ * FakeClock replaces operating.System.Now and operating.System.After.  Time
+ * only passes when Advance is called, at which point every waiter whose
+ * deadline has been reached is released.  Each duration passed to After is
+ * recorded in Waits, so a retry schedule can be checked directly.
+ *
+ * If AutoAdvance is true, each call to After advances the clock by the
+ * requested duration and returns immediately, so code under test that waits
+ * in the same goroutine runs to completion without any coordination.
+ */
+type FakeClock struct {
+	AutoAdvance bool
+	Waits       []time.Duration
+	now         time.Time
+	waiters     []clockWaiter
+	mutex       sync.Mutex
+}
+
+/*
+ * As with MockExecCommand, this should be followed by a call to
+ * InitializeSystemFunctions in a defer statement or AfterEach block.
+ */
+func MockClock(start time.Time) *FakeClock {
+	clock := &FakeClock{now: start}
+	operating.System.Now = clock.Now
+	operating.System.After = clock.After
+	return clock
+}
+
+func (clock *FakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *FakeClock) After(d time.Duration) <-chan time.Time {
+	clock.mutex.Lock()
+	clock.Waits = append(clock.Waits, d)
+	channel := make(chan time.Time, 1)
+	clock.waiters = append(clock.waiters, clockWaiter{deadline: clock.now.Add(d), channel: channel})
+	autoAdvance := clock.AutoAdvance
+	clock.mutex.Unlock()
+	if autoAdvance {
+		clock.Advance(d)
+	} else {
+		clock.Advance(0)
+	}
+	return channel
+}
+
+// Advance moves the clock forward by d and releases any waiters that are due
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = clock.now.Add(d)
+	sort.SliceStable(clock.waiters, func(i, j int) bool {
+		return clock.waiters[i].deadline.Before(clock.waiters[j].deadline)
+	})
+	remaining := make([]clockWaiter, 0)
+	for _, waiter := range clock.waiters {
+		if waiter.deadline.After(clock.now) {
+			remaining = append(remaining, waiter)
+			continue
+		}
+		waiter.channel <- clock.now
+	}
+	clock.waiters = remaining
+}
+
+/*
+ * NumWaiters returns the number of calls to After that have not yet been
+ * released, so a test can wait (e.g. with Eventually) for the code under test
+ * to start waiting before calling Advance.
+ */
+func (clock *FakeClock) NumWaiters() int {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return len(clock.waiters)
+}
@@ -0,0 +1,68 @@
+package testhelper
+
+/*
+ * This file contains a helper that makes everything operating.System reports
+ * about the process and its surroundings deterministic, for tests that would
+ * otherwise override each function separately.
+ */
+
+import (
+	"os/user"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+/*
+ * A DeterministicEnvironment holds the values that operating.System reports
+ * while it is in effect.  The fields other than Clock and Files are read each
+ * time the corresponding function is called, so a test may change them after
+ * setting up the environment, e.g. to pin a different PID.
+ */
+type DeterministicEnvironment struct {
+	Clock    *FakeClock
+	Files    *MemoryFS
+	User     *user.User
+	Hostname string
+	Pid      int
+}
+
+/*
+ * SetupDeterministicEnvironment replaces operating.System with a copy in which
+ * Now and After use a FakeClock starting at 2017-01-01 01:01:01 UTC, the file
+ * functions use a MemoryFS, and CurrentUser, Hostname, and Getpid return the
+ * values in the returned environment.  These default to the values the tests
+ * in this repository have long used, so that the log prefix of a message
+ * logged at the start time is
+ *   20170101:01:01:01 testProgram:testUser:testHost:000000-[INFO]:-
+ *
+ * The returned function restores operating.System as it was before the call,
+ * including any overrides made beforehand, and should be called in a defer
+ * statement or AfterEach block:
+ *   var env *testhelper.DeterministicEnvironment
+ *   var cleanup func()
+ *   BeforeEach(func() { env, cleanup = testhelper.SetupDeterministicEnvironment() })
+ *   AfterEach(func() { cleanup() })
+ */
+func SetupDeterministicEnvironment() (*DeterministicEnvironment, func()) {
+	original := operating.System
+	system := *original
+	operating.System = &system
+	cleanup := func() { operating.System = original }
+
+	env := &DeterministicEnvironment{
+		Clock:    MockClock(time.Date(2017, time.January, 1, 1, 1, 1, 1, time.UTC)),
+		Files:    MockFileSystem(),
+		User:     &user.User{Username: "testUser", Uid: "1000", Gid: "1000", Name: "Test User", HomeDir: "testDir"},
+		Hostname: "testHost",
+		Pid:      0,
+	}
+	operating.System.Local = time.UTC
+	operating.System.CurrentUser = func() (*user.User, error) {
+		currentUser := *env.User
+		return &currentUser, nil
+	}
+	operating.System.Hostname = func() (string, error) { return env.Hostname, nil }
+	operating.System.Getpid = func() int { return env.Pid }
+	return env, cleanup
+}
@@ -0,0 +1,118 @@
+package testhelper
+
+/*
+ * This file contains a builder for MemoryFS fixtures and assertion helpers
+ * for checking the files in a MemoryFS, to reduce the setup and checking
+ * boilerplate in file-heavy tests.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+type fixtureEntry struct {
+	path     string
+	contents string
+	mode     os.FileMode
+	isDir    bool
+}
+
+/*
+ * A FixtureFS describes a set of files and directories, e.g.
+ *   var dataDir = testhelper.NewFixtureFS().
+ *   	WithDir("/data/gpseg0", 0700).
+ *   	WithFile("/data/gpseg0/postgresql.conf", "port = 6000\n", 0600)
+ * which Install creates in a new MemoryFS.  As the builder methods return a
+ * copy, a fixture can be declared once and extended by individual tests
+ * without affecting other tests.
+ *
+ * MemoryFS does not support symbolic links, so neither does FixtureFS.
+ */
+type FixtureFS struct {
+	entries []fixtureEntry
+}
+
+func NewFixtureFS() FixtureFS {
+	return FixtureFS{}
+}
+
+func (fixture FixtureFS) with(entry fixtureEntry) FixtureFS {
+	entries := make([]fixtureEntry, len(fixture.entries), len(fixture.entries)+1)
+	copy(entries, fixture.entries)
+	return FixtureFS{entries: append(entries, entry)}
+}
+
+// WithFile adds a file, creating its parent directories with mode 0755 if they are not added separately
+func (fixture FixtureFS) WithFile(path string, contents string, mode os.FileMode) FixtureFS {
+	return fixture.with(fixtureEntry{path: path, contents: contents, mode: mode})
+}
+
+// WithDir adds a directory and any missing parent directories
+func (fixture FixtureFS) WithDir(path string, mode os.FileMode) FixtureFS {
+	return fixture.with(fixtureEntry{path: path, mode: mode, isDir: true})
+}
+
+/*
+ * Install calls MockFileSystem and creates the fixture's files and directories
+ * in the returned MemoryFS, in the order they were added.  As with
+ * MockFileSystem, this should be followed by a call to
+ * InitializeSystemFunctions in a defer statement or AfterEach block.
+ */
+func (fixture FixtureFS) Install() *MemoryFS {
+	fs := MockFileSystem()
+	fixture.Populate(fs)
+	return fs
+}
+
+// Populate creates the fixture's files and directories in an existing MemoryFS, e.g. that of a DeterministicEnvironment
+func (fixture FixtureFS) Populate(fs *MemoryFS) {
+	for _, entry := range fixture.entries {
+		if entry.isDir {
+			Expect(fs.MkdirAll(entry.path, entry.mode)).To(Succeed())
+			Expect(fs.Chmod(entry.path, entry.mode)).To(Succeed())
+			continue
+		}
+		fs.WriteFile(entry.path, entry.contents)
+		Expect(fs.Chmod(entry.path, entry.mode)).To(Succeed())
+	}
+}
+
+// ExpectFileContent expects the file to exist and to have exactly the given contents
+func ExpectFileContent(fs *MemoryFS, path string, expected string) {
+	contents, ok := fs.Contents(path)
+	ExpectWithOffset(1, ok).To(BeTrue(), "Expected file %s to exist", path)
+	ExpectWithOffset(1, contents).To(Equal(expected), "Unexpected contents in file %s", path)
+}
+
+/*
+ * ExpectTree expects the files and directories beneath root to be exactly
+ * those given, as paths relative to root with directories ending in "/",
+ * in any order, e.g.
+ *   testhelper.ExpectTree(fs, "/data", "gpseg0/", "gpseg0/postgresql.conf")
+ */
+func ExpectTree(fs *MemoryFS, root string, expected ...string) {
+	prefix := filepath.Clean(root)
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	tree := make([]string, 0)
+	for _, path := range fs.Paths() {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		relative := strings.TrimPrefix(path, prefix)
+		if info, err := fs.Stat(path); err == nil && info.IsDir() {
+			relative += "/"
+		}
+		tree = append(tree, relative)
+	}
+	sort.Strings(tree)
+	sorted := append([]string{}, expected...)
+	sort.Strings(sorted)
+	ExpectWithOffset(1, tree).To(Equal(sorted), "Unexpected files beneath %s", root)
+}
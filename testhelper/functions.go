@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gp-common-go-libs/operating"
@@ -71,13 +72,67 @@ func ExpectVersionQuery(mock sqlmock.Sqlmock, versionStr string) {
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.version() AS versionstring")).WillReturnRows(versionRow)
 }
 
+/*
+ * ExpectVersionQuery returns only the Greenplum portion of the version string,
+ * which is all that dbconn needs.  ExpectFullVersionQuery instead returns the
+ * full string that a server of the given major version would return, with the
+ * matching PostgreSQL version and a build suffix, for tests of code that
+ * parses the rest of the string.
+ */
+func FullVersionString(versionStr string) string {
+	postgresVersion := "8.3.23"
+	if version := dbconn.NewVersion(versionStr); version.AtLeast("7") {
+		postgresVersion = "12.12"
+	} else if version.AtLeast("6") {
+		postgresVersion = "9.4.26"
+	}
+	return fmt.Sprintf("PostgreSQL %s (Greenplum Database %s build commit:0000000000000000000000000000000000000000) on x86_64-unknown-linux-gnu, compiled by gcc", postgresVersion, versionStr)
+}
+
+func ExpectFullVersionQuery(mock sqlmock.Sqlmock, versionStr string) {
+	versionRow := sqlmock.NewRows([]string{"versionstring"}).AddRow(FullVersionString(versionStr))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.version() AS versionstring")).WillReturnRows(versionRow)
+}
+
 func CreateAndConnectMockDB(numConns int) (*dbconn.DBConn, sqlmock.Sqlmock) {
+	return CreateAndConnectMockDBWithVersion(numConns, "5.1.0")
+}
+
+func CreateAndConnectMockDBWithVersion(numConns int, versionStr string) (*dbconn.DBConn, sqlmock.Sqlmock) {
 	connection, mock := CreateMockDBConn()
-	ExpectVersionQuery(mock, "5.1.0")
+	ExpectVersionQuery(mock, versionStr)
 	connection.MustConnect(numConns)
 	return connection, mock
 }
 
+/*
+ * The following functions set up the expectation for the query run by
+ * cluster.GetSegmentConfiguration and return the given segments from it.
+ * ExpectSegmentConfigQuery matches the query for any version, while
+ * ExpectSegmentConfigQueryForVersion only matches the query used for the given
+ * version, which joins to pg_filespace_entry to get data directories before
+ * GPDB 6 and reads them from gp_segment_configuration from GPDB 6 onward.
+ */
+func SegmentConfigRows(segs []cluster.SegConfig) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"dbid", "contentid", "role", "preferredrole", "mode", "status", "port", "hostname", "address", "datadir"})
+	for _, seg := range segs {
+		rows.AddRow(seg.DbID, seg.ContentID, seg.Role, seg.PreferredRole, seg.Mode, seg.Status, seg.Port, seg.Hostname, seg.Address, seg.DataDir)
+	}
+	return rows
+}
+
+func ExpectSegmentConfigQuery(mock sqlmock.Sqlmock, segs []cluster.SegConfig) {
+	mock.ExpectQuery(`SELECT (.*) FROM gp_segment_configuration`).WillReturnRows(SegmentConfigRows(segs))
+}
+
+func ExpectSegmentConfigQueryForVersion(mock sqlmock.Sqlmock, versionStr string, segs []cluster.SegConfig) {
+	queryRegexp := `SELECT (.*) FROM gp_segment_configuration (WHERE role|ORDER BY)`
+	if dbconn.NewVersion(versionStr).Before("6") {
+		queryRegexp = `SELECT (.*) FROM gp_segment_configuration s JOIN pg_filespace_entry e ON s.dbid = e.fsedbid JOIN pg_filespace f ON e.fsefsoid = f.oid`
+	}
+	mock.ExpectQuery(queryRegexp).WillReturnRows(SegmentConfigRows(segs))
+}
+
 func ExpectRegexp(buffer *gbytes.Buffer, testStr string) {
 	Expect(buffer).Should(gbytes.Say(regexp.QuoteMeta(testStr)))
 }
@@ -109,3 +164,25 @@ func MockFileContents(contents string) {
 	_, _ = w.Write([]byte(contents))
 	_ = w.Close()
 }
+
+/*
+ * The following two functions replace the process-related functions in
+ * operating.System with test doubles and return them for inspection.  As with
+ * MockFileContents, they should be followed by a call to
+ * InitializeSystemFunctions in a defer statement or AfterEach block.
+ */
+func MockExecCommand(stdout string, stderr string, exitCode int) *TestCommandRunner {
+	runner := &TestCommandRunner{Stdout: stdout, Stderr: stderr, ExitCode: exitCode}
+	operating.System.ExecCommand = runner.ExecCommand
+	operating.System.ExecCommandContext = runner.ExecCommandContext
+	return runner
+}
+
+func MockProcessFunctions() *TestProcessManager {
+	manager := &TestProcessManager{NextPid: 1000}
+	operating.System.StartProcess = manager.StartProcess
+	operating.System.FindProcess = manager.FindProcess
+	operating.System.Signal = manager.Signal
+	operating.System.Kill = manager.Kill
+	return manager
+}
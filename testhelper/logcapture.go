@@ -0,0 +1,158 @@
+package testhelper
+
+/*
+ * This file contains structs and functions for capturing log messages by
+ * level and matching them in tests, without needing to match the full log
+ * prefix in a raw output buffer.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/types"
+)
+
+type LogEntry struct {
+	Level   string
+	Message string
+}
+
+/*
+ * A LogCapture is used as the log file of a test logger and records each
+ * message written to it as a LogEntry.  As log file verbosity defaults to
+ * LOGDEBUG, every message is recorded regardless of the shell verbosity.
+ * Verbose and Debug messages both have the level DEBUG, and Fatal messages
+ * have the level CRITICAL, as in log files.
+ *
+ * If the code under test sets a custom log prefix, the prefix cannot be
+ * separated from the message, so the whole line is recorded with no level.
+ */
+type LogCapture struct {
+	Stdout  *gbytes.Buffer
+	Stderr  *gbytes.Buffer
+	mutex   sync.Mutex
+	entries []LogEntry
+}
+
+var logLevelPattern = regexp.MustCompile(`-\[([A-Z]+)\]:-`)
+
+func (capture *LogCapture) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+	entry := LogEntry{Message: line}
+	if location := logLevelPattern.FindStringSubmatchIndex(line); location != nil {
+		entry.Level = line[location[2]:location[3]]
+		entry.Message = line[location[1]:]
+	}
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	capture.entries = append(capture.entries, entry)
+	return len(p), nil
+}
+
+func (capture *LogCapture) Entries() []LogEntry {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	return append([]LogEntry{}, capture.entries...)
+}
+
+func (capture *LogCapture) EntriesAtLevel(level string) []LogEntry {
+	entries := make([]LogEntry, 0)
+	for _, entry := range capture.Entries() {
+		if entry.Level == level {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (capture *LogCapture) Reset() {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	capture.entries = nil
+}
+
+/*
+ * SetupTestLogCapture is an alternative to SetupTestLogger for tests that use
+ * the log matchers below.  Shell output is still sent to buffers so that it
+ * can be checked separately if needed.
+ */
+func SetupTestLogCapture() *LogCapture {
+	capture := &LogCapture{Stdout: gbytes.NewBuffer(), Stderr: gbytes.NewBuffer()}
+	testLogger := gplog.NewLogger(capture.Stdout, capture.Stderr, capture, "LogCapture", gplog.LOGINFO, "testProgram")
+	gplog.SetLogger(testLogger)
+	return capture
+}
+
+/*
+ * The following matchers succeed if a *LogCapture contains a message at the
+ * given level that contains substring, e.g.
+ *   Expect(capture).To(testhelper.HaveLoggedError("could not connect"))
+ */
+
+func HaveLogged(level string, substring string) types.GomegaMatcher {
+	return &logMatcher{level: level, substring: substring}
+}
+
+func HaveLoggedInfo(substring string) types.GomegaMatcher {
+	return HaveLogged("INFO", substring)
+}
+
+func HaveLoggedDebug(substring string) types.GomegaMatcher {
+	return HaveLogged("DEBUG", substring)
+}
+
+func HaveLoggedWarn(substring string) types.GomegaMatcher {
+	return HaveLogged("WARNING", substring)
+}
+
+func HaveLoggedError(substring string) types.GomegaMatcher {
+	return HaveLogged("ERROR", substring)
+}
+
+func HaveLoggedCritical(substring string) types.GomegaMatcher {
+	return HaveLogged("CRITICAL", substring)
+}
+
+type logMatcher struct {
+	level     string
+	substring string
+}
+
+func (matcher *logMatcher) Match(actual interface{}) (bool, error) {
+	capture, ok := actual.(*LogCapture)
+	if !ok {
+		return false, fmt.Errorf("HaveLogged matchers expect a *testhelper.LogCapture, got:\n%s", format.Object(actual, 1))
+	}
+	for _, entry := range capture.EntriesAtLevel(matcher.level) {
+		if strings.Contains(entry.Message, matcher.substring) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (matcher *logMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected a %s message containing\n\t%q\nto have been logged, but the log contained:\n%s", matcher.level, matcher.substring, formatEntries(actual))
+}
+
+func (matcher *logMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected no %s message containing\n\t%q\nto have been logged, but the log contained:\n%s", matcher.level, matcher.substring, formatEntries(actual))
+}
+
+func formatEntries(actual interface{}) string {
+	capture, ok := actual.(*LogCapture)
+	if !ok {
+		return format.Object(actual, 1)
+	}
+	lines := make([]string, 0)
+	for _, entry := range capture.Entries() {
+		lines = append(lines, fmt.Sprintf("\t[%s] %s", entry.Level, entry.Message))
+	}
+	return strings.Join(lines, "\n")
+}
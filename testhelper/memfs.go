@@ -0,0 +1,319 @@
+package testhelper
+
+/*
+ * This file contains an in-memory filesystem for testing code that reads and
+ * writes files through operating.System.
+ */
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+type memoryFile struct {
+	contents []byte
+	mode     os.FileMode
+	modTime  time.Time
+}
+
+/*
+ * MemoryFS replaces the file functions in operating.System with an in-memory
+ * filesystem, so that tests can check the files the code under test creates
+ * without touching the disk.  Paths are cleaned but not made absolute, so a
+ * relative path and the equivalent absolute path are different files.
+ *
+ * Directories are created by MkdirAll and by writing a file, which creates its
+ * parent directories as the code under test would be expected to have done.
+ * Modification times come from operating.System.Now, so they follow a
+ * FakeClock if one is in use.  TempFile returns a real *os.File, so it is not
+ * replaced.
+ */
+type MemoryFS struct {
+	files map[string]*memoryFile
+	mutex sync.Mutex
+}
+
+/*
+ * As with MockExecCommand, this should be followed by a call to
+ * InitializeSystemFunctions in a defer statement or AfterEach block.
+ */
+func MockFileSystem() *MemoryFS {
+	fs := &MemoryFS{files: map[string]*memoryFile{}}
+	operating.System.Chmod = fs.Chmod
+	operating.System.Glob = fs.Glob
+	operating.System.IsNotExist = os.IsNotExist
+	operating.System.Link = fs.Link
+	operating.System.MkdirAll = fs.MkdirAll
+	operating.System.OpenFileRead = fs.OpenFileRead
+	operating.System.OpenFileWrite = fs.OpenFileWrite
+	operating.System.ReadFile = fs.ReadFile
+	operating.System.Remove = fs.Remove
+	operating.System.RemoveAll = fs.RemoveAll
+	operating.System.Stat = fs.Stat
+	return fs
+}
+
+// WriteFile creates or replaces a file, e.g. to set up a file for the code under test to read
+func (fs *MemoryFS) WriteFile(name string, contents string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	_ = fs.mkdirAll(filepath.Dir(name), 0755)
+	fs.files[filepath.Clean(name)] = &memoryFile{contents: []byte(contents), mode: 0644, modTime: operating.System.Now()}
+}
+
+// Contents returns the contents of a file, and false if the file does not exist or is a directory
+func (fs *MemoryFS) Contents(name string) (string, bool) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	file, ok := fs.files[filepath.Clean(name)]
+	if !ok || file.mode.IsDir() {
+		return "", false
+	}
+	return string(file.contents), true
+}
+
+// Paths returns the path of every file and directory, sorted
+func (fs *MemoryFS) Paths() []string {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	paths := make([]string, 0, len(fs.files))
+	for path := range fs.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (fs *MemoryFS) Chmod(name string, mode os.FileMode) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	file, ok := fs.files[filepath.Clean(name)]
+	if !ok {
+		return notExistError("chmod", name)
+	}
+	file.mode = file.mode&os.ModeType | mode.Perm()
+	return nil
+}
+
+func (fs *MemoryFS) Glob(pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	matches := make([]string, 0)
+	for _, path := range fs.Paths() {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			matches = append(matches, path)
+		}
+	}
+	return matches, nil
+}
+
+// Link makes newname refer to the same file as oldname, so that writes through either name are visible through both
+func (fs *MemoryFS) Link(oldname string, newname string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	file, ok := fs.files[filepath.Clean(oldname)]
+	switch {
+	case !ok:
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrNotExist}
+	case file.mode.IsDir():
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EPERM}
+	}
+	path := filepath.Clean(newname)
+	if _, exists := fs.files[path]; exists {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrExist}
+	}
+	if parent, ok := fs.files[filepath.Dir(path)]; !ok || !parent.mode.IsDir() {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+	fs.files[path] = file
+	return nil
+}
+
+func (fs *MemoryFS) MkdirAll(path string, perm os.FileMode) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.mkdirAll(path, perm)
+}
+
+func (fs *MemoryFS) mkdirAll(path string, perm os.FileMode) error {
+	path = filepath.Clean(path)
+	if file, ok := fs.files[path]; ok {
+		if !file.mode.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: path, Err: os.ErrExist}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(path); parent != path {
+		if err := fs.mkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	fs.files[path] = &memoryFile{mode: os.ModeDir | perm.Perm(), modTime: operating.System.Now()}
+	return nil
+}
+
+func (fs *MemoryFS) OpenFileRead(name string, flag int, perm os.FileMode) (operating.ReadCloserAt, error) {
+	contents, err := fs.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return memoryReader{bytes.NewReader(contents)}, nil
+}
+
+/*
+ * Writes are visible to ReadFile and Contents as soon as they are made, rather
+ * than when the file is closed, so a test can check a log file that the code
+ * under test keeps open.
+ */
+func (fs *MemoryFS) OpenFileWrite(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	path := filepath.Clean(name)
+	file, exists := fs.files[path]
+	switch {
+	case exists && file.mode.IsDir():
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, notExistError("open", name)
+	case !exists:
+		if parent, ok := fs.files[filepath.Dir(path)]; !ok || !parent.mode.IsDir() {
+			return nil, notExistError("open", name)
+		}
+		file = &memoryFile{mode: perm.Perm(), modTime: operating.System.Now()}
+		fs.files[path] = file
+	}
+	if flag&os.O_TRUNC != 0 {
+		file.contents = nil
+	}
+	return &memoryWriter{fs: fs, file: file, appending: flag&os.O_APPEND != 0}, nil
+}
+
+func (fs *MemoryFS) ReadFile(name string) ([]byte, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	file, ok := fs.files[filepath.Clean(name)]
+	if !ok {
+		return nil, notExistError("open", name)
+	}
+	if file.mode.IsDir() {
+		return nil, &os.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	return append([]byte{}, file.contents...), nil
+}
+
+func (fs *MemoryFS) Remove(name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	path := filepath.Clean(name)
+	file, ok := fs.files[path]
+	if !ok {
+		return notExistError("remove", name)
+	}
+	if file.mode.IsDir() {
+		for other := range fs.files {
+			if strings.HasPrefix(other, path+string(filepath.Separator)) {
+				return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+			}
+		}
+	}
+	delete(fs.files, path)
+	return nil
+}
+
+func (fs *MemoryFS) RemoveAll(name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	path := filepath.Clean(name)
+	for other := range fs.files {
+		if other == path || strings.HasPrefix(other, path+string(filepath.Separator)) {
+			delete(fs.files, other)
+		}
+	}
+	return nil
+}
+
+func (fs *MemoryFS) Stat(name string) (os.FileInfo, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	file, ok := fs.files[filepath.Clean(name)]
+	if !ok {
+		return nil, notExistError("stat", name)
+	}
+	return memoryFileInfo{name: filepath.Base(name), size: int64(len(file.contents)), mode: file.mode, modTime: file.modTime}, nil
+}
+
+// Errors wrap os.ErrNotExist as the os package's do, so os.IsNotExist recognizes them
+func notExistError(op string, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (reader memoryReader) Close() error {
+	return nil
+}
+
+type memoryWriter struct {
+	fs        *MemoryFS
+	file      *memoryFile
+	offset    int
+	appending bool
+	closed    bool
+}
+
+func (writer *memoryWriter) Write(p []byte) (int, error) {
+	writer.fs.mutex.Lock()
+	defer writer.fs.mutex.Unlock()
+	if writer.closed {
+		return 0, os.ErrClosed
+	}
+	if writer.appending {
+		writer.offset = len(writer.file.contents)
+	}
+	end := writer.offset + len(p)
+	if end > len(writer.file.contents) {
+		writer.file.contents = append(writer.file.contents, make([]byte, end-len(writer.file.contents))...)
+	}
+	copy(writer.file.contents[writer.offset:], p)
+	writer.offset = end
+	writer.file.modTime = operating.System.Now()
+	return len(p), nil
+}
+
+func (writer *memoryWriter) Close() error {
+	writer.fs.mutex.Lock()
+	defer writer.fs.mutex.Unlock()
+	if writer.closed {
+		return os.ErrClosed
+	}
+	writer.closed = true
+	return nil
+}
+
+type memoryFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (info memoryFileInfo) Name() string       { return info.name }
+func (info memoryFileInfo) Size() int64        { return info.size }
+func (info memoryFileInfo) Mode() os.FileMode  { return info.mode }
+func (info memoryFileInfo) ModTime() time.Time { return info.modTime }
+func (info memoryFileInfo) IsDir() bool        { return info.mode.IsDir() }
+func (info memoryFileInfo) Sys() interface{}   { return nil }
@@ -6,6 +6,12 @@ package testhelper
 
 import (
 	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
 	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/jmoiron/sqlx"
@@ -60,6 +66,7 @@ type TestExecutor struct {
 	LocalErrors   []error
 	LocalCommands []string
 	LocalContexts []context.Context
+	LocalOptions  []cluster.LocalOptions
 
 	ClusterOutput   *cluster.RemoteOutput
 	ClusterOutputs  []*cluster.RemoteOutput
@@ -74,34 +81,35 @@ type TestExecutor struct {
 }
 
 func (executor *TestExecutor) ExecuteLocalCommand(commandStr string) (string, error) {
-	executor.NumExecutions++
-	executor.NumLocalExecutions++
 	executor.LocalCommands = append(executor.LocalCommands, commandStr)
-	if (executor.LocalOutputs == nil && executor.LocalErrors != nil) || (executor.LocalOutputs != nil && executor.LocalErrors == nil) {
-		gplog.Fatal(nil, "If one of LocalOutputs or LocalErrors is set, both must be set")
-	} else if executor.LocalOutputs != nil && executor.LocalErrors != nil && len(executor.LocalOutputs) != len(executor.LocalErrors) {
-		gplog.Fatal(nil, "Found %d LocalOutputs and %d LocalErrors, but one output and one error must be set for each call", len(executor.LocalOutputs), len(executor.LocalErrors))
-	}
-	if executor.LocalOutputs != nil {
-		if executor.NumLocalExecutions <= len(executor.LocalOutputs) {
-			return executor.LocalOutputs[executor.NumLocalExecutions-1], executor.LocalErrors[executor.NumLocalExecutions-1]
-		} else if executor.UseLastOutput {
-			return executor.LocalOutputs[len(executor.LocalOutputs)-1], executor.LocalErrors[len(executor.LocalErrors)-1]
-		} else if executor.UseDefaultOutput {
-			return executor.LocalOutput, executor.LocalError
-		}
-		gplog.Fatal(nil, "ExecuteLocalCommand called %d times, but only %d outputs and errors provided", executor.NumLocalExecutions, len(executor.LocalOutputs))
-	} else if executor.ErrorOnExecNum == 0 || executor.NumLocalExecutions == executor.ErrorOnExecNum {
-		return executor.LocalOutput, executor.LocalError
-	}
-	return executor.LocalOutput, nil
+	return executor.nextLocalOutput("ExecuteLocalCommand")
 }
 
 func (executor *TestExecutor) ExecuteLocalCommandWithContext(commandStr string, ctx context.Context) (string, error) {
-	executor.NumExecutions++
-	executor.NumLocalExecutions++
 	executor.LocalCommands = append(executor.LocalCommands, commandStr)
 	executor.LocalContexts = append(executor.LocalContexts, ctx)
+	return executor.nextLocalOutput("ExecuteLocalCommandWithContext")
+}
+
+/*
+ * The command is recorded in LocalCommands joined with spaces, and its options
+ * in LocalOptions.  The local output is returned as Stdout, with an ExitCode of
+ * 1 if a local error is returned.
+ */
+func (executor *TestExecutor) ExecuteLocalCommandWithOptions(cmd []string, opts cluster.LocalOptions) (cluster.LocalOutput, error) {
+	executor.LocalCommands = append(executor.LocalCommands, strings.Join(cmd, " "))
+	executor.LocalOptions = append(executor.LocalOptions, opts)
+	stdout, err := executor.nextLocalOutput("ExecuteLocalCommandWithOptions")
+	output := cluster.LocalOutput{Stdout: stdout}
+	if err != nil {
+		output.ExitCode = 1
+	}
+	return output, err
+}
+
+func (executor *TestExecutor) nextLocalOutput(methodName string) (string, error) {
+	executor.NumExecutions++
+	executor.NumLocalExecutions++
 	if (executor.LocalOutputs == nil && executor.LocalErrors != nil) || (executor.LocalOutputs != nil && executor.LocalErrors == nil) {
 		gplog.Fatal(nil, "If one of LocalOutputs or LocalErrors is set, both must be set")
 	} else if executor.LocalOutputs != nil && executor.LocalErrors != nil && len(executor.LocalOutputs) != len(executor.LocalErrors) {
@@ -115,7 +123,7 @@ func (executor *TestExecutor) ExecuteLocalCommandWithContext(commandStr string,
 		} else if executor.UseDefaultOutput {
 			return executor.LocalOutput, executor.LocalError
 		}
-		gplog.Fatal(nil, "ExecuteLocalCommandWithContext called %d times, but only %d outputs and errors provided", executor.NumLocalExecutions, len(executor.LocalOutputs))
+		gplog.Fatal(nil, "%s called %d times, but only %d outputs and errors provided", methodName, executor.NumLocalExecutions, len(executor.LocalOutputs))
 	} else if executor.ErrorOnExecNum == 0 || executor.NumLocalExecutions == executor.ErrorOnExecNum {
 		return executor.LocalOutput, executor.LocalError
 	}
@@ -138,3 +146,79 @@ func (executor *TestExecutor) ExecuteClusterCommand(scope cluster.Scope, command
 	}
 	return executor.ClusterOutput
 }
+
+/*
+ * TestCommandRunner provides replacements for operating.System.ExecCommand and
+ * ExecCommandContext.  Each command passed in is recorded in Commands and
+ * replaced with one that writes Stdout and Stderr and exits with ExitCode, so
+ * code that builds and runs an *exec.Cmd can be tested without side effects.
+ */
+type TestCommandRunner struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Commands [][]string
+	mutex    sync.Mutex
+}
+
+func (runner *TestCommandRunner) record(name string, arg ...string) []string {
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+	runner.Commands = append(runner.Commands, append([]string{name}, arg...))
+	// The output is passed as positional parameters to avoid any need for escaping
+	return []string{"-c", `printf '%s' "$0"; printf '%s' "$1" >&2; exit $2`, runner.Stdout, runner.Stderr, strconv.Itoa(runner.ExitCode)}
+}
+
+func (runner *TestCommandRunner) ExecCommand(name string, arg ...string) *exec.Cmd {
+	return exec.Command("bash", runner.record(name, arg...)...)
+}
+
+func (runner *TestCommandRunner) ExecCommandContext(ctx context.Context, name string, arg ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "bash", runner.record(name, arg...)...)
+}
+
+/*
+ * TestProcessManager provides replacements for the process-related functions in
+ * operating.System.  StartProcess and FindProcess return placeholder processes
+ * that must only be signaled through operating.System; all signals sent to a
+ * process, including kills, are recorded by pid in Signals.
+ */
+type TestProcessManager struct {
+	ErrToReturn      error
+	NextPid          int
+	StartedProcesses [][]string
+	Signals          map[int][]os.Signal
+	mutex            sync.Mutex
+}
+
+func (manager *TestProcessManager) StartProcess(name string, argv []string, attr *os.ProcAttr) (*os.Process, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if manager.ErrToReturn != nil {
+		return nil, manager.ErrToReturn
+	}
+	manager.StartedProcesses = append(manager.StartedProcesses, argv)
+	manager.NextPid++
+	return &os.Process{Pid: manager.NextPid}, nil
+}
+
+func (manager *TestProcessManager) FindProcess(pid int) (*os.Process, error) {
+	if manager.ErrToReturn != nil {
+		return nil, manager.ErrToReturn
+	}
+	return &os.Process{Pid: pid}, nil
+}
+
+func (manager *TestProcessManager) Signal(process *os.Process, sig os.Signal) error {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if manager.Signals == nil {
+		manager.Signals = make(map[int][]os.Signal)
+	}
+	manager.Signals[process.Pid] = append(manager.Signals[process.Pid], sig)
+	return manager.ErrToReturn
+}
+
+func (manager *TestProcessManager) Kill(process *os.Process) error {
+	return manager.Signal(process, os.Kill)
+}